@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readHashFile returns the SHA-256 listed for file in a sha256sum-style
+// checksum file ("<hash>  <name>" per line, "*" marking binary mode). A file
+// with a single bare hash or a single entry applies to any name.
+func readHashFile(path, file string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var entries [][2]string // hash, name
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, name, _ := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		entries = append(entries, [2]string{hash, name})
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+
+	var hash string
+	switch {
+	case len(entries) == 0:
+		return "", fmt.Errorf("%s: no hash found", path)
+	case len(entries) == 1:
+		hash = entries[0][0]
+	default:
+		for _, e := range entries {
+			if e[1] == file || filepath.Base(e[1]) == filepath.Base(file) {
+				hash = e[0]
+				break
+			}
+		}
+		if hash == "" {
+			return "", fmt.Errorf("%s: no hash listed for %s", path, filepath.Base(file))
+		}
+	}
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+		return "", fmt.Errorf("%s: %q is not a SHA-256 (64 hex characters)", path, hash)
+	}
+	return strings.ToLower(hash), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadHashFile(t *testing.T) {
+	const (
+		a = "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"
+		b = "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d"
+	)
+	for _, tc := range []struct {
+		name, content, file, want, err string
+	}{
+		{"bare hash", a + "\n", "any.bin", a, ""},
+		{"single entry for another name", a + "  other.bin\n", "any.bin", a, ""},
+		{"uppercase", strings.ToUpper(a), "any.bin", a, ""},
+		{"by name", "# checksums\n" + a + "  a.txt\n" + b + " *b.txt\n", "dir/b.txt", b, ""},
+		{"by path", a + "  dist/a.txt\n" + b + "  dist/b.txt\n", "dist/a.txt", a, ""},
+		{"not listed", a + "  a.txt\n" + b + "  b.txt\n", "c.txt", "", "no hash listed for c.txt"},
+		{"empty", "\n# nothing\n", "a.txt", "", "no hash found"},
+		{"not a sha256", "abc123  a.txt\n", "a.txt", "", "is not a SHA-256"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "SHA256SUMS")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readHashFile(path, tc.file)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("readHashFile = %q, %v; want an error containing %q", got, err, tc.err)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Fatalf("readHashFile = %q, %v; want %q", got, err, tc.want)
+			}
+		})
+	}
+	if _, err := readHashFile(filepath.Join(t.TempDir(), "missing"), "a.txt"); !os.IsNotExist(err) {
+		t.Fatalf("readHashFile of a missing file: %v", err)
+	}
+}
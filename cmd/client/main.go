@@ -1,114 +1,643 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"io"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	pathpkg "path"
 	"path/filepath"
+	"strings"
+	"time"
 
-	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
-	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/pkg/uploadclient"
 )
 
-const (
-	serverURL = "http://localhost:8080"
-	chunkSize = 32 * 1024 // 32KB chunks
-)
+const serverURL = "http://localhost:8080"
 
 func main() {
-	if len(os.Args) < 3 {
-		log.Fatal("usage: client <file> <title>")
+	storedName := flag.String("name", "", "filename to store on the server (default: base name of <file>)")
+	verify := flag.Bool("verify", false, "download the file after upload and compare its SHA-256 with the local file")
+	verifyMaxSize := flag.Int64("verify-max-size", 1<<30, "skip -verify for files larger than this many bytes")
+	verifyForce := flag.Bool("verify-force", false, "run -verify even for files larger than -verify-max-size")
+	server := flag.String("server", serverURL, "base URL of the upload server (https:// for TLS)")
+	connectTimeout := flag.Duration("connect-timeout", uploadclient.DefaultConnectTimeout, "maximum time to establish the connection, including the TLS handshake")
+	pingInterval := flag.Duration("h2-ping-interval", 0, "send an HTTP/2 PING after this long without server frames, so long streams survive idle proxies (0 disables it)")
+	pingTimeout := flag.Duration("h2-ping-timeout", 15*time.Second, "drop the connection when a -h2-ping-interval PING is not answered within this")
+	caCert := flag.String("cacert", "", "PEM file with CA certificates to trust for an https -server")
+	clientCert := flag.String("client-cert", "", "PEM client certificate for servers that require mTLS")
+	clientKey := flag.String("client-key", "", "PEM private key for -client-cert")
+	followSymlinks := flag.Bool("follow-symlinks", true, "upload the target of a symbolic link; false refuses symlinks")
+	dryRun := flag.Bool("dry-run", false, "let the server run every check and report the hash without storing the file")
+	hashOnly := flag.Bool("hash-only", false, "only have the server hash the content and print its digest, storing nothing")
+	timeout := flag.Duration("timeout", 0, "deadline for each call, sent to the server in the Connect timeout header (0 means none)")
+	wireCompress := flag.String("wire-compress", "none", "compress messages on the wire: none or gzip (zstd is not supported)")
+	segmentSize := flag.Int64("segment-size", 0, "hash in segments of this many bytes so only corrupt segments are re-sent (0 sends one hash)")
+	hashFile := flag.String("hash-file", "", "sha256sum-style checksum file with the expected hash of <file>; the server rejects content that differs")
+	ifMatch := flag.String("if-match", "", "only overwrite the stored file if its SHA-256 is this hex digest, refusing when it changed")
+	extract := flag.Bool("extract", false, "have the server also unpack the uploaded .zip, .tar, .tar.gz or .tgz file (needs -extract-dir on the server)")
+	ttl := flag.Duration("ttl", 0, "have the server delete the stored file this long after the upload (0 keeps it); with expiry, the new TTL from now")
+	resume := flag.Bool("resume", false, "upload in chunks recorded in <file>.upload-state so an interrupted upload continues where the server left off")
+	verifyResume := flag.Bool("verify-resume", false, "with -resume, have the server check its partial file against the bytes already sent and start over when it is corrupt")
+	httpPut := flag.Bool("http-put", false, "send the file as a chunked HTTP/1.1 PUT /files/<name> with its SHA-256 in a trailer, for proxies that block streaming")
+	autoUnary := flag.Bool("auto-unary", false, "send files up to the server's UploadFile limit (from GetServerInfo, at most 8 MiB) in one UploadFile call and stream larger ones")
+	unaryThreshold := flag.Int64("unary-threshold", 0, "with -auto-unary, send files up to this many bytes in one call instead of asking the server (0 asks)")
+	session := flag.Bool("session", false, "upload through a CreateUpload session whose calls carry the Upload-Session header for sticky load balancers; with -resume the session is resumed")
+	skipExisting := flag.Bool("skip-existing", false, "ask the server first (StatFile) and skip files already stored under the same name with the same SHA-256")
+	declareChunks := flag.Bool("declare-chunks", false, "send the number of chunks in the metadata so the server rejects a stream with more or fewer")
+	ackWindow := flag.Int("ack-window", 0, "upload over UploadBidi with at most this many chunks unacknowledged by the server (0 uses Upload; plain http servers are spoken to with h2c)")
+	parallel := flag.Int("parallel", uploadclient.DefaultParallel, "files sent at once when <file> is a directory")
+	adaptive := flag.Bool("adaptive-chunking", false, "grow chunks up to 1 MiB while they send quickly and shrink them on a slow link")
+	overwrite := flag.Bool("overwrite", false, "let rename and copy replace an existing file")
+	asJSON := flag.Bool("json", false, "print a single JSON summary on stdout instead of log lines")
+	flag.Parse()
+
+	report := newReporter(*asJSON)
+	if flag.NArg() < 2 && flag.Arg(0) != "scrub" && flag.Arg(0) != "list" && flag.Arg(0) != "stats" && flag.Arg(0) != "watch" {
+		report.fatalf("usage: client [-name stored-name] [-verify] [-json] <file|http(s)-url> <title>\n       client [-json] [-parallel n] [-resume] <directory> <title>\n       client [-json] verify <local-file> <stored-name>\n       client [-json] [-overwrite] rename|copy <stored-name> <new-name>\n       client [-json] archive <archive-name.tar|.zip> <file>...\n       client [-json] [-ttl duration] expiry <stored-name>\n       client [-json] download [-resume] <stored-name> [<local-file>]\n       client [-json] stat <stored-name>\n       client [-json] scrub\n       client [-json] list [<prefix>]\n       client [-json] stats\n       client [-json] watch [-namespace org] [upload|delete|error]...")
 	}
 
-	path := os.Args[1]
-	title := os.Args[2]
+	var gzip bool
+	switch *wireCompress {
+	case "none":
+	case "gzip":
+		// compresses chunks in transit only, the stored bytes are unchanged
+		gzip = true
+	default:
+		report.fatalf("unsupported -wire-compress %q: only none and gzip are supported, connect-go ships no zstd compressor and none is vendored", *wireCompress)
+	}
 
-	f, err := os.Open(path)
+	httpClient, err := uploadclient.NewHTTPClient(uploadclient.TLSOptions{
+		ConnectTimeout: *connectTimeout,
+		CAFile:         *caCert,
+		CertFile:       *clientCert,
+		KeyFile:        *clientKey,
+		PingInterval:   *pingInterval,
+		PingTimeout:    *pingTimeout,
+		// UploadBidi needs HTTP/2, over plain http too
+		UnencryptedHTTP2: *ackWindow > 0,
+	})
 	if err != nil {
-		log.Fatalf("failed to open file: %v", err)
+		report.fatalf("failed to configure HTTP client: %v", err)
+	}
+	clientOpts := uploadclient.Options{
+		BaseURL:          *server,
+		HTTPClient:       httpClient,
+		Gzip:             gzip,
+		AdaptiveChunking: *adaptive,
+		Logf:             log.Printf,
+	}
+	client := uploadclient.New(clientOpts)
+
+	if flag.Arg(0) == "verify" && flag.NArg() == 3 {
+		runVerify(report, client, flag.Arg(1), flag.Arg(2), *timeout)
+		return
+	}
+	if flag.Arg(0) == "download" {
+		runDownload(report, client, flag.Args()[1:], *resume, *timeout)
+		return
+	}
+	if flag.Arg(0) == "stat" && flag.NArg() == 2 {
+		runStat(report, client, flag.Arg(1), *timeout)
+		return
+	}
+	if flag.Arg(0) == "scrub" && flag.NArg() == 1 {
+		runScrub(report, client, *timeout)
+		return
+	}
+	if flag.Arg(0) == "list" && flag.NArg() <= 2 {
+		runList(report, client, flag.Arg(1), *timeout)
+		return
+	}
+	if flag.Arg(0) == "stats" && flag.NArg() == 1 {
+		runStats(report, client, *timeout)
+		return
+	}
+	if flag.Arg(0) == "watch" {
+		runWatch(report, client, flag.Args()[1:], *timeout)
+		return
+	}
+	if flag.Arg(0) == "expiry" && flag.NArg() == 2 {
+		runExpiry(report, client, flag.Arg(1), *ttl, *timeout)
+		return
+	}
+	if flag.Arg(0) == "archive" && flag.NArg() >= 3 {
+		runArchive(report, client, flag.Arg(1), flag.Args()[2:], *timeout)
+		return
+	}
+	if flag.Arg(0) == "rename" && flag.NArg() == 3 {
+		runRename(report, client, flag.Arg(1), flag.Arg(2), *overwrite, *timeout)
+		return
+	}
+	if flag.Arg(0) == "copy" && flag.NArg() == 3 {
+		runCopy(report, client, flag.Arg(1), flag.Arg(2), *overwrite, *timeout)
+		return
+	}
+
+	path := flag.Arg(0)
+	title := flag.Arg(1)
+	fromURL := isURL(path)
+	uploadOpts := uploadclient.UploadOptions{
+		Title:          title,
+		DryRun:         *dryRun,
+		HashOnly:       *hashOnly,
+		RefuseSymlinks: !*followSymlinks,
+		SegmentSize:    *segmentSize,
+		IfMatchSHA256:  *ifMatch,
+		Extract:        *extract,
+		TTL:            *ttl,
+		AckWindow:      *ackWindow,
+		DeclareChunks:  *declareChunks,
+		SkipExisting:   *skipExisting,
+		VerifyResume:   *verifyResume,
+		Session:        *session,
+		HTTPPut:        *httpPut,
+		AutoUnary:      *autoUnary,
+		UnaryThreshold: *unaryThreshold,
+	}
+	if *verifyResume && !*resume {
+		report.fatalf("-verify-resume needs -resume")
+	}
+	if info, err := os.Stat(path); !fromURL && err == nil && info.IsDir() {
+		if *storedName != "" || *hashFile != "" || *ifMatch != "" || *verify {
+			report.fatalf("-name, -hash-file, -if-match and -verify apply to a single file, not a directory")
+		}
+		// the chunk-level messages of parallel uploads would interleave
+		clientOpts.Logf = nil
+		runDirectory(report, uploadclient.New(clientOpts), path, uploadclient.DirOptions{
+			Upload:   uploadOpts,
+			Parallel: *parallel,
+			Resume:   *resume,
+			Timeout:  *timeout,
+		})
+		return
+	}
+	if *storedName == "" {
+		*storedName = defaultName(path)
+	}
+	report.sum.Filename = *storedName
+
+	if fromURL {
+		log.Printf("Uploading: %s from %s", *storedName, path)
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			report.fatalf("failed to stat file: %v", err)
+		}
+		report.sum.Bytes = info.Size()
+		log.Printf("Uploading: %s (%d bytes)", info.Name(), info.Size())
 	}
-	defer f.Close()
 
-	info, err := f.Stat()
+	ctx, cancel := callContext(*timeout)
+	defer cancel()
+	uploadOpts.Name = *storedName
+	if *hashFile != "" {
+		if uploadOpts.ExpectedSHA256, err = readHashFile(*hashFile, path); err != nil {
+			report.fatalf("failed to read -hash-file: %v", err)
+		}
+	}
+	if *skipExisting && fromURL {
+		report.fatalf("-skip-existing needs a local file")
+	}
+	if *resume {
+		if fromURL {
+			report.fatalf("-resume needs a local file")
+		}
+		uploadOpts.StateFile = path + ".upload-state"
+	}
+	progress := uploadclient.NewProgress()
+	share := progress.Add(report.sum.Bytes)
+	uploadOpts.Progress = share.Sent
+	stopProgress := showProgress(report, progress)
+	var resp *uploadclient.Response
+	if fromURL {
+		resp, err = client.UploadURL(ctx, path, uploadOpts)
+	} else {
+		resp, err = client.UploadFile(ctx, path, uploadOpts)
+	}
+	share.Done(err)
+	stopProgress()
 	if err != nil {
-		log.Fatalf("failed to stat file: %v", err)
+		report.fatalf("upload failed: %v", err)
+	}
+	if fromURL {
+		report.sum.Bytes = resp.Size
 	}
-	log.Printf("Uploading: %s (%d bytes)", info.Name(), info.Size())
+	report.sum.Hash = resp.SHA256
+	report.sum.Message = resp.Message
+	report.sum.Size = resp.Size
+	report.sum.HashOk = resp.HashOk
+	report.sum.HashStatus = string(resp.HashStatus)
+	report.sum.Path = resp.StoragePath
 
-	client := fileuploadv1connect.NewFileUploadServiceClient(
-		http.DefaultClient,
-		serverURL,
-	)
+	log.Printf("Server response: %s (size: %d, hash_ok: %v)",
+		resp.Message, resp.Size, resp.HashOk)
+	if resp.HashStatus == uploadclient.HashNotProvided {
+		log.Printf("Hash not provided, the server did not verify the content")
+	}
+	if resp.StoredFilename != "" && resp.StoredFilename != *storedName {
+		// the server assigned the name, verify against the stored copy
+		log.Printf("Stored as: %s", resp.StoredFilename)
+		report.sum.StoredAs = resp.StoredFilename
+		*storedName = resp.StoredFilename
+	}
 
-	stream, err := client.Upload(context.Background())
+	if !resp.ExpiresAt.IsZero() {
+		log.Printf("Expires: %s", resp.ExpiresAt.Format(time.RFC3339))
+		report.sum.ExpiresAt = resp.ExpiresAt.Format(time.RFC3339)
+	}
+	if resp.ExtractedDir != "" {
+		log.Printf("Extracted to: %s", resp.ExtractedDir)
+	}
+	if *hashOnly {
+		log.Printf("Server SHA-256: %s", resp.SHA256)
+	}
+	if !*verify || *dryRun || *hashOnly {
+		report.done()
+		return
+	}
+	if report.sum.Bytes > *verifyMaxSize && !*verifyForce {
+		log.Printf("Skipping verification: file is larger than %d bytes (use -verify-force)", *verifyMaxSize)
+		report.done()
+		return
+	}
+	verifyCtx, cancelVerify := callContext(*timeout)
+	defer cancelVerify()
+	err = client.Verify(verifyCtx, *storedName, resp.SHA256)
+	verified := err == nil
+	report.sum.Verified = &verified
 	if err != nil {
-		log.Fatalf("failed to create upload stream: %v", err)
+		report.fatalf("Verification FAILED: %v", err)
 	}
+	log.Println("Verification passed: downloaded copy matches the local file")
+	report.done()
+}
 
-	// Phase 1: Send metadata
-	err = stream.Send(&fileuploadv1.UploadRequest{
-		Payload: &fileuploadv1.UploadRequest_Metadata{
-			Metadata: &fileuploadv1.UploadMetadata{
-				Filename: filepath.Base(path),
-				Title:    title,
-			},
-		},
-	})
+// runDirectory uploads every file below dir, showing the progress of the
+// whole directory on one line; any failed file exits with status 1
+func runDirectory(report *reporter, client *uploadclient.Client, dir string, opts uploadclient.DirOptions) {
+	report.sum.Filename = dir
+	opts.Progress = uploadclient.NewProgress()
+	opts.Done = func(f uploadclient.DirFile) {
+		if f.Err != nil {
+			log.Printf("Failed: %s: %v", f.Path, f.Err)
+			return
+		}
+		if f.Resp.Skipped {
+			log.Printf("Skipped: %s, already present as %s", f.Path, f.Resp.StoredFilename)
+			return
+		}
+		log.Printf("Uploaded: %s as %s (%d bytes, hash_ok: %v)", f.Path, f.Resp.StoredFilename, f.Resp.Size, f.Resp.HashOk)
+	}
+	stopProgress := showProgress(report, opts.Progress)
+	files, err := client.UploadDir(context.Background(), dir, opts)
+	stopProgress()
 	if err != nil {
-		log.Fatalf("failed to send metadata: %v", err)
-	}
-	log.Println("Sent metadata")
-
-	// Phase 2: Stream chunks with TeeReader (calculates hash during read)
-	hasher := sha256.New()
-	reader := io.TeeReader(f, hasher)
-	buf := make([]byte, chunkSize)
-	var totalBytes int64
-
-	for {
-		n, err := reader.Read(buf)
-		if n > 0 {
-			if sendErr := stream.Send(&fileuploadv1.UploadRequest{
-				Payload: &fileuploadv1.UploadRequest_Chunk{
-					Chunk: buf[:n],
-				},
-			}); sendErr != nil {
-				log.Fatalf("failed to send chunk: %v", sendErr)
+		report.fatalf("upload failed: %v", err)
+	}
+
+	var (
+		failed, skipped int
+		skippedBytes    int64 // counted as sent by the progress, to reach 100%
+	)
+	report.sum.HashOk = true
+	for _, f := range files {
+		file := fileSummary{Filename: f.Path, Bytes: f.Size}
+		if f.Err != nil {
+			failed++
+			file.Error = f.Err.Error()
+			report.sum.HashOk = false
+		} else {
+			file.StoredAs = f.Resp.StoredFilename
+			file.Path = f.Resp.StoragePath
+			file.Hash = f.Resp.SHA256
+			file.Message = f.Resp.Message
+			file.Size = f.Resp.Size
+			file.HashOk = f.Resp.HashOk
+			file.HashStatus = string(f.Resp.HashStatus)
+			if f.Resp.Skipped {
+				skipped++
+				skippedBytes += f.Size
 			}
-			totalBytes += int64(n)
+			report.sum.Size += f.Resp.Size
+			report.sum.HashOk = report.sum.HashOk && f.Resp.HashOk
 		}
-		if err == io.EOF {
-			break
+		report.sum.Bytes += f.Size
+		report.sum.Files = append(report.sum.Files, file)
+	}
+	st := opts.Progress.Stats()
+	report.sum.Message = fmt.Sprintf("%d of %d files uploaded", len(files)-failed-skipped, len(files))
+	if skipped > 0 {
+		report.sum.Message += fmt.Sprintf(", %d already present", skipped)
+	}
+	log.Printf("%s (%s in %v)", report.sum.Message, formatBytes(st.SentBytes-skippedBytes), st.Elapsed.Round(time.Millisecond))
+	if failed > 0 {
+		report.fatalf("%d of %d files failed", failed, len(files))
+	}
+	report.done()
+}
+
+// runVerify compares a local file with the hash stored on the server for
+// remote and exits 1 when they differ
+func runVerify(report *reporter, client *uploadclient.Client, local, remote string, timeout time.Duration) {
+	report.sum.Filename = remote
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	hash, err := client.VerifyMetadata(ctx, local, remote)
+	report.sum.Hash = hash
+	verified := err == nil
+	report.sum.Verified = &verified
+	if err != nil {
+		report.fatalf("Verification FAILED: %v", err)
+	}
+	log.Printf("Verification passed: %s matches the stored %s", local, remote)
+	report.done()
+}
+
+// runRename renames the stored file from to to
+func runRename(report *reporter, client *uploadclient.Client, from, to string, overwrite bool, timeout time.Duration) {
+	report.sum.Filename = from
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	stored, err := client.Rename(ctx, from, to, overwrite)
+	if err != nil {
+		report.fatalf("rename failed: %v", err)
+	}
+	report.sum.StoredAs = stored
+	log.Printf("Renamed: %s to %s", from, stored)
+	report.done()
+}
+
+// runDownload saves a stored file locally; args are the subcommand's own
+// [-resume] <stored-name> [<local-file>], resume the value of the global -resume
+func runDownload(report *reporter, client *uploadclient.Client, args []string, resume bool, timeout time.Duration) {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	fs.BoolVar(&resume, "resume", resume, "record progress in <local-file>.download-state and continue an interrupted download")
+	if err := fs.Parse(args); err != nil || fs.NArg() < 1 || fs.NArg() > 2 {
+		report.fatalf("usage: client download [-resume] <stored-name> [<local-file>]")
+	}
+	name, local := fs.Arg(0), filepath.Base(fs.Arg(0))
+	if fs.NArg() == 2 {
+		local = fs.Arg(1)
+	}
+	report.sum.Filename = name
+	var opts uploadclient.DownloadOptions
+	if resume {
+		opts.StateFile = local + ".download-state"
+	}
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	hash, err := client.DownloadFile(ctx, name, local, opts)
+	report.sum.Hash = hash
+	if err != nil {
+		report.fatalf("%v", err)
+	}
+	if info, err := os.Stat(local); err == nil {
+		report.sum.Bytes = info.Size()
+		report.sum.Size = info.Size()
+	}
+	verified := true
+	report.sum.Verified = &verified
+	report.done()
+}
+
+// runStat prints whether name is stored, with its size and hash; a missing
+// file exits with status 1
+func runStat(report *reporter, client *uploadclient.Client, name string, timeout time.Duration) {
+	report.sum.Filename = name
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	stored, err := client.Stat(ctx, name)
+	if err != nil {
+		report.fatalf("stat failed: %v", err)
+	}
+	if stored == nil {
+		report.fatalf("%s is not stored", name)
+	}
+	report.sum.StoredAs = stored.Name
+	report.sum.Size = stored.Size
+	report.sum.Hash = stored.SHA256
+	log.Printf("%s: %d bytes, hash: %s, modified %s", stored.Name, stored.Size, stored.SHA256, stored.Modified.Format(time.RFC3339))
+	report.done()
+}
+
+// scrubProgressEvery is how many files runScrub checks between progress lines
+const scrubProgressEvery = 100
+
+// runScrub has the server re-hash every stored file and lists the damaged
+// ones; any damaged file exits with status 1
+func runScrub(report *reporter, client *uploadclient.Client, timeout time.Duration) {
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	result, err := client.Scrub(ctx, func(p uploadclient.ScrubProgress) {
+		if p.File.Status != uploadclient.ScrubOK {
+			log.Printf("%s is %s", p.File.Name, p.File.Status)
 		}
-		if err != nil {
-			log.Fatalf("failed to read file: %v", err)
+		if p.FilesChecked%scrubProgressEvery == 0 {
+			log.Printf("Checked %d of %d files (%d bytes)", p.FilesChecked, p.FilesTotal, p.BytesChecked)
 		}
+	})
+	if err != nil {
+		report.fatalf("scrub failed: %v", err)
+	}
+	report.sum.Bytes = result.BytesChecked
+	report.sum.Message = fmt.Sprintf("%d files checked, %d damaged, %d skipped", result.FilesChecked, len(result.Damaged), result.FilesSkipped)
+	for _, f := range result.Damaged {
+		report.sum.Damaged = append(report.sum.Damaged, f.Name)
 	}
-	log.Printf("Sent %d bytes in chunks", totalBytes)
+	log.Printf("Scrub complete: %s (%d bytes)", report.sum.Message, result.BytesChecked)
+	if len(result.Damaged) > 0 {
+		report.fatalf("%d damaged files", len(result.Damaged))
+	}
+	report.done()
+}
 
-	// Phase 3: Send finish_commit with calculated hash
-	clientHash := hex.EncodeToString(hasher.Sum(nil))
-	log.Printf("Sending commit with hash: %s", clientHash)
+// runList prints the stored files whose name starts with prefix as the
+// server streams them, one "size<TAB>modified<TAB>name" line each on stdout;
+// -json collects them under "files" instead
+func runList(report *reporter, client *uploadclient.Client, prefix string, timeout time.Duration) {
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	var files int
+	err := client.ListFiles(ctx, prefix, func(f uploadclient.StoredFile) error {
+		files++
+		report.sum.Bytes += f.Size
+		if report.asJSON {
+			report.sum.Files = append(report.sum.Files, fileSummary{Filename: f.Name, Path: f.StoragePath, Size: f.Size, Hash: f.SHA256})
+			return nil
+		}
+		_, err := fmt.Printf("%d\t%s\t%s\n", f.Size, f.Modified.Format(time.RFC3339), f.Name)
+		return err
+	})
+	if err != nil {
+		report.fatalf("list failed: %v", err)
+	}
+	report.sum.Message = fmt.Sprintf("%d files", files)
+	log.Printf("Listed %d files (%d bytes)", files, report.sum.Bytes)
+	report.done()
+}
+
+// runStats prints the totals of the stored files per directory, then the
+// largest files
+func runStats(report *reporter, client *uploadclient.Client, timeout time.Duration) {
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	stats, err := client.Stats(ctx)
+	if err != nil {
+		report.fatalf("stats failed: %v", err)
+	}
+	report.sum.Bytes = stats.Bytes
+	report.sum.Message = fmt.Sprintf("%d files, %d bytes on average", stats.Files, stats.AverageSize)
+	for _, f := range stats.Largest {
+		report.sum.Files = append(report.sum.Files, fileSummary{Filename: f.Name, Path: f.StoragePath, Size: f.Size, Hash: f.SHA256})
+	}
+	if !report.asJSON {
+		for _, d := range stats.Directories {
+			fmt.Printf("%d\t%d files\t%s\n", d.Bytes, d.Files, cmp.Or(d.Directory, "."))
+		}
+		for _, f := range stats.Largest {
+			fmt.Printf("%d\t%s\t%s\n", f.Size, f.Modified.Format(time.RFC3339), f.StoragePath)
+		}
+	}
+	log.Printf("%d files (%d bytes, %d on average) as of %s", stats.Files, stats.Bytes, stats.AverageSize, stats.ComputedAt.Format(time.RFC3339))
+	report.done()
+}
 
-	err = stream.Send(&fileuploadv1.UploadRequest{
-		Payload: &fileuploadv1.UploadRequest_FinishCommit{
-			FinishCommit: clientHash,
-		},
+// runWatch prints the server events as they happen until interrupted, one
+// JSON line per event with -json
+func runWatch(report *reporter, client *uploadclient.Client, args []string, timeout time.Duration) {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "only events of clients whose certificate has this organization")
+	if err := fs.Parse(args); err != nil {
+		report.fatalf("usage: client watch [-namespace org] [upload|delete|error]...")
+	}
+	opts := uploadclient.WatchOptions{Namespace: *namespace}
+	for _, t := range fs.Args() {
+		opts.Types = append(opts.Types, uploadclient.EventType(t))
+	}
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	var events int
+	err := client.WatchEvents(ctx, opts, func(e uploadclient.Event) error {
+		events++
+		if e.Dropped > 0 {
+			log.Printf("Missed %d events, not keeping up with the server", e.Dropped)
+		}
+		if report.asJSON {
+			return enc.Encode(e)
+		}
+		line := fmt.Sprintf("%s\t%s\t%s\t%s", e.Time.Format(time.RFC3339), e.Type, e.Code, e.Filename)
+		if e.Type != uploadclient.EventDelete {
+			line += fmt.Sprintf("\t%d bytes via %s", e.Size, e.RPC)
+		}
+		if e.Identity != "" {
+			line += "\tby " + e.Identity
+		}
+		_, err := fmt.Println(line)
+		return err
 	})
+	if err != nil && ctx.Err() == nil {
+		report.fatalf("watch failed: %v", err)
+	}
+	report.sum.Message = fmt.Sprintf("%d events", events)
+	log.Printf("Watched %d events", events)
+	report.done()
+}
+
+// runExpiry prints when the stored file name expires, after extending its
+// expiry to ttl from now when ttl is set
+func runExpiry(report *reporter, client *uploadclient.Client, name string, ttl, timeout time.Duration) {
+	report.sum.Filename = name
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	var (
+		at  time.Time
+		err error
+	)
+	if ttl > 0 {
+		at, err = client.ExtendExpiry(ctx, name, ttl)
+	} else {
+		at, err = client.GetExpiry(ctx, name)
+	}
 	if err != nil {
-		log.Fatalf("failed to send commit: %v", err)
+		report.fatalf("expiry failed: %v", err)
+	}
+	if at.IsZero() {
+		log.Printf("%s does not expire", name)
+	} else {
+		report.sum.ExpiresAt = at.Format(time.RFC3339)
+		log.Printf("%s expires at %s", name, report.sum.ExpiresAt)
 	}
+	report.done()
+}
 
-	// Get response
-	resp, err := stream.CloseAndReceive()
+// runCopy stores a copy of the stored file from as to
+func runCopy(report *reporter, client *uploadclient.Client, from, to string, overwrite bool, timeout time.Duration) {
+	report.sum.Filename = from
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	resp, err := client.Copy(ctx, from, to, overwrite)
 	if err != nil {
-		log.Fatalf("upload failed: %v", err)
+		report.fatalf("copy failed: %v", err)
 	}
+	report.sum.StoredAs = resp.StoredFilename
+	report.sum.Path = resp.StoragePath
+	report.sum.Size = resp.Size
+	report.sum.Hash = resp.SHA256
+	log.Printf("Copied: %s to %s (%d bytes)", from, resp.StoredFilename, resp.Size)
+	report.done()
+}
 
-	log.Printf("Server response: %s (size: %d, hash_ok: %v)",
-		resp.Message, resp.Size, resp.HashOk)
+// runArchive stores the local files as the entries of one archive on the server
+func runArchive(report *reporter, client *uploadclient.Client, name string, paths []string, timeout time.Duration) {
+	report.sum.Filename = name
+	ctx, cancel := callContext(timeout)
+	defer cancel()
+	resp, err := client.UploadArchive(ctx, name, "", paths)
+	if err != nil {
+		report.fatalf("archive upload failed: %v", err)
+	}
+	report.sum.StoredAs = resp.StoredFilename
+	report.sum.Path = resp.StoragePath
+	report.sum.Size = resp.Size
+	report.sum.Hash = resp.SHA256
+	report.sum.Message = resp.Message
+	report.sum.HashOk = resp.HashOk
+	report.sum.HashStatus = string(resp.HashStatus)
+	log.Printf("Archive stored: %s (%d files, %d bytes)", resp.StoredFilename, len(paths), resp.Size)
+	report.done()
+}
+
+// callContext returns the context for one RPC, bounded by timeout when it is set
+func callContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// isURL reports whether the file argument is an http(s) URL to relay
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// defaultName is the name a file is stored under without -name: the base
+// name of its local path, or of the path of its URL. The server sanitizes it
+// either way and falls back when it is empty.
+func defaultName(path string) string {
+	if isURL(path) {
+		if u, err := url.Parse(path); err == nil {
+			return pathpkg.Base(u.Path)
+		}
+	}
+	return filepath.Base(path)
 }
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestDefaultName(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/tmp/upload-1234.tmp", "upload-1234.tmp"},
+		{"report.pdf", "report.pdf"},
+		{"../reports/2024/report.pdf", "report.pdf"},
+		{"https://example.com/files/report.pdf?version=2", "report.pdf"},
+		{"http://example.com/", "/"},
+	} {
+		if got := defaultName(tc.path); got != tc.want {
+			t.Errorf("defaultName(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/pkg/uploadclient"
+)
+
+// progressInterval is how often the progress line is redrawn
+const progressInterval = 200 * time.Millisecond
+
+// statusLine keeps one line of status at the bottom of a terminal. Log lines
+// written through it are printed above the status, which is then redrawn.
+type statusLine struct {
+	mu   sync.Mutex
+	out  io.Writer
+	line string
+}
+
+func (s *statusLine) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprint(s.out, "\r\033[K")
+	s.out.Write(p)
+	fmt.Fprint(s.out, s.line)
+	return len(p), nil
+}
+
+func (s *statusLine) set(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.line = line
+	fmt.Fprint(s.out, "\r\033[K", line)
+}
+
+// showProgress renders p as a single updating line on stderr until the
+// returned function is called, only when stderr is a terminal and the report
+// is not JSON
+func showProgress(report *reporter, p *uploadclient.Progress) (stop func()) {
+	if report.asJSON || !isTerminal(os.Stderr) {
+		return func() {}
+	}
+	line := &statusLine{out: os.Stderr}
+	log.SetOutput(line)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				line.set(formatProgress(p.Stats()))
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		line.set(formatProgress(p.Stats()))
+		fmt.Fprintln(os.Stderr)
+		log.SetOutput(os.Stderr)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatProgress renders stats as "3/10 files, 12.3 MiB of 40.0 MiB (30%),
+// 5.2 MiB/s"; the file count is left out for a single file
+func formatProgress(st uploadclient.ProgressStats) string {
+	var parts []string
+	if st.Files > 1 {
+		files := fmt.Sprintf("%d/%d files", st.Completed, st.Files)
+		if st.Failed > 0 {
+			files += fmt.Sprintf(" (%d failed)", st.Failed)
+		}
+		parts = append(parts, files)
+	}
+	if st.TotalBytes > 0 {
+		parts = append(parts, fmt.Sprintf("%s of %s (%d%%)", formatBytes(st.SentBytes), formatBytes(st.TotalBytes), st.SentBytes*100/st.TotalBytes))
+	} else {
+		parts = append(parts, formatBytes(st.SentBytes))
+	}
+	parts = append(parts, formatBytes(int64(st.BytesPerSecond))+"/s")
+	return strings.Join(parts, ", ")
+}
+
+// formatBytes renders n with a binary unit, as in "12.3 MiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/pkg/uploadclient"
+)
+
+func TestFormatProgress(t *testing.T) {
+	for _, tc := range []struct {
+		st   uploadclient.ProgressStats
+		want string
+	}{
+		{uploadclient.ProgressStats{Files: 1, TotalBytes: 4 << 20, SentBytes: 1 << 20, BytesPerSecond: 512}, "1.0 MiB of 4.0 MiB (25%), 512 B/s"},
+		{uploadclient.ProgressStats{Files: 10, Completed: 3, TotalBytes: 2048, SentBytes: 1024, BytesPerSecond: 1536}, "3/10 files, 1.0 KiB of 2.0 KiB (50%), 1.5 KiB/s"},
+		{uploadclient.ProgressStats{Files: 4, Completed: 2, Failed: 1, TotalBytes: 100, SentBytes: 100}, "2/4 files (1 failed), 100 B of 100 B (100%), 0 B/s"},
+		// a stream of unknown size
+		{uploadclient.ProgressStats{Files: 1, SentBytes: 3 << 30}, "3.0 GiB, 0 B/s"},
+	} {
+		if got := formatProgress(tc.st); got != tc.want {
+			t.Errorf("formatProgress(%+v) = %q, want %q", tc.st, got, tc.want)
+		}
+	}
+}
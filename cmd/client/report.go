@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// summary is the single JSON object printed by -json
+type summary struct {
+	Filename   string   `json:"filename"`
+	StoredAs   string   `json:"stored_filename,omitempty"`
+	Path       string   `json:"storage_path,omitempty"`
+	Bytes      int64    `json:"bytes"`
+	Hash       string   `json:"hash,omitempty"`
+	Message    string   `json:"message,omitempty"`
+	Size       int64    `json:"size"`
+	HashOk     bool     `json:"hash_ok"`
+	HashStatus string   `json:"hash_status,omitempty"`
+	Verified   *bool    `json:"verified,omitempty"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	Damaged    []string `json:"damaged,omitempty"`
+	// Files holds one summary per file of a directory upload
+	Files      []fileSummary `json:"files,omitempty"`
+	DurationMs float64       `json:"duration_ms"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// fileSummary is the outcome of one file of a directory upload
+type fileSummary struct {
+	Filename   string `json:"filename"`
+	StoredAs   string `json:"stored_filename,omitempty"`
+	Path       string `json:"storage_path,omitempty"`
+	Bytes      int64  `json:"bytes"`
+	Hash       string `json:"hash,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	HashOk     bool   `json:"hash_ok"`
+	HashStatus string `json:"hash_status,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// reporter prints human log lines, or a single JSON summary when asJSON is set
+type reporter struct {
+	asJSON bool
+	out    io.Writer // where the summary goes, os.Stdout
+	start  time.Time
+	sum    summary
+}
+
+func newReporter(asJSON bool) *reporter {
+	if asJSON {
+		// progress lines would corrupt the output scripts parse
+		log.SetOutput(io.Discard)
+	}
+	return &reporter{asJSON: asJSON, out: os.Stdout, start: time.Now()}
+}
+
+// fatalf reports the error and exits with status 1
+func (r *reporter) fatalf(format string, args ...any) {
+	if !r.asJSON {
+		log.Fatalf(format, args...)
+	}
+	r.sum.Error = fmt.Sprintf(format, args...)
+	r.done()
+	os.Exit(1)
+}
+
+// done prints the JSON summary, a no-op for human output
+func (r *reporter) done() {
+	if !r.asJSON {
+		return
+	}
+	r.sum.DurationMs = float64(time.Since(r.start).Microseconds()) / 1000
+	enc := json.NewEncoder(r.out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.sum); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write summary: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// newTestReporter is newReporter writing its summary to a buffer
+func newTestReporter(t *testing.T, asJSON bool) (*reporter, *bytes.Buffer) {
+	t.Helper()
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	r := newReporter(asJSON)
+	var out bytes.Buffer
+	r.out = &out
+	return r, &out
+}
+
+func TestReporterPrintsOneJSONObject(t *testing.T) {
+	r, out := newTestReporter(t, true)
+	r.sum = summary{Filename: "a.txt", Bytes: 5, Hash: "abc", Message: "ok", Size: 5, HashOk: true}
+	r.done()
+
+	var got map[string]any
+	dec := json.NewDecoder(out)
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if dec.More() {
+		t.Fatalf("more than one JSON value printed: %s", out)
+	}
+	for key, want := range map[string]any{"filename": "a.txt", "bytes": 5.0, "hash": "abc", "message": "ok", "size": 5.0, "hash_ok": true} {
+		if got[key] != want {
+			t.Errorf("%s = %v, want %v", key, got[key], want)
+		}
+	}
+	if _, ok := got["duration_ms"]; !ok {
+		t.Error("no duration_ms")
+	}
+	if _, ok := got["error"]; ok {
+		t.Error("error set on success")
+	}
+}
+
+func TestReporterHumanOutputPrintsNoSummary(t *testing.T) {
+	r, out := newTestReporter(t, false)
+	r.sum.Filename = "a.txt"
+	r.done()
+	if out.Len() != 0 {
+		t.Fatalf("human output printed %q", out)
+	}
+}
+
+func TestReporterFatalPrintsJSONError(t *testing.T) {
+	if os.Getenv("REPORTER_FATAL") == "1" {
+		newReporter(true).fatalf("upload failed: %v", "boom")
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestReporterFatalPrintsJSONError$")
+	cmd.Env = append(os.Environ(), "REPORTER_FATAL=1")
+	stdout, err := cmd.Output()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("fatalf exited with %v, want status 1", err)
+	}
+	var got summary
+	if err := json.Unmarshal(stdout, &got); err != nil {
+		t.Fatalf("output %q: %v", stdout, err)
+	}
+	if got.Error != "upload failed: boom" {
+		t.Fatalf("error %q", got.Error)
+	}
+}
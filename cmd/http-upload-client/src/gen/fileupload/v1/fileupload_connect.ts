@@ -3,8 +3,8 @@
 /* eslint-disable */
 // @ts-nocheck
 
-import { UploadFileRequest, UploadRequest, UploadResponse } from "./fileupload_pb.js";
-import { MethodKind } from "@bufbuild/protobuf";
+import { AddArchiveEntryRequest, BeginArchiveRequest, BeginArchiveResponse, CloseArchiveRequest, CopyFileRequest, CreateUploadRequest, DownloadRequest, DownloadResponse, ExpiryResponse, ExtendExpiryRequest, FileInfo, GetExpiryRequest, GetFileMetadataRequest, GetFileMetadataResponse, GetServerInfoRequest, GetServerInfoResponse, GetStatsRequest, GetStatsResponse, GetThumbnailRequest, GetThumbnailResponse, GetUploadSessionRequest, GetUploadStatusRequest, GetUploadStatusResponse, ListFilesRequest, RenameFileRequest, RenameFileResponse, ScrubRequest, ScrubResponse, ServerEvent, StatFileRequest, StatFileResponse, UploadBidiResponse, UploadChunkRequest, UploadChunkResponse, UploadFileRequest, UploadRequest, UploadResponse, UploadSession, WatchEventsRequest } from "./fileupload_pb.js";
+import { MethodIdempotency, MethodKind } from "@bufbuild/protobuf";
 
 /**
  * @generated from service fileupload.v1.FileUploadService
@@ -24,6 +24,19 @@ export const FileUploadService = {
       O: UploadResponse,
       kind: MethodKind.ClientStreaming,
     },
+    /**
+     * Same protocol as Upload, but the server acknowledges written chunks with
+     * the offset it holds, so the client can bound the data in flight. Needs
+     * HTTP/2 end to end.
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.UploadBidi
+     */
+    uploadBidi: {
+      name: "UploadBidi",
+      I: UploadRequest,
+      O: UploadBidiResponse,
+      kind: MethodKind.BiDiStreaming,
+    },
     /**
      * Unary upload for browser clients (Fetch API doesn't support client streaming)
      *
@@ -35,6 +48,247 @@ export const FileUploadService = {
       O: UploadResponse,
       kind: MethodKind.Unary,
     },
+    /**
+     * Server version and capabilities (cacheable, may be called with HTTP GET)
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.GetServerInfo
+     */
+    getServerInfo: {
+      name: "GetServerInfo",
+      I: GetServerInfoRequest,
+      O: GetServerInfoResponse,
+      kind: MethodKind.Unary,
+      idempotency: MethodIdempotency.NoSideEffects,
+    },
+    /**
+     * Size, hash and modification time of a stored file (cacheable, may be called with HTTP GET)
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.GetFileMetadata
+     */
+    getFileMetadata: {
+      name: "GetFileMetadata",
+      I: GetFileMetadataRequest,
+      O: GetFileMetadataResponse,
+      kind: MethodKind.Unary,
+      idempotency: MethodIdempotency.NoSideEffects,
+    },
+    /**
+     * Whether a file is stored under a name, with its size, hash and
+     * modification time. Unlike GetFileMetadata a missing file is not an error
+     * and the hash comes from the server's index instead of reading the file.
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.StatFile
+     */
+    statFile: {
+      name: "StatFile",
+      I: StatFileRequest,
+      O: StatFileResponse,
+      kind: MethodKind.Unary,
+      idempotency: MethodIdempotency.NoSideEffects,
+    },
+    /**
+     * Streams one FileInfo per stored file as the upload directories are read,
+     * in no particular order, so listing millions of files never builds them
+     * all into one message
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.ListFilesStream
+     */
+    listFilesStream: {
+      name: "ListFilesStream",
+      I: ListFilesRequest,
+      O: FileInfo,
+      kind: MethodKind.ServerStreaming,
+    },
+    /**
+     * Streams a stored file back in chunks, from offset when set
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.Download
+     */
+    download: {
+      name: "Download",
+      I: DownloadRequest,
+      O: DownloadResponse,
+      kind: MethodKind.ServerStreaming,
+    },
+    /**
+     * How much of a chunked UploadFile upload the server holds, so an
+     * interrupted client can resume from there
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.GetUploadStatus
+     */
+    getUploadStatus: {
+      name: "GetUploadStatus",
+      I: GetUploadStatusRequest,
+      O: GetUploadStatusResponse,
+      kind: MethodKind.Unary,
+      idempotency: MethodIdempotency.NoSideEffects,
+    },
+    /**
+     * JPEG thumbnail of a stored image, when the server generates thumbnails
+     * (cacheable, may be called with HTTP GET)
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.GetThumbnail
+     */
+    getThumbnail: {
+      name: "GetThumbnail",
+      I: GetThumbnailRequest,
+      O: GetThumbnailResponse,
+      kind: MethodKind.Unary,
+      idempotency: MethodIdempotency.NoSideEffects,
+    },
+    /**
+     * Renames a stored file without re-uploading it
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.RenameFile
+     */
+    renameFile: {
+      name: "RenameFile",
+      I: RenameFileRequest,
+      O: RenameFileResponse,
+      kind: MethodKind.Unary,
+    },
+    /**
+     * Stores a copy of a stored file under a new name without re-uploading it
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.CopyFile
+     */
+    copyFile: {
+      name: "CopyFile",
+      I: CopyFileRequest,
+      O: UploadResponse,
+      kind: MethodKind.Unary,
+    },
+    /**
+     * Starts an archive bundling several files into one stored tar or zip file:
+     * AddArchiveEntry adds each file and CloseArchive stores the archive
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.BeginArchive
+     */
+    beginArchive: {
+      name: "BeginArchive",
+      I: BeginArchiveRequest,
+      O: BeginArchiveResponse,
+      kind: MethodKind.Unary,
+    },
+    /**
+     * Adds one file to an archive started with BeginArchive
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.AddArchiveEntry
+     */
+    addArchiveEntry: {
+      name: "AddArchiveEntry",
+      I: AddArchiveEntryRequest,
+      O: UploadResponse,
+      kind: MethodKind.Unary,
+    },
+    /**
+     * Writes the archive and stores it atomically under its filename
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.CloseArchive
+     */
+    closeArchive: {
+      name: "CloseArchive",
+      I: CloseArchiveRequest,
+      O: UploadResponse,
+      kind: MethodKind.Unary,
+    },
+    /**
+     * When a stored file uploaded with a TTL or expiry time will be deleted
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.GetExpiry
+     */
+    getExpiry: {
+      name: "GetExpiry",
+      I: GetExpiryRequest,
+      O: ExpiryResponse,
+      kind: MethodKind.Unary,
+      idempotency: MethodIdempotency.NoSideEffects,
+    },
+    /**
+     * Postpones the deletion of a stored file uploaded with a TTL or expiry time
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.ExtendExpiry
+     */
+    extendExpiry: {
+      name: "ExtendExpiry",
+      I: ExtendExpiryRequest,
+      O: ExpiryResponse,
+      kind: MethodKind.Unary,
+    },
+    /**
+     * Re-hashes every stored file in the hash index and reports those whose
+     * content no longer matches it, streaming progress for each file
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.Scrub
+     */
+    scrub: {
+      name: "Scrub",
+      I: ScrubRequest,
+      O: ScrubResponse,
+      kind: MethodKind.ServerStreaming,
+    },
+    /**
+     * Streams upload, deletion and failed upload events as they happen, until
+     * the client cancels or the server stops
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.WatchEvents
+     */
+    watchEvents: {
+      name: "WatchEvents",
+      I: WatchEventsRequest,
+      O: ServerEvent,
+      kind: MethodKind.ServerStreaming,
+    },
+    /**
+     * Starts a resumable upload session of a known size; UploadChunk sends its
+     * content. Every call of a session should carry the Upload-Session header
+     * so a load balancer can route them all to the same server.
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.CreateUpload
+     */
+    createUpload: {
+      name: "CreateUpload",
+      I: CreateUploadRequest,
+      O: UploadSession,
+      kind: MethodKind.Unary,
+    },
+    /**
+     * Appends a chunk to a session; the last one stores the file
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.UploadChunk
+     */
+    uploadChunk: {
+      name: "UploadChunk",
+      I: UploadChunkRequest,
+      O: UploadChunkResponse,
+      kind: MethodKind.Unary,
+    },
+    /**
+     * How much of a session the server holds, to resume it
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.GetUploadSession
+     */
+    getUploadSession: {
+      name: "GetUploadSession",
+      I: GetUploadSessionRequest,
+      O: UploadSession,
+      kind: MethodKind.Unary,
+      idempotency: MethodIdempotency.NoSideEffects,
+    },
+    /**
+     * Totals of the stored files the caller can list, overall and per
+     * directory, with the largest ones. Computed at most every few seconds, so
+     * the latest uploads may be missing (cacheable, may be called with HTTP GET)
+     *
+     * @generated from rpc fileupload.v1.FileUploadService.GetStats
+     */
+    getStats: {
+      name: "GetStats",
+      I: GetStatsRequest,
+      O: GetStatsResponse,
+      kind: MethodKind.Unary,
+      idempotency: MethodIdempotency.NoSideEffects,
+    },
   }
 } as const;
 
@@ -2,15 +2,15 @@
 // @generated from file fileupload/v1/fileupload.proto (package fileupload.v1, syntax proto3)
 /* eslint-disable */
 
-import type { GenFile, GenMessage, GenService } from "@bufbuild/protobuf/codegenv2";
-import { fileDesc, messageDesc, serviceDesc } from "@bufbuild/protobuf/codegenv2";
+import type { GenEnum, GenFile, GenMessage, GenService } from "@bufbuild/protobuf/codegenv2";
+import { enumDesc, fileDesc, messageDesc, serviceDesc } from "@bufbuild/protobuf/codegenv2";
 import type { Message } from "@bufbuild/protobuf";
 
 /**
  * Describes the file fileupload/v1/fileupload.proto.
  */
 export const file_fileupload_v1_fileupload: GenFile = /*@__PURE__*/
-  fileDesc("Ch5maWxldXBsb2FkL3YxL2ZpbGV1cGxvYWQucHJvdG8SDWZpbGV1cGxvYWQudjEidwoNVXBsb2FkUmVxdWVzdBIxCghtZXRhZGF0YRgBIAEoCzIdLmZpbGV1cGxvYWQudjEuVXBsb2FkTWV0YWRhdGFIABIPCgVjaHVuaxgCIAEoDEgAEhcKDWZpbmlzaF9jb21taXQYAyABKAlIAEIJCgdwYXlsb2FkIjEKDlVwbG9hZE1ldGFkYXRhEhAKCGZpbGVuYW1lGAEgASgJEg0KBXRpdGxlGAIgASgJIlIKEVVwbG9hZEZpbGVSZXF1ZXN0EgwKBGRhdGEYASABKAwSEAoIZmlsZW5hbWUYAiABKAkSDQoFdGl0bGUYAyABKAkSDgoGc2hhMjU2GAQgASgJIkAKDlVwbG9hZFJlc3BvbnNlEg8KB21lc3NhZ2UYASABKAkSDAoEc2l6ZRgCIAEoAxIPCgdoYXNoX29rGAMgASgIMqsBChFGaWxlVXBsb2FkU2VydmljZRJHCgZVcGxvYWQSHC5maWxldXBsb2FkLnYxLlVwbG9hZFJlcXVlc3QaHS5maWxldXBsb2FkLnYxLlVwbG9hZFJlc3BvbnNlKAESTQoKVXBsb2FkRmlsZRIgLmZpbGV1cGxvYWQudjEuVXBsb2FkRmlsZVJlcXVlc3QaHS5maWxldXBsb2FkLnYxLlVwbG9hZFJlc3BvbnNlQsoBChFjb20uZmlsZXVwbG9hZC52MUIPRmlsZXVwbG9hZFByb3RvUAFaT2dpdGh1Yi5jb20vbGFvLXRzZXUtaXMtYWxpdmUvZ28tZ3JwYy1maWxlLXVwbG9hZC9nZW4vZmlsZXVwbG9hZC92MTtmaWxldXBsb2FkdjGiAgNGWFiqAg1GaWxldXBsb2FkLlYxygINRmlsZXVwbG9hZFxWMeICGUZpbGV1cGxvYWRcVjFcR1BCTWV0YWRhdGHqAg5GaWxldXBsb2FkOjpWMWIGcHJvdG8z");
+  fileDesc("Ch5maWxldXBsb2FkL3YxL2ZpbGV1cGxvYWQucHJvdG8SDWZpbGV1cGxvYWQudjEiswIKDVVwbG9hZFJlcXVlc3QSMQoIbWV0YWRhdGEYASABKAsyHS5maWxldXBsb2FkLnYxLlVwbG9hZE1ldGFkYXRhSAASDwoFY2h1bmsYAiABKAxIABIXCg1maW5pc2hfY29tbWl0GAMgASgJSAASOgoQc2VnbWVudGVkX2NvbW1pdBgEIAEoCzIeLmZpbGV1cGxvYWQudjEuU2VnbWVudGVkQ29tbWl0SAASGAoLY29tbWl0X3NpemUYBSABKANIAYgBARIZCgxjaHVua19jcmMzMmMYBiABKA1IAogBARIYCgtjaHVua19pbmRleBgHIAEoBEgDiAEBQgkKB3BheWxvYWRCDgoMX2NvbW1pdF9zaXplQg8KDV9jaHVua19jcmMzMmNCDgoMX2NodW5rX2luZGV4InkKElVwbG9hZEJpZGlSZXNwb25zZRInCgNhY2sYASABKAsyGC5maWxldXBsb2FkLnYxLlVwbG9hZEFja0gAEi8KBnJlc3VsdBgCIAEoCzIdLmZpbGV1cGxvYWQudjEuVXBsb2FkUmVzcG9uc2VIAEIJCgdwYXlsb2FkIlwKCVVwbG9hZEFjaxIOCgZvZmZzZXQYASABKAMSEwoLY2h1bmtfaW5kZXgYAiABKAQSKgoGc3RhdHVzGAMgASgOMhouZmlsZXVwbG9hZC52MS5DaHVua1N0YXR1cyI5Cg9TZWdtZW50ZWRDb21taXQSDgoGc2hhMjU2GAEgASgJEhYKDnNlZ21lbnRfc2hhMjU2GAIgAygJIjQKDkZpZWxkVmlvbGF0aW9uEg0KBWZpZWxkGAEgASgJEhMKC2Rlc2NyaXB0aW9uGAIgASgJIl4KD0NvcnJ1cHRTZWdtZW50cxIQCghmaWxlbmFtZRgBIAEoCRIUCgxzZWdtZW50X3NpemUYAiABKAMSEgoKdG90YWxfc2l6ZRgDIAEoAxIPCgdpbmRleGVzGAQgAygDIqoCCg5VcGxvYWRNZXRhZGF0YRIQCghmaWxlbmFtZRgBIAEoCRINCgV0aXRsZRgCIAEoCRIOCgZzaGEyNTYYAyABKAkSDwoHZHJ5X3J1bhgEIAEoCBIaCg1kZWNsYXJlZF9zaXplGAUgASgDSACIAQESFAoMc2VnbWVudF9zaXplGAYgASgDEhEKCWhhc2hfb25seRgHIAEoCBIXCg9pZl9tYXRjaF9zaGEyNTYYCCABKAkSDwoHZXh0cmFjdBgJIAEoCBITCgt0dGxfc2Vjb25kcxgKIAEoAxIUCgxleHBpcmVzX3VuaXgYCyABKAMSEQoJYWNrX2V2ZXJ5GAwgASgNEhcKD2V4cGVjdGVkX2NodW5rcxgNIAEoBEIQCg5fZGVjbGFyZWRfc2l6ZSKAAgoRVXBsb2FkRmlsZVJlcXVlc3QSDAoEZGF0YRgBIAEoDBIQCghmaWxlbmFtZRgCIAEoCRINCgV0aXRsZRgDIAEoCRIOCgZzaGEyNTYYBCABKAkSDwoHZHJ5X3J1bhgFIAEoCBITCgZvZmZzZXQYBiABKANIAIgBARIPCgdpc19sYXN0GAcgASgIEhcKD2lmX21hdGNoX3NoYTI1NhgIIAEoCRIPCgdleHRyYWN0GAkgASgIEhMKC3R0bF9zZWNvbmRzGAogASgDEhQKDGV4cGlyZXNfdW5peBgLIAEoAxIVCg1wcmVmaXhfc2hhMjU2GAwgASgJQgkKB19vZmZzZXQi3AEKDlVwbG9hZFJlc3BvbnNlEg8KB21lc3NhZ2UYASABKAkSDAoEc2l6ZRgCIAEoAxIPCgdoYXNoX29rGAMgASgIEg4KBnNoYTI1NhgEIAEoCRIXCg9zdG9yZWRfZmlsZW5hbWUYBSABKAkSFQoNZXh0cmFjdGVkX2RpchgGIAEoCRIUCgxzdG9yYWdlX3BhdGgYByABKAkSLgoLaGFzaF9zdGF0dXMYCCABKA4yGS5maWxldXBsb2FkLnYxLkhhc2hTdGF0dXMSFAoMZXhwaXJlc191bml4GAkgASgDIhYKFEdldFNlcnZlckluZm9SZXF1ZXN0ImMKFUdldFNlcnZlckluZm9SZXNwb25zZRIPCgd2ZXJzaW9uGAEgASgJEh4KFm1heF91bmFyeV91cGxvYWRfYnl0ZXMYAiABKAMSGQoRbWF4X21lc3NhZ2VfYnl0ZXMYAyABKAMiKgoWR2V0RmlsZU1ldGFkYXRhUmVxdWVzdBIQCghmaWxlbmFtZRgBIAEoCSKjAQoXR2V0RmlsZU1ldGFkYXRhUmVzcG9uc2USEAoIZmlsZW5hbWUYASABKAkSDAoEc2l6ZRgCIAEoAxIOCgZzaGEyNTYYAyABKAkSFQoNbW9kaWZpZWRfdW5peBgEIAEoAxIUCgxkb3dubG9hZF91cmwYBSABKAkSFQoNaGFzX3RodW1ibmFpbBgGIAEoCBIUCgxleHBpcmVzX3VuaXgYByABKAMiIwoPU3RhdEZpbGVSZXF1ZXN0EhAKCGZpbGVuYW1lGAEgASgJImkKEFN0YXRGaWxlUmVzcG9uc2USEAoIZmlsZW5hbWUYASABKAkSDgoGZXhpc3RzGAIgASgIEgwKBHNpemUYAyABKAMSDgoGc2hhMjU2GAQgASgJEhUKDW1vZGlmaWVkX3VuaXgYBSABKAMiIgoQTGlzdEZpbGVzUmVxdWVzdBIOCgZwcmVmaXgYASABKAkifQoIRmlsZUluZm8SEAoIZmlsZW5hbWUYASABKAkSDAoEc2l6ZRgCIAEoAxIOCgZzaGEyNTYYAyABKAkSFQoNbW9kaWZpZWRfdW5peBgEIAEoAxIUCgxzdG9yYWdlX3BhdGgYBSABKAkSFAoMZXhwaXJlc191bml4GAYgASgDIjMKD0Rvd25sb2FkUmVxdWVzdBIQCghmaWxlbmFtZRgBIAEoCRIOCgZvZmZzZXQYAiABKAMiMwoQRG93bmxvYWRSZXNwb25zZRINCgVjaHVuaxgBIAEoDBIQCghsb2NhdGlvbhgCIAEoCSIqChZHZXRVcGxvYWRTdGF0dXNSZXF1ZXN0EhAKCGZpbGVuYW1lGAEgASgJImMKF0dldFVwbG9hZFN0YXR1c1Jlc3BvbnNlEhAKCGZpbGVuYW1lGAEgASgJEg4KBm9mZnNldBgCIAEoAxIXCgp0b3RhbF9zaXplGAMgASgDSACIAQFCDQoLX3RvdGFsX3NpemUiJwoTR2V0VGh1bWJuYWlsUmVxdWVzdBIQCghmaWxlbmFtZRgBIAEoCSJZChRHZXRUaHVtYm5haWxSZXNwb25zZRIMCgRkYXRhGAEgASgMEhQKDGNvbnRlbnRfdHlwZRgCIAEoCRINCgV3aWR0aBgDIAEoBRIOCgZoZWlnaHQYBCABKAUiQAoRUmVuYW1lRmlsZVJlcXVlc3QSDAoEZnJvbRgBIAEoCRIKCgJ0bxgCIAEoCRIRCglvdmVyd3JpdGUYAyABKAgiJgoSUmVuYW1lRmlsZVJlc3BvbnNlEhAKCGZpbGVuYW1lGAEgASgJIj4KD0NvcHlGaWxlUmVxdWVzdBIMCgRmcm9tGAEgASgJEgoKAnRvGAIgASgJEhEKCW92ZXJ3cml0ZRgDIAEoCCJkChNCZWdpbkFyY2hpdmVSZXF1ZXN0EhAKCGZpbGVuYW1lGAEgASgJEiwKBmZvcm1hdBgCIAEoDjIcLmZpbGV1cGxvYWQudjEuQXJjaGl2ZUZvcm1hdBINCgV0aXRsZRgDIAEoCSJqChRCZWdpbkFyY2hpdmVSZXNwb25zZRISCgphcmNoaXZlX2lkGAEgASgJEhAKCGZpbGVuYW1lGAIgASgJEiwKBmZvcm1hdBgDIAEoDjIcLmZpbGV1cGxvYWQudjEuQXJjaGl2ZUZvcm1hdCJYChZBZGRBcmNoaXZlRW50cnlSZXF1ZXN0EhIKCmFyY2hpdmVfaWQYASABKAkSDAoEbmFtZRgCIAEoCRIMCgRkYXRhGAMgASgMEg4KBnNoYTI1NhgEIAEoCSIpChNDbG9zZUFyY2hpdmVSZXF1ZXN0EhIKCmFyY2hpdmVfaWQYASABKAkiJAoQR2V0RXhwaXJ5UmVxdWVzdBIQCghmaWxlbmFtZRgBIAEoCSJSChNFeHRlbmRFeHBpcnlSZXF1ZXN0EhAKCGZpbGVuYW1lGAEgASgJEhMKC3R0bF9zZWNvbmRzGAIgASgDEhQKDGV4cGlyZXNfdW5peBgDIAEoAyI4Cg5FeHBpcnlSZXNwb25zZRIQCghmaWxlbmFtZRgBIAEoCRIUCgxleHBpcmVzX3VuaXgYAiABKAMiDgoMU2NydWJSZXF1ZXN0IogBCglTY3J1YkZpbGUSEAoIZmlsZW5hbWUYASABKAkSKgoGc3RhdHVzGAIgASgOMhouZmlsZXVwbG9hZC52MS5TY3J1YlN0YXR1cxIXCg9leHBlY3RlZF9zaGEyNTYYAyABKAkSFQoNYWN0dWFsX3NoYTI1NhgEIAEoCRINCgVlcnJvchgFIAEoCSJ6Cg1TY3J1YlByb2dyZXNzEiYKBGZpbGUYASABKAsyGC5maWxldXBsb2FkLnYxLlNjcnViRmlsZRIVCg1maWxlc19jaGVja2VkGAIgASgDEhMKC2ZpbGVzX3RvdGFsGAMgASgDEhUKDWJ5dGVzX2NoZWNrZWQYBCABKAMifgoMU2NydWJTdW1tYXJ5EhUKDWZpbGVzX2NoZWNrZWQYASABKAMSFQoNYnl0ZXNfY2hlY2tlZBgCIAEoAxIVCg1maWxlc19za2lwcGVkGAMgASgDEikKB2RhbWFnZWQYBCADKAsyGC5maWxldXBsb2FkLnYxLlNjcnViRmlsZSJ8Cg1TY3J1YlJlc3BvbnNlEjAKCHByb2dyZXNzGAEgASgLMhwuZmlsZXVwbG9hZC52MS5TY3J1YlByb2dyZXNzSAASLgoHc3VtbWFyeRgCIAEoCzIbLmZpbGV1cGxvYWQudjEuU2NydWJTdW1tYXJ5SABCCQoHcGF5bG9hZCJQChJXYXRjaEV2ZW50c1JlcXVlc3QSJwoFdHlwZXMYASADKA4yGC5maWxldXBsb2FkLnYxLkV2ZW50VHlwZRIRCgluYW1lc3BhY2UYAiABKAki0gEKC1NlcnZlckV2ZW50EiYKBHR5cGUYASABKA4yGC5maWxldXBsb2FkLnYxLkV2ZW50VHlwZRIRCgl0aW1lX3VuaXgYAiABKAMSCwoDcnBjGAMgASgJEhAKCGZpbGVuYW1lGAQgASgJEhcKD3N0b3JlZF9maWxlbmFtZRgFIAEoCRIMCgRzaXplGAYgASgDEgwKBGNvZGUYByABKAkSEAoIaWRlbnRpdHkYCCABKAkSEQoJbmFtZXNwYWNlGAkgASgJEg8KB2Ryb3BwZWQYCiABKAQiRAoTQ3JlYXRlVXBsb2FkUmVxdWVzdBIQCghmaWxlbmFtZRgBIAEoCRINCgV0aXRsZRgCIAEoCRIMCgRzaXplGAMgASgDImYKDVVwbG9hZFNlc3Npb24SEQoJdXBsb2FkX2lkGAEgASgJEhIKCnVwbG9hZF91cmwYAiABKAkSEAoIZmlsZW5hbWUYAyABKAkSDAoEc2l6ZRgEIAEoAxIOCgZvZmZzZXQYBSABKAMiRQoSVXBsb2FkQ2h1bmtSZXF1ZXN0EhEKCXVwbG9hZF9pZBgBIAEoCRIOCgZvZmZzZXQYAiABKAMSDAoEZGF0YRgDIAEoDCJUChNVcGxvYWRDaHVua1Jlc3BvbnNlEg4KBm9mZnNldBgBIAEoAxItCgZzdG9yZWQYAiABKAsyHS5maWxldXBsb2FkLnYxLlVwbG9hZFJlc3BvbnNlIiwKF0dldFVwbG9hZFNlc3Npb25SZXF1ZXN0EhEKCXVwbG9hZF9pZBgBIAEoCSIRCg9HZXRTdGF0c1JlcXVlc3QizQEKEEdldFN0YXRzUmVzcG9uc2USEwoLdG90YWxfZmlsZXMYASABKAMSEwoLdG90YWxfYnl0ZXMYAiABKAMSFAoMYXZlcmFnZV9zaXplGAMgASgDEjIKC2RpcmVjdG9yaWVzGAQgAygLMh0uZmlsZXVwbG9hZC52MS5EaXJlY3RvcnlTdGF0cxIuCg1sYXJnZXN0X2ZpbGVzGAUgAygLMhcuZmlsZXVwbG9hZC52MS5GaWxlSW5mbxIVCg1jb21wdXRlZF91bml4GAYgASgDIkEKDkRpcmVjdG9yeVN0YXRzEhEKCWRpcmVjdG9yeRgBIAEoCRINCgVmaWxlcxgCIAEoAxINCgVieXRlcxgDIAEoAypfCgtDaHVua1N0YXR1cxIcChhDSFVOS19TVEFUVVNfVU5TUEVDSUZJRUQQABIYChRDSFVOS19TVEFUVVNfV1JJVFRFThABEhgKFENIVU5LX1NUQVRVU19DT1JSVVBUEAIqewoKSGFzaFN0YXR1cxIbChdIQVNIX1NUQVRVU19VTlNQRUNJRklFRBAAEhgKFEhBU0hfU1RBVFVTX1ZFUklGSUVEEAESGAoUSEFTSF9TVEFUVVNfTUlTTUFUQ0gQAhIcChhIQVNIX1NUQVRVU19OT1RfUFJPVklERUQQAypfCg1BcmNoaXZlRm9ybWF0Eh4KGkFSQ0hJVkVfRk9STUFUX1VOU1BFQ0lGSUVEEAASFgoSQVJDSElWRV9GT1JNQVRfVEFSEAESFgoSQVJDSElWRV9GT1JNQVRfWklQEAIqkQEKC1NjcnViU3RhdHVzEhwKGFNDUlVCX1NUQVRVU19VTlNQRUNJRklFRBAAEhMKD1NDUlVCX1NUQVRVU19PSxABEhgKFFNDUlVCX1NUQVRVU19DT1JSVVBUEAISGAoUU0NSVUJfU1RBVFVTX01JU1NJTkcQAxIbChdTQ1JVQl9TVEFUVVNfVU5SRUFEQUJMRRAEKmsKCUV2ZW50VHlwZRIaChZFVkVOVF9UWVBFX1VOU1BFQ0lGSUVEEAASFQoRRVZFTlRfVFlQRV9VUExPQUQQARIVChFFVkVOVF9UWVBFX0RFTEVURRACEhQKEEVWRU5UX1RZUEVfRVJST1IQAzKyDwoRRmlsZVVwbG9hZFNlcnZpY2USRwoGVXBsb2FkEhwuZmlsZXVwbG9hZC52MS5VcGxvYWRSZXF1ZXN0Gh0uZmlsZXVwbG9hZC52MS5VcGxvYWRSZXNwb25zZSgBElEKClVwbG9hZEJpZGkSHC5maWxldXBsb2FkLnYxLlVwbG9hZFJlcXVlc3QaIS5maWxldXBsb2FkLnYxLlVwbG9hZEJpZGlSZXNwb25zZSgBMAESTQoKVXBsb2FkRmlsZRIgLmZpbGV1cGxvYWQudjEuVXBsb2FkRmlsZVJlcXVlc3QaHS5maWxldXBsb2FkLnYxLlVwbG9hZFJlc3BvbnNlEl8KDUdldFNlcnZlckluZm8SIy5maWxldXBsb2FkLnYxLkdldFNlcnZlckluZm9SZXF1ZXN0GiQuZmlsZXVwbG9hZC52MS5HZXRTZXJ2ZXJJbmZvUmVzcG9uc2UiA5ACARJlCg9HZXRGaWxlTWV0YWRhdGESJS5maWxldXBsb2FkLnYxLkdldEZpbGVNZXRhZGF0YVJlcXVlc3QaJi5maWxldXBsb2FkLnYxLkdldEZpbGVNZXRhZGF0YVJlc3BvbnNlIgOQAgESUAoIU3RhdEZpbGUSHi5maWxldXBsb2FkLnYxLlN0YXRGaWxlUmVxdWVzdBofLmZpbGV1cGxvYWQudjEuU3RhdEZpbGVSZXNwb25zZSIDkAIBEk0KD0xpc3RGaWxlc1N0cmVhbRIfLmZpbGV1cGxvYWQudjEuTGlzdEZpbGVzUmVxdWVzdBoXLmZpbGV1cGxvYWQudjEuRmlsZUluZm8wARJNCghEb3dubG9hZBIeLmZpbGV1cGxvYWQudjEuRG93bmxvYWRSZXF1ZXN0Gh8uZmlsZXVwbG9hZC52MS5Eb3dubG9hZFJlc3BvbnNlMAESZQoPR2V0VXBsb2FkU3RhdHVzEiUuZmlsZXVwbG9hZC52MS5HZXRVcGxvYWRTdGF0dXNSZXF1ZXN0GiYuZmlsZXVwbG9hZC52MS5HZXRVcGxvYWRTdGF0dXNSZXNwb25zZSIDkAIBElwKDEdldFRodW1ibmFpbBIiLmZpbGV1cGxvYWQudjEuR2V0VGh1bWJuYWlsUmVxdWVzdBojLmZpbGV1cGxvYWQudjEuR2V0VGh1bWJuYWlsUmVzcG9uc2UiA5ACARJRCgpSZW5hbWVGaWxlEiAuZmlsZXVwbG9hZC52MS5SZW5hbWVGaWxlUmVxdWVzdBohLmZpbGV1cGxvYWQudjEuUmVuYW1lRmlsZVJlc3BvbnNlEkkKCENvcHlGaWxlEh4uZmlsZXVwbG9hZC52MS5Db3B5RmlsZVJlcXVlc3QaHS5maWxldXBsb2FkLnYxLlVwbG9hZFJlc3BvbnNlElcKDEJlZ2luQXJjaGl2ZRIiLmZpbGV1cGxvYWQudjEuQmVnaW5BcmNoaXZlUmVxdWVzdBojLmZpbGV1cGxvYWQudjEuQmVnaW5BcmNoaXZlUmVzcG9uc2USVwoPQWRkQXJjaGl2ZUVudHJ5EiUuZmlsZXVwbG9hZC52MS5BZGRBcmNoaXZlRW50cnlSZXF1ZXN0Gh0uZmlsZXVwbG9hZC52MS5VcGxvYWRSZXNwb25zZRJRCgxDbG9zZUFyY2hpdmUSIi5maWxldXBsb2FkLnYxLkNsb3NlQXJjaGl2ZVJlcXVlc3QaHS5maWxldXBsb2FkLnYxLlVwbG9hZFJlc3BvbnNlElAKCUdldEV4cGlyeRIfLmZpbGV1cGxvYWQudjEuR2V0RXhwaXJ5UmVxdWVzdBodLmZpbGV1cGxvYWQudjEuRXhwaXJ5UmVzcG9uc2UiA5ACARJRCgxFeHRlbmRFeHBpcnkSIi5maWxldXBsb2FkLnYxLkV4dGVuZEV4cGlyeVJlcXVlc3QaHS5maWxldXBsb2FkLnYxLkV4cGlyeVJlc3BvbnNlEkQKBVNjcnViEhsuZmlsZXVwbG9hZC52MS5TY3J1YlJlcXVlc3QaHC5maWxldXBsb2FkLnYxLlNjcnViUmVzcG9uc2UwARJOCgtXYXRjaEV2ZW50cxIhLmZpbGV1cGxvYWQudjEuV2F0Y2hFdmVudHNSZXF1ZXN0GhouZmlsZXVwbG9hZC52MS5TZXJ2ZXJFdmVudDABElAKDENyZWF0ZVVwbG9hZBIiLmZpbGV1cGxvYWQudjEuQ3JlYXRlVXBsb2FkUmVxdWVzdBocLmZpbGV1cGxvYWQudjEuVXBsb2FkU2Vzc2lvbhJUCgtVcGxvYWRDaHVuaxIhLmZpbGV1cGxvYWQudjEuVXBsb2FkQ2h1bmtSZXF1ZXN0GiIuZmlsZXVwbG9hZC52MS5VcGxvYWRDaHVua1Jlc3BvbnNlEl0KEEdldFVwbG9hZFNlc3Npb24SJi5maWxldXBsb2FkLnYxLkdldFVwbG9hZFNlc3Npb25SZXF1ZXN0GhwuZmlsZXVwbG9hZC52MS5VcGxvYWRTZXNzaW9uIgOQAgESUAoIR2V0U3RhdHMSHi5maWxldXBsb2FkLnYxLkdldFN0YXRzUmVxdWVzdBofLmZpbGV1cGxvYWQudjEuR2V0U3RhdHNSZXNwb25zZSIDkAIBQsoBChFjb20uZmlsZXVwbG9hZC52MUIPRmlsZXVwbG9hZFByb3RvUAFaT2dpdGh1Yi5jb20vbGFvLXRzZXUtaXMtYWxpdmUvZ28tZ3JwYy1maWxlLXVwbG9hZC9nZW4vZmlsZXVwbG9hZC92MTtmaWxldXBsb2FkdjGiAgNGWFiqAg1GaWxldXBsb2FkLlYxygINRmlsZXVwbG9hZFxWMeICGUZpbGV1cGxvYWRcVjFcR1BCTWV0YWRhdGHqAg5GaWxldXBsb2FkOjpWMWIGcHJvdG8z");
 
 /**
  * Streaming upload request using oneof for type-safe state machine
@@ -45,7 +45,41 @@ export type UploadRequest = Message<"fileupload.v1.UploadRequest"> & {
      */
     value: string;
     case: "finishCommit";
+  } | {
+    /**
+     * Phase 3 alternative when metadata.segment_size is set
+     *
+     * @generated from field: fileupload.v1.SegmentedCommit segmented_commit = 4;
+     */
+    value: SegmentedCommit;
+    case: "segmentedCommit";
   } | { case: undefined; value?: undefined };
+
+  /**
+   * With finish_commit or segmented_commit: the number of bytes the client
+   * sent, checked against what the server received. Unset skips the check.
+   *
+   * @generated from field: optional int64 commit_size = 5;
+   */
+  commitSize?: bigint;
+
+  /**
+   * With chunk: its CRC-32C (Castagnoli), checked by the server. On UploadBidi
+   * a mismatch NACKs the chunk so the client sends it again, on Upload it
+   * fails with DATA_LOSS. Unset skips the check.
+   *
+   * @generated from field: optional uint32 chunk_crc32c = 6;
+   */
+  chunkCrc32c?: number;
+
+  /**
+   * With chunk: its zero-based position in the content. Required to resend a
+   * NACKed chunk; the server drops the chunks and commit that follow a NACK
+   * until the chunk at the NACKed index arrives again.
+   *
+   * @generated from field: optional uint64 chunk_index = 7;
+   */
+  chunkIndex?: bigint;
 };
 
 /**
@@ -55,6 +89,172 @@ export type UploadRequest = Message<"fileupload.v1.UploadRequest"> & {
 export const UploadRequestSchema: GenMessage<UploadRequest> = /*@__PURE__*/
   messageDesc(file_fileupload_v1_fileupload, 0);
 
+/**
+ * Answer of UploadBidi: acks while chunks arrive, then the result
+ *
+ * @generated from message fileupload.v1.UploadBidiResponse
+ */
+export type UploadBidiResponse = Message<"fileupload.v1.UploadBidiResponse"> & {
+  /**
+   * @generated from oneof fileupload.v1.UploadBidiResponse.payload
+   */
+  payload: {
+    /**
+     * @generated from field: fileupload.v1.UploadAck ack = 1;
+     */
+    value: UploadAck;
+    case: "ack";
+  } | {
+    /**
+     * Sent last, after the commit, as Upload would return it
+     *
+     * @generated from field: fileupload.v1.UploadResponse result = 2;
+     */
+    value: UploadResponse;
+    case: "result";
+  } | { case: undefined; value?: undefined };
+};
+
+/**
+ * Describes the message fileupload.v1.UploadBidiResponse.
+ * Use `create(UploadBidiResponseSchema)` to create a new message.
+ */
+export const UploadBidiResponseSchema: GenMessage<UploadBidiResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 1);
+
+/**
+ * Acknowledges every chunk written up to offset, or NACKs the chunk after it
+ *
+ * @generated from message fileupload.v1.UploadAck
+ */
+export type UploadAck = Message<"fileupload.v1.UploadAck"> & {
+  /**
+   * Bytes received and written so far
+   *
+   * @generated from field: int64 offset = 1;
+   */
+  offset: bigint;
+
+  /**
+   * Zero-based index of the last chunk written, or of the NACKed chunk
+   *
+   * @generated from field: uint64 chunk_index = 2;
+   */
+  chunkIndex: bigint;
+
+  /**
+   * Unspecified from servers that never NACK
+   *
+   * @generated from field: fileupload.v1.ChunkStatus status = 3;
+   */
+  status: ChunkStatus;
+};
+
+/**
+ * Describes the message fileupload.v1.UploadAck.
+ * Use `create(UploadAckSchema)` to create a new message.
+ */
+export const UploadAckSchema: GenMessage<UploadAck> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 2);
+
+/**
+ * Commit carrying the hash of every segment next to the hash of the whole
+ * content. Segment i covers bytes [i*segment_size, (i+1)*segment_size) and the
+ * last one may be shorter, so each can be verified and repaired on its own.
+ *
+ * @generated from message fileupload.v1.SegmentedCommit
+ */
+export type SegmentedCommit = Message<"fileupload.v1.SegmentedCommit"> & {
+  /**
+   * Hex-encoded SHA-256 of the whole content
+   *
+   * @generated from field: string sha256 = 1;
+   */
+  sha256: string;
+
+  /**
+   * Hex-encoded SHA-256 of each segment, in order
+   *
+   * @generated from field: repeated string segment_sha256 = 2;
+   */
+  segmentSha256: string[];
+};
+
+/**
+ * Describes the message fileupload.v1.SegmentedCommit.
+ * Use `create(SegmentedCommitSchema)` to create a new message.
+ */
+export const SegmentedCommitSchema: GenMessage<SegmentedCommit> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 3);
+
+/**
+ * Detail of the invalid_argument error refusing a malformed request field
+ *
+ * @generated from message fileupload.v1.FieldViolation
+ */
+export type FieldViolation = Message<"fileupload.v1.FieldViolation"> & {
+  /**
+   * Name of the offending field, as in this file, e.g. "title"
+   *
+   * @generated from field: string field = 1;
+   */
+  field: string;
+
+  /**
+   * What is wrong with its value
+   *
+   * @generated from field: string description = 2;
+   */
+  description: string;
+};
+
+/**
+ * Describes the message fileupload.v1.FieldViolation.
+ * Use `create(FieldViolationSchema)` to create a new message.
+ */
+export const FieldViolationSchema: GenMessage<FieldViolation> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 4);
+
+/**
+ * Detail of the data_loss error returned when segments of a segmented upload
+ * do not match. The content is kept as a partial ranged upload: re-sending the
+ * listed segments with PUT /files/{filename} and Content-Range completes it.
+ *
+ * @generated from message fileupload.v1.CorruptSegments
+ */
+export type CorruptSegments = Message<"fileupload.v1.CorruptSegments"> & {
+  /**
+   * Stored (sanitized) filename to PUT the segments to
+   *
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * @generated from field: int64 segment_size = 2;
+   */
+  segmentSize: bigint;
+
+  /**
+   * @generated from field: int64 total_size = 3;
+   */
+  totalSize: bigint;
+
+  /**
+   * Zero-based indexes of the segments whose hash differs
+   *
+   * @generated from field: repeated int64 indexes = 4;
+   */
+  indexes: bigint[];
+};
+
+/**
+ * Describes the message fileupload.v1.CorruptSegments.
+ * Use `create(CorruptSegmentsSchema)` to create a new message.
+ */
+export const CorruptSegmentsSchema: GenMessage<CorruptSegments> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 5);
+
 /**
  * Metadata for file upload (sent as first message in stream)
  *
@@ -70,6 +270,95 @@ export type UploadMetadata = Message<"fileupload.v1.UploadMetadata"> & {
    * @generated from field: string title = 2;
    */
   title: string;
+
+  /**
+   * Hex-encoded SHA-256 the commit will carry, when the client knows it
+   * before sending. Concurrent uploads declaring the same hash store the
+   * content once: the others wait for the first one and share its file,
+   * still checking their own content against the hash. Empty declares none.
+   *
+   * @generated from field: string sha256 = 3;
+   */
+  sha256: string;
+
+  /**
+   * Run every check and compute the hash, but store nothing
+   *
+   * @generated from field: bool dry_run = 4;
+   */
+  dryRun: boolean;
+
+  /**
+   * Total number of bytes the client will send, unset when unknown
+   *
+   * @generated from field: optional int64 declared_size = 5;
+   */
+  declaredSize?: bigint;
+
+  /**
+   * Hash the content in segments of this many bytes, finishing with a
+   * segmented_commit; 0 uses a single finish_commit hash
+   *
+   * @generated from field: int64 segment_size = 6;
+   */
+  segmentSize: bigint;
+
+  /**
+   * Only hash the streamed content and return its digest, storing nothing;
+   * the commit hash may be empty
+   *
+   * @generated from field: bool hash_only = 7;
+   */
+  hashOnly: boolean;
+
+  /**
+   * Only overwrite the stored file if its content has this hex-encoded
+   * SHA-256, failing with FAILED_PRECONDITION when it differs or nothing is
+   * stored; empty stores unconditionally
+   *
+   * @generated from field: string if_match_sha256 = 8;
+   */
+  ifMatchSha256: string;
+
+  /**
+   * After storing the .zip, .tar, .tar.gz or .tgz file, also unpack it into a
+   * directory named after it in the caller's directory of the server's
+   * extraction directory. Fails with
+   * FAILED_PRECONDITION when the server does not extract archives.
+   *
+   * @generated from field: bool extract = 9;
+   */
+  extract: boolean;
+
+  /**
+   * Delete the stored file this many seconds after it is stored. At most one
+   * of ttl_seconds and expires_unix may be set; neither keeps it forever.
+   *
+   * @generated from field: int64 ttl_seconds = 10;
+   */
+  ttlSeconds: bigint;
+
+  /**
+   * Delete the stored file at this time, in seconds since the Unix epoch
+   *
+   * @generated from field: int64 expires_unix = 11;
+   */
+  expiresUnix: bigint;
+
+  /**
+   * UploadBidi only: acknowledge every this many chunks instead of every one
+   *
+   * @generated from field: uint32 ack_every = 12;
+   */
+  ackEvery: number;
+
+  /**
+   * Number of chunk messages the client will send, 0 when unknown. A stream
+   * sending more or fewer fails with INVALID_ARGUMENT.
+   *
+   * @generated from field: uint64 expected_chunks = 13;
+   */
+  expectedChunks: bigint;
 };
 
 /**
@@ -77,7 +366,7 @@ export type UploadMetadata = Message<"fileupload.v1.UploadMetadata"> & {
  * Use `create(UploadMetadataSchema)` to create a new message.
  */
 export const UploadMetadataSchema: GenMessage<UploadMetadata> = /*@__PURE__*/
-  messageDesc(file_fileupload_v1_fileupload, 1);
+  messageDesc(file_fileupload_v1_fileupload, 6);
 
 /**
  * Single request for browser uploads (unary)
@@ -104,6 +393,69 @@ export type UploadFileRequest = Message<"fileupload.v1.UploadFileRequest"> & {
    * @generated from field: string sha256 = 4;
    */
   sha256: string;
+
+  /**
+   * Run every check and compute the hash, but store nothing
+   *
+   * @generated from field: bool dry_run = 5;
+   */
+  dryRun: boolean;
+
+  /**
+   * Set to upload the file in several calls: data is written at this offset
+   * of a partial file that is completed once every byte up to the end of the
+   * is_last chunk has arrived. sha256 is then checked against the whole file.
+   *
+   * @generated from field: optional int64 offset = 6;
+   */
+  offset?: bigint;
+
+  /**
+   * Marks the chunk ending the file, whose end gives the total size
+   *
+   * @generated from field: bool is_last = 7;
+   */
+  isLast: boolean;
+
+  /**
+   * Only overwrite the stored file if its content has this hex-encoded
+   * SHA-256, as in UploadMetadata. With offset it is checked by the call
+   * completing the file.
+   *
+   * @generated from field: string if_match_sha256 = 8;
+   */
+  ifMatchSha256: string;
+
+  /**
+   * Unpack the stored archive, as in UploadMetadata. With offset it is
+   * unpacked by the call completing the file.
+   *
+   * @generated from field: bool extract = 9;
+   */
+  extract: boolean;
+
+  /**
+   * Expiry of the stored file, as in UploadMetadata. With offset it is taken
+   * from the call completing the file.
+   *
+   * @generated from field: int64 ttl_seconds = 10;
+   */
+  ttlSeconds: bigint;
+
+  /**
+   * @generated from field: int64 expires_unix = 11;
+   */
+  expiresUnix: bigint;
+
+  /**
+   * With offset: the hex-encoded SHA-256 of the bytes before offset, for a
+   * resumed upload. The server re-hashes its partial file first; when it
+   * differs the partial upload is discarded and the call fails with
+   * DATA_LOSS, so the client starts over from offset 0.
+   *
+   * @generated from field: string prefix_sha256 = 12;
+   */
+  prefixSha256: string;
 };
 
 /**
@@ -111,7 +463,7 @@ export type UploadFileRequest = Message<"fileupload.v1.UploadFileRequest"> & {
  * Use `create(UploadFileRequestSchema)` to create a new message.
  */
 export const UploadFileRequestSchema: GenMessage<UploadFileRequest> = /*@__PURE__*/
-  messageDesc(file_fileupload_v1_fileupload, 2);
+  messageDesc(file_fileupload_v1_fileupload, 7);
 
 /**
  * @generated from message fileupload.v1.UploadResponse
@@ -128,9 +480,61 @@ export type UploadResponse = Message<"fileupload.v1.UploadResponse"> & {
   size: bigint;
 
   /**
+   * Same as hash_status == HASH_STATUS_VERIFIED
+   *
    * @generated from field: bool hash_ok = 3;
    */
   hashOk: boolean;
+
+  /**
+   * Hex-encoded SHA-256 of the content as computed by the server
+   *
+   * @generated from field: string sha256 = 4;
+   */
+  sha256: string;
+
+  /**
+   * Name the file is stored under, for GetFileMetadata and Download: the
+   * sanitized filename, or a random one when the server assigns names.
+   * Empty when nothing was stored.
+   *
+   * @generated from field: string stored_filename = 5;
+   */
+  storedFilename: string;
+
+  /**
+   * Directory, relative to the server's extraction directory, the archive
+   * was unpacked into when extract was set
+   *
+   * @generated from field: string extracted_dir = 6;
+   */
+  extractedDir: string;
+
+  /**
+   * Where the file landed in the server's storage: its path relative to the
+   * upload directory with "/" separators, including any routed subdirectory.
+   * Empty when nothing was stored.
+   *
+   * @generated from field: string storage_path = 7;
+   */
+  storagePath: string;
+
+  /**
+   * Outcome of checking the client's hash of the content, so a missing hash
+   * is not mistaken for a wrong one. Unspecified for an incomplete chunked
+   * upload.
+   *
+   * @generated from field: fileupload.v1.HashStatus hash_status = 8;
+   */
+  hashStatus: HashStatus;
+
+  /**
+   * When the stored file will be deleted, in seconds since the Unix epoch;
+   * 0 when it does not expire
+   *
+   * @generated from field: int64 expires_unix = 9;
+   */
+  expiresUnix: bigint;
 };
 
 /**
@@ -138,32 +542,1607 @@ export type UploadResponse = Message<"fileupload.v1.UploadResponse"> & {
  * Use `create(UploadResponseSchema)` to create a new message.
  */
 export const UploadResponseSchema: GenMessage<UploadResponse> = /*@__PURE__*/
-  messageDesc(file_fileupload_v1_fileupload, 3);
+  messageDesc(file_fileupload_v1_fileupload, 8);
 
 /**
- * @generated from service fileupload.v1.FileUploadService
+ * @generated from message fileupload.v1.GetServerInfoRequest
  */
-export const FileUploadService: GenService<{
+export type GetServerInfoRequest = Message<"fileupload.v1.GetServerInfoRequest"> & {
+};
+
+/**
+ * Describes the message fileupload.v1.GetServerInfoRequest.
+ * Use `create(GetServerInfoRequestSchema)` to create a new message.
+ */
+export const GetServerInfoRequestSchema: GenMessage<GetServerInfoRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 9);
+
+/**
+ * @generated from message fileupload.v1.GetServerInfoResponse
+ */
+export type GetServerInfoResponse = Message<"fileupload.v1.GetServerInfoResponse"> & {
   /**
-   * Streaming upload for native clients (Go, etc.)
-   * Protocol: 1) metadata, 2) chunks..., 3) finish_commit
+   * @generated from field: string version = 1;
+   */
+  version: string;
+
+  /**
+   * Largest data one UploadFile call accepts, 0 when unlimited
    *
-   * @generated from rpc fileupload.v1.FileUploadService.Upload
+   * @generated from field: int64 max_unary_upload_bytes = 2;
    */
-  upload: {
-    methodKind: "client_streaming";
-    input: typeof UploadRequestSchema;
-    output: typeof UploadResponseSchema;
-  },
+  maxUnaryUploadBytes: bigint;
+
   /**
-   * Unary upload for browser clients (Fetch API doesn't support client streaming)
+   * Largest message, a chunk or a whole UploadFile request, 0 when unlimited
    *
-   * @generated from rpc fileupload.v1.FileUploadService.UploadFile
+   * @generated from field: int64 max_message_bytes = 3;
    */
-  uploadFile: {
-    methodKind: "unary";
-    input: typeof UploadFileRequestSchema;
-    output: typeof UploadResponseSchema;
+  maxMessageBytes: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.GetServerInfoResponse.
+ * Use `create(GetServerInfoResponseSchema)` to create a new message.
+ */
+export const GetServerInfoResponseSchema: GenMessage<GetServerInfoResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 10);
+
+/**
+ * @generated from message fileupload.v1.GetFileMetadataRequest
+ */
+export type GetFileMetadataRequest = Message<"fileupload.v1.GetFileMetadataRequest"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+};
+
+/**
+ * Describes the message fileupload.v1.GetFileMetadataRequest.
+ * Use `create(GetFileMetadataRequestSchema)` to create a new message.
+ */
+export const GetFileMetadataRequestSchema: GenMessage<GetFileMetadataRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 11);
+
+/**
+ * @generated from message fileupload.v1.GetFileMetadataResponse
+ */
+export type GetFileMetadataResponse = Message<"fileupload.v1.GetFileMetadataResponse"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * @generated from field: int64 size = 2;
+   */
+  size: bigint;
+
+  /**
+   * @generated from field: string sha256 = 3;
+   */
+  sha256: string;
+
+  /**
+   * Last modification time in seconds since the Unix epoch
+   *
+   * @generated from field: int64 modified_unix = 4;
+   */
+  modifiedUnix: bigint;
+
+  /**
+   * URL to fetch the content from directly, set when downloads are handed off
+   * to external storage
+   *
+   * @generated from field: string download_url = 5;
+   */
+  downloadUrl: string;
+
+  /**
+   * A thumbnail of the image is available with GetThumbnail
+   *
+   * @generated from field: bool has_thumbnail = 6;
+   */
+  hasThumbnail: boolean;
+
+  /**
+   * When the file will be deleted, in seconds since the Unix epoch; 0 when
+   * it does not expire
+   *
+   * @generated from field: int64 expires_unix = 7;
+   */
+  expiresUnix: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.GetFileMetadataResponse.
+ * Use `create(GetFileMetadataResponseSchema)` to create a new message.
+ */
+export const GetFileMetadataResponseSchema: GenMessage<GetFileMetadataResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 12);
+
+/**
+ * @generated from message fileupload.v1.StatFileRequest
+ */
+export type StatFileRequest = Message<"fileupload.v1.StatFileRequest"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+};
+
+/**
+ * Describes the message fileupload.v1.StatFileRequest.
+ * Use `create(StatFileRequestSchema)` to create a new message.
+ */
+export const StatFileRequestSchema: GenMessage<StatFileRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 13);
+
+/**
+ * @generated from message fileupload.v1.StatFileResponse
+ */
+export type StatFileResponse = Message<"fileupload.v1.StatFileResponse"> & {
+  /**
+   * Sanitized filename
+   *
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * The other fields are only set when it is true
+   *
+   * @generated from field: bool exists = 2;
+   */
+  exists: boolean;
+
+  /**
+   * @generated from field: int64 size = 3;
+   */
+  size: bigint;
+
+  /**
+   * @generated from field: string sha256 = 4;
+   */
+  sha256: string;
+
+  /**
+   * Last modification time in seconds since the Unix epoch
+   *
+   * @generated from field: int64 modified_unix = 5;
+   */
+  modifiedUnix: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.StatFileResponse.
+ * Use `create(StatFileResponseSchema)` to create a new message.
+ */
+export const StatFileResponseSchema: GenMessage<StatFileResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 14);
+
+/**
+ * @generated from message fileupload.v1.ListFilesRequest
+ */
+export type ListFilesRequest = Message<"fileupload.v1.ListFilesRequest"> & {
+  /**
+   * Only list the files whose name starts with this
+   *
+   * @generated from field: string prefix = 1;
+   */
+  prefix: string;
+};
+
+/**
+ * Describes the message fileupload.v1.ListFilesRequest.
+ * Use `create(ListFilesRequestSchema)` to create a new message.
+ */
+export const ListFilesRequestSchema: GenMessage<ListFilesRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 15);
+
+/**
+ * @generated from message fileupload.v1.FileInfo
+ */
+export type FileInfo = Message<"fileupload.v1.FileInfo"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * @generated from field: int64 size = 2;
+   */
+  size: bigint;
+
+  /**
+   * Hex-encoded SHA-256 from the server's index, empty for a file stored
+   * behind its back: the listing never reads file content
+   *
+   * @generated from field: string sha256 = 3;
+   */
+  sha256: string;
+
+  /**
+   * Last modification time in seconds since the Unix epoch
+   *
+   * @generated from field: int64 modified_unix = 4;
+   */
+  modifiedUnix: bigint;
+
+  /**
+   * Path relative to the upload directory, as in UploadResponse
+   *
+   * @generated from field: string storage_path = 5;
+   */
+  storagePath: string;
+
+  /**
+   * Expiry in seconds since the Unix epoch, 0 when the file does not expire
+   *
+   * @generated from field: int64 expires_unix = 6;
+   */
+  expiresUnix: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.FileInfo.
+ * Use `create(FileInfoSchema)` to create a new message.
+ */
+export const FileInfoSchema: GenMessage<FileInfo> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 16);
+
+/**
+ * @generated from message fileupload.v1.DownloadRequest
+ */
+export type DownloadRequest = Message<"fileupload.v1.DownloadRequest"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * Skip this many leading bytes, to resume an interrupted download; past the
+   * end of the file fails with OUT_OF_RANGE. A location handed off to external
+   * storage is not affected, fetch it with a Range header instead.
+   *
+   * @generated from field: int64 offset = 2;
+   */
+  offset: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.DownloadRequest.
+ * Use `create(DownloadRequestSchema)` to create a new message.
+ */
+export const DownloadRequestSchema: GenMessage<DownloadRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 17);
+
+/**
+ * @generated from message fileupload.v1.DownloadResponse
+ */
+export type DownloadResponse = Message<"fileupload.v1.DownloadResponse"> & {
+  /**
+   * @generated from field: bytes chunk = 1;
+   */
+  chunk: Uint8Array;
+
+  /**
+   * Set in a single message, instead of any chunks, when the content must be
+   * fetched from this URL on external storage
+   *
+   * @generated from field: string location = 2;
+   */
+  location: string;
+};
+
+/**
+ * Describes the message fileupload.v1.DownloadResponse.
+ * Use `create(DownloadResponseSchema)` to create a new message.
+ */
+export const DownloadResponseSchema: GenMessage<DownloadResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 18);
+
+/**
+ * @generated from message fileupload.v1.GetUploadStatusRequest
+ */
+export type GetUploadStatusRequest = Message<"fileupload.v1.GetUploadStatusRequest"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+};
+
+/**
+ * Describes the message fileupload.v1.GetUploadStatusRequest.
+ * Use `create(GetUploadStatusRequestSchema)` to create a new message.
+ */
+export const GetUploadStatusRequestSchema: GenMessage<GetUploadStatusRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 19);
+
+/**
+ * @generated from message fileupload.v1.GetUploadStatusResponse
+ */
+export type GetUploadStatusResponse = Message<"fileupload.v1.GetUploadStatusResponse"> & {
+  /**
+   * Stored (sanitized) filename
+   *
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * Bytes received from offset 0 without a gap: the offset to resume from.
+   * 0 when no upload of filename is in progress.
+   *
+   * @generated from field: int64 offset = 2;
+   */
+  offset: bigint;
+
+  /**
+   * Total size once the is_last chunk has arrived, unset while unknown
+   *
+   * @generated from field: optional int64 total_size = 3;
+   */
+  totalSize?: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.GetUploadStatusResponse.
+ * Use `create(GetUploadStatusResponseSchema)` to create a new message.
+ */
+export const GetUploadStatusResponseSchema: GenMessage<GetUploadStatusResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 20);
+
+/**
+ * @generated from message fileupload.v1.GetThumbnailRequest
+ */
+export type GetThumbnailRequest = Message<"fileupload.v1.GetThumbnailRequest"> & {
+  /**
+   * Stored filename of the image
+   *
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+};
+
+/**
+ * Describes the message fileupload.v1.GetThumbnailRequest.
+ * Use `create(GetThumbnailRequestSchema)` to create a new message.
+ */
+export const GetThumbnailRequestSchema: GenMessage<GetThumbnailRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 21);
+
+/**
+ * @generated from message fileupload.v1.GetThumbnailResponse
+ */
+export type GetThumbnailResponse = Message<"fileupload.v1.GetThumbnailResponse"> & {
+  /**
+   * JPEG encoded thumbnail
+   *
+   * @generated from field: bytes data = 1;
+   */
+  data: Uint8Array;
+
+  /**
+   * @generated from field: string content_type = 2;
+   */
+  contentType: string;
+
+  /**
+   * @generated from field: int32 width = 3;
+   */
+  width: number;
+
+  /**
+   * @generated from field: int32 height = 4;
+   */
+  height: number;
+};
+
+/**
+ * Describes the message fileupload.v1.GetThumbnailResponse.
+ * Use `create(GetThumbnailResponseSchema)` to create a new message.
+ */
+export const GetThumbnailResponseSchema: GenMessage<GetThumbnailResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 22);
+
+/**
+ * @generated from message fileupload.v1.RenameFileRequest
+ */
+export type RenameFileRequest = Message<"fileupload.v1.RenameFileRequest"> & {
+  /**
+   * Stored filename to rename
+   *
+   * @generated from field: string from = 1;
+   */
+  from: string;
+
+  /**
+   * New filename, sanitized like an upload's; it may route the file to
+   * another subdirectory
+   *
+   * @generated from field: string to = 2;
+   */
+  to: string;
+
+  /**
+   * Replace an existing file named to instead of failing with ALREADY_EXISTS
+   *
+   * @generated from field: bool overwrite = 3;
+   */
+  overwrite: boolean;
+};
+
+/**
+ * Describes the message fileupload.v1.RenameFileRequest.
+ * Use `create(RenameFileRequestSchema)` to create a new message.
+ */
+export const RenameFileRequestSchema: GenMessage<RenameFileRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 23);
+
+/**
+ * @generated from message fileupload.v1.RenameFileResponse
+ */
+export type RenameFileResponse = Message<"fileupload.v1.RenameFileResponse"> & {
+  /**
+   * Sanitized new filename
+   *
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+};
+
+/**
+ * Describes the message fileupload.v1.RenameFileResponse.
+ * Use `create(RenameFileResponseSchema)` to create a new message.
+ */
+export const RenameFileResponseSchema: GenMessage<RenameFileResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 24);
+
+/**
+ * @generated from message fileupload.v1.CopyFileRequest
+ */
+export type CopyFileRequest = Message<"fileupload.v1.CopyFileRequest"> & {
+  /**
+   * Stored filename to copy
+   *
+   * @generated from field: string from = 1;
+   */
+  from: string;
+
+  /**
+   * Filename of the copy, sanitized like an upload's
+   *
+   * @generated from field: string to = 2;
+   */
+  to: string;
+
+  /**
+   * Replace an existing file named to instead of failing with ALREADY_EXISTS
+   *
+   * @generated from field: bool overwrite = 3;
+   */
+  overwrite: boolean;
+};
+
+/**
+ * Describes the message fileupload.v1.CopyFileRequest.
+ * Use `create(CopyFileRequestSchema)` to create a new message.
+ */
+export const CopyFileRequestSchema: GenMessage<CopyFileRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 25);
+
+/**
+ * @generated from message fileupload.v1.BeginArchiveRequest
+ */
+export type BeginArchiveRequest = Message<"fileupload.v1.BeginArchiveRequest"> & {
+  /**
+   * Filename the archive is stored under, sanitized like an upload's
+   *
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * @generated from field: fileupload.v1.ArchiveFormat format = 2;
+   */
+  format: ArchiveFormat;
+
+  /**
+   * @generated from field: string title = 3;
+   */
+  title: string;
+};
+
+/**
+ * Describes the message fileupload.v1.BeginArchiveRequest.
+ * Use `create(BeginArchiveRequestSchema)` to create a new message.
+ */
+export const BeginArchiveRequestSchema: GenMessage<BeginArchiveRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 26);
+
+/**
+ * @generated from message fileupload.v1.BeginArchiveResponse
+ */
+export type BeginArchiveResponse = Message<"fileupload.v1.BeginArchiveResponse"> & {
+  /**
+   * Identifies the archive in AddArchiveEntry and CloseArchive
+   *
+   * @generated from field: string archive_id = 1;
+   */
+  archiveId: string;
+
+  /**
+   * Sanitized filename
+   *
+   * @generated from field: string filename = 2;
+   */
+  filename: string;
+
+  /**
+   * @generated from field: fileupload.v1.ArchiveFormat format = 3;
+   */
+  format: ArchiveFormat;
+};
+
+/**
+ * Describes the message fileupload.v1.BeginArchiveResponse.
+ * Use `create(BeginArchiveResponseSchema)` to create a new message.
+ */
+export const BeginArchiveResponseSchema: GenMessage<BeginArchiveResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 27);
+
+/**
+ * @generated from message fileupload.v1.AddArchiveEntryRequest
+ */
+export type AddArchiveEntryRequest = Message<"fileupload.v1.AddArchiveEntryRequest"> & {
+  /**
+   * @generated from field: string archive_id = 1;
+   */
+  archiveId: string;
+
+  /**
+   * Path of the entry inside the archive; "/" separates directories and each
+   * element is sanitized like a filename
+   *
+   * @generated from field: string name = 2;
+   */
+  name: string;
+
+  /**
+   * @generated from field: bytes data = 3;
+   */
+  data: Uint8Array;
+
+  /**
+   * Hex-encoded SHA-256 of data; when set, an entry that differs is refused
+   * with DATA_LOSS and not added
+   *
+   * @generated from field: string sha256 = 4;
+   */
+  sha256: string;
+};
+
+/**
+ * Describes the message fileupload.v1.AddArchiveEntryRequest.
+ * Use `create(AddArchiveEntryRequestSchema)` to create a new message.
+ */
+export const AddArchiveEntryRequestSchema: GenMessage<AddArchiveEntryRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 28);
+
+/**
+ * @generated from message fileupload.v1.CloseArchiveRequest
+ */
+export type CloseArchiveRequest = Message<"fileupload.v1.CloseArchiveRequest"> & {
+  /**
+   * @generated from field: string archive_id = 1;
+   */
+  archiveId: string;
+};
+
+/**
+ * Describes the message fileupload.v1.CloseArchiveRequest.
+ * Use `create(CloseArchiveRequestSchema)` to create a new message.
+ */
+export const CloseArchiveRequestSchema: GenMessage<CloseArchiveRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 29);
+
+/**
+ * @generated from message fileupload.v1.GetExpiryRequest
+ */
+export type GetExpiryRequest = Message<"fileupload.v1.GetExpiryRequest"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+};
+
+/**
+ * Describes the message fileupload.v1.GetExpiryRequest.
+ * Use `create(GetExpiryRequestSchema)` to create a new message.
+ */
+export const GetExpiryRequestSchema: GenMessage<GetExpiryRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 30);
+
+/**
+ * @generated from message fileupload.v1.ExtendExpiryRequest
+ */
+export type ExtendExpiryRequest = Message<"fileupload.v1.ExtendExpiryRequest"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * New expiry counted from now, or an absolute one in expires_unix; exactly
+   * one must be set and it must be later than the current expiry
+   *
+   * @generated from field: int64 ttl_seconds = 2;
+   */
+  ttlSeconds: bigint;
+
+  /**
+   * @generated from field: int64 expires_unix = 3;
+   */
+  expiresUnix: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.ExtendExpiryRequest.
+ * Use `create(ExtendExpiryRequestSchema)` to create a new message.
+ */
+export const ExtendExpiryRequestSchema: GenMessage<ExtendExpiryRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 31);
+
+/**
+ * @generated from message fileupload.v1.ExpiryResponse
+ */
+export type ExpiryResponse = Message<"fileupload.v1.ExpiryResponse"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * When the file will be deleted, in seconds since the Unix epoch; 0 when
+   * it does not expire
+   *
+   * @generated from field: int64 expires_unix = 2;
+   */
+  expiresUnix: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.ExpiryResponse.
+ * Use `create(ExpiryResponseSchema)` to create a new message.
+ */
+export const ExpiryResponseSchema: GenMessage<ExpiryResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 32);
+
+/**
+ * @generated from message fileupload.v1.ScrubRequest
+ */
+export type ScrubRequest = Message<"fileupload.v1.ScrubRequest"> & {
+};
+
+/**
+ * Describes the message fileupload.v1.ScrubRequest.
+ * Use `create(ScrubRequestSchema)` to create a new message.
+ */
+export const ScrubRequestSchema: GenMessage<ScrubRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 33);
+
+/**
+ * @generated from message fileupload.v1.ScrubFile
+ */
+export type ScrubFile = Message<"fileupload.v1.ScrubFile"> & {
+  /**
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * @generated from field: fileupload.v1.ScrubStatus status = 2;
+   */
+  status: ScrubStatus;
+
+  /**
+   * Hex-encoded SHA-256 in the hash index
+   *
+   * @generated from field: string expected_sha256 = 3;
+   */
+  expectedSha256: string;
+
+  /**
+   * Hex-encoded SHA-256 of the content, empty unless it was read
+   *
+   * @generated from field: string actual_sha256 = 4;
+   */
+  actualSha256: string;
+
+  /**
+   * @generated from field: string error = 5;
+   */
+  error: string;
+};
+
+/**
+ * Describes the message fileupload.v1.ScrubFile.
+ * Use `create(ScrubFileSchema)` to create a new message.
+ */
+export const ScrubFileSchema: GenMessage<ScrubFile> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 34);
+
+/**
+ * @generated from message fileupload.v1.ScrubProgress
+ */
+export type ScrubProgress = Message<"fileupload.v1.ScrubProgress"> & {
+  /**
+   * The file just checked
+   *
+   * @generated from field: fileupload.v1.ScrubFile file = 1;
+   */
+  file?: ScrubFile;
+
+  /**
+   * @generated from field: int64 files_checked = 2;
+   */
+  filesChecked: bigint;
+
+  /**
+   * @generated from field: int64 files_total = 3;
+   */
+  filesTotal: bigint;
+
+  /**
+   * @generated from field: int64 bytes_checked = 4;
+   */
+  bytesChecked: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.ScrubProgress.
+ * Use `create(ScrubProgressSchema)` to create a new message.
+ */
+export const ScrubProgressSchema: GenMessage<ScrubProgress> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 35);
+
+/**
+ * @generated from message fileupload.v1.ScrubSummary
+ */
+export type ScrubSummary = Message<"fileupload.v1.ScrubSummary"> & {
+  /**
+   * @generated from field: int64 files_checked = 1;
+   */
+  filesChecked: bigint;
+
+  /**
+   * @generated from field: int64 bytes_checked = 2;
+   */
+  bytesChecked: bigint;
+
+  /**
+   * Files replaced or removed while the scrub ran, not checked
+   *
+   * @generated from field: int64 files_skipped = 3;
+   */
+  filesSkipped: bigint;
+
+  /**
+   * Every file that is not SCRUB_STATUS_OK
+   *
+   * @generated from field: repeated fileupload.v1.ScrubFile damaged = 4;
+   */
+  damaged: ScrubFile[];
+};
+
+/**
+ * Describes the message fileupload.v1.ScrubSummary.
+ * Use `create(ScrubSummarySchema)` to create a new message.
+ */
+export const ScrubSummarySchema: GenMessage<ScrubSummary> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 36);
+
+/**
+ * A progress message per file, then the summary as the last message
+ *
+ * @generated from message fileupload.v1.ScrubResponse
+ */
+export type ScrubResponse = Message<"fileupload.v1.ScrubResponse"> & {
+  /**
+   * @generated from oneof fileupload.v1.ScrubResponse.payload
+   */
+  payload: {
+    /**
+     * @generated from field: fileupload.v1.ScrubProgress progress = 1;
+     */
+    value: ScrubProgress;
+    case: "progress";
+  } | {
+    /**
+     * @generated from field: fileupload.v1.ScrubSummary summary = 2;
+     */
+    value: ScrubSummary;
+    case: "summary";
+  } | { case: undefined; value?: undefined };
+};
+
+/**
+ * Describes the message fileupload.v1.ScrubResponse.
+ * Use `create(ScrubResponseSchema)` to create a new message.
+ */
+export const ScrubResponseSchema: GenMessage<ScrubResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 37);
+
+/**
+ * @generated from message fileupload.v1.WatchEventsRequest
+ */
+export type WatchEventsRequest = Message<"fileupload.v1.WatchEventsRequest"> & {
+  /**
+   * Only these event types, every type when empty
+   *
+   * @generated from field: repeated fileupload.v1.EventType types = 1;
+   */
+  types: EventType[];
+
+  /**
+   * Only events of callers whose client certificate has this organization;
+   * deletions of expired files have no caller and are left out
+   *
+   * @generated from field: string namespace = 2;
+   */
+  namespace: string;
+};
+
+/**
+ * Describes the message fileupload.v1.WatchEventsRequest.
+ * Use `create(WatchEventsRequestSchema)` to create a new message.
+ */
+export const WatchEventsRequestSchema: GenMessage<WatchEventsRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 38);
+
+/**
+ * @generated from message fileupload.v1.ServerEvent
+ */
+export type ServerEvent = Message<"fileupload.v1.ServerEvent"> & {
+  /**
+   * @generated from field: fileupload.v1.EventType type = 1;
+   */
+  type: EventType;
+
+  /**
+   * @generated from field: int64 time_unix = 2;
+   */
+  timeUnix: bigint;
+
+  /**
+   * The endpoint of an upload, as in the events log: Upload, UploadFile,
+   * multipart, PutFile, tus, ... Empty for deletions.
+   *
+   * @generated from field: string rpc = 3;
+   */
+  rpc: string;
+
+  /**
+   * @generated from field: string filename = 4;
+   */
+  filename: string;
+
+  /**
+   * Set when the file is stored under another name
+   *
+   * @generated from field: string stored_filename = 5;
+   */
+  storedFilename: string;
+
+  /**
+   * @generated from field: int64 size = 6;
+   */
+  size: bigint;
+
+  /**
+   * The error code of a failed upload, as in the events log
+   *
+   * @generated from field: string code = 7;
+   */
+  code: string;
+
+  /**
+   * @generated from field: string identity = 8;
+   */
+  identity: string;
+
+  /**
+   * @generated from field: string namespace = 9;
+   */
+  namespace: string;
+
+  /**
+   * Events not delivered to this subscriber since the previous one because
+   * it was not keeping up
+   *
+   * @generated from field: uint64 dropped = 10;
+   */
+  dropped: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.ServerEvent.
+ * Use `create(ServerEventSchema)` to create a new message.
+ */
+export const ServerEventSchema: GenMessage<ServerEvent> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 39);
+
+/**
+ * @generated from message fileupload.v1.CreateUploadRequest
+ */
+export type CreateUploadRequest = Message<"fileupload.v1.CreateUploadRequest"> & {
+  /**
+   * Sanitized like an upload's filename
+   *
+   * @generated from field: string filename = 1;
+   */
+  filename: string;
+
+  /**
+   * @generated from field: string title = 2;
+   */
+  title: string;
+
+  /**
+   * Size of the whole file in bytes
+   *
+   * @generated from field: int64 size = 3;
+   */
+  size: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.CreateUploadRequest.
+ * Use `create(CreateUploadRequestSchema)` to create a new message.
+ */
+export const CreateUploadRequestSchema: GenMessage<CreateUploadRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 40);
+
+/**
+ * @generated from message fileupload.v1.UploadSession
+ */
+export type UploadSession = Message<"fileupload.v1.UploadSession"> & {
+  /**
+   * Identifies the session in UploadChunk, GetUploadSession and the
+   * Upload-Session header
+   *
+   * @generated from field: string upload_id = 1;
+   */
+  uploadId: string;
+
+  /**
+   * Path of the same session on the tus endpoint, relative to the server
+   *
+   * @generated from field: string upload_url = 2;
+   */
+  uploadUrl: string;
+
+  /**
+   * Sanitized filename
+   *
+   * @generated from field: string filename = 3;
+   */
+  filename: string;
+
+  /**
+   * @generated from field: int64 size = 4;
+   */
+  size: bigint;
+
+  /**
+   * Bytes the server holds, where the next chunk starts
+   *
+   * @generated from field: int64 offset = 5;
+   */
+  offset: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.UploadSession.
+ * Use `create(UploadSessionSchema)` to create a new message.
+ */
+export const UploadSessionSchema: GenMessage<UploadSession> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 41);
+
+/**
+ * @generated from message fileupload.v1.UploadChunkRequest
+ */
+export type UploadChunkRequest = Message<"fileupload.v1.UploadChunkRequest"> & {
+  /**
+   * @generated from field: string upload_id = 1;
+   */
+  uploadId: string;
+
+  /**
+   * Must be the session's current offset
+   *
+   * @generated from field: int64 offset = 2;
+   */
+  offset: bigint;
+
+  /**
+   * @generated from field: bytes data = 3;
+   */
+  data: Uint8Array;
+};
+
+/**
+ * Describes the message fileupload.v1.UploadChunkRequest.
+ * Use `create(UploadChunkRequestSchema)` to create a new message.
+ */
+export const UploadChunkRequestSchema: GenMessage<UploadChunkRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 42);
+
+/**
+ * @generated from message fileupload.v1.UploadChunkResponse
+ */
+export type UploadChunkResponse = Message<"fileupload.v1.UploadChunkResponse"> & {
+  /**
+   * Bytes the server holds after this chunk
+   *
+   * @generated from field: int64 offset = 1;
+   */
+  offset: bigint;
+
+  /**
+   * Set once the last chunk stored the file
+   *
+   * @generated from field: fileupload.v1.UploadResponse stored = 2;
+   */
+  stored?: UploadResponse;
+};
+
+/**
+ * Describes the message fileupload.v1.UploadChunkResponse.
+ * Use `create(UploadChunkResponseSchema)` to create a new message.
+ */
+export const UploadChunkResponseSchema: GenMessage<UploadChunkResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 43);
+
+/**
+ * @generated from message fileupload.v1.GetUploadSessionRequest
+ */
+export type GetUploadSessionRequest = Message<"fileupload.v1.GetUploadSessionRequest"> & {
+  /**
+   * @generated from field: string upload_id = 1;
+   */
+  uploadId: string;
+};
+
+/**
+ * Describes the message fileupload.v1.GetUploadSessionRequest.
+ * Use `create(GetUploadSessionRequestSchema)` to create a new message.
+ */
+export const GetUploadSessionRequestSchema: GenMessage<GetUploadSessionRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 44);
+
+/**
+ * @generated from message fileupload.v1.GetStatsRequest
+ */
+export type GetStatsRequest = Message<"fileupload.v1.GetStatsRequest"> & {
+};
+
+/**
+ * Describes the message fileupload.v1.GetStatsRequest.
+ * Use `create(GetStatsRequestSchema)` to create a new message.
+ */
+export const GetStatsRequestSchema: GenMessage<GetStatsRequest> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 45);
+
+/**
+ * @generated from message fileupload.v1.GetStatsResponse
+ */
+export type GetStatsResponse = Message<"fileupload.v1.GetStatsResponse"> & {
+  /**
+   * @generated from field: int64 total_files = 1;
+   */
+  totalFiles: bigint;
+
+  /**
+   * @generated from field: int64 total_bytes = 2;
+   */
+  totalBytes: bigint;
+
+  /**
+   * total_bytes divided by total_files, 0 without files
+   *
+   * @generated from field: int64 average_size = 3;
+   */
+  averageSize: bigint;
+
+  /**
+   * Totals per directory, sorted by directory
+   *
+   * @generated from field: repeated fileupload.v1.DirectoryStats directories = 4;
+   */
+  directories: DirectoryStats[];
+
+  /**
+   * The largest stored files, largest first
+   *
+   * @generated from field: repeated fileupload.v1.FileInfo largest_files = 5;
+   */
+  largestFiles: FileInfo[];
+
+  /**
+   * When the totals were computed, in seconds since the Unix epoch
+   *
+   * @generated from field: int64 computed_unix = 6;
+   */
+  computedUnix: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.GetStatsResponse.
+ * Use `create(GetStatsResponseSchema)` to create a new message.
+ */
+export const GetStatsResponseSchema: GenMessage<GetStatsResponse> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 46);
+
+/**
+ * Totals of the files whose storage path starts with directory: the namespace
+ * with a storage key starting with {namespace}, the route directory otherwise.
+ * Empty for the files stored in the upload directory itself.
+ *
+ * @generated from message fileupload.v1.DirectoryStats
+ */
+export type DirectoryStats = Message<"fileupload.v1.DirectoryStats"> & {
+  /**
+   * @generated from field: string directory = 1;
+   */
+  directory: string;
+
+  /**
+   * @generated from field: int64 files = 2;
+   */
+  files: bigint;
+
+  /**
+   * @generated from field: int64 bytes = 3;
+   */
+  bytes: bigint;
+};
+
+/**
+ * Describes the message fileupload.v1.DirectoryStats.
+ * Use `create(DirectoryStatsSchema)` to create a new message.
+ */
+export const DirectoryStatsSchema: GenMessage<DirectoryStats> = /*@__PURE__*/
+  messageDesc(file_fileupload_v1_fileupload, 47);
+
+/**
+ * @generated from enum fileupload.v1.ChunkStatus
+ */
+export enum ChunkStatus {
+  /**
+   * @generated from enum value: CHUNK_STATUS_UNSPECIFIED = 0;
+   */
+  UNSPECIFIED = 0,
+
+  /**
+   * Every chunk up to chunk_index was written
+   *
+   * @generated from enum value: CHUNK_STATUS_WRITTEN = 1;
+   */
+  WRITTEN = 1,
+
+  /**
+   * The chunk at chunk_index failed its CRC and was not written; the client
+   * sends it again, followed by everything it sent after it
+   *
+   * @generated from enum value: CHUNK_STATUS_CORRUPT = 2;
+   */
+  CORRUPT = 2,
+}
+
+/**
+ * Describes the enum fileupload.v1.ChunkStatus.
+ */
+export const ChunkStatusSchema: GenEnum<ChunkStatus> = /*@__PURE__*/
+  enumDesc(file_fileupload_v1_fileupload, 0);
+
+/**
+ * @generated from enum fileupload.v1.HashStatus
+ */
+export enum HashStatus {
+  /**
+   * @generated from enum value: HASH_STATUS_UNSPECIFIED = 0;
+   */
+  UNSPECIFIED = 0,
+
+  /**
+   * The client's hash matches the content
+   *
+   * @generated from enum value: HASH_STATUS_VERIFIED = 1;
+   */
+  VERIFIED = 1,
+
+  /**
+   * The client's hash differs from the content
+   *
+   * @generated from enum value: HASH_STATUS_MISMATCH = 2;
+   */
+  MISMATCH = 2,
+
+  /**
+   * The client sent no hash, nothing was verified
+   *
+   * @generated from enum value: HASH_STATUS_NOT_PROVIDED = 3;
+   */
+  NOT_PROVIDED = 3,
+}
+
+/**
+ * Describes the enum fileupload.v1.HashStatus.
+ */
+export const HashStatusSchema: GenEnum<HashStatus> = /*@__PURE__*/
+  enumDesc(file_fileupload_v1_fileupload, 1);
+
+/**
+ * @generated from enum fileupload.v1.ArchiveFormat
+ */
+export enum ArchiveFormat {
+  /**
+   * Taken from the filename: zip for .zip, tar otherwise
+   *
+   * @generated from enum value: ARCHIVE_FORMAT_UNSPECIFIED = 0;
+   */
+  UNSPECIFIED = 0,
+
+  /**
+   * @generated from enum value: ARCHIVE_FORMAT_TAR = 1;
+   */
+  TAR = 1,
+
+  /**
+   * @generated from enum value: ARCHIVE_FORMAT_ZIP = 2;
+   */
+  ZIP = 2,
+}
+
+/**
+ * Describes the enum fileupload.v1.ArchiveFormat.
+ */
+export const ArchiveFormatSchema: GenEnum<ArchiveFormat> = /*@__PURE__*/
+  enumDesc(file_fileupload_v1_fileupload, 2);
+
+/**
+ * @generated from enum fileupload.v1.ScrubStatus
+ */
+export enum ScrubStatus {
+  /**
+   * @generated from enum value: SCRUB_STATUS_UNSPECIFIED = 0;
+   */
+  UNSPECIFIED = 0,
+
+  /**
+   * The content still has its indexed hash
+   *
+   * @generated from enum value: SCRUB_STATUS_OK = 1;
+   */
+  OK = 1,
+
+  /**
+   * The content has another hash: bit rot or a change outside the server
+   *
+   * @generated from enum value: SCRUB_STATUS_CORRUPT = 2;
+   */
+  CORRUPT = 2,
+
+  /**
+   * The indexed file is gone from the upload directory
+   *
+   * @generated from enum value: SCRUB_STATUS_MISSING = 3;
+   */
+  MISSING = 3,
+
+  /**
+   * The file could not be read, see error
+   *
+   * @generated from enum value: SCRUB_STATUS_UNREADABLE = 4;
+   */
+  UNREADABLE = 4,
+}
+
+/**
+ * Describes the enum fileupload.v1.ScrubStatus.
+ */
+export const ScrubStatusSchema: GenEnum<ScrubStatus> = /*@__PURE__*/
+  enumDesc(file_fileupload_v1_fileupload, 3);
+
+/**
+ * @generated from enum fileupload.v1.EventType
+ */
+export enum EventType {
+  /**
+   * @generated from enum value: EVENT_TYPE_UNSPECIFIED = 0;
+   */
+  UNSPECIFIED = 0,
+
+  /**
+   * A file was stored
+   *
+   * @generated from enum value: EVENT_TYPE_UPLOAD = 1;
+   */
+  UPLOAD = 1,
+
+  /**
+   * An expired file was deleted
+   *
+   * @generated from enum value: EVENT_TYPE_DELETE = 2;
+   */
+  DELETE = 2,
+
+  /**
+   * An upload failed
+   *
+   * @generated from enum value: EVENT_TYPE_ERROR = 3;
+   */
+  ERROR = 3,
+}
+
+/**
+ * Describes the enum fileupload.v1.EventType.
+ */
+export const EventTypeSchema: GenEnum<EventType> = /*@__PURE__*/
+  enumDesc(file_fileupload_v1_fileupload, 4);
+
+/**
+ * @generated from service fileupload.v1.FileUploadService
+ */
+export const FileUploadService: GenService<{
+  /**
+   * Streaming upload for native clients (Go, etc.)
+   * Protocol: 1) metadata, 2) chunks..., 3) finish_commit
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.Upload
+   */
+  upload: {
+    methodKind: "client_streaming";
+    input: typeof UploadRequestSchema;
+    output: typeof UploadResponseSchema;
+  },
+  /**
+   * Same protocol as Upload, but the server acknowledges written chunks with
+   * the offset it holds, so the client can bound the data in flight. Needs
+   * HTTP/2 end to end.
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.UploadBidi
+   */
+  uploadBidi: {
+    methodKind: "bidi_streaming";
+    input: typeof UploadRequestSchema;
+    output: typeof UploadBidiResponseSchema;
+  },
+  /**
+   * Unary upload for browser clients (Fetch API doesn't support client streaming)
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.UploadFile
+   */
+  uploadFile: {
+    methodKind: "unary";
+    input: typeof UploadFileRequestSchema;
+    output: typeof UploadResponseSchema;
+  },
+  /**
+   * Server version and capabilities (cacheable, may be called with HTTP GET)
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.GetServerInfo
+   */
+  getServerInfo: {
+    methodKind: "unary";
+    input: typeof GetServerInfoRequestSchema;
+    output: typeof GetServerInfoResponseSchema;
+  },
+  /**
+   * Size, hash and modification time of a stored file (cacheable, may be called with HTTP GET)
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.GetFileMetadata
+   */
+  getFileMetadata: {
+    methodKind: "unary";
+    input: typeof GetFileMetadataRequestSchema;
+    output: typeof GetFileMetadataResponseSchema;
+  },
+  /**
+   * Whether a file is stored under a name, with its size, hash and
+   * modification time. Unlike GetFileMetadata a missing file is not an error
+   * and the hash comes from the server's index instead of reading the file.
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.StatFile
+   */
+  statFile: {
+    methodKind: "unary";
+    input: typeof StatFileRequestSchema;
+    output: typeof StatFileResponseSchema;
+  },
+  /**
+   * Streams one FileInfo per stored file as the upload directories are read,
+   * in no particular order, so listing millions of files never builds them
+   * all into one message
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.ListFilesStream
+   */
+  listFilesStream: {
+    methodKind: "server_streaming";
+    input: typeof ListFilesRequestSchema;
+    output: typeof FileInfoSchema;
+  },
+  /**
+   * Streams a stored file back in chunks, from offset when set
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.Download
+   */
+  download: {
+    methodKind: "server_streaming";
+    input: typeof DownloadRequestSchema;
+    output: typeof DownloadResponseSchema;
+  },
+  /**
+   * How much of a chunked UploadFile upload the server holds, so an
+   * interrupted client can resume from there
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.GetUploadStatus
+   */
+  getUploadStatus: {
+    methodKind: "unary";
+    input: typeof GetUploadStatusRequestSchema;
+    output: typeof GetUploadStatusResponseSchema;
+  },
+  /**
+   * JPEG thumbnail of a stored image, when the server generates thumbnails
+   * (cacheable, may be called with HTTP GET)
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.GetThumbnail
+   */
+  getThumbnail: {
+    methodKind: "unary";
+    input: typeof GetThumbnailRequestSchema;
+    output: typeof GetThumbnailResponseSchema;
+  },
+  /**
+   * Renames a stored file without re-uploading it
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.RenameFile
+   */
+  renameFile: {
+    methodKind: "unary";
+    input: typeof RenameFileRequestSchema;
+    output: typeof RenameFileResponseSchema;
+  },
+  /**
+   * Stores a copy of a stored file under a new name without re-uploading it
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.CopyFile
+   */
+  copyFile: {
+    methodKind: "unary";
+    input: typeof CopyFileRequestSchema;
+    output: typeof UploadResponseSchema;
+  },
+  /**
+   * Starts an archive bundling several files into one stored tar or zip file:
+   * AddArchiveEntry adds each file and CloseArchive stores the archive
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.BeginArchive
+   */
+  beginArchive: {
+    methodKind: "unary";
+    input: typeof BeginArchiveRequestSchema;
+    output: typeof BeginArchiveResponseSchema;
+  },
+  /**
+   * Adds one file to an archive started with BeginArchive
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.AddArchiveEntry
+   */
+  addArchiveEntry: {
+    methodKind: "unary";
+    input: typeof AddArchiveEntryRequestSchema;
+    output: typeof UploadResponseSchema;
+  },
+  /**
+   * Writes the archive and stores it atomically under its filename
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.CloseArchive
+   */
+  closeArchive: {
+    methodKind: "unary";
+    input: typeof CloseArchiveRequestSchema;
+    output: typeof UploadResponseSchema;
+  },
+  /**
+   * When a stored file uploaded with a TTL or expiry time will be deleted
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.GetExpiry
+   */
+  getExpiry: {
+    methodKind: "unary";
+    input: typeof GetExpiryRequestSchema;
+    output: typeof ExpiryResponseSchema;
+  },
+  /**
+   * Postpones the deletion of a stored file uploaded with a TTL or expiry time
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.ExtendExpiry
+   */
+  extendExpiry: {
+    methodKind: "unary";
+    input: typeof ExtendExpiryRequestSchema;
+    output: typeof ExpiryResponseSchema;
+  },
+  /**
+   * Re-hashes every stored file in the hash index and reports those whose
+   * content no longer matches it, streaming progress for each file
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.Scrub
+   */
+  scrub: {
+    methodKind: "server_streaming";
+    input: typeof ScrubRequestSchema;
+    output: typeof ScrubResponseSchema;
+  },
+  /**
+   * Streams upload, deletion and failed upload events as they happen, until
+   * the client cancels or the server stops
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.WatchEvents
+   */
+  watchEvents: {
+    methodKind: "server_streaming";
+    input: typeof WatchEventsRequestSchema;
+    output: typeof ServerEventSchema;
+  },
+  /**
+   * Starts a resumable upload session of a known size; UploadChunk sends its
+   * content. Every call of a session should carry the Upload-Session header
+   * so a load balancer can route them all to the same server.
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.CreateUpload
+   */
+  createUpload: {
+    methodKind: "unary";
+    input: typeof CreateUploadRequestSchema;
+    output: typeof UploadSessionSchema;
+  },
+  /**
+   * Appends a chunk to a session; the last one stores the file
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.UploadChunk
+   */
+  uploadChunk: {
+    methodKind: "unary";
+    input: typeof UploadChunkRequestSchema;
+    output: typeof UploadChunkResponseSchema;
+  },
+  /**
+   * How much of a session the server holds, to resume it
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.GetUploadSession
+   */
+  getUploadSession: {
+    methodKind: "unary";
+    input: typeof GetUploadSessionRequestSchema;
+    output: typeof UploadSessionSchema;
+  },
+  /**
+   * Totals of the stored files the caller can list, overall and per
+   * directory, with the largest ones. Computed at most every few seconds, so
+   * the latest uploads may be missing (cacheable, may be called with HTTP GET)
+   *
+   * @generated from rpc fileupload.v1.FileUploadService.GetStats
+   */
+  getStats: {
+    methodKind: "unary";
+    input: typeof GetStatsRequestSchema;
+    output: typeof GetStatsResponseSchema;
   },
 }> = /*@__PURE__*/
   serviceDesc(file_fileupload_v1_fileupload, 0);
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/pkg/uploadserver"
+)
+
+// openAuditSink turns the -audit-log flag into a sink: "" disables auditing,
+// "-" writes to stdout, "syslog" to the local syslog daemon and anything else
+// appends to that file
+func openAuditSink(target string) (uploadserver.AuditSink, error) {
+	switch target {
+	case "":
+		return nil, nil
+	case "-":
+		return uploadserver.NewJSONLAuditSink(os.Stdout), nil
+	case "syslog":
+		w, err := openSyslog()
+		if err != nil {
+			return nil, err
+		}
+		return uploadserver.NewJSONLAuditSink(w), nil
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return uploadserver.NewJSONLAuditSink(f), nil
+}
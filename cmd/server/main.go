@@ -2,179 +2,344 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"errors"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	"connectrpc.com/connect"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/otel/trace"
 
-	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
-	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/pkg/uploadserver"
 )
 
 const uploadDir = "uploads"
 
-// sanitizeFilename prevents path traversal attacks
-func sanitizeFilename(filename string) string {
-	base := filepath.Base(filename)
-	base = strings.ReplaceAll(base, "/", "_")
-	base = strings.ReplaceAll(base, "\\", "_")
-	if base == "" || base == "." || base == ".." {
-		base = "unnamed_file"
+func main() {
+	eventsPath := flag.String("events-log", "events.jsonl", "append-only JSONL log of uploads (empty disables it)")
+	auditLog := flag.String("audit-log", "", "JSONL audit record of every RPC: a file, - for stdout or syslog (empty disables it)")
+	natsURL := flag.String("nats-url", "", "publish an event for every stored upload to these comma-separated NATS server URLs, needs a build with -tags nats (empty disables it)")
+	natsSubject := flag.String("nats-subject", "uploads.completed", "NATS subject of the -nats-url upload events")
+	publishRequired := flag.Bool("publish-required", false, "fail uploads whose -nats-url event could not be published (the file stays stored)")
+	eventsMaxBytes := flag.Int64("events-max-bytes", 10<<20, "rotate the events log once it exceeds this many bytes")
+	otelEndpoint := flag.String("otel-endpoint", "", "export a trace span of every RPC and upload to this OTLP/HTTP collector URL, e.g. http://localhost:4318 (empty disables tracing)")
+	probeInterval := flag.Duration("storage-probe-interval", 10*time.Second, "how often to verify the upload directory is writable")
+	diskHighWater := flag.Float64("disk-high-water", 0, "refuse uploads once this percentage of the upload filesystem is used, checked every -storage-probe-interval (0 disables)")
+	diskLowWater := flag.Float64("disk-low-water", 0, "accept uploads again once disk usage drops below this percentage (0 is -disk-high-water minus 5)")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	readHeaderTimeout := flag.Duration("read-header-timeout", 10*time.Second, "time allowed to read request headers")
+	idleTimeout := flag.Duration("idle-timeout", 2*time.Minute, "how long idle keep-alive connections stay open")
+	metricsAddr := flag.String("metrics-addr", "", "serve the expvar metrics on GET /debug/vars of this separate address, such as 127.0.0.1:9090 (empty serves none)")
+	corsMaxAge := flag.Duration("cors-max-age", 10*time.Minute, "how long browsers may cache a CORS preflight answer (0 sends no Access-Control-Max-Age, negative disables caching)")
+	pingInterval := flag.Duration("h2-ping-interval", 0, "send an HTTP/2 PING on connections idle for this long, so long streams survive idle proxies (0 disables it)")
+	pingTimeout := flag.Duration("h2-ping-timeout", 15*time.Second, "close a connection whose -h2-ping-interval PING is not answered within this")
+	maxHeaderBytes := flag.Int("max-header-bytes", 64<<10, "maximum size of request headers")
+	maxMessageBytes := flag.Int("max-message-bytes", 64<<20, "maximum size of a single RPC message (a chunk or a unary upload)")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate file; serves HTTPS together with -tls-key")
+	tlsKey := flag.String("tls-key", "", "PEM private key file for -tls-cert")
+	clientCA := flag.String("client-ca", "", "PEM CA file; when set clients must present a certificate it signed (mTLS)")
+	allowedClients := flag.String("allowed-clients", "", "comma-separated client certificate names (CN or SAN) allowed to upload; requires -client-ca")
+	maxPerIdentity := flag.Int("max-uploads-per-identity", 0, "concurrent uploads allowed per client certificate identity (0 means unlimited)")
+	maxPerNamespace := flag.Int("max-uploads-per-namespace", 0, "concurrent uploads allowed per client certificate organization (0 means unlimited)")
+	maxOpenFiles := flag.Int("max-open-files", 0, "maximum storage files held open at once by uploads and downloads (0 means unlimited)")
+	openFilesWait := flag.Duration("open-files-wait", 5*time.Second, "how long a request waits for one of -max-open-files before failing")
+	maxBufferMemory := flag.Int64("max-buffer-memory", 0, "bound in bytes on memory of received chunks waiting to be written, across all streaming uploads (0 means unlimited)")
+	maxUnary := flag.Int64("max-unary-upload-bytes", 0, "largest UploadFile request data accepted in one call, larger ones must stream (0 means unlimited)")
+	maxTitleLength := flag.Int("max-title-length", 1024, "maximum length in bytes of an upload title (0 means unlimited)")
+	maxFileSize := flag.Int64("max-file-size", 0, "maximum size in bytes of one stored file (0 means unlimited)")
+	slowChunk := flag.Duration("slow-chunk-threshold", 5*time.Second, "log a warning when a streaming upload waits longer than this for a chunk (0 disables)")
+	indexPath := flag.String("index-file", "hash-index.json", "file persisting the content hash index")
+	rebuildIndex := flag.Bool("rebuild-index", false, "rehash every stored file instead of loading the saved index")
+	rejectDuplicates := flag.Bool("reject-duplicate-content", false, "reject uploads whose content is already stored under another name")
+	dedupCopies := flag.Bool("dedup-copies", false, "store CopyFile copies as hard links sharing the original's content")
+	redactFilenames := flag.Bool("redact-filenames", false, "log a short digest instead of filenames and titles")
+	redactHashes := flag.Bool("redact-hashes", false, "log content hashes truncated to 8 hex characters")
+	compressMinBytes := flag.Int("compress-min-bytes", 1024, "only gzip responses larger than this when clients accept it")
+	compressDownloads := flag.Bool("compress-downloads", false, "gzip GET /files/{name} responses of text, JSON, XML and other compressible types for clients that accept it")
+	partialMaxAge := flag.Duration("partial-max-age", 24*time.Hour, "delete resumable uploads not written to for this long (0 keeps them)")
+	maxIncomplete := flag.Int("max-incomplete-uploads", 0, "refuse new resumable uploads while this many are incomplete (0 means unlimited)")
+	externalURL := flag.String("external-url", "", "base URL where stored files are published (CDN, bucket website); downloads redirect there instead of streaming")
+	extractDir := flag.String("extract-dir", "", "let uploads ask to unpack .zip, .tar, .tar.gz and .tgz files into a directory of this one named after the archive (empty disables it)")
+	extractMaxBytes := flag.Int64("extract-max-bytes", 1<<30, "most bytes one -extract-dir archive may unpack to (0 means unlimited)")
+	extractMaxEntries := flag.Int("extract-max-entries", 10000, "most files and directories one -extract-dir archive may contain (0 means unlimited)")
+	readOnly := flag.Bool("read-only", false, "start in read-only mode, refusing uploads, renames and copies; SIGUSR1 enters it and SIGUSR2 leaves it")
+	selfTest := flag.Bool("self-test", false, "store, read back and delete a test file at startup and refuse to start if that fails")
+	fsync := flag.String("fsync", "none", "when uploads are flushed to disk before success is reported: none, on-commit or per-chunk")
+	skipHash := flag.Bool("skip-hash-verification", false, "store Upload and UploadBidi streams without hashing them, for trusted networks (responses report hash_status not_provided)")
+	hashWorkers := flag.Int("hash-workers", 0, "hash streamed uploads on up to this many goroutines, concurrently with disk writes (0 hashes on the write path)")
+	thumbnails := flag.Bool("thumbnails", false, "store a JPEG thumbnail of every uploaded PNG, JPEG or GIF image, served by GetThumbnail")
+	thumbnailSize := flag.Int("thumbnail-size", 256, "longest side of a -thumbnails thumbnail in pixels")
+	thumbnailMaxSource := flag.Int("thumbnail-max-source", 4096, "skip -thumbnails for images wider or taller than this many pixels (0 is unlimited)")
+	retention := flag.Duration("retention", 0, "write-once mode: stored files cannot be overwritten, renamed or deleted for this long after they are stored (0 disables)")
+	randomNames := flag.Bool("random-names", false, "store every upload under a random UUID name keeping its extension, ignoring the client's filename")
+	nameTransform := flag.String("name-transform", "", "rewrite stored filenames with comma-separated built-in transformers applied in order: slugify, timestamp")
+	routes := flag.String("route", "", "comma-separated match=subdir rules storing files by extension or content type, e.g. image/*=images,.pdf=docs")
+	storageKey := flag.String("storage-key", "", "store files at a path from this template instead of their -route directory, e.g. {namespace}/{yyyy}/{mm}/{hash[:2]}/{filename}")
+	extensionPolicies := flag.String("extension-policies", "", "JSON file of the extensions each client certificate organization may store, {\"acme\": {\"allow\": [\".pdf\"]}, \"*\": {\"deny\": [\".exe\"]}} (empty accepts every file)")
+	flag.Parse()
+
+	var allowed []string
+	if *allowedClients != "" {
+		if *clientCA == "" {
+			log.Fatalf("-allowed-clients requires -client-ca so client certificates are verified")
+		}
+		allowed = strings.Split(*allowedClients, ",")
 	}
-	return base
-}
-
-type Server struct {
-	fileuploadv1connect.UnimplementedFileUploadServiceHandler
-}
-
-// Upload handles streaming uploads with the Commit message pattern:
-// 1. metadata -> 2. chunks... -> 3. finish_commit (hash verification)
-func (s *Server) Upload(
-	ctx context.Context, stream *connect.ClientStream[fileuploadv1.UploadRequest]) (*fileuploadv1.UploadResponse, error) {
-
-	var (
-		file      *os.File
-		filename  string
-		totalSize int64
-		hasher    = sha256.New()
-	)
-
-	for stream.Receive() {
-		// Check context for cancellation
-		select {
-		case <-ctx.Done():
-			if file != nil {
-				file.Close()
-				os.Remove(filepath.Join(uploadDir, filename))
-			}
-			return nil, ctx.Err()
-		default:
+	routing, err := parseRoutes(*routes)
+	if err != nil {
+		log.Fatalf("Invalid -route: %v", err)
+	}
+	policies, err := readExtensionPolicies(*extensionPolicies)
+	if err != nil {
+		log.Fatalf("Invalid -extension-policies: %v", err)
+	}
+	syncPolicy, err := uploadserver.ParseSyncPolicy(*fsync)
+	if err != nil {
+		log.Fatalf("Invalid -fsync: %v", err)
+	}
+	nameTransformer, err := uploadserver.ParseNameTransformer(*nameTransform)
+	if err != nil {
+		log.Fatalf("Invalid -name-transform: %v", err)
+	}
+	if nameTransformer != nil && *randomNames {
+		log.Fatalf("-name-transform cannot be combined with -random-names")
+	}
+	audit, err := openAuditSink(*auditLog)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+	var publisher uploadserver.Publisher
+	if *natsURL != "" {
+		if publisher, err = uploadserver.NewNATSPublisher(*natsURL, *natsSubject); err != nil {
+			log.Fatalf("Invalid -nats-url: %v", err)
 		}
+	}
 
-		req := stream.Msg()
-
-		switch payload := req.Payload.(type) {
-
-		case *fileuploadv1.UploadRequest_Metadata:
-			if file != nil {
-				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("metadata already received"))
-			}
-
-			filename = sanitizeFilename(payload.Metadata.Filename)
-			safePath := filepath.Join(uploadDir, filename)
-			log.Printf("Upload started: %s (title: %s)", filename, payload.Metadata.Title)
-
-			var err error
-			file, err = os.Create(safePath)
-			if err != nil {
-				return nil, connect.NewError(connect.CodeInternal, err)
-			}
-			defer file.Close()
-
-		case *fileuploadv1.UploadRequest_Chunk:
-			if file == nil {
-				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("metadata must be sent first"))
-			}
-
-			// Write to file AND update hash
-			if _, err := file.Write(payload.Chunk); err != nil {
-				return nil, connect.NewError(connect.CodeInternal, err)
-			}
-			hasher.Write(payload.Chunk)
-			totalSize += int64(len(payload.Chunk))
-
-		case *fileuploadv1.UploadRequest_FinishCommit:
-			if file == nil {
-				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("no file data received"))
-			}
-
-			// Final hash verification
-			serverHash := hex.EncodeToString(hasher.Sum(nil))
-			clientHash := payload.FinishCommit
-
-			log.Printf("Upload complete: %s (%d bytes)", filename, totalSize)
-			log.Printf("Hash verification - Server: %s, Client: %s", serverHash, clientHash)
-
-			if serverHash != clientHash {
-				log.Printf("HASH MISMATCH! Deleting corrupted file")
-				file.Close()
-				os.Remove(filepath.Join(uploadDir, filename))
-				return nil, connect.NewError(connect.CodeDataLoss, errors.New("checksum mismatch"))
-			}
-
-			return &fileuploadv1.UploadResponse{
-				Message: "Upload successful and verified",
-				Size:    totalSize,
-				HashOk:  true,
-			}, nil
-
-		default:
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("unknown message type"))
+	var tracerProvider trace.TracerProvider // nil disables tracing
+	if *otelEndpoint != "" {
+		provider, err := newTracerProvider(*otelEndpoint)
+		if err != nil {
+			log.Fatalf("Invalid -otel-endpoint: %v", err)
 		}
+		defer provider.Shutdown(context.Background())
+		tracerProvider = provider
 	}
 
-	if err := stream.Err(); err != nil {
-		return nil, err
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	var readOnlyMode atomic.Bool
+	readOnlyMode.Store(*readOnly)
+	watchReadOnly(&readOnlyMode)
+	if *readOnly {
+		log.Printf("Read-only mode: true")
 	}
 
-	return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("stream closed without commit"))
-}
+	thumbSize := 0
+	if *thumbnails {
+		thumbSize = *thumbnailSize
+	}
 
-// UploadFile handles unary uploads from browser clients
-func (s *Server) UploadFile(
-	ctx context.Context, req *fileuploadv1.UploadFileRequest) (*fileuploadv1.UploadResponse, error) {
+	handler, err := uploadserver.New(uploadserver.Config{
+		Dir:                    uploadDir,
+		StorageProbeInterval:   *probeInterval,
+		DiskHighWater:          *diskHighWater,
+		DiskLowWater:           *diskLowWater,
+		SelfTest:               *selfTest,
+		IndexFile:              *indexPath,
+		RebuildIndex:           *rebuildIndex,
+		RejectDuplicateContent: *rejectDuplicates,
+		DedupCopies:            *dedupCopies,
+		EventsLog:              *eventsPath,
+		EventsMaxBytes:         *eventsMaxBytes,
+		AuditSink:              audit,
+		Publisher:              publisher,
+		PublishRequired:        *publishRequired,
+		MaxMessageBytes:        *maxMessageBytes,
+		MaxFileSize:            *maxFileSize,
+		MaxUnaryUploadBytes:    *maxUnary,
+		MaxTitleLength:         *maxTitleLength,
+		MaxBufferMemory:        *maxBufferMemory,
+		MaxOpenFiles:           *maxOpenFiles,
+		OpenFilesWait:          *openFilesWait,
+		PartialMaxAge:          *partialMaxAge,
+		MaxIncompleteSessions:  *maxIncomplete,
+		RedactFilenames:        *redactFilenames,
+		RedactHashes:           *redactHashes,
+		CompressMinBytes:       *compressMinBytes,
+		CompressDownloads:      *compressDownloads,
+		SlowChunkThreshold:     *slowChunk,
+		Sync:                   syncPolicy,
+		HashWorkers:            *hashWorkers,
+		SkipHashVerification:   *skipHash,
+		RandomNames:            *randomNames,
+		Retention:              *retention,
+		NameTransformer:        nameTransformer,
+		ThumbnailSize:          thumbSize,
+		ThumbnailMaxSource:     *thumbnailMaxSource,
+		Routes:                 routing,
+		StorageKey:             *storageKey,
+		ExternalURL:            externalLocation(*externalURL),
+		ReadOnly:               readOnlyMode.Load,
+		ExtractDir:             *extractDir,
+		ExtractMaxBytes:        *extractMaxBytes,
+		ExtractMaxEntries:      *extractMaxEntries,
+		AllowedClients:         allowed,
+		MaxUploadsPerIdentity:  *maxPerIdentity,
+		MaxUploadsPerNamespace: *maxPerNamespace,
+		ExtensionPolicies:      policies,
+		TracerProvider:         tracerProvider,
+		Context:                ctx,
+	})
+	if err != nil {
+		log.Fatalf("Failed to start upload service: %v", err)
+	}
 
-	filename := sanitizeFilename(req.Filename)
-	safePath := filepath.Join(uploadDir, filename)
+	httpServer := newHTTPServer(*addr, withCORS(handler, *corsMaxAge), httpLimits{
+		readHeaderTimeout: *readHeaderTimeout,
+		idleTimeout:       *idleTimeout,
+		maxHeaderBytes:    *maxHeaderBytes,
+		pingInterval:      *pingInterval,
+		pingTimeout:       *pingTimeout,
+	})
 
-	log.Printf("UploadFile: %s (title: %s)", filename, req.Title)
+	if *metricsAddr != "" {
+		go serveMetrics(*metricsAddr, *readHeaderTimeout)
+	}
 
-	// Calculate and verify hash
-	hasher := sha256.New()
-	hasher.Write(req.Data)
-	serverHash := hex.EncodeToString(hasher.Sum(nil))
-	hashOk := (serverHash == req.Sha256)
+	if *tlsCert == "" {
+		if *clientCA != "" {
+			log.Fatalf("-client-ca requires -tls-cert and -tls-key")
+		}
+		log.Printf("Server on %s", *addr)
+		err = httpServer.ListenAndServe()
+	} else {
+		if httpServer.TLSConfig, err = serverTLSConfig(*clientCA); err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		log.Printf("Server on %s (TLS, client certificates required: %v)", *addr, *clientCA != "")
+		err = httpServer.ListenAndServeTLS(*tlsCert, *tlsKey)
+	}
+	if err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
 
-	log.Printf("Hash verification - Server: %s, Client: %s, OK: %v", serverHash, req.Sha256, hashOk)
+// httpLimits bound how long and how much a client may make the server read
+// before a request reaches the handler
+type httpLimits struct {
+	readHeaderTimeout time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	pingInterval      time.Duration // HTTP/2 PING after this long without frames, 0 sends none
+	pingTimeout       time.Duration
+}
 
-	// Write file
-	if err := os.WriteFile(safePath, req.Data, 0644); err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+// withCORS lets browsers of any origin call handler, caching their preflight
+// answers for maxAge
+func withCORS(handler http.Handler, maxAge time.Duration) http.Handler {
+	seconds := int(maxAge.Seconds())
+	if maxAge < 0 {
+		// rs/cors sends 0 for a negative value, disabling the cache
+		seconds = -1
 	}
-
-	return &fileuploadv1.UploadResponse{
-		Message: "ok",
-		Size:    int64(len(req.Data)),
-		HashOk:  hashOk,
-	}, nil
+	return cors.New(cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"*"},
+		AllowCredentials: false,
+		ExposedHeaders:   uploadserver.ExposedHeaders,
+		MaxAge:           seconds,
+	}).Handler(handler)
 }
 
-func main() {
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		log.Fatalf("Failed to create upload directory: %v", err)
+// newHTTPServer serves handler on addr within limits, over HTTP/1.1 and
+// HTTP/2. HTTP/2 is negotiated over TLS; plain http accepts it without TLS
+// (h2c) next to HTTP/1.1, so UploadBidi works there too.
+func newHTTPServer(addr string, handler http.Handler, limits httpLimits) *http.Server {
+	// No ReadTimeout/WriteTimeout: streaming uploads legitimately last for a long time,
+	// slow clients are bounded by ReadHeaderTimeout and IdleTimeout instead.
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: limits.readHeaderTimeout,
+		IdleTimeout:       limits.idleTimeout,
+		MaxHeaderBytes:    limits.maxHeaderBytes,
+		HTTP2: &http.HTTP2Config{
+			SendPingTimeout: limits.pingInterval,
+			PingTimeout:     limits.pingTimeout,
+		},
+		Protocols: new(http.Protocols),
 	}
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	return srv
+}
 
+// serveMetrics serves the expvar metrics on their own listener, kept apart
+// from the uploads so they need not be reachable by clients
+func serveMetrics(addr string, readHeaderTimeout time.Duration) {
 	mux := http.NewServeMux()
-	mux.Handle(fileuploadv1connect.NewFileUploadServiceHandler(&Server{}))
+	mux.Handle("GET /debug/vars", uploadserver.MetricsHandler())
+	metricsServer := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+	log.Printf("Metrics on %s/debug/vars", addr)
+	if err := metricsServer.ListenAndServe(); err != nil {
+		log.Fatalf("Metrics server failed: %v", err)
+	}
+}
 
-	corsHandler := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: false,
-		ExposedHeaders:   []string{"Connect-Protocol-Version", "Grpc-Status", "Grpc-Message"},
-	})
+// parseRoutes parses the -route flag: comma-separated match=subdir pairs
+func parseRoutes(s string) ([]uploadserver.Route, error) {
+	var routes []uploadserver.Route
+	for _, rule := range strings.Split(s, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		match, dir, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("rule %q is not match=subdir", rule)
+		}
+		routes = append(routes, uploadserver.Route{Match: match, Dir: dir})
+	}
+	return routes, nil
+}
 
-	log.Println("Server on :8080")
-	if err := http.ListenAndServe(":8080", corsHandler.Handler(mux)); err != nil {
-		log.Fatalf("Server failed: %v", err)
+// readExtensionPolicies returns the extension policies by namespace kept as
+// JSON in the file at path, nil without one
+func readExtensionPolicies(path string) (map[string]uploadserver.ExtensionPolicy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policies map[string]uploadserver.ExtensionPolicy
+	if err := json.Unmarshal(b, &policies); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policies, nil
+}
+
+// externalLocation turns the -external-url flag into Config.ExternalURL, nil
+// when it is empty
+func externalLocation(base string) func(string) (string, error) {
+	if base == "" {
+		return nil
+	}
+	base = strings.TrimSuffix(base, "/")
+	return func(name string) (string, error) {
+		segments := strings.Split(name, "/")
+		for i, seg := range segments {
+			segments[i] = url.PathEscape(seg)
+		}
+		return base + "/" + strings.Join(segments, "/"), nil
 	}
 }
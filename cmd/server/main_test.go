@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/pkg/uploadserver"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// serveTest serves handler with newHTTPServer within limits on a local port
+// and returns its address
+func serveTest(t *testing.T, handler http.Handler, limits httpLimits) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newHTTPServer(ln.Addr().String(), handler, limits)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, r.Proto)
+})
+
+func TestHTTPServerRefusesLargeHeaders(t *testing.T) {
+	addr := serveTest(t, okHandler, httpLimits{maxHeaderBytes: 1 << 10})
+	req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/", nil)
+	req.Header.Set("X-Bomb", strings.Repeat("x", 16<<10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("status %d, want 431", resp.StatusCode)
+	}
+}
+
+func TestHTTPServerDropsSlowHeaders(t *testing.T) {
+	addr := serveTest(t, okHandler, httpLimits{readHeaderTimeout: 100 * time.Millisecond})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// a slowloris client never finishes its headers
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	b, _ := io.ReadAll(conn)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("connection held for %v", elapsed)
+	}
+	if strings.Contains(string(b), "200 OK") {
+		t.Fatalf("unfinished headers were served: %q", b)
+	}
+}
+
+func TestHTTPServerSpeaksH2C(t *testing.T) {
+	addr := serveTest(t, okHandler, httpLimits{})
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	resp, err := (&http.Client{Transport: transport}).Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if b, _ := io.ReadAll(resp.Body); string(b) != "HTTP/2.0" {
+		t.Fatalf("served over %s, want h2c", b)
+	}
+}
+
+// idleProxy forwards connections to target and drops those without traffic
+// either way for idle, like a load balancer or NAT would. It returns its address.
+func idleProxy(t *testing.T, target string, idle time.Duration) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			in, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			out, err := net.Dial("tcp", target)
+			if err != nil {
+				in.Close()
+				continue
+			}
+			var last atomic.Int64
+			last.Store(time.Now().UnixNano())
+			forward := func(dst, src net.Conn) {
+				buf := make([]byte, 32<<10)
+				for {
+					n, err := src.Read(buf)
+					if n > 0 {
+						last.Store(time.Now().UnixNano())
+						dst.Write(buf[:n])
+					}
+					if err != nil {
+						in.Close()
+						out.Close()
+						return
+					}
+				}
+			}
+			go forward(out, in)
+			go forward(in, out)
+			go func() {
+				for range time.Tick(idle / 4) {
+					if time.Since(time.Unix(0, last.Load())) > idle {
+						in.Close()
+						out.Close()
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// gappedUpload posts a body to url whose two halves are sent gap apart and
+// returns what the server answered
+func gappedUpload(client *http.Client, url string, gap time.Duration) (string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, "first ")
+		time.Sleep(gap)
+		io.WriteString(pw, "second")
+		pw.Close()
+	}()
+	resp, err := client.Post(url, "application/octet-stream", pr)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return string(b), err
+}
+
+func TestHTTPServerPingsKeepLongStreamsAlive(t *testing.T) {
+	counter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		fmt.Fprintf(w, "%s %d", r.Proto, n)
+	})
+	upload := func(limits httpLimits) (string, error) {
+		hs := httptest.NewUnstartedServer(counter)
+		hs.Config = newHTTPServer("", counter, limits)
+		hs.EnableHTTP2 = true
+		hs.StartTLS()
+		t.Cleanup(hs.Close)
+		proxy := idleProxy(t, hs.Listener.Addr().String(), 300*time.Millisecond)
+		return gappedUpload(hs.Client(), "https://"+proxy+"/", time.Second)
+	}
+
+	// the proxy drops a silent stream, so the test shows the pings matter
+	if got, err := upload(httpLimits{}); err == nil {
+		t.Fatalf("a stream idle for longer than the proxy allows survived without pings: %q", got)
+	}
+	got, err := upload(httpLimits{pingInterval: 50 * time.Millisecond, pingTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("stream with server pings: %v", err)
+	}
+	if got != "HTTP/2.0 12" {
+		t.Fatalf("server answered %q, want all 12 bytes over HTTP/2", got)
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := parseRoutes(" image/*=images, .pdf=docs ,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes[0].Match != "image/*" || routes[0].Dir != "images" || routes[1].Match != ".pdf" || routes[1].Dir != "docs" {
+		t.Fatalf("parseRoutes = %+v", routes)
+	}
+	if routes, err := parseRoutes(""); err != nil || len(routes) != 0 {
+		t.Fatalf("parseRoutes of an empty flag = %+v, %v", routes, err)
+	}
+	if _, err := parseRoutes(".pdf"); err == nil {
+		t.Fatal("parseRoutes accepted a rule without a directory")
+	}
+}
+
+func TestReadExtensionPolicies(t *testing.T) {
+	if policies, err := readExtensionPolicies(""); policies != nil || err != nil {
+		t.Fatalf("readExtensionPolicies(\"\") = %v, %v; want no policies", policies, err)
+	}
+	path := filepath.Join(t.TempDir(), "policies.json")
+	os.WriteFile(path, []byte(`{"acme": {"allow": [".pdf"]}, "*": {"deny": [".exe", ".bat"]}}`), 0644)
+	policies, err := readExtensionPolicies(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 2 || len(policies["acme"].Allow) != 1 || len(policies["*"].Deny) != 2 {
+		t.Fatalf("policies = %+v", policies)
+	}
+	os.WriteFile(path, []byte(`{"acme": ".pdf"}`), 0644)
+	if _, err := readExtensionPolicies(path); err == nil {
+		t.Fatal("readExtensionPolicies accepted a policy that is not an object")
+	}
+}
+
+func TestExternalLocation(t *testing.T) {
+	if externalLocation("") != nil {
+		t.Fatal("an empty -external-url hands downloads off")
+	}
+	locate := externalLocation("https://cdn.example.com/files/")
+	got, err := locate("docs/annual report#1.pdf")
+	if want := "https://cdn.example.com/files/docs/annual%20report%231.pdf"; err != nil || got != want {
+		t.Fatalf("location = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestOpenAuditSink(t *testing.T) {
+	if sink, err := openAuditSink(""); sink != nil || err != nil {
+		t.Fatalf("openAuditSink(\"\") = %v, %v; want no auditing", sink, err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	os.WriteFile(path, []byte("kept\n"), 0644)
+	sink, err := openAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Audit(uploadserver.AuditRecord{Procedure: "/p/Call", Code: "ok"})
+	if b, _ := os.ReadFile(path); !strings.HasPrefix(string(b), "kept\n{") {
+		t.Fatalf("audit log holds %q, want the record appended", b)
+	}
+	if _, err := openAuditSink(filepath.Join(t.TempDir(), "missing", "audit.jsonl")); err == nil {
+		t.Fatal("openAuditSink of an uncreatable file succeeded")
+	}
+}
+
+func TestCORSPreflightMaxAge(t *testing.T) {
+	for _, tc := range []struct {
+		maxAge time.Duration
+		want   []string
+	}{
+		{10 * time.Minute, []string{"600"}},
+		{0, nil},
+		{-1, []string{"0"}},
+	} {
+		t.Run(tc.maxAge.String(), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/fileupload.v1.FileUploadService/UploadFile", nil)
+			req.Header.Set("Origin", "https://example.com")
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			rec := httptest.NewRecorder()
+			withCORS(okHandler, tc.maxAge).ServeHTTP(rec, req)
+			if got := rec.Header().Values("Access-Control-Max-Age"); !slices.Equal(got, tc.want) {
+				t.Fatalf("Access-Control-Max-Age %q, want %q", got, tc.want)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+				t.Fatalf("Access-Control-Allow-Origin %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// watchReadOnly enters read-only mode on SIGUSR1 and leaves it on SIGUSR2
+func watchReadOnly(readOnly *atomic.Bool) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range ch {
+			on := sig == syscall.SIGUSR1
+			if readOnly.Swap(on) != on {
+				log.Printf("Read-only mode: %v", on)
+			}
+		}
+	}()
+}
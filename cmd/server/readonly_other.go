@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package main
+
+import "sync/atomic"
+
+// watchReadOnly does nothing without SIGUSR1 and SIGUSR2, only -read-only
+// sets the mode
+func watchReadOnly(readOnly *atomic.Bool) {}
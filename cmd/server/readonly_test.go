@@ -0,0 +1,30 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchReadOnly(t *testing.T) {
+	var readOnly atomic.Bool
+	watchReadOnly(&readOnly)
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for readOnly.Load() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("read-only mode still %v", !want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	waitFor(true)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	waitFor(false)
+}
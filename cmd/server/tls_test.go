@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerTLSConfig(t *testing.T) {
+	config, err := serverTLSConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ClientAuth != tls.NoClientCert {
+		t.Fatalf("client auth %v without a client CA", config.ClientAuth)
+	}
+
+	// any certificate will do as a CA here, the handshake is not exercised
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(block), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if config, err = serverTLSConfig(caFile); err != nil {
+		t.Fatal(err)
+	}
+	if config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Fatalf("client auth %v with a client CA, want verified client certificates", config.ClientAuth)
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0644)
+	for _, path := range []string{filepath.Join(t.TempDir(), "missing.pem"), notPEM} {
+		if _, err := serverTLSConfig(path); err == nil {
+			t.Errorf("client CA file %s accepted", filepath.Base(path))
+		}
+	}
+}
@@ -21,6 +21,277 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type ChunkStatus int32
+
+const (
+	ChunkStatus_CHUNK_STATUS_UNSPECIFIED ChunkStatus = 0
+	// Every chunk up to chunk_index was written
+	ChunkStatus_CHUNK_STATUS_WRITTEN ChunkStatus = 1
+	// The chunk at chunk_index failed its CRC and was not written; the client
+	// sends it again, followed by everything it sent after it
+	ChunkStatus_CHUNK_STATUS_CORRUPT ChunkStatus = 2
+)
+
+// Enum value maps for ChunkStatus.
+var (
+	ChunkStatus_name = map[int32]string{
+		0: "CHUNK_STATUS_UNSPECIFIED",
+		1: "CHUNK_STATUS_WRITTEN",
+		2: "CHUNK_STATUS_CORRUPT",
+	}
+	ChunkStatus_value = map[string]int32{
+		"CHUNK_STATUS_UNSPECIFIED": 0,
+		"CHUNK_STATUS_WRITTEN":     1,
+		"CHUNK_STATUS_CORRUPT":     2,
+	}
+)
+
+func (x ChunkStatus) Enum() *ChunkStatus {
+	p := new(ChunkStatus)
+	*p = x
+	return p
+}
+
+func (x ChunkStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChunkStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_fileupload_v1_fileupload_proto_enumTypes[0].Descriptor()
+}
+
+func (ChunkStatus) Type() protoreflect.EnumType {
+	return &file_fileupload_v1_fileupload_proto_enumTypes[0]
+}
+
+func (x ChunkStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChunkStatus.Descriptor instead.
+func (ChunkStatus) EnumDescriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{0}
+}
+
+type HashStatus int32
+
+const (
+	HashStatus_HASH_STATUS_UNSPECIFIED HashStatus = 0
+	// The client's hash matches the content
+	HashStatus_HASH_STATUS_VERIFIED HashStatus = 1
+	// The client's hash differs from the content
+	HashStatus_HASH_STATUS_MISMATCH HashStatus = 2
+	// The client sent no hash, nothing was verified
+	HashStatus_HASH_STATUS_NOT_PROVIDED HashStatus = 3
+)
+
+// Enum value maps for HashStatus.
+var (
+	HashStatus_name = map[int32]string{
+		0: "HASH_STATUS_UNSPECIFIED",
+		1: "HASH_STATUS_VERIFIED",
+		2: "HASH_STATUS_MISMATCH",
+		3: "HASH_STATUS_NOT_PROVIDED",
+	}
+	HashStatus_value = map[string]int32{
+		"HASH_STATUS_UNSPECIFIED":  0,
+		"HASH_STATUS_VERIFIED":     1,
+		"HASH_STATUS_MISMATCH":     2,
+		"HASH_STATUS_NOT_PROVIDED": 3,
+	}
+)
+
+func (x HashStatus) Enum() *HashStatus {
+	p := new(HashStatus)
+	*p = x
+	return p
+}
+
+func (x HashStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (HashStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_fileupload_v1_fileupload_proto_enumTypes[1].Descriptor()
+}
+
+func (HashStatus) Type() protoreflect.EnumType {
+	return &file_fileupload_v1_fileupload_proto_enumTypes[1]
+}
+
+func (x HashStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use HashStatus.Descriptor instead.
+func (HashStatus) EnumDescriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{1}
+}
+
+type ArchiveFormat int32
+
+const (
+	// Taken from the filename: zip for .zip, tar otherwise
+	ArchiveFormat_ARCHIVE_FORMAT_UNSPECIFIED ArchiveFormat = 0
+	ArchiveFormat_ARCHIVE_FORMAT_TAR         ArchiveFormat = 1
+	ArchiveFormat_ARCHIVE_FORMAT_ZIP         ArchiveFormat = 2
+)
+
+// Enum value maps for ArchiveFormat.
+var (
+	ArchiveFormat_name = map[int32]string{
+		0: "ARCHIVE_FORMAT_UNSPECIFIED",
+		1: "ARCHIVE_FORMAT_TAR",
+		2: "ARCHIVE_FORMAT_ZIP",
+	}
+	ArchiveFormat_value = map[string]int32{
+		"ARCHIVE_FORMAT_UNSPECIFIED": 0,
+		"ARCHIVE_FORMAT_TAR":         1,
+		"ARCHIVE_FORMAT_ZIP":         2,
+	}
+)
+
+func (x ArchiveFormat) Enum() *ArchiveFormat {
+	p := new(ArchiveFormat)
+	*p = x
+	return p
+}
+
+func (x ArchiveFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ArchiveFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_fileupload_v1_fileupload_proto_enumTypes[2].Descriptor()
+}
+
+func (ArchiveFormat) Type() protoreflect.EnumType {
+	return &file_fileupload_v1_fileupload_proto_enumTypes[2]
+}
+
+func (x ArchiveFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ArchiveFormat.Descriptor instead.
+func (ArchiveFormat) EnumDescriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{2}
+}
+
+type ScrubStatus int32
+
+const (
+	ScrubStatus_SCRUB_STATUS_UNSPECIFIED ScrubStatus = 0
+	// The content still has its indexed hash
+	ScrubStatus_SCRUB_STATUS_OK ScrubStatus = 1
+	// The content has another hash: bit rot or a change outside the server
+	ScrubStatus_SCRUB_STATUS_CORRUPT ScrubStatus = 2
+	// The indexed file is gone from the upload directory
+	ScrubStatus_SCRUB_STATUS_MISSING ScrubStatus = 3
+	// The file could not be read, see error
+	ScrubStatus_SCRUB_STATUS_UNREADABLE ScrubStatus = 4
+)
+
+// Enum value maps for ScrubStatus.
+var (
+	ScrubStatus_name = map[int32]string{
+		0: "SCRUB_STATUS_UNSPECIFIED",
+		1: "SCRUB_STATUS_OK",
+		2: "SCRUB_STATUS_CORRUPT",
+		3: "SCRUB_STATUS_MISSING",
+		4: "SCRUB_STATUS_UNREADABLE",
+	}
+	ScrubStatus_value = map[string]int32{
+		"SCRUB_STATUS_UNSPECIFIED": 0,
+		"SCRUB_STATUS_OK":          1,
+		"SCRUB_STATUS_CORRUPT":     2,
+		"SCRUB_STATUS_MISSING":     3,
+		"SCRUB_STATUS_UNREADABLE":  4,
+	}
+)
+
+func (x ScrubStatus) Enum() *ScrubStatus {
+	p := new(ScrubStatus)
+	*p = x
+	return p
+}
+
+func (x ScrubStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ScrubStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_fileupload_v1_fileupload_proto_enumTypes[3].Descriptor()
+}
+
+func (ScrubStatus) Type() protoreflect.EnumType {
+	return &file_fileupload_v1_fileupload_proto_enumTypes[3]
+}
+
+func (x ScrubStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ScrubStatus.Descriptor instead.
+func (ScrubStatus) EnumDescriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{3}
+}
+
+type EventType int32
+
+const (
+	EventType_EVENT_TYPE_UNSPECIFIED EventType = 0
+	// A file was stored
+	EventType_EVENT_TYPE_UPLOAD EventType = 1
+	// An expired file was deleted
+	EventType_EVENT_TYPE_DELETE EventType = 2
+	// An upload failed
+	EventType_EVENT_TYPE_ERROR EventType = 3
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_TYPE_UNSPECIFIED",
+		1: "EVENT_TYPE_UPLOAD",
+		2: "EVENT_TYPE_DELETE",
+		3: "EVENT_TYPE_ERROR",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED": 0,
+		"EVENT_TYPE_UPLOAD":      1,
+		"EVENT_TYPE_DELETE":      2,
+		"EVENT_TYPE_ERROR":       3,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_fileupload_v1_fileupload_proto_enumTypes[4].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_fileupload_v1_fileupload_proto_enumTypes[4]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{4}
+}
+
 // Streaming upload request using oneof for type-safe state machine
 type UploadRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -29,7 +300,19 @@ type UploadRequest struct {
 	//	*UploadRequest_Metadata
 	//	*UploadRequest_Chunk
 	//	*UploadRequest_FinishCommit
-	Payload       isUploadRequest_Payload `protobuf_oneof:"payload"`
+	//	*UploadRequest_SegmentedCommit
+	Payload isUploadRequest_Payload `protobuf_oneof:"payload"`
+	// With finish_commit or segmented_commit: the number of bytes the client
+	// sent, checked against what the server received. Unset skips the check.
+	CommitSize *int64 `protobuf:"varint,5,opt,name=commit_size,json=commitSize,proto3,oneof" json:"commit_size,omitempty"`
+	// With chunk: its CRC-32C (Castagnoli), checked by the server. On UploadBidi
+	// a mismatch NACKs the chunk so the client sends it again, on Upload it
+	// fails with DATA_LOSS. Unset skips the check.
+	ChunkCrc32C *uint32 `protobuf:"varint,6,opt,name=chunk_crc32c,json=chunkCrc32c,proto3,oneof" json:"chunk_crc32c,omitempty"`
+	// With chunk: its zero-based position in the content. Required to resend a
+	// NACKed chunk; the server drops the chunks and commit that follow a NACK
+	// until the chunk at the NACKed index arrives again.
+	ChunkIndex    *uint64 `protobuf:"varint,7,opt,name=chunk_index,json=chunkIndex,proto3,oneof" json:"chunk_index,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -98,6 +381,36 @@ func (x *UploadRequest) GetFinishCommit() string {
 	return ""
 }
 
+func (x *UploadRequest) GetSegmentedCommit() *SegmentedCommit {
+	if x != nil {
+		if x, ok := x.Payload.(*UploadRequest_SegmentedCommit); ok {
+			return x.SegmentedCommit
+		}
+	}
+	return nil
+}
+
+func (x *UploadRequest) GetCommitSize() int64 {
+	if x != nil && x.CommitSize != nil {
+		return *x.CommitSize
+	}
+	return 0
+}
+
+func (x *UploadRequest) GetChunkCrc32C() uint32 {
+	if x != nil && x.ChunkCrc32C != nil {
+		return *x.ChunkCrc32C
+	}
+	return 0
+}
+
+func (x *UploadRequest) GetChunkIndex() uint64 {
+	if x != nil && x.ChunkIndex != nil {
+		return *x.ChunkIndex
+	}
+	return 0
+}
+
 type isUploadRequest_Payload interface {
 	isUploadRequest_Payload()
 }
@@ -117,35 +430,45 @@ type UploadRequest_FinishCommit struct {
 	FinishCommit string `protobuf:"bytes,3,opt,name=finish_commit,json=finishCommit,proto3,oneof"`
 }
 
+type UploadRequest_SegmentedCommit struct {
+	// Phase 3 alternative when metadata.segment_size is set
+	SegmentedCommit *SegmentedCommit `protobuf:"bytes,4,opt,name=segmented_commit,json=segmentedCommit,proto3,oneof"`
+}
+
 func (*UploadRequest_Metadata) isUploadRequest_Payload() {}
 
 func (*UploadRequest_Chunk) isUploadRequest_Payload() {}
 
 func (*UploadRequest_FinishCommit) isUploadRequest_Payload() {}
 
-// Metadata for file upload (sent as first message in stream)
-type UploadMetadata struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
-	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+func (*UploadRequest_SegmentedCommit) isUploadRequest_Payload() {}
+
+// Answer of UploadBidi: acks while chunks arrive, then the result
+type UploadBidiResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*UploadBidiResponse_Ack
+	//	*UploadBidiResponse_Result
+	Payload       isUploadBidiResponse_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UploadMetadata) Reset() {
-	*x = UploadMetadata{}
+func (x *UploadBidiResponse) Reset() {
+	*x = UploadBidiResponse{}
 	mi := &file_fileupload_v1_fileupload_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UploadMetadata) String() string {
+func (x *UploadBidiResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UploadMetadata) ProtoMessage() {}
+func (*UploadBidiResponse) ProtoMessage() {}
 
-func (x *UploadMetadata) ProtoReflect() protoreflect.Message {
+func (x *UploadBidiResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_fileupload_v1_fileupload_proto_msgTypes[1]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -157,50 +480,80 @@ func (x *UploadMetadata) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UploadMetadata.ProtoReflect.Descriptor instead.
-func (*UploadMetadata) Descriptor() ([]byte, []int) {
+// Deprecated: Use UploadBidiResponse.ProtoReflect.Descriptor instead.
+func (*UploadBidiResponse) Descriptor() ([]byte, []int) {
 	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{1}
 }
 
-func (x *UploadMetadata) GetFilename() string {
+func (x *UploadBidiResponse) GetPayload() isUploadBidiResponse_Payload {
 	if x != nil {
-		return x.Filename
+		return x.Payload
 	}
-	return ""
+	return nil
 }
 
-func (x *UploadMetadata) GetTitle() string {
+func (x *UploadBidiResponse) GetAck() *UploadAck {
 	if x != nil {
-		return x.Title
+		if x, ok := x.Payload.(*UploadBidiResponse_Ack); ok {
+			return x.Ack
+		}
 	}
-	return ""
+	return nil
 }
 
-// Single request for browser uploads (unary)
-type UploadFileRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
-	Filename      string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
-	Title         string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
-	Sha256        string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`
+func (x *UploadBidiResponse) GetResult() *UploadResponse {
+	if x != nil {
+		if x, ok := x.Payload.(*UploadBidiResponse_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isUploadBidiResponse_Payload interface {
+	isUploadBidiResponse_Payload()
+}
+
+type UploadBidiResponse_Ack struct {
+	Ack *UploadAck `protobuf:"bytes,1,opt,name=ack,proto3,oneof"`
+}
+
+type UploadBidiResponse_Result struct {
+	// Sent last, after the commit, as Upload would return it
+	Result *UploadResponse `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*UploadBidiResponse_Ack) isUploadBidiResponse_Payload() {}
+
+func (*UploadBidiResponse_Result) isUploadBidiResponse_Payload() {}
+
+// Acknowledges every chunk written up to offset, or NACKs the chunk after it
+type UploadAck struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Bytes received and written so far
+	Offset int64 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Zero-based index of the last chunk written, or of the NACKed chunk
+	ChunkIndex uint64 `protobuf:"varint,2,opt,name=chunk_index,json=chunkIndex,proto3" json:"chunk_index,omitempty"`
+	// Unspecified from servers that never NACK
+	Status        ChunkStatus `protobuf:"varint,3,opt,name=status,proto3,enum=fileupload.v1.ChunkStatus" json:"status,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UploadFileRequest) Reset() {
-	*x = UploadFileRequest{}
+func (x *UploadAck) Reset() {
+	*x = UploadAck{}
 	mi := &file_fileupload_v1_fileupload_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UploadFileRequest) String() string {
+func (x *UploadAck) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UploadFileRequest) ProtoMessage() {}
+func (*UploadAck) ProtoMessage() {}
 
-func (x *UploadFileRequest) ProtoReflect() protoreflect.Message {
+func (x *UploadAck) ProtoReflect() protoreflect.Message {
 	mi := &file_fileupload_v1_fileupload_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -212,63 +565,115 @@ func (x *UploadFileRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UploadFileRequest.ProtoReflect.Descriptor instead.
-func (*UploadFileRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use UploadAck.ProtoReflect.Descriptor instead.
+func (*UploadAck) Descriptor() ([]byte, []int) {
 	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *UploadFileRequest) GetData() []byte {
+func (x *UploadAck) GetOffset() int64 {
 	if x != nil {
-		return x.Data
+		return x.Offset
 	}
-	return nil
+	return 0
 }
 
-func (x *UploadFileRequest) GetFilename() string {
+func (x *UploadAck) GetChunkIndex() uint64 {
 	if x != nil {
-		return x.Filename
+		return x.ChunkIndex
 	}
-	return ""
+	return 0
 }
 
-func (x *UploadFileRequest) GetTitle() string {
+func (x *UploadAck) GetStatus() ChunkStatus {
 	if x != nil {
-		return x.Title
+		return x.Status
 	}
-	return ""
+	return ChunkStatus_CHUNK_STATUS_UNSPECIFIED
 }
 
-func (x *UploadFileRequest) GetSha256() string {
+// Commit carrying the hash of every segment next to the hash of the whole
+// content. Segment i covers bytes [i*segment_size, (i+1)*segment_size) and the
+// last one may be shorter, so each can be verified and repaired on its own.
+type SegmentedCommit struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Hex-encoded SHA-256 of the whole content
+	Sha256 string `protobuf:"bytes,1,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Hex-encoded SHA-256 of each segment, in order
+	SegmentSha256 []string `protobuf:"bytes,2,rep,name=segment_sha256,json=segmentSha256,proto3" json:"segment_sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SegmentedCommit) Reset() {
+	*x = SegmentedCommit{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SegmentedCommit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SegmentedCommit) ProtoMessage() {}
+
+func (x *SegmentedCommit) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SegmentedCommit.ProtoReflect.Descriptor instead.
+func (*SegmentedCommit) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SegmentedCommit) GetSha256() string {
 	if x != nil {
 		return x.Sha256
 	}
 	return ""
 }
 
-type UploadResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
-	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
-	HashOk        bool                   `protobuf:"varint,3,opt,name=hash_ok,json=hashOk,proto3" json:"hash_ok,omitempty"`
+func (x *SegmentedCommit) GetSegmentSha256() []string {
+	if x != nil {
+		return x.SegmentSha256
+	}
+	return nil
+}
+
+// Detail of the invalid_argument error refusing a malformed request field
+type FieldViolation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Name of the offending field, as in this file, e.g. "title"
+	Field string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	// What is wrong with its value
+	Description   string `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UploadResponse) Reset() {
-	*x = UploadResponse{}
-	mi := &file_fileupload_v1_fileupload_proto_msgTypes[3]
+func (x *FieldViolation) Reset() {
+	*x = FieldViolation{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UploadResponse) String() string {
+func (x *FieldViolation) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UploadResponse) ProtoMessage() {}
+func (*FieldViolation) ProtoMessage() {}
 
-func (x *UploadResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_fileupload_v1_fileupload_proto_msgTypes[3]
+func (x *FieldViolation) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -279,58 +684,3233 @@ func (x *UploadResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UploadResponse.ProtoReflect.Descriptor instead.
-func (*UploadResponse) Descriptor() ([]byte, []int) {
-	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use FieldViolation.ProtoReflect.Descriptor instead.
+func (*FieldViolation) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *UploadResponse) GetMessage() string {
+func (x *FieldViolation) GetField() string {
 	if x != nil {
-		return x.Message
+		return x.Field
 	}
 	return ""
 }
 
-func (x *UploadResponse) GetSize() int64 {
+func (x *FieldViolation) GetDescription() string {
 	if x != nil {
-		return x.Size
+		return x.Description
 	}
-	return 0
+	return ""
 }
 
-func (x *UploadResponse) GetHashOk() bool {
-	if x != nil {
-		return x.HashOk
-	}
-	return false
+// Detail of the data_loss error returned when segments of a segmented upload
+// do not match. The content is kept as a partial ranged upload: re-sending the
+// listed segments with PUT /files/{filename} and Content-Range completes it.
+type CorruptSegments struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Stored (sanitized) filename to PUT the segments to
+	Filename    string `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	SegmentSize int64  `protobuf:"varint,2,opt,name=segment_size,json=segmentSize,proto3" json:"segment_size,omitempty"`
+	TotalSize   int64  `protobuf:"varint,3,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	// Zero-based indexes of the segments whose hash differs
+	Indexes       []int64 `protobuf:"varint,4,rep,packed,name=indexes,proto3" json:"indexes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-var File_fileupload_v1_fileupload_proto protoreflect.FileDescriptor
+func (x *CorruptSegments) Reset() {
+	*x = CorruptSegments{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
 
-const file_fileupload_v1_fileupload_proto_rawDesc = "" +
+func (x *CorruptSegments) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CorruptSegments) ProtoMessage() {}
+
+func (x *CorruptSegments) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CorruptSegments.ProtoReflect.Descriptor instead.
+func (*CorruptSegments) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CorruptSegments) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *CorruptSegments) GetSegmentSize() int64 {
+	if x != nil {
+		return x.SegmentSize
+	}
+	return 0
+}
+
+func (x *CorruptSegments) GetTotalSize() int64 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+func (x *CorruptSegments) GetIndexes() []int64 {
+	if x != nil {
+		return x.Indexes
+	}
+	return nil
+}
+
+// Metadata for file upload (sent as first message in stream)
+type UploadMetadata struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	Title    string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	// Hex-encoded SHA-256 the commit will carry, when the client knows it
+	// before sending. Concurrent uploads declaring the same hash store the
+	// content once: the others wait for the first one and share its file,
+	// still checking their own content against the hash. Empty declares none.
+	Sha256 string `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Run every check and compute the hash, but store nothing
+	DryRun bool `protobuf:"varint,4,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// Total number of bytes the client will send, unset when unknown
+	DeclaredSize *int64 `protobuf:"varint,5,opt,name=declared_size,json=declaredSize,proto3,oneof" json:"declared_size,omitempty"`
+	// Hash the content in segments of this many bytes, finishing with a
+	// segmented_commit; 0 uses a single finish_commit hash
+	SegmentSize int64 `protobuf:"varint,6,opt,name=segment_size,json=segmentSize,proto3" json:"segment_size,omitempty"`
+	// Only hash the streamed content and return its digest, storing nothing;
+	// the commit hash may be empty
+	HashOnly bool `protobuf:"varint,7,opt,name=hash_only,json=hashOnly,proto3" json:"hash_only,omitempty"`
+	// Only overwrite the stored file if its content has this hex-encoded
+	// SHA-256, failing with FAILED_PRECONDITION when it differs or nothing is
+	// stored; empty stores unconditionally
+	IfMatchSha256 string `protobuf:"bytes,8,opt,name=if_match_sha256,json=ifMatchSha256,proto3" json:"if_match_sha256,omitempty"`
+	// After storing the .zip, .tar, .tar.gz or .tgz file, also unpack it into a
+	// directory named after it in the caller's directory of the server's
+	// extraction directory. Fails with
+	// FAILED_PRECONDITION when the server does not extract archives.
+	Extract bool `protobuf:"varint,9,opt,name=extract,proto3" json:"extract,omitempty"`
+	// Delete the stored file this many seconds after it is stored. At most one
+	// of ttl_seconds and expires_unix may be set; neither keeps it forever.
+	TtlSeconds int64 `protobuf:"varint,10,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	// Delete the stored file at this time, in seconds since the Unix epoch
+	ExpiresUnix int64 `protobuf:"varint,11,opt,name=expires_unix,json=expiresUnix,proto3" json:"expires_unix,omitempty"`
+	// UploadBidi only: acknowledge every this many chunks instead of every one
+	AckEvery uint32 `protobuf:"varint,12,opt,name=ack_every,json=ackEvery,proto3" json:"ack_every,omitempty"`
+	// Number of chunk messages the client will send, 0 when unknown. A stream
+	// sending more or fewer fails with INVALID_ARGUMENT.
+	ExpectedChunks uint64 `protobuf:"varint,13,opt,name=expected_chunks,json=expectedChunks,proto3" json:"expected_chunks,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *UploadMetadata) Reset() {
+	*x = UploadMetadata{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadMetadata) ProtoMessage() {}
+
+func (x *UploadMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadMetadata.ProtoReflect.Descriptor instead.
+func (*UploadMetadata) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UploadMetadata) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *UploadMetadata) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *UploadMetadata) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *UploadMetadata) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *UploadMetadata) GetDeclaredSize() int64 {
+	if x != nil && x.DeclaredSize != nil {
+		return *x.DeclaredSize
+	}
+	return 0
+}
+
+func (x *UploadMetadata) GetSegmentSize() int64 {
+	if x != nil {
+		return x.SegmentSize
+	}
+	return 0
+}
+
+func (x *UploadMetadata) GetHashOnly() bool {
+	if x != nil {
+		return x.HashOnly
+	}
+	return false
+}
+
+func (x *UploadMetadata) GetIfMatchSha256() string {
+	if x != nil {
+		return x.IfMatchSha256
+	}
+	return ""
+}
+
+func (x *UploadMetadata) GetExtract() bool {
+	if x != nil {
+		return x.Extract
+	}
+	return false
+}
+
+func (x *UploadMetadata) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *UploadMetadata) GetExpiresUnix() int64 {
+	if x != nil {
+		return x.ExpiresUnix
+	}
+	return 0
+}
+
+func (x *UploadMetadata) GetAckEvery() uint32 {
+	if x != nil {
+		return x.AckEvery
+	}
+	return 0
+}
+
+func (x *UploadMetadata) GetExpectedChunks() uint64 {
+	if x != nil {
+		return x.ExpectedChunks
+	}
+	return 0
+}
+
+// Single request for browser uploads (unary)
+type UploadFileRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Data     []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Filename string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	Title    string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Sha256   string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Run every check and compute the hash, but store nothing
+	DryRun bool `protobuf:"varint,5,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// Set to upload the file in several calls: data is written at this offset
+	// of a partial file that is completed once every byte up to the end of the
+	// is_last chunk has arrived. sha256 is then checked against the whole file.
+	Offset *int64 `protobuf:"varint,6,opt,name=offset,proto3,oneof" json:"offset,omitempty"`
+	// Marks the chunk ending the file, whose end gives the total size
+	IsLast bool `protobuf:"varint,7,opt,name=is_last,json=isLast,proto3" json:"is_last,omitempty"`
+	// Only overwrite the stored file if its content has this hex-encoded
+	// SHA-256, as in UploadMetadata. With offset it is checked by the call
+	// completing the file.
+	IfMatchSha256 string `protobuf:"bytes,8,opt,name=if_match_sha256,json=ifMatchSha256,proto3" json:"if_match_sha256,omitempty"`
+	// Unpack the stored archive, as in UploadMetadata. With offset it is
+	// unpacked by the call completing the file.
+	Extract bool `protobuf:"varint,9,opt,name=extract,proto3" json:"extract,omitempty"`
+	// Expiry of the stored file, as in UploadMetadata. With offset it is taken
+	// from the call completing the file.
+	TtlSeconds  int64 `protobuf:"varint,10,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	ExpiresUnix int64 `protobuf:"varint,11,opt,name=expires_unix,json=expiresUnix,proto3" json:"expires_unix,omitempty"`
+	// With offset: the hex-encoded SHA-256 of the bytes before offset, for a
+	// resumed upload. The server re-hashes its partial file first; when it
+	// differs the partial upload is discarded and the call fails with
+	// DATA_LOSS, so the client starts over from offset 0.
+	PrefixSha256  string `protobuf:"bytes,12,opt,name=prefix_sha256,json=prefixSha256,proto3" json:"prefix_sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadFileRequest) Reset() {
+	*x = UploadFileRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadFileRequest) ProtoMessage() {}
+
+func (x *UploadFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadFileRequest.ProtoReflect.Descriptor instead.
+func (*UploadFileRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UploadFileRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *UploadFileRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *UploadFileRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *UploadFileRequest) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *UploadFileRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *UploadFileRequest) GetOffset() int64 {
+	if x != nil && x.Offset != nil {
+		return *x.Offset
+	}
+	return 0
+}
+
+func (x *UploadFileRequest) GetIsLast() bool {
+	if x != nil {
+		return x.IsLast
+	}
+	return false
+}
+
+func (x *UploadFileRequest) GetIfMatchSha256() string {
+	if x != nil {
+		return x.IfMatchSha256
+	}
+	return ""
+}
+
+func (x *UploadFileRequest) GetExtract() bool {
+	if x != nil {
+		return x.Extract
+	}
+	return false
+}
+
+func (x *UploadFileRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *UploadFileRequest) GetExpiresUnix() int64 {
+	if x != nil {
+		return x.ExpiresUnix
+	}
+	return 0
+}
+
+func (x *UploadFileRequest) GetPrefixSha256() string {
+	if x != nil {
+		return x.PrefixSha256
+	}
+	return ""
+}
+
+type UploadResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Message string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Size    int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	// Same as hash_status == HASH_STATUS_VERIFIED
+	HashOk bool `protobuf:"varint,3,opt,name=hash_ok,json=hashOk,proto3" json:"hash_ok,omitempty"`
+	// Hex-encoded SHA-256 of the content as computed by the server
+	Sha256 string `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Name the file is stored under, for GetFileMetadata and Download: the
+	// sanitized filename, or a random one when the server assigns names.
+	// Empty when nothing was stored.
+	StoredFilename string `protobuf:"bytes,5,opt,name=stored_filename,json=storedFilename,proto3" json:"stored_filename,omitempty"`
+	// Directory, relative to the server's extraction directory, the archive
+	// was unpacked into when extract was set
+	ExtractedDir string `protobuf:"bytes,6,opt,name=extracted_dir,json=extractedDir,proto3" json:"extracted_dir,omitempty"`
+	// Where the file landed in the server's storage: its path relative to the
+	// upload directory with "/" separators, including any routed subdirectory.
+	// Empty when nothing was stored.
+	StoragePath string `protobuf:"bytes,7,opt,name=storage_path,json=storagePath,proto3" json:"storage_path,omitempty"`
+	// Outcome of checking the client's hash of the content, so a missing hash
+	// is not mistaken for a wrong one. Unspecified for an incomplete chunked
+	// upload.
+	HashStatus HashStatus `protobuf:"varint,8,opt,name=hash_status,json=hashStatus,proto3,enum=fileupload.v1.HashStatus" json:"hash_status,omitempty"`
+	// When the stored file will be deleted, in seconds since the Unix epoch;
+	// 0 when it does not expire
+	ExpiresUnix   int64 `protobuf:"varint,9,opt,name=expires_unix,json=expiresUnix,proto3" json:"expires_unix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadResponse) Reset() {
+	*x = UploadResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadResponse) ProtoMessage() {}
+
+func (x *UploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadResponse.ProtoReflect.Descriptor instead.
+func (*UploadResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UploadResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *UploadResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadResponse) GetHashOk() bool {
+	if x != nil {
+		return x.HashOk
+	}
+	return false
+}
+
+func (x *UploadResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *UploadResponse) GetStoredFilename() string {
+	if x != nil {
+		return x.StoredFilename
+	}
+	return ""
+}
+
+func (x *UploadResponse) GetExtractedDir() string {
+	if x != nil {
+		return x.ExtractedDir
+	}
+	return ""
+}
+
+func (x *UploadResponse) GetStoragePath() string {
+	if x != nil {
+		return x.StoragePath
+	}
+	return ""
+}
+
+func (x *UploadResponse) GetHashStatus() HashStatus {
+	if x != nil {
+		return x.HashStatus
+	}
+	return HashStatus_HASH_STATUS_UNSPECIFIED
+}
+
+func (x *UploadResponse) GetExpiresUnix() int64 {
+	if x != nil {
+		return x.ExpiresUnix
+	}
+	return 0
+}
+
+type GetServerInfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetServerInfoRequest) Reset() {
+	*x = GetServerInfoRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetServerInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetServerInfoRequest) ProtoMessage() {}
+
+func (x *GetServerInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetServerInfoRequest.ProtoReflect.Descriptor instead.
+func (*GetServerInfoRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{9}
+}
+
+type GetServerInfoResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	// Largest data one UploadFile call accepts, 0 when unlimited
+	MaxUnaryUploadBytes int64 `protobuf:"varint,2,opt,name=max_unary_upload_bytes,json=maxUnaryUploadBytes,proto3" json:"max_unary_upload_bytes,omitempty"`
+	// Largest message, a chunk or a whole UploadFile request, 0 when unlimited
+	MaxMessageBytes int64 `protobuf:"varint,3,opt,name=max_message_bytes,json=maxMessageBytes,proto3" json:"max_message_bytes,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *GetServerInfoResponse) Reset() {
+	*x = GetServerInfoResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetServerInfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetServerInfoResponse) ProtoMessage() {}
+
+func (x *GetServerInfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetServerInfoResponse.ProtoReflect.Descriptor instead.
+func (*GetServerInfoResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetServerInfoResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *GetServerInfoResponse) GetMaxUnaryUploadBytes() int64 {
+	if x != nil {
+		return x.MaxUnaryUploadBytes
+	}
+	return 0
+}
+
+func (x *GetServerInfoResponse) GetMaxMessageBytes() int64 {
+	if x != nil {
+		return x.MaxMessageBytes
+	}
+	return 0
+}
+
+type GetFileMetadataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetFileMetadataRequest) Reset() {
+	*x = GetFileMetadataRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFileMetadataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFileMetadataRequest) ProtoMessage() {}
+
+func (x *GetFileMetadataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFileMetadataRequest.ProtoReflect.Descriptor instead.
+func (*GetFileMetadataRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetFileMetadataRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type GetFileMetadataResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	Size     int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256   string                 `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Last modification time in seconds since the Unix epoch
+	ModifiedUnix int64 `protobuf:"varint,4,opt,name=modified_unix,json=modifiedUnix,proto3" json:"modified_unix,omitempty"`
+	// URL to fetch the content from directly, set when downloads are handed off
+	// to external storage
+	DownloadUrl string `protobuf:"bytes,5,opt,name=download_url,json=downloadUrl,proto3" json:"download_url,omitempty"`
+	// A thumbnail of the image is available with GetThumbnail
+	HasThumbnail bool `protobuf:"varint,6,opt,name=has_thumbnail,json=hasThumbnail,proto3" json:"has_thumbnail,omitempty"`
+	// When the file will be deleted, in seconds since the Unix epoch; 0 when
+	// it does not expire
+	ExpiresUnix   int64 `protobuf:"varint,7,opt,name=expires_unix,json=expiresUnix,proto3" json:"expires_unix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetFileMetadataResponse) Reset() {
+	*x = GetFileMetadataResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFileMetadataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFileMetadataResponse) ProtoMessage() {}
+
+func (x *GetFileMetadataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFileMetadataResponse.ProtoReflect.Descriptor instead.
+func (*GetFileMetadataResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetFileMetadataResponse) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *GetFileMetadataResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *GetFileMetadataResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *GetFileMetadataResponse) GetModifiedUnix() int64 {
+	if x != nil {
+		return x.ModifiedUnix
+	}
+	return 0
+}
+
+func (x *GetFileMetadataResponse) GetDownloadUrl() string {
+	if x != nil {
+		return x.DownloadUrl
+	}
+	return ""
+}
+
+func (x *GetFileMetadataResponse) GetHasThumbnail() bool {
+	if x != nil {
+		return x.HasThumbnail
+	}
+	return false
+}
+
+func (x *GetFileMetadataResponse) GetExpiresUnix() int64 {
+	if x != nil {
+		return x.ExpiresUnix
+	}
+	return 0
+}
+
+type StatFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatFileRequest) Reset() {
+	*x = StatFileRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatFileRequest) ProtoMessage() {}
+
+func (x *StatFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatFileRequest.ProtoReflect.Descriptor instead.
+func (*StatFileRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *StatFileRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type StatFileResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sanitized filename
+	Filename string `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	// The other fields are only set when it is true
+	Exists bool   `protobuf:"varint,2,opt,name=exists,proto3" json:"exists,omitempty"`
+	Size   int64  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256 string `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Last modification time in seconds since the Unix epoch
+	ModifiedUnix  int64 `protobuf:"varint,5,opt,name=modified_unix,json=modifiedUnix,proto3" json:"modified_unix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatFileResponse) Reset() {
+	*x = StatFileResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatFileResponse) ProtoMessage() {}
+
+func (x *StatFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatFileResponse.ProtoReflect.Descriptor instead.
+func (*StatFileResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *StatFileResponse) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *StatFileResponse) GetExists() bool {
+	if x != nil {
+		return x.Exists
+	}
+	return false
+}
+
+func (x *StatFileResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *StatFileResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *StatFileResponse) GetModifiedUnix() int64 {
+	if x != nil {
+		return x.ModifiedUnix
+	}
+	return 0
+}
+
+type ListFilesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only list the files whose name starts with this
+	Prefix        string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFilesRequest) Reset() {
+	*x = ListFilesRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFilesRequest) ProtoMessage() {}
+
+func (x *ListFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFilesRequest.ProtoReflect.Descriptor instead.
+func (*ListFilesRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListFilesRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+type FileInfo struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	Size     int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	// Hex-encoded SHA-256 from the server's index, empty for a file stored
+	// behind its back: the listing never reads file content
+	Sha256 string `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Last modification time in seconds since the Unix epoch
+	ModifiedUnix int64 `protobuf:"varint,4,opt,name=modified_unix,json=modifiedUnix,proto3" json:"modified_unix,omitempty"`
+	// Path relative to the upload directory, as in UploadResponse
+	StoragePath string `protobuf:"bytes,5,opt,name=storage_path,json=storagePath,proto3" json:"storage_path,omitempty"`
+	// Expiry in seconds since the Unix epoch, 0 when the file does not expire
+	ExpiresUnix   int64 `protobuf:"varint,6,opt,name=expires_unix,json=expiresUnix,proto3" json:"expires_unix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileInfo) Reset() {
+	*x = FileInfo{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileInfo) ProtoMessage() {}
+
+func (x *FileInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileInfo.ProtoReflect.Descriptor instead.
+func (*FileInfo) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *FileInfo) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *FileInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileInfo) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *FileInfo) GetModifiedUnix() int64 {
+	if x != nil {
+		return x.ModifiedUnix
+	}
+	return 0
+}
+
+func (x *FileInfo) GetStoragePath() string {
+	if x != nil {
+		return x.StoragePath
+	}
+	return ""
+}
+
+func (x *FileInfo) GetExpiresUnix() int64 {
+	if x != nil {
+		return x.ExpiresUnix
+	}
+	return 0
+}
+
+type DownloadRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Skip this many leading bytes, to resume an interrupted download; past the
+	// end of the file fails with OUT_OF_RANGE. A location handed off to external
+	// storage is not affected, fetch it with a Range header instead.
+	Offset        int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadRequest) Reset() {
+	*x = DownloadRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadRequest) ProtoMessage() {}
+
+func (x *DownloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadRequest.ProtoReflect.Descriptor instead.
+func (*DownloadRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DownloadRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *DownloadRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type DownloadResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Chunk []byte                 `protobuf:"bytes,1,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	// Set in a single message, instead of any chunks, when the content must be
+	// fetched from this URL on external storage
+	Location      string `protobuf:"bytes,2,opt,name=location,proto3" json:"location,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadResponse) Reset() {
+	*x = DownloadResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadResponse) ProtoMessage() {}
+
+func (x *DownloadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadResponse.ProtoReflect.Descriptor instead.
+func (*DownloadResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DownloadResponse) GetChunk() []byte {
+	if x != nil {
+		return x.Chunk
+	}
+	return nil
+}
+
+func (x *DownloadResponse) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+type GetUploadStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUploadStatusRequest) Reset() {
+	*x = GetUploadStatusRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUploadStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUploadStatusRequest) ProtoMessage() {}
+
+func (x *GetUploadStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUploadStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetUploadStatusRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetUploadStatusRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type GetUploadStatusResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Stored (sanitized) filename
+	Filename string `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Bytes received from offset 0 without a gap: the offset to resume from.
+	// 0 when no upload of filename is in progress.
+	Offset int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Total size once the is_last chunk has arrived, unset while unknown
+	TotalSize     *int64 `protobuf:"varint,3,opt,name=total_size,json=totalSize,proto3,oneof" json:"total_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUploadStatusResponse) Reset() {
+	*x = GetUploadStatusResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUploadStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUploadStatusResponse) ProtoMessage() {}
+
+func (x *GetUploadStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUploadStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetUploadStatusResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *GetUploadStatusResponse) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *GetUploadStatusResponse) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *GetUploadStatusResponse) GetTotalSize() int64 {
+	if x != nil && x.TotalSize != nil {
+		return *x.TotalSize
+	}
+	return 0
+}
+
+type GetThumbnailRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Stored filename of the image
+	Filename      string `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetThumbnailRequest) Reset() {
+	*x = GetThumbnailRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetThumbnailRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetThumbnailRequest) ProtoMessage() {}
+
+func (x *GetThumbnailRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetThumbnailRequest.ProtoReflect.Descriptor instead.
+func (*GetThumbnailRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *GetThumbnailRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type GetThumbnailResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// JPEG encoded thumbnail
+	Data          []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	ContentType   string `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Width         int32  `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32  `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetThumbnailResponse) Reset() {
+	*x = GetThumbnailResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetThumbnailResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetThumbnailResponse) ProtoMessage() {}
+
+func (x *GetThumbnailResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetThumbnailResponse.ProtoReflect.Descriptor instead.
+func (*GetThumbnailResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *GetThumbnailResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *GetThumbnailResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *GetThumbnailResponse) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *GetThumbnailResponse) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type RenameFileRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Stored filename to rename
+	From string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	// New filename, sanitized like an upload's; it may route the file to
+	// another subdirectory
+	To string `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// Replace an existing file named to instead of failing with ALREADY_EXISTS
+	Overwrite     bool `protobuf:"varint,3,opt,name=overwrite,proto3" json:"overwrite,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenameFileRequest) Reset() {
+	*x = RenameFileRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenameFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenameFileRequest) ProtoMessage() {}
+
+func (x *RenameFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenameFileRequest.ProtoReflect.Descriptor instead.
+func (*RenameFileRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RenameFileRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *RenameFileRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *RenameFileRequest) GetOverwrite() bool {
+	if x != nil {
+		return x.Overwrite
+	}
+	return false
+}
+
+type RenameFileResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sanitized new filename
+	Filename      string `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenameFileResponse) Reset() {
+	*x = RenameFileResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenameFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenameFileResponse) ProtoMessage() {}
+
+func (x *RenameFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenameFileResponse.ProtoReflect.Descriptor instead.
+func (*RenameFileResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *RenameFileResponse) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type CopyFileRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Stored filename to copy
+	From string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	// Filename of the copy, sanitized like an upload's
+	To string `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// Replace an existing file named to instead of failing with ALREADY_EXISTS
+	Overwrite     bool `protobuf:"varint,3,opt,name=overwrite,proto3" json:"overwrite,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CopyFileRequest) Reset() {
+	*x = CopyFileRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CopyFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CopyFileRequest) ProtoMessage() {}
+
+func (x *CopyFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CopyFileRequest.ProtoReflect.Descriptor instead.
+func (*CopyFileRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *CopyFileRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *CopyFileRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *CopyFileRequest) GetOverwrite() bool {
+	if x != nil {
+		return x.Overwrite
+	}
+	return false
+}
+
+type BeginArchiveRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Filename the archive is stored under, sanitized like an upload's
+	Filename      string        `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	Format        ArchiveFormat `protobuf:"varint,2,opt,name=format,proto3,enum=fileupload.v1.ArchiveFormat" json:"format,omitempty"`
+	Title         string        `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BeginArchiveRequest) Reset() {
+	*x = BeginArchiveRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BeginArchiveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BeginArchiveRequest) ProtoMessage() {}
+
+func (x *BeginArchiveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BeginArchiveRequest.ProtoReflect.Descriptor instead.
+func (*BeginArchiveRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *BeginArchiveRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *BeginArchiveRequest) GetFormat() ArchiveFormat {
+	if x != nil {
+		return x.Format
+	}
+	return ArchiveFormat_ARCHIVE_FORMAT_UNSPECIFIED
+}
+
+func (x *BeginArchiveRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+type BeginArchiveResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifies the archive in AddArchiveEntry and CloseArchive
+	ArchiveId string `protobuf:"bytes,1,opt,name=archive_id,json=archiveId,proto3" json:"archive_id,omitempty"`
+	// Sanitized filename
+	Filename      string        `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	Format        ArchiveFormat `protobuf:"varint,3,opt,name=format,proto3,enum=fileupload.v1.ArchiveFormat" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BeginArchiveResponse) Reset() {
+	*x = BeginArchiveResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BeginArchiveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BeginArchiveResponse) ProtoMessage() {}
+
+func (x *BeginArchiveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BeginArchiveResponse.ProtoReflect.Descriptor instead.
+func (*BeginArchiveResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *BeginArchiveResponse) GetArchiveId() string {
+	if x != nil {
+		return x.ArchiveId
+	}
+	return ""
+}
+
+func (x *BeginArchiveResponse) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *BeginArchiveResponse) GetFormat() ArchiveFormat {
+	if x != nil {
+		return x.Format
+	}
+	return ArchiveFormat_ARCHIVE_FORMAT_UNSPECIFIED
+}
+
+type AddArchiveEntryRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ArchiveId string                 `protobuf:"bytes,1,opt,name=archive_id,json=archiveId,proto3" json:"archive_id,omitempty"`
+	// Path of the entry inside the archive; "/" separates directories and each
+	// element is sanitized like a filename
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Data []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	// Hex-encoded SHA-256 of data; when set, an entry that differs is refused
+	// with DATA_LOSS and not added
+	Sha256        string `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddArchiveEntryRequest) Reset() {
+	*x = AddArchiveEntryRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddArchiveEntryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddArchiveEntryRequest) ProtoMessage() {}
+
+func (x *AddArchiveEntryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddArchiveEntryRequest.ProtoReflect.Descriptor instead.
+func (*AddArchiveEntryRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *AddArchiveEntryRequest) GetArchiveId() string {
+	if x != nil {
+		return x.ArchiveId
+	}
+	return ""
+}
+
+func (x *AddArchiveEntryRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AddArchiveEntryRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *AddArchiveEntryRequest) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+type CloseArchiveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ArchiveId     string                 `protobuf:"bytes,1,opt,name=archive_id,json=archiveId,proto3" json:"archive_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CloseArchiveRequest) Reset() {
+	*x = CloseArchiveRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CloseArchiveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloseArchiveRequest) ProtoMessage() {}
+
+func (x *CloseArchiveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloseArchiveRequest.ProtoReflect.Descriptor instead.
+func (*CloseArchiveRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *CloseArchiveRequest) GetArchiveId() string {
+	if x != nil {
+		return x.ArchiveId
+	}
+	return ""
+}
+
+type GetExpiryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetExpiryRequest) Reset() {
+	*x = GetExpiryRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetExpiryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetExpiryRequest) ProtoMessage() {}
+
+func (x *GetExpiryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetExpiryRequest.ProtoReflect.Descriptor instead.
+func (*GetExpiryRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *GetExpiryRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type ExtendExpiryRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	// New expiry counted from now, or an absolute one in expires_unix; exactly
+	// one must be set and it must be later than the current expiry
+	TtlSeconds    int64 `protobuf:"varint,2,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	ExpiresUnix   int64 `protobuf:"varint,3,opt,name=expires_unix,json=expiresUnix,proto3" json:"expires_unix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtendExpiryRequest) Reset() {
+	*x = ExtendExpiryRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtendExpiryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtendExpiryRequest) ProtoMessage() {}
+
+func (x *ExtendExpiryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtendExpiryRequest.ProtoReflect.Descriptor instead.
+func (*ExtendExpiryRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *ExtendExpiryRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *ExtendExpiryRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *ExtendExpiryRequest) GetExpiresUnix() int64 {
+	if x != nil {
+		return x.ExpiresUnix
+	}
+	return 0
+}
+
+type ExpiryResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	// When the file will be deleted, in seconds since the Unix epoch; 0 when
+	// it does not expire
+	ExpiresUnix   int64 `protobuf:"varint,2,opt,name=expires_unix,json=expiresUnix,proto3" json:"expires_unix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExpiryResponse) Reset() {
+	*x = ExpiryResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExpiryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExpiryResponse) ProtoMessage() {}
+
+func (x *ExpiryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExpiryResponse.ProtoReflect.Descriptor instead.
+func (*ExpiryResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *ExpiryResponse) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *ExpiryResponse) GetExpiresUnix() int64 {
+	if x != nil {
+		return x.ExpiresUnix
+	}
+	return 0
+}
+
+type ScrubRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubRequest) Reset() {
+	*x = ScrubRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubRequest) ProtoMessage() {}
+
+func (x *ScrubRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubRequest.ProtoReflect.Descriptor instead.
+func (*ScrubRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{33}
+}
+
+type ScrubFile struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	Status   ScrubStatus            `protobuf:"varint,2,opt,name=status,proto3,enum=fileupload.v1.ScrubStatus" json:"status,omitempty"`
+	// Hex-encoded SHA-256 in the hash index
+	ExpectedSha256 string `protobuf:"bytes,3,opt,name=expected_sha256,json=expectedSha256,proto3" json:"expected_sha256,omitempty"`
+	// Hex-encoded SHA-256 of the content, empty unless it was read
+	ActualSha256  string `protobuf:"bytes,4,opt,name=actual_sha256,json=actualSha256,proto3" json:"actual_sha256,omitempty"`
+	Error         string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubFile) Reset() {
+	*x = ScrubFile{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubFile) ProtoMessage() {}
+
+func (x *ScrubFile) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubFile.ProtoReflect.Descriptor instead.
+func (*ScrubFile) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *ScrubFile) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *ScrubFile) GetStatus() ScrubStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ScrubStatus_SCRUB_STATUS_UNSPECIFIED
+}
+
+func (x *ScrubFile) GetExpectedSha256() string {
+	if x != nil {
+		return x.ExpectedSha256
+	}
+	return ""
+}
+
+func (x *ScrubFile) GetActualSha256() string {
+	if x != nil {
+		return x.ActualSha256
+	}
+	return ""
+}
+
+func (x *ScrubFile) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type ScrubProgress struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The file just checked
+	File          *ScrubFile `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	FilesChecked  int64      `protobuf:"varint,2,opt,name=files_checked,json=filesChecked,proto3" json:"files_checked,omitempty"`
+	FilesTotal    int64      `protobuf:"varint,3,opt,name=files_total,json=filesTotal,proto3" json:"files_total,omitempty"`
+	BytesChecked  int64      `protobuf:"varint,4,opt,name=bytes_checked,json=bytesChecked,proto3" json:"bytes_checked,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubProgress) Reset() {
+	*x = ScrubProgress{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubProgress) ProtoMessage() {}
+
+func (x *ScrubProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubProgress.ProtoReflect.Descriptor instead.
+func (*ScrubProgress) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *ScrubProgress) GetFile() *ScrubFile {
+	if x != nil {
+		return x.File
+	}
+	return nil
+}
+
+func (x *ScrubProgress) GetFilesChecked() int64 {
+	if x != nil {
+		return x.FilesChecked
+	}
+	return 0
+}
+
+func (x *ScrubProgress) GetFilesTotal() int64 {
+	if x != nil {
+		return x.FilesTotal
+	}
+	return 0
+}
+
+func (x *ScrubProgress) GetBytesChecked() int64 {
+	if x != nil {
+		return x.BytesChecked
+	}
+	return 0
+}
+
+type ScrubSummary struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	FilesChecked int64                  `protobuf:"varint,1,opt,name=files_checked,json=filesChecked,proto3" json:"files_checked,omitempty"`
+	BytesChecked int64                  `protobuf:"varint,2,opt,name=bytes_checked,json=bytesChecked,proto3" json:"bytes_checked,omitempty"`
+	// Files replaced or removed while the scrub ran, not checked
+	FilesSkipped int64 `protobuf:"varint,3,opt,name=files_skipped,json=filesSkipped,proto3" json:"files_skipped,omitempty"`
+	// Every file that is not SCRUB_STATUS_OK
+	Damaged       []*ScrubFile `protobuf:"bytes,4,rep,name=damaged,proto3" json:"damaged,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubSummary) Reset() {
+	*x = ScrubSummary{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubSummary) ProtoMessage() {}
+
+func (x *ScrubSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubSummary.ProtoReflect.Descriptor instead.
+func (*ScrubSummary) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *ScrubSummary) GetFilesChecked() int64 {
+	if x != nil {
+		return x.FilesChecked
+	}
+	return 0
+}
+
+func (x *ScrubSummary) GetBytesChecked() int64 {
+	if x != nil {
+		return x.BytesChecked
+	}
+	return 0
+}
+
+func (x *ScrubSummary) GetFilesSkipped() int64 {
+	if x != nil {
+		return x.FilesSkipped
+	}
+	return 0
+}
+
+func (x *ScrubSummary) GetDamaged() []*ScrubFile {
+	if x != nil {
+		return x.Damaged
+	}
+	return nil
+}
+
+// A progress message per file, then the summary as the last message
+type ScrubResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*ScrubResponse_Progress
+	//	*ScrubResponse_Summary
+	Payload       isScrubResponse_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubResponse) Reset() {
+	*x = ScrubResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubResponse) ProtoMessage() {}
+
+func (x *ScrubResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubResponse.ProtoReflect.Descriptor instead.
+func (*ScrubResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *ScrubResponse) GetPayload() isScrubResponse_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *ScrubResponse) GetProgress() *ScrubProgress {
+	if x != nil {
+		if x, ok := x.Payload.(*ScrubResponse_Progress); ok {
+			return x.Progress
+		}
+	}
+	return nil
+}
+
+func (x *ScrubResponse) GetSummary() *ScrubSummary {
+	if x != nil {
+		if x, ok := x.Payload.(*ScrubResponse_Summary); ok {
+			return x.Summary
+		}
+	}
+	return nil
+}
+
+type isScrubResponse_Payload interface {
+	isScrubResponse_Payload()
+}
+
+type ScrubResponse_Progress struct {
+	Progress *ScrubProgress `protobuf:"bytes,1,opt,name=progress,proto3,oneof"`
+}
+
+type ScrubResponse_Summary struct {
+	Summary *ScrubSummary `protobuf:"bytes,2,opt,name=summary,proto3,oneof"`
+}
+
+func (*ScrubResponse_Progress) isScrubResponse_Payload() {}
+
+func (*ScrubResponse_Summary) isScrubResponse_Payload() {}
+
+type WatchEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only these event types, every type when empty
+	Types []EventType `protobuf:"varint,1,rep,packed,name=types,proto3,enum=fileupload.v1.EventType" json:"types,omitempty"`
+	// Only events of callers whose client certificate has this organization;
+	// deletions of expired files have no caller and are left out
+	Namespace     string `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsRequest) Reset() {
+	*x = WatchEventsRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsRequest) ProtoMessage() {}
+
+func (x *WatchEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventsRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *WatchEventsRequest) GetTypes() []EventType {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *WatchEventsRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type ServerEvent struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Type     EventType              `protobuf:"varint,1,opt,name=type,proto3,enum=fileupload.v1.EventType" json:"type,omitempty"`
+	TimeUnix int64                  `protobuf:"varint,2,opt,name=time_unix,json=timeUnix,proto3" json:"time_unix,omitempty"`
+	// The endpoint of an upload, as in the events log: Upload, UploadFile,
+	// multipart, PutFile, tus, ... Empty for deletions.
+	Rpc      string `protobuf:"bytes,3,opt,name=rpc,proto3" json:"rpc,omitempty"`
+	Filename string `protobuf:"bytes,4,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Set when the file is stored under another name
+	StoredFilename string `protobuf:"bytes,5,opt,name=stored_filename,json=storedFilename,proto3" json:"stored_filename,omitempty"`
+	Size           int64  `protobuf:"varint,6,opt,name=size,proto3" json:"size,omitempty"`
+	// The error code of a failed upload, as in the events log
+	Code      string `protobuf:"bytes,7,opt,name=code,proto3" json:"code,omitempty"`
+	Identity  string `protobuf:"bytes,8,opt,name=identity,proto3" json:"identity,omitempty"`
+	Namespace string `protobuf:"bytes,9,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// Events not delivered to this subscriber since the previous one because
+	// it was not keeping up
+	Dropped       uint64 `protobuf:"varint,10,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerEvent) Reset() {
+	*x = ServerEvent{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent) ProtoMessage() {}
+
+func (x *ServerEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent.ProtoReflect.Descriptor instead.
+func (*ServerEvent) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *ServerEvent) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *ServerEvent) GetTimeUnix() int64 {
+	if x != nil {
+		return x.TimeUnix
+	}
+	return 0
+}
+
+func (x *ServerEvent) GetRpc() string {
+	if x != nil {
+		return x.Rpc
+	}
+	return ""
+}
+
+func (x *ServerEvent) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *ServerEvent) GetStoredFilename() string {
+	if x != nil {
+		return x.StoredFilename
+	}
+	return ""
+}
+
+func (x *ServerEvent) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *ServerEvent) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ServerEvent) GetIdentity() string {
+	if x != nil {
+		return x.Identity
+	}
+	return ""
+}
+
+func (x *ServerEvent) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ServerEvent) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+type CreateUploadRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sanitized like an upload's filename
+	Filename string `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	Title    string `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	// Size of the whole file in bytes
+	Size          int64 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateUploadRequest) Reset() {
+	*x = CreateUploadRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateUploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateUploadRequest) ProtoMessage() {}
+
+func (x *CreateUploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateUploadRequest.ProtoReflect.Descriptor instead.
+func (*CreateUploadRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *CreateUploadRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *CreateUploadRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreateUploadRequest) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type UploadSession struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifies the session in UploadChunk, GetUploadSession and the
+	// Upload-Session header
+	UploadId string `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	// Path of the same session on the tus endpoint, relative to the server
+	UploadUrl string `protobuf:"bytes,2,opt,name=upload_url,json=uploadUrl,proto3" json:"upload_url,omitempty"`
+	// Sanitized filename
+	Filename string `protobuf:"bytes,3,opt,name=filename,proto3" json:"filename,omitempty"`
+	Size     int64  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	// Bytes the server holds, where the next chunk starts
+	Offset        int64 `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadSession) Reset() {
+	*x = UploadSession{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadSession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadSession) ProtoMessage() {}
+
+func (x *UploadSession) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadSession.ProtoReflect.Descriptor instead.
+func (*UploadSession) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *UploadSession) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *UploadSession) GetUploadUrl() string {
+	if x != nil {
+		return x.UploadUrl
+	}
+	return ""
+}
+
+func (x *UploadSession) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *UploadSession) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadSession) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type UploadChunkRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	UploadId string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	// Must be the session's current offset
+	Offset        int64  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Data          []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadChunkRequest) Reset() {
+	*x = UploadChunkRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadChunkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadChunkRequest) ProtoMessage() {}
+
+func (x *UploadChunkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadChunkRequest.ProtoReflect.Descriptor instead.
+func (*UploadChunkRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *UploadChunkRequest) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *UploadChunkRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *UploadChunkRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type UploadChunkResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Bytes the server holds after this chunk
+	Offset int64 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Set once the last chunk stored the file
+	Stored        *UploadResponse `protobuf:"bytes,2,opt,name=stored,proto3" json:"stored,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadChunkResponse) Reset() {
+	*x = UploadChunkResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadChunkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadChunkResponse) ProtoMessage() {}
+
+func (x *UploadChunkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadChunkResponse.ProtoReflect.Descriptor instead.
+func (*UploadChunkResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *UploadChunkResponse) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *UploadChunkResponse) GetStored() *UploadResponse {
+	if x != nil {
+		return x.Stored
+	}
+	return nil
+}
+
+type GetUploadSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUploadSessionRequest) Reset() {
+	*x = GetUploadSessionRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUploadSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUploadSessionRequest) ProtoMessage() {}
+
+func (x *GetUploadSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUploadSessionRequest.ProtoReflect.Descriptor instead.
+func (*GetUploadSessionRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *GetUploadSessionRequest) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{45}
+}
+
+type GetStatsResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	TotalFiles int64                  `protobuf:"varint,1,opt,name=total_files,json=totalFiles,proto3" json:"total_files,omitempty"`
+	TotalBytes int64                  `protobuf:"varint,2,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	// total_bytes divided by total_files, 0 without files
+	AverageSize int64 `protobuf:"varint,3,opt,name=average_size,json=averageSize,proto3" json:"average_size,omitempty"`
+	// Totals per directory, sorted by directory
+	Directories []*DirectoryStats `protobuf:"bytes,4,rep,name=directories,proto3" json:"directories,omitempty"`
+	// The largest stored files, largest first
+	LargestFiles []*FileInfo `protobuf:"bytes,5,rep,name=largest_files,json=largestFiles,proto3" json:"largest_files,omitempty"`
+	// When the totals were computed, in seconds since the Unix epoch
+	ComputedUnix  int64 `protobuf:"varint,6,opt,name=computed_unix,json=computedUnix,proto3" json:"computed_unix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsResponse) Reset() {
+	*x = GetStatsResponse{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsResponse) ProtoMessage() {}
+
+func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *GetStatsResponse) GetTotalFiles() int64 {
+	if x != nil {
+		return x.TotalFiles
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetAverageSize() int64 {
+	if x != nil {
+		return x.AverageSize
+	}
+	return 0
+}
+
+func (x *GetStatsResponse) GetDirectories() []*DirectoryStats {
+	if x != nil {
+		return x.Directories
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetLargestFiles() []*FileInfo {
+	if x != nil {
+		return x.LargestFiles
+	}
+	return nil
+}
+
+func (x *GetStatsResponse) GetComputedUnix() int64 {
+	if x != nil {
+		return x.ComputedUnix
+	}
+	return 0
+}
+
+// Totals of the files whose storage path starts with directory: the namespace
+// with a storage key starting with {namespace}, the route directory otherwise.
+// Empty for the files stored in the upload directory itself.
+type DirectoryStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Directory     string                 `protobuf:"bytes,1,opt,name=directory,proto3" json:"directory,omitempty"`
+	Files         int64                  `protobuf:"varint,2,opt,name=files,proto3" json:"files,omitempty"`
+	Bytes         int64                  `protobuf:"varint,3,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DirectoryStats) Reset() {
+	*x = DirectoryStats{}
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DirectoryStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DirectoryStats) ProtoMessage() {}
+
+func (x *DirectoryStats) ProtoReflect() protoreflect.Message {
+	mi := &file_fileupload_v1_fileupload_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DirectoryStats.ProtoReflect.Descriptor instead.
+func (*DirectoryStats) Descriptor() ([]byte, []int) {
+	return file_fileupload_v1_fileupload_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *DirectoryStats) GetDirectory() string {
+	if x != nil {
+		return x.Directory
+	}
+	return ""
+}
+
+func (x *DirectoryStats) GetFiles() int64 {
+	if x != nil {
+		return x.Files
+	}
+	return 0
+}
+
+func (x *DirectoryStats) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+var File_fileupload_v1_fileupload_proto protoreflect.FileDescriptor
+
+const file_fileupload_v1_fileupload_proto_rawDesc = "" +
 	"\n" +
-	"\x1efileupload/v1/fileupload.proto\x12\rfileupload.v1\"\x96\x01\n" +
+	"\x1efileupload/v1/fileupload.proto\x12\rfileupload.v1\"\x88\x03\n" +
 	"\rUploadRequest\x12;\n" +
 	"\bmetadata\x18\x01 \x01(\v2\x1d.fileupload.v1.UploadMetadataH\x00R\bmetadata\x12\x16\n" +
 	"\x05chunk\x18\x02 \x01(\fH\x00R\x05chunk\x12%\n" +
-	"\rfinish_commit\x18\x03 \x01(\tH\x00R\ffinishCommitB\t\n" +
-	"\apayload\"B\n" +
+	"\rfinish_commit\x18\x03 \x01(\tH\x00R\ffinishCommit\x12K\n" +
+	"\x10segmented_commit\x18\x04 \x01(\v2\x1e.fileupload.v1.SegmentedCommitH\x00R\x0fsegmentedCommit\x12$\n" +
+	"\vcommit_size\x18\x05 \x01(\x03H\x01R\n" +
+	"commitSize\x88\x01\x01\x12&\n" +
+	"\fchunk_crc32c\x18\x06 \x01(\rH\x02R\vchunkCrc32c\x88\x01\x01\x12$\n" +
+	"\vchunk_index\x18\a \x01(\x04H\x03R\n" +
+	"chunkIndex\x88\x01\x01B\t\n" +
+	"\apayloadB\x0e\n" +
+	"\f_commit_sizeB\x0f\n" +
+	"\r_chunk_crc32cB\x0e\n" +
+	"\f_chunk_index\"\x86\x01\n" +
+	"\x12UploadBidiResponse\x12,\n" +
+	"\x03ack\x18\x01 \x01(\v2\x18.fileupload.v1.UploadAckH\x00R\x03ack\x127\n" +
+	"\x06result\x18\x02 \x01(\v2\x1d.fileupload.v1.UploadResponseH\x00R\x06resultB\t\n" +
+	"\apayload\"x\n" +
+	"\tUploadAck\x12\x16\n" +
+	"\x06offset\x18\x01 \x01(\x03R\x06offset\x12\x1f\n" +
+	"\vchunk_index\x18\x02 \x01(\x04R\n" +
+	"chunkIndex\x122\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x1a.fileupload.v1.ChunkStatusR\x06status\"P\n" +
+	"\x0fSegmentedCommit\x12\x16\n" +
+	"\x06sha256\x18\x01 \x01(\tR\x06sha256\x12%\n" +
+	"\x0esegment_sha256\x18\x02 \x03(\tR\rsegmentSha256\"H\n" +
+	"\x0eFieldViolation\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\"\x89\x01\n" +
+	"\x0fCorruptSegments\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12!\n" +
+	"\fsegment_size\x18\x02 \x01(\x03R\vsegmentSize\x12\x1d\n" +
+	"\n" +
+	"total_size\x18\x03 \x01(\x03R\ttotalSize\x12\x18\n" +
+	"\aindexes\x18\x04 \x03(\x03R\aindexes\"\xbb\x03\n" +
 	"\x0eUploadMetadata\x12\x1a\n" +
 	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x14\n" +
-	"\x05title\x18\x02 \x01(\tR\x05title\"q\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\tR\x06sha256\x12\x17\n" +
+	"\adry_run\x18\x04 \x01(\bR\x06dryRun\x12(\n" +
+	"\rdeclared_size\x18\x05 \x01(\x03H\x00R\fdeclaredSize\x88\x01\x01\x12!\n" +
+	"\fsegment_size\x18\x06 \x01(\x03R\vsegmentSize\x12\x1b\n" +
+	"\thash_only\x18\a \x01(\bR\bhashOnly\x12&\n" +
+	"\x0fif_match_sha256\x18\b \x01(\tR\rifMatchSha256\x12\x18\n" +
+	"\aextract\x18\t \x01(\bR\aextract\x12\x1f\n" +
+	"\vttl_seconds\x18\n" +
+	" \x01(\x03R\n" +
+	"ttlSeconds\x12!\n" +
+	"\fexpires_unix\x18\v \x01(\x03R\vexpiresUnix\x12\x1b\n" +
+	"\tack_every\x18\f \x01(\rR\backEvery\x12'\n" +
+	"\x0fexpected_chunks\x18\r \x01(\x04R\x0eexpectedChunksB\x10\n" +
+	"\x0e_declared_size\"\xf6\x02\n" +
 	"\x11UploadFileRequest\x12\x12\n" +
 	"\x04data\x18\x01 \x01(\fR\x04data\x12\x1a\n" +
 	"\bfilename\x18\x02 \x01(\tR\bfilename\x12\x14\n" +
 	"\x05title\x18\x03 \x01(\tR\x05title\x12\x16\n" +
-	"\x06sha256\x18\x04 \x01(\tR\x06sha256\"W\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\x12\x17\n" +
+	"\adry_run\x18\x05 \x01(\bR\x06dryRun\x12\x1b\n" +
+	"\x06offset\x18\x06 \x01(\x03H\x00R\x06offset\x88\x01\x01\x12\x17\n" +
+	"\ais_last\x18\a \x01(\bR\x06isLast\x12&\n" +
+	"\x0fif_match_sha256\x18\b \x01(\tR\rifMatchSha256\x12\x18\n" +
+	"\aextract\x18\t \x01(\bR\aextract\x12\x1f\n" +
+	"\vttl_seconds\x18\n" +
+	" \x01(\x03R\n" +
+	"ttlSeconds\x12!\n" +
+	"\fexpires_unix\x18\v \x01(\x03R\vexpiresUnix\x12#\n" +
+	"\rprefix_sha256\x18\f \x01(\tR\fprefixSha256B\t\n" +
+	"\a_offset\"\xbf\x02\n" +
 	"\x0eUploadResponse\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage\x12\x12\n" +
 	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x17\n" +
-	"\ahash_ok\x18\x03 \x01(\bR\x06hashOk2\xab\x01\n" +
+	"\ahash_ok\x18\x03 \x01(\bR\x06hashOk\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\x12'\n" +
+	"\x0fstored_filename\x18\x05 \x01(\tR\x0estoredFilename\x12#\n" +
+	"\rextracted_dir\x18\x06 \x01(\tR\fextractedDir\x12!\n" +
+	"\fstorage_path\x18\a \x01(\tR\vstoragePath\x12:\n" +
+	"\vhash_status\x18\b \x01(\x0e2\x19.fileupload.v1.HashStatusR\n" +
+	"hashStatus\x12!\n" +
+	"\fexpires_unix\x18\t \x01(\x03R\vexpiresUnix\"\x16\n" +
+	"\x14GetServerInfoRequest\"\x92\x01\n" +
+	"\x15GetServerInfoResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x123\n" +
+	"\x16max_unary_upload_bytes\x18\x02 \x01(\x03R\x13maxUnaryUploadBytes\x12*\n" +
+	"\x11max_message_bytes\x18\x03 \x01(\x03R\x0fmaxMessageBytes\"4\n" +
+	"\x16GetFileMetadataRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"\xf1\x01\n" +
+	"\x17GetFileMetadataResponse\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\tR\x06sha256\x12#\n" +
+	"\rmodified_unix\x18\x04 \x01(\x03R\fmodifiedUnix\x12!\n" +
+	"\fdownload_url\x18\x05 \x01(\tR\vdownloadUrl\x12#\n" +
+	"\rhas_thumbnail\x18\x06 \x01(\bR\fhasThumbnail\x12!\n" +
+	"\fexpires_unix\x18\a \x01(\x03R\vexpiresUnix\"-\n" +
+	"\x0fStatFileRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"\x97\x01\n" +
+	"\x10StatFileResponse\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x16\n" +
+	"\x06exists\x18\x02 \x01(\bR\x06exists\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\x12#\n" +
+	"\rmodified_unix\x18\x05 \x01(\x03R\fmodifiedUnix\"*\n" +
+	"\x10ListFilesRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\"\xbd\x01\n" +
+	"\bFileInfo\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\tR\x06sha256\x12#\n" +
+	"\rmodified_unix\x18\x04 \x01(\x03R\fmodifiedUnix\x12!\n" +
+	"\fstorage_path\x18\x05 \x01(\tR\vstoragePath\x12!\n" +
+	"\fexpires_unix\x18\x06 \x01(\x03R\vexpiresUnix\"E\n" +
+	"\x0fDownloadRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\"D\n" +
+	"\x10DownloadResponse\x12\x14\n" +
+	"\x05chunk\x18\x01 \x01(\fR\x05chunk\x12\x1a\n" +
+	"\blocation\x18\x02 \x01(\tR\blocation\"4\n" +
+	"\x16GetUploadStatusRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"\x80\x01\n" +
+	"\x17GetUploadStatusResponse\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12\"\n" +
+	"\n" +
+	"total_size\x18\x03 \x01(\x03H\x00R\ttotalSize\x88\x01\x01B\r\n" +
+	"\v_total_size\"1\n" +
+	"\x13GetThumbnailRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"{\n" +
+	"\x14GetThumbnailResponse\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\"U\n" +
+	"\x11RenameFileRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x1c\n" +
+	"\toverwrite\x18\x03 \x01(\bR\toverwrite\"0\n" +
+	"\x12RenameFileResponse\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"S\n" +
+	"\x0fCopyFileRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x1c\n" +
+	"\toverwrite\x18\x03 \x01(\bR\toverwrite\"}\n" +
+	"\x13BeginArchiveRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x124\n" +
+	"\x06format\x18\x02 \x01(\x0e2\x1c.fileupload.v1.ArchiveFormatR\x06format\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\"\x87\x01\n" +
+	"\x14BeginArchiveResponse\x12\x1d\n" +
+	"\n" +
+	"archive_id\x18\x01 \x01(\tR\tarchiveId\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x124\n" +
+	"\x06format\x18\x03 \x01(\x0e2\x1c.fileupload.v1.ArchiveFormatR\x06format\"w\n" +
+	"\x16AddArchiveEntryRequest\x12\x1d\n" +
+	"\n" +
+	"archive_id\x18\x01 \x01(\tR\tarchiveId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\"4\n" +
+	"\x13CloseArchiveRequest\x12\x1d\n" +
+	"\n" +
+	"archive_id\x18\x01 \x01(\tR\tarchiveId\".\n" +
+	"\x10GetExpiryRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"u\n" +
+	"\x13ExtendExpiryRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x1f\n" +
+	"\vttl_seconds\x18\x02 \x01(\x03R\n" +
+	"ttlSeconds\x12!\n" +
+	"\fexpires_unix\x18\x03 \x01(\x03R\vexpiresUnix\"O\n" +
+	"\x0eExpiryResponse\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12!\n" +
+	"\fexpires_unix\x18\x02 \x01(\x03R\vexpiresUnix\"\x0e\n" +
+	"\fScrubRequest\"\xbf\x01\n" +
+	"\tScrubFile\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x122\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x1a.fileupload.v1.ScrubStatusR\x06status\x12'\n" +
+	"\x0fexpected_sha256\x18\x03 \x01(\tR\x0eexpectedSha256\x12#\n" +
+	"\ractual_sha256\x18\x04 \x01(\tR\factualSha256\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\"\xa8\x01\n" +
+	"\rScrubProgress\x12,\n" +
+	"\x04file\x18\x01 \x01(\v2\x18.fileupload.v1.ScrubFileR\x04file\x12#\n" +
+	"\rfiles_checked\x18\x02 \x01(\x03R\ffilesChecked\x12\x1f\n" +
+	"\vfiles_total\x18\x03 \x01(\x03R\n" +
+	"filesTotal\x12#\n" +
+	"\rbytes_checked\x18\x04 \x01(\x03R\fbytesChecked\"\xb1\x01\n" +
+	"\fScrubSummary\x12#\n" +
+	"\rfiles_checked\x18\x01 \x01(\x03R\ffilesChecked\x12#\n" +
+	"\rbytes_checked\x18\x02 \x01(\x03R\fbytesChecked\x12#\n" +
+	"\rfiles_skipped\x18\x03 \x01(\x03R\ffilesSkipped\x122\n" +
+	"\adamaged\x18\x04 \x03(\v2\x18.fileupload.v1.ScrubFileR\adamaged\"\x8f\x01\n" +
+	"\rScrubResponse\x12:\n" +
+	"\bprogress\x18\x01 \x01(\v2\x1c.fileupload.v1.ScrubProgressH\x00R\bprogress\x127\n" +
+	"\asummary\x18\x02 \x01(\v2\x1b.fileupload.v1.ScrubSummaryH\x00R\asummaryB\t\n" +
+	"\apayload\"b\n" +
+	"\x12WatchEventsRequest\x12.\n" +
+	"\x05types\x18\x01 \x03(\x0e2\x18.fileupload.v1.EventTypeR\x05types\x12\x1c\n" +
+	"\tnamespace\x18\x02 \x01(\tR\tnamespace\"\xab\x02\n" +
+	"\vServerEvent\x12,\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x18.fileupload.v1.EventTypeR\x04type\x12\x1b\n" +
+	"\ttime_unix\x18\x02 \x01(\x03R\btimeUnix\x12\x10\n" +
+	"\x03rpc\x18\x03 \x01(\tR\x03rpc\x12\x1a\n" +
+	"\bfilename\x18\x04 \x01(\tR\bfilename\x12'\n" +
+	"\x0fstored_filename\x18\x05 \x01(\tR\x0estoredFilename\x12\x12\n" +
+	"\x04size\x18\x06 \x01(\x03R\x04size\x12\x12\n" +
+	"\x04code\x18\a \x01(\tR\x04code\x12\x1a\n" +
+	"\bidentity\x18\b \x01(\tR\bidentity\x12\x1c\n" +
+	"\tnamespace\x18\t \x01(\tR\tnamespace\x12\x18\n" +
+	"\adropped\x18\n" +
+	" \x01(\x04R\adropped\"[\n" +
+	"\x13CreateUploadRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\"\x93\x01\n" +
+	"\rUploadSession\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12\x1d\n" +
+	"\n" +
+	"upload_url\x18\x02 \x01(\tR\tuploadUrl\x12\x1a\n" +
+	"\bfilename\x18\x03 \x01(\tR\bfilename\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06offset\x18\x05 \x01(\x03R\x06offset\"]\n" +
+	"\x12UploadChunkRequest\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"d\n" +
+	"\x13UploadChunkResponse\x12\x16\n" +
+	"\x06offset\x18\x01 \x01(\x03R\x06offset\x125\n" +
+	"\x06stored\x18\x02 \x01(\v2\x1d.fileupload.v1.UploadResponseR\x06stored\"6\n" +
+	"\x17GetUploadSessionRequest\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\"\x11\n" +
+	"\x0fGetStatsRequest\"\x9b\x02\n" +
+	"\x10GetStatsResponse\x12\x1f\n" +
+	"\vtotal_files\x18\x01 \x01(\x03R\n" +
+	"totalFiles\x12\x1f\n" +
+	"\vtotal_bytes\x18\x02 \x01(\x03R\n" +
+	"totalBytes\x12!\n" +
+	"\faverage_size\x18\x03 \x01(\x03R\vaverageSize\x12?\n" +
+	"\vdirectories\x18\x04 \x03(\v2\x1d.fileupload.v1.DirectoryStatsR\vdirectories\x12<\n" +
+	"\rlargest_files\x18\x05 \x03(\v2\x17.fileupload.v1.FileInfoR\flargestFiles\x12#\n" +
+	"\rcomputed_unix\x18\x06 \x01(\x03R\fcomputedUnix\"Z\n" +
+	"\x0eDirectoryStats\x12\x1c\n" +
+	"\tdirectory\x18\x01 \x01(\tR\tdirectory\x12\x14\n" +
+	"\x05files\x18\x02 \x01(\x03R\x05files\x12\x14\n" +
+	"\x05bytes\x18\x03 \x01(\x03R\x05bytes*_\n" +
+	"\vChunkStatus\x12\x1c\n" +
+	"\x18CHUNK_STATUS_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14CHUNK_STATUS_WRITTEN\x10\x01\x12\x18\n" +
+	"\x14CHUNK_STATUS_CORRUPT\x10\x02*{\n" +
+	"\n" +
+	"HashStatus\x12\x1b\n" +
+	"\x17HASH_STATUS_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14HASH_STATUS_VERIFIED\x10\x01\x12\x18\n" +
+	"\x14HASH_STATUS_MISMATCH\x10\x02\x12\x1c\n" +
+	"\x18HASH_STATUS_NOT_PROVIDED\x10\x03*_\n" +
+	"\rArchiveFormat\x12\x1e\n" +
+	"\x1aARCHIVE_FORMAT_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12ARCHIVE_FORMAT_TAR\x10\x01\x12\x16\n" +
+	"\x12ARCHIVE_FORMAT_ZIP\x10\x02*\x91\x01\n" +
+	"\vScrubStatus\x12\x1c\n" +
+	"\x18SCRUB_STATUS_UNSPECIFIED\x10\x00\x12\x13\n" +
+	"\x0fSCRUB_STATUS_OK\x10\x01\x12\x18\n" +
+	"\x14SCRUB_STATUS_CORRUPT\x10\x02\x12\x18\n" +
+	"\x14SCRUB_STATUS_MISSING\x10\x03\x12\x1b\n" +
+	"\x17SCRUB_STATUS_UNREADABLE\x10\x04*k\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11EVENT_TYPE_UPLOAD\x10\x01\x12\x15\n" +
+	"\x11EVENT_TYPE_DELETE\x10\x02\x12\x14\n" +
+	"\x10EVENT_TYPE_ERROR\x10\x032\xb2\x0f\n" +
 	"\x11FileUploadService\x12G\n" +
-	"\x06Upload\x12\x1c.fileupload.v1.UploadRequest\x1a\x1d.fileupload.v1.UploadResponse(\x01\x12M\n" +
+	"\x06Upload\x12\x1c.fileupload.v1.UploadRequest\x1a\x1d.fileupload.v1.UploadResponse(\x01\x12Q\n" +
 	"\n" +
-	"UploadFile\x12 .fileupload.v1.UploadFileRequest\x1a\x1d.fileupload.v1.UploadResponseB\xca\x01\n" +
+	"UploadBidi\x12\x1c.fileupload.v1.UploadRequest\x1a!.fileupload.v1.UploadBidiResponse(\x010\x01\x12M\n" +
+	"\n" +
+	"UploadFile\x12 .fileupload.v1.UploadFileRequest\x1a\x1d.fileupload.v1.UploadResponse\x12_\n" +
+	"\rGetServerInfo\x12#.fileupload.v1.GetServerInfoRequest\x1a$.fileupload.v1.GetServerInfoResponse\"\x03\x90\x02\x01\x12e\n" +
+	"\x0fGetFileMetadata\x12%.fileupload.v1.GetFileMetadataRequest\x1a&.fileupload.v1.GetFileMetadataResponse\"\x03\x90\x02\x01\x12P\n" +
+	"\bStatFile\x12\x1e.fileupload.v1.StatFileRequest\x1a\x1f.fileupload.v1.StatFileResponse\"\x03\x90\x02\x01\x12M\n" +
+	"\x0fListFilesStream\x12\x1f.fileupload.v1.ListFilesRequest\x1a\x17.fileupload.v1.FileInfo0\x01\x12M\n" +
+	"\bDownload\x12\x1e.fileupload.v1.DownloadRequest\x1a\x1f.fileupload.v1.DownloadResponse0\x01\x12e\n" +
+	"\x0fGetUploadStatus\x12%.fileupload.v1.GetUploadStatusRequest\x1a&.fileupload.v1.GetUploadStatusResponse\"\x03\x90\x02\x01\x12\\\n" +
+	"\fGetThumbnail\x12\".fileupload.v1.GetThumbnailRequest\x1a#.fileupload.v1.GetThumbnailResponse\"\x03\x90\x02\x01\x12Q\n" +
+	"\n" +
+	"RenameFile\x12 .fileupload.v1.RenameFileRequest\x1a!.fileupload.v1.RenameFileResponse\x12I\n" +
+	"\bCopyFile\x12\x1e.fileupload.v1.CopyFileRequest\x1a\x1d.fileupload.v1.UploadResponse\x12W\n" +
+	"\fBeginArchive\x12\".fileupload.v1.BeginArchiveRequest\x1a#.fileupload.v1.BeginArchiveResponse\x12W\n" +
+	"\x0fAddArchiveEntry\x12%.fileupload.v1.AddArchiveEntryRequest\x1a\x1d.fileupload.v1.UploadResponse\x12Q\n" +
+	"\fCloseArchive\x12\".fileupload.v1.CloseArchiveRequest\x1a\x1d.fileupload.v1.UploadResponse\x12P\n" +
+	"\tGetExpiry\x12\x1f.fileupload.v1.GetExpiryRequest\x1a\x1d.fileupload.v1.ExpiryResponse\"\x03\x90\x02\x01\x12Q\n" +
+	"\fExtendExpiry\x12\".fileupload.v1.ExtendExpiryRequest\x1a\x1d.fileupload.v1.ExpiryResponse\x12D\n" +
+	"\x05Scrub\x12\x1b.fileupload.v1.ScrubRequest\x1a\x1c.fileupload.v1.ScrubResponse0\x01\x12N\n" +
+	"\vWatchEvents\x12!.fileupload.v1.WatchEventsRequest\x1a\x1a.fileupload.v1.ServerEvent0\x01\x12P\n" +
+	"\fCreateUpload\x12\".fileupload.v1.CreateUploadRequest\x1a\x1c.fileupload.v1.UploadSession\x12T\n" +
+	"\vUploadChunk\x12!.fileupload.v1.UploadChunkRequest\x1a\".fileupload.v1.UploadChunkResponse\x12]\n" +
+	"\x10GetUploadSession\x12&.fileupload.v1.GetUploadSessionRequest\x1a\x1c.fileupload.v1.UploadSession\"\x03\x90\x02\x01\x12P\n" +
+	"\bGetStats\x12\x1e.fileupload.v1.GetStatsRequest\x1a\x1f.fileupload.v1.GetStatsResponse\"\x03\x90\x02\x01B\xca\x01\n" +
 	"\x11com.fileupload.v1B\x0fFileuploadProtoP\x01ZOgithub.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1;fileuploadv1\xa2\x02\x03FXX\xaa\x02\rFileupload.V1\xca\x02\rFileupload\\V1\xe2\x02\x19Fileupload\\V1\\GPBMetadata\xea\x02\x0eFileupload::V1b\x06proto3"
 
 var (
@@ -345,24 +3925,133 @@ func file_fileupload_v1_fileupload_proto_rawDescGZIP() []byte {
 	return file_fileupload_v1_fileupload_proto_rawDescData
 }
 
-var file_fileupload_v1_fileupload_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_fileupload_v1_fileupload_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_fileupload_v1_fileupload_proto_msgTypes = make([]protoimpl.MessageInfo, 48)
 var file_fileupload_v1_fileupload_proto_goTypes = []any{
-	(*UploadRequest)(nil),     // 0: fileupload.v1.UploadRequest
-	(*UploadMetadata)(nil),    // 1: fileupload.v1.UploadMetadata
-	(*UploadFileRequest)(nil), // 2: fileupload.v1.UploadFileRequest
-	(*UploadResponse)(nil),    // 3: fileupload.v1.UploadResponse
+	(ChunkStatus)(0),                // 0: fileupload.v1.ChunkStatus
+	(HashStatus)(0),                 // 1: fileupload.v1.HashStatus
+	(ArchiveFormat)(0),              // 2: fileupload.v1.ArchiveFormat
+	(ScrubStatus)(0),                // 3: fileupload.v1.ScrubStatus
+	(EventType)(0),                  // 4: fileupload.v1.EventType
+	(*UploadRequest)(nil),           // 5: fileupload.v1.UploadRequest
+	(*UploadBidiResponse)(nil),      // 6: fileupload.v1.UploadBidiResponse
+	(*UploadAck)(nil),               // 7: fileupload.v1.UploadAck
+	(*SegmentedCommit)(nil),         // 8: fileupload.v1.SegmentedCommit
+	(*FieldViolation)(nil),          // 9: fileupload.v1.FieldViolation
+	(*CorruptSegments)(nil),         // 10: fileupload.v1.CorruptSegments
+	(*UploadMetadata)(nil),          // 11: fileupload.v1.UploadMetadata
+	(*UploadFileRequest)(nil),       // 12: fileupload.v1.UploadFileRequest
+	(*UploadResponse)(nil),          // 13: fileupload.v1.UploadResponse
+	(*GetServerInfoRequest)(nil),    // 14: fileupload.v1.GetServerInfoRequest
+	(*GetServerInfoResponse)(nil),   // 15: fileupload.v1.GetServerInfoResponse
+	(*GetFileMetadataRequest)(nil),  // 16: fileupload.v1.GetFileMetadataRequest
+	(*GetFileMetadataResponse)(nil), // 17: fileupload.v1.GetFileMetadataResponse
+	(*StatFileRequest)(nil),         // 18: fileupload.v1.StatFileRequest
+	(*StatFileResponse)(nil),        // 19: fileupload.v1.StatFileResponse
+	(*ListFilesRequest)(nil),        // 20: fileupload.v1.ListFilesRequest
+	(*FileInfo)(nil),                // 21: fileupload.v1.FileInfo
+	(*DownloadRequest)(nil),         // 22: fileupload.v1.DownloadRequest
+	(*DownloadResponse)(nil),        // 23: fileupload.v1.DownloadResponse
+	(*GetUploadStatusRequest)(nil),  // 24: fileupload.v1.GetUploadStatusRequest
+	(*GetUploadStatusResponse)(nil), // 25: fileupload.v1.GetUploadStatusResponse
+	(*GetThumbnailRequest)(nil),     // 26: fileupload.v1.GetThumbnailRequest
+	(*GetThumbnailResponse)(nil),    // 27: fileupload.v1.GetThumbnailResponse
+	(*RenameFileRequest)(nil),       // 28: fileupload.v1.RenameFileRequest
+	(*RenameFileResponse)(nil),      // 29: fileupload.v1.RenameFileResponse
+	(*CopyFileRequest)(nil),         // 30: fileupload.v1.CopyFileRequest
+	(*BeginArchiveRequest)(nil),     // 31: fileupload.v1.BeginArchiveRequest
+	(*BeginArchiveResponse)(nil),    // 32: fileupload.v1.BeginArchiveResponse
+	(*AddArchiveEntryRequest)(nil),  // 33: fileupload.v1.AddArchiveEntryRequest
+	(*CloseArchiveRequest)(nil),     // 34: fileupload.v1.CloseArchiveRequest
+	(*GetExpiryRequest)(nil),        // 35: fileupload.v1.GetExpiryRequest
+	(*ExtendExpiryRequest)(nil),     // 36: fileupload.v1.ExtendExpiryRequest
+	(*ExpiryResponse)(nil),          // 37: fileupload.v1.ExpiryResponse
+	(*ScrubRequest)(nil),            // 38: fileupload.v1.ScrubRequest
+	(*ScrubFile)(nil),               // 39: fileupload.v1.ScrubFile
+	(*ScrubProgress)(nil),           // 40: fileupload.v1.ScrubProgress
+	(*ScrubSummary)(nil),            // 41: fileupload.v1.ScrubSummary
+	(*ScrubResponse)(nil),           // 42: fileupload.v1.ScrubResponse
+	(*WatchEventsRequest)(nil),      // 43: fileupload.v1.WatchEventsRequest
+	(*ServerEvent)(nil),             // 44: fileupload.v1.ServerEvent
+	(*CreateUploadRequest)(nil),     // 45: fileupload.v1.CreateUploadRequest
+	(*UploadSession)(nil),           // 46: fileupload.v1.UploadSession
+	(*UploadChunkRequest)(nil),      // 47: fileupload.v1.UploadChunkRequest
+	(*UploadChunkResponse)(nil),     // 48: fileupload.v1.UploadChunkResponse
+	(*GetUploadSessionRequest)(nil), // 49: fileupload.v1.GetUploadSessionRequest
+	(*GetStatsRequest)(nil),         // 50: fileupload.v1.GetStatsRequest
+	(*GetStatsResponse)(nil),        // 51: fileupload.v1.GetStatsResponse
+	(*DirectoryStats)(nil),          // 52: fileupload.v1.DirectoryStats
 }
 var file_fileupload_v1_fileupload_proto_depIdxs = []int32{
-	1, // 0: fileupload.v1.UploadRequest.metadata:type_name -> fileupload.v1.UploadMetadata
-	0, // 1: fileupload.v1.FileUploadService.Upload:input_type -> fileupload.v1.UploadRequest
-	2, // 2: fileupload.v1.FileUploadService.UploadFile:input_type -> fileupload.v1.UploadFileRequest
-	3, // 3: fileupload.v1.FileUploadService.Upload:output_type -> fileupload.v1.UploadResponse
-	3, // 4: fileupload.v1.FileUploadService.UploadFile:output_type -> fileupload.v1.UploadResponse
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	11, // 0: fileupload.v1.UploadRequest.metadata:type_name -> fileupload.v1.UploadMetadata
+	8,  // 1: fileupload.v1.UploadRequest.segmented_commit:type_name -> fileupload.v1.SegmentedCommit
+	7,  // 2: fileupload.v1.UploadBidiResponse.ack:type_name -> fileupload.v1.UploadAck
+	13, // 3: fileupload.v1.UploadBidiResponse.result:type_name -> fileupload.v1.UploadResponse
+	0,  // 4: fileupload.v1.UploadAck.status:type_name -> fileupload.v1.ChunkStatus
+	1,  // 5: fileupload.v1.UploadResponse.hash_status:type_name -> fileupload.v1.HashStatus
+	2,  // 6: fileupload.v1.BeginArchiveRequest.format:type_name -> fileupload.v1.ArchiveFormat
+	2,  // 7: fileupload.v1.BeginArchiveResponse.format:type_name -> fileupload.v1.ArchiveFormat
+	3,  // 8: fileupload.v1.ScrubFile.status:type_name -> fileupload.v1.ScrubStatus
+	39, // 9: fileupload.v1.ScrubProgress.file:type_name -> fileupload.v1.ScrubFile
+	39, // 10: fileupload.v1.ScrubSummary.damaged:type_name -> fileupload.v1.ScrubFile
+	40, // 11: fileupload.v1.ScrubResponse.progress:type_name -> fileupload.v1.ScrubProgress
+	41, // 12: fileupload.v1.ScrubResponse.summary:type_name -> fileupload.v1.ScrubSummary
+	4,  // 13: fileupload.v1.WatchEventsRequest.types:type_name -> fileupload.v1.EventType
+	4,  // 14: fileupload.v1.ServerEvent.type:type_name -> fileupload.v1.EventType
+	13, // 15: fileupload.v1.UploadChunkResponse.stored:type_name -> fileupload.v1.UploadResponse
+	52, // 16: fileupload.v1.GetStatsResponse.directories:type_name -> fileupload.v1.DirectoryStats
+	21, // 17: fileupload.v1.GetStatsResponse.largest_files:type_name -> fileupload.v1.FileInfo
+	5,  // 18: fileupload.v1.FileUploadService.Upload:input_type -> fileupload.v1.UploadRequest
+	5,  // 19: fileupload.v1.FileUploadService.UploadBidi:input_type -> fileupload.v1.UploadRequest
+	12, // 20: fileupload.v1.FileUploadService.UploadFile:input_type -> fileupload.v1.UploadFileRequest
+	14, // 21: fileupload.v1.FileUploadService.GetServerInfo:input_type -> fileupload.v1.GetServerInfoRequest
+	16, // 22: fileupload.v1.FileUploadService.GetFileMetadata:input_type -> fileupload.v1.GetFileMetadataRequest
+	18, // 23: fileupload.v1.FileUploadService.StatFile:input_type -> fileupload.v1.StatFileRequest
+	20, // 24: fileupload.v1.FileUploadService.ListFilesStream:input_type -> fileupload.v1.ListFilesRequest
+	22, // 25: fileupload.v1.FileUploadService.Download:input_type -> fileupload.v1.DownloadRequest
+	24, // 26: fileupload.v1.FileUploadService.GetUploadStatus:input_type -> fileupload.v1.GetUploadStatusRequest
+	26, // 27: fileupload.v1.FileUploadService.GetThumbnail:input_type -> fileupload.v1.GetThumbnailRequest
+	28, // 28: fileupload.v1.FileUploadService.RenameFile:input_type -> fileupload.v1.RenameFileRequest
+	30, // 29: fileupload.v1.FileUploadService.CopyFile:input_type -> fileupload.v1.CopyFileRequest
+	31, // 30: fileupload.v1.FileUploadService.BeginArchive:input_type -> fileupload.v1.BeginArchiveRequest
+	33, // 31: fileupload.v1.FileUploadService.AddArchiveEntry:input_type -> fileupload.v1.AddArchiveEntryRequest
+	34, // 32: fileupload.v1.FileUploadService.CloseArchive:input_type -> fileupload.v1.CloseArchiveRequest
+	35, // 33: fileupload.v1.FileUploadService.GetExpiry:input_type -> fileupload.v1.GetExpiryRequest
+	36, // 34: fileupload.v1.FileUploadService.ExtendExpiry:input_type -> fileupload.v1.ExtendExpiryRequest
+	38, // 35: fileupload.v1.FileUploadService.Scrub:input_type -> fileupload.v1.ScrubRequest
+	43, // 36: fileupload.v1.FileUploadService.WatchEvents:input_type -> fileupload.v1.WatchEventsRequest
+	45, // 37: fileupload.v1.FileUploadService.CreateUpload:input_type -> fileupload.v1.CreateUploadRequest
+	47, // 38: fileupload.v1.FileUploadService.UploadChunk:input_type -> fileupload.v1.UploadChunkRequest
+	49, // 39: fileupload.v1.FileUploadService.GetUploadSession:input_type -> fileupload.v1.GetUploadSessionRequest
+	50, // 40: fileupload.v1.FileUploadService.GetStats:input_type -> fileupload.v1.GetStatsRequest
+	13, // 41: fileupload.v1.FileUploadService.Upload:output_type -> fileupload.v1.UploadResponse
+	6,  // 42: fileupload.v1.FileUploadService.UploadBidi:output_type -> fileupload.v1.UploadBidiResponse
+	13, // 43: fileupload.v1.FileUploadService.UploadFile:output_type -> fileupload.v1.UploadResponse
+	15, // 44: fileupload.v1.FileUploadService.GetServerInfo:output_type -> fileupload.v1.GetServerInfoResponse
+	17, // 45: fileupload.v1.FileUploadService.GetFileMetadata:output_type -> fileupload.v1.GetFileMetadataResponse
+	19, // 46: fileupload.v1.FileUploadService.StatFile:output_type -> fileupload.v1.StatFileResponse
+	21, // 47: fileupload.v1.FileUploadService.ListFilesStream:output_type -> fileupload.v1.FileInfo
+	23, // 48: fileupload.v1.FileUploadService.Download:output_type -> fileupload.v1.DownloadResponse
+	25, // 49: fileupload.v1.FileUploadService.GetUploadStatus:output_type -> fileupload.v1.GetUploadStatusResponse
+	27, // 50: fileupload.v1.FileUploadService.GetThumbnail:output_type -> fileupload.v1.GetThumbnailResponse
+	29, // 51: fileupload.v1.FileUploadService.RenameFile:output_type -> fileupload.v1.RenameFileResponse
+	13, // 52: fileupload.v1.FileUploadService.CopyFile:output_type -> fileupload.v1.UploadResponse
+	32, // 53: fileupload.v1.FileUploadService.BeginArchive:output_type -> fileupload.v1.BeginArchiveResponse
+	13, // 54: fileupload.v1.FileUploadService.AddArchiveEntry:output_type -> fileupload.v1.UploadResponse
+	13, // 55: fileupload.v1.FileUploadService.CloseArchive:output_type -> fileupload.v1.UploadResponse
+	37, // 56: fileupload.v1.FileUploadService.GetExpiry:output_type -> fileupload.v1.ExpiryResponse
+	37, // 57: fileupload.v1.FileUploadService.ExtendExpiry:output_type -> fileupload.v1.ExpiryResponse
+	42, // 58: fileupload.v1.FileUploadService.Scrub:output_type -> fileupload.v1.ScrubResponse
+	44, // 59: fileupload.v1.FileUploadService.WatchEvents:output_type -> fileupload.v1.ServerEvent
+	46, // 60: fileupload.v1.FileUploadService.CreateUpload:output_type -> fileupload.v1.UploadSession
+	48, // 61: fileupload.v1.FileUploadService.UploadChunk:output_type -> fileupload.v1.UploadChunkResponse
+	46, // 62: fileupload.v1.FileUploadService.GetUploadSession:output_type -> fileupload.v1.UploadSession
+	51, // 63: fileupload.v1.FileUploadService.GetStats:output_type -> fileupload.v1.GetStatsResponse
+	41, // [41:64] is the sub-list for method output_type
+	18, // [18:41] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
 }
 
 func init() { file_fileupload_v1_fileupload_proto_init() }
@@ -374,19 +4063,32 @@ func file_fileupload_v1_fileupload_proto_init() {
 		(*UploadRequest_Metadata)(nil),
 		(*UploadRequest_Chunk)(nil),
 		(*UploadRequest_FinishCommit)(nil),
+		(*UploadRequest_SegmentedCommit)(nil),
+	}
+	file_fileupload_v1_fileupload_proto_msgTypes[1].OneofWrappers = []any{
+		(*UploadBidiResponse_Ack)(nil),
+		(*UploadBidiResponse_Result)(nil),
+	}
+	file_fileupload_v1_fileupload_proto_msgTypes[6].OneofWrappers = []any{}
+	file_fileupload_v1_fileupload_proto_msgTypes[7].OneofWrappers = []any{}
+	file_fileupload_v1_fileupload_proto_msgTypes[20].OneofWrappers = []any{}
+	file_fileupload_v1_fileupload_proto_msgTypes[37].OneofWrappers = []any{
+		(*ScrubResponse_Progress)(nil),
+		(*ScrubResponse_Summary)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fileupload_v1_fileupload_proto_rawDesc), len(file_fileupload_v1_fileupload_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      5,
+			NumMessages:   48,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_fileupload_v1_fileupload_proto_goTypes,
 		DependencyIndexes: file_fileupload_v1_fileupload_proto_depIdxs,
+		EnumInfos:         file_fileupload_v1_fileupload_proto_enumTypes,
 		MessageInfos:      file_fileupload_v1_fileupload_proto_msgTypes,
 	}.Build()
 	File_fileupload_v1_fileupload_proto = out.File
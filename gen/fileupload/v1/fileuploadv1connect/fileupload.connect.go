@@ -36,9 +36,71 @@ const (
 	// FileUploadServiceUploadProcedure is the fully-qualified name of the FileUploadService's Upload
 	// RPC.
 	FileUploadServiceUploadProcedure = "/fileupload.v1.FileUploadService/Upload"
+	// FileUploadServiceUploadBidiProcedure is the fully-qualified name of the FileUploadService's
+	// UploadBidi RPC.
+	FileUploadServiceUploadBidiProcedure = "/fileupload.v1.FileUploadService/UploadBidi"
 	// FileUploadServiceUploadFileProcedure is the fully-qualified name of the FileUploadService's
 	// UploadFile RPC.
 	FileUploadServiceUploadFileProcedure = "/fileupload.v1.FileUploadService/UploadFile"
+	// FileUploadServiceGetServerInfoProcedure is the fully-qualified name of the FileUploadService's
+	// GetServerInfo RPC.
+	FileUploadServiceGetServerInfoProcedure = "/fileupload.v1.FileUploadService/GetServerInfo"
+	// FileUploadServiceGetFileMetadataProcedure is the fully-qualified name of the FileUploadService's
+	// GetFileMetadata RPC.
+	FileUploadServiceGetFileMetadataProcedure = "/fileupload.v1.FileUploadService/GetFileMetadata"
+	// FileUploadServiceStatFileProcedure is the fully-qualified name of the FileUploadService's
+	// StatFile RPC.
+	FileUploadServiceStatFileProcedure = "/fileupload.v1.FileUploadService/StatFile"
+	// FileUploadServiceListFilesStreamProcedure is the fully-qualified name of the FileUploadService's
+	// ListFilesStream RPC.
+	FileUploadServiceListFilesStreamProcedure = "/fileupload.v1.FileUploadService/ListFilesStream"
+	// FileUploadServiceDownloadProcedure is the fully-qualified name of the FileUploadService's
+	// Download RPC.
+	FileUploadServiceDownloadProcedure = "/fileupload.v1.FileUploadService/Download"
+	// FileUploadServiceGetUploadStatusProcedure is the fully-qualified name of the FileUploadService's
+	// GetUploadStatus RPC.
+	FileUploadServiceGetUploadStatusProcedure = "/fileupload.v1.FileUploadService/GetUploadStatus"
+	// FileUploadServiceGetThumbnailProcedure is the fully-qualified name of the FileUploadService's
+	// GetThumbnail RPC.
+	FileUploadServiceGetThumbnailProcedure = "/fileupload.v1.FileUploadService/GetThumbnail"
+	// FileUploadServiceRenameFileProcedure is the fully-qualified name of the FileUploadService's
+	// RenameFile RPC.
+	FileUploadServiceRenameFileProcedure = "/fileupload.v1.FileUploadService/RenameFile"
+	// FileUploadServiceCopyFileProcedure is the fully-qualified name of the FileUploadService's
+	// CopyFile RPC.
+	FileUploadServiceCopyFileProcedure = "/fileupload.v1.FileUploadService/CopyFile"
+	// FileUploadServiceBeginArchiveProcedure is the fully-qualified name of the FileUploadService's
+	// BeginArchive RPC.
+	FileUploadServiceBeginArchiveProcedure = "/fileupload.v1.FileUploadService/BeginArchive"
+	// FileUploadServiceAddArchiveEntryProcedure is the fully-qualified name of the FileUploadService's
+	// AddArchiveEntry RPC.
+	FileUploadServiceAddArchiveEntryProcedure = "/fileupload.v1.FileUploadService/AddArchiveEntry"
+	// FileUploadServiceCloseArchiveProcedure is the fully-qualified name of the FileUploadService's
+	// CloseArchive RPC.
+	FileUploadServiceCloseArchiveProcedure = "/fileupload.v1.FileUploadService/CloseArchive"
+	// FileUploadServiceGetExpiryProcedure is the fully-qualified name of the FileUploadService's
+	// GetExpiry RPC.
+	FileUploadServiceGetExpiryProcedure = "/fileupload.v1.FileUploadService/GetExpiry"
+	// FileUploadServiceExtendExpiryProcedure is the fully-qualified name of the FileUploadService's
+	// ExtendExpiry RPC.
+	FileUploadServiceExtendExpiryProcedure = "/fileupload.v1.FileUploadService/ExtendExpiry"
+	// FileUploadServiceScrubProcedure is the fully-qualified name of the FileUploadService's Scrub RPC.
+	FileUploadServiceScrubProcedure = "/fileupload.v1.FileUploadService/Scrub"
+	// FileUploadServiceWatchEventsProcedure is the fully-qualified name of the FileUploadService's
+	// WatchEvents RPC.
+	FileUploadServiceWatchEventsProcedure = "/fileupload.v1.FileUploadService/WatchEvents"
+	// FileUploadServiceCreateUploadProcedure is the fully-qualified name of the FileUploadService's
+	// CreateUpload RPC.
+	FileUploadServiceCreateUploadProcedure = "/fileupload.v1.FileUploadService/CreateUpload"
+	// FileUploadServiceUploadChunkProcedure is the fully-qualified name of the FileUploadService's
+	// UploadChunk RPC.
+	FileUploadServiceUploadChunkProcedure = "/fileupload.v1.FileUploadService/UploadChunk"
+	// FileUploadServiceGetUploadSessionProcedure is the fully-qualified name of the FileUploadService's
+	// GetUploadSession RPC.
+	FileUploadServiceGetUploadSessionProcedure = "/fileupload.v1.FileUploadService/GetUploadSession"
+	// FileUploadServiceGetStatsProcedure is the fully-qualified name of the FileUploadService's
+	// GetStats RPC.
+	FileUploadServiceGetStatsProcedure = "/fileupload.v1.FileUploadService/GetStats"
 )
 
 // FileUploadServiceClient is a client for the fileupload.v1.FileUploadService service.
@@ -46,8 +108,65 @@ type FileUploadServiceClient interface {
 	// Streaming upload for native clients (Go, etc.)
 	// Protocol: 1) metadata, 2) chunks..., 3) finish_commit
 	Upload(context.Context) (*connect.ClientStreamForClientSimple[v1.UploadRequest, v1.UploadResponse], error)
+	// Same protocol as Upload, but the server acknowledges written chunks with
+	// the offset it holds, so the client can bound the data in flight. Needs
+	// HTTP/2 end to end.
+	UploadBidi(context.Context) (*connect.BidiStreamForClientSimple[v1.UploadRequest, v1.UploadBidiResponse], error)
 	// Unary upload for browser clients (Fetch API doesn't support client streaming)
 	UploadFile(context.Context, *v1.UploadFileRequest) (*v1.UploadResponse, error)
+	// Server version and capabilities (cacheable, may be called with HTTP GET)
+	GetServerInfo(context.Context, *v1.GetServerInfoRequest) (*v1.GetServerInfoResponse, error)
+	// Size, hash and modification time of a stored file (cacheable, may be called with HTTP GET)
+	GetFileMetadata(context.Context, *v1.GetFileMetadataRequest) (*v1.GetFileMetadataResponse, error)
+	// Whether a file is stored under a name, with its size, hash and
+	// modification time. Unlike GetFileMetadata a missing file is not an error
+	// and the hash comes from the server's index instead of reading the file.
+	StatFile(context.Context, *v1.StatFileRequest) (*v1.StatFileResponse, error)
+	// Streams one FileInfo per stored file as the upload directories are read,
+	// in no particular order, so listing millions of files never builds them
+	// all into one message
+	ListFilesStream(context.Context, *v1.ListFilesRequest) (*connect.ServerStreamForClient[v1.FileInfo], error)
+	// Streams a stored file back in chunks, from offset when set
+	Download(context.Context, *v1.DownloadRequest) (*connect.ServerStreamForClient[v1.DownloadResponse], error)
+	// How much of a chunked UploadFile upload the server holds, so an
+	// interrupted client can resume from there
+	GetUploadStatus(context.Context, *v1.GetUploadStatusRequest) (*v1.GetUploadStatusResponse, error)
+	// JPEG thumbnail of a stored image, when the server generates thumbnails
+	// (cacheable, may be called with HTTP GET)
+	GetThumbnail(context.Context, *v1.GetThumbnailRequest) (*v1.GetThumbnailResponse, error)
+	// Renames a stored file without re-uploading it
+	RenameFile(context.Context, *v1.RenameFileRequest) (*v1.RenameFileResponse, error)
+	// Stores a copy of a stored file under a new name without re-uploading it
+	CopyFile(context.Context, *v1.CopyFileRequest) (*v1.UploadResponse, error)
+	// Starts an archive bundling several files into one stored tar or zip file:
+	// AddArchiveEntry adds each file and CloseArchive stores the archive
+	BeginArchive(context.Context, *v1.BeginArchiveRequest) (*v1.BeginArchiveResponse, error)
+	// Adds one file to an archive started with BeginArchive
+	AddArchiveEntry(context.Context, *v1.AddArchiveEntryRequest) (*v1.UploadResponse, error)
+	// Writes the archive and stores it atomically under its filename
+	CloseArchive(context.Context, *v1.CloseArchiveRequest) (*v1.UploadResponse, error)
+	// When a stored file uploaded with a TTL or expiry time will be deleted
+	GetExpiry(context.Context, *v1.GetExpiryRequest) (*v1.ExpiryResponse, error)
+	// Postpones the deletion of a stored file uploaded with a TTL or expiry time
+	ExtendExpiry(context.Context, *v1.ExtendExpiryRequest) (*v1.ExpiryResponse, error)
+	// Re-hashes every stored file in the hash index and reports those whose
+	// content no longer matches it, streaming progress for each file
+	Scrub(context.Context, *v1.ScrubRequest) (*connect.ServerStreamForClient[v1.ScrubResponse], error)
+	// Streams upload, deletion and failed upload events as they happen, until
+	// the client cancels or the server stops
+	WatchEvents(context.Context, *v1.WatchEventsRequest) (*connect.ServerStreamForClient[v1.ServerEvent], error)
+	// Starts a resumable upload session of a known size; UploadChunk sends its
+	// content. Every call of a session should carry the Upload-Session header
+	// so a load balancer can route them all to the same server.
+	CreateUpload(context.Context, *v1.CreateUploadRequest) (*v1.UploadSession, error)
+	// Appends a chunk to a session; the last one stores the file
+	UploadChunk(context.Context, *v1.UploadChunkRequest) (*v1.UploadChunkResponse, error)
+	// How much of a session the server holds, to resume it
+	GetUploadSession(context.Context, *v1.GetUploadSessionRequest) (*v1.UploadSession, error)
+	// Totals of the stored files the caller can list, overall and per
+	// directory, with the largest ones. Computed at most every few seconds, so
+	// the latest uploads may be missing (cacheable, may be called with HTTP GET)
+	GetStats(context.Context, *v1.GetStatsRequest) (*v1.GetStatsResponse, error)
 }
 
 // NewFileUploadServiceClient constructs a client for the fileupload.v1.FileUploadService service.
@@ -67,19 +186,174 @@ func NewFileUploadServiceClient(httpClient connect.HTTPClient, baseURL string, o
 			connect.WithSchema(fileUploadServiceMethods.ByName("Upload")),
 			connect.WithClientOptions(opts...),
 		),
+		uploadBidi: connect.NewClient[v1.UploadRequest, v1.UploadBidiResponse](
+			httpClient,
+			baseURL+FileUploadServiceUploadBidiProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("UploadBidi")),
+			connect.WithClientOptions(opts...),
+		),
 		uploadFile: connect.NewClient[v1.UploadFileRequest, v1.UploadResponse](
 			httpClient,
 			baseURL+FileUploadServiceUploadFileProcedure,
 			connect.WithSchema(fileUploadServiceMethods.ByName("UploadFile")),
 			connect.WithClientOptions(opts...),
 		),
+		getServerInfo: connect.NewClient[v1.GetServerInfoRequest, v1.GetServerInfoResponse](
+			httpClient,
+			baseURL+FileUploadServiceGetServerInfoProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("GetServerInfo")),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		getFileMetadata: connect.NewClient[v1.GetFileMetadataRequest, v1.GetFileMetadataResponse](
+			httpClient,
+			baseURL+FileUploadServiceGetFileMetadataProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("GetFileMetadata")),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		statFile: connect.NewClient[v1.StatFileRequest, v1.StatFileResponse](
+			httpClient,
+			baseURL+FileUploadServiceStatFileProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("StatFile")),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		listFilesStream: connect.NewClient[v1.ListFilesRequest, v1.FileInfo](
+			httpClient,
+			baseURL+FileUploadServiceListFilesStreamProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("ListFilesStream")),
+			connect.WithClientOptions(opts...),
+		),
+		download: connect.NewClient[v1.DownloadRequest, v1.DownloadResponse](
+			httpClient,
+			baseURL+FileUploadServiceDownloadProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("Download")),
+			connect.WithClientOptions(opts...),
+		),
+		getUploadStatus: connect.NewClient[v1.GetUploadStatusRequest, v1.GetUploadStatusResponse](
+			httpClient,
+			baseURL+FileUploadServiceGetUploadStatusProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("GetUploadStatus")),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		getThumbnail: connect.NewClient[v1.GetThumbnailRequest, v1.GetThumbnailResponse](
+			httpClient,
+			baseURL+FileUploadServiceGetThumbnailProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("GetThumbnail")),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		renameFile: connect.NewClient[v1.RenameFileRequest, v1.RenameFileResponse](
+			httpClient,
+			baseURL+FileUploadServiceRenameFileProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("RenameFile")),
+			connect.WithClientOptions(opts...),
+		),
+		copyFile: connect.NewClient[v1.CopyFileRequest, v1.UploadResponse](
+			httpClient,
+			baseURL+FileUploadServiceCopyFileProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("CopyFile")),
+			connect.WithClientOptions(opts...),
+		),
+		beginArchive: connect.NewClient[v1.BeginArchiveRequest, v1.BeginArchiveResponse](
+			httpClient,
+			baseURL+FileUploadServiceBeginArchiveProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("BeginArchive")),
+			connect.WithClientOptions(opts...),
+		),
+		addArchiveEntry: connect.NewClient[v1.AddArchiveEntryRequest, v1.UploadResponse](
+			httpClient,
+			baseURL+FileUploadServiceAddArchiveEntryProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("AddArchiveEntry")),
+			connect.WithClientOptions(opts...),
+		),
+		closeArchive: connect.NewClient[v1.CloseArchiveRequest, v1.UploadResponse](
+			httpClient,
+			baseURL+FileUploadServiceCloseArchiveProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("CloseArchive")),
+			connect.WithClientOptions(opts...),
+		),
+		getExpiry: connect.NewClient[v1.GetExpiryRequest, v1.ExpiryResponse](
+			httpClient,
+			baseURL+FileUploadServiceGetExpiryProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("GetExpiry")),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		extendExpiry: connect.NewClient[v1.ExtendExpiryRequest, v1.ExpiryResponse](
+			httpClient,
+			baseURL+FileUploadServiceExtendExpiryProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("ExtendExpiry")),
+			connect.WithClientOptions(opts...),
+		),
+		scrub: connect.NewClient[v1.ScrubRequest, v1.ScrubResponse](
+			httpClient,
+			baseURL+FileUploadServiceScrubProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("Scrub")),
+			connect.WithClientOptions(opts...),
+		),
+		watchEvents: connect.NewClient[v1.WatchEventsRequest, v1.ServerEvent](
+			httpClient,
+			baseURL+FileUploadServiceWatchEventsProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("WatchEvents")),
+			connect.WithClientOptions(opts...),
+		),
+		createUpload: connect.NewClient[v1.CreateUploadRequest, v1.UploadSession](
+			httpClient,
+			baseURL+FileUploadServiceCreateUploadProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("CreateUpload")),
+			connect.WithClientOptions(opts...),
+		),
+		uploadChunk: connect.NewClient[v1.UploadChunkRequest, v1.UploadChunkResponse](
+			httpClient,
+			baseURL+FileUploadServiceUploadChunkProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("UploadChunk")),
+			connect.WithClientOptions(opts...),
+		),
+		getUploadSession: connect.NewClient[v1.GetUploadSessionRequest, v1.UploadSession](
+			httpClient,
+			baseURL+FileUploadServiceGetUploadSessionProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("GetUploadSession")),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		getStats: connect.NewClient[v1.GetStatsRequest, v1.GetStatsResponse](
+			httpClient,
+			baseURL+FileUploadServiceGetStatsProcedure,
+			connect.WithSchema(fileUploadServiceMethods.ByName("GetStats")),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
 	}
 }
 
 // fileUploadServiceClient implements FileUploadServiceClient.
 type fileUploadServiceClient struct {
-	upload     *connect.Client[v1.UploadRequest, v1.UploadResponse]
-	uploadFile *connect.Client[v1.UploadFileRequest, v1.UploadResponse]
+	upload           *connect.Client[v1.UploadRequest, v1.UploadResponse]
+	uploadBidi       *connect.Client[v1.UploadRequest, v1.UploadBidiResponse]
+	uploadFile       *connect.Client[v1.UploadFileRequest, v1.UploadResponse]
+	getServerInfo    *connect.Client[v1.GetServerInfoRequest, v1.GetServerInfoResponse]
+	getFileMetadata  *connect.Client[v1.GetFileMetadataRequest, v1.GetFileMetadataResponse]
+	statFile         *connect.Client[v1.StatFileRequest, v1.StatFileResponse]
+	listFilesStream  *connect.Client[v1.ListFilesRequest, v1.FileInfo]
+	download         *connect.Client[v1.DownloadRequest, v1.DownloadResponse]
+	getUploadStatus  *connect.Client[v1.GetUploadStatusRequest, v1.GetUploadStatusResponse]
+	getThumbnail     *connect.Client[v1.GetThumbnailRequest, v1.GetThumbnailResponse]
+	renameFile       *connect.Client[v1.RenameFileRequest, v1.RenameFileResponse]
+	copyFile         *connect.Client[v1.CopyFileRequest, v1.UploadResponse]
+	beginArchive     *connect.Client[v1.BeginArchiveRequest, v1.BeginArchiveResponse]
+	addArchiveEntry  *connect.Client[v1.AddArchiveEntryRequest, v1.UploadResponse]
+	closeArchive     *connect.Client[v1.CloseArchiveRequest, v1.UploadResponse]
+	getExpiry        *connect.Client[v1.GetExpiryRequest, v1.ExpiryResponse]
+	extendExpiry     *connect.Client[v1.ExtendExpiryRequest, v1.ExpiryResponse]
+	scrub            *connect.Client[v1.ScrubRequest, v1.ScrubResponse]
+	watchEvents      *connect.Client[v1.WatchEventsRequest, v1.ServerEvent]
+	createUpload     *connect.Client[v1.CreateUploadRequest, v1.UploadSession]
+	uploadChunk      *connect.Client[v1.UploadChunkRequest, v1.UploadChunkResponse]
+	getUploadSession *connect.Client[v1.GetUploadSessionRequest, v1.UploadSession]
+	getStats         *connect.Client[v1.GetStatsRequest, v1.GetStatsResponse]
 }
 
 // Upload calls fileupload.v1.FileUploadService.Upload.
@@ -87,6 +361,11 @@ func (c *fileUploadServiceClient) Upload(ctx context.Context) (*connect.ClientSt
 	return c.upload.CallClientStreamSimple(ctx)
 }
 
+// UploadBidi calls fileupload.v1.FileUploadService.UploadBidi.
+func (c *fileUploadServiceClient) UploadBidi(ctx context.Context) (*connect.BidiStreamForClientSimple[v1.UploadRequest, v1.UploadBidiResponse], error) {
+	return c.uploadBidi.CallBidiStreamSimple(ctx)
+}
+
 // UploadFile calls fileupload.v1.FileUploadService.UploadFile.
 func (c *fileUploadServiceClient) UploadFile(ctx context.Context, req *v1.UploadFileRequest) (*v1.UploadResponse, error) {
 	response, err := c.uploadFile.CallUnary(ctx, connect.NewRequest(req))
@@ -96,13 +375,234 @@ func (c *fileUploadServiceClient) UploadFile(ctx context.Context, req *v1.Upload
 	return nil, err
 }
 
+// GetServerInfo calls fileupload.v1.FileUploadService.GetServerInfo.
+func (c *fileUploadServiceClient) GetServerInfo(ctx context.Context, req *v1.GetServerInfoRequest) (*v1.GetServerInfoResponse, error) {
+	response, err := c.getServerInfo.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// GetFileMetadata calls fileupload.v1.FileUploadService.GetFileMetadata.
+func (c *fileUploadServiceClient) GetFileMetadata(ctx context.Context, req *v1.GetFileMetadataRequest) (*v1.GetFileMetadataResponse, error) {
+	response, err := c.getFileMetadata.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// StatFile calls fileupload.v1.FileUploadService.StatFile.
+func (c *fileUploadServiceClient) StatFile(ctx context.Context, req *v1.StatFileRequest) (*v1.StatFileResponse, error) {
+	response, err := c.statFile.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// ListFilesStream calls fileupload.v1.FileUploadService.ListFilesStream.
+func (c *fileUploadServiceClient) ListFilesStream(ctx context.Context, req *v1.ListFilesRequest) (*connect.ServerStreamForClient[v1.FileInfo], error) {
+	return c.listFilesStream.CallServerStream(ctx, connect.NewRequest(req))
+}
+
+// Download calls fileupload.v1.FileUploadService.Download.
+func (c *fileUploadServiceClient) Download(ctx context.Context, req *v1.DownloadRequest) (*connect.ServerStreamForClient[v1.DownloadResponse], error) {
+	return c.download.CallServerStream(ctx, connect.NewRequest(req))
+}
+
+// GetUploadStatus calls fileupload.v1.FileUploadService.GetUploadStatus.
+func (c *fileUploadServiceClient) GetUploadStatus(ctx context.Context, req *v1.GetUploadStatusRequest) (*v1.GetUploadStatusResponse, error) {
+	response, err := c.getUploadStatus.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// GetThumbnail calls fileupload.v1.FileUploadService.GetThumbnail.
+func (c *fileUploadServiceClient) GetThumbnail(ctx context.Context, req *v1.GetThumbnailRequest) (*v1.GetThumbnailResponse, error) {
+	response, err := c.getThumbnail.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// RenameFile calls fileupload.v1.FileUploadService.RenameFile.
+func (c *fileUploadServiceClient) RenameFile(ctx context.Context, req *v1.RenameFileRequest) (*v1.RenameFileResponse, error) {
+	response, err := c.renameFile.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// CopyFile calls fileupload.v1.FileUploadService.CopyFile.
+func (c *fileUploadServiceClient) CopyFile(ctx context.Context, req *v1.CopyFileRequest) (*v1.UploadResponse, error) {
+	response, err := c.copyFile.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// BeginArchive calls fileupload.v1.FileUploadService.BeginArchive.
+func (c *fileUploadServiceClient) BeginArchive(ctx context.Context, req *v1.BeginArchiveRequest) (*v1.BeginArchiveResponse, error) {
+	response, err := c.beginArchive.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// AddArchiveEntry calls fileupload.v1.FileUploadService.AddArchiveEntry.
+func (c *fileUploadServiceClient) AddArchiveEntry(ctx context.Context, req *v1.AddArchiveEntryRequest) (*v1.UploadResponse, error) {
+	response, err := c.addArchiveEntry.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// CloseArchive calls fileupload.v1.FileUploadService.CloseArchive.
+func (c *fileUploadServiceClient) CloseArchive(ctx context.Context, req *v1.CloseArchiveRequest) (*v1.UploadResponse, error) {
+	response, err := c.closeArchive.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// GetExpiry calls fileupload.v1.FileUploadService.GetExpiry.
+func (c *fileUploadServiceClient) GetExpiry(ctx context.Context, req *v1.GetExpiryRequest) (*v1.ExpiryResponse, error) {
+	response, err := c.getExpiry.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// ExtendExpiry calls fileupload.v1.FileUploadService.ExtendExpiry.
+func (c *fileUploadServiceClient) ExtendExpiry(ctx context.Context, req *v1.ExtendExpiryRequest) (*v1.ExpiryResponse, error) {
+	response, err := c.extendExpiry.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// Scrub calls fileupload.v1.FileUploadService.Scrub.
+func (c *fileUploadServiceClient) Scrub(ctx context.Context, req *v1.ScrubRequest) (*connect.ServerStreamForClient[v1.ScrubResponse], error) {
+	return c.scrub.CallServerStream(ctx, connect.NewRequest(req))
+}
+
+// WatchEvents calls fileupload.v1.FileUploadService.WatchEvents.
+func (c *fileUploadServiceClient) WatchEvents(ctx context.Context, req *v1.WatchEventsRequest) (*connect.ServerStreamForClient[v1.ServerEvent], error) {
+	return c.watchEvents.CallServerStream(ctx, connect.NewRequest(req))
+}
+
+// CreateUpload calls fileupload.v1.FileUploadService.CreateUpload.
+func (c *fileUploadServiceClient) CreateUpload(ctx context.Context, req *v1.CreateUploadRequest) (*v1.UploadSession, error) {
+	response, err := c.createUpload.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// UploadChunk calls fileupload.v1.FileUploadService.UploadChunk.
+func (c *fileUploadServiceClient) UploadChunk(ctx context.Context, req *v1.UploadChunkRequest) (*v1.UploadChunkResponse, error) {
+	response, err := c.uploadChunk.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// GetUploadSession calls fileupload.v1.FileUploadService.GetUploadSession.
+func (c *fileUploadServiceClient) GetUploadSession(ctx context.Context, req *v1.GetUploadSessionRequest) (*v1.UploadSession, error) {
+	response, err := c.getUploadSession.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
+// GetStats calls fileupload.v1.FileUploadService.GetStats.
+func (c *fileUploadServiceClient) GetStats(ctx context.Context, req *v1.GetStatsRequest) (*v1.GetStatsResponse, error) {
+	response, err := c.getStats.CallUnary(ctx, connect.NewRequest(req))
+	if response != nil {
+		return response.Msg, err
+	}
+	return nil, err
+}
+
 // FileUploadServiceHandler is an implementation of the fileupload.v1.FileUploadService service.
 type FileUploadServiceHandler interface {
 	// Streaming upload for native clients (Go, etc.)
 	// Protocol: 1) metadata, 2) chunks..., 3) finish_commit
 	Upload(context.Context, *connect.ClientStream[v1.UploadRequest]) (*v1.UploadResponse, error)
+	// Same protocol as Upload, but the server acknowledges written chunks with
+	// the offset it holds, so the client can bound the data in flight. Needs
+	// HTTP/2 end to end.
+	UploadBidi(context.Context, *connect.BidiStream[v1.UploadRequest, v1.UploadBidiResponse]) error
 	// Unary upload for browser clients (Fetch API doesn't support client streaming)
 	UploadFile(context.Context, *v1.UploadFileRequest) (*v1.UploadResponse, error)
+	// Server version and capabilities (cacheable, may be called with HTTP GET)
+	GetServerInfo(context.Context, *v1.GetServerInfoRequest) (*v1.GetServerInfoResponse, error)
+	// Size, hash and modification time of a stored file (cacheable, may be called with HTTP GET)
+	GetFileMetadata(context.Context, *v1.GetFileMetadataRequest) (*v1.GetFileMetadataResponse, error)
+	// Whether a file is stored under a name, with its size, hash and
+	// modification time. Unlike GetFileMetadata a missing file is not an error
+	// and the hash comes from the server's index instead of reading the file.
+	StatFile(context.Context, *v1.StatFileRequest) (*v1.StatFileResponse, error)
+	// Streams one FileInfo per stored file as the upload directories are read,
+	// in no particular order, so listing millions of files never builds them
+	// all into one message
+	ListFilesStream(context.Context, *v1.ListFilesRequest, *connect.ServerStream[v1.FileInfo]) error
+	// Streams a stored file back in chunks, from offset when set
+	Download(context.Context, *v1.DownloadRequest, *connect.ServerStream[v1.DownloadResponse]) error
+	// How much of a chunked UploadFile upload the server holds, so an
+	// interrupted client can resume from there
+	GetUploadStatus(context.Context, *v1.GetUploadStatusRequest) (*v1.GetUploadStatusResponse, error)
+	// JPEG thumbnail of a stored image, when the server generates thumbnails
+	// (cacheable, may be called with HTTP GET)
+	GetThumbnail(context.Context, *v1.GetThumbnailRequest) (*v1.GetThumbnailResponse, error)
+	// Renames a stored file without re-uploading it
+	RenameFile(context.Context, *v1.RenameFileRequest) (*v1.RenameFileResponse, error)
+	// Stores a copy of a stored file under a new name without re-uploading it
+	CopyFile(context.Context, *v1.CopyFileRequest) (*v1.UploadResponse, error)
+	// Starts an archive bundling several files into one stored tar or zip file:
+	// AddArchiveEntry adds each file and CloseArchive stores the archive
+	BeginArchive(context.Context, *v1.BeginArchiveRequest) (*v1.BeginArchiveResponse, error)
+	// Adds one file to an archive started with BeginArchive
+	AddArchiveEntry(context.Context, *v1.AddArchiveEntryRequest) (*v1.UploadResponse, error)
+	// Writes the archive and stores it atomically under its filename
+	CloseArchive(context.Context, *v1.CloseArchiveRequest) (*v1.UploadResponse, error)
+	// When a stored file uploaded with a TTL or expiry time will be deleted
+	GetExpiry(context.Context, *v1.GetExpiryRequest) (*v1.ExpiryResponse, error)
+	// Postpones the deletion of a stored file uploaded with a TTL or expiry time
+	ExtendExpiry(context.Context, *v1.ExtendExpiryRequest) (*v1.ExpiryResponse, error)
+	// Re-hashes every stored file in the hash index and reports those whose
+	// content no longer matches it, streaming progress for each file
+	Scrub(context.Context, *v1.ScrubRequest, *connect.ServerStream[v1.ScrubResponse]) error
+	// Streams upload, deletion and failed upload events as they happen, until
+	// the client cancels or the server stops
+	WatchEvents(context.Context, *v1.WatchEventsRequest, *connect.ServerStream[v1.ServerEvent]) error
+	// Starts a resumable upload session of a known size; UploadChunk sends its
+	// content. Every call of a session should carry the Upload-Session header
+	// so a load balancer can route them all to the same server.
+	CreateUpload(context.Context, *v1.CreateUploadRequest) (*v1.UploadSession, error)
+	// Appends a chunk to a session; the last one stores the file
+	UploadChunk(context.Context, *v1.UploadChunkRequest) (*v1.UploadChunkResponse, error)
+	// How much of a session the server holds, to resume it
+	GetUploadSession(context.Context, *v1.GetUploadSessionRequest) (*v1.UploadSession, error)
+	// Totals of the stored files the caller can list, overall and per
+	// directory, with the largest ones. Computed at most every few seconds, so
+	// the latest uploads may be missing (cacheable, may be called with HTTP GET)
+	GetStats(context.Context, *v1.GetStatsRequest) (*v1.GetStatsResponse, error)
 }
 
 // NewFileUploadServiceHandler builds an HTTP handler from the service implementation. It returns
@@ -118,18 +618,194 @@ func NewFileUploadServiceHandler(svc FileUploadServiceHandler, opts ...connect.H
 		connect.WithSchema(fileUploadServiceMethods.ByName("Upload")),
 		connect.WithHandlerOptions(opts...),
 	)
+	fileUploadServiceUploadBidiHandler := connect.NewBidiStreamHandler(
+		FileUploadServiceUploadBidiProcedure,
+		svc.UploadBidi,
+		connect.WithSchema(fileUploadServiceMethods.ByName("UploadBidi")),
+		connect.WithHandlerOptions(opts...),
+	)
 	fileUploadServiceUploadFileHandler := connect.NewUnaryHandlerSimple(
 		FileUploadServiceUploadFileProcedure,
 		svc.UploadFile,
 		connect.WithSchema(fileUploadServiceMethods.ByName("UploadFile")),
 		connect.WithHandlerOptions(opts...),
 	)
+	fileUploadServiceGetServerInfoHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceGetServerInfoProcedure,
+		svc.GetServerInfo,
+		connect.WithSchema(fileUploadServiceMethods.ByName("GetServerInfo")),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceGetFileMetadataHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceGetFileMetadataProcedure,
+		svc.GetFileMetadata,
+		connect.WithSchema(fileUploadServiceMethods.ByName("GetFileMetadata")),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceStatFileHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceStatFileProcedure,
+		svc.StatFile,
+		connect.WithSchema(fileUploadServiceMethods.ByName("StatFile")),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceListFilesStreamHandler := connect.NewServerStreamHandlerSimple(
+		FileUploadServiceListFilesStreamProcedure,
+		svc.ListFilesStream,
+		connect.WithSchema(fileUploadServiceMethods.ByName("ListFilesStream")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceDownloadHandler := connect.NewServerStreamHandlerSimple(
+		FileUploadServiceDownloadProcedure,
+		svc.Download,
+		connect.WithSchema(fileUploadServiceMethods.ByName("Download")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceGetUploadStatusHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceGetUploadStatusProcedure,
+		svc.GetUploadStatus,
+		connect.WithSchema(fileUploadServiceMethods.ByName("GetUploadStatus")),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceGetThumbnailHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceGetThumbnailProcedure,
+		svc.GetThumbnail,
+		connect.WithSchema(fileUploadServiceMethods.ByName("GetThumbnail")),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceRenameFileHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceRenameFileProcedure,
+		svc.RenameFile,
+		connect.WithSchema(fileUploadServiceMethods.ByName("RenameFile")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceCopyFileHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceCopyFileProcedure,
+		svc.CopyFile,
+		connect.WithSchema(fileUploadServiceMethods.ByName("CopyFile")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceBeginArchiveHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceBeginArchiveProcedure,
+		svc.BeginArchive,
+		connect.WithSchema(fileUploadServiceMethods.ByName("BeginArchive")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceAddArchiveEntryHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceAddArchiveEntryProcedure,
+		svc.AddArchiveEntry,
+		connect.WithSchema(fileUploadServiceMethods.ByName("AddArchiveEntry")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceCloseArchiveHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceCloseArchiveProcedure,
+		svc.CloseArchive,
+		connect.WithSchema(fileUploadServiceMethods.ByName("CloseArchive")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceGetExpiryHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceGetExpiryProcedure,
+		svc.GetExpiry,
+		connect.WithSchema(fileUploadServiceMethods.ByName("GetExpiry")),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceExtendExpiryHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceExtendExpiryProcedure,
+		svc.ExtendExpiry,
+		connect.WithSchema(fileUploadServiceMethods.ByName("ExtendExpiry")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceScrubHandler := connect.NewServerStreamHandlerSimple(
+		FileUploadServiceScrubProcedure,
+		svc.Scrub,
+		connect.WithSchema(fileUploadServiceMethods.ByName("Scrub")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceWatchEventsHandler := connect.NewServerStreamHandlerSimple(
+		FileUploadServiceWatchEventsProcedure,
+		svc.WatchEvents,
+		connect.WithSchema(fileUploadServiceMethods.ByName("WatchEvents")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceCreateUploadHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceCreateUploadProcedure,
+		svc.CreateUpload,
+		connect.WithSchema(fileUploadServiceMethods.ByName("CreateUpload")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceUploadChunkHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceUploadChunkProcedure,
+		svc.UploadChunk,
+		connect.WithSchema(fileUploadServiceMethods.ByName("UploadChunk")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceGetUploadSessionHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceGetUploadSessionProcedure,
+		svc.GetUploadSession,
+		connect.WithSchema(fileUploadServiceMethods.ByName("GetUploadSession")),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileUploadServiceGetStatsHandler := connect.NewUnaryHandlerSimple(
+		FileUploadServiceGetStatsProcedure,
+		svc.GetStats,
+		connect.WithSchema(fileUploadServiceMethods.ByName("GetStats")),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
 	return "/fileupload.v1.FileUploadService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case FileUploadServiceUploadProcedure:
 			fileUploadServiceUploadHandler.ServeHTTP(w, r)
+		case FileUploadServiceUploadBidiProcedure:
+			fileUploadServiceUploadBidiHandler.ServeHTTP(w, r)
 		case FileUploadServiceUploadFileProcedure:
 			fileUploadServiceUploadFileHandler.ServeHTTP(w, r)
+		case FileUploadServiceGetServerInfoProcedure:
+			fileUploadServiceGetServerInfoHandler.ServeHTTP(w, r)
+		case FileUploadServiceGetFileMetadataProcedure:
+			fileUploadServiceGetFileMetadataHandler.ServeHTTP(w, r)
+		case FileUploadServiceStatFileProcedure:
+			fileUploadServiceStatFileHandler.ServeHTTP(w, r)
+		case FileUploadServiceListFilesStreamProcedure:
+			fileUploadServiceListFilesStreamHandler.ServeHTTP(w, r)
+		case FileUploadServiceDownloadProcedure:
+			fileUploadServiceDownloadHandler.ServeHTTP(w, r)
+		case FileUploadServiceGetUploadStatusProcedure:
+			fileUploadServiceGetUploadStatusHandler.ServeHTTP(w, r)
+		case FileUploadServiceGetThumbnailProcedure:
+			fileUploadServiceGetThumbnailHandler.ServeHTTP(w, r)
+		case FileUploadServiceRenameFileProcedure:
+			fileUploadServiceRenameFileHandler.ServeHTTP(w, r)
+		case FileUploadServiceCopyFileProcedure:
+			fileUploadServiceCopyFileHandler.ServeHTTP(w, r)
+		case FileUploadServiceBeginArchiveProcedure:
+			fileUploadServiceBeginArchiveHandler.ServeHTTP(w, r)
+		case FileUploadServiceAddArchiveEntryProcedure:
+			fileUploadServiceAddArchiveEntryHandler.ServeHTTP(w, r)
+		case FileUploadServiceCloseArchiveProcedure:
+			fileUploadServiceCloseArchiveHandler.ServeHTTP(w, r)
+		case FileUploadServiceGetExpiryProcedure:
+			fileUploadServiceGetExpiryHandler.ServeHTTP(w, r)
+		case FileUploadServiceExtendExpiryProcedure:
+			fileUploadServiceExtendExpiryHandler.ServeHTTP(w, r)
+		case FileUploadServiceScrubProcedure:
+			fileUploadServiceScrubHandler.ServeHTTP(w, r)
+		case FileUploadServiceWatchEventsProcedure:
+			fileUploadServiceWatchEventsHandler.ServeHTTP(w, r)
+		case FileUploadServiceCreateUploadProcedure:
+			fileUploadServiceCreateUploadHandler.ServeHTTP(w, r)
+		case FileUploadServiceUploadChunkProcedure:
+			fileUploadServiceUploadChunkHandler.ServeHTTP(w, r)
+		case FileUploadServiceGetUploadSessionProcedure:
+			fileUploadServiceGetUploadSessionHandler.ServeHTTP(w, r)
+		case FileUploadServiceGetStatsProcedure:
+			fileUploadServiceGetStatsHandler.ServeHTTP(w, r)
 		default:
 			http.NotFound(w, r)
 		}
@@ -143,6 +819,90 @@ func (UnimplementedFileUploadServiceHandler) Upload(context.Context, *connect.Cl
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.Upload is not implemented"))
 }
 
+func (UnimplementedFileUploadServiceHandler) UploadBidi(context.Context, *connect.BidiStream[v1.UploadRequest, v1.UploadBidiResponse]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.UploadBidi is not implemented"))
+}
+
 func (UnimplementedFileUploadServiceHandler) UploadFile(context.Context, *v1.UploadFileRequest) (*v1.UploadResponse, error) {
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.UploadFile is not implemented"))
 }
+
+func (UnimplementedFileUploadServiceHandler) GetServerInfo(context.Context, *v1.GetServerInfoRequest) (*v1.GetServerInfoResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.GetServerInfo is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) GetFileMetadata(context.Context, *v1.GetFileMetadataRequest) (*v1.GetFileMetadataResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.GetFileMetadata is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) StatFile(context.Context, *v1.StatFileRequest) (*v1.StatFileResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.StatFile is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) ListFilesStream(context.Context, *v1.ListFilesRequest, *connect.ServerStream[v1.FileInfo]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.ListFilesStream is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) Download(context.Context, *v1.DownloadRequest, *connect.ServerStream[v1.DownloadResponse]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.Download is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) GetUploadStatus(context.Context, *v1.GetUploadStatusRequest) (*v1.GetUploadStatusResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.GetUploadStatus is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) GetThumbnail(context.Context, *v1.GetThumbnailRequest) (*v1.GetThumbnailResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.GetThumbnail is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) RenameFile(context.Context, *v1.RenameFileRequest) (*v1.RenameFileResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.RenameFile is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) CopyFile(context.Context, *v1.CopyFileRequest) (*v1.UploadResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.CopyFile is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) BeginArchive(context.Context, *v1.BeginArchiveRequest) (*v1.BeginArchiveResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.BeginArchive is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) AddArchiveEntry(context.Context, *v1.AddArchiveEntryRequest) (*v1.UploadResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.AddArchiveEntry is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) CloseArchive(context.Context, *v1.CloseArchiveRequest) (*v1.UploadResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.CloseArchive is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) GetExpiry(context.Context, *v1.GetExpiryRequest) (*v1.ExpiryResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.GetExpiry is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) ExtendExpiry(context.Context, *v1.ExtendExpiryRequest) (*v1.ExpiryResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.ExtendExpiry is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) Scrub(context.Context, *v1.ScrubRequest, *connect.ServerStream[v1.ScrubResponse]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.Scrub is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) WatchEvents(context.Context, *v1.WatchEventsRequest, *connect.ServerStream[v1.ServerEvent]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.WatchEvents is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) CreateUpload(context.Context, *v1.CreateUploadRequest) (*v1.UploadSession, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.CreateUpload is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) UploadChunk(context.Context, *v1.UploadChunkRequest) (*v1.UploadChunkResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.UploadChunk is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) GetUploadSession(context.Context, *v1.GetUploadSessionRequest) (*v1.UploadSession, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.GetUploadSession is not implemented"))
+}
+
+func (UnimplementedFileUploadServiceHandler) GetStats(context.Context, *v1.GetStatsRequest) (*v1.GetStatsResponse, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("fileupload.v1.FileUploadService.GetStats is not implemented"))
+}
@@ -0,0 +1,54 @@
+package uploadclient
+
+import "time"
+
+const (
+	// DefaultMaxChunkSize bounds the chunks of Options.AdaptiveChunking when
+	// Options.MaxChunkSize is 0
+	DefaultMaxChunkSize = 1 << 20
+	// minAdaptiveChunkSize is the smallest chunk adaptive chunking shrinks to
+	minAdaptiveChunkSize = 4 * 1024
+
+	// a chunk sent faster than this doubles the next one, one slower than
+	// slowChunkSend halves it
+	fastChunkSend = 20 * time.Millisecond
+	slowChunkSend = 250 * time.Millisecond
+)
+
+// chunkSizer picks the size of each chunk. It stays fixed unless adaptive,
+// then it grows on a fast link and shrinks on a slow one, within [min, max],
+// from how long the previous chunk took to send.
+type chunkSizer struct {
+	size     int
+	min, max int
+	adaptive bool
+}
+
+func (c *Client) newChunkSizer() *chunkSizer {
+	if !c.adaptive {
+		return &chunkSizer{size: c.chunkSize, min: c.chunkSize, max: c.chunkSize}
+	}
+	return &chunkSizer{
+		size:     c.chunkSize,
+		min:      min(minAdaptiveChunkSize, c.chunkSize),
+		max:      max(c.maxChunkSize, c.chunkSize),
+		adaptive: true,
+	}
+}
+
+// observe records that a chunk of n bytes took d to send and returns whether
+// the next size changed
+func (s *chunkSizer) observe(n int, d time.Duration) bool {
+	if !s.adaptive || n < s.size {
+		// a short read says nothing about the link
+		return false
+	}
+	prev := s.size
+	switch {
+	case d < fastChunkSend:
+		s.size = min(s.size*2, s.max)
+	case d > slowChunkSend:
+		s.size = max(s.size/2, s.min)
+	}
+	return s.size != prev
+}
@@ -0,0 +1,96 @@
+package uploadclient
+
+import (
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkSizer(t *testing.T) {
+	fixed := (&Client{chunkSize: 8}).newChunkSizer()
+	if fixed.observe(8, time.Nanosecond) || fixed.size != 8 {
+		t.Fatalf("a fixed chunk size changed to %d", fixed.size)
+	}
+
+	c := &Client{chunkSize: 8 << 10, adaptive: true, maxChunkSize: 32 << 10}
+	s := c.newChunkSizer()
+	var sizes []int
+	for _, d := range []time.Duration{
+		time.Millisecond,       // fast: 16K
+		time.Millisecond,       // fast: 32K
+		time.Millisecond,       // fast, at the bound: 32K
+		100 * time.Millisecond, // neither: 32K
+		time.Second,            // slow: 16K
+		time.Second,            // slow: 8K
+		time.Second,            // slow: 4K
+		time.Second,            // slow, at the bound: 4K
+	} {
+		s.observe(s.size, d)
+		sizes = append(sizes, s.size>>10)
+	}
+	if want := []int{16, 32, 32, 32, 16, 8, 4, 4}; !slices.Equal(sizes, want) {
+		t.Fatalf("sizes in KiB %v, want %v", sizes, want)
+	}
+	// a short read, at the end of the file, leaves the size alone
+	if s.observe(10, time.Nanosecond) || s.size != 4<<10 {
+		t.Fatalf("a short chunk changed the size to %d", s.size)
+	}
+}
+
+// delayTransport simulates a link where every request takes delay
+type delayTransport struct {
+	delay time.Duration
+}
+
+func (d delayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	time.Sleep(d.delay)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// chunkedUpload sends content with adaptive chunked UploadFile calls over a
+// link of delay per call and returns the size of every chunk
+func chunkedUpload(t *testing.T, content string, chunkSize int, delay time.Duration) []int {
+	t.Helper()
+	srv := &fakeServer{}
+	client := New(Options{
+		BaseURL:          newFakeServer(t, srv),
+		HTTPClient:       &http.Client{Transport: delayTransport{delay}},
+		ChunkSize:        chunkSize,
+		AdaptiveChunking: true,
+		MaxChunkSize:     64 << 10,
+	})
+	opts := UploadOptions{Name: "a.bin", StateFile: filepath.Join(t.TempDir(), "a.bin.upload-state")}
+	if _, err := client.UploadFile(t.Context(), writeTemp(t, content), opts); err != nil {
+		t.Fatal(err)
+	}
+	if srv.files["a.bin"] != content {
+		t.Fatal("stored content differs")
+	}
+	var sizes []int
+	for i, offset := range srv.chunks {
+		end := len(content)
+		if i+1 < len(srv.chunks) {
+			end = srv.chunks[i+1]
+		}
+		sizes = append(sizes, end-offset)
+	}
+	return sizes
+}
+
+func TestAdaptiveChunkingFastLink(t *testing.T) {
+	sizes := chunkedUpload(t, strings.Repeat("x", 100<<10), 4<<10, 0)
+	// local calls are fast, a busy machine may still hold one back
+	if len(sizes) < 2 || sizes[0] != 4<<10 || slices.Max(sizes) < 16<<10 {
+		t.Fatalf("chunk sizes on a fast link %v, want them to grow from 4 KiB", sizes)
+	}
+}
+
+func TestAdaptiveChunkingSlowLink(t *testing.T) {
+	sizes := chunkedUpload(t, strings.Repeat("x", 28<<10), 16<<10, slowChunkSend+50*time.Millisecond)
+	if want := []int{16 << 10, 8 << 10, 4 << 10}; !slices.Equal(sizes, want) {
+		t.Fatalf("chunk sizes on a slow link %v, want %v", sizes, want)
+	}
+}
@@ -0,0 +1,55 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// UploadArchive stores the local files at paths as the entries of a single
+// archive named name on the server, a zip file when name ends in .zip and a
+// tar file otherwise. Each entry is named after the base name of its file and
+// sent in one call, so entries are bounded by the server's message size. The
+// archive only appears once every entry arrived; an archive abandoned on an
+// error is deleted by the server after -partial-max-age.
+func (c *Client) UploadArchive(ctx context.Context, name, title string, paths []string) (*Response, error) {
+	begin, err := c.rpc.BeginArchive(ctx, &fileuploadv1.BeginArchiveRequest{Filename: name, Title: title})
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		entry := filepath.Base(path)
+		c.logf("Adding to %s: %s (%d bytes)", begin.Filename, entry, len(data))
+		if _, err := c.rpc.AddArchiveEntry(ctx, &fileuploadv1.AddArchiveEntryRequest{
+			ArchiveId: begin.ArchiveId,
+			Name:      entry,
+			Data:      data,
+			Sha256:    hex.EncodeToString(sum[:]),
+		}); err != nil {
+			return nil, fmt.Errorf("add %s: %w", entry, err)
+		}
+	}
+	resp, err := c.rpc.CloseArchive(ctx, &fileuploadv1.CloseArchiveRequest{ArchiveId: begin.ArchiveId})
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Message:        resp.Message,
+		Size:           resp.Size,
+		HashOk:         resp.HashOk,
+		HashStatus:     hashStatus(resp.HashStatus),
+		SHA256:         resp.Sha256,
+		StoredFilename: resp.StoredFilename,
+		StoragePath:    resp.StoragePath,
+	}, nil
+}
@@ -0,0 +1,729 @@
+// Package uploadclient uploads files to the file upload service with the
+// streaming Upload RPC (metadata, chunks, then a SHA-256 commit), for Go
+// programs that want to embed uploads instead of running cmd/client.
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+const (
+	// DefaultChunkSize is the size of each streamed chunk
+	DefaultChunkSize = 32 * 1024
+
+	// messages smaller than this are sent uncompressed even with Gzip
+	compressMinBytes = 1024
+)
+
+// Options configures a Client. Only BaseURL is required.
+type Options struct {
+	// BaseURL of the upload server, such as http://localhost:8080
+	BaseURL string
+	// HTTPClient sends the requests; nil uses NewHTTPClient with default settings
+	HTTPClient *http.Client
+	// ChunkSize is the size of each streamed chunk, DefaultChunkSize when 0
+	ChunkSize int
+	// AdaptiveChunking starts at ChunkSize and doubles the chunks while they
+	// send quickly, halving them when they are slow, up to MaxChunkSize
+	AdaptiveChunking bool
+	// MaxChunkSize bounds adaptive chunks, DefaultMaxChunkSize when 0. It
+	// must stay under the server's -max-message-bytes.
+	MaxChunkSize int
+	// Gzip compresses messages on the wire; the stored bytes are unchanged
+	Gzip bool
+	// Retries is how many times UploadFile retries after an unavailable server
+	Retries int
+	// RetryDelay is the wait before the first retry, doubled after each one
+	RetryDelay time.Duration
+	// Token is sent as a bearer token in the Authorization header when set
+	Token string
+	// Logf receives progress messages, nil discards them
+	Logf func(format string, args ...any)
+	// FetchClient downloads the source of UploadURL; nil uses NewHTTPClient
+	// with default settings, so HTTPClient's private CA is not applied to it
+	FetchClient *http.Client
+}
+
+// UploadOptions describe one upload
+type UploadOptions struct {
+	// Name is the filename to store on the server (sanitized server-side)
+	Name string
+	// Title is free-form metadata logged by the server
+	Title string
+	// DryRun runs every server-side check without storing anything
+	DryRun bool
+	// HashOnly has the server hash the content and return the digest as
+	// Response.SHA256 without storing it or checking it against a filename
+	HashOnly bool
+	// RefuseSymlinks makes UploadFile fail with ErrSymlink when path is a
+	// symbolic link instead of uploading the file it points to
+	RefuseSymlinks bool
+	// SegmentSize hashes the content in segments of this many bytes so the
+	// server can tell which ones arrived corrupt; UploadFile then re-sends
+	// only those. 0 sends a single hash.
+	SegmentSize int64
+	// ExpectedSHA256 is a known hex-encoded hash of the content, such as one
+	// from a checksum file. It is committed instead of the hash computed while
+	// sending, so the server rejects content that does not match it.
+	ExpectedSHA256 string
+	// IfMatchSHA256 only overwrites the stored file if its content has this
+	// hex-encoded hash, so concurrent writers do not lose each other's
+	// updates. The server refuses with CodeFailedPrecondition otherwise.
+	IfMatchSHA256 string
+	// Extract has the server unpack the uploaded .zip, .tar, .tar.gz or .tgz
+	// file into its extraction directory too, see Response.ExtractedDir
+	Extract bool
+	// TTL has the server delete the stored file this long after storing it,
+	// rounded up to whole seconds; 0 keeps it. See Client.ExtendExpiry.
+	TTL time.Duration
+	// AckWindow sends over UploadBidi with at most this many chunks not yet
+	// acknowledged as written by the server, bounding the data in flight on
+	// slow or lossy links. It needs HTTP/2: an https server, or a client from
+	// NewHTTPClient with TLSOptions.UnencryptedHTTP2. 0 uses Upload.
+	AckWindow int
+	// DeclareChunks sends the number of chunks in the metadata so the server
+	// checks the stream has exactly that many; every chunk but the last is
+	// then full. Ignored when the size is unknown or with AdaptiveChunking.
+	DeclareChunks bool
+	// Progress, when not nil, is called with the bytes of the content sent so
+	// far, after each chunk; a retry starting over reports from 0 again. See
+	// Progress for adding up several uploads.
+	Progress func(sent int64)
+	// StateFile makes UploadFile resumable: the file is sent in chunked
+	// UploadFile calls whose progress is recorded here, so a restarted client
+	// continues from the server's GetUploadStatus offset. It is removed on success.
+	StateFile string
+	// VerifyResume has the server check, when a StateFile upload resumes, that
+	// its partial file matches the bytes already sent; a corrupt partial file
+	// is discarded and the upload starts over from the first byte.
+	VerifyResume bool
+	// SkipExisting has UploadFile first ask StatFile whether Name already
+	// holds a file of the same size and SHA-256, and send nothing when it
+	// does, for re-runs of bulk uploads. Ignored without a Name.
+	SkipExisting bool
+	// HTTPPut sends the file as the chunked body of a single PUT /files/{name}
+	// instead of an Upload stream, for proxies that block streaming RPCs or
+	// HTTP/2. Its SHA-256, computed while sending, follows in a trailer that
+	// the server checks like an Upload commit. Title is not sent.
+	HTTPPut bool
+	// Session sends the file in UploadChunk calls of a CreateUpload session,
+	// each carrying the Upload-Session header for sticky load balancing. With
+	// a StateFile the session is recorded, so a restarted client resumes it.
+	Session bool
+	// AutoUnary has UploadFile send a file no larger than the server's
+	// UploadFile limit, from GetServerInfo, in one UploadFile call carrying
+	// its SHA-256, and stream larger ones. At most DefaultUnaryThreshold is
+	// sent at once unless UnaryThreshold says otherwise. HashOnly, SegmentSize
+	// and AckWindow uploads always stream. Content not matching
+	// ExpectedSHA256 fails before anything is sent, and a hash the server
+	// does not confirm fails the upload, as with a stream.
+	AutoUnary bool
+	// UnaryThreshold overrides the largest file AutoUnary sends in one call
+	UnaryThreshold int64
+}
+
+func (o UploadOptions) reportProgress(sent int64) {
+	if o.Progress != nil {
+		o.Progress(sent)
+	}
+}
+
+// ErrSymlink is returned by UploadFile for a symbolic link with RefuseSymlinks
+var ErrSymlink = errors.New("refusing to upload a symbolic link")
+
+// Response is the server's answer to an upload
+type Response struct {
+	Message string
+	Size    int64
+	HashOk  bool
+	// SHA256 is the hex-encoded hash computed locally while sending, or the
+	// one computed by the server with UploadOptions.HashOnly
+	SHA256 string
+	// StoredFilename is the name the server stored the file under, which may
+	// differ from UploadOptions.Name when the server assigns random names
+	StoredFilename string
+	// ExtractedDir is where the server unpacked the archive with
+	// UploadOptions.Extract, relative to its extraction directory
+	ExtractedDir string
+	// StoragePath is where the file landed on the server, relative to its
+	// upload directory, including any routed subdirectory
+	StoragePath string
+	// HashStatus tells how the server checked the hash it was sent; HashOk is
+	// false both for a mismatch and when no hash was sent
+	HashStatus HashStatus
+	// ExpiresAt is when the server deletes the file uploaded with
+	// UploadOptions.TTL, zero when it does not expire
+	ExpiresAt time.Time
+	// Skipped is set when UploadOptions.SkipExisting found the same content
+	// already stored under the name, so nothing was sent
+	Skipped bool
+}
+
+// HashStatus is the outcome of the server checking the client's hash
+type HashStatus string
+
+const (
+	HashVerified    HashStatus = "verified"
+	HashMismatch    HashStatus = "mismatch"
+	HashNotProvided HashStatus = "not_provided"
+)
+
+// hashStatus converts the response enum, empty for an older server that does
+// not report it
+func hashStatus(s fileuploadv1.HashStatus) HashStatus {
+	switch s {
+	case fileuploadv1.HashStatus_HASH_STATUS_VERIFIED:
+		return HashVerified
+	case fileuploadv1.HashStatus_HASH_STATUS_MISMATCH:
+		return HashMismatch
+	case fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED:
+		return HashNotProvided
+	}
+	return ""
+}
+
+// Client uploads files to one server
+type Client struct {
+	rpc       fileuploadv1connect.FileUploadServiceClient
+	http      *http.Client
+	baseURL   string
+	token     string
+	chunkSize int
+	// adaptive and maxChunkSize configure newChunkSizer
+	adaptive     bool
+	maxChunkSize int
+	retries      int
+	retryDelay   time.Duration
+	logf         func(format string, args ...any)
+	fetch        *http.Client
+	// info is the server's GetServerInfo answer, nil until asked
+	infoMu sync.Mutex
+	info   *fileuploadv1.GetServerInfoResponse
+}
+
+// New returns a Client for opts.BaseURL
+func New(opts Options) *Client {
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient, _ = NewHTTPClient(TLSOptions{})
+	}
+	var clientOpts []connect.ClientOption
+	if opts.Gzip {
+		clientOpts = append(clientOpts, connect.WithSendGzip(), connect.WithCompressMinBytes(compressMinBytes))
+	}
+	if opts.Token != "" {
+		clientOpts = append(clientOpts, connect.WithInterceptors(bearerToken(opts.Token)))
+	}
+	clientOpts = append(clientOpts, connect.WithInterceptors(sessionRouting{}))
+
+	c := &Client{
+		rpc:          fileuploadv1connect.NewFileUploadServiceClient(httpClient, opts.BaseURL, clientOpts...),
+		http:         httpClient,
+		baseURL:      strings.TrimSuffix(opts.BaseURL, "/"),
+		token:        opts.Token,
+		chunkSize:    opts.ChunkSize,
+		adaptive:     opts.AdaptiveChunking,
+		maxChunkSize: opts.MaxChunkSize,
+		retries:      opts.Retries,
+		retryDelay:   opts.RetryDelay,
+		logf:         opts.Logf,
+		fetch:        opts.FetchClient,
+	}
+	if c.chunkSize <= 0 {
+		c.chunkSize = DefaultChunkSize
+	}
+	if c.maxChunkSize <= 0 {
+		c.maxChunkSize = DefaultMaxChunkSize
+	}
+	if c.retryDelay <= 0 {
+		c.retryDelay = time.Second
+	}
+	if c.logf == nil {
+		c.logf = func(string, ...any) {}
+	}
+	if c.fetch == nil {
+		c.fetch, _ = NewHTTPClient(TLSOptions{})
+	}
+	return c
+}
+
+// UploadFile uploads the file at path, retrying from the start when the
+// server is unavailable
+func (c *Client) UploadFile(ctx context.Context, path string, opts UploadOptions) (*Response, error) {
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := c.uploadFileOnce(ctx, path, opts)
+		if err == nil || attempt >= c.retries || connect.CodeOf(err) != connect.CodeUnavailable {
+			return resp, err
+		}
+		c.logf("Upload attempt %d failed, retrying in %v: %v", attempt+1, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (c *Client) uploadFileOnce(ctx context.Context, path string, opts UploadOptions) (*Response, error) {
+	if opts.RefuseSymlinks {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil, fmt.Errorf("%s: %w", path, ErrSymlink)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if opts.SkipExisting && opts.Name != "" && !opts.DryRun && !opts.HashOnly {
+		if resp, err := c.skipExisting(ctx, f, info.Size(), opts); resp != nil || err != nil {
+			return resp, err
+		}
+	}
+	if opts.HTTPPut {
+		return c.uploadPut(ctx, f, opts)
+	}
+	if opts.Session {
+		return c.uploadSession(ctx, f, info, opts)
+	}
+	if opts.StateFile != "" {
+		return c.uploadResumable(ctx, f, info, opts)
+	}
+	if opts.wantsUnary() {
+		threshold, err := c.unaryThreshold(ctx, opts)
+		switch {
+		case err != nil:
+			c.logf("Could not get the server's UploadFile limit, streaming: %v", err)
+		case info.Size() <= threshold:
+			return c.uploadUnary(ctx, f, info.Size(), opts)
+		}
+	}
+	resp, hash, err := c.uploadStream(ctx, f, info.Size(), opts)
+	if corrupt := CorruptSegmentsOf(err); corrupt != nil {
+		c.logf("Server reported corrupt segments %v, re-sending them", corrupt.Indexes)
+		return c.repairSegments(ctx, f, corrupt, hash)
+	}
+	return resp, err
+}
+
+// skipExisting returns the Response of an upload that is not needed because
+// the server already stores the content of f under opts.Name, nil otherwise.
+// Only a file of the same size is hashed.
+func (c *Client) skipExisting(ctx context.Context, f *os.File, size int64, opts UploadOptions) (*Response, error) {
+	stored, err := c.Stat(ctx, opts.Name)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", opts.Name, err)
+	}
+	if stored == nil || stored.Size != size {
+		return nil, nil
+	}
+	hash, err := hashPrefix(f, size)
+	if err != nil {
+		return nil, fmt.Errorf("read input: %w", err)
+	}
+	if hash != stored.SHA256 {
+		return nil, nil
+	}
+	c.logf("%s is already stored with hash %s, skipping it", opts.Name, hash)
+	// nothing is left to send
+	opts.reportProgress(size)
+	return &Response{
+		Message:        "already present",
+		Size:           size,
+		HashOk:         true,
+		HashStatus:     HashVerified,
+		SHA256:         hash,
+		StoredFilename: stored.Name,
+		Skipped:        true,
+	}, nil
+}
+
+// UploadURL relays the body of an http(s) URL to the server as it is
+// downloaded, without buffering it. Redirects are followed and anything but a
+// final 200 fails. There are no retries since the body can only be read once.
+func (c *Client) UploadURL(ctx context.Context, rawURL string, opts UploadOptions) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme %q", req.URL.Scheme)
+	}
+	src, err := c.fetch.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch source: %w", err)
+	}
+	defer src.Body.Close()
+	if src.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch source: %s returned %s", src.Request.URL, src.Status)
+	}
+	// ContentLength is -1 when unknown, which UploadStream leaves undeclared
+	c.logf("Relaying %s (%d bytes)", src.Request.URL, src.ContentLength)
+	return c.UploadStream(ctx, src.Body, src.ContentLength, opts)
+}
+
+// readFull fills p like io.ReadFull, so only the last chunk of an upload
+// declaring its chunk count is short, and ends at io.EOF
+func readFull(r io.Reader, p []byte) (int, error) {
+	n, err := io.ReadFull(r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// UploadStream uploads everything read from r. size is declared to the server
+// so it can reject a mismatched or oversized stream early; pass -1 when unknown.
+// With opts.SegmentSize the server keeps content with corrupt segments for
+// repair, see CorruptSegmentsOf.
+func (c *Client) UploadStream(ctx context.Context, r io.Reader, size int64, opts UploadOptions) (*Response, error) {
+	resp, _, err := c.uploadStream(ctx, r, size, opts)
+	return resp, err
+}
+
+// uploadStream is UploadStream also returning the hash of what was sent
+func (c *Client) uploadStream(ctx context.Context, r io.Reader, size int64, opts UploadOptions) (*Response, string, error) {
+	stream, err := c.openUpload(ctx, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("create upload stream: %w", err)
+	}
+
+	// Phase 1: Send metadata
+	metadata := &fileuploadv1.UploadMetadata{
+		Filename:      opts.Name,
+		Title:         opts.Title,
+		DryRun:        opts.DryRun,
+		HashOnly:      opts.HashOnly,
+		SegmentSize:   opts.SegmentSize,
+		IfMatchSha256: opts.IfMatchSHA256,
+		Extract:       opts.Extract,
+		TtlSeconds:    ttlSeconds(opts.TTL),
+	}
+	if size >= 0 {
+		metadata.DeclaredSize = proto.Int64(size)
+	}
+	sizer := c.newChunkSizer()
+	read := io.Reader.Read
+	if opts.DeclareChunks && size > 0 && !sizer.adaptive {
+		metadata.ExpectedChunks = uint64((size + int64(sizer.size) - 1) / int64(sizer.size))
+		read = readFull
+	}
+	err = stream.Send(&fileuploadv1.UploadRequest{
+		Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: metadata},
+	})
+	if err != nil {
+		return nil, "", closeWithError(stream, fmt.Errorf("send metadata: %w", err))
+	}
+	c.logf("Sent metadata")
+
+	// Phase 2: Stream chunks with TeeReader (calculates hash during read)
+	hasher := sha256.New()
+	segments := newSegmentHasher(opts.SegmentSize)
+	reader := io.TeeReader(r, io.MultiWriter(hasher, segments))
+	buf := make([]byte, sizer.max)
+	var totalBytes int64
+	opts.reportProgress(0)
+
+	for {
+		n, err := read(reader, buf[:sizer.size])
+		if n > 0 {
+			start := time.Now()
+			if sendErr := stream.Send(&fileuploadv1.UploadRequest{
+				Payload: &fileuploadv1.UploadRequest_Chunk{
+					Chunk: buf[:n],
+				},
+			}); sendErr != nil {
+				return nil, "", closeWithError(stream, fmt.Errorf("send chunk: %w", sendErr))
+			}
+			totalBytes += int64(n)
+			opts.reportProgress(totalBytes)
+			if sizer.observe(n, time.Since(start)) {
+				c.logf("Chunk size now %d bytes", sizer.size)
+			}
+			if err := stream.chunkSent(); err != nil {
+				return nil, "", closeWithError(stream, fmt.Errorf("wait for ack: %w", err))
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, "", closeWithError(stream, fmt.Errorf("read input: %w", err))
+		}
+	}
+	c.logf("Sent %d bytes in chunks", totalBytes)
+
+	// Phase 3: Send finish_commit with calculated hash
+	clientHash := hex.EncodeToString(hasher.Sum(nil))
+	if opts.ExpectedSHA256 != "" && opts.ExpectedSHA256 != clientHash {
+		c.logf("Content read has hash %s, committing the expected %s", clientHash, opts.ExpectedSHA256)
+		clientHash = opts.ExpectedSHA256
+	}
+	c.logf("Sending commit with hash: %s", clientHash)
+
+	commit := &fileuploadv1.UploadRequest{
+		Payload: &fileuploadv1.UploadRequest_FinishCommit{
+			FinishCommit: clientHash,
+		},
+		CommitSize: proto.Int64(totalBytes),
+	}
+	if opts.SegmentSize > 0 {
+		commit.Payload = &fileuploadv1.UploadRequest_SegmentedCommit{
+			SegmentedCommit: &fileuploadv1.SegmentedCommit{
+				Sha256:        clientHash,
+				SegmentSha256: segments.sum(),
+			},
+		}
+	}
+	if err := stream.Send(commit); err != nil {
+		return nil, clientHash, closeWithError(stream, fmt.Errorf("send commit: %w", err))
+	}
+
+	resp, err := stream.CloseAndReceive()
+	if err != nil {
+		return nil, clientHash, err
+	}
+	out := &Response{
+		Message:        resp.Message,
+		Size:           resp.Size,
+		HashOk:         resp.HashOk,
+		HashStatus:     hashStatus(resp.HashStatus),
+		SHA256:         clientHash,
+		StoredFilename: resp.StoredFilename,
+		StoragePath:    resp.StoragePath,
+		ExtractedDir:   resp.ExtractedDir,
+		ExpiresAt:      unixTime(resp.ExpiresUnix),
+	}
+	if opts.HashOnly {
+		out.SHA256 = resp.Sha256
+	}
+	return out, clientHash, nil
+}
+
+// closeWithError ends a broken stream. A failed Send usually means the server
+// already answered, so its error is preferred over the local one.
+func closeWithError(stream uploadSender, err error) error {
+	if _, serverErr := stream.CloseAndReceive(); serverErr != nil {
+		return serverErr
+	}
+	return err
+}
+
+// Verify downloads the stored file and compares its SHA-256 with localHash
+func (c *Client) Verify(ctx context.Context, filename, localHash string) error {
+	stream, err := c.rpc.Download(ctx, &fileuploadv1.DownloadRequest{Filename: filename})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	hasher := sha256.New()
+	var size int64
+	for stream.Receive() {
+		if location := stream.Msg().Location; location != "" {
+			// the server hands the download off to external storage
+			if size, err = c.fetchInto(ctx, location, hasher); err != nil {
+				return fmt.Errorf("download failed: %w", err)
+			}
+			break
+		}
+		chunk := stream.Msg().Chunk
+		hasher.Write(chunk)
+		size += int64(len(chunk))
+	}
+	if err := stream.Err(); err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	remoteHash := hex.EncodeToString(hasher.Sum(nil))
+	c.logf("Downloaded %d bytes, hash: %s", size, remoteHash)
+	if remoteHash != localHash {
+		return fmt.Errorf("hash mismatch: local %s, remote %s", localHash, remoteHash)
+	}
+	return nil
+}
+
+// VerifyMetadata compares the SHA-256 of the local file at path with the hash
+// the server reports for filename, without downloading the stored copy. It
+// returns the local hash.
+func (c *Client) VerifyMetadata(ctx context.Context, path, filename string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", fmt.Errorf("hash local file: %w", err)
+	}
+	localHash := hex.EncodeToString(hasher.Sum(nil))
+
+	meta, err := c.rpc.GetFileMetadata(ctx, &fileuploadv1.GetFileMetadataRequest{Filename: filename})
+	if err != nil {
+		return localHash, fmt.Errorf("get metadata: %w", err)
+	}
+	c.logf("Stored %s: %d bytes, hash: %s", meta.Filename, meta.Size, meta.Sha256)
+	if meta.Sha256 != localHash {
+		return localHash, fmt.Errorf("hash mismatch: local %s, remote %s", localHash, meta.Sha256)
+	}
+	return localHash, nil
+}
+
+// StoredFile describes a file stored on the server
+type StoredFile struct {
+	Name     string
+	Size     int64
+	SHA256   string
+	Modified time.Time
+	// StoragePath and ExpiresAt are only set by ListFiles and Stats
+	StoragePath string
+	ExpiresAt   time.Time
+}
+
+// Stat returns the stored file name, nil when there is none, without
+// transferring its content
+func (c *Client) Stat(ctx context.Context, name string) (*StoredFile, error) {
+	resp, err := c.rpc.StatFile(ctx, &fileuploadv1.StatFileRequest{Filename: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Exists {
+		return nil, nil
+	}
+	return &StoredFile{
+		Name:     resp.Filename,
+		Size:     resp.Size,
+		SHA256:   resp.Sha256,
+		Modified: time.Unix(resp.ModifiedUnix, 0),
+	}, nil
+}
+
+// Rename gives the stored file from the name to, replacing an existing file
+// only with overwrite. It returns the name the server stored it under.
+func (c *Client) Rename(ctx context.Context, from, to string, overwrite bool) (string, error) {
+	resp, err := c.rpc.RenameFile(ctx, &fileuploadv1.RenameFileRequest{From: from, To: to, Overwrite: overwrite})
+	if err != nil {
+		return "", err
+	}
+	return resp.Filename, nil
+}
+
+// GetExpiry returns when the server deletes a stored file uploaded with a
+// TTL, zero when it does not expire
+func (c *Client) GetExpiry(ctx context.Context, name string) (time.Time, error) {
+	resp, err := c.rpc.GetExpiry(ctx, &fileuploadv1.GetExpiryRequest{Filename: name})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return unixTime(resp.ExpiresUnix), nil
+}
+
+// ExtendExpiry postpones the deletion of a stored file uploaded with a TTL
+// to ttl from now and returns the new expiry. The server refuses to bring it
+// forward or to make a file without an expiry expire.
+func (c *Client) ExtendExpiry(ctx context.Context, name string, ttl time.Duration) (time.Time, error) {
+	resp, err := c.rpc.ExtendExpiry(ctx, &fileuploadv1.ExtendExpiryRequest{Filename: name, TtlSeconds: ttlSeconds(ttl)})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return unixTime(resp.ExpiresUnix), nil
+}
+
+// ttlSeconds rounds a TTL up to the whole seconds the server counts in
+func ttlSeconds(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return int64((ttl + time.Second - 1) / time.Second)
+}
+
+// unixTime converts an expiry in seconds since the Unix epoch, zero for 0
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// Copy stores a copy of the stored file from under the name to, replacing
+// an existing file only with overwrite
+func (c *Client) Copy(ctx context.Context, from, to string, overwrite bool) (*Response, error) {
+	resp, err := c.rpc.CopyFile(ctx, &fileuploadv1.CopyFileRequest{From: from, To: to, Overwrite: overwrite})
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Message:        resp.Message,
+		Size:           resp.Size,
+		HashOk:         resp.HashOk,
+		HashStatus:     hashStatus(resp.HashStatus),
+		SHA256:         resp.Sha256,
+		StoredFilename: resp.StoredFilename,
+		StoragePath:    resp.StoragePath,
+	}, nil
+}
+
+// fetchInto copies the body of a GET to location into w
+func (c *Client) fetchInto(ctx context.Context, location string, w io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.fetch.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s returned %s", location, resp.Status)
+	}
+	return io.Copy(w, resp.Body)
+}
+
+// bearerToken adds an Authorization header to every request
+type bearerToken string
+
+func (t bearerToken) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		req.Header().Set("Authorization", "Bearer "+string(t))
+		return next(ctx, req)
+	}
+}
+
+func (t bearerToken) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		conn.RequestHeader().Set("Authorization", "Bearer "+string(t))
+		return conn
+	}
+}
+
+func (t bearerToken) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
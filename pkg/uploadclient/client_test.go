@@ -0,0 +1,750 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+// fakeServer stores uploads in files and serves Download from them, in two
+// chunks each starting at the requested offset. The first failures calls to Upload answer Unavailable. A
+// segmented upload reports the corrupt segments, which ranged PUTs repair.
+type fakeServer struct {
+	fileuploadv1connect.UnimplementedFileUploadServiceHandler
+
+	// external hands downloads off to this base URL instead of streaming them
+	external string
+
+	mu       sync.Mutex
+	files    map[string]string
+	failures int
+	calls    int
+	chunks   []int
+	metadata *fileuploadv1.UploadMetadata
+	// commitSize is the byte count sent with the last commit
+	commitSize *int64
+	auth       string
+	corrupt    []int64           // segments to report corrupt, still missing their repair
+	repairs    []string          // Content-Range of every repairing PUT
+	partial    map[string][]byte // chunked UploadFile uploads in progress
+	// crash is called once crashAfter chunks of a chunked UploadFile arrived
+	crash      func()
+	crashAfter int
+	// keyed holds the result of every completed upload by Idempotency-Key
+	keyed map[string]*fileuploadv1.UploadResponse
+	keys  []string // Idempotency-Key of every chunk
+	// prefixes is the prefix_sha256 of every chunk that reached the partial
+	prefixes []string
+	// cutDownloads fails this many Download calls after their first chunk
+	cutDownloads int
+	offsets      []int64 // offset of every Download
+	// maxUnacked is the most chunks UploadBidi held without acknowledging them
+	maxUnacked int
+	// wrongAck makes UploadBidi acknowledge one byte too many
+	wrongAck bool
+	// nack lists chunk indexes UploadBidi NACKs the first time they arrive,
+	// reporting them with their CRC32C in nacked
+	nack   []uint64
+	nacked []uint64
+	// events are sent to WatchEvents, which records its request in watched
+	events  []*fileuploadv1.ServerEvent
+	watched *fileuploadv1.WatchEventsRequest
+	// sessions holds the CreateUpload sessions in progress by upload ID, and
+	// routed the Upload-Session header of every call of one
+	sessions map[string]*fileuploadv1.UploadSession
+	routed   []string
+	// encodings is the Transfer-Encoding of every whole-file PUT
+	encodings [][]string
+	// info answers GetServerInfo, which is unimplemented while nil; infoCalls
+	// counts its calls
+	info      *fileuploadv1.GetServerInfoResponse
+	infoCalls int
+	// unary is the data size of every UploadFile call without an offset,
+	// whose content is damaged on the way with damage
+	unary  []int
+	damage bool
+}
+
+func (s *fakeServer) Upload(ctx context.Context, stream *connect.ClientStream[fileuploadv1.UploadRequest]) (*fileuploadv1.UploadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	s.auth = stream.RequestHeader().Get("Authorization")
+	if s.calls <= s.failures {
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("try again later"))
+	}
+
+	var data []byte
+	var commit string
+	s.chunks = nil
+	for stream.Receive() {
+		switch p := stream.Msg().Payload.(type) {
+		case *fileuploadv1.UploadRequest_Metadata:
+			s.metadata = p.Metadata
+		case *fileuploadv1.UploadRequest_Chunk:
+			data = append(data, p.Chunk...)
+			s.chunks = append(s.chunks, len(p.Chunk))
+		case *fileuploadv1.UploadRequest_FinishCommit:
+			commit = p.FinishCommit
+		case *fileuploadv1.UploadRequest_SegmentedCommit:
+			commit = p.SegmentedCommit.Sha256
+		}
+		if stream.Msg().CommitSize != nil {
+			s.commitSize = stream.Msg().CommitSize
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if !s.metadata.DryRun && !s.metadata.HashOnly {
+		s.files[s.metadata.Filename] = string(data)
+	}
+	if size := s.metadata.SegmentSize; size > 0 && len(s.corrupt) > 0 {
+		err := connect.NewError(connect.CodeDataLoss, errors.New("checksum mismatch"))
+		detail, _ := connect.NewErrorDetail(&fileuploadv1.CorruptSegments{
+			Filename:    s.metadata.Filename,
+			SegmentSize: size,
+			TotalSize:   int64(len(data)),
+			Indexes:     s.corrupt,
+		})
+		err.AddDetail(detail)
+		return nil, err
+	}
+	status := fileuploadv1.HashStatus_HASH_STATUS_VERIFIED
+	if hash != commit {
+		status = fileuploadv1.HashStatus_HASH_STATUS_MISMATCH
+	}
+	return &fileuploadv1.UploadResponse{
+		Message:    "stored",
+		Size:       int64(len(data)),
+		HashOk:     hash == commit,
+		HashStatus: status,
+		Sha256:     hash,
+	}, nil
+}
+
+func (s *fakeServer) Download(ctx context.Context, req *fileuploadv1.DownloadRequest, stream *connect.ServerStream[fileuploadv1.DownloadResponse]) error {
+	s.mu.Lock()
+	data, ok := s.files[req.Filename]
+	s.offsets = append(s.offsets, req.Offset)
+	cut := s.cutDownloads > 0
+	if cut {
+		s.cutDownloads--
+	}
+	s.mu.Unlock()
+	if !ok {
+		return connect.NewError(connect.CodeNotFound, errors.New("file not found"))
+	}
+	if s.external != "" {
+		return stream.Send(&fileuploadv1.DownloadResponse{Location: s.external + "/" + req.Filename})
+	}
+	if req.Offset < 0 || req.Offset > int64(len(data)) {
+		return connect.NewError(connect.CodeOutOfRange, errors.New("offset outside the file"))
+	}
+	data = data[req.Offset:]
+	half := len(data) / 2
+	for _, chunk := range []string{data[:half], data[half:]} {
+		if err := stream.Send(&fileuploadv1.DownloadResponse{Chunk: []byte(chunk)}); err != nil {
+			return err
+		}
+		if cut {
+			return connect.NewError(connect.CodeUnavailable, errors.New("connection lost"))
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) GetFileMetadata(ctx context.Context, req *fileuploadv1.GetFileMetadataRequest) (*fileuploadv1.GetFileMetadataResponse, error) {
+	s.mu.Lock()
+	data, ok := s.files[req.Filename]
+	s.mu.Unlock()
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("file not found"))
+	}
+	sum := sha256.Sum256([]byte(data))
+	return &fileuploadv1.GetFileMetadataResponse{
+		Filename: req.Filename,
+		Size:     int64(len(data)),
+		Sha256:   hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+func (s *fakeServer) StatFile(ctx context.Context, req *fileuploadv1.StatFileRequest) (*fileuploadv1.StatFileResponse, error) {
+	meta, err := s.GetFileMetadata(ctx, &fileuploadv1.GetFileMetadataRequest{Filename: req.Filename})
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		return &fileuploadv1.StatFileResponse{Filename: req.Filename}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fileuploadv1.StatFileResponse{
+		Filename:     req.Filename,
+		Exists:       true,
+		Size:         meta.Size,
+		Sha256:       meta.Sha256,
+		ModifiedUnix: 1700000000,
+	}, nil
+}
+
+func (s *fakeServer) ListFilesStream(ctx context.Context, req *fileuploadv1.ListFilesRequest, stream *connect.ServerStream[fileuploadv1.FileInfo]) error {
+	s.mu.Lock()
+	var infos []*fileuploadv1.FileInfo
+	for name, content := range s.files {
+		if strings.HasPrefix(name, req.Prefix) {
+			sum := sha256.Sum256([]byte(content))
+			infos = append(infos, &fileuploadv1.FileInfo{
+				Filename: name, Size: int64(len(content)), Sha256: hex.EncodeToString(sum[:]),
+				ModifiedUnix: 1700000000, StoragePath: "docs/" + name,
+			})
+		}
+	}
+	s.mu.Unlock()
+	for _, info := range infos {
+		if err := stream.Send(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) WatchEvents(ctx context.Context, req *fileuploadv1.WatchEventsRequest, stream *connect.ServerStream[fileuploadv1.ServerEvent]) error {
+	s.mu.Lock()
+	s.watched = req
+	events := s.events
+	s.mu.Unlock()
+	for _, e := range events {
+		if err := stream.Send(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putRange repairs a segment of an upload rejected for corrupt segments,
+// answering 201 with the upload once no corrupt segment is left
+func (s *fakeServer) putRange(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	cr := r.Header.Get("Content-Range")
+	if cr == "" {
+		s.putWhole(w, r, body)
+		return
+	}
+	s.repairs = append(s.repairs, cr)
+	var start, end, total int64
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &total); err != nil || len(s.corrupt) == 0 {
+		http.Error(w, "unexpected PUT", http.StatusBadRequest)
+		return
+	}
+	name := r.PathValue("name")
+	data := []byte(s.files[name])
+	copy(data[start:end+1], body)
+	s.files[name] = string(data)
+	s.corrupt = s.corrupt[1:]
+	if len(s.corrupt) > 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	out, _ := protojson.Marshal(&fileuploadv1.UploadResponse{
+		Size:   int64(len(data)),
+		HashOk: hash == r.Header.Get("X-Content-Sha256"),
+		Sha256: hash,
+	})
+	w.WriteHeader(http.StatusCreated)
+	w.Write(out)
+}
+
+// newFakeServer serves srv over httptest and returns its base URL
+func newFakeServer(t *testing.T, srv *fakeServer) string {
+	t.Helper()
+	if srv.files == nil {
+		srv.files = map[string]string{}
+	}
+	mux := http.NewServeMux()
+	mux.Handle(fileuploadv1connect.NewFileUploadServiceHandler(srv))
+	mux.HandleFunc("PUT /files/{name}", srv.putRange)
+	hs := httptest.NewUnstartedServer(mux)
+	// HTTP/2 without TLS too, for UploadBidi
+	hs.Config.Protocols = new(http.Protocols)
+	hs.Config.Protocols.SetHTTP1(true)
+	hs.Config.Protocols.SetUnencryptedHTTP2(true)
+	hs.Start()
+	t.Cleanup(hs.Close)
+	return hs.URL
+}
+
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUploadFile(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv), ChunkSize: 4})
+
+	resp, err := client.UploadFile(t.Context(), writeTemp(t, "embedded upload"), UploadOptions{Name: "a.txt", Title: "library"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("embedded upload"))
+	if !resp.HashOk || resp.HashStatus != HashVerified || resp.Size != 15 || resp.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("response %+v, want hash_ok, verified, size 15 and the local hash", resp)
+	}
+	if got := srv.files["a.txt"]; got != "embedded upload" {
+		t.Fatalf("stored %q", got)
+	}
+	if srv.metadata.Title != "library" || srv.metadata.GetDeclaredSize() != 15 {
+		t.Fatalf("metadata %v, want the title and a declared size of 15", srv.metadata)
+	}
+	if want := []int{4, 4, 4, 3}; !slices.Equal(srv.chunks, want) {
+		t.Fatalf("chunk sizes %v, want %v", srv.chunks, want)
+	}
+	if srv.commitSize == nil || *srv.commitSize != 15 {
+		t.Fatalf("commit size %v, want the 15 bytes sent", srv.commitSize)
+	}
+}
+
+func TestHashStatus(t *testing.T) {
+	for status, want := range map[fileuploadv1.HashStatus]HashStatus{
+		fileuploadv1.HashStatus_HASH_STATUS_VERIFIED:     HashVerified,
+		fileuploadv1.HashStatus_HASH_STATUS_MISMATCH:     HashMismatch,
+		fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED: HashNotProvided,
+		// an older server does not report it
+		fileuploadv1.HashStatus_HASH_STATUS_UNSPECIFIED: "",
+	} {
+		if got := hashStatus(status); got != want {
+			t.Errorf("hashStatus(%v) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestUploadFileExpectedSHA256(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	sum := sha256.Sum256([]byte("as published"))
+	expected := hex.EncodeToString(sum[:])
+	resp, err := client.UploadFile(t.Context(), writeTemp(t, "as published"), UploadOptions{Name: "a.txt", ExpectedSHA256: expected})
+	if err != nil || !resp.HashOk || resp.SHA256 != expected {
+		t.Fatalf("upload matching the expected hash: %+v, %v", resp, err)
+	}
+
+	// the expected hash is committed, so the server sees the difference
+	resp, err = client.UploadFile(t.Context(), writeTemp(t, "tampered"), UploadOptions{Name: "b.txt", ExpectedSHA256: expected})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.HashOk || resp.SHA256 != expected {
+		t.Fatalf("upload differing from the expected hash: %+v, want a failed hash check of the expected hash", resp)
+	}
+}
+
+func TestUploadFileHashOnly(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	resp, err := client.UploadFile(t.Context(), writeTemp(t, "digest"), UploadOptions{Name: "a.txt", HashOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !srv.metadata.HashOnly {
+		t.Fatal("metadata does not ask for hash_only")
+	}
+	if _, ok := srv.files["a.txt"]; ok {
+		t.Fatal("a hash-only upload was stored")
+	}
+	sum := sha256.Sum256([]byte("digest"))
+	if resp.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("SHA256 %s, want the hash the server computed", resp.SHA256)
+	}
+}
+
+func TestUploadStreamUnknownSize(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	resp, err := client.UploadStream(t.Context(), strings.NewReader("from a pipe"), -1, UploadOptions{Name: "pipe.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.HashOk || srv.files["pipe.txt"] != "from a pipe" {
+		t.Fatalf("response %+v, stored %q", resp, srv.files["pipe.txt"])
+	}
+	if srv.metadata.DeclaredSize != nil {
+		t.Fatalf("declared size %d for a stream of unknown size", srv.metadata.GetDeclaredSize())
+	}
+	// the count is known once the stream ends
+	if srv.commitSize == nil || *srv.commitSize != 11 {
+		t.Fatalf("commit size %v, want the 11 bytes sent", srv.commitSize)
+	}
+}
+
+func TestUploadFileDryRun(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	if _, err := client.UploadFile(t.Context(), writeTemp(t, "not stored"), UploadOptions{Name: "a.txt", DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !srv.metadata.DryRun {
+		t.Fatal("dry_run not sent")
+	}
+	if _, ok := srv.files["a.txt"]; ok {
+		t.Fatal("dry run stored the file")
+	}
+}
+
+func TestUploadFileRetries(t *testing.T) {
+	srv := &fakeServer{failures: 2}
+	client := New(Options{BaseURL: newFakeServer(t, srv), Retries: 2, RetryDelay: time.Millisecond})
+
+	if _, err := client.UploadFile(t.Context(), writeTemp(t, "third time lucky"), UploadOptions{Name: "a.txt"}); err != nil {
+		t.Fatalf("upload after two unavailable answers: %v", err)
+	}
+	if srv.calls != 3 || srv.files["a.txt"] != "third time lucky" {
+		t.Fatalf("%d calls, stored %q", srv.calls, srv.files["a.txt"])
+	}
+
+	srv = &fakeServer{failures: 2}
+	client = New(Options{BaseURL: newFakeServer(t, srv), Retries: 1, RetryDelay: time.Millisecond})
+	_, err := client.UploadFile(t.Context(), writeTemp(t, "out of retries"), UploadOptions{Name: "a.txt"})
+	if connect.CodeOf(err) != connect.CodeUnavailable || srv.calls != 2 {
+		t.Fatalf("upload with too few retries: %v after %d calls, want unavailable after 2", err, srv.calls)
+	}
+}
+
+func TestUploadFileSendsToken(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv), Token: "s3cret"})
+
+	if _, err := client.UploadFile(t.Context(), writeTemp(t, "authorized"), UploadOptions{Name: "a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if srv.auth != "Bearer s3cret" {
+		t.Fatalf("Authorization %q, want the bearer token", srv.auth)
+	}
+}
+
+func TestUploadFileGzip(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv), Gzip: true})
+
+	content := strings.Repeat("compressible ", 1000)
+	resp, err := client.UploadFile(t.Context(), writeTemp(t, content), UploadOptions{Name: "a.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.HashOk || srv.files["a.txt"] != content {
+		t.Fatal("gzip changed the stored bytes")
+	}
+}
+
+func TestUploadFileMissing(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	if _, err := client.UploadFile(t.Context(), filepath.Join(t.TempDir(), "missing"), UploadOptions{Name: "a.txt"}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("upload of a missing file: %v", err)
+	}
+	if srv.calls != 0 {
+		t.Fatal("a missing file reached the server")
+	}
+}
+
+func TestUploadFileRepairsSegments(t *testing.T) {
+	srv := &fakeServer{corrupt: []int64{1, 3}}
+	client := New(Options{BaseURL: newFakeServer(t, srv), ChunkSize: 3})
+
+	content := "0123456789abcdef!"
+	resp, err := client.UploadFile(t.Context(), writeTemp(t, content), UploadOptions{Name: "big.bin", SegmentSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.HashOk || srv.files["big.bin"] != content {
+		t.Fatalf("repaired upload: %+v, stored %q", resp, srv.files["big.bin"])
+	}
+	if want := []string{"bytes 4-7/17", "bytes 12-15/17"}; !slices.Equal(srv.repairs, want) {
+		t.Fatalf("re-sent %q, want only the corrupt segments %q", srv.repairs, want)
+	}
+	if srv.metadata.SegmentSize != 4 {
+		t.Fatalf("metadata segment size %d", srv.metadata.SegmentSize)
+	}
+}
+
+func TestUploadStreamReportsCorruptSegments(t *testing.T) {
+	srv := &fakeServer{corrupt: []int64{0}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	_, err := client.UploadStream(t.Context(), strings.NewReader("abcdef"), -1, UploadOptions{Name: "s.bin", SegmentSize: 4})
+	corrupt := CorruptSegmentsOf(err)
+	if corrupt == nil || !slices.Equal(corrupt.Indexes, []int64{0}) || corrupt.TotalSize != 6 {
+		t.Fatalf("CorruptSegmentsOf(%v) = %+v", err, corrupt)
+	}
+	if CorruptSegmentsOf(errors.New("other")) != nil {
+		t.Fatal("CorruptSegmentsOf found segments in an unrelated error")
+	}
+	if len(srv.repairs) != 0 {
+		t.Fatalf("UploadStream re-sent %q from a stream it cannot rewind", srv.repairs)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	srv := &fakeServer{files: map[string]string{"a.txt": "checked end to end"}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	sum := sha256.Sum256([]byte("checked end to end"))
+	local := hex.EncodeToString(sum[:])
+	if err := client.Verify(t.Context(), "a.txt", local); err != nil {
+		t.Fatalf("Verify of an intact file: %v", err)
+	}
+	sum = sha256.Sum256([]byte("checked end to END"))
+	if err := client.Verify(t.Context(), "a.txt", hex.EncodeToString(sum[:])); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Fatalf("Verify of a changed file: %v, want a hash mismatch", err)
+	}
+	if err := client.Verify(t.Context(), "missing.txt", local); err == nil {
+		t.Fatal("Verify of a missing file succeeded")
+	}
+}
+
+func TestVerifyMetadata(t *testing.T) {
+	srv := &fakeServer{files: map[string]string{"a.txt": "compared by hash"}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	sum := sha256.Sum256([]byte("compared by hash"))
+	hash, err := client.VerifyMetadata(t.Context(), writeTemp(t, "compared by hash"), "a.txt")
+	if err != nil || hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("VerifyMetadata of an intact file = %s, %v", hash, err)
+	}
+	if _, err := client.VerifyMetadata(t.Context(), writeTemp(t, "compared by HASH"), "a.txt"); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Fatalf("VerifyMetadata of a changed file: %v, want a hash mismatch", err)
+	}
+	if _, err := client.VerifyMetadata(t.Context(), writeTemp(t, "x"), "missing.txt"); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("VerifyMetadata of a missing stored file: %v, want not found", err)
+	}
+	if _, err := client.VerifyMetadata(t.Context(), filepath.Join(t.TempDir(), "nope"), "a.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("VerifyMetadata of a missing local file: %v", err)
+	}
+}
+
+func TestVerifyExternalLocation(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/a.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "served by the CDN")
+	}))
+	t.Cleanup(cdn.Close)
+	srv := &fakeServer{external: cdn.URL, files: map[string]string{"a.txt": "", "gone.txt": ""}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	sum := sha256.Sum256([]byte("served by the CDN"))
+	if err := client.Verify(t.Context(), "a.txt", hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("Verify of a file handed off to external storage: %v", err)
+	}
+	if err := client.Verify(t.Context(), "gone.txt", hex.EncodeToString(sum[:])); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Verify of a file missing from external storage: %v", err)
+	}
+}
+
+func TestUploadFileSymlinks(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	target := writeTemp(t, "behind a link")
+	link := filepath.Join(t.TempDir(), "link.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	if _, err := client.UploadFile(t.Context(), link, UploadOptions{Name: "followed.txt"}); err != nil {
+		t.Fatalf("upload through a link: %v", err)
+	}
+	if srv.files["followed.txt"] != "behind a link" {
+		t.Fatalf("stored %q, want the link target", srv.files["followed.txt"])
+	}
+	if _, err := client.UploadFile(t.Context(), link, UploadOptions{Name: "refused.txt", RefuseSymlinks: true}); !errors.Is(err, ErrSymlink) {
+		t.Fatalf("upload of a link with RefuseSymlinks: %v, want ErrSymlink", err)
+	}
+	if _, err := client.UploadFile(t.Context(), target, UploadOptions{Name: "plain.txt", RefuseSymlinks: true}); err != nil {
+		t.Fatalf("upload of a regular file with RefuseSymlinks: %v", err)
+	}
+	if srv.calls != 2 {
+		t.Fatalf("%d uploads reached the server, want 2", srv.calls)
+	}
+}
+
+func TestUploadFileSymlinkCycle(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := os.Symlink(b, a); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// following the links never ends, the upload must fail instead of looping
+	for _, refuse := range []bool{false, true} {
+		if _, err := client.UploadFile(t.Context(), a, UploadOptions{Name: "a", RefuseSymlinks: refuse}); err == nil {
+			t.Errorf("upload of a symlink cycle succeeded (RefuseSymlinks %v)", refuse)
+		}
+	}
+	if srv.calls != 0 {
+		t.Fatal("a symlink cycle reached the server")
+	}
+}
+
+func TestUploadURL(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv), ChunkSize: 8})
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report.txt":
+			w.Write([]byte("relayed from a URL"))
+		case "/moved":
+			http.Redirect(w, r, "/report.txt", http.StatusFound)
+		case "/streamed":
+			// no Content-Length, the size is unknown until the end
+			w.(http.Flusher).Flush()
+			w.Write([]byte("chunked body"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(source.Close)
+
+	resp, err := client.UploadURL(t.Context(), source.URL+"/report.txt", UploadOptions{Name: "report.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.HashOk || srv.files["report.txt"] != "relayed from a URL" || srv.metadata.GetDeclaredSize() != 18 {
+		t.Fatalf("response %+v, stored %q, metadata %v", resp, srv.files["report.txt"], srv.metadata)
+	}
+
+	if _, err := client.UploadURL(t.Context(), source.URL+"/moved", UploadOptions{Name: "moved.txt"}); err != nil {
+		t.Fatalf("upload through a redirect: %v", err)
+	}
+	if srv.files["moved.txt"] != "relayed from a URL" {
+		t.Fatalf("redirect stored %q", srv.files["moved.txt"])
+	}
+
+	if _, err := client.UploadURL(t.Context(), source.URL+"/streamed", UploadOptions{Name: "streamed.txt"}); err != nil {
+		t.Fatalf("upload of a body of unknown size: %v", err)
+	}
+	if srv.files["streamed.txt"] != "chunked body" || srv.metadata.DeclaredSize != nil {
+		t.Fatalf("stored %q, declared %v", srv.files["streamed.txt"], srv.metadata.DeclaredSize)
+	}
+
+	calls := srv.calls
+	for _, bad := range []string{source.URL + "/missing", "ftp://example.com/x", "http://%zz"} {
+		if _, err := client.UploadURL(t.Context(), bad, UploadOptions{Name: "bad.txt"}); err == nil {
+			t.Errorf("upload from %s succeeded", bad)
+		}
+	}
+	if srv.calls != calls {
+		t.Fatal("a failed fetch reached the server")
+	}
+}
+
+func TestStat(t *testing.T) {
+	url := newFakeServer(t, &fakeServer{files: map[string]string{"a.txt": "hello"}})
+	client := New(Options{BaseURL: url})
+
+	got, err := client.Stat(t.Context(), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("hello"))
+	if got == nil || got.Size != 5 || got.SHA256 != hex.EncodeToString(sum[:]) || !got.Modified.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("Stat = %+v", got)
+	}
+	if got, err := client.Stat(t.Context(), "missing.txt"); err != nil || got != nil {
+		t.Fatalf("Stat of a missing file = %+v, %v, want nil", got, err)
+	}
+}
+
+func TestUploadStreamDeclareChunks(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv), ChunkSize: 4})
+
+	// short reads still make full chunks, so the count holds
+	r := iotest.OneByteReader(strings.NewReader("0123456789"))
+	if _, err := client.UploadStream(t.Context(), r, 10, UploadOptions{Name: "a.txt", DeclareChunks: true}); err != nil {
+		t.Fatal(err)
+	}
+	if srv.metadata.ExpectedChunks != 3 || !slices.Equal(srv.chunks, []int{4, 4, 2}) {
+		t.Fatalf("declared %d chunks, sent %v", srv.metadata.ExpectedChunks, srv.chunks)
+	}
+	if srv.files["a.txt"] != "0123456789" {
+		t.Fatalf("stored %q", srv.files["a.txt"])
+	}
+
+	// nothing to count for a stream of unknown size
+	if _, err := client.UploadStream(t.Context(), strings.NewReader("pipe"), -1, UploadOptions{Name: "b.txt", DeclareChunks: true}); err != nil {
+		t.Fatal(err)
+	}
+	if srv.metadata.ExpectedChunks != 0 {
+		t.Fatalf("declared %d chunks for a stream of unknown size", srv.metadata.ExpectedChunks)
+	}
+}
+
+func TestUploadFileSkipExisting(t *testing.T) {
+	srv := &fakeServer{files: map[string]string{"same.txt": "unchanged", "changed.txt": "old bytes"}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	var progress int64
+	opts := UploadOptions{SkipExisting: true, Progress: func(sent int64) { progress = sent }}
+
+	opts.Name = "same.txt"
+	resp, err := client.UploadFile(t.Context(), writeTemp(t, "unchanged"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Skipped || !resp.HashOk || resp.StoredFilename != "same.txt" || resp.Size != 9 || progress != 9 {
+		t.Fatalf("response %+v, progress %d, want skipped with the whole file reported", resp, progress)
+	}
+	if srv.metadata != nil {
+		t.Fatalf("an upload was sent for a file already present: %v", srv.metadata)
+	}
+
+	// the same size with other content, and a new name, are uploaded
+	for name, content := range map[string]string{"changed.txt": "new bytes", "new.txt": "new"} {
+		opts.Name = name
+		resp, err := client.UploadFile(t.Context(), writeTemp(t, content), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Skipped || srv.files[name] != content {
+			t.Fatalf("%s: response %+v, stored %q", name, resp, srv.files[name])
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package uploadclient
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultParallel is how many files UploadDir sends at once by default
+const DefaultParallel = 4
+
+// DirOptions configures UploadDir
+type DirOptions struct {
+	// Upload applies to every file; its Name, StateFile and Progress are
+	// set per file
+	Upload UploadOptions
+	// Parallel is how many files are sent at once, DefaultParallel when 0
+	Parallel int
+	// Progress, when not nil, has every file added to it before the first
+	// one is sent, so its totals cover the whole directory
+	Progress *Progress
+	// Resume makes each upload resumable with a state file next to it,
+	// named after the file plus ".upload-state"
+	Resume bool
+	// Timeout bounds the upload of each file, 0 means none
+	Timeout time.Duration
+	// Done, when not nil, is called as each file finishes, from the
+	// goroutine that sent it
+	Done func(DirFile)
+}
+
+// DirFile is the outcome of uploading one file of a directory
+type DirFile struct {
+	// Path is the local file, Name the name it was sent under
+	Path string
+	Name string
+	Size int64
+	Resp *Response
+	Err  error
+}
+
+// DirStoredName is the name UploadDir sends the file at rel, relative to the
+// directory, under: the server keeps no directories, so the path elements
+// are joined with '_', as in "photos_2026_beach.jpg"
+func DirStoredName(rel string) string {
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "_")
+}
+
+// UploadDir uploads every regular file below dir, opts.Parallel at a time,
+// skipping the state files of resumable uploads. Files whose names collide
+// once flattened by DirStoredName are refused before anything is sent. The
+// error is only about walking dir; the outcome of each file is in its
+// DirFile, in walk order.
+func (c *Client) UploadDir(ctx context.Context, dir string, opts DirOptions) ([]DirFile, error) {
+	var files []DirFile
+	paths := make(map[string]string) // stored name -> local path
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// symbolic links are resolved, or refused, by UploadFile
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		// state files of interrupted resumable uploads are not content
+		if !info.Mode().IsRegular() || strings.HasSuffix(path, ".upload-state") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := DirStoredName(rel)
+		if other, ok := paths[name]; ok {
+			return fmt.Errorf("%s and %s would both be stored as %s", other, path, name)
+		}
+		paths[name] = path
+		files = append(files, DirFile{Path: path, Name: name, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([]*FileProgress, len(files))
+	if opts.Progress != nil {
+		for i, f := range files {
+			shares[i] = opts.Progress.Add(f.Size)
+		}
+	}
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(parallel, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				f := &files[i]
+				uploadOpts := opts.Upload
+				uploadOpts.Name = f.Name
+				uploadOpts.StateFile = ""
+				if opts.Resume {
+					uploadOpts.StateFile = f.Path + ".upload-state"
+				}
+				uploadOpts.Progress = nil
+				if shares[i] != nil {
+					uploadOpts.Progress = shares[i].Sent
+				}
+				f.Resp, f.Err = c.uploadDirFile(ctx, f.Path, uploadOpts, opts.Timeout)
+				if shares[i] != nil {
+					shares[i].Done(f.Err)
+				}
+				if opts.Done != nil {
+					opts.Done(*f)
+				}
+			}
+		}()
+	}
+	for i := range files {
+		if ctx.Err() != nil {
+			files[i].Err = ctx.Err()
+			continue
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return files, nil
+}
+
+func (c *Client) uploadDirFile(ctx context.Context, path string, opts UploadOptions, timeout time.Duration) (*Response, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return c.UploadFile(ctx, path, opts)
+}
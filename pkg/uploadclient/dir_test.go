@@ -0,0 +1,104 @@
+package uploadclient
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// writeTree creates the files, by slash-separated path, below a new directory
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestUploadDir(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"a.txt":                       "first",
+		"photos/2026/beach.jpg":       "sand and sea",
+		"photos/notes.txt":            "x",
+		"photos/big.bin.upload-state": "{}",
+	})
+	srv := &fakeServer{files: map[string]string{}}
+	url := newFakeServer(t, srv)
+	progress := NewProgress()
+	var mu sync.Mutex
+	var done []string
+	files, err := New(Options{BaseURL: url}).UploadDir(t.Context(), dir, DirOptions{
+		Parallel: 2,
+		Progress: progress,
+		Done: func(f DirFile) {
+			mu.Lock()
+			defer mu.Unlock()
+			done = append(done, f.Name)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a.txt": "first", "photos_2026_beach.jpg": "sand and sea", "photos_notes.txt": "x"}
+	if len(files) != len(want) || len(done) != len(want) {
+		t.Fatalf("%d files, %d reported done, want %d", len(files), len(done), len(want))
+	}
+	for _, f := range files {
+		if f.Err != nil || !f.Resp.HashOk || f.Size != int64(len(want[f.Name])) {
+			t.Errorf("%s: %+v", f.Name, f)
+		}
+		if got := srv.files[f.Name]; got != want[f.Name] {
+			t.Errorf("%s stored %q, want %q", f.Name, got, want[f.Name])
+		}
+	}
+	slices.Sort(done)
+	if !slices.Equal(done, []string{"a.txt", "photos_2026_beach.jpg", "photos_notes.txt"}) {
+		t.Errorf("reported done %v", done)
+	}
+	if st := progress.Stats(); st.Files != 3 || st.Completed != 3 || st.Failed != 0 || st.TotalBytes != 18 || st.SentBytes != 18 {
+		t.Fatalf("aggregate %+v, want 3 files and 18 bytes sent", st)
+	}
+}
+
+func TestUploadDirNameCollision(t *testing.T) {
+	// both would be stored as a_b.txt
+	dir := writeTree(t, map[string]string{"a/b.txt": "1", "a_b.txt": "2"})
+	srv := &fakeServer{files: map[string]string{}}
+	url := newFakeServer(t, srv)
+	if _, err := New(Options{BaseURL: url}).UploadDir(t.Context(), dir, DirOptions{}); err == nil {
+		t.Fatal("colliding names were accepted")
+	}
+	if srv.calls != 0 {
+		t.Fatalf("%d uploads sent before the collision was found", srv.calls)
+	}
+}
+
+func TestUploadDirFailures(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "a", "b.txt": "b"})
+	srv := &fakeServer{files: map[string]string{}, failures: 100}
+	url := newFakeServer(t, srv)
+	progress := NewProgress()
+	client := New(Options{BaseURL: url})
+	files, err := client.UploadDir(t.Context(), dir, DirOptions{Progress: progress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.Err == nil {
+			t.Errorf("%s uploaded to a failing server", f.Name)
+		}
+	}
+	if st := progress.Stats(); st.Failed != 2 || st.Completed != 0 {
+		t.Fatalf("aggregate %+v, want 2 failed", st)
+	}
+}
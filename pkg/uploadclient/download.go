@@ -0,0 +1,180 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// DownloadOptions configures DownloadFile
+type DownloadOptions struct {
+	// StateFile makes DownloadFile resumable: the stored file's size and hash
+	// are recorded here, so a restarted client appends the rest to the partial
+	// file instead of starting over, as long as the stored file is unchanged.
+	// It is removed on success.
+	StateFile string
+}
+
+// downloadState is what DownloadOptions.StateFile records between attempts
+// of a resumable download. It pins the version of the stored file, so bytes of
+// two different versions are never joined.
+type downloadState struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+func readDownloadState(path string) (downloadState, error) {
+	var state downloadState
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(b, &state)
+	return state, err
+}
+
+func writeDownloadState(path string, state downloadState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// DownloadFile saves the stored file name at path and returns its SHA-256,
+// verified against the hash the server reports. The content is written to
+// path+".part" and renamed into place once verified.
+func (c *Client) DownloadFile(ctx context.Context, name, path string, opts DownloadOptions) (string, error) {
+	meta, err := c.rpc.GetFileMetadata(ctx, &fileuploadv1.GetFileMetadataRequest{Filename: name})
+	if err != nil {
+		return "", fmt.Errorf("get metadata: %w", err)
+	}
+	partPath := path + ".part"
+
+	var offset int64
+	if opts.StateFile != "" {
+		state, err := readDownloadState(opts.StateFile)
+		if err == nil && state.Name == name && state.SHA256 == meta.Sha256 && state.Size == meta.Size {
+			if info, err := os.Stat(partPath); err == nil && info.Size() <= meta.Size {
+				offset = info.Size()
+				c.logf("Resuming download of %s at byte %d of %d", name, offset, meta.Size)
+			}
+		} else if err == nil {
+			c.logf("Stored %s changed since the last attempt, downloading it again", name)
+		}
+		state = downloadState{Name: name, Size: meta.Size, SHA256: meta.Sha256}
+		if err := writeDownloadState(opts.StateFile, state); err != nil {
+			return "", fmt.Errorf("write download state: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return "", err
+	}
+	// whatever came past offset in an earlier attempt is not trusted
+	err = f.Truncate(offset)
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err == nil {
+		err = c.downloadInto(ctx, name, offset, f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// the partial file is kept for the next attempt with a state file
+		if opts.StateFile == "" {
+			os.Remove(partPath)
+		}
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+
+	hash, err := hashPath(partPath)
+	if err != nil {
+		return "", err
+	}
+	if hash != meta.Sha256 {
+		os.Remove(partPath)
+		if opts.StateFile != "" {
+			os.Remove(opts.StateFile)
+		}
+		return hash, fmt.Errorf("hash mismatch: local %s, remote %s", hash, meta.Sha256)
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return hash, err
+	}
+	if opts.StateFile != "" {
+		if err := os.Remove(opts.StateFile); err != nil {
+			c.logf("Could not remove download state %s: %v", opts.StateFile, err)
+		}
+	}
+	c.logf("Downloaded %s to %s (%d bytes, hash: %s)", name, path, meta.Size, hash)
+	return hash, nil
+}
+
+// downloadInto writes the stored file name from offset on to w
+func (c *Client) downloadInto(ctx context.Context, name string, offset int64, w io.Writer) error {
+	stream, err := c.rpc.Download(ctx, &fileuploadv1.DownloadRequest{Filename: name, Offset: offset})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	for stream.Receive() {
+		if location := stream.Msg().Location; location != "" {
+			// the server hands the download off to external storage
+			return c.fetchRangeInto(ctx, location, offset, w)
+		}
+		if _, err := w.Write(stream.Msg().Chunk); err != nil {
+			return err
+		}
+	}
+	return stream.Err()
+}
+
+// fetchRangeInto copies the body of a GET to location from offset on into w
+func (c *Client) fetchRangeInto(ctx context.Context, location string, offset int64, w io.Writer) error {
+	if offset == 0 {
+		_, err := c.fetchInto(ctx, location, w)
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	resp, err := c.fetch.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("%s returned %s to a range request", location, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// hashPath returns the hex-encoded SHA-256 of the file at path
+func hashPath(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,87 @@
+package uploadclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDownloadFile(t *testing.T) {
+	srv := &fakeServer{files: map[string]string{"a.txt": "0123456789"}}
+	url := newFakeServer(t, srv)
+	path := filepath.Join(t.TempDir(), "a.txt")
+
+	hash, err := New(Options{BaseURL: url}).DownloadFile(t.Context(), "a.txt", path, DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("0123456789"))
+	if got, _ := os.ReadFile(path); string(got) != "0123456789" || hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("downloaded %q with hash %s", got, hash)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("partial file kept after success: %v", err)
+	}
+}
+
+func TestDownloadFileResumes(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	srv := &fakeServer{files: map[string]string{"a.txt": content}, cutDownloads: 1}
+	url := newFakeServer(t, srv)
+	path := filepath.Join(t.TempDir(), "a.txt")
+	opts := DownloadOptions{StateFile: path + ".download-state"}
+
+	// the connection drops after the first half
+	if _, err := New(Options{BaseURL: url}).DownloadFile(t.Context(), "a.txt", path, opts); err == nil {
+		t.Fatal("download survived the dropped connection")
+	}
+	if got, _ := os.ReadFile(path + ".part"); string(got) != content[:10] {
+		t.Fatalf("partial file holds %q", got)
+	}
+	if _, err := os.Stat(opts.StateFile); err != nil {
+		t.Fatalf("no state file left by the interrupted download: %v", err)
+	}
+
+	// a rerun asks only for the rest
+	if _, err := New(Options{BaseURL: url}).DownloadFile(t.Context(), "a.txt", path, opts); err != nil {
+		t.Fatalf("resumed download: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Fatalf("downloaded %q", got)
+	}
+	if !slices.Equal(srv.offsets, []int64{0, 10}) {
+		t.Fatalf("downloads at offsets %v, want 0 then 10", srv.offsets)
+	}
+	for _, left := range []string{path + ".part", opts.StateFile} {
+		if _, err := os.Stat(left); !os.IsNotExist(err) {
+			t.Errorf("%s kept after success: %v", filepath.Base(left), err)
+		}
+	}
+}
+
+func TestDownloadFileRestartsWhenChanged(t *testing.T) {
+	srv := &fakeServer{files: map[string]string{"a.txt": "old content!"}, cutDownloads: 1}
+	url := newFakeServer(t, srv)
+	path := filepath.Join(t.TempDir(), "a.txt")
+	opts := DownloadOptions{StateFile: path + ".download-state"}
+	if _, err := New(Options{BaseURL: url}).DownloadFile(t.Context(), "a.txt", path, opts); err == nil {
+		t.Fatal("download survived the dropped connection")
+	}
+
+	// the bytes of the old version are not joined to the new one
+	srv.mu.Lock()
+	srv.files["a.txt"] = "new content!"
+	srv.mu.Unlock()
+	if _, err := New(Options{BaseURL: url}).DownloadFile(t.Context(), "a.txt", path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new content!" {
+		t.Fatalf("downloaded %q", got)
+	}
+	if !slices.Equal(srv.offsets, []int64{0, 0}) {
+		t.Fatalf("downloads at offsets %v, want both from the start", srv.offsets)
+	}
+}
@@ -0,0 +1,105 @@
+package uploadclient
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// contentHashHeader carries the SHA-256 of a PUT body, here as a trailer
+const contentHashHeader = "X-Content-Sha256"
+
+// uploadPut sends f as the chunked body of a PUT /files/{name}, hashing it
+// while it is read and sending the hash, or opts.ExpectedSHA256, as a trailer
+// for the server to check
+func (c *Client) uploadPut(ctx context.Context, f *os.File, opts UploadOptions) (*Response, error) {
+	if opts.DryRun || opts.HashOnly || opts.SegmentSize > 0 || opts.Extract || opts.TTL > 0 || opts.IfMatchSHA256 != "" {
+		return nil, errors.New("an HTTP PUT upload cannot be a dry run, hash only, segmented, extracted, expiring or conditional")
+	}
+	name := opts.Name
+	if name == "" {
+		name = filepath.Base(f.Name())
+	}
+	body := &trailerHash{
+		r:        f,
+		h:        sha256.New(),
+		trailer:  http.Header{contentHashHeader: nil},
+		commit:   opts.ExpectedSHA256,
+		progress: opts.reportProgress,
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.baseURL+"/files/"+url.PathEscape(name), body)
+	if err != nil {
+		return nil, err
+	}
+	// an unknown length sends the body chunked, so it may carry a trailer
+	req.ContentLength = -1
+	req.Trailer = body.trailer
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("PUT %s: %s: %s", name, resp.Status, strings.TrimSpace(string(b)))
+	}
+	var stored fileuploadv1.UploadResponse
+	if err := protojson.Unmarshal(b, &stored); err != nil {
+		return nil, fmt.Errorf("decode PUT response: %w", err)
+	}
+	c.logf("Sent %d bytes in a chunked PUT", stored.Size)
+	return &Response{
+		Message:        stored.Message,
+		Size:           stored.Size,
+		HashOk:         stored.HashOk,
+		HashStatus:     hashStatus(stored.HashStatus),
+		SHA256:         body.sum,
+		StoredFilename: stored.StoredFilename,
+		StoragePath:    stored.StoragePath,
+	}, nil
+}
+
+// trailerHash hashes what is read from r and sets the X-Content-Sha256
+// trailer once r is done, which the HTTP client then sends after the body.
+// The trailer is commit when set, the hash of what was read otherwise.
+type trailerHash struct {
+	r        io.Reader
+	h        hash.Hash
+	trailer  http.Header
+	commit   string
+	sum      string
+	sent     int64
+	progress func(sent int64)
+}
+
+func (t *trailerHash) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.h.Write(p[:n])
+	t.sent += int64(n)
+	t.progress(t.sent)
+	if err == io.EOF {
+		t.sum = hex.EncodeToString(t.h.Sum(nil))
+		t.trailer.Set(contentHashHeader, cmp.Or(t.commit, t.sum))
+	}
+	return n, err
+}
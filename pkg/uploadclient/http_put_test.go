@@ -0,0 +1,92 @@
+package uploadclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// putWhole stores a PUT without Content-Range, refusing it when the hash in
+// its trailer does not match
+func (s *fakeServer) putWhole(w http.ResponseWriter, r *http.Request, body []byte) {
+	s.encodings = append(s.encodings, r.TransferEncoding)
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	if h := r.Trailer.Get(contentHashHeader); h != hash {
+		http.Error(w, "checksum mismatch", http.StatusInternalServerError)
+		return
+	}
+	name := r.PathValue("name")
+	s.files[name] = string(body)
+	out, _ := protojson.Marshal(&fileuploadv1.UploadResponse{
+		Message:        "ok",
+		Size:           int64(len(body)),
+		HashOk:         true,
+		HashStatus:     fileuploadv1.HashStatus_HASH_STATUS_VERIFIED,
+		Sha256:         hash,
+		StoredFilename: name,
+	})
+	w.WriteHeader(http.StatusCreated)
+	w.Write(out)
+}
+
+func TestUploadFileHTTPPut(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	var sent int64
+	resp, err := client.UploadFile(t.Context(), writeTemp(t, "put through a proxy"), UploadOptions{
+		Name: "p.txt", HTTPPut: true, Progress: func(n int64) { sent = n },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("put through a proxy"))
+	if !resp.HashOk || resp.HashStatus != HashVerified || resp.SHA256 != hex.EncodeToString(sum[:]) || resp.StoredFilename != "p.txt" {
+		t.Fatalf("response %+v", resp)
+	}
+	if got := srv.files["p.txt"]; got != "put through a proxy" {
+		t.Fatalf("stored %q", got)
+	}
+	// the hash can only follow the body in a trailer of a chunked one
+	if len(srv.encodings) != 1 || !slices.Equal(srv.encodings[0], []string{"chunked"}) {
+		t.Fatalf("Transfer-Encoding %q, want chunked", srv.encodings)
+	}
+	if sent != int64(len("put through a proxy")) {
+		t.Fatalf("progress reported %d bytes", sent)
+	}
+}
+
+func TestUploadFileHTTPPutExpectedSHA256(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	// the expected hash is what the trailer commits, the server refuses it
+	_, err := client.UploadFile(t.Context(), writeTemp(t, "actual"), UploadOptions{
+		Name: "p.txt", HTTPPut: true, ExpectedSHA256: hex.EncodeToString(make([]byte, 32)),
+	})
+	if err == nil {
+		t.Fatal("upload with a wrong expected hash accepted")
+	}
+	if _, ok := srv.files["p.txt"]; ok {
+		t.Fatal("the server stored it")
+	}
+}
+
+func TestUploadFileHTTPPutRefusesOptions(t *testing.T) {
+	client := New(Options{BaseURL: newFakeServer(t, &fakeServer{})})
+	for _, opts := range []UploadOptions{
+		{Name: "a.txt", HTTPPut: true, DryRun: true},
+		{Name: "a.txt", HTTPPut: true, HashOnly: true},
+		{Name: "a.txt", HTTPPut: true, TTL: time.Minute},
+	} {
+		if _, err := client.UploadFile(t.Context(), writeTemp(t, "x"), opts); err == nil {
+			t.Errorf("HTTP PUT upload with %+v accepted", opts)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package uploadclient
+
+import (
+	"context"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// ListFiles calls fn for every stored file whose name starts with prefix, as
+// the server streams them and in no particular order. An error returned by
+// fn stops the listing and is returned. SHA256 is empty for files the server
+// has not indexed.
+func (c *Client) ListFiles(ctx context.Context, prefix string, fn func(StoredFile) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.rpc.ListFilesStream(ctx, &fileuploadv1.ListFilesRequest{Prefix: prefix})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	for stream.Receive() {
+		f := stream.Msg()
+		if err := fn(StoredFile{
+			Name:        f.Filename,
+			Size:        f.Size,
+			SHA256:      f.Sha256,
+			Modified:    time.Unix(f.ModifiedUnix, 0),
+			StoragePath: f.StoragePath,
+			ExpiresAt:   unixTime(f.ExpiresUnix),
+		}); err != nil {
+			return err
+		}
+	}
+	return stream.Err()
+}
@@ -0,0 +1,40 @@
+package uploadclient
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestListFiles(t *testing.T) {
+	srv := &fakeServer{files: map[string]string{"report-1.txt": "first", "report-2.txt": "second", "notes.txt": "notes"}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	var names []string
+	err := client.ListFiles(t.Context(), "report-", func(f StoredFile) error {
+		names = append(names, f.Name)
+		if f.Size != int64(len(srv.files[f.Name])) || len(f.SHA256) != 64 || f.StoragePath != "docs/"+f.Name || !f.Modified.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("listed %+v", f)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"report-1.txt", "report-2.txt"}) {
+		t.Fatalf("listed %v", names)
+	}
+
+	// an error from fn stops the listing
+	stop := errors.New("stop")
+	calls := 0
+	err = client.ListFiles(t.Context(), "", func(StoredFile) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("ListFiles returned %v after %d calls, want stop after 1", err, calls)
+	}
+}
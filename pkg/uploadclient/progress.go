@@ -0,0 +1,111 @@
+package uploadclient
+
+import (
+	"sync"
+	"time"
+)
+
+// throughputWindow is how far back Progress.Stats measures the current
+// throughput
+const throughputWindow = 5 * time.Second
+
+// Progress adds up the progress of one or more uploads, possibly running in
+// parallel, for a single status line. It is safe for concurrent use.
+type Progress struct {
+	mu         sync.Mutex
+	start      time.Time
+	files      int
+	completed  int
+	failed     int
+	totalBytes int64
+	sentBytes  int64
+	samples    []progressSample // recent Stats calls, oldest first
+}
+
+type progressSample struct {
+	at   time.Time
+	sent int64
+}
+
+// ProgressStats is a snapshot of a Progress
+type ProgressStats struct {
+	Files      int
+	Completed  int
+	Failed     int
+	TotalBytes int64
+	SentBytes  int64
+	// BytesPerSecond is the throughput over the last few seconds
+	BytesPerSecond float64
+	Elapsed        time.Duration
+}
+
+// NewProgress returns an empty Progress
+func NewProgress() *Progress {
+	now := time.Now()
+	// until Stats has been called for a while, the throughput is the average
+	return &Progress{start: now, samples: []progressSample{{at: now}}}
+}
+
+// FileProgress is the share of one upload in a Progress
+type FileProgress struct {
+	p    *Progress
+	sent int64
+	done bool
+}
+
+// Add counts a file of size bytes, -1 when unknown, and returns its share,
+// whose Sent method is meant for UploadOptions.Progress
+func (p *Progress) Add(size int64) *FileProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.files++
+	p.totalBytes += max(size, 0)
+	return &FileProgress{p: p}
+}
+
+// Sent records that sent bytes of the file were sent so far
+func (f *FileProgress) Sent(sent int64) {
+	f.p.mu.Lock()
+	defer f.p.mu.Unlock()
+	f.p.sentBytes += sent - f.sent
+	f.sent = sent
+}
+
+// Done records the outcome of the upload of the file; later calls are ignored
+func (f *FileProgress) Done(err error) {
+	f.p.mu.Lock()
+	defer f.p.mu.Unlock()
+	if f.done {
+		return
+	}
+	f.done = true
+	if err != nil {
+		f.p.failed++
+	} else {
+		f.p.completed++
+	}
+}
+
+// Stats returns the current totals. The throughput is measured between Stats
+// calls, so it is only meaningful when they are made regularly.
+func (p *Progress) Stats() ProgressStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.samples = append(p.samples, progressSample{at: now, sent: p.sentBytes})
+	for len(p.samples) > 2 && now.Sub(p.samples[1].at) >= throughputWindow {
+		p.samples = p.samples[1:]
+	}
+	stats := ProgressStats{
+		Files:      p.files,
+		Completed:  p.completed,
+		Failed:     p.failed,
+		TotalBytes: p.totalBytes,
+		SentBytes:  p.sentBytes,
+		Elapsed:    now.Sub(p.start),
+	}
+	if oldest := p.samples[0]; now.After(oldest.at) {
+		stats.BytesPerSecond = float64(p.sentBytes-oldest.sent) / now.Sub(oldest.at).Seconds()
+	}
+	return stats
+}
@@ -0,0 +1,39 @@
+package uploadclient
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestProgressTotals(t *testing.T) {
+	p := NewProgress()
+	a, b, c := p.Add(100), p.Add(50), p.Add(-1)
+
+	// shares report how far their own upload got, in any order
+	var wg sync.WaitGroup
+	for _, share := range []*FileProgress{a, b, c} {
+		wg.Go(func() {
+			for sent := int64(10); sent <= 50; sent += 10 {
+				share.Sent(sent)
+			}
+		})
+	}
+	wg.Wait()
+	a.Sent(100)
+	// a retry starting over takes its bytes back
+	c.Sent(20)
+	a.Done(nil)
+	b.Done(errors.New("failed"))
+	b.Done(nil)
+
+	st := p.Stats()
+	want := ProgressStats{Files: 3, Completed: 1, Failed: 1, TotalBytes: 150, SentBytes: 170}
+	if st.Files != want.Files || st.Completed != want.Completed || st.Failed != want.Failed ||
+		st.TotalBytes != want.TotalBytes || st.SentBytes != want.SentBytes {
+		t.Fatalf("stats %+v, want %+v", st, want)
+	}
+	if st.BytesPerSecond <= 0 || st.Elapsed <= 0 {
+		t.Fatalf("throughput %v over %v", st.BytesPerSecond, st.Elapsed)
+	}
+}
@@ -0,0 +1,202 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// uploadState is what UploadOptions.StateFile records between attempts of a
+// resumable upload. A saved state only applies to the same stored name and
+// an unchanged local file.
+type uploadState struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified_unix_nano"`
+	SHA256   string `json:"sha256"`
+	// Acked is how many bytes the server acknowledged, for information only:
+	// the server's GetUploadStatus offset decides where to resume
+	Acked int64 `json:"acked"`
+	// IdempotencyKey is sent with every call of the upload, so the server
+	// answers a completed one with its result instead of a new upload
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// UploadID is the server's session of an UploadOptions.Session upload
+	UploadID string `json:"upload_id,omitempty"`
+}
+
+func readUploadState(path string) (uploadState, error) {
+	var state uploadState
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(b, &state)
+	return state, err
+}
+
+func writeUploadState(path string, state uploadState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// uploadResumable sends f in chunked UploadFile calls, recording progress in
+// opts.StateFile. When the state file matches f, it asks the server how much
+// it already holds and continues from there. Every call carries the
+// idempotency key of the state file, so a server that stored the file before
+// its answer was lost returns that result again. The state file is removed
+// once the server has the whole file.
+func (c *Client) uploadResumable(ctx context.Context, f *os.File, info os.FileInfo, opts UploadOptions) (*Response, error) {
+	if opts.DryRun || opts.HashOnly || opts.SegmentSize > 0 {
+		return nil, errors.New("a resumable upload cannot be a dry run, hash only or segmented")
+	}
+	state := uploadState{Name: opts.Name, Size: info.Size(), Modified: info.ModTime().UnixNano()}
+
+	var offset int64
+	saved, err := readUploadState(opts.StateFile)
+	if err == nil && saved.Name == state.Name && saved.Size == state.Size && saved.Modified == state.Modified {
+		state.IdempotencyKey = saved.IdempotencyKey
+		ctx = withIdempotencyKey(ctx, state.IdempotencyKey)
+		status, err := c.rpc.GetUploadStatus(ctx, &fileuploadv1.GetUploadStatusRequest{Filename: opts.Name})
+		if err != nil {
+			return nil, fmt.Errorf("get upload status: %w", err)
+		}
+		state.SHA256 = saved.SHA256
+		offset = min(status.Offset, state.Size)
+		c.logf("Resuming at byte %d of %d (%d acknowledged before)", offset, state.Size, saved.Acked)
+		if offset == state.Size && state.Size > 0 && state.IdempotencyKey != "" {
+			// stored before the answer was lost: the last byte sent again
+			// returns the result stored under the key
+			offset--
+		}
+	}
+	if state.IdempotencyKey == "" {
+		state.IdempotencyKey = rand.Text()
+		ctx = withIdempotencyKey(ctx, state.IdempotencyKey)
+	}
+	if state.SHA256 == "" {
+		if state.SHA256, err = hashPrefix(f, state.Size); err != nil {
+			return nil, fmt.Errorf("read input: %w", err)
+		}
+	}
+	if opts.ExpectedSHA256 != "" && opts.ExpectedSHA256 != state.SHA256 {
+		return nil, fmt.Errorf("content has hash %s, expected %s", state.SHA256, opts.ExpectedSHA256)
+	}
+	state.Acked = offset
+	if err := writeUploadState(opts.StateFile, state); err != nil {
+		return nil, fmt.Errorf("write upload state: %w", err)
+	}
+	opts.reportProgress(offset)
+
+	req := &fileuploadv1.UploadFileRequest{
+		Filename: opts.Name,
+		Title:    opts.Title,
+		Sha256:   state.SHA256,
+		// checked by the call completing the file
+		IfMatchSha256: opts.IfMatchSHA256,
+		Extract:       opts.Extract,
+		TtlSeconds:    ttlSeconds(opts.TTL),
+	}
+	var resp *fileuploadv1.UploadResponse
+	if state.Size == 0 {
+		// an empty file is a single call, chunks must carry data
+		if resp, err = c.rpc.UploadFile(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	if opts.VerifyResume && offset > 0 {
+		if req.PrefixSha256, err = hashPrefix(f, offset); err != nil {
+			return nil, fmt.Errorf("read input: %w", err)
+		}
+	}
+	sizer := c.newChunkSizer()
+	buf := make([]byte, sizer.max)
+	for offset < state.Size {
+		n, err := f.ReadAt(buf[:min(int64(sizer.size), state.Size-offset)], offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("read input: %w", err)
+		}
+		req.Data = buf[:n]
+		req.Offset = proto.Int64(offset)
+		req.IsLast = offset+int64(n) == state.Size
+		start := time.Now()
+		if resp, err = c.rpc.UploadFile(ctx, req); err != nil {
+			if req.PrefixSha256 != "" && connect.CodeOf(err) == connect.CodeDataLoss {
+				c.logf("Partial upload on the server does not match the first %d bytes, starting over", offset)
+				req.PrefixSha256 = ""
+				offset = 0
+				opts.reportProgress(0)
+				continue
+			}
+			return nil, fmt.Errorf("send chunk at byte %d: %w", offset, err)
+		}
+		// only the first chunk of a resumed upload is checked
+		req.PrefixSha256 = ""
+		if sizer.observe(n, time.Since(start)) {
+			c.logf("Chunk size now %d bytes", sizer.size)
+		}
+		offset += int64(n)
+		opts.reportProgress(offset)
+		state.Acked = offset
+		if err := writeUploadState(opts.StateFile, state); err != nil {
+			return nil, fmt.Errorf("write upload state: %w", err)
+		}
+	}
+	c.logf("Sent %d bytes in chunks", state.Size)
+
+	if resp == nil {
+		// every byte was acknowledged before the restart, but the completing call's answer was lost
+		return nil, errors.New("server reports every byte received but no completed upload, remove the state file to start over")
+	}
+	if err := os.Remove(opts.StateFile); err != nil {
+		c.logf("Could not remove upload state %s: %v", opts.StateFile, err)
+	}
+	return &Response{
+		Message:        resp.Message,
+		Size:           resp.Size,
+		HashOk:         resp.HashOk,
+		HashStatus:     hashStatus(resp.HashStatus),
+		SHA256:         state.SHA256,
+		StoredFilename: resp.StoredFilename,
+		StoragePath:    resp.StoragePath,
+		ExtractedDir:   resp.ExtractedDir,
+		ExpiresAt:      unixTime(resp.ExpiresUnix),
+	}, nil
+}
+
+// idempotencyHeader carries the key naming a resumable upload across calls
+const idempotencyHeader = "Idempotency-Key"
+
+// withIdempotencyKey returns ctx sending key as the Idempotency-Key of its
+// calls, which must not be concurrent
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	ctx, info := connect.NewClientContext(ctx)
+	info.RequestHeader().Set(idempotencyHeader, key)
+	return ctx
+}
+
+// hashPrefix returns the hex-encoded SHA-256 of the first n bytes of f
+func hashPrefix(f *os.File, n int64) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, io.NewSectionReader(f, 0, n)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
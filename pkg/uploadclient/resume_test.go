@@ -0,0 +1,231 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// idempotencyKeyOf returns the Idempotency-Key the call of ctx was sent with
+func idempotencyKeyOf(ctx context.Context) string {
+	info, _ := connect.CallInfoForHandlerContext(ctx)
+	return info.RequestHeader().Get(idempotencyHeader)
+}
+
+// UploadFile assembles chunked uploads in memory, recording the offset of
+// every chunk in chunks, and answers a completed Idempotency-Key again
+func (s *fakeServer) UploadFile(ctx context.Context, req *fileuploadv1.UploadFileRequest) (*fileuploadv1.UploadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Offset == nil {
+		return s.uploadWhole(req), nil
+	}
+	key := idempotencyKeyOf(ctx)
+	s.keys = append(s.keys, key)
+	if resp, ok := s.keyed[key]; ok {
+		s.chunks = append(s.chunks, int(req.GetOffset()))
+		return resp, nil
+	}
+	if s.partial == nil {
+		s.partial = map[string][]byte{}
+	}
+	data := s.partial[req.Filename]
+	if req.GetOffset() == 0 {
+		data = nil // starting over
+	}
+	if int(req.GetOffset()) != len(data) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("chunk out of order"))
+	}
+	s.prefixes = append(s.prefixes, req.PrefixSha256)
+	if req.PrefixSha256 != "" {
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != req.PrefixSha256 {
+			delete(s.partial, req.Filename)
+			return nil, connect.NewError(connect.CodeDataLoss, errors.New("partial upload does not match prefix_sha256"))
+		}
+	}
+	s.partial[req.Filename] = append(data, req.Data...)
+	s.chunks = append(s.chunks, int(req.GetOffset()))
+	if s.crash != nil && len(s.chunks) == s.crashAfter {
+		s.crash()
+	}
+	if !req.IsLast {
+		return &fileuploadv1.UploadResponse{Message: "chunk received", Size: int64(len(s.partial[req.Filename]))}, nil
+	}
+	data = s.partial[req.Filename]
+	delete(s.partial, req.Filename)
+	s.files[req.Filename] = string(data)
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	resp := &fileuploadv1.UploadResponse{Message: "ok", Size: int64(len(data)), HashOk: hash == req.Sha256, Sha256: hash, StoredFilename: req.Filename}
+	if key != "" {
+		if s.keyed == nil {
+			s.keyed = map[string]*fileuploadv1.UploadResponse{}
+		}
+		s.keyed[key] = resp
+	}
+	return resp, nil
+}
+
+func (s *fakeServer) GetUploadStatus(ctx context.Context, req *fileuploadv1.GetUploadStatusRequest) (*fileuploadv1.GetUploadStatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp, ok := s.keyed[idempotencyKeyOf(ctx)]; ok {
+		return &fileuploadv1.GetUploadStatusResponse{Filename: req.Filename, Offset: resp.Size, TotalSize: &resp.Size}, nil
+	}
+	return &fileuploadv1.GetUploadStatusResponse{Filename: req.Filename, Offset: int64(len(s.partial[req.Filename]))}, nil
+}
+
+func TestUploadFileResumesAfterCrash(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	path := writeTemp(t, content)
+	opts := UploadOptions{Name: "a.txt", StateFile: filepath.Join(t.TempDir(), "a.txt.upload-state")}
+
+	// the first client dies once the server holds two chunks
+	crashed, crash := context.WithCancel(t.Context())
+	srv := &fakeServer{crash: crash, crashAfter: 2}
+	url := newFakeServer(t, srv)
+	if _, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(crashed, path, opts); err == nil {
+		t.Fatal("upload survived the crash")
+	}
+	if _, err := os.Stat(opts.StateFile); err != nil {
+		t.Fatalf("no state file left by the crashed client: %v", err)
+	}
+
+	// a new client picks up where the server left off
+	srv.mu.Lock()
+	srv.chunks, srv.crash = nil, nil
+	srv.mu.Unlock()
+	resp, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(t.Context(), path, opts)
+	if err != nil {
+		t.Fatalf("resumed upload: %v", err)
+	}
+	if !resp.HashOk || resp.Size != int64(len(content)) {
+		t.Fatalf("resumed upload = %+v", resp)
+	}
+	if got := srv.files["a.txt"]; got != content {
+		t.Fatalf("server holds %q", got)
+	}
+	if !slices.Equal(srv.chunks, []int{8, 12, 16}) {
+		t.Fatalf("resumed upload sent chunks at %v, want only those past byte 8", srv.chunks)
+	}
+	if _, err := os.Stat(opts.StateFile); !os.IsNotExist(err) {
+		t.Fatalf("state file kept after success: %v", err)
+	}
+}
+
+func TestUploadFileIgnoresStaleState(t *testing.T) {
+	path := writeTemp(t, "new content")
+	opts := UploadOptions{Name: "a.txt", StateFile: filepath.Join(t.TempDir(), "a.txt.upload-state")}
+	// recorded for another version of the file
+	if err := writeUploadState(opts.StateFile, uploadState{Name: "a.txt", Size: 11, SHA256: "stale", Acked: 8}); err != nil {
+		t.Fatal(err)
+	}
+	// resuming at the server's offset would mix in the old content
+	srv := &fakeServer{partial: map[string][]byte{"a.txt": []byte("old cont")}}
+	url := newFakeServer(t, srv)
+
+	if _, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(t.Context(), path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if srv.chunks[0] != 0 || srv.files["a.txt"] != "new content" {
+		t.Fatalf("upload with a stale state sent chunks at %v, stored %q", srv.chunks, srv.files["a.txt"])
+	}
+}
+
+func TestUploadFileAnswerLost(t *testing.T) {
+	const content = "hello world"
+	path := writeTemp(t, content)
+	opts := UploadOptions{Name: "a.txt", StateFile: filepath.Join(t.TempDir(), "a.txt.upload-state")}
+
+	// the client dies once the server stored the last chunk, before its answer
+	crashed, crash := context.WithCancel(t.Context())
+	srv := &fakeServer{crash: crash, crashAfter: 3}
+	url := newFakeServer(t, srv)
+	if _, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(crashed, path, opts); err == nil {
+		t.Fatal("upload survived the crash")
+	}
+	if srv.files["a.txt"] != content {
+		t.Fatalf("server holds %q before the crash", srv.files["a.txt"])
+	}
+	state, err := readUploadState(opts.StateFile)
+	if err != nil || state.IdempotencyKey == "" {
+		t.Fatalf("state file %+v, %v, want an idempotency key", state, err)
+	}
+	for _, key := range srv.keys {
+		if key != state.IdempotencyKey {
+			t.Fatalf("chunks sent with keys %q, want %q", srv.keys, state.IdempotencyKey)
+		}
+	}
+
+	// the rerun reconnects with the same key: the last byte sent again
+	// returns the stored result
+	srv.mu.Lock()
+	srv.chunks, srv.crash, srv.keys = nil, nil, nil
+	srv.mu.Unlock()
+	resp, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(t.Context(), path, opts)
+	if err != nil {
+		t.Fatalf("reconnect with the same key: %v", err)
+	}
+	if !slices.Equal(srv.chunks, []int{10}) || !slices.Equal(srv.keys, []string{state.IdempotencyKey}) {
+		t.Fatalf("rerun sent chunks at %v with keys %q, want the last byte under the saved key", srv.chunks, srv.keys)
+	}
+	if !resp.HashOk || resp.Size != int64(len(content)) || resp.StoredFilename != "a.txt" {
+		t.Fatalf("rerun answered %+v", resp)
+	}
+	if _, err := os.Stat(opts.StateFile); !os.IsNotExist(err) {
+		t.Fatalf("state file kept after success: %v", err)
+	}
+}
+
+func TestUploadFileVerifyResume(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	for _, tc := range []struct {
+		desc   string
+		tamper bool
+		chunks []int
+	}{
+		{"good partial", false, []int{8, 12, 16}},
+		// the damaged partial refuses the chunk at 8 and the upload starts over
+		{"tampered partial", true, []int{0, 4, 8, 12, 16}},
+	} {
+		path := writeTemp(t, content)
+		opts := UploadOptions{Name: "a.txt", StateFile: filepath.Join(t.TempDir(), "a.txt.upload-state"), VerifyResume: true}
+		crashed, crash := context.WithCancel(t.Context())
+		srv := &fakeServer{crash: crash, crashAfter: 2}
+		url := newFakeServer(t, srv)
+		if _, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(crashed, path, opts); err == nil {
+			t.Fatalf("%s: upload survived the crash", tc.desc)
+		}
+
+		srv.mu.Lock()
+		srv.chunks, srv.prefixes, srv.crash = nil, nil, nil
+		if tc.tamper {
+			srv.partial["a.txt"][5] = 'X'
+		}
+		srv.mu.Unlock()
+		resp, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(t.Context(), path, opts)
+		if err != nil {
+			t.Fatalf("%s: resumed upload: %v", tc.desc, err)
+		}
+		if !resp.HashOk || srv.files["a.txt"] != content {
+			t.Fatalf("%s: stored %q, %+v", tc.desc, srv.files["a.txt"], resp)
+		}
+		if !slices.Equal(srv.chunks, tc.chunks) {
+			t.Errorf("%s: chunks at %v, want %v", tc.desc, srv.chunks, tc.chunks)
+		}
+		// only the first resumed chunk carries the hash of what came before
+		sum := sha256.Sum256([]byte(content[:8]))
+		if srv.prefixes[0] != hex.EncodeToString(sum[:]) || slices.ContainsFunc(srv.prefixes[1:], func(p string) bool { return p != "" }) {
+			t.Errorf("%s: prefix hashes %q", tc.desc, srv.prefixes)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package uploadclient
+
+import (
+	"context"
+	"errors"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// ScrubStatus is the outcome of re-hashing one stored file
+type ScrubStatus string
+
+const (
+	ScrubOK         ScrubStatus = "ok"
+	ScrubCorrupt    ScrubStatus = "corrupt"
+	ScrubMissing    ScrubStatus = "missing"
+	ScrubUnreadable ScrubStatus = "unreadable"
+)
+
+func scrubStatus(s fileuploadv1.ScrubStatus) ScrubStatus {
+	switch s {
+	case fileuploadv1.ScrubStatus_SCRUB_STATUS_OK:
+		return ScrubOK
+	case fileuploadv1.ScrubStatus_SCRUB_STATUS_CORRUPT:
+		return ScrubCorrupt
+	case fileuploadv1.ScrubStatus_SCRUB_STATUS_MISSING:
+		return ScrubMissing
+	case fileuploadv1.ScrubStatus_SCRUB_STATUS_UNREADABLE:
+		return ScrubUnreadable
+	}
+	return ""
+}
+
+// ScrubbedFile is one stored file checked by Scrub
+type ScrubbedFile struct {
+	Name   string
+	Status ScrubStatus
+	// ExpectedSHA256 is the hash the server recorded when storing the file,
+	// ActualSHA256 the one of its content now, empty unless it was read
+	ExpectedSHA256 string
+	ActualSHA256   string
+	// Error tells why an unreadable file could not be read
+	Error string
+}
+
+// ScrubProgress is reported by Scrub after each file
+type ScrubProgress struct {
+	File         ScrubbedFile
+	FilesChecked int64
+	FilesTotal   int64
+	BytesChecked int64
+}
+
+// ScrubReport is the outcome of Scrub
+type ScrubReport struct {
+	FilesChecked int64
+	BytesChecked int64
+	// FilesSkipped were replaced or removed while the scrub ran
+	FilesSkipped int64
+	// Damaged lists every file that is not ScrubOK
+	Damaged []ScrubbedFile
+}
+
+func scrubbedFile(f *fileuploadv1.ScrubFile) ScrubbedFile {
+	return ScrubbedFile{
+		Name:           f.GetFilename(),
+		Status:         scrubStatus(f.GetStatus()),
+		ExpectedSHA256: f.GetExpectedSha256(),
+		ActualSHA256:   f.GetActualSha256(),
+		Error:          f.GetError(),
+	}
+}
+
+// Scrub has the server re-hash every stored file and compare it with the hash
+// recorded when it was stored. progress, when not nil, is called after each
+// file. The server runs one scrub at a time and answers CodeUnavailable while
+// another one is running.
+func (c *Client) Scrub(ctx context.Context, progress func(ScrubProgress)) (*ScrubReport, error) {
+	stream, err := c.rpc.Scrub(ctx, &fileuploadv1.ScrubRequest{})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	for stream.Receive() {
+		if p := stream.Msg().GetProgress(); p != nil && progress != nil {
+			progress(ScrubProgress{
+				File:         scrubbedFile(p.File),
+				FilesChecked: p.FilesChecked,
+				FilesTotal:   p.FilesTotal,
+				BytesChecked: p.BytesChecked,
+			})
+		}
+		if sum := stream.Msg().GetSummary(); sum != nil {
+			report := &ScrubReport{
+				FilesChecked: sum.FilesChecked,
+				BytesChecked: sum.BytesChecked,
+				FilesSkipped: sum.FilesSkipped,
+			}
+			for _, f := range sum.Damaged {
+				report.Damaged = append(report.Damaged, scrubbedFile(f))
+			}
+			return report, nil
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("scrub ended without a summary")
+}
@@ -0,0 +1,61 @@
+package uploadclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// Scrub reports every stored file intact except rotten.txt
+func (s *fakeServer) Scrub(ctx context.Context, req *fileuploadv1.ScrubRequest, stream *connect.ServerStream[fileuploadv1.ScrubResponse]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		return connect.NewError(connect.CodeUnavailable, errors.New("a scrub is already running"))
+	}
+	files := []*fileuploadv1.ScrubFile{
+		{Filename: "good.txt", Status: fileuploadv1.ScrubStatus_SCRUB_STATUS_OK, ExpectedSha256: "aa", ActualSha256: "aa"},
+		{Filename: "rotten.txt", Status: fileuploadv1.ScrubStatus_SCRUB_STATUS_CORRUPT, ExpectedSha256: "bb", ActualSha256: "cc"},
+	}
+	summary := &fileuploadv1.ScrubSummary{}
+	for _, f := range files {
+		summary.FilesChecked++
+		summary.BytesChecked += 10
+		if f.Status != fileuploadv1.ScrubStatus_SCRUB_STATUS_OK {
+			summary.Damaged = append(summary.Damaged, f)
+		}
+		if err := stream.Send(&fileuploadv1.ScrubResponse{Payload: &fileuploadv1.ScrubResponse_Progress{Progress: &fileuploadv1.ScrubProgress{
+			File: f, FilesChecked: summary.FilesChecked, FilesTotal: int64(len(files)), BytesChecked: summary.BytesChecked,
+		}}}); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&fileuploadv1.ScrubResponse{Payload: &fileuploadv1.ScrubResponse_Summary{Summary: summary}})
+}
+
+func TestScrub(t *testing.T) {
+	url := newFakeServer(t, &fakeServer{})
+	var progress []ScrubProgress
+	report, err := New(Options{BaseURL: url}).Scrub(t.Context(), func(p ScrubProgress) { progress = append(progress, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 2 || progress[1].FilesChecked != 2 || progress[1].FilesTotal != 2 || progress[0].File.Status != ScrubOK {
+		t.Fatalf("progress %+v", progress)
+	}
+	want := ScrubbedFile{Name: "rotten.txt", Status: ScrubCorrupt, ExpectedSHA256: "bb", ActualSHA256: "cc"}
+	if report.FilesChecked != 2 || report.BytesChecked != 20 || len(report.Damaged) != 1 || report.Damaged[0] != want {
+		t.Fatalf("report %+v", report)
+	}
+}
+
+func TestScrubRunning(t *testing.T) {
+	url := newFakeServer(t, &fakeServer{failures: 1})
+	if _, err := New(Options{BaseURL: url}).Scrub(t.Context(), nil); connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Fatalf("scrub while another runs: %v, want unavailable", err)
+	}
+}
@@ -0,0 +1,140 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// segmentHasher hashes what is written to it in fixed-size segments, the
+// client side of a segmented commit. A nil segmentHasher discards writes.
+type segmentHasher struct {
+	size int64
+	cur  hash.Hash
+	n    int64 // bytes hashed into cur
+	sums []string
+}
+
+func newSegmentHasher(size int64) *segmentHasher {
+	if size <= 0 {
+		return nil
+	}
+	return &segmentHasher{size: size, cur: sha256.New()}
+}
+
+func (h *segmentHasher) Write(p []byte) (int, error) {
+	if h == nil {
+		return len(p), nil
+	}
+	written := len(p)
+	for len(p) > 0 {
+		n := min(int64(len(p)), h.size-h.n)
+		h.cur.Write(p[:n])
+		h.n += n
+		p = p[n:]
+		if h.n == h.size {
+			h.next()
+		}
+	}
+	return written, nil
+}
+
+func (h *segmentHasher) next() {
+	h.sums = append(h.sums, hex.EncodeToString(h.cur.Sum(nil)))
+	h.cur.Reset()
+	h.n = 0
+}
+
+// sum returns the hash of every segment, including a shorter last one
+func (h *segmentHasher) sum() []string {
+	if h.n > 0 {
+		h.next()
+	}
+	return h.sums
+}
+
+// CorruptSegmentsOf returns the segments the server found corrupt in a
+// segmented upload rejected with data_loss, or nil for any other error
+func CorruptSegmentsOf(err error) *fileuploadv1.CorruptSegments {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeDataLoss {
+		return nil
+	}
+	for _, detail := range connectErr.Details() {
+		msg, err := detail.Value()
+		if err != nil {
+			continue
+		}
+		if corrupt, ok := msg.(*fileuploadv1.CorruptSegments); ok {
+			return corrupt
+		}
+	}
+	return nil
+}
+
+// repairSegments re-sends the corrupt segments of a kept upload as ranged PUTs,
+// the last of which completes the file on the server
+func (c *Client) repairSegments(ctx context.Context, src io.ReaderAt, corrupt *fileuploadv1.CorruptSegments, hash string) (*Response, error) {
+	target := c.baseURL + "/files/" + url.PathEscape(corrupt.Filename)
+	for _, i := range corrupt.Indexes {
+		start := i * corrupt.SegmentSize
+		end := min(start+corrupt.SegmentSize, corrupt.TotalSize) - 1
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, io.NewSectionReader(src, start, end-start+1))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = end - start + 1
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, corrupt.TotalSize))
+		req.Header.Set("X-Content-Sha256", hash)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("re-send segment %d: %w", i, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("re-send segment %d: %w", i, err)
+		}
+		c.logf("Re-sent segment %d (bytes %d-%d): %s", i, start, end, resp.Status)
+
+		switch resp.StatusCode {
+		case http.StatusAccepted:
+			continue
+		case http.StatusCreated:
+			var stored fileuploadv1.UploadResponse
+			if err := protojson.Unmarshal(body, &stored); err != nil {
+				return nil, fmt.Errorf("decode repair response: %w", err)
+			}
+			if !stored.HashOk {
+				return nil, fmt.Errorf("hash mismatch after re-sending segments: local %s, remote %s", hash, stored.Sha256)
+			}
+			return &Response{
+				Message:        "Upload repaired and verified",
+				Size:           stored.Size,
+				HashOk:         true,
+				HashStatus:     HashVerified,
+				SHA256:         hash,
+				StoredFilename: stored.StoredFilename,
+				StoragePath:    stored.StoragePath,
+			}, nil
+		default:
+			return nil, fmt.Errorf("re-send segment %d: %s: %s", i, resp.Status, strings.TrimSpace(string(body)))
+		}
+	}
+	return nil, errors.New("server did not complete the repaired upload")
+}
@@ -0,0 +1,58 @@
+package uploadclient
+
+import (
+	"context"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// Stats are the totals of the files a server stores, see Client.Stats
+type Stats struct {
+	Files       int64
+	Bytes       int64
+	AverageSize int64
+	// Directories has the totals per first directory of the storage paths,
+	// sorted; "" holds the files stored in the upload directory itself
+	Directories []DirectoryStats
+	// Largest are the largest stored files, largest first
+	Largest []StoredFile
+	// ComputedAt is when the server computed the totals, which it reuses
+	// for a few seconds
+	ComputedAt time.Time
+}
+
+// DirectoryStats are the totals of the files stored under one directory
+type DirectoryStats struct {
+	Directory string
+	Files     int64
+	Bytes     int64
+}
+
+// Stats returns the totals of the stored files
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	resp, err := c.rpc.GetStats(ctx, &fileuploadv1.GetStatsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{
+		Files:       resp.TotalFiles,
+		Bytes:       resp.TotalBytes,
+		AverageSize: resp.AverageSize,
+		ComputedAt:  time.Unix(resp.ComputedUnix, 0),
+	}
+	for _, d := range resp.Directories {
+		stats.Directories = append(stats.Directories, DirectoryStats{Directory: d.Directory, Files: d.Files, Bytes: d.Bytes})
+	}
+	for _, f := range resp.LargestFiles {
+		stats.Largest = append(stats.Largest, StoredFile{
+			Name:        f.Filename,
+			Size:        f.Size,
+			SHA256:      f.Sha256,
+			Modified:    time.Unix(f.ModifiedUnix, 0),
+			StoragePath: f.StoragePath,
+			ExpiresAt:   unixTime(f.ExpiresUnix),
+		})
+	}
+	return stats, nil
+}
@@ -0,0 +1,52 @@
+package uploadclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// GetStats totals the stored files, all under docs/ like ListFilesStream
+func (s *fakeServer) GetStats(ctx context.Context, req *fileuploadv1.GetStatsRequest) (*fileuploadv1.GetStatsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &fileuploadv1.GetStatsResponse{ComputedUnix: 1700000000}
+	docs := &fileuploadv1.DirectoryStats{Directory: "docs"}
+	for name, content := range s.files {
+		size := int64(len(content))
+		resp.TotalFiles++
+		resp.TotalBytes += size
+		docs.Files++
+		docs.Bytes += size
+		if resp.LargestFiles == nil || size > resp.LargestFiles[0].Size {
+			resp.LargestFiles = []*fileuploadv1.FileInfo{{Filename: name, Size: size, StoragePath: "docs/" + name, ModifiedUnix: 1700000000}}
+		}
+	}
+	if resp.TotalFiles > 0 {
+		resp.AverageSize = resp.TotalBytes / resp.TotalFiles
+		resp.Directories = []*fileuploadv1.DirectoryStats{docs}
+	}
+	return resp, nil
+}
+
+func TestStats(t *testing.T) {
+	srv := &fakeServer{files: map[string]string{"a.txt": "hello", "b.txt": "hello world!"}}
+	stats, err := New(Options{BaseURL: newFakeServer(t, srv)}).Stats(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 2 || stats.Bytes != 17 || stats.AverageSize != 8 {
+		t.Fatalf("totals %+v", stats)
+	}
+	if len(stats.Directories) != 1 || stats.Directories[0] != (DirectoryStats{Directory: "docs", Files: 2, Bytes: 17}) {
+		t.Fatalf("directories %+v", stats.Directories)
+	}
+	if len(stats.Largest) != 1 || stats.Largest[0].Name != "b.txt" || stats.Largest[0].StoragePath != "docs/b.txt" || stats.Largest[0].Size != 12 {
+		t.Fatalf("largest %+v", stats.Largest)
+	}
+	if !stats.ComputedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("computed at %v", stats.ComputedAt)
+	}
+}
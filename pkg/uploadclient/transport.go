@@ -0,0 +1,94 @@
+package uploadclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// TLSOptions configure the connection settings of NewHTTPClient
+type TLSOptions struct {
+	// ConnectTimeout bounds dialing and the TLS handshake, DefaultConnectTimeout when 0
+	ConnectTimeout time.Duration
+	// CAFile is a PEM file with CA certificates to trust for https servers
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key for mutual TLS
+	CertFile, KeyFile string
+	// PingInterval sends an HTTP/2 PING after this long without frames from
+	// the server, keeping long streams alive through idle-sensitive proxies
+	// and NATs; 0 sends none
+	PingInterval time.Duration
+	// PingTimeout closes the connection when a PING is not answered within
+	// it, 15s when 0
+	PingTimeout time.Duration
+	// UnencryptedHTTP2 speaks HTTP/2 without TLS (h2c) to http:// servers,
+	// which UploadBidi needs there; https servers negotiate HTTP/2 anyway.
+	// Such a client cannot fall back to HTTP/1.1.
+	UnencryptedHTTP2 bool
+}
+
+// DefaultConnectTimeout is the dial and TLS handshake timeout of NewHTTPClient
+const DefaultConnectTimeout = 10 * time.Second
+
+// NewHTTPClient builds an HTTP client suited to uploads. Unlike
+// http.DefaultClient it bounds dialing and the TLS handshake, and it can trust
+// a private CA for servers behind self-signed certificates and present a
+// client certificate for mutual TLS. There is no overall request timeout
+// since a streaming upload may legitimately take a long time; use context
+// deadlines per call instead.
+func NewHTTPClient(opts TLSOptions) (*http.Client, error) {
+	connectTimeout := opts.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = DefaultConnectTimeout
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		// presented to servers that require client certificates (mTLS)
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no PEM certificates found in CA file " + opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ForceAttemptHTTP2:     true, // negotiated via ALPN over https, plain http stays on HTTP/1.1 unless UnencryptedHTTP2
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		HTTP2: &http.HTTP2Config{
+			SendPingTimeout: opts.PingInterval,
+			PingTimeout:     opts.PingTimeout,
+		},
+	}
+	if opts.UnencryptedHTTP2 {
+		// with HTTP/1.1 allowed too, http:// URLs would use it
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,236 @@
+package uploadclient
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/pkg/uploadserver"
+)
+
+func TestNewHTTPClientTrustsCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(srv.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(block), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewHTTPClient(TLSOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("a self-signed server was trusted without a CA file")
+	}
+	if client, err = NewHTTPClient(TLSOptions{CAFile: caFile}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("server signed by the CA file: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewHTTPClientRefusesBadCAFile(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(dir, "missing.pem"), notPEM} {
+		if _, err := NewHTTPClient(TLSOptions{CAFile: path}); err == nil {
+			t.Errorf("CA file %s accepted", filepath.Base(path))
+		}
+	}
+}
+
+func TestNewHTTPClientConnectTimeout(t *testing.T) {
+	// accepts connections and never answers the TLS handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// until the client gives up
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	client, err := NewHTTPClient(TLSOptions{ConnectTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = client.Get("https://" + ln.Addr().String())
+	if err == nil || !strings.Contains(err.Error(), "handshake timeout") {
+		t.Fatalf("stalled handshake: %v, want a handshake timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("stalled handshake gave up after %v", elapsed)
+	}
+}
+
+func TestNewHTTPClientRefusesBadClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "client.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, files := range [][2]string{{notPEM, notPEM}, {notPEM, ""}, {"", filepath.Join(dir, "missing.key")}} {
+		if _, err := NewHTTPClient(TLSOptions{CertFile: files[0], KeyFile: files[1]}); err == nil {
+			t.Errorf("client certificate %q with key %q accepted", files[0], files[1])
+		}
+	}
+}
+
+// idleProxy forwards connections to target and drops those without traffic
+// either way for idle, like a load balancer or NAT would. It returns its address.
+func idleProxy(t *testing.T, target string, idle time.Duration) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			in, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			out, err := net.Dial("tcp", target)
+			if err != nil {
+				in.Close()
+				continue
+			}
+			var last atomic.Int64
+			last.Store(time.Now().UnixNano())
+			forward := func(dst, src net.Conn) {
+				buf := make([]byte, 32<<10)
+				for {
+					n, err := src.Read(buf)
+					if n > 0 {
+						last.Store(time.Now().UnixNano())
+						dst.Write(buf[:n])
+					}
+					if err != nil {
+						in.Close()
+						out.Close()
+						return
+					}
+				}
+			}
+			go forward(out, in)
+			go forward(in, out)
+			go func() {
+				for range time.Tick(idle / 4) {
+					if time.Since(time.Unix(0, last.Load())) > idle {
+						in.Close()
+						out.Close()
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestNewHTTPClientPingsKeepLongStreamsAlive(t *testing.T) {
+	// the server sends no pings of its own
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		fmt.Fprintf(w, "%s %d", r.Proto, n)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(block), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(opts TLSOptions) (string, error) {
+		client, err := NewHTTPClient(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxy := idleProxy(t, srv.Listener.Addr().String(), 300*time.Millisecond)
+		// the two halves of the body are a second apart
+		pr, pw := io.Pipe()
+		go func() {
+			io.WriteString(pw, "first ")
+			time.Sleep(time.Second)
+			io.WriteString(pw, "second")
+			pw.Close()
+		}()
+		resp, err := client.Post("https://"+proxy+"/", "application/octet-stream", pr)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	if got, err := upload(TLSOptions{CAFile: caFile}); err == nil {
+		t.Fatalf("a stream idle for longer than the proxy allows survived without pings: %q", got)
+	}
+	got, err := upload(TLSOptions{CAFile: caFile, PingInterval: 50 * time.Millisecond, PingTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("stream with client pings: %v", err)
+	}
+	if got != "HTTP/2.0 12" {
+		t.Fatalf("server answered %q, want all 12 bytes over HTTP/2", got)
+	}
+}
+
+func TestAckWindowOverUnencryptedHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	handler, err := uploadserver.New(uploadserver.Config{Dir: dir, Context: t.Context()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// plain http as cmd/server serves it without -tls-cert
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	httpClient, err := NewHTTPClient(TLSOptions{UnencryptedHTTP2: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(Options{BaseURL: srv.URL, HTTPClient: httpClient, ChunkSize: 4})
+	resp, err := c.UploadFile(t.Context(), writeTemp(t, "sent over h2c"), UploadOptions{Name: "bidi.txt", AckWindow: 2})
+	if err != nil {
+		t.Fatalf("UploadBidi over plain http: %v", err)
+	}
+	if !resp.HashOk {
+		t.Fatalf("response %+v", resp)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "bidi.txt")); string(got) != "sent over h2c" {
+		t.Fatalf("stored %q", got)
+	}
+}
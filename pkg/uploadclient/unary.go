@@ -0,0 +1,109 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// DefaultUnaryThreshold is the largest file UploadOptions.AutoUnary sends in
+// one UploadFile call when the server allows more, since the call holds the
+// whole file in memory on both sides
+const DefaultUnaryThreshold = 8 << 20
+
+// unaryMessageReserve is left for the fields of an UploadFile request other
+// than its data, in a message of the server's largest size
+const unaryMessageReserve = 64 << 10
+
+// wantsUnary reports whether AutoUnary may send a file in one UploadFile
+// call: hash-only, segmented and acknowledged uploads need a stream
+func (o UploadOptions) wantsUnary() bool {
+	return o.AutoUnary && !o.HashOnly && o.SegmentSize == 0 && o.AckWindow == 0
+}
+
+// unaryThreshold returns the largest file AutoUnary sends in one UploadFile
+// call: opts.UnaryThreshold, or what the server accepts up to
+// DefaultUnaryThreshold
+func (c *Client) unaryThreshold(ctx context.Context, opts UploadOptions) (int64, error) {
+	if opts.UnaryThreshold > 0 {
+		return opts.UnaryThreshold, nil
+	}
+	info, err := c.serverInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	limit := int64(DefaultUnaryThreshold)
+	if info.MaxUnaryUploadBytes > 0 {
+		limit = min(limit, info.MaxUnaryUploadBytes)
+	}
+	if info.MaxMessageBytes > 0 {
+		limit = min(limit, info.MaxMessageBytes-unaryMessageReserve)
+	}
+	return limit, nil
+}
+
+// serverInfo returns the server's GetServerInfo answer, asked once per Client
+func (c *Client) serverInfo(ctx context.Context) (*fileuploadv1.GetServerInfoResponse, error) {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	if c.info == nil {
+		info, err := c.rpc.GetServerInfo(ctx, &fileuploadv1.GetServerInfoRequest{})
+		if err != nil {
+			return nil, err
+		}
+		c.info = info
+	}
+	return c.info, nil
+}
+
+// uploadUnary sends the size bytes of f in one UploadFile call carrying their
+// SHA-256. UploadFile stores the data whatever its hash and only reports
+// whether it matched, so content differing from opts.ExpectedSHA256 is
+// refused before it is sent, and a mismatch the server reports is an error
+// like the checksum mismatch of a stream.
+func (c *Client) uploadUnary(ctx context.Context, f *os.File, size int64, opts UploadOptions) (*Response, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(f, 0, size), data); err != nil {
+		return nil, fmt.Errorf("read input: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if opts.ExpectedSHA256 != "" && opts.ExpectedSHA256 != hash {
+		return nil, fmt.Errorf("content has hash %s, expected %s", hash, opts.ExpectedSHA256)
+	}
+	opts.reportProgress(0)
+	resp, err := c.rpc.UploadFile(ctx, &fileuploadv1.UploadFileRequest{
+		Data:          data,
+		Filename:      opts.Name,
+		Title:         opts.Title,
+		Sha256:        hash,
+		DryRun:        opts.DryRun,
+		IfMatchSha256: opts.IfMatchSHA256,
+		Extract:       opts.Extract,
+		TtlSeconds:    ttlSeconds(opts.TTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.HashOk {
+		return nil, fmt.Errorf("server computed hash %s for %s, not the %s sent: the content was damaged on the way", resp.Sha256, opts.Name, hash)
+	}
+	opts.reportProgress(size)
+	c.logf("Sent %d bytes in one UploadFile call", size)
+	return &Response{
+		Message:        resp.Message,
+		Size:           resp.Size,
+		HashOk:         resp.HashOk,
+		HashStatus:     hashStatus(resp.HashStatus),
+		SHA256:         hash,
+		StoredFilename: resp.StoredFilename,
+		StoragePath:    resp.StoragePath,
+		ExtractedDir:   resp.ExtractedDir,
+		ExpiresAt:      unixTime(resp.ExpiresUnix),
+	}, nil
+}
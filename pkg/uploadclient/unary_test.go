@@ -0,0 +1,158 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// GetServerInfo answers info
+func (s *fakeServer) GetServerInfo(ctx context.Context, req *fileuploadv1.GetServerInfoRequest) (*fileuploadv1.GetServerInfoResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.infoCalls++
+	if s.info == nil {
+		return nil, connect.NewError(connect.CodeUnimplemented, errors.New("no server info"))
+	}
+	return s.info, nil
+}
+
+// uploadWhole stores the data of an UploadFile call without an offset and
+// reports whether it matches req.Sha256, like the server stores it either way
+func (s *fakeServer) uploadWhole(req *fileuploadv1.UploadFileRequest) *fileuploadv1.UploadResponse {
+	s.unary = append(s.unary, len(req.Data))
+	data := req.Data
+	if s.damage {
+		data = append(slices.Clone(data), '!')
+	}
+	if !req.DryRun {
+		s.files[req.Filename] = string(data)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	status := fileuploadv1.HashStatus_HASH_STATUS_VERIFIED
+	if hash != req.Sha256 {
+		status = fileuploadv1.HashStatus_HASH_STATUS_MISMATCH
+	}
+	return &fileuploadv1.UploadResponse{
+		Message: "ok", Size: int64(len(data)), HashOk: hash == req.Sha256, HashStatus: status,
+		Sha256: hash, StoredFilename: req.Filename,
+	}
+}
+
+func TestAutoUnaryBoundary(t *testing.T) {
+	srv := &fakeServer{info: &fileuploadv1.GetServerInfoResponse{MaxUnaryUploadBytes: 10}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	for _, content := range []string{"0123456789", "0123456789a"} {
+		resp, err := client.UploadFile(t.Context(), writeTemp(t, content), UploadOptions{Name: "a.txt", AutoUnary: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.HashOk || srv.files["a.txt"] != content {
+			t.Fatalf("%d bytes: response %+v, stored %q", len(content), resp, srv.files["a.txt"])
+		}
+	}
+	// the file at the limit went in one call, the one past it was streamed
+	if !slices.Equal(srv.unary, []int{10}) || srv.calls != 1 {
+		t.Fatalf("UploadFile calls of %v bytes and %d streams, want [10] and 1", srv.unary, srv.calls)
+	}
+	if srv.infoCalls != 1 {
+		t.Fatalf("GetServerInfo called %d times, want once per Client", srv.infoCalls)
+	}
+}
+
+func TestAutoUnaryMessageLimit(t *testing.T) {
+	srv := &fakeServer{info: &fileuploadv1.GetServerInfoResponse{MaxUnaryUploadBytes: 1 << 20, MaxMessageBytes: unaryMessageReserve + 5}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	for _, content := range []string{"12345", "123456"} {
+		if _, err := client.UploadFile(t.Context(), writeTemp(t, content), UploadOptions{Name: "a.txt", AutoUnary: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(srv.unary, []int{5}) || srv.calls != 1 {
+		t.Fatalf("UploadFile calls of %v bytes and %d streams, want [5] and 1", srv.unary, srv.calls)
+	}
+}
+
+func TestAutoUnaryThresholdOverride(t *testing.T) {
+	srv := &fakeServer{info: &fileuploadv1.GetServerInfoResponse{MaxUnaryUploadBytes: 100}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	for _, content := range []string{"abc", "abcd"} {
+		opts := UploadOptions{Name: "a.txt", AutoUnary: true, UnaryThreshold: 3}
+		if _, err := client.UploadFile(t.Context(), writeTemp(t, content), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(srv.unary, []int{3}) || srv.calls != 1 {
+		t.Fatalf("UploadFile calls of %v bytes and %d streams, want [3] and 1", srv.unary, srv.calls)
+	}
+	if srv.infoCalls != 0 {
+		t.Fatalf("GetServerInfo called %d times with a threshold set", srv.infoCalls)
+	}
+}
+
+func TestAutoUnaryDefaultThreshold(t *testing.T) {
+	client := New(Options{BaseURL: newFakeServer(t, &fakeServer{info: &fileuploadv1.GetServerInfoResponse{}})})
+	threshold, err := client.unaryThreshold(t.Context(), UploadOptions{})
+	if err != nil || threshold != DefaultUnaryThreshold {
+		t.Fatalf("threshold %d, %v; want DefaultUnaryThreshold without server limits", threshold, err)
+	}
+}
+
+func TestAutoUnaryStreamsWithoutServerInfo(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	if _, err := client.UploadFile(t.Context(), writeTemp(t, "data"), UploadOptions{Name: "a.txt", AutoUnary: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.unary) != 0 || srv.calls != 1 {
+		t.Fatalf("UploadFile calls of %v bytes and %d streams, want the file streamed", srv.unary, srv.calls)
+	}
+}
+
+func TestAutoUnaryStreamsWhatNeedsAStream(t *testing.T) {
+	srv := &fakeServer{info: &fileuploadv1.GetServerInfoResponse{}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	for _, opts := range []UploadOptions{
+		{Name: "a.txt", AutoUnary: true, HashOnly: true},
+		{Name: "a.txt", AutoUnary: true, SegmentSize: 4},
+	} {
+		if _, err := client.UploadFile(t.Context(), writeTemp(t, "data"), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(srv.unary) != 0 {
+		t.Fatalf("UploadFile calls of %v bytes, want every upload streamed", srv.unary)
+	}
+}
+
+func TestAutoUnaryRefusesContentNotMatchingExpectedHash(t *testing.T) {
+	srv := &fakeServer{info: &fileuploadv1.GetServerInfoResponse{}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	sum := sha256.Sum256([]byte("expected"))
+	_, err := client.UploadFile(t.Context(), writeTemp(t, "actual"), UploadOptions{
+		Name: "a.txt", AutoUnary: true, ExpectedSHA256: hex.EncodeToString(sum[:]),
+	})
+	if err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Fatalf("upload of content not matching the expected hash: %v", err)
+	}
+	if len(srv.unary) != 0 || srv.files["a.txt"] != "" {
+		t.Fatalf("content sent (%v) or stored (%q)", srv.unary, srv.files["a.txt"])
+	}
+}
+
+func TestAutoUnaryFailsOnUnconfirmedHash(t *testing.T) {
+	srv := &fakeServer{info: &fileuploadv1.GetServerInfoResponse{}, damage: true}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	if _, err := client.UploadFile(t.Context(), writeTemp(t, "data"), UploadOptions{Name: "a.txt", AutoUnary: true}); err == nil {
+		t.Fatal("upload of damaged content succeeded")
+	}
+}
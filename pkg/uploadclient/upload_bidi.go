@@ -0,0 +1,214 @@
+package uploadclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// uploadSender is the client side of Upload or UploadBidi
+type uploadSender interface {
+	Send(*fileuploadv1.UploadRequest) error
+	// chunkSent is called after each chunk was sent; it blocks while too many
+	// chunks are unacknowledged
+	chunkSent() error
+	CloseAndReceive() (*fileuploadv1.UploadResponse, error)
+}
+
+// openUpload starts an Upload stream, or an UploadBidi one with opts.AckWindow
+func (c *Client) openUpload(ctx context.Context, opts UploadOptions) (uploadSender, error) {
+	if opts.AckWindow <= 0 {
+		stream, err := c.rpc.Upload(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return simpleUpload{stream}, nil
+	}
+	stream, err := c.rpc.UploadBidi(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &bidiUpload{
+		stream: stream,
+		window: opts.AckWindow,
+		every:  ackEvery(opts.AckWindow),
+		logf:   c.logf,
+	}, nil
+}
+
+// ackEvery is how many chunks the server is asked to acknowledge at once
+// for a window of that many unacknowledged chunks. Half the window keeps the
+// client sending while an ack is on its way.
+func ackEvery(window int) int {
+	return max(1, window/2)
+}
+
+// crc32c is the table of the chunk_crc32c checksums
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// simpleUpload sends without acknowledgements, over Upload
+type simpleUpload struct {
+	*connect.ClientStreamForClientSimple[fileuploadv1.UploadRequest, fileuploadv1.UploadResponse]
+}
+
+func (simpleUpload) chunkSent() error { return nil }
+
+// bidiUpload keeps at most window chunks unacknowledged, over UploadBidi.
+// Chunks carry their CRC and index and are kept until acknowledged, so one
+// the server NACKs is sent again, with every chunk sent after it.
+type bidiUpload struct {
+	stream   *connect.BidiStreamForClientSimple[fileuploadv1.UploadRequest, fileuploadv1.UploadBidiResponse]
+	window   int
+	every    int
+	pending  []sentChunk // unacknowledged chunks, in order
+	next     uint64      // index of the next chunk
+	sent     int64       // offset after the last chunk
+	acked    int64       // offset of the last ack
+	statuses bool        // the server reports chunk statuses, so may NACK
+	commit   *fileuploadv1.UploadRequest
+	closed   bool
+	result   *fileuploadv1.UploadResponse
+	logf     func(format string, args ...any)
+}
+
+// sentChunk is a chunk request awaiting its ack
+type sentChunk struct {
+	req    *fileuploadv1.UploadRequest
+	offset int64 // offset after the chunk
+}
+
+func (b *bidiUpload) Send(req *fileuploadv1.UploadRequest) error {
+	switch payload := req.Payload.(type) {
+	case *fileuploadv1.UploadRequest_Metadata:
+		payload.Metadata.AckEvery = uint32(b.every)
+	case *fileuploadv1.UploadRequest_Chunk:
+		// the caller reuses the buffer of the chunk
+		chunk := bytes.Clone(payload.Chunk)
+		req = &fileuploadv1.UploadRequest{
+			Payload:     &fileuploadv1.UploadRequest_Chunk{Chunk: chunk},
+			ChunkCrc32C: proto.Uint32(crc32.Checksum(chunk, crc32c)),
+			ChunkIndex:  proto.Uint64(b.next),
+		}
+		b.next++
+		b.sent += int64(len(chunk))
+		b.pending = append(b.pending, sentChunk{req: req, offset: b.sent})
+	default:
+		b.commit = req
+		if err := b.stream.Send(req); err != nil {
+			return err
+		}
+		// the server acknowledges the chunks left at the commit, any NACKed
+		// must be sent again before the requests end
+		for b.statuses && len(b.pending) > 0 && b.result == nil {
+			if err := b.receiveAck(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return b.stream.Send(req)
+}
+
+func (b *bidiUpload) chunkSent() error {
+	for len(b.pending) >= b.window {
+		if b.result != nil {
+			return errors.New("server answered before the commit")
+		}
+		if err := b.receiveAck(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiveAck waits for the next ack and checks it against the offsets sent,
+// or sends the chunks again from one the server NACKs. A result ends the
+// acks, the server answers before the commit only with an error.
+func (b *bidiUpload) receiveAck() error {
+	msg, err := b.stream.Receive()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("upload stream ended without a result")
+		}
+		return err
+	}
+	if result := msg.GetResult(); result != nil {
+		b.result = result
+		return nil
+	}
+	ack := msg.GetAck()
+	switch ack.GetStatus() {
+	case fileuploadv1.ChunkStatus_CHUNK_STATUS_CORRUPT:
+		b.statuses = true
+		return b.resend(ack)
+	case fileuploadv1.ChunkStatus_CHUNK_STATUS_WRITTEN:
+		b.statuses = true
+	}
+	offset := ack.GetOffset()
+	for i, chunk := range b.pending {
+		if chunk.offset == offset {
+			b.pending = b.pending[i+1:]
+			b.acked = offset
+			return nil
+		}
+	}
+	return fmt.Errorf("server acknowledged offset %d, not the end of a chunk among the %d bytes sent", offset, b.sent)
+}
+
+// resend sends the chunk NACKed by ack again, with the chunks and commit
+// sent after it, which the server dropped
+func (b *bidiUpload) resend(ack *fileuploadv1.UploadAck) error {
+	i := slices.IndexFunc(b.pending, func(chunk sentChunk) bool {
+		return chunk.req.GetChunkIndex() == ack.GetChunkIndex()
+	})
+	if i < 0 || b.offsetBefore(i) != ack.GetOffset() {
+		return fmt.Errorf("server NACKed chunk %d at offset %d, not an unacknowledged chunk", ack.GetChunkIndex(), ack.GetOffset())
+	}
+	if b.closed {
+		return fmt.Errorf("server NACKed chunk %d after the upload was closed", ack.GetChunkIndex())
+	}
+	b.logf("Server NACKed chunk %d, sending it again with the %d after it", ack.GetChunkIndex(), len(b.pending)-i-1)
+	for _, chunk := range b.pending[i:] {
+		if err := b.stream.Send(chunk.req); err != nil {
+			return err
+		}
+	}
+	if b.commit != nil {
+		return b.stream.Send(b.commit)
+	}
+	return nil
+}
+
+// offsetBefore returns the offset before the ith unacknowledged chunk
+func (b *bidiUpload) offsetBefore(i int) int64 {
+	if i == 0 {
+		return b.acked
+	}
+	return b.pending[i-1].offset
+}
+
+// CloseAndReceive ends the requests and reads the remaining acks up to the
+// result
+func (b *bidiUpload) CloseAndReceive() (*fileuploadv1.UploadResponse, error) {
+	defer b.stream.CloseResponse()
+	b.closed = true
+	if err := b.stream.CloseRequest(); err != nil {
+		return nil, err
+	}
+	for b.result == nil {
+		if err := b.receiveAck(); err != nil {
+			return nil, err
+		}
+	}
+	b.logf("Server acknowledged %d bytes", b.acked)
+	return b.result, nil
+}
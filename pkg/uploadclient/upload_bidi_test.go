@@ -0,0 +1,179 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// UploadBidi acknowledges every ack_every chunks and stores the upload. The
+// chunks listed in nack are NACKed once, the chunks and commit that follow
+// them dropped until they are sent again.
+func (s *fakeServer) UploadBidi(ctx context.Context, stream *connect.BidiStream[fileuploadv1.UploadRequest, fileuploadv1.UploadBidiResponse]) error {
+	var (
+		data      []byte
+		commit    string
+		every     = 1
+		unacked   int
+		next      uint64
+		resending bool
+		metadata  *fileuploadv1.UploadMetadata
+	)
+	sendAck := func(ack *fileuploadv1.UploadAck) error {
+		return stream.Send(&fileuploadv1.UploadBidiResponse{
+			Payload: &fileuploadv1.UploadBidiResponse_Ack{Ack: ack},
+		})
+	}
+	written := func() *fileuploadv1.UploadAck {
+		offset := int64(len(data))
+		if s.wrongAck {
+			offset++
+		}
+		return &fileuploadv1.UploadAck{Offset: offset, ChunkIndex: next - 1, Status: fileuploadv1.ChunkStatus_CHUNK_STATUS_WRITTEN}
+	}
+	for {
+		msg, err := stream.Receive()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch p := msg.Payload.(type) {
+		case *fileuploadv1.UploadRequest_Metadata:
+			metadata = p.Metadata
+			every = max(1, int(p.Metadata.AckEvery))
+		case *fileuploadv1.UploadRequest_Chunk:
+			if resending && msg.GetChunkIndex() != next {
+				continue
+			}
+			resending = false
+			if msg.ChunkCrc32C != nil && crc32.Checksum(p.Chunk, crc32c) != msg.GetChunkCrc32C() {
+				return connect.NewError(connect.CodeDataLoss, errors.New("chunk failed its CRC32C check"))
+			}
+			s.mu.Lock()
+			i := slices.Index(s.nack, next)
+			if i >= 0 {
+				s.nack = slices.Delete(s.nack, i, i+1)
+				s.nacked = append(s.nacked, next)
+			}
+			s.mu.Unlock()
+			if i >= 0 {
+				resending = true
+				if err := sendAck(&fileuploadv1.UploadAck{Offset: int64(len(data)), ChunkIndex: next, Status: fileuploadv1.ChunkStatus_CHUNK_STATUS_CORRUPT}); err != nil {
+					return err
+				}
+				continue
+			}
+			data = append(data, p.Chunk...)
+			next++
+			unacked++
+			s.mu.Lock()
+			s.maxUnacked = max(s.maxUnacked, unacked)
+			s.mu.Unlock()
+			if unacked%every != 0 {
+				continue
+			}
+			unacked = 0
+			if err := sendAck(written()); err != nil {
+				return err
+			}
+		case *fileuploadv1.UploadRequest_FinishCommit:
+			if resending {
+				continue
+			}
+			commit = p.FinishCommit
+			if unacked > 0 {
+				unacked = 0
+				if err := sendAck(written()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	s.mu.Lock()
+	s.metadata = metadata
+	s.files[metadata.Filename] = string(data)
+	s.mu.Unlock()
+	return stream.Send(&fileuploadv1.UploadBidiResponse{
+		Payload: &fileuploadv1.UploadBidiResponse_Result{Result: &fileuploadv1.UploadResponse{
+			Size:   int64(len(data)),
+			HashOk: hash == commit,
+			Sha256: hash,
+		}},
+	})
+}
+
+// newBidiClient returns a client of url speaking HTTP/2 without TLS
+func newBidiClient(t *testing.T, url string) *Client {
+	t.Helper()
+	httpClient, err := NewHTTPClient(TLSOptions{UnencryptedHTTP2: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(Options{BaseURL: url, HTTPClient: httpClient, ChunkSize: 4})
+}
+
+func TestUploadFileAckWindow(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	srv := &fakeServer{}
+	url := newFakeServer(t, srv)
+	c := newBidiClient(t, url)
+
+	resp, err := c.UploadFile(t.Context(), writeTemp(t, content), UploadOptions{Name: "a.txt", AckWindow: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.HashOk || srv.files["a.txt"] != content {
+		t.Fatalf("response %+v, stored %q", resp, srv.files["a.txt"])
+	}
+	// acks are asked for every half window, and the client never gets
+	// further ahead of them than the window
+	if srv.metadata.AckEvery != 3 {
+		t.Errorf("ack_every %d, want half the window", srv.metadata.AckEvery)
+	}
+	if srv.maxUnacked > 6 {
+		t.Errorf("server held %d unacknowledged chunks, window is 6", srv.maxUnacked)
+	}
+}
+
+func TestUploadFileAckMismatch(t *testing.T) {
+	srv := &fakeServer{wrongAck: true}
+	url := newFakeServer(t, srv)
+	c := newBidiClient(t, url)
+
+	_, err := c.UploadFile(t.Context(), writeTemp(t, strings.Repeat("x", 40)), UploadOptions{Name: "a.txt", AckWindow: 2})
+	if err == nil || !strings.Contains(err.Error(), "acknowledged offset") {
+		t.Fatalf("ack of bytes never sent: %v", err)
+	}
+}
+
+func TestUploadFileResendsNackedChunks(t *testing.T) {
+	content := strings.Repeat("0123456789", 4)
+	srv := &fakeServer{nack: []uint64{1, 9}}
+	c := newBidiClient(t, newFakeServer(t, srv))
+
+	resp, err := c.UploadFile(t.Context(), writeTemp(t, content), UploadOptions{Name: "a.txt", AckWindow: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// chunk 9 is the last: the client waits for its ack after the commit
+	if !slices.Equal(srv.nacked, []uint64{1, 9}) || len(srv.nack) != 0 {
+		t.Fatalf("NACKed %v, left %v", srv.nacked, srv.nack)
+	}
+	if !resp.HashOk || srv.files["a.txt"] != content {
+		t.Fatalf("response %+v, stored %q", resp, srv.files["a.txt"])
+	}
+}
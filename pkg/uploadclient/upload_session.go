@@ -0,0 +1,159 @@
+package uploadclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// uploadSessionHeader names the session a call belongs to, for load balancers
+const uploadSessionHeader = "Upload-Session"
+
+// uploadSession sends f through a CreateUpload session. With opts.StateFile,
+// a recorded session of the same unchanged file is resumed from the offset
+// the server holds, and a new one is created when the server lost it.
+func (c *Client) uploadSession(ctx context.Context, f *os.File, info os.FileInfo, opts UploadOptions) (*Response, error) {
+	if opts.DryRun || opts.HashOnly || opts.SegmentSize > 0 || opts.Extract || opts.TTL > 0 || opts.IfMatchSHA256 != "" {
+		return nil, errors.New("a session upload cannot be a dry run, hash only, segmented, extracted, expiring or conditional")
+	}
+	state := uploadState{Name: opts.Name, Size: info.Size(), Modified: info.ModTime().UnixNano()}
+
+	var (
+		sess *fileuploadv1.UploadSession
+		err  error
+	)
+	if opts.StateFile != "" {
+		saved, err := readUploadState(opts.StateFile)
+		if err == nil && saved.UploadID != "" && saved.Name == state.Name && saved.Size == state.Size && saved.Modified == state.Modified {
+			sess, err = c.rpc.GetUploadSession(ctx, &fileuploadv1.GetUploadSessionRequest{UploadId: saved.UploadID})
+			switch {
+			case connect.CodeOf(err) == connect.CodeNotFound:
+				c.logf("Upload session %s is gone, starting over", saved.UploadID)
+			case err != nil:
+				return nil, fmt.Errorf("get upload session: %w", err)
+			default:
+				state.SHA256 = saved.SHA256
+				c.logf("Resuming session %s at byte %d of %d", sess.UploadId, sess.Offset, state.Size)
+			}
+		}
+	}
+	if state.SHA256 == "" {
+		if state.SHA256, err = hashPrefix(f, state.Size); err != nil {
+			return nil, fmt.Errorf("read input: %w", err)
+		}
+	}
+	if opts.ExpectedSHA256 != "" && opts.ExpectedSHA256 != state.SHA256 {
+		return nil, fmt.Errorf("content has hash %s, expected %s", state.SHA256, opts.ExpectedSHA256)
+	}
+	if sess == nil {
+		sess, err = c.rpc.CreateUpload(ctx, &fileuploadv1.CreateUploadRequest{
+			Filename: opts.Name,
+			Title:    opts.Title,
+			Size:     state.Size,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create upload: %w", err)
+		}
+		c.logf("Created upload session %s", sess.UploadId)
+	}
+	state.UploadID = sess.UploadId
+	offset := sess.Offset
+	saveState := func() error {
+		if opts.StateFile == "" {
+			return nil
+		}
+		state.Acked = offset
+		if err := writeUploadState(opts.StateFile, state); err != nil {
+			return fmt.Errorf("write upload state: %w", err)
+		}
+		return nil
+	}
+	if err := saveState(); err != nil {
+		return nil, err
+	}
+	opts.reportProgress(offset)
+
+	// the chunk completing the session stores the file; when every byte is
+	// already there, as for an empty file, an empty chunk completes it
+	var stored *fileuploadv1.UploadResponse
+	sizer := c.newChunkSizer()
+	buf := make([]byte, sizer.max)
+	for stored == nil {
+		n, err := f.ReadAt(buf[:min(int64(sizer.size), state.Size-offset)], offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("read input: %w", err)
+		}
+		start := time.Now()
+		resp, err := c.rpc.UploadChunk(ctx, &fileuploadv1.UploadChunkRequest{
+			UploadId: sess.UploadId,
+			Offset:   offset,
+			Data:     buf[:n],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("send chunk at byte %d: %w", offset, err)
+		}
+		if sizer.observe(n, time.Since(start)) {
+			c.logf("Chunk size now %d bytes", sizer.size)
+		}
+		if resp.Stored == nil && resp.Offset >= state.Size {
+			return nil, fmt.Errorf("server holds all %d bytes of session %s but stored no file", state.Size, sess.UploadId)
+		}
+		offset = resp.Offset
+		stored = resp.Stored
+		opts.reportProgress(offset)
+		if err := saveState(); err != nil {
+			return nil, err
+		}
+	}
+	c.logf("Sent %d bytes in session %s", state.Size, sess.UploadId)
+
+	if opts.StateFile != "" {
+		if err := os.Remove(opts.StateFile); err != nil {
+			c.logf("Could not remove upload state %s: %v", opts.StateFile, err)
+		}
+	}
+	// the server hashes what it stored, the client checks it
+	if stored.Sha256 != state.SHA256 {
+		return nil, fmt.Errorf("server stored content with hash %s, sent %s", stored.Sha256, state.SHA256)
+	}
+	return &Response{
+		Message:        stored.Message,
+		Size:           state.Size,
+		HashOk:         true,
+		HashStatus:     HashVerified,
+		SHA256:         state.SHA256,
+		StoredFilename: stored.StoredFilename,
+		StoragePath:    stored.StoragePath,
+	}, nil
+}
+
+// sessionRouting sets the Upload-Session header of the calls of a session
+// from their upload ID
+type sessionRouting struct{}
+
+func (sessionRouting) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		switch msg := req.Any().(type) {
+		case *fileuploadv1.UploadChunkRequest:
+			req.Header().Set(uploadSessionHeader, msg.UploadId)
+		case *fileuploadv1.GetUploadSessionRequest:
+			req.Header().Set(uploadSessionHeader, msg.UploadId)
+		}
+		return next(ctx, req)
+	}
+}
+
+func (sessionRouting) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (sessionRouting) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
@@ -0,0 +1,185 @@
+package uploadclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// CreateUpload starts a session whose bytes are kept in partial
+func (s *fakeServer) CreateUpload(ctx context.Context, req *fileuploadv1.CreateUploadRequest) (*fileuploadv1.UploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = map[string]*fileuploadv1.UploadSession{}
+		s.partial = map[string][]byte{}
+	}
+	sess := &fileuploadv1.UploadSession{UploadId: fmt.Sprintf("session-%d", len(s.sessions)+1), Filename: req.Filename, Size: req.Size}
+	s.sessions[sess.UploadId] = sess
+	return sess, nil
+}
+
+// GetUploadSession reports the bytes a session holds
+func (s *fakeServer) GetUploadSession(ctx context.Context, req *fileuploadv1.GetUploadSessionRequest) (*fileuploadv1.UploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routed = append(s.routed, routedSession(ctx))
+	sess, ok := s.sessions[req.UploadId]
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("upload not found"))
+	}
+	return &fileuploadv1.UploadSession{UploadId: sess.UploadId, Filename: sess.Filename, Size: sess.Size, Offset: int64(len(s.partial[sess.UploadId]))}, nil
+}
+
+// UploadChunk appends to a session and stores its file once complete
+func (s *fakeServer) UploadChunk(ctx context.Context, req *fileuploadv1.UploadChunkRequest) (*fileuploadv1.UploadChunkResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routed = append(s.routed, routedSession(ctx))
+	sess, ok := s.sessions[req.UploadId]
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("upload not found"))
+	}
+	data := s.partial[req.UploadId]
+	if req.Offset != int64(len(data)) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("offset does not match the current offset"))
+	}
+	data = append(data, req.Data...)
+	s.partial[req.UploadId] = data
+	s.chunks = append(s.chunks, int(req.Offset))
+	if s.crash != nil && len(s.chunks) == s.crashAfter {
+		s.crash()
+	}
+	if int64(len(data)) < sess.Size {
+		return &fileuploadv1.UploadChunkResponse{Offset: int64(len(data))}, nil
+	}
+	delete(s.sessions, req.UploadId)
+	delete(s.partial, req.UploadId)
+	s.files[sess.Filename] = string(data)
+	sum := sha256.Sum256(data)
+	return &fileuploadv1.UploadChunkResponse{Offset: int64(len(data)), Stored: &fileuploadv1.UploadResponse{
+		Message: "ok", Size: int64(len(data)), Sha256: hex.EncodeToString(sum[:]), StoredFilename: sess.Filename,
+	}}, nil
+}
+
+// routedSession returns the Upload-Session header of a call
+func routedSession(ctx context.Context) string {
+	info, _ := connect.CallInfoForHandlerContext(ctx)
+	return info.RequestHeader().Get(uploadSessionHeader)
+}
+
+func TestUploadFileSession(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv), ChunkSize: 4})
+	resp, err := client.UploadFile(t.Context(), writeTemp(t, "hello session"), UploadOptions{Name: "s.txt", Session: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("hello session"))
+	if !resp.HashOk || resp.HashStatus != HashVerified || resp.SHA256 != hex.EncodeToString(sum[:]) || resp.StoredFilename != "s.txt" {
+		t.Fatalf("response %+v", resp)
+	}
+	if got := srv.files["s.txt"]; got != "hello session" {
+		t.Fatalf("stored %q", got)
+	}
+	if !slices.Equal(srv.chunks, []int{0, 4, 8, 12}) {
+		t.Fatalf("chunks at %v", srv.chunks)
+	}
+	// every call of the session is routed by its upload ID
+	for _, h := range srv.routed {
+		if h != "session-1" {
+			t.Fatalf("Upload-Session headers %q, want session-1 on every call", srv.routed)
+		}
+	}
+}
+
+func TestUploadFileSessionEmpty(t *testing.T) {
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	if _, err := client.UploadFile(t.Context(), writeTemp(t, ""), UploadOptions{Name: "empty.txt", Session: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := srv.files["empty.txt"]; !ok || got != "" {
+		t.Fatalf("stored %q (%v), want an empty file", got, ok)
+	}
+}
+
+func TestUploadFileSessionResumes(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	path := writeTemp(t, content)
+	opts := UploadOptions{Name: "a.txt", Session: true, StateFile: filepath.Join(t.TempDir(), "a.txt.upload-state")}
+
+	crashed, crash := context.WithCancel(t.Context())
+	srv := &fakeServer{crash: crash, crashAfter: 2}
+	url := newFakeServer(t, srv)
+	if _, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(crashed, path, opts); err == nil {
+		t.Fatal("upload survived the crash")
+	}
+	state, err := readUploadState(opts.StateFile)
+	if err != nil || state.UploadID != "session-1" {
+		t.Fatalf("state %+v, %v; want the session recorded", state, err)
+	}
+
+	srv.mu.Lock()
+	srv.chunks, srv.crash = nil, nil
+	srv.mu.Unlock()
+	if _, err := New(Options{BaseURL: url, ChunkSize: 4}).UploadFile(t.Context(), path, opts); err != nil {
+		t.Fatalf("resumed upload: %v", err)
+	}
+	if got := srv.files["a.txt"]; got != content {
+		t.Fatalf("server holds %q", got)
+	}
+	if !slices.Equal(srv.chunks, []int{8, 12, 16}) {
+		t.Fatalf("resumed session sent chunks at %v, want only those past byte 8", srv.chunks)
+	}
+	if _, err := os.Stat(opts.StateFile); !os.IsNotExist(err) {
+		t.Fatalf("state file kept after success: %v", err)
+	}
+}
+
+func TestUploadFileSessionLost(t *testing.T) {
+	path := writeTemp(t, "content")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateFile := filepath.Join(t.TempDir(), "state")
+	state := uploadState{Name: "a.txt", Size: info.Size(), Modified: info.ModTime().UnixNano(), UploadID: "gone"}
+	if err := writeUploadState(stateFile, state); err != nil {
+		t.Fatal(err)
+	}
+	srv := &fakeServer{}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+	if _, err := client.UploadFile(t.Context(), path, UploadOptions{Name: "a.txt", Session: true, StateFile: stateFile}); err != nil {
+		t.Fatalf("upload after the session was lost: %v", err)
+	}
+	if got := srv.files["a.txt"]; got != "content" {
+		t.Fatalf("stored %q", got)
+	}
+	if srv.routed[0] != "gone" {
+		t.Fatalf("routed %q, want the lost session looked up first", srv.routed)
+	}
+}
+
+func TestUploadFileSessionRefusesOptions(t *testing.T) {
+	client := New(Options{BaseURL: newFakeServer(t, &fakeServer{})})
+	for _, opts := range []UploadOptions{
+		{Name: "a.txt", Session: true, DryRun: true},
+		{Name: "a.txt", Session: true, SegmentSize: 4},
+		{Name: "a.txt", Session: true, IfMatchSHA256: "abc"},
+	} {
+		if _, err := client.UploadFile(t.Context(), writeTemp(t, "x"), opts); err == nil {
+			t.Errorf("session upload with %+v accepted", opts)
+		}
+	}
+}
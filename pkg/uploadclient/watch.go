@@ -0,0 +1,99 @@
+package uploadclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// EventType is the kind of a server event
+type EventType string
+
+const (
+	EventUpload EventType = "upload"
+	EventDelete EventType = "delete"
+	EventError  EventType = "error"
+)
+
+var eventTypes = map[EventType]fileuploadv1.EventType{
+	EventUpload: fileuploadv1.EventType_EVENT_TYPE_UPLOAD,
+	EventDelete: fileuploadv1.EventType_EVENT_TYPE_DELETE,
+	EventError:  fileuploadv1.EventType_EVENT_TYPE_ERROR,
+}
+
+func eventType(t fileuploadv1.EventType) EventType {
+	for name, value := range eventTypes {
+		if value == t {
+			return name
+		}
+	}
+	return ""
+}
+
+// WatchOptions selects the events WatchEvents reports
+type WatchOptions struct {
+	// Types are the event types wanted, every type when empty
+	Types []EventType
+	// Namespace keeps the events of callers whose client certificate has
+	// this organization, leaving out the deletions of expired files
+	Namespace string
+}
+
+// Event is an upload, a deletion or a failed upload on the server
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// RPC is the endpoint of an upload, as in the server events log
+	RPC            string `json:"rpc,omitempty"`
+	Filename       string `json:"filename"`
+	StoredFilename string `json:"stored_filename,omitempty"`
+	Size           int64  `json:"size"`
+	// Code is "ok", or the error code of a failed upload
+	Code      string `json:"code"`
+	Identity  string `json:"identity,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Dropped events were not delivered before this one because the client
+	// was not keeping up
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// WatchEvents calls fn for every server event matching opts as it happens,
+// until ctx is done, the server stops or fn returns an error, which is
+// returned
+func (c *Client) WatchEvents(ctx context.Context, opts WatchOptions, fn func(Event) error) error {
+	req := &fileuploadv1.WatchEventsRequest{Namespace: opts.Namespace}
+	for _, t := range opts.Types {
+		value, ok := eventTypes[t]
+		if !ok {
+			return fmt.Errorf("unknown event type %q", t)
+		}
+		req.Types = append(req.Types, value)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.rpc.WatchEvents(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	for stream.Receive() {
+		e := stream.Msg()
+		if err := fn(Event{
+			Type:           eventType(e.Type),
+			Time:           time.Unix(e.TimeUnix, 0),
+			RPC:            e.Rpc,
+			Filename:       e.Filename,
+			StoredFilename: e.StoredFilename,
+			Size:           e.Size,
+			Code:           e.Code,
+			Identity:       e.Identity,
+			Namespace:      e.Namespace,
+			Dropped:        e.Dropped,
+		}); err != nil {
+			return err
+		}
+	}
+	return stream.Err()
+}
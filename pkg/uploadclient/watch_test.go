@@ -0,0 +1,49 @@
+package uploadclient
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestWatchEvents(t *testing.T) {
+	srv := &fakeServer{events: []*fileuploadv1.ServerEvent{
+		{Type: fileuploadv1.EventType_EVENT_TYPE_UPLOAD, TimeUnix: 1700000000, Rpc: "Upload", Filename: "a.txt", Size: 4, Code: "ok", Identity: "alice", Namespace: "acme"},
+		{Type: fileuploadv1.EventType_EVENT_TYPE_ERROR, Rpc: "UploadFile", Filename: "b.txt", Code: "data_loss", Dropped: 2},
+	}}
+	client := New(Options{BaseURL: newFakeServer(t, srv)})
+
+	var events []Event
+	opts := WatchOptions{Types: []EventType{EventUpload, EventError}, Namespace: "acme"}
+	if err := client.WatchEvents(t.Context(), opts, func(e Event) error {
+		events = append(events, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []fileuploadv1.EventType{fileuploadv1.EventType_EVENT_TYPE_UPLOAD, fileuploadv1.EventType_EVENT_TYPE_ERROR}
+	if !slices.Equal(srv.watched.Types, want) || srv.watched.Namespace != "acme" {
+		t.Fatalf("request %v", srv.watched)
+	}
+	if len(events) != 2 {
+		t.Fatalf("received %d events", len(events))
+	}
+	if e := events[0]; e.Type != EventUpload || !e.Time.Equal(time.Unix(1700000000, 0)) || e.Filename != "a.txt" || e.Size != 4 || e.Identity != "alice" || e.Namespace != "acme" {
+		t.Errorf("upload event %+v", e)
+	}
+	if e := events[1]; e.Type != EventError || e.Code != "data_loss" || e.Dropped != 2 {
+		t.Errorf("error event %+v", e)
+	}
+
+	// an error from fn ends the watch
+	stop := errors.New("stop")
+	if err := client.WatchEvents(t.Context(), WatchOptions{}, func(Event) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("WatchEvents returned %v, want stop", err)
+	}
+	if err := client.WatchEvents(t.Context(), WatchOptions{Types: []EventType{"rename"}}, nil); err == nil {
+		t.Fatal("an unknown event type was accepted")
+	}
+}
@@ -0,0 +1,352 @@
+package uploadserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// archivesDir holds, inside partialDir, the archive sessions: {id}.json holds
+// the session and the {id} directory its entries, one file each, until
+// CloseArchive writes them into the stored archive. Like tusDir it cannot be
+// named by a ranged upload.
+const archivesDir = ".archives"
+
+var errArchiveNotFound = errors.New("archive not found")
+
+type archiveSession struct {
+	ID       string                     `json:"id"`
+	Filename string                     `json:"filename"`
+	Title    string                     `json:"title"`
+	Format   fileuploadv1.ArchiveFormat `json:"format"`
+	Entries  []archiveEntry             `json:"entries"`
+}
+
+type archiveEntry struct {
+	Name  string    `json:"name"`
+	Size  int64     `json:"size"`
+	Added time.Time `json:"added"`
+}
+
+func (s *Server) archivePaths(id string) (info, dir string) {
+	base := filepath.Join(s.dir, partialDir, archivesDir, id)
+	return base + ".json", base
+}
+
+func (s *Server) loadArchive(id string) (*archiveSession, error) {
+	// ids are generated as hex, anything else cannot name a session
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return nil, connect.NewError(connect.CodeNotFound, errArchiveNotFound)
+	}
+	infoPath, _ := s.archivePaths(id)
+	b, err := os.ReadFile(infoPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, connect.NewError(connect.CodeNotFound, errArchiveNotFound)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	var sess archiveSession
+	if err := json.Unmarshal(b, &sess); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if err := checkSessionFilename(sess.Filename); err != nil || sess.ID != id {
+		log.Printf("Archive %s: refusing a session file not written by the server", id)
+		return nil, connect.NewError(connect.CodeInternal, errSessionFilename)
+	}
+	return &sess, nil
+}
+
+// saveArchive replaces the session file atomically, so an interrupted write
+// never loses the entries already added
+func (s *Server) saveArchive(sess *archiveSession) error {
+	infoPath, dir := s.archivePaths(sess.ID)
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, ".session.tmp")
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, infoPath)
+}
+
+// archiveEntryName sanitizes each "/" separated element of an entry path like
+// a filename, so the archive cannot extract outside its target directory
+func archiveEntryName(name string) (string, error) {
+	var elems []string
+	for _, elem := range strings.Split(strings.ReplaceAll(name, "\\", "/"), "/") {
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			return "", errors.New("entry name must not contain ..")
+		}
+		elems = append(elems, sanitizeFilename(elem))
+	}
+	if len(elems) == 0 {
+		return "", errors.New("entry name is empty")
+	}
+	return strings.Join(elems, "/"), nil
+}
+
+func archiveFormatName(f fileuploadv1.ArchiveFormat) string {
+	if f == fileuploadv1.ArchiveFormat_ARCHIVE_FORMAT_ZIP {
+		return "zip"
+	}
+	return "tar"
+}
+
+// BeginArchive starts an archive session filled by AddArchiveEntry
+func (s *Server) BeginArchive(
+	ctx context.Context, req *fileuploadv1.BeginArchiveRequest) (*fileuploadv1.BeginArchiveResponse, error) {
+
+	if err := s.checkUploadable(); err != nil {
+		return nil, err
+	}
+	filename := sanitizeFilename(req.Filename)
+	if err := s.checkExtension(ctx, filename); err != nil {
+		return nil, err
+	}
+	format := req.Format
+	switch format {
+	case fileuploadv1.ArchiveFormat_ARCHIVE_FORMAT_UNSPECIFIED:
+		format = fileuploadv1.ArchiveFormat_ARCHIVE_FORMAT_TAR
+		if strings.EqualFold(filepath.Ext(filename), ".zip") {
+			format = fileuploadv1.ArchiveFormat_ARCHIVE_FORMAT_ZIP
+		}
+	case fileuploadv1.ArchiveFormat_ARCHIVE_FORMAT_TAR, fileuploadv1.ArchiveFormat_ARCHIVE_FORMAT_ZIP:
+	default:
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown archive format %d", format))
+	}
+	if err := s.checkSessionCapacity(); err != nil {
+		return nil, err
+	}
+
+	var raw [16]byte
+	rand.Read(raw[:])
+	sess := &archiveSession{
+		ID:       hex.EncodeToString(raw[:]),
+		Filename: filename,
+		Title:    req.Title,
+		Format:   format,
+	}
+	_, dir := s.archivePaths(sess.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if err := s.saveArchive(sess); err != nil {
+		os.RemoveAll(dir)
+		return nil, writeError(err)
+	}
+
+	log.Printf("Archive started: %s for %s (%s, title: %s)", sess.ID, s.redact.name(filename), archiveFormatName(format), s.redact.title(req.Title))
+	return &fileuploadv1.BeginArchiveResponse{
+		ArchiveId: sess.ID,
+		Filename:  filename,
+		Format:    format,
+	}, nil
+}
+
+// AddArchiveEntry stores one entry of an open archive until CloseArchive
+func (s *Server) AddArchiveEntry(
+	ctx context.Context, req *fileuploadv1.AddArchiveEntryRequest) (*fileuploadv1.UploadResponse, error) {
+
+	if err := s.checkUploadable(); err != nil {
+		return nil, err
+	}
+	name, err := archiveEntryName(req.Name)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	unlock := s.archives.lock(req.ArchiveId)
+	defer unlock()
+	sess, err := s.loadArchive(req.ArchiveId)
+	if err != nil {
+		return nil, err
+	}
+	total := int64(len(req.Data))
+	for _, e := range sess.Entries {
+		if e.Name == name {
+			return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("%s is already in the archive", name))
+		}
+		total += e.Size
+	}
+	// the archive framing is not counted, it adds little to the entries
+	if err := s.checkFileSize(total); err != nil {
+		return nil, err
+	}
+
+	_, dir := s.archivePaths(sess.ID)
+	entryPath := filepath.Join(dir, strconv.Itoa(len(sess.Entries)))
+	size, serverHash, err := s.writeFile(ctx, entryPath, bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, err
+	}
+	if req.Sha256 != "" && serverHash != req.Sha256 {
+		os.Remove(entryPath)
+		return nil, connect.NewError(connect.CodeDataLoss, errors.New("checksum mismatch"))
+	}
+	sess.Entries = append(sess.Entries, archiveEntry{Name: name, Size: size, Added: time.Now()})
+	if err := s.saveArchive(sess); err != nil {
+		os.Remove(entryPath)
+		return nil, writeError(err)
+	}
+
+	log.Printf("Archive %s: added %s (%d bytes)", sess.ID, s.redact.name(name), size)
+	return &fileuploadv1.UploadResponse{
+		Message:    "entry added",
+		Size:       size,
+		HashOk:     serverHash == req.Sha256,
+		HashStatus: hashStatus(serverHash, req.Sha256),
+		Sha256:     serverHash,
+	}, nil
+}
+
+// CloseArchive writes the entries of an archive into a tar or zip file and
+// moves it into place in one rename, then removes the session
+func (s *Server) CloseArchive(
+	ctx context.Context, req *fileuploadv1.CloseArchiveRequest) (resp *fileuploadv1.UploadResponse, err error) {
+
+	var (
+		filename string
+		size     int64
+	)
+	defer func() {
+		s.recordUpload(ctx, "CloseArchive", filename, resp.GetStoredFilename(), size, resp.GetHashOk(), err)
+	}()
+
+	if err := s.checkUploadable(); err != nil {
+		return nil, err
+	}
+	unlock := s.archives.lock(req.ArchiveId)
+	defer unlock()
+	sess, err := s.loadArchive(req.ArchiveId)
+	if err != nil {
+		return nil, err
+	}
+	filename = sess.Filename
+
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	infoPath, dir := s.archivePaths(sess.ID)
+	outPath := filepath.Join(dir, ".archive")
+	size, serverHash, err := s.writeArchive(sess, dir, outPath)
+	if err != nil {
+		os.Remove(outPath)
+		return nil, writeError(err)
+	}
+	stored := s.storedName(ctx, filename)
+	err = s.placeStored(outPath, stored, serverHash)
+	// a rejected duplicate ends the session too, like a completed tus upload
+	if err == nil || connect.CodeOf(err) == connect.CodeAlreadyExists {
+		os.Remove(infoPath)
+		os.RemoveAll(dir)
+		s.archives.forget(sess.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.uploaded(ctx, "CloseArchive", filename, stored, size, serverHash); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Archive stored: %s (%s, %d entries, %d bytes)", s.redact.name(stored), archiveFormatName(sess.Format), len(sess.Entries), size)
+	return &fileuploadv1.UploadResponse{
+		Message:        "archive stored",
+		Size:           size,
+		HashOk:         true,
+		HashStatus:     fileuploadv1.HashStatus_HASH_STATUS_VERIFIED,
+		Sha256:         serverHash,
+		StoredFilename: baseName(stored),
+		StoragePath:    s.files.relPath(stored),
+	}, nil
+}
+
+// writeArchive writes the entries kept in dir as an archive at path and
+// returns its size and hex-encoded SHA-256
+func (s *Server) writeArchive(sess *archiveSession, dir, path string) (int64, string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	w := io.MultiWriter(f, hasher)
+
+	var (
+		add   func(e archiveEntry, content io.Reader) error
+		flush func() error
+	)
+	if sess.Format == fileuploadv1.ArchiveFormat_ARCHIVE_FORMAT_ZIP {
+		zw := zip.NewWriter(w)
+		add = func(e archiveEntry, content io.Reader) error {
+			ew, err := zw.CreateHeader(&zip.FileHeader{Name: e.Name, Method: zip.Deflate, Modified: e.Added})
+			if err == nil {
+				_, err = io.Copy(ew, content)
+			}
+			return err
+		}
+		flush = zw.Close
+	} else {
+		tw := tar.NewWriter(w)
+		add = func(e archiveEntry, content io.Reader) error {
+			hdr := &tar.Header{Typeflag: tar.TypeReg, Name: e.Name, Size: e.Size, Mode: 0644, ModTime: e.Added}
+			err := tw.WriteHeader(hdr)
+			if err == nil {
+				_, err = io.Copy(tw, content)
+			}
+			return err
+		}
+		flush = tw.Close
+	}
+
+	for i, e := range sess.Entries {
+		content, err := os.Open(filepath.Join(dir, strconv.Itoa(i)))
+		if err != nil {
+			return 0, "", err
+		}
+		err = add(e, content)
+		content.Close()
+		if err != nil {
+			return 0, "", fmt.Errorf("entry %s: %w", e.Name, err)
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, "", err
+	}
+	if err := s.syncCommit(f); err != nil {
+		return 0, "", err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	if err := f.Close(); err != nil {
+		return 0, "", err
+	}
+	return info.Size(), hex.EncodeToString(hasher.Sum(nil)), nil
+}
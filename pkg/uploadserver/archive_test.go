@@ -0,0 +1,184 @@
+package uploadserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// buildArchive stores entries, a name then content each, in the archive filename
+func (ts *testServer) buildArchive(t *testing.T, filename string, entries ...string) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	begin, err := ts.client.BeginArchive(t.Context(), &fileuploadv1.BeginArchiveRequest{Filename: filename})
+	if err != nil {
+		t.Fatalf("BeginArchive: %v", err)
+	}
+	for i := 0; i < len(entries); i += 2 {
+		_, err := ts.client.AddArchiveEntry(t.Context(), &fileuploadv1.AddArchiveEntryRequest{
+			ArchiveId: begin.ArchiveId,
+			Name:      entries[i],
+			Data:      []byte(entries[i+1]),
+			Sha256:    sha256Hex(entries[i+1]),
+		})
+		if err != nil {
+			t.Fatalf("AddArchiveEntry %s: %v", entries[i], err)
+		}
+	}
+	return ts.client.CloseArchive(t.Context(), &fileuploadv1.CloseArchiveRequest{ArchiveId: begin.ArchiveId})
+}
+
+func TestArchiveTar(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	resp, err := ts.buildArchive(t, "bundle.tar", "a.txt", "first", `docs\b.txt`, "second")
+	if err != nil {
+		t.Fatalf("CloseArchive: %v", err)
+	}
+	if resp.StoredFilename != "bundle.tar" || resp.Sha256 != sha256Hex(ts.stored(t, "bundle.tar")) {
+		t.Fatalf("response %v does not match the stored archive", resp)
+	}
+
+	tr := tar.NewReader(strings.NewReader(ts.stored(t, "bundle.tar")))
+	var got []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(tr)
+		got = append(got, hdr.Name+"="+string(content))
+	}
+	if want := "a.txt=first docs/b.txt=second"; strings.Join(got, " ") != want {
+		t.Fatalf("entries %q, want %q", got, want)
+	}
+}
+
+func TestArchiveZip(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if _, err := ts.buildArchive(t, "bundle.zip", "a.txt", "first"); err != nil {
+		t.Fatalf("CloseArchive: %v", err)
+	}
+	data := ts.stored(t, "bundle.zip")
+	zr, err := zip.NewReader(bytes.NewReader([]byte(data)), int64(len(data)))
+	if err != nil {
+		t.Fatalf("stored archive is not a zip: %v", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "a.txt" {
+		t.Fatalf("zip entries %v, want a.txt", zr.File)
+	}
+}
+
+func TestArchiveRejectsBadEntries(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	begin, err := ts.client.BeginArchive(t.Context(), &fileuploadv1.BeginArchiveRequest{Filename: "x.tar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ts.client.AddArchiveEntry(t.Context(), &fileuploadv1.AddArchiveEntryRequest{
+		ArchiveId: begin.ArchiveId, Name: "a.txt", Data: []byte("data"), Sha256: sha256Hex("other"),
+	})
+	if connect.CodeOf(err) != connect.CodeDataLoss {
+		t.Fatalf("entry with a wrong hash: %v, want data_loss", err)
+	}
+	_, err = ts.client.AddArchiveEntry(t.Context(), &fileuploadv1.AddArchiveEntryRequest{
+		ArchiveId: begin.ArchiveId, Name: "../docs/b.txt", Data: []byte("data"),
+	})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("entry with ..: %v, want invalid_argument", err)
+	}
+}
+
+func TestArchiveSessionOutOfReachOfRangedPut(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	begin, err := ts.client.BeginArchive(t.Context(), &fileuploadv1.BeginArchiveRequest{Filename: "x.tar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := begin.ArchiveId
+
+	evil := `{"id":"` + id + `","filename":"../escaped.txt"}`
+	for _, name := range []string{id + ".json", archivesDir} {
+		req := ts.newRequest(t, http.MethodPut, "/files/"+name, strings.NewReader(evil))
+		req.Header.Set("Content-Range", "bytes 0-"+strconv.Itoa(len(evil)-1)+"/*")
+		ts.do(t, req)
+	}
+	resp, err := ts.client.CloseArchive(t.Context(), &fileuploadv1.CloseArchiveRequest{ArchiveId: id})
+	if err != nil {
+		t.Fatalf("CloseArchive: %v", err)
+	}
+	if resp.StoredFilename != "x.tar" {
+		t.Fatalf("stored as %q, want x.tar", resp.StoredFilename)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "..", "escaped.txt")); !os.IsNotExist(err) {
+		t.Fatalf("a file was written outside the upload directory: %v", err)
+	}
+}
+
+func TestArchiveRefusesUnsafeSessionFilename(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	begin, err := ts.client.BeginArchive(t.Context(), &fileuploadv1.BeginArchiveRequest{Filename: "x.tar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoPath, _ := ts.srv.archivePaths(begin.ArchiveId)
+	evil := `{"id":"` + begin.ArchiveId + `","filename":"../escaped.txt"}`
+	if err := os.WriteFile(infoPath, []byte(evil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.client.CloseArchive(t.Context(), &fileuploadv1.CloseArchiveRequest{ArchiveId: begin.ArchiveId}); err == nil {
+		t.Fatal("CloseArchive of a tampered session succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "..", "escaped.txt")); !os.IsNotExist(err) {
+		t.Fatalf("a file was written outside the upload directory: %v", err)
+	}
+}
+
+func TestArchiveRefusedExtension(t *testing.T) {
+	ts := newTestServer(t, &Server{extensions: map[string]ExtensionPolicy{
+		defaultPolicyNamespace: {Allow: []string{".zip"}},
+	}})
+	_, err := ts.client.BeginArchive(t.Context(), &fileuploadv1.BeginArchiveRequest{Filename: "x.tar"})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("archive with a refused extension: %v, want invalid_argument", err)
+	}
+	if _, err := ts.buildArchive(t, "x.zip", "a.txt", "first"); err != nil {
+		t.Fatalf("archive with an allowed extension: %v", err)
+	}
+}
+
+func TestJanitorRemovesAbandonedArchives(t *testing.T) {
+	ts := newTestServer(t, &Server{partialMaxAge: time.Hour})
+	begin, err := ts.client.BeginArchive(t.Context(), &fileuploadv1.BeginArchiveRequest{Filename: "x.tar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoPath, dir := ts.srv.archivePaths(begin.ArchiveId)
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(infoPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	ts.srv.sweepPartials()
+	for _, p := range []string{infoPath, dir} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("abandoned archive %s was kept: %v", p, err)
+		}
+	}
+	if _, err := ts.client.CloseArchive(t.Context(), &fileuploadv1.CloseArchiveRequest{ArchiveId: begin.ArchiveId}); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("CloseArchive of a removed session: %v, want not found", err)
+	}
+}
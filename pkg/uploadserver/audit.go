@@ -0,0 +1,133 @@
+package uploadserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// AuditRecord describes one finished RPC
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Procedure string    `json:"procedure"`
+	Peer      string    `json:"peer"`
+	Identity  string    `json:"identity,omitempty"`
+	// Filename as requested by the client, before sanitization
+	Filename   string  `json:"filename,omitempty"`
+	Code       string  `json:"code"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// AuditSink receives an AuditRecord for every RPC. Audit is called
+// concurrently and must not block for long.
+type AuditSink interface {
+	Audit(AuditRecord)
+}
+
+// jsonlAuditSink writes each record as one JSON line
+type jsonlAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLAuditSink returns a sink writing one JSON line per record to w, such
+// as os.Stdout, an append-only file or a syslog writer
+func NewJSONLAuditSink(w io.Writer) AuditSink {
+	return &jsonlAuditSink{w: w}
+}
+
+func (s *jsonlAuditSink) Audit(r AuditRecord) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(line); err != nil {
+		log.Printf("Audit log write failed: %v", err)
+	}
+}
+
+// auditInterceptor reports every RPC to a sink
+type auditInterceptor struct {
+	sink AuditSink
+}
+
+func (i auditInterceptor) record(ctx context.Context, procedure, filename string, start time.Time, err error) {
+	c := callerFrom(ctx)
+	code := "ok"
+	if err != nil {
+		code = connect.CodeOf(err).String()
+	}
+	i.sink.Audit(AuditRecord{
+		Time:       start.UTC(),
+		Procedure:  procedure,
+		Peer:       c.addr,
+		Identity:   c.identity,
+		Filename:   filename,
+		Code:       code,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	})
+}
+
+func (i auditInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		start := time.Now()
+		resp, err := next(ctx, req)
+		i.record(ctx, req.Spec().Procedure, requestFilename(req.Any()), start, err)
+		return resp, err
+	}
+}
+
+func (i auditInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i auditInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		start := time.Now()
+		watched := &filenameConn{StreamingHandlerConn: conn}
+		err := next(ctx, watched)
+		i.record(ctx, conn.Spec().Procedure, watched.filename, start, err)
+		return err
+	}
+}
+
+// filenameConn remembers the filename of the first request message naming one
+type filenameConn struct {
+	connect.StreamingHandlerConn
+	filename string
+}
+
+func (c *filenameConn) Receive(msg any) error {
+	err := c.StreamingHandlerConn.Receive(msg)
+	if err == nil && c.filename == "" {
+		c.filename = requestFilename(msg)
+	}
+	return err
+}
+
+// requestFilename returns the filename carried by a request message, "" if none
+func requestFilename(msg any) string {
+	switch m := msg.(type) {
+	case *fileuploadv1.UploadRequest:
+		return m.GetMetadata().GetFilename()
+	case *fileuploadv1.RenameFileRequest:
+		return m.GetFrom()
+	case *fileuploadv1.CopyFileRequest:
+		return m.GetFrom()
+	case *fileuploadv1.AddArchiveEntryRequest:
+		return m.GetName()
+	case interface{ GetFilename() string }:
+		return m.GetFilename()
+	}
+	return ""
+}
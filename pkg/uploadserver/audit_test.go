@@ -0,0 +1,96 @@
+package uploadserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+// recordingSink keeps every audit record
+type recordingSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (s *recordingSink) Audit(r AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+}
+
+func (s *recordingSink) all() []AuditRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AuditRecord(nil), s.records...)
+}
+
+func TestAuditSink(t *testing.T) {
+	sink := &recordingSink{}
+	_, client := serveConfig(t, Config{Dir: t.TempDir(), AuditSink: sink})
+
+	if _, err := client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "../unary.txt", Data: []byte("x"), Sha256: sha256Hex("x")}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: "streamed.txt"}}})
+	if _, err := stream.CloseAndReceive(); err == nil {
+		t.Fatal("upload without a commit succeeded")
+	}
+	client.GetFileMetadata(t.Context(), &fileuploadv1.GetFileMetadataRequest{Filename: "missing.txt"})
+
+	got := sink.all()
+	if len(got) != 3 {
+		t.Fatalf("recorded %d RPCs, want 3: %+v", len(got), got)
+	}
+	for i, want := range []struct{ procedure, filename, code string }{
+		{fileuploadv1connect.FileUploadServiceUploadFileProcedure, "../unary.txt", "ok"},
+		{fileuploadv1connect.FileUploadServiceUploadProcedure, "streamed.txt", "invalid_argument"},
+		{fileuploadv1connect.FileUploadServiceGetFileMetadataProcedure, "missing.txt", "not_found"},
+	} {
+		r := got[i]
+		if r.Procedure != want.procedure || r.Filename != want.filename || r.Code != want.code {
+			t.Errorf("record %d = %+v, want %s of %q with %s", i, r, want.procedure, want.filename, want.code)
+		}
+		if r.Peer == "" || r.Time.IsZero() || time.Since(r.Time) > time.Minute {
+			t.Errorf("record %d lacks its peer or time: %+v", i, r)
+		}
+	}
+}
+
+func TestAuditRecordsRefusedCalls(t *testing.T) {
+	sink := &recordingSink{}
+	_, client := serveConfig(t, Config{Dir: t.TempDir(), AuditSink: sink, AllowedClients: []string{"alice"}})
+
+	client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "a.txt", Data: []byte("x"), Sha256: sha256Hex("x")})
+	if got := sink.all(); len(got) != 1 || got[0].Code != "permission_denied" || got[0].Filename != "a.txt" {
+		t.Fatalf("records %+v, want the refused upload", got)
+	}
+}
+
+func TestJSONLAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONLAuditSink(&buf)
+	sink.Audit(AuditRecord{Procedure: "/p/One", Code: "ok"})
+	sink.Audit(AuditRecord{Procedure: "/p/Two", Identity: "alice", Code: "not_found"})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %q, want two lines", buf.String())
+	}
+	var r AuditRecord
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil || r.Procedure != "/p/Two" || r.Identity != "alice" {
+		t.Fatalf("second line %s decodes to %+v, %v", lines[1], r, err)
+	}
+	if strings.Contains(lines[0], "identity") || strings.Contains(lines[0], "filename") {
+		t.Errorf("empty fields are written: %s", lines[0])
+	}
+}
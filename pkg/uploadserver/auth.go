@@ -0,0 +1,154 @@
+package uploadserver
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+var errClientNotAllowed = errors.New("client certificate not allowed to upload")
+
+// caller identifies who sent a request: the remote address and, over mTLS,
+// the names from the verified client certificate
+type caller struct {
+	addr      string
+	identity  string   // certificate common name, or its first SAN without one
+	namespace string   // first certificate organization, the tenant
+	names     []string // common name and every DNS/email SAN
+}
+
+type callerKey struct{}
+
+// withCaller stores the caller of every request in its context, for the
+// upload allowlist and the event log
+func withCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := caller{addr: r.RemoteAddr}
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			c.names = certNames(r.TLS.PeerCertificates[0])
+			if len(c.names) > 0 {
+				c.identity = c.names[0]
+			}
+			if org := r.TLS.PeerCertificates[0].Subject.Organization; len(org) > 0 {
+				c.namespace = org[0]
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	})
+}
+
+func callerFrom(ctx context.Context) caller {
+	c, _ := ctx.Value(callerKey{}).(caller)
+	return c
+}
+
+func certNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	return append(names, cert.EmailAddresses...)
+}
+
+// clientAllowlist holds the certificate names permitted to upload; a nil
+// allowlist permits everyone
+type clientAllowlist map[string]struct{}
+
+func newClientAllowlist(names []string) clientAllowlist {
+	if len(names) == 0 {
+		return nil
+	}
+	allowed := make(clientAllowlist)
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = struct{}{}
+		}
+	}
+	return allowed
+}
+
+// check returns CodePermissionDenied unless one of the caller's certificate
+// names is on the allowlist
+func (a clientAllowlist) check(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	for _, name := range callerFrom(ctx).names {
+		if _, ok := a[name]; ok {
+			return nil
+		}
+	}
+	return connect.NewError(connect.CodePermissionDenied, errClientNotAllowed)
+}
+
+// require guards a plain-HTTP upload handler with the allowlist
+func (a clientAllowlist) require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := a.check(r.Context()); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// uploadProcedures are the RPCs that change stored data, or with Scrub read
+// all of it, and are subject to the allowlist
+var uploadProcedures = map[string]bool{
+	fileuploadv1connect.FileUploadServiceUploadProcedure:          true,
+	fileuploadv1connect.FileUploadServiceUploadBidiProcedure:      true,
+	fileuploadv1connect.FileUploadServiceUploadFileProcedure:      true,
+	fileuploadv1connect.FileUploadServiceRenameFileProcedure:      true,
+	fileuploadv1connect.FileUploadServiceCopyFileProcedure:        true,
+	fileuploadv1connect.FileUploadServiceBeginArchiveProcedure:    true,
+	fileuploadv1connect.FileUploadServiceAddArchiveEntryProcedure: true,
+	fileuploadv1connect.FileUploadServiceCloseArchiveProcedure:    true,
+	fileuploadv1connect.FileUploadServiceExtendExpiryProcedure:    true,
+	fileuploadv1connect.FileUploadServiceScrubProcedure:           true,
+	fileuploadv1connect.FileUploadServiceCreateUploadProcedure:    true,
+	fileuploadv1connect.FileUploadServiceUploadChunkProcedure:     true,
+}
+
+// adminProcedures are the other RPCs subject to the allowlist; they run for
+// as long as the client wants and are not counted as uploads
+var adminProcedures = map[string]bool{
+	fileuploadv1connect.FileUploadServiceWatchEventsProcedure: true,
+}
+
+// allowlistInterceptor applies the allowlist to the upload and admin RPCs
+type allowlistInterceptor struct {
+	allowed clientAllowlist
+}
+
+func (i allowlistInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if p := req.Spec().Procedure; uploadProcedures[p] || adminProcedures[p] {
+			if err := i.allowed.check(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i allowlistInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i allowlistInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if p := conn.Spec().Procedure; uploadProcedures[p] || adminProcedures[p] {
+			if err := i.allowed.check(ctx); err != nil {
+				return err
+			}
+		}
+		return next(ctx, conn)
+	}
+}
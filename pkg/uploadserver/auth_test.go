@@ -0,0 +1,138 @@
+package uploadserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+func TestNewClientAllowlist(t *testing.T) {
+	if newClientAllowlist(nil) != nil {
+		t.Fatal("an empty allowlist restricts uploads")
+	}
+	allowed := newClientAllowlist([]string{" alice ", "bob@example.com", ""})
+	if len(allowed) != 2 {
+		t.Fatalf("allowlist %v, want alice and bob@example.com", allowed)
+	}
+	for _, name := range []string{"alice", "bob@example.com"} {
+		if _, ok := allowed[name]; !ok {
+			t.Errorf("%s missing from %v", name, allowed)
+		}
+	}
+}
+
+func TestClientAllowlist(t *testing.T) {
+	events, err := openEventLog(filepath.Join(t.TempDir(), "events.jsonl"), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	allowed := newClientAllowlist([]string{"alice"})
+	ts := newTestServer(t, &Server{events: events}, connect.WithInterceptors(allowlistInterceptor{allowed}))
+	ca := newTestCA(t)
+	hs := serveMutualTLS(t, ts, ca)
+	alice := mutualTLSClient(t, ca, ca.issue(t, "alice", x509.ExtKeyUsageClientAuth))
+	mallory := mutualTLSClient(t, ca, ca.issue(t, "mallory", x509.ExtKeyUsageClientAuth))
+
+	upload := func(client *http.Client, name string) error {
+		_, err := fileuploadv1connect.NewFileUploadServiceClient(client, hs.URL).UploadFile(t.Context(),
+			&fileuploadv1.UploadFileRequest{Filename: name, Data: []byte("data"), Sha256: sha256Hex("data")})
+		return err
+	}
+	if err := upload(mallory, "mallory.txt"); connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Fatalf("upload by a client off the allowlist: %v, want permission_denied", err)
+	}
+	if err := upload(alice, "alice.txt"); err != nil {
+		t.Fatalf("upload by an allowed client: %v", err)
+	}
+	// reads are not restricted
+	if _, err := fileuploadv1connect.NewFileUploadServiceClient(mallory, hs.URL).GetServerInfo(t.Context(),
+		&fileuploadv1.GetServerInfoRequest{}); err != nil {
+		t.Fatalf("GetServerInfo by a client off the allowlist: %v", err)
+	}
+
+	// the authenticated identity is recorded with the upload
+	logged := readEvents(t, events.path)
+	if len(logged) != 1 || logged[0].Filename != "alice.txt" || logged[0].Identity != "alice" {
+		t.Fatalf("events %+v, want the upload of alice.txt by alice", logged)
+	}
+}
+
+func TestClientAllowlistGuardsHTTPUploads(t *testing.T) {
+	allowed := newClientAllowlist([]string{"alice"})
+	ts := newTestServer(t, &Server{})
+	ca := newTestCA(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /files/{name}", allowed.require(ts.srv.handlePutFile))
+	ts.Config.Handler = withCaller(mux)
+	hs := serveMutualTLS(t, ts, ca)
+
+	put := func(client *http.Client, name string) int {
+		req, err := http.NewRequest(http.MethodPut, hs.URL+"/files/"+name, bytes.NewReader([]byte("data")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := put(mutualTLSClient(t, ca, ca.issue(t, "mallory", x509.ExtKeyUsageClientAuth)), "mallory.txt"); code != http.StatusForbidden {
+		t.Fatalf("PUT by a client off the allowlist: %d, want 403", code)
+	}
+	if code := put(mutualTLSClient(t, ca, ca.issue(t, "alice", x509.ExtKeyUsageClientAuth)), "alice.txt"); code != http.StatusCreated && code != http.StatusOK {
+		t.Fatalf("PUT by an allowed client: %d", code)
+	}
+	if got := ts.stored(t, "alice.txt"); got != "data" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestWithCallerCarriesIdentity(t *testing.T) {
+	var got caller
+	handler := withCaller(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = callerFrom(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/a.txt", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+		Subject:        pkix.Name{CommonName: "alice", Organization: []string{"acme", "other"}},
+		DNSNames:       []string{"alice.acme.example"},
+		EmailAddresses: []string{"alice@acme.example"},
+	}}}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got.addr != req.RemoteAddr || got.identity != "alice" || got.namespace != "acme" ||
+		!slices.Equal(got.names, []string{"alice", "alice.acme.example", "alice@acme.example"}) {
+		t.Fatalf("caller %+v", got)
+	}
+
+	// without a common name the first SAN identifies the caller
+	req.TLS.PeerCertificates[0].Subject = pkix.Name{}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got.identity != "alice.acme.example" || got.namespace != "" {
+		t.Fatalf("caller without a common name %+v", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/files/a.txt", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got.addr != req.RemoteAddr || got.identity != "" || got.namespace != "" || got.names != nil {
+		t.Fatalf("caller without a certificate %+v", got)
+	}
+	if c := callerFrom(context.Background()); c.identity != "" || c.addr != "" {
+		t.Fatalf("caller of a context without one %+v", c)
+	}
+}
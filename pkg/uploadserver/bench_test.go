@@ -0,0 +1,52 @@
+package uploadserver
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// BenchmarkUpload streams files of a few sizes in chunks of a few sizes;
+// compare runs with benchstat
+func BenchmarkUpload(b *testing.B) {
+	for _, size := range []int{1 << 20, 16 << 20} {
+		data := make([]byte, size)
+		rand.Read(data)
+		for _, chunk := range []int{4 << 10, 64 << 10, 1 << 20} {
+			b.Run(fmt.Sprintf("file=%dKiB/chunk=%dKiB", size>>10, chunk>>10), func(b *testing.B) {
+				ts := newTestServer(b, &Server{})
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, err := ts.streamUpload(b.Context(), "bench.bin", data, chunk); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkUploadFile sends files of a few sizes in one UploadFile call
+func BenchmarkUploadFile(b *testing.B) {
+	for _, size := range []int{4 << 10, 256 << 10, 4 << 20} {
+		data := make([]byte, size)
+		rand.Read(data)
+		sum := sha256.Sum256(data)
+		req := &fileuploadv1.UploadFileRequest{Filename: "bench.bin", Data: data, Sha256: hex.EncodeToString(sum[:])}
+		b.Run(fmt.Sprintf("file=%dKiB", size>>10), func(b *testing.B) {
+			ts := newTestServer(b, &Server{})
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := ts.client.UploadFile(b.Context(), req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
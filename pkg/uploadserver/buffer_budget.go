@@ -0,0 +1,58 @@
+package uploadserver
+
+import (
+	"context"
+	"sync"
+)
+
+// byteBudget is a counting semaphore over bytes bounding the memory held by
+// received chunks across all uploads. A nil budget never blocks.
+type byteBudget struct {
+	mu      sync.Mutex
+	limit   int64
+	avail   int64
+	changed chan struct{} // closed and replaced on every release
+}
+
+func newByteBudget(limit int64) *byteBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &byteBudget{limit: limit, avail: limit, changed: make(chan struct{})}
+}
+
+// acquire waits until n bytes are available or ctx is done and returns what
+// was reserved, which is the whole budget when n exceeds it
+func (b *byteBudget) acquire(ctx context.Context, n int64) (int64, error) {
+	if b == nil || n <= 0 {
+		return 0, nil
+	}
+	n = min(n, b.limit)
+	for {
+		b.mu.Lock()
+		if b.avail >= n {
+			b.avail -= n
+			b.mu.Unlock()
+			return n, nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (b *byteBudget) release(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.avail += n
+	close(b.changed)
+	b.changed = make(chan struct{})
+	b.mu.Unlock()
+}
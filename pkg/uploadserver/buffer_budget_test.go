@@ -0,0 +1,140 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestByteBudget(t *testing.T) {
+	var unlimited *byteBudget
+	if n, err := unlimited.acquire(t.Context(), 1<<40); n != 0 || err != nil {
+		t.Fatalf("nil budget acquire = %d, %v", n, err)
+	}
+	unlimited.release(1 << 40)
+
+	b := newByteBudget(10)
+	if n, err := b.acquire(t.Context(), 6); n != 6 || err != nil {
+		t.Fatalf("acquire 6 of 10 = %d, %v", n, err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := b.acquire(ctx, 6); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire over the budget: %v, want it to wait until the deadline", err)
+	}
+
+	got := make(chan int64)
+	go func() {
+		n, _ := b.acquire(context.Background(), 6)
+		got <- n
+	}()
+	select {
+	case <-got:
+		t.Fatal("acquire over the budget did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.release(6)
+	if n := <-got; n != 6 {
+		t.Fatalf("acquire after release = %d", n)
+	}
+	b.release(6)
+
+	// a chunk larger than the whole budget takes all of it rather than waiting forever
+	if n, err := b.acquire(t.Context(), 25); n != 10 || err != nil {
+		t.Fatalf("acquire 25 of 10 = %d, %v", n, err)
+	}
+	b.release(10)
+}
+
+func TestMaxBufferMemoryBlocksStreams(t *testing.T) {
+	srv := &Server{buffers: newByteBudget(1024)}
+	ts := newTestServer(t, srv)
+	// another upload holds the whole budget
+	held, err := srv.buffers.acquire(t.Context(), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ts.streamUpload(t.Context(), "waiting.txt", []byte("blocked until memory is free"), 8)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("upload finished while the budget was exhausted: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	srv.buffers.release(held)
+	if err := <-done; err != nil {
+		t.Fatalf("upload after memory was released: %v", err)
+	}
+	if got := ts.stored(t, "waiting.txt"); got != "blocked until memory is free" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestMaxBufferMemoryConcurrentUploads(t *testing.T) {
+	const limit = 4096
+	srv := &Server{buffers: newByteBudget(limit)}
+	ts := newTestServer(t, srv)
+
+	// samples the memory accounted against the budget while the uploads run
+	stop := make(chan struct{})
+	var peak int64
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			srv.buffers.mu.Lock()
+			peak = max(peak, srv.buffers.limit-srv.buffers.avail)
+			srv.buffers.mu.Unlock()
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Microsecond):
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := []byte(strings.Repeat(fmt.Sprint(i%10), 32<<10))
+			if _, err := ts.streamUpload(t.Context(), fmt.Sprintf("f%d.bin", i), data, 1024); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-sampled
+	close(errs)
+	for err := range errs {
+		t.Errorf("upload under a memory budget: %v", err)
+	}
+	if peak > limit {
+		t.Fatalf("%d bytes of chunks held at once, over the budget of %d", peak, limit)
+	}
+	// handlers release their last chunk right after answering
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		srv.buffers.mu.Lock()
+		left := srv.buffers.limit - srv.buffers.avail
+		srv.buffers.mu.Unlock()
+		if left == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d bytes still accounted after every upload finished", left)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,53 @@
+package uploadserver
+
+import (
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// countedUpload streams data under name and commits it claiming size bytes sent
+func (ts *testServer) countedUpload(t *testing.T, name, data string, size *int64, segmented bool) error {
+	t.Helper()
+	commit := &fileuploadv1.UploadRequest{
+		Payload:    &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex(data)},
+		CommitSize: size,
+	}
+	metadata := &fileuploadv1.UploadMetadata{Filename: name}
+	if segmented {
+		metadata.SegmentSize = 4
+		commit.Payload = &fileuploadv1.UploadRequest_SegmentedCommit{
+			SegmentedCommit: &fileuploadv1.SegmentedCommit{Sha256: sha256Hex(data), SegmentSha256: segmentSums(data, 4)},
+		}
+	}
+	_, err := ts.sendUpload(t,
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: metadata}},
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte(data)}},
+		commit,
+	)
+	return err
+}
+
+func TestCommitSize(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+
+	if err := ts.countedUpload(t, "counted.txt", "all there", proto.Int64(9), false); err != nil {
+		t.Fatalf("matching count: %v", err)
+	}
+	if err := ts.countedUpload(t, "uncounted.txt", "no count", nil, false); err != nil {
+		t.Fatalf("without a count: %v", err)
+	}
+
+	// the client sent more than arrived: the hash over the truncated data is no help
+	for _, segmented := range []bool{false, true} {
+		err := ts.countedUpload(t, "truncated.txt", "arrived", proto.Int64(12), segmented)
+		if connect.CodeOf(err) != connect.CodeInvalidArgument || !strings.Contains(err.Error(), "client sent 12 bytes but the server received 7") {
+			t.Fatalf("segmented=%v: truncated stream: %v, want invalid argument with both counts", segmented, err)
+		}
+		ts.assertNotStored(t, "truncated.txt")
+	}
+}
@@ -0,0 +1,44 @@
+package uploadserver
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+func TestGzipOnTheWire(t *testing.T) {
+	ts := newTestServer(t, &Server{}, connect.WithCompressMinBytes(1024))
+	var mu sync.Mutex
+	var encodings []string
+	handler := ts.Config.Handler
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		encodings = append(encodings, r.Header.Get("Connect-Content-Encoding"))
+		mu.Unlock()
+		handler.ServeHTTP(w, r)
+	})
+	ts.client = fileuploadv1connect.NewFileUploadServiceClient(ts.Client(), ts.URL, connect.WithSendGzip())
+
+	data := strings.Repeat("compressible line\n", 1000)
+	resp, err := ts.streamUpload(t.Context(), "logs.txt", []byte(data), 4096)
+	if err != nil {
+		t.Fatalf("gzip upload: %v", err)
+	}
+	if !resp.HashOk {
+		t.Fatalf("response %+v", resp)
+	}
+	if got := ts.stored(t, "logs.txt"); got != data {
+		t.Fatal("stored bytes differ from the uploaded ones")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Contains(encodings, "gzip") {
+		t.Fatalf("stream encodings %q, want gzip", encodings)
+	}
+}
@@ -0,0 +1,401 @@
+package uploadserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+// Config configures the handler returned by New. Only Dir is required.
+type Config struct {
+	// Dir is the storage directory, created when missing
+	Dir string
+	// StorageProbe reports whether Dir currently accepts writes; nil writes and
+	// deletes a probe file. Uploads are refused while it fails.
+	StorageProbe func() error
+	// StorageProbeInterval is how often StorageProbe runs, 10s when 0
+	StorageProbeInterval time.Duration
+	// DiskHighWater refuses uploads with CodeResourceExhausted once this
+	// percentage of the filesystem holding Dir is used, e.g. 90; 0 disables it.
+	// Usage is measured every StorageProbeInterval.
+	DiskHighWater float64
+	// DiskLowWater is the usage in percent below which uploads are accepted
+	// again, DiskHighWater minus 5 when 0
+	DiskLowWater float64
+	// DiskUsage reports the used and total bytes of the filesystem holding
+	// Dir for DiskHighWater; nil asks the operating system
+	DiskUsage func() (used, total uint64, err error)
+	// SelfTest makes New store, read back and delete a small file in every
+	// storage directory, failing when anything does not round-trip
+	SelfTest bool
+
+	// IndexFile persists the content hash index; empty keeps it in memory only
+	IndexFile string
+	// RebuildIndex rehashes every stored file instead of loading IndexFile
+	RebuildIndex bool
+	// RejectDuplicateContent refuses content already stored under another name
+	RejectDuplicateContent bool
+	// DedupCopies makes CopyFile store a copy as a hard link sharing the
+	// content of the original, taking no extra space, when both are on the
+	// same filesystem. Uploads replacing either one unlink it first, so the
+	// other keeps its content.
+	DedupCopies bool
+
+	// EventsLog is an append-only JSONL log of every upload; empty disables it
+	EventsLog string
+	// EventsMaxBytes rotates EventsLog to EventsLog+".1" past this size, 0 never rotates
+	EventsMaxBytes int64
+	// AuditSink receives a record of every RPC, nil disables auditing.
+	// NewJSONLAuditSink covers files, stdout and syslog.
+	AuditSink AuditSink
+	// Publisher receives an UploadCompleted event for every stored upload,
+	// such as NewNATSPublisher; nil publishes nothing. Failures are logged.
+	Publisher Publisher
+	// PublishRequired fails an upload with CodeUnavailable when its event
+	// could not be published. The file stays stored, so a retry overwrites it.
+	PublishRequired bool
+
+	// MaxMessageBytes bounds one RPC message (a chunk or a unary upload), 0 is unlimited
+	MaxMessageBytes int
+	// MaxFileSize bounds one stored file, 0 is unlimited
+	MaxFileSize int64
+	// MaxTitleLength bounds the title of an upload in bytes, 0 is unlimited.
+	// Longer titles are refused with CodeInvalidArgument.
+	MaxTitleLength int
+	// MaxUnaryUploadBytes bounds the data of one UploadFile call, which is
+	// held in memory whole, 0 is unlimited. Larger files must be streamed
+	// with Upload or split into UploadFile calls with offset.
+	MaxUnaryUploadBytes int64
+	// MaxBufferMemory bounds the memory of received chunks waiting to be
+	// written across all uploads, 0 is unlimited. A stream that would exceed
+	// it stops reading until enough chunks have been written.
+	MaxBufferMemory int64
+	// MaxOpenFiles bounds the storage files held open at once by uploads and
+	// downloads, 0 is unlimited. Short opens such as hashing are not counted,
+	// so keep it under the process descriptor limit with some headroom.
+	MaxOpenFiles int
+	// OpenFilesWait is how long a request waits for one of MaxOpenFiles
+	// before failing with CodeResourceExhausted, 5s when 0
+	OpenFilesWait time.Duration
+	// PartialMaxAge deletes partial ranged PUT and tus uploads not written to
+	// for this long, 0 keeps them until they complete
+	PartialMaxAge time.Duration
+	// MaxIncompleteSessions refuses new resumable uploads while this many are
+	// incomplete, 0 is unlimited
+	MaxIncompleteSessions int
+	// RedactFilenames logs a short digest instead of each filename and title.
+	// The events log and AuditSink still record them.
+	RedactFilenames bool
+	// RedactHashes logs content hashes truncated to 8 hex characters
+	RedactHashes bool
+	// CompressMinBytes is the smallest response gzipped for clients that accept it
+	CompressMinBytes int
+	// CompressDownloads gzips GET /files/{name} responses of compressible
+	// types (text, JSON, XML...) larger than CompressMinBytes for clients
+	// that accept it. Range requests are answered uncompressed.
+	CompressDownloads bool
+	// SlowChunkThreshold logs a warning when a stream waits longer for a chunk, 0 disables it
+	SlowChunkThreshold time.Duration
+	// Sync is when uploaded data is fsynced before success is reported,
+	// SyncNone by default. Stronger policies cost write throughput.
+	Sync SyncPolicy
+	// HashWorkers moves the hashing of streamed uploads off the write path to
+	// up to this many goroutines shared by all uploads: each upload's SHA-256,
+	// and with segment_size several segments at once. A chunk is then hashed
+	// while the next one is received and written. When every worker is busy
+	// an upload hashes on its write path. 0, the default, always does.
+	HashWorkers int
+	// SkipHashVerification stores Upload and UploadBidi streams without
+	// hashing them, for trusted networks where the CPU is better spent on
+	// throughput. The client's hash is not checked, responses report
+	// HASH_STATUS_NOT_PROVIDED and no sha256, and the files are left out of
+	// the hash index. Hash only, segmented and if_match uploads are refused,
+	// and New refuses it with RejectDuplicateContent or a {hash} StorageKey.
+	SkipHashVerification bool
+
+	// RandomNames ignores the client's filename and stores every upload
+	// under a random UUID keeping the extension, returned as
+	// UploadResponse.stored_filename. The events log keeps the requested name.
+	RandomNames bool
+	// Retention makes the store write-once (WORM): a stored file cannot be
+	// overwritten, renamed or deleted by its expiry for this long, attempts
+	// fail with CodePermissionDenied. The end of each retention is kept in
+	// the upload directory and enforced even once Retention is turned off.
+	Retention time.Duration
+	// NameTransformer rewrites the sanitized client filename into the name a
+	// file is stored under, returned as UploadResponse.stored_filename; its
+	// result is sanitized again. See Slugify, TimestampPrefix and
+	// ParseNameTransformer. It is not applied with RandomNames.
+	NameTransformer func(original string) string
+
+	// ThumbnailSize makes a JPEG thumbnail whose longest side is this many
+	// pixels of every stored PNG, JPEG or GIF image, served by GetThumbnail;
+	// 0 disables thumbnails
+	ThumbnailSize int
+	// ThumbnailMaxSource skips images whose width or height is over this many
+	// pixels instead of decoding them, 0 is unlimited
+	ThumbnailMaxSource int
+
+	// Routes store files in subdirectories of Dir by extension or content
+	// type, the first match wins. Unmatched files stay in Dir. Routes apply to
+	// the sanitized filename, so downloads and metadata find them the same way.
+	Routes []Route
+	// StorageKey places every stored file at a path derived from a template
+	// such as "{namespace}/{yyyy}/{mm}/{hash[:2]}/{filename}", evaluated once
+	// its content is stored, instead of its route's directory. The variables
+	// are namespace (the caller's certificate organization, "default" without
+	// one), yyyy, mm and dd (UTC), hash or hash[:n], filename and uuid; the
+	// last element must be {filename}. Where each file went is kept in Dir by
+	// namespace: callers only reach the files their own namespace stored, so
+	// two namespaces may store the same filename. Empty uses Routes.
+	StorageKey string
+
+	// ExtractDir enables the extract upload option: archives are unpacked
+	// into a directory of it named after the archive. Empty disables it.
+	ExtractDir string
+	// ExtractMaxBytes and ExtractMaxEntries bound what one archive may unpack
+	// to, against zip bombs; 0 is unlimited
+	ExtractMaxBytes   int64
+	ExtractMaxEntries int
+	// ReadOnly reports whether the server is in read-only mode, where uploads,
+	// renames and copies fail with CodeUnavailable while downloads and
+	// metadata keep working. It is consulted on every write; nil leaves the
+	// server writable.
+	ReadOnly func() bool
+	// ExternalURL returns where clients fetch a stored file directly, such as a
+	// pre-signed object storage URL; name is the file's path relative to Dir
+	// with forward slashes. When set, Download and GET /files/{name} hand out
+	// this location instead of the content, and GetFileMetadata includes it.
+	ExternalURL func(name string) (string, error)
+
+	// AllowedClients are the client certificate names (CN, DNS or email SAN)
+	// allowed to upload; empty allows everyone. Only meaningful when the
+	// enclosing server verifies client certificates.
+	AllowedClients []string
+	// MaxUploadsPerIdentity caps the uploads in progress per client
+	// certificate identity, 0 is unlimited. Callers without a certificate
+	// are not limited.
+	MaxUploadsPerIdentity int
+	// MaxUploadsPerNamespace caps the uploads in progress per namespace, the
+	// organization (O) of the client certificate, shared by every identity
+	// of a tenant; 0 is unlimited. Callers without one are not limited.
+	MaxUploadsPerNamespace int
+	// ExtensionPolicies restrict the file types each namespace may store,
+	// keyed by namespace (the organization of the client certificate). The
+	// "*" entry applies to the namespaces without one and to callers without
+	// a certificate; without it they are not restricted. Refused uploads fail
+	// with CodeInvalidArgument.
+	ExtensionPolicies map[string]ExtensionPolicy
+
+	// TracerProvider receives a server span for every RPC and plain-HTTP
+	// upload, with child spans for the write and hash phases; nil disables tracing
+	TracerProvider trace.TracerProvider
+
+	// Context bounds the background work: when it is done the storage probe
+	// stops and the index and events log are closed. nil means context.Background().
+	Context context.Context
+}
+
+const defaultStorageProbeInterval = 10 * time.Second
+
+// New prepares the storage directory, the hash index and the events log and
+// returns a handler serving the upload RPCs and HTTP endpoints:
+//
+//	POST /upload             multipart/form-data upload
+//	PUT  /files/{name}       whole or Content-Range upload
+//	GET  /files/{name}       download with Range support
+//	     /tus/               tus resumable uploads
+//	GET  /healthz, /readyz   liveness and storage readiness
+//
+// The expvar metrics are not among them, see MetricsHandler.
+func New(cfg Config) (http.Handler, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("uploadserver: Config.Dir is required")
+	}
+	ctx := cfg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create upload directory: %w", err)
+	}
+	probe := cfg.StorageProbe
+	if probe == nil {
+		probe = func() error { return checkWritable(cfg.Dir) }
+	}
+	if err := probe(); err != nil {
+		return nil, fmt.Errorf("upload directory %q is not writable: %w", cfg.Dir, err)
+	}
+
+	files, err := newRouter(cfg.Dir, cfg.Routes)
+	if err != nil {
+		return nil, err
+	}
+	storageKey, err := parseKeyTemplate(cfg.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SkipHashVerification && (cfg.RejectDuplicateContent || storageKey.uses("hash")) {
+		return nil, fmt.Errorf("skipping hash verification leaves no hash for duplicate rejection or a {hash} storage key")
+	}
+	// read before the hash index, which scans the directories of the keys
+	if files.keys, err = openStorageKeys(filepath.Join(cfg.Dir, storageKeyFile)); err != nil {
+		return nil, fmt.Errorf("load storage keys: %w", err)
+	}
+	extensions, err := newExtensionPolicies(cfg.ExtensionPolicies)
+	if err != nil {
+		return nil, err
+	}
+	extract, err := newExtractor(cfg.ExtractDir, cfg.ExtractMaxBytes, cfg.ExtractMaxEntries)
+	if err != nil {
+		return nil, err
+	}
+	disk, err := newDiskWatermark(cfg.DiskHighWater, cfg.DiskLowWater, cfg.DiskUsage, cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	redact := redactor{names: cfg.RedactFilenames, hashes: cfg.RedactHashes}
+	index, err := openHashIndex(cfg.IndexFile, files, cfg.RebuildIndex, redact)
+	if err != nil {
+		return nil, fmt.Errorf("index upload directory: %w", err)
+	}
+	expiries, err := openExpiries(filepath.Join(cfg.Dir, expiryFile))
+	if err != nil {
+		return nil, fmt.Errorf("load file expiries: %w", err)
+	}
+	if cfg.Retention < 0 {
+		return nil, fmt.Errorf("retention %v must not be negative", cfg.Retention)
+	}
+	retention, err := openExpiries(filepath.Join(cfg.Dir, retentionFile))
+	if err != nil {
+		return nil, fmt.Errorf("load file retention: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		index.close()
+	}()
+
+	s := &Server{
+		dir:                   cfg.Dir,
+		files:                 files,
+		storage:               newStorageHealth(probe),
+		disk:                  disk,
+		index:                 index,
+		expiries:              expiries,
+		retention:             retention,
+		retentionPeriod:       cfg.Retention,
+		rejectDuplicates:      cfg.RejectDuplicateContent,
+		dedupCopies:           cfg.DedupCopies,
+		slowChunkThreshold:    cfg.SlowChunkThreshold,
+		maxFileSize:           cfg.MaxFileSize,
+		maxUnaryUploadBytes:   cfg.MaxUnaryUploadBytes,
+		extensions:            extensions,
+		maxMessageBytes:       cfg.MaxMessageBytes,
+		rules:                 metadataRules{maxTitleLength: cfg.MaxTitleLength},
+		buffers:               newByteBudget(cfg.MaxBufferMemory),
+		openFiles:             newFileBudget(cfg.MaxOpenFiles, cfg.OpenFilesWait),
+		partialMaxAge:         cfg.PartialMaxAge,
+		maxIncompleteSessions: cfg.MaxIncompleteSessions,
+		externalURL:           cfg.ExternalURL,
+		readOnly:              cfg.ReadOnly,
+		extract:               extract,
+		sync:                  cfg.Sync,
+		hashWorkers:           make(chan struct{}, max(cfg.HashWorkers, 0)),
+		skipHash:              cfg.SkipHashVerification,
+		redact:                redact,
+		randomNames:           cfg.RandomNames,
+		nameTransformer:       cfg.NameTransformer,
+		storageKey:            storageKey,
+		publisher:             cfg.Publisher,
+		publishRequired:       cfg.PublishRequired,
+		thumbs:                newThumbnailer(cfg.ThumbnailSize, cfg.ThumbnailMaxSource),
+		compressDownloads:     cfg.CompressDownloads,
+		compressMinBytes:      int64(cfg.CompressMinBytes),
+	}
+	s.ranged.dir = filepath.Join(s.dir, partialDir, rangesDir)
+	s.ranged.sync = cfg.Sync == SyncPerChunk
+	s.keys.dir = filepath.Join(s.dir, partialDir, keysDir)
+	s.keys.sync = cfg.Sync != SyncNone
+
+	if cfg.SelfTest {
+		if err := s.selfTest(ctx); err != nil {
+			return nil, fmt.Errorf("storage self-test failed: %w", err)
+		}
+	}
+
+	if cfg.EventsLog != "" {
+		events, err := openEventLog(cfg.EventsLog, cfg.EventsMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("open events log: %w", err)
+		}
+		s.events = events
+		go func() {
+			<-ctx.Done()
+			events.Close()
+		}()
+	}
+
+	interval := cfg.StorageProbeInterval
+	if interval <= 0 {
+		interval = defaultStorageProbeInterval
+	}
+	go s.storage.run(ctx, interval)
+	go s.disk.run(ctx, interval)
+	go s.runJanitor(ctx)
+	s.stopping = ctx.Done()
+
+	allowed := newClientAllowlist(cfg.AllowedClients)
+	limiter := newCallerLimiters(newIdentityLimiter(cfg.MaxUploadsPerIdentity), newNamespaceLimiter(cfg.MaxUploadsPerNamespace))
+	interceptors := []connect.Interceptor{allowlistInterceptor{allowed}}
+	if len(limiter) > 0 {
+		interceptors = append(interceptors, identityLimitInterceptor{limiter})
+	}
+	interceptors = append(interceptors, validationInterceptor{s.rules})
+	if cfg.AuditSink != nil {
+		// outside the allowlist, so that refused calls are recorded too
+		interceptors = append([]connect.Interceptor{auditInterceptor{cfg.AuditSink}}, interceptors...)
+	}
+	provider := cfg.TracerProvider
+	if provider != nil {
+		tracing, err := newTracingInterceptor(provider)
+		if err != nil {
+			return nil, fmt.Errorf("set up tracing: %w", err)
+		}
+		// outermost, so that refused calls are traced too
+		interceptors = append([]connect.Interceptor{tracing}, interceptors...)
+	}
+
+	mux := http.NewServeMux()
+	// gzip-compressed requests are accepted out of the box, responses are only
+	// compressed when the client asks for it and the message is worth it
+	mux.Handle(fileuploadv1connect.NewFileUploadServiceHandler(s,
+		connect.WithReadMaxBytes(cfg.MaxMessageBytes),
+		connect.WithCompressMinBytes(cfg.CompressMinBytes),
+		connect.WithInterceptors(interceptors...),
+	))
+	mux.HandleFunc("POST /upload", traced(provider, allowed.require(limiter.require(decodeBody(s.handleMultipartUpload)))))
+	mux.HandleFunc("PUT /files/{name}", traced(provider, allowed.require(limiter.require(decodeBody(s.handlePutFile)))))
+	mux.HandleFunc("GET /files/{name}", s.handleGetFile)
+	mux.HandleFunc(tusBasePath, traced(provider, allowed.require(limiter.require(s.handleTus))))
+	mux.HandleFunc("GET /healthz", healthz)
+	mux.HandleFunc("GET /readyz", s.storage.readyz)
+
+	return withCaller(s.withRequestConfig(checkHeaders(mux))), nil
+}
+
+// ExposedHeaders are the response headers browser clients need to read, for
+// the CORS configuration of the enclosing server
+var ExposedHeaders = []string{
+	"Connect-Protocol-Version", "Grpc-Status", "Grpc-Message", "Range",
+	"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Upload-Offset", "Upload-Length",
+	"Tus-Max-Size", "Accept-Ranges", "Content-Range", "ETag", "Upload-Stored-Filename", "Upload-Session",
+}
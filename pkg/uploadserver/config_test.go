@@ -0,0 +1,102 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+// serveConfig serves the handler New returns for cfg, stopped with the test
+func serveConfig(t *testing.T, cfg Config) (*httptest.Server, fileuploadv1connect.FileUploadServiceClient) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	cfg.Context = ctx
+	handler, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := httptest.NewServer(handler)
+	t.Cleanup(hs.Close)
+	return hs, fileuploadv1connect.NewFileUploadServiceClient(hs.Client(), hs.URL)
+}
+
+func TestNew(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+	hs, client := serveConfig(t, Config{Dir: dir, EventsLog: events, IndexFile: filepath.Join(t.TempDir(), "index.json")})
+
+	_, err := client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename: "embedded.txt",
+		Data:     []byte("served by New"),
+		Sha256:   sha256Hex("served by New"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "embedded.txt")); err != nil || string(b) != "served by New" {
+		t.Fatalf("stored %q, %v", b, err)
+	}
+	if got := readEvents(t, events); len(got) != 1 || got[0].Filename != "embedded.txt" {
+		t.Fatalf("events %+v, want the upload", got)
+	}
+
+	for _, path := range []string{"/files/embedded.txt", "/healthz", "/readyz"} {
+		resp, err := hs.Client().Get(hs.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestNewRequiresDir(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Fatal("New without a directory succeeded")
+	}
+}
+
+func TestNewStorageProbe(t *testing.T) {
+	broken := errors.New("read-only filesystem")
+	_, err := New(Config{Dir: t.TempDir(), StorageProbe: func() error { return broken }})
+	if !errors.Is(err, broken) {
+		t.Fatalf("New over unwritable storage: %v, want the probe error", err)
+	}
+}
+
+func TestNewConfigLimits(t *testing.T) {
+	hs, client := serveConfig(t, Config{Dir: t.TempDir(), MaxFileSize: 4, AllowedClients: []string{"alice"}})
+
+	_, err := client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "a.txt", Data: []byte("x"), Sha256: sha256Hex("x")})
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Fatalf("upload without a client certificate: %v, want permission denied", err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, hs.URL+"/files/a.txt", strings.NewReader("x"))
+	resp, err := hs.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("PUT without a client certificate: status %d, want 403", resp.StatusCode)
+	}
+
+	_, client = serveConfig(t, Config{Dir: t.TempDir(), MaxFileSize: 4})
+	_, err = client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "a.txt", Data: []byte("too big"), Sha256: sha256Hex("too big")})
+	if connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("upload over MaxFileSize: %v, want resource exhausted", err)
+	}
+}
@@ -0,0 +1,111 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// CopyFile stores a copy of a stored file under a new name, as if it had
+// been uploaded again: it is indexed, thumbnailed and published like one.
+// With Config.DedupCopies the copy is a hard link sharing the content of the
+// file, taking no extra space, unless they are on different filesystems.
+func (s *Server) CopyFile(
+	ctx context.Context, req *fileuploadv1.CopyFileRequest) (*fileuploadv1.UploadResponse, error) {
+
+	from, to := sanitizeFilename(req.From), sanitizeFilename(req.To)
+	if err := s.checkUploadable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkExtension(ctx, to); err != nil {
+		return nil, err
+	}
+	from, to = s.lookupName(ctx, from), s.uploadName(ctx, to)
+	src := s.files.path(from)
+	info, err := os.Stat(src)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("file not found: %s", baseName(from)))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if from == to {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("cannot copy a file onto itself"))
+	}
+	if err := s.checkRetention(to); err != nil {
+		return nil, err
+	}
+
+	hash, ok := s.index.hashOf(from)
+	if !ok {
+		if hash, err = hashFile(src); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	}
+	// a copy is duplicate content by definition
+	if err := s.checkDuplicate(to, hash); err != nil {
+		return nil, err
+	}
+	if err := s.checkFileSize(info.Size()); err != nil {
+		return nil, err
+	}
+	dst, key, err := s.writePath(to, hash, req.Overwrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return nil, writeError(err)
+	}
+	linked := false
+	if s.dedupCopies {
+		linked, err = linkFile(src, dst, req.Overwrite)
+	}
+	if err == nil && !linked {
+		err = s.copyFile(src, dst, req.Overwrite)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("%s already exists", baseName(to)))
+		}
+		// an overwritten file is gone
+		s.index.remove(to)
+		return nil, writeError(err)
+	}
+	if err := s.syncDir(dst); err != nil {
+		return nil, writeError(err)
+	}
+	if key != "" {
+		s.keyed(to, key)
+	}
+	s.index.set(to, hash)
+	s.expiries.set(to, time.Time{})
+	s.retain(to)
+	message := "copied"
+	if linked {
+		message = "linked"
+	}
+	log.Printf("Copied: %s to %s (%d bytes, %s)", s.redact.name(from), s.redact.name(to), info.Size(), message)
+
+	if err := s.uploaded(ctx, "CopyFile", baseName(to), to, info.Size(), hash); err != nil {
+		return nil, err
+	}
+	return &fileuploadv1.UploadResponse{
+		Message:        message,
+		Size:           info.Size(),
+		HashOk:         true,
+		HashStatus:     fileuploadv1.HashStatus_HASH_STATUS_VERIFIED,
+		Sha256:         hash,
+		StoredFilename: baseName(to),
+		StoragePath:    s.files.relPath(to),
+	}, nil
+}
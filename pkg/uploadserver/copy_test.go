@@ -0,0 +1,113 @@
+package uploadserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestCopyFileCopiesBytes(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	ts.uploadFile(t, "a.txt", "original")
+	resp, err := ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "a.txt", To: "b.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "copied" || resp.StoredFilename != "b.txt" || resp.Sha256 != sha256Hex("original") {
+		t.Fatalf("response %v", resp)
+	}
+	if got := ts.stored(t, "b.txt"); got != "original" {
+		t.Fatalf("copy holds %q", got)
+	}
+	if ts.sameFile(t, "a.txt", "b.txt") {
+		t.Fatal("the copy shares the original's content without DedupCopies")
+	}
+
+	_, err = ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "a.txt", To: "b.txt"})
+	if connect.CodeOf(err) != connect.CodeAlreadyExists {
+		t.Fatalf("copy onto an existing file: %v, want already exists", err)
+	}
+}
+
+func TestCopyFileDedup(t *testing.T) {
+	ts := newTestServer(t, &Server{dedupCopies: true})
+	ts.uploadFile(t, "a.txt", "original")
+	resp, err := ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "a.txt", To: "b.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "linked" || resp.Size != int64(len("original")) || resp.Sha256 != sha256Hex("original") {
+		t.Fatalf("response %v", resp)
+	}
+	if !ts.sameFile(t, "a.txt", "b.txt") {
+		t.Fatal("the copy does not share the original's content")
+	}
+
+	// an existing file is replaced by a link too
+	ts.uploadFile(t, "c.txt", "other")
+	if _, err := ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "a.txt", To: "c.txt", Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !ts.sameFile(t, "a.txt", "c.txt") {
+		t.Fatal("the overwritten copy does not share the original's content")
+	}
+	entries, _ := os.ReadDir(ts.dir)
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) == ".link" {
+			t.Errorf("temporary link %s left behind", e.Name())
+		}
+	}
+
+	// uploading over the copy, by stream, unary call or PUT, leaves the original alone
+	if _, err := ts.streamUpload(t.Context(), "b.txt", []byte("streamed"), 4); err != nil {
+		t.Fatal(err)
+	}
+	ts.uploadFile(t, "c.txt", "replaced")
+	if resp, _ := ts.putRange(t, "a.txt", "", "put"); resp.StatusCode != 201 {
+		t.Fatalf("PUT: status %d", resp.StatusCode)
+	}
+	for name, want := range map[string]string{"a.txt": "put", "b.txt": "streamed", "c.txt": "replaced"} {
+		if got := ts.stored(t, name); got != want {
+			t.Errorf("%s holds %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCopyFileIndexedAndChecked(t *testing.T) {
+	ts := newTestServer(t, &Server{
+		index:      newHashIndex(),
+		extensions: map[string]ExtensionPolicy{"*": {Deny: []string{".exe"}}},
+	})
+	ts.uploadFile(t, "a.txt", "original")
+	if _, err := ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "a.txt", To: "b.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if hash, ok := ts.srv.index.hashOf("b.txt"); !ok || hash != sha256Hex("original") {
+		t.Fatalf("index entry of the copy: %q, %v", hash, ok)
+	}
+
+	for name, tt := range map[string]struct {
+		from, to string
+		code     connect.Code
+	}{
+		"missing source":    {"missing.txt", "c.txt", connect.CodeNotFound},
+		"onto itself":       {"a.txt", "a.txt", connect.CodeInvalidArgument},
+		"refused extension": {"a.txt", "a.exe", connect.CodeInvalidArgument},
+	} {
+		_, err := ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: tt.from, To: tt.to})
+		if connect.CodeOf(err) != tt.code {
+			t.Errorf("%s: %v, want %v", name, err, tt.code)
+		}
+	}
+	ts.assertNotStored(t, "a.exe")
+
+	// a copy is duplicate content by definition
+	ts.srv.rejectDuplicates = true
+	if _, err := ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "a.txt", To: "d.txt"}); connect.CodeOf(err) != connect.CodeAlreadyExists {
+		t.Fatalf("copy with duplicate rejection: %v, want already exists", err)
+	}
+}
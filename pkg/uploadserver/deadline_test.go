@@ -0,0 +1,78 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+// timeoutHeader sends timeout in the Connect timeout header of streaming calls
+type timeoutHeader time.Duration
+
+func (d timeoutHeader) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc { return next }
+
+func (d timeoutHeader) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		conn.RequestHeader().Set("Connect-Timeout-Ms", fmt.Sprint(time.Duration(d).Milliseconds()))
+		return conn
+	}
+}
+
+func (d timeoutHeader) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+func TestDeadlineExceededMidUpload(t *testing.T) {
+	events, err := openEventLog(filepath.Join(t.TempDir(), "events.jsonl"), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	ts := newTestServer(t, &Server{events: events})
+
+	// the deadline is only sent in the Connect timeout header, so that the
+	// client does not cancel the call itself before the server sees it expire
+	client := fileuploadv1connect.NewFileUploadServiceClient(ts.Client(), ts.URL,
+		connect.WithInterceptors(timeoutHeader(200*time.Millisecond)))
+	stream, err := client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: "slow.txt"},
+	}})
+	// a throttled client keeps sending chunks past its deadline
+	for range 10 {
+		if err := stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{
+			Chunk: []byte("chunk"),
+		}}); err != nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err := stream.CloseAndReceive(); connect.CodeOf(err) != connect.CodeDeadlineExceeded {
+		t.Fatalf("got %v, want deadline_exceeded", err)
+	}
+
+	// the server notices the deadline on its own, and logs the upload once it cleaned up
+	var logged []uploadEvent
+	for start := time.Now(); len(logged) == 0 && time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		logged = readEvents(t, events.path)
+	}
+	if len(logged) != 1 || logged[0].Code != connect.CodeDeadlineExceeded.String() {
+		t.Fatalf("events %+v, want one deadline_exceeded upload", logged)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "slow.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("partial file left behind: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package uploadserver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// sizedUpload streams data as name in chunks of 4 bytes, declaring size in the metadata
+func (ts *testServer) sizedUpload(t *testing.T, name, data string, size *int64) error {
+	t.Helper()
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := []*fileuploadv1.UploadRequest{{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: name, DeclaredSize: size},
+	}}}
+	for off := 0; off < len(data); off += 4 {
+		reqs = append(reqs, &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{
+			Chunk: []byte(data[off:min(off+4, len(data))]),
+		}})
+	}
+	reqs = append(reqs, &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{
+		FinishCommit: sha256Hex(data),
+	}})
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			break
+		}
+	}
+	_, err = stream.CloseAndReceive()
+	return err
+}
+
+func TestDeclaredSize(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	const data = "0123456789"
+	for _, tc := range []struct {
+		name string
+		size *int64
+		code connect.Code // 0 when the upload succeeds
+	}{
+		{"unset.txt", nil, 0},
+		{"exact.txt", proto.Int64(10), 0},
+		{"under.txt", proto.Int64(12), connect.CodeInvalidArgument},
+		{"over.txt", proto.Int64(6), connect.CodeInvalidArgument},
+		{"negative.txt", proto.Int64(-1), connect.CodeInvalidArgument},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ts.sizedUpload(t, tc.name, data, tc.size)
+			if tc.code == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := ts.stored(t, tc.name); got != data {
+					t.Fatalf("stored %q", got)
+				}
+				return
+			}
+			if connect.CodeOf(err) != tc.code {
+				t.Fatalf("got %v, want %v", err, tc.code)
+			}
+			if _, err := os.Stat(filepath.Join(ts.dir, tc.name)); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("a rejected upload was stored: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package uploadserver
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"connectrpc.com/connect"
+)
+
+func TestWriteErrorDiskFull(t *testing.T) {
+	// a write to a full disk fails with ENOSPC wrapped in a PathError
+	full := errWriter{&fs.PathError{Op: "write", Path: "f", Err: syscall.ENOSPC}}
+	err := writeError(writeFull(full, []byte("x")))
+	if connect.CodeOf(err) != connect.CodeResourceExhausted || !errors.Is(err, errDiskFull) {
+		t.Fatalf("write to a full disk: %v, want resource exhausted: disk full", err)
+	}
+	err = writeError(writeFull(errWriter{syscall.EIO}, []byte("x")))
+	if connect.CodeOf(err) != connect.CodeInternal {
+		t.Fatalf("failed write: %v, want internal", err)
+	}
+}
+
+func TestWriteHTTPErrorDiskFull(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{writeError(syscall.ENOSPC), http.StatusInsufficientStorage},
+		{connect.NewError(connect.CodeResourceExhausted, errors.New("too big")), http.StatusRequestEntityTooLarge},
+	} {
+		rec := httptest.NewRecorder()
+		writeHTTPError(rec, tc.err)
+		if rec.Code != tc.status {
+			t.Errorf("writeHTTPError(%v): status %d, want %d", tc.err, rec.Code, tc.status)
+		}
+	}
+}
+
+// fillDisk makes path a link to /dev/full, where every write fails with ENOSPC
+func fillDisk(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full to simulate a full disk")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path)
+	if err := os.Symlink("/dev/full", path); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+}
+
+func TestRangedPutDiskFull(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	fillDisk(t, ts.partialPath("full.bin"))
+
+	resp, body := ts.putRange(t, "full.bin", "bytes 0-3/8", "data")
+	if resp.StatusCode != http.StatusInsufficientStorage {
+		t.Fatalf("PUT to a full disk: status %d (%s), want 507", resp.StatusCode, body)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "full.bin")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("an upload that hit a full disk was stored")
+	}
+}
+
+func TestTusPatchDiskFull(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	location := ts.tusCreate(t, "full.bin", 4)
+	_, dataPath := ts.srv.tusPaths(path.Base(location))
+	fillDisk(t, dataPath)
+
+	req := ts.tusRequest(t, http.MethodPatch, location, "data")
+	req.Header.Set("Upload-Offset", "0")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	resp, body := ts.do(t, req)
+	if resp.StatusCode != http.StatusInsufficientStorage {
+		t.Fatalf("PATCH to a full disk: status %d (%s), want 507", resp.StatusCode, body)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "full.bin")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("an upload that hit a full disk was stored")
+	}
+}
@@ -0,0 +1,121 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// diskUsedPercent and diskNearlyFull publish the latest disk usage check on
+// /debug/vars, with Config.DiskHighWater set
+var (
+	diskUsedPercent = expvar.NewFloat("uploadserver_disk_used_percent")
+	diskNearlyFull  = expvar.NewInt("uploadserver_disk_nearly_full")
+)
+
+// errStorageNearlyFull is the cause of the ResourceExhausted error refusing
+// uploads above Config.DiskHighWater
+var errStorageNearlyFull = errors.New("storage nearly full")
+
+// diskWatermark refuses uploads once the disk usage reaches high percent and
+// accepts them again below low, so uploads fail early instead of filling the
+// disk halfway through. Usage is sampled periodically, not per request.
+type diskWatermark struct {
+	high, low float64
+	usage     func() (used, total uint64, err error)
+	full      atomic.Bool
+}
+
+// newDiskWatermark returns nil when high is 0. usage nil measures the
+// filesystem holding dir.
+func newDiskWatermark(high, low float64, usage func() (used, total uint64, err error), dir string) (*diskWatermark, error) {
+	if high == 0 {
+		return nil, nil
+	}
+	if high < 0 || high > 100 {
+		return nil, fmt.Errorf("disk high-water mark %v%% is not between 0 and 100", high)
+	}
+	if low == 0 {
+		low = max(0, high-5)
+	}
+	if low < 0 || low > high {
+		return nil, fmt.Errorf("disk low-water mark %v%% is not between 0 and the high-water mark %v%%", low, high)
+	}
+	if usage == nil {
+		usage = func() (uint64, uint64, error) { return diskUsage(dir) }
+	}
+	w := &diskWatermark{high: high, low: low, usage: usage}
+	if _, _, err := usage(); err != nil {
+		return nil, fmt.Errorf("measure disk usage: %w", err)
+	}
+	w.update()
+	return w, nil
+}
+
+// update samples the disk usage and logs when uploads stop or resume; a
+// failed measurement keeps the previous state
+func (w *diskWatermark) update() {
+	used, total, err := w.usage()
+	if err != nil {
+		log.Printf("Failed to measure disk usage: %v", err)
+		return
+	}
+	if total == 0 {
+		return
+	}
+	percent := float64(used) / float64(total) * 100
+	diskUsedPercent.Set(percent)
+	switch full := w.full.Load(); {
+	case !full && percent >= w.high:
+		w.full.Store(true)
+		log.Printf("Disk usage %.1f%% reached the high-water mark of %v%%, refusing uploads", percent, w.high)
+	case full && percent < w.low:
+		w.full.Store(false)
+		log.Printf("Disk usage %.1f%% is below the low-water mark of %v%%, accepting uploads again", percent, w.low)
+	}
+	if w.full.Load() {
+		diskNearlyFull.Set(1)
+	} else {
+		diskNearlyFull.Set(0)
+	}
+}
+
+// run samples the disk usage every interval until ctx is done
+func (w *diskWatermark) run(ctx context.Context, interval time.Duration) {
+	if w == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.update()
+		}
+	}
+}
+
+// check returns a CodeResourceExhausted error above the high-water mark
+func (w *diskWatermark) check() error {
+	if w != nil && w.full.Load() {
+		return connect.NewError(connect.CodeResourceExhausted, errStorageNearlyFull)
+	}
+	return nil
+}
+
+// checkUploadable is checkWritesAllowed for requests that add data to the
+// store, which are also refused while the disk is nearly full
+func (s *Server) checkUploadable() error {
+	if err := s.checkWritesAllowed(); err != nil {
+		return err
+	}
+	return s.disk.check()
+}
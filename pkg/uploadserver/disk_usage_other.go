@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package uploadserver
+
+import "errors"
+
+// diskUsage is not implemented here, set Config.DiskUsage instead
+func diskUsage(dir string) (used, total uint64, err error) {
+	return 0, 0, errors.New("disk usage is not supported on this platform, set Config.DiskUsage")
+}
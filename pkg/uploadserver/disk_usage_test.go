@@ -0,0 +1,97 @@
+package uploadserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// fakeDisk reports used percent of a 100 byte disk
+type fakeDisk struct{ used atomic.Uint64 }
+
+func (d *fakeDisk) usage() (used, total uint64, err error) {
+	return d.used.Load(), 100, nil
+}
+
+func TestDiskWatermark(t *testing.T) {
+	disk := &fakeDisk{}
+	disk.used.Store(50)
+	w, err := newDiskWatermark(90, 80, disk.usage, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		used uint64
+		full bool
+	}{
+		{89, false},
+		{90, true},
+		{85, true}, // between the marks nothing changes
+		{79, false},
+		{85, false},
+	} {
+		disk.used.Store(tc.used)
+		w.update()
+		if err := w.check(); (err != nil) != tc.full {
+			t.Errorf("at %d%%: %v, want refused %v", tc.used, err, tc.full)
+		}
+	}
+	if got := diskUsedPercent.Value(); got != 85 {
+		t.Errorf("published usage %v, want 85", got)
+	}
+}
+
+func TestDiskWatermarkConfig(t *testing.T) {
+	usage := (&fakeDisk{}).usage
+	if w, err := newDiskWatermark(0, 0, usage, ""); w != nil || err != nil {
+		t.Fatalf("disabled watermark: %v, %v", w, err)
+	}
+	w, err := newDiskWatermark(90, 0, usage, "")
+	if err != nil || w.low != 85 {
+		t.Fatalf("default low-water mark %v, %v, want 85", w, err)
+	}
+	for _, tc := range []struct{ high, low float64 }{{101, 0}, {-1, 0}, {90, 95}, {90, -1}} {
+		if _, err := newDiskWatermark(tc.high, tc.low, usage, ""); err == nil {
+			t.Errorf("marks %v/%v accepted", tc.high, tc.low)
+		}
+	}
+	broken := func() (uint64, uint64, error) { return 0, 0, errors.New("statfs failed") }
+	if _, err := newDiskWatermark(90, 0, broken, ""); err == nil {
+		t.Error("a disk that cannot be measured was accepted")
+	}
+}
+
+func TestUploadsRefusedNearlyFull(t *testing.T) {
+	disk := &fakeDisk{}
+	disk.used.Store(95)
+	w, err := newDiskWatermark(90, 0, disk.usage, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, &Server{disk: w})
+
+	_, err = ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "a.txt", Data: []byte("a"), Sha256: sha256Hex("a")})
+	if connect.CodeOf(err) != connect.CodeResourceExhausted || !strings.Contains(err.Error(), errStorageNearlyFull.Error()) {
+		t.Fatalf("UploadFile: %v, want resource exhausted: storage nearly full", err)
+	}
+	if _, err := ts.streamUpload(t.Context(), "b.txt", []byte("b"), 1); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("Upload: %v, want resource exhausted", err)
+	}
+	if resp, body := ts.putRange(t, "c.txt", "bytes 0-0/1", "c"); resp.StatusCode != http.StatusInsufficientStorage {
+		t.Fatalf("ranged PUT: status %d (%s), want 507", resp.StatusCode, body)
+	}
+	ts.assertNotStored(t, "a.txt")
+
+	// uploads resume once usage drops below the low-water mark
+	disk.used.Store(50)
+	w.update()
+	if got := ts.uploadFile(t, "a.txt", "a").StoredFilename; got != "a.txt" {
+		t.Fatalf("stored %q", got)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows && !plan9
+
+package uploadserver
+
+import "syscall"
+
+// diskUsage returns the used and total bytes of the filesystem holding dir,
+// counting the blocks reserved for root as used like df does
+func diskUsage(dir string) (used, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	used = (st.Blocks - st.Bfree) * uint64(st.Bsize)
+	return used, used + st.Bavail*uint64(st.Bsize), nil
+}
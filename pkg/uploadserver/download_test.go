@@ -0,0 +1,84 @@
+package uploadserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// download returns the content of the stored file name, read with Download
+func (ts *testServer) download(ctx context.Context, name string) ([]byte, error) {
+	stream, err := ts.client.Download(ctx, &fileuploadv1.DownloadRequest{Filename: name})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	var got []byte
+	for stream.Receive() {
+		got = append(got, stream.Msg().Chunk...)
+	}
+	return got, stream.Err()
+}
+
+func TestDownload(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	// spans several download chunks
+	data := strings.Repeat("0123456789", downloadChunkSize/4)
+	ts.uploadFile(t, "big.txt", data)
+
+	got, err := ts.download(t.Context(), "big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Fatalf("downloaded %d bytes, want the %d stored", len(got), len(data))
+	}
+	if got, err := ts.download(t.Context(), "../uploads/big.txt"); err != nil || string(got) != data {
+		t.Fatalf("download by a path: %d bytes, %v, want the sanitized name", len(got), err)
+	}
+
+	if _, err := ts.download(t.Context(), "missing.txt"); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("download of a missing file: %v, want not found", err)
+	}
+	if err := os.Mkdir(filepath.Join(ts.dir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.download(t.Context(), "subdir"); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("download of a directory: %v, want not found", err)
+	}
+}
+
+func TestDownloadOffset(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	data := strings.Repeat("0123456789", downloadChunkSize/4)
+	ts.uploadFile(t, "big.txt", data)
+
+	read := func(offset int64) (string, error) {
+		stream, err := ts.client.Download(t.Context(), &fileuploadv1.DownloadRequest{Filename: "big.txt", Offset: offset})
+		if err != nil {
+			return "", err
+		}
+		defer stream.Close()
+		var got []byte
+		for stream.Receive() {
+			got = append(got, stream.Msg().Chunk...)
+		}
+		return string(got), stream.Err()
+	}
+	for _, offset := range []int64{1, downloadChunkSize + 3, int64(len(data))} {
+		if got, err := read(offset); err != nil || got != data[offset:] {
+			t.Errorf("download from %d: %d bytes, %v, want %d", offset, len(got), err, len(data)-int(offset))
+		}
+	}
+	for _, offset := range []int64{-1, int64(len(data)) + 1} {
+		if _, err := read(offset); connect.CodeOf(err) != connect.CodeOutOfRange {
+			t.Errorf("download from %d: %v, want out of range", offset, err)
+		}
+	}
+}
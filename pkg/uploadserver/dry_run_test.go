@@ -0,0 +1,101 @@
+package uploadserver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// dryRunUpload streams data as name with dry_run set and commits hash
+func (ts *testServer) dryRunUpload(t *testing.T, name, data, hash string) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*fileuploadv1.UploadRequest{
+		{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: name, DryRun: true}}},
+		{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte(data)}},
+		{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: hash}},
+	} {
+		if err := stream.Send(req); err != nil {
+			break
+		}
+	}
+	return stream.CloseAndReceive()
+}
+
+// assertNotStored fails unless name is absent from the upload directory
+func (ts *testServer) assertNotStored(t *testing.T, name string) {
+	t.Helper()
+	if _, err := os.Stat(filepath.Join(ts.dir, name)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("%s was stored: %v", name, err)
+	}
+}
+
+func TestDryRunStoresNothing(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+
+	resp, err := ts.dryRunUpload(t, "stream.txt", "streamed", sha256Hex("streamed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.HashOk || resp.Sha256 != sha256Hex("streamed") || resp.Size != int64(len("streamed")) {
+		t.Fatalf("dry run response %+v", resp)
+	}
+	ts.assertNotStored(t, "stream.txt")
+
+	resp, err = ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename: "unary.txt",
+		Data:     []byte("unary"),
+		Sha256:   sha256Hex("something else"),
+		DryRun:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.HashOk || resp.Sha256 != sha256Hex("unary") {
+		t.Fatalf("dry run response %+v, want the server hash and hash_ok false", resp)
+	}
+	ts.assertNotStored(t, "unary.txt")
+
+	entries, err := os.ReadDir(ts.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("dry runs left %d entries in the upload directory", len(entries))
+	}
+}
+
+func TestDryRunKeepsStoredFile(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.uploadFile(t, "a.txt", "v1")
+	if _, err := ts.dryRunUpload(t, "a.txt", "v2", sha256Hex("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.stored(t, "a.txt"); got != "v1" {
+		t.Fatalf("a.txt = %q after a dry run, want v1", got)
+	}
+}
+
+func TestDryRunRunsChecks(t *testing.T) {
+	ts := newDedupServer(t)
+	if _, err := ts.dryRunUpload(t, "c.txt", "X", sha256Hex("X")); connect.CodeOf(err) != connect.CodeAlreadyExists {
+		t.Fatalf("dry run of stored content: %v, want already_exists", err)
+	}
+	if _, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename: "c.txt", Data: []byte("X"), DryRun: true,
+	}); connect.CodeOf(err) != connect.CodeAlreadyExists {
+		t.Fatalf("unary dry run of stored content: %v, want already_exists", err)
+	}
+	if _, err := ts.dryRunUpload(t, "c.txt", "corrupt", sha256Hex("other")); connect.CodeOf(err) != connect.CodeDataLoss {
+		t.Fatalf("dry run with the wrong hash: %v, want data_loss", err)
+	}
+	ts.assertNotStored(t, "c.txt")
+}
@@ -0,0 +1,139 @@
+package uploadserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// uploadEvent is one line of the JSONL event log
+type uploadEvent struct {
+	Time     time.Time `json:"time"`
+	RPC      string    `json:"rpc"`
+	Filename string    `json:"filename"`
+	// StoredFilename is set when the file is stored under another name
+	StoredFilename string `json:"stored_filename,omitempty"`
+	Size           int64  `json:"size"`
+	HashOk         bool   `json:"hash_ok"`
+	Code           string `json:"code"`
+	Peer           string `json:"peer"`
+	Identity       string `json:"identity,omitempty"`
+}
+
+// eventLog appends one JSON line per completed or failed upload and rotates
+// the file to path+".1" once it grows past maxBytes
+type eventLog struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	w        *bufio.Writer
+	size     int64
+}
+
+func openEventLog(path string, maxBytes int64) (*eventLog, error) {
+	l := &eventLog{path: path, maxBytes: maxBytes}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *eventLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.w, l.size = f, bufio.NewWriter(f), info.Size()
+	return nil
+}
+
+// rotate keeps a single previous generation of the log
+func (l *eventLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// record logs the outcome of an upload of filename, stored under stored,
+// attributed to the caller stored in ctx by withCaller; a nil eventLog records nothing
+func (l *eventLog) record(ctx context.Context, rpc, filename, stored string, size int64, hashOk bool, err error) {
+	if l == nil {
+		return
+	}
+	c := callerFrom(ctx)
+	code := "ok"
+	if err != nil {
+		code = connect.CodeOf(err).String()
+	}
+	if stored == filename {
+		stored = ""
+	}
+	line, jsonErr := json.Marshal(uploadEvent{
+		Time:           time.Now().UTC(),
+		RPC:            rpc,
+		Filename:       filename,
+		StoredFilename: stored,
+		Size:           size,
+		HashOk:         hashOk,
+		Code:           code,
+		Peer:           c.addr,
+		Identity:       c.identity,
+	})
+	if jsonErr != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxBytes > 0 && l.size+int64(len(line)) > l.maxBytes && l.size > 0 {
+		if rotErr := l.rotate(); rotErr != nil {
+			log.Printf("Event log rotation failed: %v", rotErr)
+			return
+		}
+	}
+	// flush every line so a crash never leaves buffered events behind
+	n, _ := l.w.Write(line)
+	if flushErr := l.w.Flush(); flushErr != nil {
+		log.Printf("Event log write failed: %v", flushErr)
+	}
+	l.size += int64(n)
+}
+
+func (l *eventLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Flush(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// recordUpload logs the outcome of an upload of filename, stored under
+// stored, to the event log and to the trace span of its request, and
+// publishes it to the WatchEvents subscribers
+func (s *Server) recordUpload(ctx context.Context, rpc, filename, stored string, size int64, hashOk bool, err error) {
+	s.events.record(ctx, rpc, filename, stored, size, hashOk, err)
+	traceUpload(ctx, filename, size, hashOk, err)
+	s.broadcastUpload(ctx, rpc, filename, stored, size, err)
+}
@@ -0,0 +1,95 @@
+package uploadserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readEvents returns the events logged in the file at path
+func readEvents(t *testing.T, path string) []uploadEvent {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []uploadEvent
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e uploadEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %q of %s: %v", sc.Text(), path, err)
+		}
+		events = append(events, e)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestEventLogRecordsUploads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, err := openEventLog(path, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	ts := newTestServer(t, &Server{events: l})
+	ts.uploadFile(t, "a.txt", "logged")
+	if _, err := declaredUpload(t.Context(), ts.client, "b.txt", sha256Hex("something else"), []byte("corrupt")); err == nil {
+		t.Fatal("an upload with the wrong hash succeeded")
+	}
+
+	// every line is flushed when the upload ends
+	events := readEvents(t, path)
+	if len(events) != 2 {
+		t.Fatalf("%d events logged, want 2: %+v", len(events), events)
+	}
+	ok, failed := events[0], events[1]
+	if ok.RPC != "UploadFile" || ok.Filename != "a.txt" || ok.Size != int64(len("logged")) || !ok.HashOk || ok.Code != "ok" || ok.Peer == "" || ok.Time.IsZero() {
+		t.Errorf("event of the upload: %+v", ok)
+	}
+	if failed.RPC != "Upload" || failed.Filename != "b.txt" || failed.HashOk || failed.Code != "data_loss" {
+		t.Errorf("event of the failed upload: %+v", failed)
+	}
+}
+
+func TestEventLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, err := openEventLog(path, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		l.record(t.Context(), "Upload", "a.txt", "a.txt", 1, true, nil)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rotated, current := readEvents(t, path+".1"), readEvents(t, path)
+	if len(rotated) == 0 || len(current) == 0 || len(rotated)+len(current) != 3 {
+		t.Fatalf("%d events rotated and %d current, want 3 in all", len(rotated), len(current))
+	}
+	if info, _ := os.Stat(path); info.Size() > 300 {
+		t.Fatalf("the log grew to %d bytes, past its limit", info.Size())
+	}
+
+	// a reopened log counts the lines already there against the limit
+	if l, err = openEventLog(path, 300); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		l.record(t.Context(), "Upload", "b.txt", "b.txt", 1, true, nil)
+	}
+	l.Close()
+	if info, _ := os.Stat(path); info.Size() > 300 {
+		t.Fatalf("the reopened log grew to %d bytes, past its limit", info.Size())
+	}
+	if rotated := readEvents(t, path+".1"); rotated[len(rotated)-1].Filename != "b.txt" {
+		t.Fatalf("rotated %+v, want the lines written after reopening", rotated)
+	}
+}
@@ -0,0 +1,246 @@
+package uploadserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// expiryFile holds, in the upload directory, the expiry of files uploaded
+// with a TTL or expiry time
+const expiryFile = ".expiry.json"
+
+// expiries maps stored files to the time the janitor deletes them. It is
+// saved to path after each change so expiring files survive restarts.
+type expiries struct {
+	mu   sync.Mutex
+	path string               // on-disk copy, empty keeps it in memory only
+	at   map[string]time.Time // filename -> expiry
+}
+
+// expiriesFile is the on-disk format of expiries
+type expiriesFile struct {
+	Files map[string]time.Time `json:"files"`
+}
+
+// openExpiries loads the expiries saved at path. Unlike the hash index they
+// cannot be rebuilt from the stored files, so a corrupt file is an error.
+func openExpiries(path string) (*expiries, error) {
+	e := &expiries{path: path, at: make(map[string]time.Time)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f expiriesFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", path, err)
+	}
+	for name, at := range f.Files {
+		e.at[name] = at
+	}
+	return e, nil
+}
+
+// get returns the expiry of filename, zero when it does not expire
+func (e *expiries) get(filename string) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.at[filename]
+}
+
+// set makes filename expire at at, or never when at is zero
+func (e *expiries) set(filename string, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if at.IsZero() {
+		if _, ok := e.at[filename]; !ok {
+			return
+		}
+		delete(e.at, filename)
+	} else {
+		e.at[filename] = at
+	}
+	e.saveOrLog()
+}
+
+// rename moves the expiry of from to to, replacing the one of to
+func (e *expiries) rename(from, to string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	at, ok := e.at[from]
+	_, replaced := e.at[to]
+	if !ok && !replaced {
+		return
+	}
+	delete(e.at, from)
+	delete(e.at, to)
+	if ok {
+		e.at[to] = at
+	}
+	e.saveOrLog()
+}
+
+// due returns the files whose expiry is not after now
+func (e *expiries) due(now time.Time) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var names []string
+	for name, at := range e.at {
+		if !at.After(now) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// saveOrLog writes the expiries to a temporary file renamed over path. Callers
+// hold e.mu.
+func (e *expiries) saveOrLog() {
+	if e.path == "" {
+		return
+	}
+	b, err := json.Marshal(expiriesFile{Files: e.at})
+	if err == nil {
+		tmp := e.path + ".tmp"
+		if err = os.WriteFile(tmp, b, 0644); err == nil {
+			err = os.Rename(tmp, e.path)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save %s: %v", filepath.Base(e.path), err)
+	}
+}
+
+// expiryTime returns when a file stored at now with ttlSeconds or
+// expiresUnix expires, zero when neither is set
+func expiryTime(ttlSeconds, expiresUnix int64, now time.Time) (time.Time, error) {
+	switch {
+	case ttlSeconds != 0 && expiresUnix != 0:
+		return time.Time{}, fieldViolation("ttl_seconds", "cannot be combined with expires_unix, set only one")
+	case ttlSeconds < 0:
+		return time.Time{}, fieldViolation("ttl_seconds", "must not be negative")
+	case ttlSeconds > math.MaxInt64/int64(time.Second):
+		return time.Time{}, fieldViolation("ttl_seconds", "is too large")
+	case ttlSeconds > 0:
+		return now.Add(time.Duration(ttlSeconds) * time.Second), nil
+	case expiresUnix < 0:
+		return time.Time{}, fieldViolation("expires_unix", "must not be negative")
+	case expiresUnix > 0:
+		at := time.Unix(expiresUnix, 0)
+		if !at.After(now) {
+			return time.Time{}, fieldViolation("expires_unix", "is in the past")
+		}
+		return at, nil
+	}
+	return time.Time{}, nil
+}
+
+// unixOrZero returns t in seconds since the Unix epoch, 0 for the zero time
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// sweepExpired deletes the stored files whose expiry has passed, with their
+// hash index entry and thumbnail
+func (s *Server) sweepExpired() {
+	// read-only mode keeps the upload directory unchanged, expired files are
+	// removed once it ends
+	if s.readOnly != nil && s.readOnly() {
+		return
+	}
+	s.sweepRetention()
+	for _, name := range s.expiries.due(time.Now()) {
+		at := s.expiries.get(name)
+		// removed by the first sweep once its retention has ended
+		if s.checkRetention(name) != nil {
+			continue
+		}
+		if err := os.Remove(s.files.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Janitor: cannot remove expired %s: %v", s.redact.name(name), s.redact.err(err))
+			continue
+		}
+		s.index.remove(name)
+		s.files.keys.set(name, "")
+		os.Remove(s.thumbnailPath(name))
+		s.expiries.set(name, time.Time{})
+		s.recordDelete(baseName(name))
+		log.Printf("Janitor: removed expired file %s (expired %s)", s.redact.name(name), at.Format(time.RFC3339))
+	}
+}
+
+// GetExpiry reports when a stored file uploaded with a TTL or expiry time is
+// deleted
+func (s *Server) GetExpiry(
+	ctx context.Context, req *fileuploadv1.GetExpiryRequest) (*fileuploadv1.ExpiryResponse, error) {
+
+	filename := s.lookupName(ctx, sanitizeFilename(req.Filename))
+	if err := s.checkStored(filename); err != nil {
+		return nil, err
+	}
+	return &fileuploadv1.ExpiryResponse{
+		Filename:    baseName(filename),
+		ExpiresUnix: unixOrZero(s.expiries.get(filename)),
+	}, nil
+}
+
+// ExtendExpiry postpones the deletion of an expiring stored file. A file
+// without an expiry cannot be given one, it was not uploaded to be shared
+// for a limited time.
+func (s *Server) ExtendExpiry(
+	ctx context.Context, req *fileuploadv1.ExtendExpiryRequest) (*fileuploadv1.ExpiryResponse, error) {
+
+	filename := s.lookupName(ctx, sanitizeFilename(req.Filename))
+	if err := s.checkWritesAllowed(); err != nil {
+		return nil, err
+	}
+	if req.TtlSeconds == 0 && req.ExpiresUnix == 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("set ttl_seconds or expires_unix"))
+	}
+	at, err := expiryTime(req.TtlSeconds, req.ExpiresUnix, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkStored(filename); err != nil {
+		return nil, err
+	}
+	current := s.expiries.get(filename)
+	if current.IsZero() {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("%s does not expire", baseName(filename)))
+	}
+	if at.Before(current) {
+		return nil, connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("new expiry %s is before the current one %s", at.Format(time.RFC3339), current.Format(time.RFC3339)))
+	}
+	s.expiries.set(filename, at)
+	log.Printf("Expiry of %s extended to %s", s.redact.name(filename), at.Format(time.RFC3339))
+	return &fileuploadv1.ExpiryResponse{Filename: baseName(filename), ExpiresUnix: at.Unix()}, nil
+}
+
+// checkStored returns CodeNotFound unless filename is a stored regular file
+func (s *Server) checkStored(filename string) error {
+	info, err := os.Stat(s.files.path(filename))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("file not found: %s", baseName(filename)))
+	}
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	return nil
+}
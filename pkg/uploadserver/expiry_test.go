@@ -0,0 +1,202 @@
+package uploadserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func (ts *testServer) uploadExpiring(t *testing.T, name, data string, ttl, expiresUnix int64) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	return ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename:    name,
+		Data:        []byte(data),
+		Sha256:      sha256Hex(data),
+		TtlSeconds:  ttl,
+		ExpiresUnix: expiresUnix,
+	})
+}
+
+// expire moves the expiry of name into the past, as if its TTL had run out
+func (ts *testServer) expire(name string) {
+	ts.srv.expiries.set(name, time.Now().Add(-time.Second))
+}
+
+func TestUploadExpiry(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	before := time.Now()
+	resp, err := ts.uploadExpiring(t, "temp.txt", "temporary", 3600, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := before.Add(time.Hour).Unix()
+	if resp.ExpiresUnix < want || resp.ExpiresUnix > want+5 {
+		t.Fatalf("expires_unix %d, want about %d", resp.ExpiresUnix, want)
+	}
+	got, err := ts.client.GetExpiry(t.Context(), &fileuploadv1.GetExpiryRequest{Filename: "temp.txt"})
+	if err != nil || got.ExpiresUnix != resp.ExpiresUnix {
+		t.Fatalf("GetExpiry %v, %v, want %d", got, err, resp.ExpiresUnix)
+	}
+	meta, err := ts.client.GetFileMetadata(t.Context(), &fileuploadv1.GetFileMetadataRequest{Filename: "temp.txt"})
+	if err != nil || meta.ExpiresUnix != resp.ExpiresUnix {
+		t.Fatalf("metadata %v, %v, want expires_unix %d", meta, err, resp.ExpiresUnix)
+	}
+
+	// an absolute expiry is kept as sent, by the stream too
+	at := time.Now().Add(24 * time.Hour).Unix()
+	resp, err = ts.sendUpload(t,
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+			Metadata: &fileuploadv1.UploadMetadata{Filename: "streamed.txt", ExpiresUnix: at},
+		}},
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("streamed")}},
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("streamed")}},
+	)
+	if err != nil || resp.ExpiresUnix != at {
+		t.Fatalf("streamed upload %v, %v, want expires_unix %d", resp, err, at)
+	}
+
+	// a file uploaded without one never expires
+	if resp := ts.uploadFile(t, "kept.txt", "kept"); resp.ExpiresUnix != 0 {
+		t.Fatalf("expires_unix %d without a TTL", resp.ExpiresUnix)
+	}
+}
+
+func TestJanitorRemovesExpiredFiles(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	if _, err := ts.uploadExpiring(t, "temp.txt", "temporary", 3600, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.uploadExpiring(t, "later.txt", "later", 3600, 0); err != nil {
+		t.Fatal(err)
+	}
+	ts.uploadFile(t, "kept.txt", "kept")
+
+	ts.expire("temp.txt")
+	ts.srv.sweepExpired()
+	if _, err := os.Stat(filepath.Join(ts.dir, "temp.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expired file kept: %v", err)
+	}
+	if _, ok := ts.srv.index.hashOf("temp.txt"); ok {
+		t.Error("expired file left in the hash index")
+	}
+	if _, err := ts.client.GetExpiry(t.Context(), &fileuploadv1.GetExpiryRequest{Filename: "temp.txt"}); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Errorf("GetExpiry of a removed file: %v, want not found", err)
+	}
+	for _, name := range []string{"later.txt", "kept.txt"} {
+		if _, err := os.Stat(filepath.Join(ts.dir, name)); err != nil {
+			t.Errorf("%s removed before its expiry: %v", name, err)
+		}
+	}
+}
+
+func TestExtendExpiry(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	resp, err := ts.uploadExpiring(t, "temp.txt", "temporary", 60, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extend := func(name string, ttl, at int64) (*fileuploadv1.ExpiryResponse, error) {
+		return ts.client.ExtendExpiry(t.Context(), &fileuploadv1.ExtendExpiryRequest{Filename: name, TtlSeconds: ttl, ExpiresUnix: at})
+	}
+	extended, err := extend("temp.txt", 7200, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extended.ExpiresUnix < resp.ExpiresUnix+7000 {
+		t.Fatalf("extended to %d, from %d", extended.ExpiresUnix, resp.ExpiresUnix)
+	}
+	// the extended expiry is what the janitor goes by
+	ts.srv.sweepExpired()
+	if got := ts.srv.expiries.get("temp.txt").Unix(); got != extended.ExpiresUnix {
+		t.Fatalf("expiry %d, want %d", got, extended.ExpiresUnix)
+	}
+
+	ts.uploadFile(t, "kept.txt", "kept")
+	for _, tc := range []struct {
+		name    string
+		ttl, at int64
+		want    connect.Code
+	}{
+		{"temp.txt", 60, 0, connect.CodeInvalidArgument},    // brings it forward
+		{"temp.txt", 0, 0, connect.CodeInvalidArgument},     // sets nothing
+		{"temp.txt", 60, 1, connect.CodeInvalidArgument},    // sets both
+		{"kept.txt", 60, 0, connect.CodeFailedPrecondition}, // does not expire
+		{"missing.txt", 60, 0, connect.CodeNotFound},
+	} {
+		if _, err := extend(tc.name, tc.ttl, tc.at); connect.CodeOf(err) != tc.want {
+			t.Errorf("extend %s by %d to %d: %v, want %v", tc.name, tc.ttl, tc.at, err, tc.want)
+		}
+	}
+}
+
+func TestUploadExpiryValidation(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	for _, tc := range []struct {
+		desc    string
+		ttl, at int64
+	}{
+		{"both set", 60, time.Now().Add(time.Hour).Unix()},
+		{"negative ttl", -1, 0},
+		{"negative expiry", 0, -1},
+		{"expiry in the past", 0, time.Now().Add(-time.Hour).Unix()},
+	} {
+		if _, err := ts.uploadExpiring(t, "bad.txt", "bad", tc.ttl, tc.at); connect.CodeOf(err) != connect.CodeInvalidArgument {
+			t.Errorf("%s: %v, want invalid argument", tc.desc, err)
+		}
+	}
+	ts.assertNotStored(t, "bad.txt")
+}
+
+func TestExpiryFollowsTheFile(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if _, err := ts.uploadExpiring(t, "a.txt", "a", 3600, 0); err != nil {
+		t.Fatal(err)
+	}
+	// a rename takes the expiry along
+	if _, err := ts.rename(t, "a.txt", "b.txt", false); err != nil {
+		t.Fatal(err)
+	}
+	if !ts.srv.expiries.get("a.txt").IsZero() || ts.srv.expiries.get("b.txt").IsZero() {
+		t.Fatal("the expiry did not follow the renamed file")
+	}
+	// new content stored without one does not inherit it
+	ts.uploadFile(t, "b.txt", "replaced")
+	if !ts.srv.expiries.get("b.txt").IsZero() {
+		t.Fatal("a replacing upload inherited the expiry")
+	}
+	ts.expire("c.txt")
+	if _, err := ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "b.txt", To: "c.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if !ts.srv.expiries.get("c.txt").IsZero() {
+		t.Fatal("a copy inherited the expiry of the name it was stored under")
+	}
+}
+
+func TestExpiriesSurviveRestart(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	resp, err := ts.uploadExpiring(t, "temp.txt", "temporary", 3600, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := openExpiries(filepath.Join(ts.dir, expiryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.get("temp.txt").Unix(); got != resp.ExpiresUnix {
+		t.Fatalf("loaded expiry %d, want %d", got, resp.ExpiresUnix)
+	}
+
+	// a corrupt file fails to load rather than keeping files forever
+	if err := os.WriteFile(filepath.Join(ts.dir, expiryFile), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openExpiries(filepath.Join(ts.dir, expiryFile)); err == nil {
+		t.Fatal("corrupt expiries loaded")
+	}
+}
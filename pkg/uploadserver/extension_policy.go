@@ -0,0 +1,88 @@
+package uploadserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// defaultPolicyNamespace keys the ExtensionPolicies entry applying to every
+// namespace without its own, and to callers without a namespace
+const defaultPolicyNamespace = "*"
+
+// ExtensionPolicy restricts the file types a namespace may store, by the
+// extension of the filename such as ".pdf" or ".tar.gz", matched without
+// regard to case
+type ExtensionPolicy struct {
+	// Allow lists the only extensions accepted, empty accepts every one not
+	// denied. Files without an extension are refused by an allow list.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists extensions refused even when allowed
+	Deny []string `json:"deny,omitempty"`
+}
+
+// newExtensionPolicies validates and normalizes the policies of
+// Config.ExtensionPolicies
+func newExtensionPolicies(policies map[string]ExtensionPolicy) (map[string]ExtensionPolicy, error) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]ExtensionPolicy, len(policies))
+	for namespace, policy := range policies {
+		var err error
+		if policy.Allow, err = normalizeExtensions(policy.Allow); err != nil {
+			return nil, fmt.Errorf("extension policy of %q: %w", namespace, err)
+		}
+		if policy.Deny, err = normalizeExtensions(policy.Deny); err != nil {
+			return nil, fmt.Errorf("extension policy of %q: %w", namespace, err)
+		}
+		normalized[namespace] = policy
+	}
+	return normalized, nil
+}
+
+func normalizeExtensions(exts []string) ([]string, error) {
+	var normalized []string
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if len(ext) < 2 || !strings.HasPrefix(ext, ".") || strings.ContainsAny(ext, `/\`) {
+			return nil, fmt.Errorf("%q is not an extension such as .pdf", ext)
+		}
+		normalized = append(normalized, ext)
+	}
+	return normalized, nil
+}
+
+// permits reports whether the policy accepts filename
+func (p ExtensionPolicy) permits(filename string) bool {
+	name := strings.ToLower(filename)
+	for _, ext := range p.Deny {
+		if strings.HasSuffix(name, ext) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, ext := range p.Allow {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExtension refuses filename with CodeInvalidArgument when the
+// extension policy resolved for the caller's namespace does not accept it
+func (s *Server) checkExtension(ctx context.Context, filename string) error {
+	cfg := s.requestConfig(ctx)
+	if cfg.extensions == nil || cfg.extensions.permits(filename) {
+		return nil
+	}
+	if cfg.namespace == "" {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("file type of %s is not accepted", filename))
+	}
+	return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("file type of %s is not accepted for namespace %s", filename, cfg.namespace))
+}
@@ -0,0 +1,160 @@
+package uploadserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// inNamespace returns ctx with a caller whose certificate belongs to namespace
+func inNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller{identity: namespace + "-client", namespace: namespace})
+}
+
+func TestExtensionPolicyPermits(t *testing.T) {
+	policy := ExtensionPolicy{Allow: []string{".pdf", ".tar.gz"}, Deny: []string{".secret.pdf"}}
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"report.pdf", true},
+		{"REPORT.PDF", true},
+		{"backup.tar.gz", true},
+		{"archive.gz", false},
+		{"notes.txt", false},
+		{"README", false},
+		{"keys.secret.pdf", false},
+	}
+	for _, tt := range tests {
+		if got := policy.permits(tt.name); got != tt.ok {
+			t.Errorf("permits(%q) = %v, want %v", tt.name, got, tt.ok)
+		}
+	}
+	if !(ExtensionPolicy{Deny: []string{".exe"}}).permits("README") {
+		t.Error("a deny list alone refused a file without extension")
+	}
+}
+
+func TestNewExtensionPoliciesRejectsBadExtensions(t *testing.T) {
+	for _, ext := range []string{"pdf", ".", "", "../x"} {
+		if _, err := newExtensionPolicies(map[string]ExtensionPolicy{"acme": {Allow: []string{ext}}}); err == nil {
+			t.Errorf("extension %q accepted", ext)
+		}
+	}
+	policies, err := newExtensionPolicies(map[string]ExtensionPolicy{"acme": {Deny: []string{" .EXE "}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := policies["acme"].Deny; len(got) != 1 || got[0] != ".exe" {
+		t.Fatalf("deny list %q, want it normalized to .exe", got)
+	}
+}
+
+func TestCallerNamespace(t *testing.T) {
+	var got caller
+	handler := withCaller(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = callerFrom(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+		Subject: pkix.Name{CommonName: "uploader", Organization: []string{"acme", "other"}},
+	}}}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got.identity != "uploader" || got.namespace != "acme" {
+		t.Fatalf("caller %+v, want identity uploader in namespace acme", got)
+	}
+}
+
+func TestExtensionPoliciesPerNamespace(t *testing.T) {
+	policies, err := newExtensionPolicies(map[string]ExtensionPolicy{
+		"acme":   {Allow: []string{".pdf"}},
+		"globex": {Deny: []string{".pdf"}},
+		"*":      {Deny: []string{".exe"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, &Server{extensions: policies})
+
+	tests := []struct {
+		namespace string
+		name      string
+		ok        bool
+	}{
+		{"acme", "a.pdf", true},
+		{"acme", "a.txt", false},
+		{"globex", "g.pdf", false},
+		{"globex", "g.txt", true},
+		// namespaces without a policy, and callers without one, get the default
+		{"initech", "i.pdf", true},
+		{"initech", "i.exe", false},
+		{"", "anonymous.exe", false},
+	}
+	for _, tt := range tests {
+		_, err := ts.srv.UploadFile(inNamespace(t.Context(), tt.namespace), &fileuploadv1.UploadFileRequest{
+			Filename: tt.name, Data: []byte("content"),
+		})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s uploading %s: error %v, want ok %v", tt.namespace, tt.name, err, tt.ok)
+		}
+		if err != nil && (connect.CodeOf(err) != connect.CodeInvalidArgument || !strings.Contains(err.Error(), tt.namespace)) {
+			t.Errorf("%s uploading %s: %v, want invalid argument naming the namespace", tt.namespace, tt.name, err)
+		}
+		if !tt.ok {
+			ts.assertNotStored(t, tt.name)
+		}
+	}
+
+	// the streaming RPC checks the name from its metadata, except for hash only uploads
+	upload := func(name string, hashOnly bool) error {
+		_, err := ts.sendUpload(t,
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+				Metadata: &fileuploadv1.UploadMetadata{Filename: name, HashOnly: hashOnly},
+			}},
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("MZ")}},
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("MZ")}},
+		)
+		return err
+	}
+	if err := upload("setup.exe", false); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("streaming a denied file: %v, want invalid argument", err)
+	}
+	if err := upload("setup.exe", true); err != nil {
+		t.Fatalf("hashing a denied file: %v", err)
+	}
+}
+
+func TestExtensionPolicyOverHTTP(t *testing.T) {
+	ts := newTestServer(t, &Server{extensions: map[string]ExtensionPolicy{"*": {Deny: []string{".exe"}}}})
+	if resp, body := ts.putRange(t, "setup.exe", "", "MZ"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("PUT of a denied file: status %d, body %s", resp.StatusCode, body)
+	}
+	if resp, body := ts.putRange(t, "notes.txt", "", "text"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT of an accepted file: status %d, body %s", resp.StatusCode, body)
+	}
+
+	body, ct := multipartBody(t, "setup.exe", "MZ", nil)
+	req := ts.newRequest(t, http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", ct)
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("multipart upload of a denied file: status %d, body %s", resp.StatusCode, body)
+	}
+
+	req = ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", "2")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("setup.exe")))
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("tus creation of a denied file: status %d, body %s", resp.StatusCode, body)
+	}
+	ts.assertNotStored(t, "setup.exe")
+}
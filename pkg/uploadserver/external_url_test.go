@@ -0,0 +1,78 @@
+package uploadserver
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// cdnURL is a Config.ExternalURL publishing the storage directory on a CDN
+func cdnURL(name string) (string, error) {
+	return "https://cdn.example.com/" + name, nil
+}
+
+func TestExternalURL(t *testing.T) {
+	ts := newRoutedServer(t, Route{Match: ".pdf", Dir: "docs"})
+	ts.srv.externalURL = cdnURL
+	ts.uploadFile(t, "report.pdf", "published")
+	ts.uploadFile(t, "notes.txt", "published too")
+
+	// the redirect is not followed, the CDN does not exist
+	client := ts.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(ts.URL + "/files/report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "https://cdn.example.com/docs/report.pdf" {
+		t.Fatalf("GET: status %d, Location %q; want a redirect to the routed path", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	stream, err := ts.client.Download(t.Context(), &fileuploadv1.DownloadRequest{Filename: "notes.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []*fileuploadv1.DownloadResponse
+	for stream.Receive() {
+		msgs = append(msgs, stream.Msg())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Location != "https://cdn.example.com/notes.txt" || len(msgs[0].Chunk) != 0 {
+		t.Fatalf("Download answered %v, want a single location message", msgs)
+	}
+
+	meta, err := ts.client.GetFileMetadata(t.Context(), &fileuploadv1.GetFileMetadataRequest{Filename: "report.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.DownloadUrl != "https://cdn.example.com/docs/report.pdf" || meta.Sha256 != sha256Hex("published") {
+		t.Fatalf("metadata %+v, want the download URL", meta)
+	}
+
+	// a missing file is still not found rather than handed off
+	if resp, _ := ts.do(t, ts.newRequest(t, http.MethodGet, "/files/missing.txt", nil)); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET of a missing file: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestExternalURLFails(t *testing.T) {
+	ts := newTestServer(t, &Server{externalURL: func(string) (string, error) {
+		return "", errors.New("signing key unavailable")
+	}})
+	ts.uploadFile(t, "a.txt", "data")
+
+	if resp, _ := ts.do(t, ts.newRequest(t, http.MethodGet, "/files/a.txt", nil)); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("GET while locating fails: status %d, want 503", resp.StatusCode)
+	}
+	_, err := ts.client.GetFileMetadata(t.Context(), &fileuploadv1.GetFileMetadataRequest{Filename: "a.txt"})
+	if connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Fatalf("metadata while locating fails: %v, want unavailable", err)
+	}
+}
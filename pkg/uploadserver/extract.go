@@ -0,0 +1,300 @@
+package uploadserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+var (
+	// errExtractLimit refuses an archive over Config.ExtractMaxBytes or
+	// Config.ExtractMaxEntries, reported as CodeResourceExhausted
+	errExtractLimit = errors.New("archive too large to extract")
+	// errBadArchive refuses a corrupt archive or an entry that cannot be
+	// unpacked safely, reported as CodeInvalidArgument
+	errBadArchive = errors.New("invalid archive")
+)
+
+// archiveExtensions maps the archive extensions that can be extracted to
+// their kind, longest first
+var archiveExtensions = []struct{ ext, kind string }{
+	{".tar.gz", "tgz"}, {".tgz", "tgz"}, {".tar", "tar"}, {".zip", "zip"},
+}
+
+// archiveKind returns how an archive named filename is read and the name
+// without its extension, or "" when filename is no archive
+func archiveKind(filename string) (kind, stem string) {
+	lower := strings.ToLower(filename)
+	for _, a := range archiveExtensions {
+		if strings.HasSuffix(lower, a.ext) && len(filename) > len(a.ext) {
+			return a.kind, filename[:len(filename)-len(a.ext)]
+		}
+	}
+	return "", ""
+}
+
+// extractor unpacks stored archives into directories of dir, one per
+// archive inside a directory per callerScope, bounding what a single archive
+// may unpack to. A nil extractor refuses every extraction.
+type extractor struct {
+	dir        string
+	maxBytes   int64
+	maxEntries int
+}
+
+func newExtractor(dir string, maxBytes int64, maxEntries int) (*extractor, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create extraction directory: %w", err)
+	}
+	return &extractor{dir: dir, maxBytes: maxBytes, maxEntries: maxEntries}, nil
+}
+
+// checkExtract refuses an extract request up front when extraction is
+// disabled or filename is no archive, before anything is stored
+func (s *Server) checkExtract(filename string, extract bool) error {
+	if !extract {
+		return nil
+	}
+	if s.extract == nil {
+		return connect.NewError(connect.CodeFailedPrecondition, errors.New("archive extraction is disabled on this server"))
+	}
+	if kind, _ := archiveKind(filename); kind == "" {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("cannot extract %s: not a .zip, .tar, .tar.gz or .tgz file", filename))
+	}
+	return nil
+}
+
+// extractStored unpacks the archive uploaded as filename and stored as stored
+// into the extraction directory and returns the directory it went to,
+// relative to it. Like partial uploads, the archives of each caller go to
+// their callerScope, so two clients uploading a bundle of the same name never
+// replace each other's extraction. The archive stays stored when that fails.
+func (s *Server) extractStored(ctx context.Context, filename, stored string) (string, error) {
+	kind, stem := archiveKind(filename)
+	if base := baseName(stored); base != filename {
+		// a random name only keeps the last extension
+		stem = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	rel := scopedName(ctx, stem)
+	entries, err := s.extract.extract(s.files.path(stored), kind, rel)
+	if err != nil {
+		log.Printf("Extracting %s failed: %v", s.redact.name(stored), s.redact.err(err))
+		code := connect.CodeInternal
+		switch {
+		case errors.Is(err, errBadArchive):
+			code = connect.CodeInvalidArgument
+		case errors.Is(err, errExtractLimit):
+			code = connect.CodeResourceExhausted
+		}
+		return "", connect.NewError(code, fmt.Errorf("file stored but not extracted: %w", err))
+	}
+	log.Printf("Extracted: %s to %s (%d entries)", s.redact.name(stored), s.redact.name(rel), entries)
+	return rel, nil
+}
+
+// extract unpacks the archive at path into a fresh directory and swaps it in
+// for dir/rel, a "/" separated path, so a failed or partial extraction never
+// replaces the previous one. It returns the number of entries.
+func (x *extractor) extract(path, kind, rel string) (int, error) {
+	target := filepath.Join(x.dir, filepath.FromSlash(rel))
+	parent := filepath.Dir(target)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.MkdirTemp(parent, "."+filepath.Base(target)+".tmp-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmp)
+
+	u := &unpacker{x: x, root: tmp}
+	switch kind {
+	case "zip":
+		err = u.zip(path)
+	default:
+		err = u.tar(path, kind == "tgz")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	old := ""
+	if _, err := os.Lstat(target); err == nil {
+		old = tmp + ".old"
+		if err := os.Rename(target, old); err != nil {
+			return 0, err
+		}
+		defer os.RemoveAll(old)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		if old != "" {
+			os.Rename(old, target)
+		}
+		return 0, err
+	}
+	return u.entries, nil
+}
+
+// unpacker writes archive entries below root, counting them against the
+// extractor's limits
+type unpacker struct {
+	x       *extractor
+	root    string
+	entries int
+	bytes   int64
+}
+
+// entryPath resolves an entry name below root, refusing absolute names and
+// any that climb out of it with .. (zip-slip)
+func (u *unpacker) entryPath(name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(name, "./")))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: entry %q escapes the extraction directory", errBadArchive, name)
+	}
+	return filepath.Join(u.root, rel), nil
+}
+
+func (u *unpacker) count() error {
+	if u.entries++; u.x.maxEntries > 0 && u.entries > u.x.maxEntries {
+		return fmt.Errorf("%w: more than %d entries", errExtractLimit, u.x.maxEntries)
+	}
+	return nil
+}
+
+func (u *unpacker) dir(name string) error {
+	if err := u.count(); err != nil {
+		return err
+	}
+	path, err := u.entryPath(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0755)
+}
+
+// file writes one regular entry, trusting the bytes actually read rather
+// than the size the archive declares
+func (u *unpacker) file(name string, r io.Reader) error {
+	if err := u.count(); err != nil {
+		return err
+	}
+	path, err := u.entryPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if u.x.maxBytes > 0 {
+		r = io.LimitReader(r, u.x.maxBytes-u.bytes+1)
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if u.bytes += n; u.x.maxBytes > 0 && u.bytes > u.x.maxBytes {
+		return fmt.Errorf("%w: more than %d bytes", errExtractLimit, u.x.maxBytes)
+	}
+	return nil
+}
+
+func unsupportedEntry(name string) error {
+	return fmt.Errorf("%w: entry %q is not a regular file or directory", errBadArchive, name)
+}
+
+func corruptArchive(err error) error {
+	return fmt.Errorf("%w: %w", errBadArchive, err)
+}
+
+func (u *unpacker) zip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return corruptArchive(err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			err = u.dir(f.Name)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			if rc, err = f.Open(); err != nil {
+				return corruptArchive(err)
+			}
+			err = u.file(f.Name, rc)
+			rc.Close()
+		default:
+			err = unsupportedEntry(f.Name)
+		}
+		if err != nil {
+			if errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) {
+				return corruptArchive(err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unpacker) tar(path string, gzipped bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return corruptArchive(err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return corruptArchive(err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = u.dir(hdr.Name)
+		case tar.TypeReg:
+			err = u.file(hdr.Name, tr)
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			err = unsupportedEntry(hdr.Name)
+		}
+		if err != nil {
+			if errors.Is(err, tar.ErrHeader) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, gzip.ErrChecksum) {
+				return corruptArchive(err)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package uploadserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// tarEntry is one entry of a test archive: a directory when name ends with
+// "/", a symlink to link when set, a regular file of data otherwise
+type tarEntry struct {
+	name, data, link string
+}
+
+func makeTar(t *testing.T, gzipped bool, entries ...tarEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.data))}
+		switch {
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		case strings.HasSuffix(e.name, "/"):
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.String()
+}
+
+func makeZip(t *testing.T, entries ...tarEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		content := e.data
+		switch {
+		case e.link != "":
+			hdr.SetMode(fs.ModeSymlink | 0777)
+			content = e.link
+		case strings.HasSuffix(e.name, "/"):
+			hdr.SetMode(fs.ModeDir | 0755)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// newExtractServer starts a test server extracting into its own directory,
+// returned with it
+func newExtractServer(t *testing.T, maxBytes int64, maxEntries int) (*testServer, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "extracted")
+	x, err := newExtractor(dir, maxBytes, maxEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestServer(t, &Server{extract: x}), dir
+}
+
+func (ts *testServer) uploadExtract(t *testing.T, name, data string) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	return ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename: name,
+		Data:     []byte(data),
+		Extract:  true,
+	})
+}
+
+// extracted returns the files below dir as path=content, directories as path/
+func extracted(t *testing.T, dir string) []string {
+	t.Helper()
+	var got []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			got = append(got, rel+"/")
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		got = append(got, rel+"="+string(data))
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestExtractArchives(t *testing.T) {
+	ts, dir := newExtractServer(t, 0, 0)
+	entries := []tarEntry{{name: "site/"}, {name: "site/index.html", data: "<h1>hi</h1>"}, {name: "./notes.txt", data: "notes"}}
+	for name, data := range map[string]string{
+		"bundle.zip":    makeZip(t, entries...),
+		"bundle.tar":    makeTar(t, false, entries...),
+		"bundle.tar.gz": makeTar(t, true, entries...),
+		"bundle.tgz":    makeTar(t, true, entries...),
+	} {
+		resp, err := ts.uploadExtract(t, name, data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp.ExtractedDir != defaultScope+"/bundle" {
+			t.Errorf("%s: extracted to %q, want %s/bundle", name, resp.ExtractedDir, defaultScope)
+		}
+		if ts.stored(t, name) != data {
+			t.Errorf("%s: the archive itself was not stored", name)
+		}
+		got := strings.Join(extracted(t, filepath.Join(dir, defaultScope, "bundle")), " ")
+		if want := "notes.txt=notes site/ site/index.html=<h1>hi</h1>"; got != want {
+			t.Errorf("%s: extracted %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExtractIsPerCaller(t *testing.T) {
+	ts, dir := newExtractServer(t, 0, 0)
+	ts.uploadFile(t, "site.tar", makeTar(t, false, tarEntry{name: "a.txt", data: "alice"}))
+	if _, err := ts.srv.extractStored(asCaller(t.Context(), "alice"), "site.tar", "site.tar"); err != nil {
+		t.Fatal(err)
+	}
+	ts.uploadFile(t, "site.tar", makeTar(t, false, tarEntry{name: "b.txt", data: "bob"}))
+	rel, err := ts.srv.extractStored(asCaller(t.Context(), "bob"), "site.tar", "site.tar")
+	if err != nil || rel != "bob/site" {
+		t.Fatalf("extracted to %q, %v, want bob/site", rel, err)
+	}
+	// bob's bundle of the same name leaves alice's extraction alone
+	if got := extracted(t, filepath.Join(dir, "alice", "site")); len(got) != 1 || got[0] != "a.txt=alice" {
+		t.Fatalf("extraction of alice %q", got)
+	}
+	if got := extracted(t, filepath.Join(dir, "bob", "site")); len(got) != 1 || got[0] != "b.txt=bob" {
+		t.Fatalf("extraction of bob %q", got)
+	}
+}
+
+func TestExtractRejectsUnsafeEntries(t *testing.T) {
+	ts, dir := newExtractServer(t, 0, 0)
+	for _, tc := range []struct {
+		desc string
+		name string
+		data string
+	}{
+		{"tar with ../", "slip.tar", makeTar(t, false, tarEntry{name: "ok.txt", data: "ok"}, tarEntry{name: "../evil.txt", data: "evil"})},
+		{"zip with ../", "slip.zip", makeZip(t, tarEntry{name: "a/../../evil.txt", data: "evil"})},
+		{"tar with an absolute name", "abs.tar", makeTar(t, false, tarEntry{name: "/tmp/evil.txt", data: "evil"})},
+		{"zip with an absolute name", "abs.zip", makeZip(t, tarEntry{name: "/tmp/evil.txt", data: "evil"})},
+		{"tar with a symlink", "link.tar", makeTar(t, false, tarEntry{name: "out", link: "../../"}, tarEntry{name: "out/evil.txt", data: "evil"})},
+		{"zip with a symlink", "link.zip", makeZip(t, tarEntry{name: "out", link: "/etc"})},
+		{"corrupt archive", "corrupt.zip", "not a zip at all"},
+	} {
+		_, err := ts.uploadExtract(t, tc.name, tc.data)
+		if connect.CodeOf(err) != connect.CodeInvalidArgument {
+			t.Errorf("%s: %v, want invalid_argument", tc.desc, err)
+		}
+		// the upload itself is kept, only the extraction fails
+		if ts.stored(t, tc.name) != tc.data {
+			t.Errorf("%s: the archive was not stored", tc.desc)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil.txt")); !os.IsNotExist(err) {
+		t.Fatalf("an entry was written outside the extraction directory: %v", err)
+	}
+	// neither an extraction nor its temporary directory is left behind
+	if got := extracted(t, dir); len(got) != 1 || got[0] != defaultScope+"/" {
+		t.Fatalf("extraction directory holds %q after refused archives", got)
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	ts, dir := newExtractServer(t, 1<<20, 3)
+	tooMany := makeZip(t, tarEntry{name: "1"}, tarEntry{name: "2"}, tarEntry{name: "3"}, tarEntry{name: "4"})
+	if _, err := ts.uploadExtract(t, "many.zip", tooMany); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Errorf("archive over the entry limit: %v, want resource_exhausted", err)
+	}
+	// 64 MiB of zeros deflate to some 64 KiB, the limit stops the copy at 1 MiB
+	bomb := makeZip(t, tarEntry{name: "zeros", data: strings.Repeat("\x00", 64<<20)})
+	if _, err := ts.uploadExtract(t, "bomb.zip", bomb); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Errorf("zip bomb: %v, want resource_exhausted", err)
+	}
+	// the limit counts every entry
+	split := makeTar(t, true, tarEntry{name: "a", data: strings.Repeat("a", 600<<10)}, tarEntry{name: "b", data: strings.Repeat("b", 600<<10)})
+	if _, err := ts.uploadExtract(t, "split.tgz", split); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Errorf("entries over the size limit together: %v, want resource_exhausted", err)
+	}
+	if got := extracted(t, dir); len(got) != 1 || got[0] != defaultScope+"/" {
+		t.Fatalf("extraction directory holds %q after refused archives", got)
+	}
+	if _, err := ts.uploadExtract(t, "fits.tar", makeTar(t, false, tarEntry{name: "a", data: "a"}, tarEntry{name: "b", data: "b"})); err != nil {
+		t.Fatalf("archive within the limits: %v", err)
+	}
+}
+
+func TestExtractKeepsPreviousOnFailure(t *testing.T) {
+	ts, dir := newExtractServer(t, 0, 0)
+	if _, err := ts.uploadExtract(t, "app.tar", makeTar(t, false, tarEntry{name: "v1.txt", data: "one"})); err != nil {
+		t.Fatal(err)
+	}
+	bad := makeTar(t, false, tarEntry{name: "v2.txt", data: "two"}, tarEntry{name: "../evil", data: "evil"})
+	if _, err := ts.uploadExtract(t, "app.tar", bad); err == nil {
+		t.Fatal("unsafe archive extracted")
+	}
+	if got := extracted(t, filepath.Join(dir, defaultScope, "app")); len(got) != 1 || got[0] != "v1.txt=one" {
+		t.Fatalf("a failed extraction replaced the previous one: %q", got)
+	}
+	if _, err := ts.uploadExtract(t, "app.tar", makeTar(t, false, tarEntry{name: "v3.txt", data: "three"})); err != nil {
+		t.Fatal(err)
+	}
+	if got := extracted(t, filepath.Join(dir, defaultScope, "app")); len(got) != 1 || got[0] != "v3.txt=three" {
+		t.Fatalf("a new extraction did not replace the previous one: %q", got)
+	}
+}
+
+func TestExtractRefused(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if _, err := ts.uploadExtract(t, "a.zip", makeZip(t, tarEntry{name: "a", data: "a"})); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Errorf("extract without -extract-dir: %v, want failed_precondition", err)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "a.zip")); !os.IsNotExist(err) {
+		t.Errorf("a refused extract stored the archive: %v", err)
+	}
+	ts, _ = newExtractServer(t, 0, 0)
+	if _, err := ts.uploadExtract(t, "a.txt", "text"); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Errorf("extract of a file that is no archive: %v, want invalid_argument", err)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("a refused extract stored the file: %v", err)
+	}
+}
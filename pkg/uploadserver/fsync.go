@@ -0,0 +1,125 @@
+package uploadserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SyncPolicy decides when uploaded data is flushed to stable storage with fsync
+type SyncPolicy int
+
+const (
+	// SyncNone leaves flushing to the operating system: fastest, but a crash
+	// or power loss can lose files the server already reported as stored
+	SyncNone SyncPolicy = iota
+	// SyncOnCommit flushes a file and its directory entry once, before the
+	// upload is reported successful
+	SyncOnCommit
+	// SyncPerChunk also flushes every chunk, ranged PUT and tus PATCH before
+	// acknowledging it, so resumable uploads never resume past lost data
+	SyncPerChunk
+)
+
+// ParseSyncPolicy parses none, on-commit or per-chunk
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch s {
+	case "none", "":
+		return SyncNone, nil
+	case "on-commit":
+		return SyncOnCommit, nil
+	case "per-chunk":
+		return SyncPerChunk, nil
+	}
+	return SyncNone, fmt.Errorf("unknown sync policy %q, want none, on-commit or per-chunk", s)
+}
+
+// fsync flushes a file or directory to stable storage, replaced by tests to
+// count the flushes
+var fsync = (*os.File).Sync
+
+// syncChunk flushes f after a chunk was written under SyncPerChunk
+func (s *Server) syncChunk(f *os.File) error {
+	if s.sync != SyncPerChunk {
+		return nil
+	}
+	return fsync(f)
+}
+
+// syncCommit flushes a completed file before it is reported stored
+func (s *Server) syncCommit(f *os.File) error {
+	if s.sync == SyncNone {
+		return nil
+	}
+	return fsync(f)
+}
+
+// syncPath flushes the completed file at path, for files written by earlier requests
+func (s *Server) syncPath(path string) error {
+	if s.sync == SyncNone {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fsync(f)
+}
+
+// syncDir flushes the directory holding path so a new or renamed entry survives a crash
+func (s *Server) syncDir(path string) error {
+	if s.sync == SyncNone {
+		return nil
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return fsync(d)
+}
+
+// chunkSyncer flushes the file after every write of a streamed HTTP body
+type chunkSyncer struct{ f *os.File }
+
+func (c chunkSyncer) Write(p []byte) (int, error) {
+	n, err := c.f.Write(p)
+	if err == nil {
+		err = fsync(c.f)
+	}
+	return n, err
+}
+
+// writeFileAtomic replaces the file at path with data: it writes a temporary
+// file beside it, flushes it when sync is set and renames it over path, so a
+// crash leaves either the old or the new content, never a truncated file
+func writeFileAtomic(path string, data []byte, sync bool) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = writeFull(f, data)
+	if err == nil && sync {
+		err = fsync(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if sync {
+		return syncDir(filepath.Dir(path))
+	}
+	return nil
+}
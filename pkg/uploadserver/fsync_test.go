@@ -0,0 +1,104 @@
+package uploadserver
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseSyncPolicy(t *testing.T) {
+	for s, want := range map[string]SyncPolicy{"": SyncNone, "none": SyncNone, "on-commit": SyncOnCommit, "per-chunk": SyncPerChunk} {
+		if got, err := ParseSyncPolicy(s); err != nil || got != want {
+			t.Errorf("ParseSyncPolicy(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseSyncPolicy("always"); err == nil {
+		t.Error("ParseSyncPolicy accepted an unknown policy")
+	}
+}
+
+// countSyncs counts the flushes of every file and directory until the test ends
+func countSyncs(t *testing.T) func() map[string]int {
+	var (
+		mu     sync.Mutex
+		synced = make(map[string]int)
+	)
+	fsync = func(f *os.File) error {
+		mu.Lock()
+		synced[f.Name()]++
+		mu.Unlock()
+		return f.Sync()
+	}
+	t.Cleanup(func() { fsync = (*os.File).Sync })
+	return func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		got := synced
+		synced = make(map[string]int)
+		return got
+	}
+}
+
+func TestSyncOnCommit(t *testing.T) {
+	synced := countSyncs(t)
+	ts := newTestServer(t, &Server{sync: SyncOnCommit})
+
+	// the file is flushed where it is written, its directory once it is in place
+	ts.uploadFile(t, "unary.txt", "unary")
+	if _, err := ts.streamUpload(t.Context(), "streamed.txt", []byte("0123456789"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := ts.putRange(t, "put.txt", "", "put"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+	got := synced()
+	for _, name := range []string{"unary.txt", "streamed.txt", "put.txt"} {
+		if got[ts.srv.files.path(name)] != 1 {
+			t.Errorf("%s flushed %d times, want once", name, got[ts.srv.files.path(name)])
+		}
+	}
+	if got[ts.dir] != 3 {
+		t.Errorf("upload directory flushed %d times, want once per upload", got[ts.dir])
+	}
+
+	// a ranged upload is flushed once complete, not range by range
+	ts.putRange(t, "ranged.txt", "bytes 0-3/8", "0123")
+	if got := synced(); len(got) != 0 {
+		t.Fatalf("flushes before the ranged upload completed: %v", got)
+	}
+	ts.putRange(t, "ranged.txt", "bytes 4-7/8", "4567")
+	if got := synced(); got[ts.partialPath("ranged.txt")] != 1 || got[ts.dir] != 1 {
+		t.Fatalf("completed ranged upload flushed %v", got)
+	}
+}
+
+func TestSyncPerChunk(t *testing.T) {
+	synced := countSyncs(t)
+	ts := newTestServer(t, &Server{sync: SyncPerChunk})
+
+	if _, err := ts.streamUpload(t.Context(), "streamed.txt", []byte("0123456789"), 2); err != nil {
+		t.Fatal(err)
+	}
+	// five chunks and the commit
+	if got := synced()[ts.srv.files.path("streamed.txt")]; got != 6 {
+		t.Errorf("streamed file flushed %d times, want 6", got)
+	}
+	ts.putRange(t, "ranged.txt", "bytes 0-3/8", "0123")
+	if got := synced()[ts.partialPath("ranged.txt")]; got != 1 {
+		t.Errorf("range flushed %d times before it was acknowledged, want once", got)
+	}
+}
+
+func TestSyncNone(t *testing.T) {
+	synced := countSyncs(t)
+	ts := newTestServer(t, &Server{})
+	ts.uploadFile(t, "unary.txt", "unary")
+	if _, err := ts.streamUpload(t.Context(), "streamed.txt", []byte(strings.Repeat("x", 64)), 8); err != nil {
+		t.Fatal(err)
+	}
+	if got := synced(); len(got) != 0 {
+		t.Fatalf("flushes without a sync policy: %v", got)
+	}
+}
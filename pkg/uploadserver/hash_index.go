@@ -0,0 +1,326 @@
+package uploadserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// pendingDir holds, inside partialDir, the content of uploads waiting for
+// checkDuplicate before it may replace a stored file, or for their storage key
+const pendingDir = ".pending"
+
+// hashIndex maps content hashes to the stored files holding that content.
+// It is kept up to date by every upload path so lookups never touch the disk,
+// and each change is journaled so it survives restarts.
+type hashIndex struct {
+	mu      sync.RWMutex
+	journal *journal[string]               // on-disk copy, nil keeps it in memory only
+	byName  map[string]string              // filename -> sha256
+	byHash  map[string]map[string]struct{} // sha256 -> filenames
+}
+
+func newHashIndex() *hashIndex {
+	return &hashIndex{
+		byName: make(map[string]string),
+		byHash: make(map[string]map[string]struct{}),
+	}
+}
+
+// openHashIndex loads the index saved at path and reconciles it with the files
+// stored by files: entries for missing files are dropped and unknown files are
+// hashed. A missing or unreadable index, or rebuild, rescans every file instead.
+func openHashIndex(path string, files *router, rebuild bool, redact redactor) (*hashIndex, error) {
+	x := newHashIndex()
+	var known map[string]string
+	if path != "" {
+		if rebuild {
+			if err := removeJournal(path); err != nil {
+				return nil, err
+			}
+		} else if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			log.Printf("Hash index %s not found, building it", path)
+		}
+		j, saved, err := openJournal[string](path)
+		if err != nil {
+			log.Printf("Hash index %s is corrupt, rebuilding it: %v", path, err)
+			if err := removeJournal(path); err != nil {
+				return nil, err
+			}
+			if j, saved, err = openJournal[string](path); err != nil {
+				return nil, err
+			}
+		}
+		x.journal, known = j, saved
+	}
+
+	if err := x.scan(files, known, redact); err != nil {
+		return nil, err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	// the scan may have dropped and added entries without logging them
+	if err := x.journal.compact(x.byName); err != nil {
+		return nil, err
+	}
+	log.Printf("Hash index ready: %d files", len(x.byName))
+	return x, nil
+}
+
+// scan indexes every regular file in the directories of files, reusing the
+// hashes in known and hashing the rest. Hidden files (probes, partial uploads)
+// are skipped, and so are files sitting outside the directory they route to.
+func (x *hashIndex) scan(files *router, known map[string]string, redact redactor) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, sub := range files.dirs() {
+		entries, err := os.ReadDir(filepath.Join(files.dir, sub))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			name := files.nameAt(sub, e.Name())
+			if files.subdir(name) != sub {
+				log.Printf("Hash index: skipping %s, files named like it are stored in %q", filepath.Join(sub, redact.name(e.Name())), files.subdir(name))
+				continue
+			}
+			hash, ok := known[name]
+			if !ok {
+				hash, err = hashFile(files.path(name))
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+			x.setLocked(name, hash)
+		}
+	}
+	return nil
+}
+
+func (x *hashIndex) set(filename, hash string) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.setLocked(filename, hash)
+	x.logOrWarn(x.journal.set(filename, hash, x.byName))
+}
+
+func (x *hashIndex) remove(filename string) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.byName[filename]; ok {
+		x.removeLocked(filename)
+		x.logOrWarn(x.journal.remove(filename, x.byName))
+	}
+}
+
+func (x *hashIndex) setLocked(filename, hash string) {
+	x.removeLocked(filename)
+	x.byName[filename] = hash
+	if x.byHash[hash] == nil {
+		x.byHash[hash] = make(map[string]struct{})
+	}
+	x.byHash[hash][filename] = struct{}{}
+}
+
+func (x *hashIndex) removeLocked(filename string) {
+	hash, ok := x.byName[filename]
+	if !ok {
+		return
+	}
+	delete(x.byName, filename)
+	delete(x.byHash[hash], filename)
+	if len(x.byHash[hash]) == 0 {
+		delete(x.byHash, hash)
+	}
+}
+
+// logOrWarn reports a change the journal failed to save; the in-memory index
+// stays authoritative and the next startup reconciles it with the store
+func (x *hashIndex) logOrWarn(err error) {
+	if err != nil {
+		log.Printf("Failed to save hash index: %v", err)
+	}
+}
+
+// close closes the journal once the server stops
+func (x *hashIndex) close() {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.journal.close()
+}
+
+// hashOf returns the indexed hash of a stored file
+func (x *hashIndex) hashOf(filename string) (string, bool) {
+	if x == nil {
+		return "", false
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	hash, ok := x.byName[filename]
+	return hash, ok
+}
+
+// claim removes filename from the index if it is stored with the given hash,
+// so of concurrent overwrites conditional on the same hash only one proceeds
+func (x *hashIndex) claim(filename, hash string) bool {
+	if x == nil {
+		return false
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if current, ok := x.byName[filename]; !ok || current != hash {
+		return false
+	}
+	x.removeLocked(filename)
+	x.logOrWarn(x.journal.remove(filename, x.byName))
+	return true
+}
+
+// lookup returns a stored file other than exclude whose content has the given hash
+func (x *hashIndex) lookup(hash, exclude string) (string, bool) {
+	if x == nil {
+		return "", false
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	names := make([]string, 0, len(x.byHash[hash]))
+	for name := range x.byHash[hash] {
+		if name != exclude {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	sort.Strings(names)
+	return names[0], true
+}
+
+// Config.RejectDuplicateContent is set
+// -reject-duplicate-content is enabled
+func (s *Server) checkDuplicate(filename, hash string) error {
+	if !s.rejectDuplicates {
+		return nil
+	}
+	if existing, ok := s.index.lookup(hash, filename); ok {
+		return connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("content already stored as %s", baseName(existing)))
+	}
+	return nil
+}
+
+// checkIfMatch enforces if_match_sha256: filename must be stored with
+// content hash want. claim takes the file for an overwrite that is about to
+// start, a dry run only checks. An empty want always passes.
+func (s *Server) checkIfMatch(filename, want string, claim bool) error {
+	if want == "" {
+		return nil
+	}
+	var ok bool
+	if claim {
+		ok = s.index.claim(filename, want)
+	} else {
+		current, stored := s.index.hashOf(filename)
+		ok = stored && current == want
+	}
+	if !ok {
+		return connect.NewError(connect.CodeFailedPrecondition,
+			fmt.Errorf("%s is not stored with hash %s: it changed or does not exist", baseName(filename), want))
+	}
+	return nil
+}
+
+// stagedPath returns where new content for filename is written until
+// placeStored puts it in place. Under RejectDuplicateContent that is a
+// file of its own in partialDir, so a rejected duplicate never replaces what
+// is stored as filename, and so it is in immutable mode, where a file under
+// retention must not be written over, and under a StorageKey, whose key is
+// only known once the content is. Otherwise it is the stored file itself,
+// dropped from the index as its content is about to be replaced.
+func (s *Server) stagedPath(filename string) (string, error) {
+	if !s.rejectDuplicates && s.retentionPeriod == 0 && s.storageKey == nil && s.checkRetention(filename) == nil {
+		s.index.remove(filename)
+		return s.files.path(filename), nil
+	}
+	dir := filepath.Join(s.dir, partialDir, pendingDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	var raw [16]byte
+	rand.Read(raw[:])
+	return filepath.Join(dir, hex.EncodeToString(raw[:])), nil
+}
+
+// placeStored moves the complete content at staged into place as filename
+// and registers it. A duplicate rejected by checkDuplicate, or content for a
+// file under retention, is deleted before it replaces anything, leaving the
+// file stored as filename untouched. A keyed filename goes to the key
+// Config.StorageKey resolves it to. The new file does not inherit the expiry
+// of the one it replaced, and starts its retention under Config.Retention.
+func (s *Server) placeStored(staged, filename, hash string) error {
+	if err := s.checkRetention(filename); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	if err := s.checkDuplicate(filename, hash); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	finalPath, key := s.files.path(filename), ""
+	if isKeyed(filename) {
+		var err error
+		if key, err = s.resolveKey(filename, hash); err != nil {
+			os.Remove(staged)
+			return err
+		}
+		finalPath = filepath.Join(s.dir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
+			os.Remove(staged)
+			return writeError(err)
+		}
+	}
+	if staged != finalPath {
+		if err := os.Rename(staged, finalPath); err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+	}
+	if err := s.syncDir(finalPath); err != nil {
+		return writeError(err)
+	}
+	if key != "" {
+		s.keyed(filename, key)
+	}
+	if hash == "" {
+		// an unhashed upload must not keep the hash of the file it replaced
+		s.index.remove(filename)
+	} else {
+		s.index.set(filename, hash)
+	}
+	s.expiries.set(filename, time.Time{})
+	s.retain(filename)
+	return nil
+}
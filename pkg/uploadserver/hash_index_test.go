@@ -0,0 +1,200 @@
+package uploadserver
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// newDedupServer serves a Server rejecting duplicate content, with a.txt
+// holding "v1" and b.txt holding "X"
+func newDedupServer(t *testing.T) *testServer {
+	t.Helper()
+	ts := newTestServer(t, &Server{index: newHashIndex(), rejectDuplicates: true})
+	ts.uploadFile(t, "a.txt", "v1")
+	ts.uploadFile(t, "b.txt", "X")
+	return ts
+}
+
+func TestHashIndexLookup(t *testing.T) {
+	x := newHashIndex()
+	x.set("b.txt", "h1")
+	x.set("a.txt", "h1")
+	x.set("c.txt", "h2")
+	if name, ok := x.lookup("h1", ""); !ok || name != "a.txt" {
+		t.Fatalf("lookup h1: %q, %v, want the first name", name, ok)
+	}
+	if name, ok := x.lookup("h1", "a.txt"); !ok || name != "b.txt" {
+		t.Fatalf("lookup h1 excluding a.txt: %q, %v", name, ok)
+	}
+	// replacing a file moves it to its new hash
+	x.set("c.txt", "h1")
+	if _, ok := x.lookup("h2", ""); ok {
+		t.Fatal("the replaced content of c.txt is still indexed")
+	}
+	x.remove("a.txt")
+	x.remove("b.txt")
+	if name, _ := x.lookup("h1", ""); name != "c.txt" {
+		t.Fatalf("lookup h1 after removals: %q", name)
+	}
+}
+
+func TestHashIndexScan(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, ".write-probe"), []byte("p"), 0644)
+	os.Mkdir(filepath.Join(dir, partialDir), 0755)
+	x := newHashIndex()
+	if err := x.scan(&router{dir: dir}, nil, redactor{}); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := x.lookup(sha256Hex("a"), ""); !ok || name != "a.txt" {
+		t.Fatalf("lookup after scan: %q, %v", name, ok)
+	}
+	if len(x.byName) != 1 {
+		t.Fatalf("indexed %v, want only the regular file", x.byName)
+	}
+}
+
+// openTestIndex opens the hash index of the files stored in dir, saved at path
+func openTestIndex(t *testing.T, dir, path string, rebuild bool) *hashIndex {
+	t.Helper()
+	x, err := openHashIndex(path, &router{dir: dir}, rebuild, redactor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(x.close)
+	return x
+}
+
+func TestHashIndexSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "index.json")
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	x := openTestIndex(t, dir, path, false)
+	if hash := x.byName["a.txt"]; hash != sha256Hex("a") {
+		t.Fatalf("scanned hash %q", hash)
+	}
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+	x.set("b.txt", sha256Hex("b"))
+	x.remove("a.txt")
+	os.Remove(filepath.Join(dir, "a.txt"))
+
+	reopened := openTestIndex(t, dir, path, false)
+	if name, ok := reopened.lookup(sha256Hex("b"), ""); !ok || name != "b.txt" {
+		t.Fatalf("lookup of b after a restart: %q, %v", name, ok)
+	}
+	if _, ok := reopened.byName["a.txt"]; ok {
+		t.Fatal("a removed file is still indexed")
+	}
+}
+
+func TestHashIndexRebuildsAfterCorruption(t *testing.T) {
+	for _, corrupt := range []string{"index.json", "index.json.log"} {
+		t.Run(corrupt, func(t *testing.T) {
+			dir, indexDir := t.TempDir(), t.TempDir()
+			path := filepath.Join(indexDir, "index.json")
+			os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+			openTestIndex(t, dir, path, false).set("a.txt", sha256Hex("a"))
+
+			if err := os.WriteFile(filepath.Join(indexDir, corrupt), []byte("{not json\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			x := openTestIndex(t, dir, path, false)
+			if hash := x.byName["a.txt"]; hash != sha256Hex("a") {
+				t.Fatalf("hash %q after rebuilding a corrupt index", hash)
+			}
+			// the rebuilt index was saved whole
+			if hash := openTestIndex(t, dir, path, false).byName["a.txt"]; hash != sha256Hex("a") {
+				t.Fatalf("hash %q after reopening the rebuilt index", hash)
+			}
+		})
+	}
+}
+
+func TestHashIndexRebuildIgnoresSavedHashes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "index.json")
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	// a stale entry, such as one left by a file replaced while the server was down
+	openTestIndex(t, dir, path, false).set("a.txt", sha256Hex("stale"))
+
+	if hash := openTestIndex(t, dir, path, false).byName["a.txt"]; hash != sha256Hex("stale") {
+		t.Fatalf("hash %q, want the saved one reused", hash)
+	}
+	if hash := openTestIndex(t, dir, path, true).byName["a.txt"]; hash != sha256Hex("a") {
+		t.Fatalf("hash %q after a rebuild, want the file rehashed", hash)
+	}
+}
+
+func TestRejectDuplicateContent(t *testing.T) {
+	ts := newDedupServer(t)
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "c.txt", Data: []byte("X"), Sha256: sha256Hex("X")})
+	if connect.CodeOf(err) != connect.CodeAlreadyExists || !strings.Contains(err.Error(), "b.txt") {
+		t.Fatalf("duplicate upload: %v, want already exists naming b.txt", err)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "c.txt")); !os.IsNotExist(err) {
+		t.Fatalf("the rejected duplicate was stored: %v", err)
+	}
+	// the same content again under its own name is no duplicate
+	ts.uploadFile(t, "b.txt", "X")
+}
+
+// TestRejectedDuplicateKeepsEarlierVersion replaces a.txt with the content of
+// b.txt through every upload path: each is rejected and a.txt keeps "v1"
+func TestRejectedDuplicateKeepsEarlierVersion(t *testing.T) {
+	ts := newDedupServer(t)
+	check := func(path string, err error) {
+		t.Helper()
+		if connect.CodeOf(err) != connect.CodeAlreadyExists {
+			t.Errorf("%s: %v, want already exists", path, err)
+		}
+		if got := ts.stored(t, "a.txt"); got != "v1" {
+			t.Fatalf("%s: a.txt holds %q after a rejected duplicate, want the earlier version", path, got)
+		}
+	}
+	httpErr := func(resp *http.Response, _ []byte) error {
+		if resp.StatusCode == http.StatusConflict {
+			return connect.NewError(connect.CodeAlreadyExists, nil)
+		}
+		return connect.NewError(connect.CodeUnknown, nil)
+	}
+
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "a.txt", Data: []byte("X"), Sha256: sha256Hex("X")})
+	check("UploadFile", err)
+	_, err = ts.streamUpload(t.Context(), "a.txt", []byte("X"), 1)
+	check("Upload", err)
+	_, err = declaredUpload(t.Context(), ts.client, "a.txt", sha256Hex("X"), []byte("X"))
+	check("Upload with a declared hash", err)
+
+	body, ct := multipartBody(t, "a.txt", "X", nil)
+	check("multipart", httpErr(ts.do(t, withContentType(ts.newRequest(t, http.MethodPost, "/upload", body), ct))))
+	check("PUT", httpErr(ts.putRange(t, "a.txt", "", "X")))
+	check("ranged PUT", httpErr(ts.putRange(t, "a.txt", "bytes 0-0/1", "X")))
+
+	req := ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", "1")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("a.txt")))
+	resp, _ := ts.do(t, req)
+	check("tus", httpErr(ts.tusPatch(t, resp.Header.Get("Location"), 0, "X"), nil))
+
+	// nothing rejected is left behind
+	if left, _ := os.ReadDir(filepath.Join(ts.dir, partialDir, pendingDir)); len(left) != 0 {
+		t.Fatalf("rejected content left in %s: %v", pendingDir, left)
+	}
+	if left, _ := os.ReadDir(filepath.Join(ts.dir, partialDir, tusDir)); len(left) != 0 {
+		t.Fatalf("the rejected tus session is left: %v", left)
+	}
+}
+
+func withContentType(req *http.Request, ct string) *http.Request {
+	req.Header.Set("Content-Type", ct)
+	return req
+}
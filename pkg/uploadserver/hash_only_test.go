@@ -0,0 +1,54 @@
+package uploadserver
+
+import (
+	"testing"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// hashOnlyUpload streams data as name with hash_only set and commits hash
+func (ts *testServer) hashOnlyUpload(t *testing.T, name, data, hash string) *fileuploadv1.UploadResponse {
+	t.Helper()
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*fileuploadv1.UploadRequest{
+		{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: name, HashOnly: true}}},
+		{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte(data)}},
+		{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: hash}},
+	} {
+		if err := stream.Send(req); err != nil {
+			break
+		}
+	}
+	resp, err := stream.CloseAndReceive()
+	if err != nil {
+		t.Fatalf("hash-only upload of %s: %v", name, err)
+	}
+	return resp
+}
+
+func TestHashOnly(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex(), rejectDuplicates: true})
+
+	resp := ts.hashOnlyUpload(t, "digest.bin", "hash me", "")
+	if resp.Sha256 != sha256Hex("hash me") || resp.Size != int64(len("hash me")) || resp.HashOk {
+		t.Fatalf("hash-only response without a commit hash: %+v", resp)
+	}
+	ts.assertNotStored(t, "digest.bin")
+
+	if resp := ts.hashOnlyUpload(t, "digest.bin", "hash me", sha256Hex("hash me")); !resp.HashOk {
+		t.Fatalf("hash-only response with a matching commit hash: %+v", resp)
+	}
+	if resp := ts.hashOnlyUpload(t, "digest.bin", "hash me", sha256Hex("other")); resp.HashOk {
+		t.Fatalf("hash-only response with a differing commit hash: %+v", resp)
+	}
+
+	// content stored under another name is no reason to refuse hashing it
+	ts.uploadFile(t, "stored.bin", "hash me")
+	if resp := ts.hashOnlyUpload(t, "digest.bin", "hash me", ""); resp.Sha256 != sha256Hex("hash me") {
+		t.Fatalf("hash-only upload of stored content: %+v", resp)
+	}
+	ts.assertNotStored(t, "digest.bin")
+}
@@ -0,0 +1,88 @@
+package uploadserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"sync"
+)
+
+// hashQueueChunks is how many chunks wait for a hash worker before Write
+// blocks, bounding the memory they hold beyond Config.MaxBufferMemory
+const hashQueueChunks = 8
+
+// chunkHash is the SHA-256 of a stream of chunks: end marks the end of the
+// input, sum waits for the digest
+type chunkHash interface {
+	Write(p []byte)
+	end()
+	sum() string
+}
+
+// serialHash hashes each chunk on the write path
+type serialHash struct {
+	h hash.Hash
+}
+
+func (s serialHash) Write(p []byte) { s.h.Write(p) }
+func (s serialHash) end()           {}
+func (s serialHash) sum() string    { return hex.EncodeToString(s.h.Sum(nil)) }
+
+// asyncHash hashes the chunks on a worker goroutine, so a chunk is hashed
+// while the next one is received and written. SHA-256 is sequential, so the
+// worker takes the chunks in order. Chunks are handed over, not copied:
+// they must not change after Write.
+type asyncHash struct {
+	chunks chan []byte
+	done   chan struct{}
+	once   sync.Once
+	digest string
+}
+
+func newAsyncHash(release func()) *asyncHash {
+	a := &asyncHash{chunks: make(chan []byte, hashQueueChunks), done: make(chan struct{})}
+	go func() {
+		// the worker is free again once sum returns
+		defer close(a.done)
+		defer release()
+		h := sha256.New()
+		for p := range a.chunks {
+			h.Write(p)
+		}
+		a.digest = hex.EncodeToString(h.Sum(nil))
+	}()
+	return a
+}
+
+func (a *asyncHash) Write(p []byte) { a.chunks <- p }
+func (a *asyncHash) end()           { a.once.Do(func() { close(a.chunks) }) }
+
+func (a *asyncHash) sum() string {
+	a.end()
+	<-a.done
+	return a.digest
+}
+
+// noHash stands in for the hash of uploads with Config.SkipHashVerification
+type noHash struct{}
+
+func (noHash) Write(p []byte) {}
+func (noHash) end()           {}
+func (noHash) sum() string    { return "" }
+
+var errHashingDisabled = errors.New("hash verification is disabled on this server")
+
+// newChunkHash returns a hash run by one of the Config.HashWorkers workers,
+// or on the write path when they are all busy or there are none
+func (s *Server) newChunkHash() chunkHash {
+	if s.skipHash {
+		return noHash{}
+	}
+	select {
+	case s.hashWorkers <- struct{}{}:
+		return newAsyncHash(func() { <-s.hashWorkers })
+	default:
+		return serialHash{sha256.New()}
+	}
+}
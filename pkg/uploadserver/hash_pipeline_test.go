@@ -0,0 +1,109 @@
+package uploadserver
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	mathrand "math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// randomChunks splits n random bytes into chunks of random sizes
+func randomChunks(t *testing.T, n int) ([]byte, [][]byte) {
+	t.Helper()
+	data := make([]byte, n)
+	rand.Read(data)
+	var chunks [][]byte
+	for rest := data; len(rest) > 0; {
+		size := min(len(rest), 1+mathrand.IntN(4096))
+		chunks = append(chunks, rest[:size])
+		rest = rest[size:]
+	}
+	return data, chunks
+}
+
+func TestChunkHashMatchesSerial(t *testing.T) {
+	s := &Server{hashWorkers: make(chan struct{}, 2)}
+	for range 20 {
+		data, chunks := randomChunks(t, mathrand.IntN(1<<20))
+		want := sha256.Sum256(data)
+
+		h := s.newChunkHash()
+		if _, ok := h.(*asyncHash); !ok {
+			t.Fatalf("with a free worker, hashed by %T", h)
+		}
+		for _, c := range chunks {
+			h.Write(c)
+		}
+		if got := h.sum(); got != hex.EncodeToString(want[:]) {
+			t.Fatalf("%d bytes in %d chunks: worker hash %s, serial %x", len(data), len(chunks), got, want)
+		}
+	}
+	if len(s.hashWorkers) != 0 {
+		t.Fatalf("%d workers still held", len(s.hashWorkers))
+	}
+}
+
+func TestChunkHashBusyWorkers(t *testing.T) {
+	s := &Server{hashWorkers: make(chan struct{}, 1)}
+	first, second := s.newChunkHash(), s.newChunkHash()
+	if _, ok := second.(serialHash); !ok {
+		t.Fatalf("with every worker busy, hashed by %T, want on the write path", second)
+	}
+	first.end()
+	first.sum()
+	if _, ok := s.newChunkHash().(*asyncHash); !ok {
+		t.Fatal("an ended hash kept its worker")
+	}
+	if _, ok := (&Server{}).newChunkHash().(serialHash); !ok {
+		t.Fatal("without hash workers, not hashed on the write path")
+	}
+}
+
+func TestSegmentHasherWorkersMatchSerial(t *testing.T) {
+	data, chunks := randomChunks(t, 300_000)
+	const size = 64 << 10
+	serial := newSegmentHasher(size, (&Server{}).newChunkHash)
+	parallel := newSegmentHasher(size, (&Server{hashWorkers: make(chan struct{}, 4)}).newChunkHash)
+	for _, c := range chunks {
+		serial.Write(c)
+		parallel.Write(c)
+	}
+	var sums []string
+	for off := 0; off < len(data); off += size {
+		sum := sha256.Sum256(data[off:min(off+size, len(data))])
+		sums = append(sums, hex.EncodeToString(sum[:]))
+	}
+	for name, h := range map[string]*segmentHasher{"serial": serial, "workers": parallel} {
+		if bad, err := h.corrupt(sums); err != nil || len(bad) != 0 {
+			t.Errorf("%s: corrupt segments %v, %v", name, bad, err)
+		}
+	}
+
+	// a changed segment is found wherever it is hashed
+	sums[2] = sha256Hex("something else")
+	h := newSegmentHasher(size, (&Server{hashWorkers: make(chan struct{}, 4)}).newChunkHash)
+	for _, c := range chunks {
+		h.Write(c)
+	}
+	if bad, err := h.corrupt(sums); err != nil || !slices.Equal(bad, []int64{2}) {
+		t.Fatalf("corrupt = %v, %v; want segment 2", bad, err)
+	}
+}
+
+func TestUploadWithHashWorkers(t *testing.T) {
+	ts := newTestServer(t, &Server{hashWorkers: make(chan struct{}, 2)})
+	data, _ := randomChunks(t, 200_000)
+	resp, err := ts.streamUpload(t.Context(), "random.bin", data, 7000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(data)
+	if !resp.HashOk || resp.Sha256 != hex.EncodeToString(want[:]) {
+		t.Fatalf("hash %s (ok %v), want %x", resp.Sha256, resp.HashOk, want)
+	}
+	if got := ts.stored(t, "random.bin"); got != string(data) {
+		t.Fatal("stored content differs")
+	}
+}
@@ -0,0 +1,73 @@
+package uploadserver
+
+import (
+	"net/http"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestHashStatus(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	for _, tc := range []struct {
+		hash   string
+		status fileuploadv1.HashStatus
+	}{
+		{sha256Hex("data"), fileuploadv1.HashStatus_HASH_STATUS_VERIFIED},
+		{sha256Hex("other"), fileuploadv1.HashStatus_HASH_STATUS_MISMATCH},
+		{"", fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED},
+	} {
+		resp, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "a.txt", Data: []byte("data"), Sha256: tc.hash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.HashStatus != tc.status || resp.HashOk != (tc.status == fileuploadv1.HashStatus_HASH_STATUS_VERIFIED) {
+			t.Errorf("UploadFile with hash %q: status %v, hash_ok %v, want %v", tc.hash, resp.HashStatus, resp.HashOk, tc.status)
+		}
+
+		body, ct := multipartBody(t, "form.txt", "data", map[string]string{"sha256": tc.hash})
+		req := ts.newRequest(t, http.MethodPost, "/upload", body)
+		req.Header.Set("Content-Type", ct)
+		if got := ts.httpHashStatus(t, req); got != tc.status {
+			t.Errorf("multipart with hash %q: status %v, want %v", tc.hash, got, tc.status)
+		}
+	}
+
+	// a PUT either declares the right hash or stores nothing
+	req := ts.newRequest(t, http.MethodPut, "/files/put.txt", nil)
+	req.Body = http.NoBody
+	if got := ts.httpHashStatus(t, req); got != fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED {
+		t.Errorf("PUT without a hash: status %v", got)
+	}
+
+	// a streamed upload always commits with its hash
+	resp, err := ts.streamUpload(t.Context(), "streamed.txt", []byte("data"), 2)
+	if err != nil || resp.HashStatus != fileuploadv1.HashStatus_HASH_STATUS_VERIFIED {
+		t.Fatalf("streamed upload: status %v, %v", resp.GetHashStatus(), err)
+	}
+	// chunked calls report nothing until the upload is complete
+	partial, err := ts.uploadChunk(t.Context(), "c.txt", 0, "da", false, "")
+	if err != nil || partial.HashStatus != fileuploadv1.HashStatus_HASH_STATUS_UNSPECIFIED {
+		t.Fatalf("incomplete chunked upload: status %v, %v", partial.GetHashStatus(), err)
+	}
+	done, err := ts.uploadChunk(t.Context(), "c.txt", 2, "ta", true, "")
+	if err != nil || done.HashStatus != fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED {
+		t.Fatalf("completed chunked upload without a hash: status %v, %v", done.GetHashStatus(), err)
+	}
+}
+
+// httpHashStatus sends req and returns the hash status of its JSON response
+func (ts *testServer) httpHashStatus(t *testing.T, req *http.Request) fileuploadv1.HashStatus {
+	t.Helper()
+	resp, body := ts.do(t, req)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		t.Fatalf("%s %s: status %d: %s", req.Method, req.URL.Path, resp.StatusCode, body)
+	}
+	var out fileuploadv1.UploadResponse
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, &out); err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	return out.HashStatus
+}
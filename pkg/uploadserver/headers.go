@@ -0,0 +1,64 @@
+package uploadserver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"connectrpc.com/connect"
+)
+
+// singleHeaders may appear at most once in a request. A repeated one could be
+// read differently by a proxy in front and by this server.
+var singleHeaders = []string{
+	"Content-Type", "Content-Encoding", "Content-Range", contentHashHeader, idempotencyHeader,
+	"Upload-Offset", "Upload-Length", "Upload-Metadata", "Tus-Resumable",
+	"Connect-Protocol-Version", "Connect-Timeout-Ms", "Connect-Content-Encoding", "Grpc-Timeout",
+}
+
+// checkHeaders refuses requests whose headers cannot be meant seriously with
+// CodeInvalidArgument before any handler runs: a repeated single-valued
+// header, Content-Range on anything but PUT, an X-Content-Sha256 that is no
+// SHA-256 and a negative or non-numeric tus Upload-Offset or Upload-Length.
+// Net/http already refuses malformed or conflicting Content-Length and
+// Transfer-Encoding headers.
+func checkHeaders(next http.Handler) http.Handler {
+	errWriter := connect.NewErrorWriter()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := suspiciousHeader(r); err != nil {
+			err := connect.NewError(connect.CodeInvalidArgument, err)
+			if errWriter.IsSupported(r) {
+				errWriter.Write(w, r, err)
+			} else {
+				writeHTTPError(w, err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func suspiciousHeader(r *http.Request) error {
+	for _, name := range singleHeaders {
+		if len(r.Header.Values(name)) > 1 {
+			return fmt.Errorf("repeated %s header", name)
+		}
+	}
+	if r.Header.Get("Content-Range") != "" && r.Method != http.MethodPut {
+		return fmt.Errorf("Content-Range is only valid on PUT, not %s", r.Method)
+	}
+	if h := r.Header.Get(contentHashHeader); h != "" {
+		if b, err := hex.DecodeString(h); err != nil || len(b) != 32 {
+			return fmt.Errorf("%s must be a hex-encoded SHA-256", contentHashHeader)
+		}
+	}
+	for _, name := range []string{"Upload-Offset", "Upload-Length"} {
+		if h := r.Header.Get(name); h != "" {
+			if n, err := strconv.ParseInt(h, 10, 64); err != nil || n < 0 {
+				return fmt.Errorf("%s must be a non-negative integer", name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package uploadserver
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestSuspiciousHeaders(t *testing.T) {
+	hash := sha256Hex("data")
+	tests := []struct {
+		desc   string
+		method string
+		header http.Header
+		ok     bool
+	}{
+		{"plain PUT", http.MethodPut, http.Header{"Content-Range": {"bytes 0-3/4"}, contentHashHeader: {hash}}, true},
+		{"tus PATCH", http.MethodPatch, http.Header{"Upload-Offset": {"0"}, "Tus-Resumable": {"1.0.0"}}, true},
+		{"repeated Content-Type", http.MethodPost, http.Header{"Content-Type": {"application/proto", "application/json"}}, false},
+		{"repeated Content-Range", http.MethodPut, http.Header{"Content-Range": {"bytes 0-3/4", "bytes 4-7/8"}}, false},
+		{"repeated Idempotency-Key", http.MethodPut, http.Header{idempotencyHeader: {"a", "b"}}, false},
+		{"repeated Grpc-Timeout", http.MethodPost, http.Header{"Grpc-Timeout": {"1S", "1000S"}}, false},
+		{"Content-Range on POST", http.MethodPost, http.Header{"Content-Range": {"bytes 0-3/4"}}, false},
+		{"hash that is no hex", http.MethodPut, http.Header{contentHashHeader: {strings.Repeat("z", 64)}}, false},
+		{"hash too short", http.MethodPut, http.Header{contentHashHeader: {hash[:62]}}, false},
+		{"negative Upload-Offset", http.MethodPatch, http.Header{"Upload-Offset": {"-1"}}, false},
+		{"non-numeric Upload-Length", http.MethodPost, http.Header{"Upload-Length": {"ten"}}, false},
+	}
+	for _, tc := range tests {
+		r, _ := http.NewRequest(tc.method, "/", nil)
+		r.Header = tc.header
+		if err := suspiciousHeader(r); (err == nil) != tc.ok {
+			t.Errorf("%s: %v, want ok %v", tc.desc, err, tc.ok)
+		}
+	}
+}
+
+func TestCheckHeadersRefusesBeforeHandlers(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+
+	// plain HTTP clients get a problem document
+	req := ts.newRequest(t, http.MethodPut, "/files/a.txt", strings.NewReader("data"))
+	req.Header.Add("Content-Range", "bytes 0-3/4")
+	req.Header.Add("Content-Range", "bytes 0-3/*")
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "repeated Content-Range") {
+		t.Fatalf("repeated Content-Range: status %d: %s", resp.StatusCode, body)
+	}
+	req = ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", "-5")
+	if resp, _ := ts.do(t, req); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("negative Upload-Length: status %d, want 400", resp.StatusCode)
+	}
+
+	// RPC clients get their error format
+	ctx, info := connect.NewClientContext(t.Context())
+	info.RequestHeader().Set("Content-Range", "bytes 0-3/4")
+	_, err := ts.client.UploadFile(ctx, &fileuploadv1.UploadFileRequest{Filename: "a.txt", Data: []byte("data")})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("RPC with Content-Range: %v, want invalid_argument", err)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("a refused request stored a file: %v", err)
+	}
+}
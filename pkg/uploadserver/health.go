@@ -0,0 +1,87 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+var errStorageUnavailable = errors.New("storage unavailable")
+
+// storageHealth holds the outcome of the latest periodic storage probe so
+// uploads doomed to fail can be rejected before they start
+type storageHealth struct {
+	lastErr atomic.Pointer[error]
+	probe   func() error
+}
+
+func newStorageHealth(probe func() error) *storageHealth {
+	h := &storageHealth{probe: probe}
+	h.update()
+	return h
+}
+
+// update runs the probe once and logs healthy/unhealthy transitions
+func (h *storageHealth) update() {
+	err := h.probe()
+	prev := h.lastErr.Swap(&err)
+	wasHealthy := prev == nil || *prev == nil
+	switch {
+	case err != nil && wasHealthy:
+		log.Printf("Storage became unhealthy: %v", err)
+	case err == nil && !wasHealthy:
+		log.Printf("Storage is healthy again")
+	}
+}
+
+// run probes storage every interval until ctx is done
+func (h *storageHealth) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.update()
+		}
+	}
+}
+
+// err returns the latest probe failure, or nil when storage is healthy
+func (h *storageHealth) err() error {
+	if h == nil {
+		return nil
+	}
+	if p := h.lastErr.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// checkAvailable returns a CodeUnavailable error when storage is unhealthy
+func (h *storageHealth) checkAvailable() error {
+	if err := h.err(); err != nil {
+		return connect.NewError(connect.CodeUnavailable, errStorageUnavailable)
+	}
+	return nil
+}
+
+// readyz reports 503 while the storage probe is failing
+func (h *storageHealth) readyz(w http.ResponseWriter, r *http.Request) {
+	if err := h.err(); err != nil {
+		http.Error(w, "not ready: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// healthz reports that the process is alive
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,56 @@
+package uploadserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestUnhealthyStorageRejectsUploads(t *testing.T) {
+	var probeErr error
+	health := newStorageHealth(func() error { return probeErr })
+	ts := newTestServer(t, &Server{storage: health})
+	ts.uploadFile(t, "before.txt", "healthy")
+
+	probeErr = errors.New("disk full")
+	health.update()
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "a.txt", Data: []byte("x"), Sha256: sha256Hex("x")})
+	if connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Fatalf("UploadFile on unhealthy storage: %v, want unavailable", err)
+	}
+	if _, err := declaredUpload(t.Context(), ts.client, "b.txt", sha256Hex("x"), []byte("x")); connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Fatalf("Upload on unhealthy storage: %v, want unavailable", err)
+	}
+
+	probeErr = nil
+	health.update()
+	ts.uploadFile(t, "after.txt", "healthy again")
+}
+
+func TestReadyzFollowsStorageProbe(t *testing.T) {
+	var probeErr error
+	health := newStorageHealth(func() error { return probeErr })
+	readyz := func() int {
+		rec := httptest.NewRecorder()
+		health.readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("readyz on healthy storage: %d", code)
+	}
+	probeErr = errors.New("read-only file system")
+	health.update()
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz on unhealthy storage: %d, want 503", code)
+	}
+	probeErr = nil
+	health.update()
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("readyz after recovery: %d", code)
+	}
+}
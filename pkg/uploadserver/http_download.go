@@ -0,0 +1,60 @@
+package uploadserver
+
+import (
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+// handleGetFile serves /files/{name} for browsers, video players and download
+// managers. http.ServeContent handles Range, If-Range and conditional requests,
+// with the content SHA-256 as a strong ETag. With Config.ExternalURL the
+// client is redirected to external storage instead. With
+// Config.CompressDownloads whole responses of compressible types are gzipped.
+func (s *Server) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	filename := s.lookupName(r.Context(), sanitizeFilename(r.PathValue("name")))
+	release, err := s.openFiles.acquire(r.Context())
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	defer release()
+	file, err := s.openStoredFile(filename)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	defer file.Close()
+
+	location, err := s.externalLocation(filename)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if location != "" {
+		http.Redirect(w, r, location, http.StatusFound)
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		writeHTTPError(w, connect.NewError(connect.CodeInternal, err))
+		return
+	}
+	hash, ok := s.index.hashOf(filename)
+	if !ok {
+		if hash, err = hashFile(s.files.path(filename)); err != nil {
+			writeHTTPError(w, connect.NewError(connect.CodeInternal, err))
+			return
+		}
+	}
+
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	if s.compressDownloads && info.Size() >= s.compressMinBytes && r.Header.Get("Range") == "" && acceptsGzip(r) {
+		gw := &gzipResponse{ResponseWriter: w}
+		defer gw.Close()
+		w = gw
+	}
+	http.ServeContent(w, r, baseName(filename), info.ModTime(), file)
+}
@@ -0,0 +1,60 @@
+package uploadserver
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetFile(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.uploadFile(t, "movie.bin", "0123456789")
+	etag := `"` + sha256Hex("0123456789") + `"`
+
+	resp, body := ts.do(t, ts.newRequest(t, http.MethodGet, "/files/movie.bin", nil))
+	if resp.StatusCode != http.StatusOK || string(body) != "0123456789" {
+		t.Fatalf("GET: %d %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("ETag"); got != etag {
+		t.Errorf("ETag %s, want %s", got, etag)
+	}
+	if got := resp.Header.Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Accept-Ranges %q", got)
+	}
+
+	req := ts.newRequest(t, http.MethodGet, "/files/movie.bin", nil)
+	req.Header.Set("Range", "bytes=4-")
+	resp, body = ts.do(t, req)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "456789" {
+		t.Fatalf("ranged GET: %d %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Range"); got != "bytes 4-9/10" {
+		t.Errorf("Content-Range %q", got)
+	}
+
+	// If-Range resumes only while the content is unchanged
+	req = ts.newRequest(t, http.MethodGet, "/files/movie.bin", nil)
+	req.Header.Set("Range", "bytes=0-1")
+	req.Header.Set("If-Range", etag)
+	if resp, body = ts.do(t, req); resp.StatusCode != http.StatusPartialContent || string(body) != "01" {
+		t.Fatalf("GET with a current If-Range: %d %q", resp.StatusCode, body)
+	}
+	req.Header.Set("If-Range", `"`+sha256Hex("old content")+`"`)
+	if resp, body = ts.do(t, req); resp.StatusCode != http.StatusOK || string(body) != "0123456789" {
+		t.Fatalf("GET with a stale If-Range: %d %q, want the whole file", resp.StatusCode, body)
+	}
+
+	req = ts.newRequest(t, http.MethodGet, "/files/movie.bin", nil)
+	req.Header.Set("If-None-Match", etag)
+	if resp, _ = ts.do(t, req); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional GET: %d, want 304", resp.StatusCode)
+	}
+}
+
+func TestGetFileNotFound(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	for _, path := range []string{"/files/missing.txt", "/files/..%2Fmain.go", "/files/.partial"} {
+		if resp, _ := ts.do(t, ts.newRequest(t, http.MethodGet, path, nil)); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", path, resp.StatusCode)
+		}
+	}
+}
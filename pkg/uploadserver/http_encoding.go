@@ -0,0 +1,158 @@
+package uploadserver
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// bodyDecodeError is a request body that does not decode per its Content-Encoding
+type bodyDecodeError struct {
+	err error
+}
+
+func (e bodyDecodeError) Error() string { return "invalid gzip request body: " + e.err.Error() }
+func (e bodyDecodeError) Unwrap() error { return e.err }
+
+// gzipBody decompresses a request body, marking corrupt or truncated data
+// apart from errors of the connection itself
+type gzipBody struct {
+	zr   *gzip.Reader
+	body io.ReadCloser
+}
+
+func (b gzipBody) Read(p []byte) (int, error) {
+	n, err := b.zr.Read(p)
+	var corrupt flate.CorruptInputError
+	if errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &corrupt) {
+		err = bodyDecodeError{err}
+	}
+	return n, err
+}
+
+func (b gzipBody) Close() error { return b.body.Close() }
+
+// decodeBody lets a plain-HTTP upload handler read a Content-Encoding: gzip
+// body as the content it encodes, so limits, hashes and X-Content-Sha256 all
+// apply to the decompressed bytes
+func decodeBody(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				writeProblem(w, http.StatusBadRequest, "invalid_argument", bodyDecodeError{err}.Error())
+				return
+			}
+			r.Body = gzipBody{zr: zr, body: r.Body}
+			r.Header.Del("Content-Encoding")
+			// only the compressed length is known
+			r.ContentLength = -1
+		default:
+			w.Header().Set("Accept-Encoding", "gzip")
+			writeProblem(w, http.StatusUnsupportedMediaType, "", "unsupported Content-Encoding, only gzip is accepted")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// compressible reports whether content of contentType is worth gzipping:
+// text and structured documents are, while images, video and archives such
+// as JPEG or zip are compressed already
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-ndjson",
+		"application/yaml", "application/x-yaml", "application/toml", "application/sql",
+		"application/x-sh", "application/x-tar", "application/wasm", "image/bmp":
+		return true
+	}
+	return false
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip", "*":
+		default:
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponse gzips a 200 response whose Content-Type is compressible,
+// passing any other one (304, 412, an error) through unchanged. The length
+// is dropped and the ETag made weak, as the bytes sent are not the stored
+// ones. Close ends the compressed stream.
+type gzipResponse struct {
+	http.ResponseWriter
+	zw       *gzip.Writer
+	written  bool
+	compress bool
+}
+
+func (g *gzipResponse) WriteHeader(code int) {
+	if !g.written {
+		g.written = true
+		h := g.Header()
+		h.Add("Vary", "Accept-Encoding")
+		if code == http.StatusOK && compressible(h.Get("Content-Type")) {
+			g.compress = true
+			h.Set("Content-Encoding", "gzip")
+			h.Del("Content-Length")
+			if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+	g.ResponseWriter.WriteHeader(code)
+}
+
+func (g *gzipResponse) Write(p []byte) (int, error) {
+	if !g.written {
+		g.WriteHeader(http.StatusOK)
+	}
+	if !g.compress {
+		return g.ResponseWriter.Write(p)
+	}
+	if g.zw == nil {
+		g.zw = gzip.NewWriter(g.ResponseWriter)
+	}
+	return g.zw.Write(p)
+}
+
+// Close flushes the compressed stream; a HEAD request, which has no body,
+// writes nothing
+func (g *gzipResponse) Close() error {
+	if g.zw == nil {
+		return nil
+	}
+	return g.zw.Close()
+}
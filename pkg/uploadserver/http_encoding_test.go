@@ -0,0 +1,157 @@
+package uploadserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveEncoded sends req to handler behind decodeBody
+func serveEncoded(handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	decodeBody(handler)(rec, req)
+	return rec
+}
+
+func TestGzipMultipartUpload(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	body, ct := multipartBody(t, "logs.txt", strings.Repeat("log line\n", 100), map[string]string{
+		"sha256": sha256Hex(strings.Repeat("log line\n", 100)),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(gzipped(t, body.Bytes())))
+	req.Header.Set("Content-Type", ct)
+	req.Header.Set("Content-Encoding", "gzip")
+	if rec := serveEncoded(ts.srv.handleMultipartUpload, req); rec.Code != http.StatusOK {
+		t.Fatalf("gzip multipart upload: status %d: %s", rec.Code, rec.Body)
+	}
+	if got := ts.stored(t, "logs.txt"); got != strings.Repeat("log line\n", 100) {
+		t.Fatalf("stored %q, want the decompressed content", got)
+	}
+}
+
+func TestGzipPut(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	put := func(name, encoding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/files/"+name, bytes.NewReader(body))
+		req.SetPathValue("name", name)
+		req.Header.Set("Content-Encoding", encoding)
+		return serveEncoded(ts.srv.handlePutFile, req)
+	}
+
+	if rec := put("a.txt", "gzip", gzipped(t, []byte("compressed put"))); rec.Code != http.StatusCreated {
+		t.Fatalf("gzip PUT: status %d: %s", rec.Code, rec.Body)
+	}
+	if got := ts.stored(t, "a.txt"); got != "compressed put" {
+		t.Fatalf("stored %q", got)
+	}
+	if rec := put("b.txt", "identity", []byte("plain")); rec.Code != http.StatusCreated || ts.stored(t, "b.txt") != "plain" {
+		t.Fatalf("identity PUT: status %d: %s", rec.Code, rec.Body)
+	}
+
+	truncated := gzipped(t, []byte(strings.Repeat("truncated ", 100)))
+	for name, tt := range map[string]struct {
+		encoding string
+		body     []byte
+		status   int
+	}{
+		"not gzip":  {"gzip", []byte("plain text"), http.StatusBadRequest},
+		"truncated": {"gzip", truncated[:len(truncated)/2], http.StatusBadRequest},
+		"brotli":    {"br", []byte("whatever"), http.StatusUnsupportedMediaType},
+	} {
+		rec := put("bad.txt", tt.encoding, tt.body)
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: status %d (%s), want %d with a problem", name, rec.Code, rec.Header().Get("Content-Type"), tt.status)
+		}
+		ts.assertNotStored(t, "bad.txt")
+	}
+}
+
+// getEncoded fetches /files/name asking for encoding, and returns the
+// response with its body decompressed when it was gzipped
+func (ts *testServer) getEncoded(t *testing.T, name, encoding string, header ...string) (*http.Response, string) {
+	t.Helper()
+	req := ts.newRequest(t, http.MethodGet, "/files/"+name, nil)
+	// set by hand, the transport passes the compressed body through
+	req.Header.Set("Accept-Encoding", encoding)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, body := ts.do(t, req)
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return resp, string(body)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(plain)
+}
+
+func TestCompressDownloads(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex(), compressDownloads: true, compressMinBytes: 64})
+	text := strings.Repeat("a compressible line of text\n", 20)
+	ts.uploadFile(t, "notes.txt", text)
+	ts.uploadFile(t, "data.json", `{"items": [`+strings.Repeat(`"item", `, 20)+`"last"]}`)
+	ts.uploadFile(t, "photo.jpg", strings.Repeat("\xff\xd8", 100))
+	ts.uploadFile(t, "bundle.zip", "PK\x03\x04"+strings.Repeat("z", 100))
+	ts.uploadFile(t, "tiny.txt", "short")
+
+	for _, tc := range []struct {
+		name     string
+		encoding string
+		gzipped  bool
+	}{
+		{"notes.txt", "gzip", true},
+		{"data.json", "br, gzip;q=0.5", true},
+		{"notes.txt", "", false},
+		{"notes.txt", "gzip;q=0", false},
+		{"photo.jpg", "gzip", false},
+		{"bundle.zip", "gzip", false},
+		{"tiny.txt", "gzip", false}, // below compressMinBytes
+	} {
+		resp, body := ts.getEncoded(t, tc.name, tc.encoding)
+		if resp.StatusCode != http.StatusOK || body != ts.stored(t, tc.name) {
+			t.Errorf("%s with %q: status %d, body %q", tc.name, tc.encoding, resp.StatusCode, body)
+			continue
+		}
+		if gzipped := resp.Header.Get("Content-Encoding") == "gzip"; gzipped != tc.gzipped {
+			t.Errorf("%s with %q: gzipped %v, want %v", tc.name, tc.encoding, gzipped, tc.gzipped)
+		}
+		if tc.gzipped && !strings.HasPrefix(resp.Header.Get("ETag"), `W/"`) {
+			t.Errorf("%s: gzipped with a strong ETag %s", tc.name, resp.Header.Get("ETag"))
+		}
+	}
+
+	// ranges are of the stored bytes
+	resp, body := ts.getEncoded(t, "notes.txt", "gzip", "Range", "bytes=0-9")
+	if resp.StatusCode != http.StatusPartialContent || resp.Header.Get("Content-Encoding") != "" || body != text[:10] {
+		t.Fatalf("ranged GET: %d %q encoded %q", resp.StatusCode, body, resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestCompressDownloadsOff(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex(), compressMinBytes: 64})
+	ts.uploadFile(t, "notes.txt", strings.Repeat("text\n", 100))
+	if resp, _ := ts.getEncoded(t, "notes.txt", "gzip"); resp.Header.Get("Content-Encoding") != "" {
+		t.Fatal("download gzipped without CompressDownloads")
+	}
+}
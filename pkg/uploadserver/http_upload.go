@@ -0,0 +1,234 @@
+package uploadserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// maxFormFieldSize bounds the non-file fields of a multipart upload
+const maxFormFieldSize = 4096
+
+// writeFile stores r at path while hashing it in a single pass and returns
+// the number of bytes written with their hex-encoded SHA-256. The file is
+// removed again if it grows past the maximum file size. Errors are connect errors.
+func (s *Server) writeFile(ctx context.Context, path string, r io.Reader) (n int64, hash string, err error) {
+	// the content is hashed as it is written
+	_, span := startPhase(ctx, "write")
+	defer func() { endPhase(span, err) }()
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+	defer release()
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, "", connect.NewError(connect.CodeInternal, err)
+	}
+	if s.maxFileSize > 0 {
+		r = io.LimitReader(r, s.maxFileSize+1)
+	}
+	hasher := sha256.New()
+	var w io.Writer = f
+	if s.sync == SyncPerChunk {
+		w = chunkSyncer{f}
+	}
+	n, err = io.Copy(w, io.TeeReader(r, hasher))
+	if err == nil {
+		err = s.syncCommit(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.checkFileSize(n)
+	} else {
+		err = writeError(err)
+	}
+	if err != nil {
+		os.Remove(path)
+		return n, "", err
+	}
+	return n, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// handleMultipartUpload accepts multipart/form-data uploads from curl and HTML
+// forms: a "file" part plus optional "title" and "sha256" fields. The file is
+// streamed to disk and answered with the same JSON body as the UploadFile RPC.
+func (s *Server) handleMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	var (
+		filename string
+		size     int64
+		resp     *fileuploadv1.UploadResponse
+		err      error
+	)
+	defer func() {
+		s.recordUpload(r.Context(), "multipart", filename, resp.GetStoredFilename(), size, resp.GetHashOk(), err)
+	}()
+
+	if err = s.checkUploadable(); err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	resp, filename, size, err = s.receiveMultipart(r)
+	if err == nil {
+		err = s.uploaded(r.Context(), "multipart", filename, resp.StoredFilename, size, resp.Sha256)
+	}
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		writeHTTPError(w, connect.NewError(connect.CodeInternal, err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func (s *Server) receiveMultipart(r *http.Request) (*fileuploadv1.UploadResponse, string, int64, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", 0, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	var (
+		filename, stored, title, clientHash, serverHash string
+		staged                                          string
+		size                                            int64
+		gotFile                                         bool
+	)
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, filename, size, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+
+		switch part.FormName() {
+		case "title":
+			if title, err = readFormField(part); err == nil {
+				if err := s.checkTitle(title); err != nil {
+					if gotFile {
+						os.Remove(staged)
+					}
+					return nil, filename, size, err
+				}
+			}
+		case "sha256":
+			clientHash, err = readFormField(part)
+		case "file":
+			if gotFile {
+				return nil, filename, size, connect.NewError(connect.CodeInvalidArgument, errors.New("only one file per request"))
+			}
+			gotFile = true
+			filename = sanitizeFilename(part.FileName())
+			if err := s.checkExtension(r.Context(), filename); err != nil {
+				return nil, filename, size, err
+			}
+			stored = s.storedName(r.Context(), filename)
+			if err := s.checkRetention(stored); err != nil {
+				return nil, filename, size, err
+			}
+			if staged, err = s.stagedPath(stored); err != nil {
+				return nil, filename, size, connect.NewError(connect.CodeInternal, err)
+			}
+			size, serverHash, err = s.writeFile(r.Context(), staged, part)
+			if err != nil {
+				return nil, filename, size, err
+			}
+		}
+		part.Close()
+		if err != nil {
+			return nil, filename, size, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+	}
+	if !gotFile {
+		return nil, "", 0, connect.NewError(connect.CodeInvalidArgument, errors.New("missing \"file\" part"))
+	}
+	if err := s.placeStored(staged, stored, serverHash); err != nil {
+		return nil, filename, size, err
+	}
+
+	hashOk := serverHash == clientHash
+	log.Printf("Multipart upload: %s (title: %s, %d bytes)", s.redact.name(filename), s.redact.title(title), size)
+	log.Printf("Hash verification - Server: %s, Client: %s, OK: %v", s.redact.hash(serverHash), s.redact.hash(clientHash), hashOk)
+
+	return &fileuploadv1.UploadResponse{
+		Message:        "ok",
+		Size:           size,
+		HashOk:         hashOk,
+		HashStatus:     hashStatus(serverHash, clientHash),
+		Sha256:         serverHash,
+		StoredFilename: baseName(stored),
+		StoragePath:    s.files.relPath(stored),
+	}, filename, size, nil
+}
+
+// readFormField reads a small non-file multipart field
+func readFormField(part io.Reader) (string, error) {
+	b, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxFormFieldSize {
+		return "", errors.New("form field too large")
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// writeHTTPError answers a plain-HTTP request with a problem body and the
+// status matching a connect error code
+func writeHTTPError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch connect.CodeOf(err) {
+	case connect.CodeInvalidArgument, connect.CodeOutOfRange:
+		status = http.StatusBadRequest
+	case connect.CodeNotFound:
+		status = http.StatusNotFound
+	case connect.CodeAlreadyExists:
+		status = http.StatusConflict
+	case connect.CodeFailedPrecondition:
+		status = http.StatusPreconditionFailed
+	case connect.CodePermissionDenied:
+		status = http.StatusForbidden
+	case connect.CodeUnauthenticated:
+		status = http.StatusUnauthorized
+	case connect.CodeResourceExhausted:
+		status = http.StatusRequestEntityTooLarge
+		switch {
+		case errors.Is(err, errDiskFull), errors.Is(err, errStorageNearlyFull):
+			status = http.StatusInsufficientStorage
+		case errors.Is(err, errTooManySessions), errors.Is(err, errTooManyUploads):
+			status = http.StatusTooManyRequests
+		case errors.Is(err, errTooManyOpenFiles):
+			status = http.StatusServiceUnavailable
+		}
+	case connect.CodeUnavailable:
+		status = http.StatusServiceUnavailable
+	case connect.CodeDeadlineExceeded:
+		status = http.StatusGatewayTimeout
+	}
+	msg := err.Error()
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		msg = connectErr.Message()
+	}
+	writeProblem(w, status, connect.CodeOf(err).String(), msg)
+}
@@ -0,0 +1,87 @@
+package uploadserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// multipartBody builds a form with fields and, unless name is empty, a
+// "file" part holding data
+func multipartBody(t *testing.T, name, data string, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	if name != "" {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(data))
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, mw.FormDataContentType()
+}
+
+func postMultipart(s *Server, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	s.handleMultipartUpload(rec, req)
+	return rec
+}
+
+func TestMultipartUpload(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	body, ct := multipartBody(t, "../form.txt", "from a form", map[string]string{"title": "T", "sha256": sha256Hex("from a form")})
+	rec := postMultipart(ts.srv, body, ct)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Size   string `json:"size"`
+		HashOk bool   `json:"hashOk"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: %v", rec.Body, err)
+	}
+	if resp.Size != "11" || !resp.HashOk {
+		t.Errorf("response %s", rec.Body)
+	}
+	if got := ts.stored(t, "form.txt"); got != "from a form" {
+		t.Errorf("stored %q", got)
+	}
+
+	// a wrong hash still stores the file but reports it
+	body, ct = multipartBody(t, "b.txt", "data", map[string]string{"sha256": sha256Hex("other")})
+	if rec := postMultipart(ts.srv, body, ct); rec.Code != http.StatusOK || bytes.Contains(rec.Body.Bytes(), []byte("hashOk")) {
+		t.Errorf("upload with a wrong hash: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestMultipartUploadRejectsBadForms(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	body, ct := multipartBody(t, "", "", map[string]string{"title": "no file"})
+	if rec := postMultipart(ts.srv, body, ct); rec.Code != http.StatusBadRequest {
+		t.Errorf("form without a file: %d, want 400", rec.Code)
+	}
+	body, ct = multipartBody(t, "a.txt", "x", map[string]string{"title": string(make([]byte, maxFormFieldSize+1))})
+	if rec := postMultipart(ts.srv, body, ct); rec.Code != http.StatusBadRequest {
+		t.Errorf("oversized field: %d, want 400", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader([]byte("plain")))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	ts.srv.handleMultipartUpload(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("non-multipart body: %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,262 @@
+package uploadserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// idempotencyHeader carries a key the client chooses for one upload and
+// sends with every request of it: PUTs and chunked UploadFile calls. A
+// request repeating the key of a completed upload is answered with its
+// stored result instead of writing again, one reconnecting to an incomplete
+// upload learns its offset and resumes there.
+const idempotencyHeader = "Idempotency-Key"
+
+// keysDir holds, inside partialDir, one file per idempotency key
+const keysDir = ".keys"
+
+// maxIdempotencyKeyLen bounds the keys clients may choose
+const maxIdempotencyKeyLen = 255
+
+// errKeyedHashMismatch is the cause of the DataLoss error refusing to store a
+// keyed upload whose content does not have the hash it declared
+var errKeyedHashMismatch = errors.New("checksum mismatch, the upload was discarded: start it over")
+
+// keyedUpload is the persisted state of an idempotency key
+type keyedUpload struct {
+	// Key is the caller's scope, "/" and the key it sent
+	Key      string `json:"key"`
+	Filename string `json:"filename"`
+	// SHA256 is the hash the upload declared, it is only stored with it
+	SHA256 string `json:"sha256"`
+	// Result is the UploadResponse of the completed upload, as JSON
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// idempotencyKeys maps idempotency keys to their uploads, saved in dir so
+// they survive restarts
+type idempotencyKeys struct {
+	mu   sync.Mutex
+	keys map[string]*keyedUpload
+	dir  string // keysDir, nothing is saved when empty
+	sync bool   // flush every saved key
+}
+
+// path is the file of key, named after its hash since clients choose keys freely
+func (k *idempotencyKeys) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(k.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get returns the upload of key, loading it the first time after a restart,
+// or nil when there is none. Callers hold k.mu.
+func (k *idempotencyKeys) get(key string) *keyedUpload {
+	if u, ok := k.keys[key]; ok {
+		return u
+	}
+	if k.dir == "" {
+		return nil
+	}
+	b, err := os.ReadFile(k.path(key))
+	if err != nil {
+		return nil
+	}
+	var u keyedUpload
+	if err := json.Unmarshal(b, &u); err != nil || u.Key != key {
+		os.Remove(k.path(key))
+		return nil
+	}
+	if k.keys == nil {
+		k.keys = make(map[string]*keyedUpload)
+	}
+	k.keys[key] = &u
+	return &u
+}
+
+// save persists u, replacing its previous state atomically. Callers hold k.mu.
+func (k *idempotencyKeys) save(u *keyedUpload) error {
+	if k.dir == "" {
+		return nil
+	}
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(k.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(k.path(u.Key), b, k.sync)
+}
+
+// begin returns the upload of key, recording a new one of filename with the
+// declared hash when unknown. A key already used for another file or
+// content is FailedPrecondition.
+func (k *idempotencyKeys) begin(key, filename, hash string) (keyedUpload, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if u := k.get(key); u != nil {
+		if u.Filename != filename {
+			return keyedUpload{}, connect.NewError(connect.CodeFailedPrecondition,
+				fmt.Errorf("%s was already used for another file", idempotencyHeader))
+		}
+		if hash != "" && hash != u.SHA256 {
+			return keyedUpload{}, connect.NewError(connect.CodeFailedPrecondition,
+				fmt.Errorf("%s was already used for content with another hash", idempotencyHeader))
+		}
+		if k.dir != "" {
+			// expire counts from the last use
+			now := time.Now()
+			os.Chtimes(k.path(key), now, now)
+		}
+		return *u, nil
+	}
+	if hash == "" {
+		return keyedUpload{}, connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("an upload with an %s must declare its SHA-256", idempotencyHeader))
+	}
+	u := &keyedUpload{Key: key, Filename: filename, SHA256: hash}
+	if err := k.save(u); err != nil {
+		return keyedUpload{}, connect.NewError(connect.CodeInternal, fmt.Errorf("save idempotency key: %w", err))
+	}
+	if k.keys == nil {
+		k.keys = make(map[string]*keyedUpload)
+	}
+	k.keys[key] = u
+	return *u, nil
+}
+
+// lookup returns the upload of key
+func (k *idempotencyKeys) lookup(key string) (keyedUpload, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if u := k.get(key); u != nil {
+		return *u, true
+	}
+	return keyedUpload{}, false
+}
+
+// complete records resp as the result of key
+func (k *idempotencyKeys) complete(key string, resp *fileuploadv1.UploadResponse) error {
+	result, err := protojson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	u := k.get(key)
+	if u == nil {
+		return nil
+	}
+	u.Result = result
+	return k.save(u)
+}
+
+// expire forgets the keys not used for longer than maxAge, along with the
+// results of their uploads
+func (k *idempotencyKeys) expire(maxAge time.Duration) {
+	if k.dir == "" {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entries, err := readDirIfExists(k.dir)
+	if err != nil {
+		log.Printf("Janitor: cannot list idempotency keys: %v", err)
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || time.Since(info.ModTime()) <= maxAge {
+			continue
+		}
+		path := filepath.Join(k.dir, e.Name())
+		if b, err := os.ReadFile(path); err == nil {
+			var u keyedUpload
+			if json.Unmarshal(b, &u) == nil {
+				delete(k.keys, u.Key)
+			}
+		}
+		os.Remove(path)
+	}
+}
+
+// idempotencyKey returns the key of a request scoped to the caller, so
+// callers never see each other's uploads, or "" without one
+func idempotencyKey(ctx context.Context, h http.Header) (string, error) {
+	key := h.Get(idempotencyHeader)
+	if key == "" {
+		return "", nil
+	}
+	if len(key) > maxIdempotencyKeyLen || strings.ContainsFunc(key, func(r rune) bool { return r < 0x20 || r > 0x7e }) {
+		return "", connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("%s must be at most %d printable ASCII characters", idempotencyHeader, maxIdempotencyKeyLen))
+	}
+	return callerScope(ctx) + "/" + key, nil
+}
+
+// rpcHeader returns the request headers of the RPC handling ctx
+func rpcHeader(ctx context.Context) http.Header {
+	if info, ok := connect.CallInfoForHandlerContext(ctx); ok {
+		return info.RequestHeader()
+	}
+	return nil
+}
+
+// beginKeyed looks up the idempotency key of a request uploading filename
+// with the declared hash, recording it when unknown. It returns a zero
+// keyedUpload for requests without a key.
+func (s *Server) beginKeyed(ctx context.Context, h http.Header, filename, hash string) (keyedUpload, error) {
+	key, err := idempotencyKey(ctx, h)
+	if err != nil || key == "" {
+		return keyedUpload{}, err
+	}
+	return s.keys.begin(key, filename, hash)
+}
+
+// keyedResult decodes the stored result of a completed keyed upload
+func keyedResult(u keyedUpload) (*fileuploadv1.UploadResponse, error) {
+	var resp fileuploadv1.UploadResponse
+	if err := protojson.Unmarshal(u.Result, &resp); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("stored result of %s: %w", idempotencyHeader, err))
+	}
+	return &resp, nil
+}
+
+// completeKeyed records resp as the result of a keyed upload, a no-op for
+// uploads without a key. The file is stored already: failing to record it
+// is only logged, a retry then uploads it again. A partial file and ranges
+// earlier attempts of the upload left behind, such as ranges sent before a
+// whole body, are removed now rather than by the janitor.
+func (s *Server) completeKeyed(ctx context.Context, u keyedUpload, resp *fileuploadv1.UploadResponse) {
+	if u.Key == "" {
+		return
+	}
+	if err := s.keys.complete(u.Key, resp); err != nil {
+		log.Printf("Failed to save idempotency key of %s: %v", s.redact.name(u.Filename), s.redact.err(err))
+	}
+	name := scopedName(ctx, u.Filename)
+	s.ranged.mu.Lock()
+	defer s.ranged.mu.Unlock()
+	s.ranged.forget(name)
+	if err := os.Remove(filepath.Join(s.dir, partialDir, name)); err == nil {
+		log.Printf("Removed the partial upload of %s, stored under its %s", s.redact.name(u.Filename), idempotencyHeader)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Cannot remove the partial upload of %s: %v", s.redact.name(u.Filename), s.redact.err(err))
+	}
+}
@@ -0,0 +1,292 @@
+package uploadserver
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// putKeyed PUTs body to /files/{name} under the idempotency key, declaring
+// the hash of the whole file, with the Content-Range header cr
+func (ts *testServer) putKeyed(t *testing.T, key, hash, name, cr, body string) (*http.Response, []byte) {
+	t.Helper()
+	req := ts.newRequest(t, http.MethodPut, "/files/"+name, strings.NewReader(body))
+	req.Header.Set(idempotencyHeader, key)
+	if hash != "" {
+		req.Header.Set(contentHashHeader, hash)
+	}
+	if cr != "" {
+		req.Header.Set("Content-Range", cr)
+	}
+	return ts.do(t, req)
+}
+
+// restart drops the in-memory upload state, as a restarted server over the
+// same directory starts with none
+func (ts *testServer) restart() {
+	ts.srv.ranged.mu.Lock()
+	ts.srv.ranged.uploads = nil
+	ts.srv.ranged.mu.Unlock()
+	ts.srv.keys.mu.Lock()
+	ts.srv.keys.keys = nil
+	ts.srv.keys.mu.Unlock()
+}
+
+func TestKeyedPutResumesAfterRestart(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	hash := sha256Hex("0123456789")
+	if resp, _ := ts.putKeyed(t, "k1", hash, "r.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("first range: status %d", resp.StatusCode)
+	}
+
+	// the client reconnects with its key and asks where to resume
+	ts.restart()
+	resp, _ := ts.putKeyed(t, "k1", "", "r.bin", "bytes */*", "")
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Range") != "bytes=0-4" {
+		t.Fatalf("status query: status %d, Range %q", resp.StatusCode, resp.Header.Get("Range"))
+	}
+	resp, stored := ts.putKeyed(t, "k1", hash, "r.bin", "bytes 5-9/*", "56789")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("last range: status %d, body %s", resp.StatusCode, stored)
+	}
+
+	// a retry of the completing request, after another restart, is answered
+	// with the stored result and writes nothing
+	ts.restart()
+	resp, replayed := ts.putKeyed(t, "k1", hash, "r.bin", "bytes 5-9/*", "XXXXX")
+	if resp.StatusCode != http.StatusCreated || string(replayed) != string(stored) {
+		t.Fatalf("retry: status %d, body %s, want %s", resp.StatusCode, replayed, stored)
+	}
+	if got := ts.stored(t, "r.bin"); got != "0123456789" {
+		t.Fatalf("stored %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, partialDir, defaultScope, "r.bin")); !os.IsNotExist(err) {
+		t.Fatalf("a retry left a partial file: %v", err)
+	}
+}
+
+func TestRangesSurviveRestart(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if _, err := ts.uploadChunk(t.Context(), "c.txt", 0, "hello", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	ts.restart()
+	status, err := ts.client.GetUploadStatus(t.Context(), &fileuploadv1.GetUploadStatusRequest{Filename: "c.txt"})
+	if err != nil || status.Offset != 5 {
+		t.Fatalf("status after a restart %v, %v, want offset 5", status, err)
+	}
+	if _, err := ts.uploadChunk(t.Context(), "c.txt", 5, " world", true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.stored(t, "c.txt"); got != "hello world" {
+		t.Fatalf("stored %q", got)
+	}
+	// the saved ranges go with the completed upload
+	if _, err := os.Stat(filepath.Join(ts.dir, partialDir, rangesDir, defaultScope, "c.txt.json")); !os.IsNotExist(err) {
+		t.Fatalf("ranges of a completed upload left behind: %v", err)
+	}
+}
+
+func TestKeyedPutRefusesHashMismatch(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	wrong := sha256Hex("something else")
+	if resp, _ := ts.putKeyed(t, "k1", wrong, "m.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("first range: status %d", resp.StatusCode)
+	}
+	if resp, _ := ts.putKeyed(t, "k1", "", "m.bin", "bytes 5-9/*", "56789"); resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("last range: status %d, want data loss", resp.StatusCode)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "m.bin")); !os.IsNotExist(err) {
+		t.Fatalf("content with another hash was stored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, partialDir, defaultScope, "m.bin")); !os.IsNotExist(err) {
+		t.Fatalf("content with another hash left its partial file: %v", err)
+	}
+
+	// the whole body of a keyed PUT is checked against its header too
+	if resp, _ := ts.putKeyed(t, "k2", wrong, "w.bin", "", "content"); resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("whole body: status %d, want data loss", resp.StatusCode)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "w.bin")); !os.IsNotExist(err) {
+		t.Fatalf("content with another hash was stored: %v", err)
+	}
+}
+
+func TestKeyedUploadRequirements(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	hash := sha256Hex("0123456789")
+	if resp, _ := ts.putKeyed(t, "k0", "", "a.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("key without a hash: status %d, want 400", resp.StatusCode)
+	}
+	if resp, _ := ts.putKeyed(t, strings.Repeat("k", maxIdempotencyKeyLen+1), hash, "a.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("key too long: status %d, want 400", resp.StatusCode)
+	}
+	if resp, _ := ts.putKeyed(t, "k1", hash, "a.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("first range: status %d", resp.StatusCode)
+	}
+	if resp, _ := ts.putKeyed(t, "k1", hash, "b.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("key of a.bin used for b.bin: status %d, want 412", resp.StatusCode)
+	}
+	if resp, _ := ts.putKeyed(t, "k1", sha256Hex("other"), "a.bin", "bytes 5-9/*", "56789"); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("key declaring another hash: status %d, want 412", resp.StatusCode)
+	}
+}
+
+func TestKeysArePerCaller(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	hash := sha256Hex("hello")
+	first, err := ts.srv.beginKeyed(asCaller(t.Context(), "alice"), http.Header{idempotencyHeader: {"k"}}, "a.txt", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.srv.completeKeyed(asCaller(t.Context(), "alice"), first, &fileuploadv1.UploadResponse{Size: 5})
+	// the same key sent by another client names another upload
+	other, err := ts.srv.beginKeyed(asCaller(t.Context(), "bob"), http.Header{idempotencyHeader: {"k"}}, "b.txt", hash)
+	if err != nil || other.Result != nil || other.Key == first.Key {
+		t.Fatalf("key of bob %+v, %v, want a new upload", other, err)
+	}
+}
+
+func TestKeyedUploadFileChunks(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	hash := sha256Hex("hello world")
+	ctx, info := connect.NewClientContext(t.Context())
+	info.RequestHeader().Set(idempotencyHeader, "chunks")
+	send := func(offset int64, data string, last bool) (*fileuploadv1.UploadResponse, error) {
+		return ts.client.UploadFile(ctx, &fileuploadv1.UploadFileRequest{
+			Filename: "c.txt",
+			Sha256:   hash,
+			Data:     []byte(data),
+			Offset:   proto.Int64(offset),
+			IsLast:   last,
+		})
+	}
+	if _, err := send(0, "hello", false); err != nil {
+		t.Fatal(err)
+	}
+	first, err := send(5, " world", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the answer was lost: the key reports every byte in and a retry of
+	// the last chunk returns the same result
+	ts.restart()
+	status, err := ts.client.GetUploadStatus(ctx, &fileuploadv1.GetUploadStatusRequest{Filename: "c.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Offset != 11 || status.GetTotalSize() != 11 {
+		t.Fatalf("status %v, want every byte in", status)
+	}
+	again, err := send(10, "d", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(first, again) {
+		t.Fatalf("retry answered %v, want %v", again, first)
+	}
+	if got := ts.stored(t, "c.txt"); got != "hello world" {
+		t.Fatalf("stored %q", got)
+	}
+
+	// without the key the upload is no longer in progress
+	status, err = ts.client.GetUploadStatus(t.Context(), &fileuploadv1.GetUploadStatusRequest{Filename: "c.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Offset != 0 {
+		t.Fatalf("status without the key %v, want nothing in progress", status)
+	}
+}
+
+func TestJanitorExpiresKeys(t *testing.T) {
+	ts := newTestServer(t, &Server{partialMaxAge: time.Hour})
+	if resp, _ := ts.putKeyed(t, "old", sha256Hex("old"), "old.txt", "", "old"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status %d", resp.StatusCode)
+	}
+	if resp, _ := ts.putKeyed(t, "new", sha256Hex("new"), "new.txt", "", "new"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status %d", resp.StatusCode)
+	}
+	oldKey, _ := idempotencyKey(t.Context(), http.Header{idempotencyHeader: {"old"}})
+	newKey, _ := idempotencyKey(t.Context(), http.Header{idempotencyHeader: {"new"}})
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(ts.srv.keys.path(oldKey), past, past); err != nil {
+		t.Fatal(err)
+	}
+	ts.srv.sweepPartials()
+	if _, ok := ts.srv.keys.lookup(oldKey); ok {
+		t.Error("a key idle for longer than the partial max age was kept")
+	}
+	if _, ok := ts.srv.keys.lookup(newKey); !ok {
+		t.Error("a recent key was expired")
+	}
+}
+
+func TestKeyedUploadRemovesPartials(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	hash := sha256Hex("0123456789")
+	if resp, _ := ts.putKeyed(t, "k1", hash, "p.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("first range: status %d", resp.StatusCode)
+	}
+	// the client gives up on ranges and sends the whole body under the same key
+	if resp, body := ts.putKeyed(t, "k1", hash, "p.bin", "", "0123456789"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("whole body: status %d, body %s", resp.StatusCode, body)
+	}
+	for _, p := range []string{
+		filepath.Join(ts.dir, partialDir, defaultScope, "p.bin"),
+		ts.srv.ranged.statePath(scopedName(t.Context(), "p.bin")),
+	} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s left after the keyed upload completed: %v", p, err)
+		}
+	}
+	if state := ts.srv.ranged.status(scopedName(t.Context(), "p.bin")); len(state.ranges) != 0 {
+		t.Fatalf("ranges %v kept after the keyed upload completed", state.ranges)
+	}
+	if got := ts.stored(t, "p.bin"); got != "0123456789" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestUnkeyedUploadKeepsPartials(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if resp, _ := ts.putKeyed(t, "k1", sha256Hex("0123456789"), "p.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("first range: status %d", resp.StatusCode)
+	}
+	// a whole body without the key is another upload, the janitor collects
+	// the keyed one if it is abandoned
+	req := ts.newRequest(t, http.MethodPut, "/files/p.bin", strings.NewReader("other"))
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("unkeyed body: status %d, body %s", resp.StatusCode, body)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, partialDir, defaultScope, "p.bin")); err != nil {
+		t.Fatalf("partial file of the keyed upload removed: %v", err)
+	}
+}
+
+func TestKeyedUploadKeepsOtherCallersPartials(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	alice, bob := asCaller(t.Context(), "alice"), asCaller(t.Context(), "bob")
+	if _, err := ts.srv.writeRange(alice, "p.bin", -1, byteRange{0, 4}, strings.NewReader("01234")); err != nil {
+		t.Fatal(err)
+	}
+	keyed, err := ts.srv.beginKeyed(bob, http.Header{idempotencyHeader: {"k"}}, "p.bin", sha256Hex("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.srv.completeKeyed(bob, keyed, &fileuploadv1.UploadResponse{Size: 3})
+	if _, err := os.Stat(filepath.Join(ts.dir, partialDir, scopedName(alice, "p.bin"))); err != nil {
+		t.Fatalf("partial file of alice removed by the upload of bob: %v", err)
+	}
+	if state := ts.srv.ranged.status(scopedName(alice, "p.bin")); state.contiguous() != 5 {
+		t.Fatalf("ranges of alice %v, want bytes 0-4 kept", state.ranges)
+	}
+}
@@ -0,0 +1,187 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"connectrpc.com/connect"
+)
+
+// maxTrackedIdentities bounds the distinct identities or namespaces published
+// in identityUploads and namespaceUploads; uploads of any further one are
+// counted under "other"
+const maxTrackedIdentities = 100
+
+// identityUploads and namespaceUploads are the number of uploads in progress
+// per client identity and per namespace, published on /debug/vars. Entries
+// without uploads are dropped.
+var (
+	identityUploads  = expvar.NewMap("uploadserver_identity_uploads")
+	namespaceUploads = expvar.NewMap("uploadserver_namespace_uploads")
+)
+
+// errTooManyUploads is the cause of the ResourceExhausted error refusing an
+// upload over Config.MaxUploadsPerIdentity or Config.MaxUploadsPerNamespace
+var errTooManyUploads = errors.New("too many concurrent uploads")
+
+// callerLimiter caps the uploads in progress per key of the caller: its
+// certificate identity, or the namespace grouping several identities of one
+// tenant. Callers without a key are not limited. A nil callerLimiter allows
+// everything.
+type callerLimiter struct {
+	max     int
+	key     func(caller) string
+	what    string // how the key is named in errors, "" for an identity
+	uploads *expvar.Map
+	mu      sync.Mutex
+	active  map[string]int
+	metric  map[string]string // key to its key in uploads
+}
+
+func newCallerLimiter(max int, key func(caller) string, what string, uploads *expvar.Map) *callerLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &callerLimiter{max: max, key: key, what: what, uploads: uploads, active: make(map[string]int), metric: make(map[string]string)}
+}
+
+// newIdentityLimiter caps the uploads per certificate identity
+func newIdentityLimiter(max int) *callerLimiter {
+	return newCallerLimiter(max, func(c caller) string { return c.identity }, "", identityUploads)
+}
+
+// newNamespaceLimiter caps the uploads per certificate namespace
+func newNamespaceLimiter(max int) *callerLimiter {
+	return newCallerLimiter(max, func(c caller) string { return c.namespace }, "namespace ", namespaceUploads)
+}
+
+// acquire counts an upload for the caller in ctx and returns the function
+// ending it, or CodeResourceExhausted when the caller is at the limit
+func (l *callerLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	name := l.key(callerFrom(ctx))
+	if name == "" {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[name] >= l.max {
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("%w: %s%s already has %d in progress", errTooManyUploads, l.what, name, l.max))
+	}
+	key, ok := l.metric[name]
+	if !ok {
+		key = name
+		if len(l.metric) >= maxTrackedIdentities {
+			key = "other"
+		}
+		l.metric[name] = key
+	}
+	l.active[name]++
+	l.uploads.Add(key, 1)
+	return func() { l.release(name, key) }, nil
+}
+
+func (l *callerLimiter) release(name, key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.uploads.Add(key, -1)
+	if l.active[name]--; l.active[name] > 0 {
+		return
+	}
+	delete(l.active, name)
+	delete(l.metric, name)
+	if key == name {
+		l.uploads.Delete(key)
+	}
+}
+
+// callerLimiters applies several limits, all of which must admit an upload
+type callerLimiters []*callerLimiter
+
+// newCallerLimiters keeps the limits that are set
+func newCallerLimiters(limiters ...*callerLimiter) callerLimiters {
+	var ls callerLimiters
+	for _, l := range limiters {
+		if l != nil {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// acquire counts an upload against every limit, or none of them when one
+// refuses it
+func (ls callerLimiters) acquire(ctx context.Context) (func(), error) {
+	var releases []func()
+	releaseAll := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+	for _, l := range ls {
+		release, err := l.acquire(ctx)
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releaseAll, nil
+}
+
+// require guards a plain-HTTP upload handler with the limits
+func (ls callerLimiters) require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, err := ls.acquire(r.Context())
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		defer release()
+		next(w, r)
+	}
+}
+
+// identityLimitInterceptor applies the limits to the upload RPCs
+type identityLimitInterceptor struct {
+	limiter callerLimiters
+}
+
+func (i identityLimitInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if !uploadProcedures[req.Spec().Procedure] {
+			return next(ctx, req)
+		}
+		release, err := i.limiter.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return next(ctx, req)
+	}
+}
+
+func (i identityLimitInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i identityLimitInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if !uploadProcedures[conn.Spec().Procedure] {
+			return next(ctx, conn)
+		}
+		release, err := i.limiter.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return next(ctx, conn)
+	}
+}
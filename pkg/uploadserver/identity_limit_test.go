@@ -0,0 +1,247 @@
+package uploadserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+)
+
+// identityCount is the published number of uploads in progress of identity
+func identityCount(identity string) string {
+	if v := identityUploads.Get(identity); v != nil {
+		return v.String()
+	}
+	return ""
+}
+
+// asTenant is asCaller for identity in namespace
+func asTenant(ctx context.Context, namespace, identity string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller{identity: identity, namespace: namespace, names: []string{identity}})
+}
+
+func TestIdentityLimiter(t *testing.T) {
+	var unlimited *callerLimiter
+	if release, err := unlimited.acquire(asCaller(t.Context(), "alice")); err != nil {
+		t.Fatalf("nil limiter: %v", err)
+	} else {
+		release()
+	}
+	if newIdentityLimiter(0) != nil {
+		t.Fatal("a limit of 0 limits uploads")
+	}
+
+	l := newIdentityLimiter(2)
+	alice := asCaller(t.Context(), "limit-alice")
+	bob := asCaller(t.Context(), "limit-bob")
+	first, err := l.acquire(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := l.acquire(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.acquire(alice); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("third upload of the same identity: %v, want resource exhausted", err)
+	}
+	if got := identityCount("limit-alice"); got != "2" {
+		t.Fatalf("published count %q, want 2", got)
+	}
+	// other identities and anonymous callers are not affected
+	other, err := l.acquire(bob)
+	if err != nil {
+		t.Fatalf("upload of another identity: %v", err)
+	}
+	other()
+	for range 5 {
+		if _, err := l.acquire(t.Context()); err != nil {
+			t.Fatalf("anonymous upload: %v", err)
+		}
+	}
+
+	first()
+	third, err := l.acquire(alice)
+	if err != nil {
+		t.Fatalf("upload after one ended: %v", err)
+	}
+	second()
+	third()
+	if got := identityCount("limit-alice"); got != "" {
+		t.Fatalf("identity without uploads still published with %s", got)
+	}
+}
+
+func TestIdentityLimiterBoundsMetrics(t *testing.T) {
+	l := newIdentityLimiter(1)
+	var releases []func()
+	for i := range maxTrackedIdentities + 5 {
+		release, err := l.acquire(asCaller(t.Context(), fmt.Sprintf("many-%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+	if got := identityCount("other"); got != "5" {
+		t.Fatalf("identities past the bound counted as other: %q, want 5", got)
+	}
+	if identityUploads.Get(fmt.Sprintf("many-%d", maxTrackedIdentities)) != nil {
+		t.Fatal("an identity past the bound was published")
+	}
+	for _, release := range releases {
+		release()
+	}
+	if got := identityCount("other"); got != "0" {
+		t.Fatalf("other = %q after every upload ended", got)
+	}
+}
+
+func TestIdentityLimiterConcurrentIdentities(t *testing.T) {
+	const limit, requests = 3, 10
+	l := newIdentityLimiter(limit)
+	identities := []string{"concurrent-alice", "concurrent-bob"}
+
+	// the admitted requests hold their upload until every other one was answered
+	block := make(chan struct{})
+	var (
+		mu           sync.Mutex
+		active, peak = map[string]int{}, map[string]int{}
+		passed       = map[string]int{}
+		refused      sync.WaitGroup
+		wg           sync.WaitGroup
+	)
+	handler := newCallerLimiters(l).require(func(w http.ResponseWriter, r *http.Request) {
+		identity := callerFrom(r.Context()).identity
+		mu.Lock()
+		active[identity]++
+		peak[identity] = max(peak[identity], active[identity])
+		passed[identity]++
+		mu.Unlock()
+		<-block
+		mu.Lock()
+		active[identity]--
+		mu.Unlock()
+	})
+	refused.Add(len(identities) * (requests - limit))
+	for _, identity := range identities {
+		for range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequestWithContext(asCaller(t.Context(), identity), http.MethodPut, "/files/a", nil)
+				rec := httptest.NewRecorder()
+				handler(rec, req)
+				if rec.Code == http.StatusTooManyRequests {
+					refused.Done()
+				}
+			}()
+		}
+	}
+	refused.Wait()
+	close(block)
+	wg.Wait()
+	for _, identity := range identities {
+		if passed[identity] != limit || peak[identity] != limit {
+			t.Errorf("%s: %d uploads admitted, %d at once; want %d", identity, passed[identity], peak[identity], limit)
+		}
+	}
+}
+
+func TestCallerLimitersAllOrNothing(t *testing.T) {
+	ls := newCallerLimiters(newIdentityLimiter(1), nil, newNamespaceLimiter(2))
+	if len(ls) != 2 {
+		t.Fatalf("%d limits kept, want the 2 that are set", len(ls))
+	}
+	if len(newCallerLimiters(newIdentityLimiter(0), newNamespaceLimiter(0))) != 0 {
+		t.Fatal("limits of 0 were kept")
+	}
+
+	first, err := ls.acquire(asTenant(t.Context(), "all-acme", "all-alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// refused by the identity limit, so not counted against the namespace
+	if _, err := ls.acquire(asTenant(t.Context(), "all-acme", "all-alice")); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("second upload of the identity: %v, want resource exhausted", err)
+	}
+	second, err := ls.acquire(asTenant(t.Context(), "all-acme", "all-bob"))
+	if err != nil {
+		t.Fatalf("upload of another identity of the namespace: %v", err)
+	}
+	_, err = ls.acquire(asTenant(t.Context(), "all-acme", "all-carol"))
+	if connect.CodeOf(err) != connect.CodeResourceExhausted || !strings.Contains(err.Error(), "namespace all-acme") {
+		t.Fatalf("third upload of the namespace: %v, want resource exhausted naming it", err)
+	}
+	if v := namespaceUploads.Get("all-acme"); v == nil || v.String() != "2" {
+		t.Fatalf("published namespace count %v, want 2", v)
+	}
+	first()
+	second()
+	if namespaceUploads.Get("all-acme") != nil || identityCount("all-alice") != "" {
+		t.Fatal("counts still published after every upload ended")
+	}
+}
+
+func TestNamespaceLimiterConcurrentNamespaces(t *testing.T) {
+	const limit, identities = 3, 4
+	ls := newCallerLimiters(newNamespaceLimiter(limit))
+	namespaces := []string{"concurrent-acme", "concurrent-globex"}
+
+	// the admitted requests hold their upload until every other one was answered
+	block := make(chan struct{})
+	var (
+		mu           sync.Mutex
+		active, peak = map[string]int{}, map[string]int{}
+		passed       = map[string]int{}
+		refused      sync.WaitGroup
+		wg           sync.WaitGroup
+	)
+	handler := ls.require(func(w http.ResponseWriter, r *http.Request) {
+		namespace := callerFrom(r.Context()).namespace
+		mu.Lock()
+		active[namespace]++
+		peak[namespace] = max(peak[namespace], active[namespace])
+		passed[namespace]++
+		mu.Unlock()
+		<-block
+		mu.Lock()
+		active[namespace]--
+		mu.Unlock()
+	})
+	// each identity of a namespace sends two uploads, counted together
+	refused.Add(len(namespaces) * (2*identities - limit))
+	for _, namespace := range namespaces {
+		for i := range 2 * identities {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx := asTenant(t.Context(), namespace, fmt.Sprintf("%s-%d", namespace, i%identities))
+				req := httptest.NewRequestWithContext(ctx, http.MethodPut, "/files/a", nil)
+				rec := httptest.NewRecorder()
+				handler(rec, req)
+				if rec.Code == http.StatusTooManyRequests {
+					refused.Done()
+				}
+			}()
+		}
+	}
+	refused.Wait()
+	close(block)
+	wg.Wait()
+	for _, namespace := range namespaces {
+		if passed[namespace] != limit || peak[namespace] != limit {
+			t.Errorf("%s: %d uploads admitted, %d at once; want %d", namespace, passed[namespace], peak[namespace], limit)
+		}
+	}
+	// callers without a namespace are not limited
+	for range limit + 1 {
+		if _, err := ls.acquire(asCaller(t.Context(), "no-namespace")); err != nil {
+			t.Fatalf("upload without a namespace: %v", err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package uploadserver
+
+import (
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// conditionalUpload stores data as name with UploadFile only if the stored file has hash ifMatch
+func (ts *testServer) conditionalUpload(t *testing.T, name, data, ifMatch string, dryRun bool) error {
+	t.Helper()
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename:      name,
+		Data:          []byte(data),
+		Sha256:        sha256Hex(data),
+		DryRun:        dryRun,
+		IfMatchSha256: ifMatch,
+	})
+	return err
+}
+
+func TestIfMatch(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.uploadFile(t, "doc.txt", "v1")
+
+	if err := ts.conditionalUpload(t, "doc.txt", "v2", sha256Hex("other"), false); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Fatalf("overwrite with a stale hash: %v, want failed precondition", err)
+	}
+	if got := ts.stored(t, "doc.txt"); got != "v1" {
+		t.Fatalf("refused overwrite changed the file to %q", got)
+	}
+	if err := ts.conditionalUpload(t, "missing.txt", "v1", sha256Hex("v1"), false); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Fatalf("conditional upload of a missing file: %v, want failed precondition", err)
+	}
+	ts.assertNotStored(t, "missing.txt")
+
+	// a dry run checks without taking the file
+	if err := ts.conditionalUpload(t, "doc.txt", "v2", sha256Hex("v1"), true); err != nil {
+		t.Fatalf("dry run with the current hash: %v", err)
+	}
+	if err := ts.conditionalUpload(t, "doc.txt", "v2", sha256Hex("v1"), false); err != nil {
+		t.Fatalf("overwrite with the current hash: %v", err)
+	}
+	if got := ts.stored(t, "doc.txt"); got != "v2" {
+		t.Fatalf("stored %q, want v2", got)
+	}
+	// a second writer that read v1 lost the race
+	if err := ts.conditionalUpload(t, "doc.txt", "v3", sha256Hex("v1"), false); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Fatalf("overwrite of a changed file: %v, want failed precondition", err)
+	}
+}
+
+func TestIfMatchStreamingAndChunked(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.uploadFile(t, "doc.txt", "v1")
+
+	stream := func(ifMatch, data string) error {
+		_, err := ts.sendUpload(t,
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+				Metadata: &fileuploadv1.UploadMetadata{Filename: "doc.txt", IfMatchSha256: ifMatch},
+			}},
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte(data)}},
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex(data)}},
+		)
+		return err
+	}
+	if err := stream(sha256Hex("stale"), "v2"); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Fatalf("streamed overwrite with a stale hash: %v, want failed precondition", err)
+	}
+	if got := ts.stored(t, "doc.txt"); got != "v1" {
+		t.Fatalf("refused streamed overwrite left %q", got)
+	}
+	if err := stream(sha256Hex("v1"), "v2"); err != nil {
+		t.Fatalf("streamed overwrite with the current hash: %v", err)
+	}
+
+	// chunked UploadFile calls check the hash with the call completing the file
+	chunk := func(offset int64, data string, last bool, ifMatch string) error {
+		_, err := ts.srv.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+			Filename:      "doc.txt",
+			Data:          []byte(data),
+			Sha256:        sha256Hex("v3v3"),
+			Offset:        &offset,
+			IsLast:        last,
+			IfMatchSha256: ifMatch,
+		})
+		return err
+	}
+	if err := chunk(0, "v3", false, sha256Hex("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := chunk(2, "v3", true, sha256Hex("v1")); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Fatalf("completing chunk with a stale hash: %v, want failed precondition", err)
+	}
+	if err := chunk(2, "v3", true, sha256Hex("v2")); err != nil {
+		t.Fatalf("completing chunk with the current hash: %v", err)
+	}
+	if got := ts.stored(t, "doc.txt"); got != "v3v3" {
+		t.Fatalf("stored %q, want v3v3", got)
+	}
+}
+
+func TestIfMatchClaimsOnce(t *testing.T) {
+	x := newHashIndex()
+	x.set("doc.txt", sha256Hex("v1"))
+	if x.claim("doc.txt", sha256Hex("v2")) {
+		t.Fatal("claimed with the wrong hash")
+	}
+	if !x.claim("doc.txt", sha256Hex("v1")) {
+		t.Fatal("claim with the current hash failed")
+	}
+	if x.claim("doc.txt", sha256Hex("v1")) {
+		t.Fatal("two concurrent overwrites both claimed the file")
+	}
+}
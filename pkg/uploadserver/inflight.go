@@ -0,0 +1,129 @@
+package uploadserver
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// inflight coalesces concurrent uploads declaring the same content: the
+// first one stores it while the others wait for it, then link to its file
+// rather than writing the content again.
+type inflight struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+type inflightCall struct {
+	done    chan struct{}
+	waiters int // callers waiting for the leader, guarded by inflight.mu
+	blob    blob
+	err     error
+}
+
+// blob is the file an upload stored its content in
+type blob struct {
+	path string
+	info os.FileInfo
+}
+
+// begin returns the call storing the content of key, and whether the caller
+// leads it: the leader stores the content and must end the call.
+func (g *inflight) begin(key string) (c *inflightCall, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls == nil {
+		g.calls = make(map[string]*inflightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		c.waiters++
+		return c, false
+	}
+	c = &inflightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	return c, true
+}
+
+// end hands the file the leader of c stored, or the error it failed with,
+// to the callers waiting for it
+func (g *inflight) end(key string, c *inflightCall, b blob, err error) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	c.blob, c.err = b, err
+	close(c.done)
+}
+
+// wait blocks until the leader of c ends the call
+func (c *inflightCall) wait(ctx context.Context) (blob, error) {
+	select {
+	case <-c.done:
+		return c.blob, c.err
+	case <-ctx.Done():
+		return blob{}, ctx.Err()
+	}
+}
+
+// shareContent lets the uploads concurrently declaring content with hash
+// store it once. The first one leads: shareContent returns false and a
+// finish function to call with the name it stored the content under, or the
+// error it failed with. The others wait for the leader, then store the
+// content at path by linking it to the leader's file and get true. When the
+// leader failed, or its file was replaced since, they get false too and
+// store their content themselves; finish does nothing for them.
+func (s *Server) shareContent(ctx context.Context, hash, path string) (shared bool, finish func(name string, err error), err error) {
+	c, leader := s.writes.begin(hash)
+	if leader {
+		return false, func(name string, err error) {
+			var b blob
+			if err == nil {
+				b.path = s.files.path(name)
+				b.info, err = os.Stat(b.path)
+			}
+			s.writes.end(hash, c, b, err)
+		}, nil
+	}
+	b, err := c.wait(ctx)
+	if ctx.Err() != nil {
+		return false, nil, contextError(ctx)
+	}
+	finish = func(string, error) {}
+	if err != nil {
+		return false, finish, nil
+	}
+	return s.linkBlob(b, path), finish, nil
+}
+
+// linkBlob stores the content of b at path, as a hard link when the
+// filesystem allows it and as a copy otherwise. It stores nothing and
+// returns false when b was replaced or removed since it was stored, so its
+// content is no longer known.
+func (s *Server) linkBlob(b blob, path string) bool {
+	in, err := os.Open(b.path)
+	if err != nil {
+		return false
+	}
+	defer in.Close()
+	if info, err := in.Stat(); err != nil || !os.SameFile(info, b.info) {
+		return false
+	}
+	linked, err := linkFile(b.path, path, true)
+	switch {
+	case err != nil:
+	case linked:
+		// b.path may have been replaced between the check and the link
+		if info, err := os.Stat(path); err != nil || !os.SameFile(info, b.info) {
+			os.Remove(path)
+			return false
+		}
+	default:
+		err = copyFrom(in, path)
+	}
+	if err != nil {
+		log.Printf("Could not share %s, storing the upload itself: %v", s.redact.name(filepath.Base(b.path)), s.redact.err(err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,209 @@
+package uploadserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+// awaitWaiters fails the test unless an upload of hash leads and n others
+// wait for it
+func awaitWaiters(t *testing.T, g *inflight, hash string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		g.mu.Lock()
+		c, ok := g.calls[hash]
+		waiting := 0
+		if ok {
+			waiting = c.waiters
+		}
+		g.mu.Unlock()
+		if ok && waiting >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d uploads waiting (leading: %v)", waiting, n, ok)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// sameFile reports whether the stored files a and b share their content
+func (ts *testServer) sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	ia, err := os.Stat(filepath.Join(ts.dir, a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ib, err := os.Stat(filepath.Join(ts.dir, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(ia, ib)
+}
+
+// startLeader opens an Upload stream declaring hash, sends it the first
+// chunk of data and waits for the server to take it as the leader of hash
+func (ts *testServer) startLeader(t *testing.T, name, hash string, data []byte) *connect.ClientStreamForClientSimple[fileuploadv1.UploadRequest, fileuploadv1.UploadResponse] {
+	t.Helper()
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*fileuploadv1.UploadRequest{
+		{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: name, Sha256: hash}}},
+		{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: data}},
+	} {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	awaitWaiters(t, &ts.srv.writes, hash, 0)
+	return stream
+}
+
+// finishLeader sends the rest of the content and commit to a stream of
+// startLeader and returns its response
+func finishLeader(t *testing.T, stream *connect.ClientStreamForClientSimple[fileuploadv1.UploadRequest, fileuploadv1.UploadResponse], rest []byte, commit string) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	for _, req := range []*fileuploadv1.UploadRequest{
+		{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: rest}},
+		{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: commit}},
+	} {
+		if err := stream.Send(req); err != nil {
+			break // the server's error comes with the response
+		}
+	}
+	return stream.CloseAndReceive()
+}
+
+// declaredUpload streams data as name, declaring hash in the metadata and
+// committing it
+func declaredUpload(ctx context.Context, client fileuploadv1connect.FileUploadServiceClient, name, hash string, data []byte) (*fileuploadv1.UploadResponse, error) {
+	stream, err := client.Upload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range []*fileuploadv1.UploadRequest{
+		{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: name, Sha256: hash}}},
+		{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: data}},
+		{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: hash}},
+	} {
+		if err := stream.Send(req); err != nil {
+			break // the server's error comes with the response
+		}
+	}
+	return stream.CloseAndReceive()
+}
+
+func TestConcurrentUploadsShareDeclaredContent(t *testing.T) {
+	const waiters = 4
+	ts := newTestServer(t, &Server{})
+	data := bytes.Repeat([]byte("shared content "), 4096)
+	hash := sha256Hex(string(data))
+
+	leader := ts.startLeader(t, "leader.bin", hash, data[:1000])
+	var wg sync.WaitGroup
+	responses := make([]*fileuploadv1.UploadResponse, waiters)
+	errs := make([]error, waiters)
+	for i := range waiters {
+		name := fmt.Sprintf("copy-%d.bin", i)
+		wg.Go(func() {
+			// streams and unary calls alike
+			if i%2 == 0 {
+				responses[i], errs[i] = declaredUpload(t.Context(), ts.client, name, hash, data)
+				return
+			}
+			responses[i], errs[i] = ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: name, Data: data, Sha256: hash})
+		})
+	}
+	awaitWaiters(t, &ts.srv.writes, hash, waiters)
+	if _, err := finishLeader(t, leader, data[1000:], hash); err != nil {
+		t.Fatalf("leader: %v", err)
+	}
+	wg.Wait()
+
+	for i := range waiters {
+		name := fmt.Sprintf("copy-%d.bin", i)
+		if errs[i] != nil {
+			t.Errorf("%s: %v", name, errs[i])
+			continue
+		}
+		if resp := responses[i]; resp.Size != int64(len(data)) || !resp.HashOk {
+			t.Errorf("%s: response %v", name, resp)
+		}
+		if !ts.sameFile(t, "leader.bin", name) {
+			t.Errorf("%s was written again instead of sharing the leader's file", name)
+		}
+	}
+	if !strings.Contains(responses[0].Message, "shared") {
+		t.Errorf("stream response message %q does not tell the content was shared", responses[0].Message)
+	}
+}
+
+func TestConcurrentUploadStoresItselfWhenLeaderFails(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	data := []byte("content of a failing leader")
+	hash := sha256Hex(string(data))
+
+	leader := ts.startLeader(t, "leader.bin", hash, data[:10])
+	done := make(chan error)
+	go func() {
+		_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "waiter.bin", Data: data, Sha256: hash})
+		done <- err
+	}()
+	awaitWaiters(t, &ts.srv.writes, hash, 1)
+	// the leader commits a hash other than the one it declared
+	if _, err := finishLeader(t, leader, data[10:], sha256Hex("other")); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("leader committing another hash: %v, want invalid argument", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("waiter: %v", err)
+	}
+	if got := ts.stored(t, "waiter.bin"); got != string(data) {
+		t.Fatalf("waiter stored %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "leader.bin")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the failed leader left its file behind: %v", err)
+	}
+}
+
+func TestConcurrentUploadWithOtherContentFails(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	data := []byte("declared content")
+	hash := sha256Hex(string(data))
+
+	leader := ts.startLeader(t, "leader.bin", hash, data[:4])
+	done := make(chan error)
+	go func() {
+		// declares the leader's hash without holding its content
+		_, err := declaredUpload(t.Context(), ts.client, "liar.bin", hash, []byte("other content"))
+		done <- err
+	}()
+	awaitWaiters(t, &ts.srv.writes, hash, 1)
+	if _, err := finishLeader(t, leader, data[4:], hash); err != nil {
+		t.Fatalf("leader: %v", err)
+	}
+	if err := <-done; connect.CodeOf(err) != connect.CodeDataLoss {
+		t.Fatalf("upload declaring another content's hash: %v, want data loss", err)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "liar.bin")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the shared file was left behind: %v", err)
+	}
+	if got := ts.stored(t, "leader.bin"); got != string(data) {
+		t.Fatalf("leader holds %q", got)
+	}
+}
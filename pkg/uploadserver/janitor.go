@@ -0,0 +1,203 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// janitorInterval is how often abandoned partial uploads are looked for
+const janitorInterval = time.Minute
+
+// incompleteSessions is the number of ranged PUT and tus uploads holding a
+// partial file, published by MetricsHandler
+var incompleteSessions = expvar.NewInt("uploadserver_incomplete_sessions")
+
+// errTooManySessions is the cause of the ResourceExhausted error refusing a new
+// resumable upload over Config.MaxIncompleteSessions
+var errTooManySessions = errors.New("too many incomplete uploads, finish or abandon one first")
+
+// partialSession is one incomplete upload: a ranged upload file in partialDir
+// at its scopedName, the .json/.bin pair of a tus session in tusDir or the
+// .json file of an archive session in archivesDir
+type partialSession struct {
+	name      string // scoped name of a ranged upload, empty for tus and archives
+	tusID     string
+	archiveID string
+	modTime   time.Time // latest write to any of its files
+}
+
+// partialSessions lists the incomplete uploads found in partialDir
+func (s *Server) partialSessions() ([]partialSession, error) {
+	var sessions []partialSession
+	ranged, err := readDirIfExists(filepath.Join(s.dir, partialDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range ranged {
+		// skips tusDir, archivesDir and the staging directory of uploads not yet placed
+		if !scope.IsDir() || strings.HasPrefix(scope.Name(), ".") {
+			continue
+		}
+		files, err := readDirIfExists(filepath.Join(s.dir, partialDir, scope.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range files {
+			if info, err := e.Info(); err == nil && info.Mode().IsRegular() {
+				sessions = append(sessions, partialSession{name: scope.Name() + "/" + e.Name(), modTime: info.ModTime()})
+			}
+		}
+	}
+	tus, err := readDirIfExists(filepath.Join(s.dir, partialDir, tusDir))
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int) // tus id -> index in sessions
+	for _, e := range tus {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimSuffix(e.Name(), ".json"), ".bin")
+		if i, ok := ids[id]; ok {
+			if info.ModTime().After(sessions[i].modTime) {
+				sessions[i].modTime = info.ModTime()
+			}
+			continue
+		}
+		ids[id] = len(sessions)
+		sessions = append(sessions, partialSession{tusID: id, modTime: info.ModTime()})
+	}
+	archives, err := readDirIfExists(filepath.Join(s.dir, partialDir, archivesDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range archives {
+		// the session file is rewritten by every added entry
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if info, err := e.Info(); err == nil && ok && info.Mode().IsRegular() {
+			sessions = append(sessions, partialSession{archiveID: id, modTime: info.ModTime()})
+		}
+	}
+	return sessions, nil
+}
+
+// readDirIfExists is os.ReadDir, listing nothing for a missing directory
+func readDirIfExists(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+// checkSessionCapacity refuses a new resumable upload once
+// Config.MaxIncompleteSessions are in progress
+func (s *Server) checkSessionCapacity() error {
+	if s.maxIncompleteSessions <= 0 {
+		return nil
+	}
+	sessions, err := s.partialSessions()
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	incompleteSessions.Set(int64(len(sessions)))
+	if len(sessions) >= s.maxIncompleteSessions {
+		return connect.NewError(connect.CodeResourceExhausted, errTooManySessions)
+	}
+	return nil
+}
+
+// runJanitor deletes partial uploads idle for longer than Config.PartialMaxAge
+// and expired files, and refreshes the session count, until ctx is done
+func (s *Server) runJanitor(ctx context.Context) {
+	interval := janitorInterval
+	if s.partialMaxAge > 0 {
+		interval = min(interval, s.partialMaxAge/2)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.sweepPartials()
+		s.sweepExpired()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) sweepPartials() {
+	sessions, err := s.partialSessions()
+	if err != nil {
+		log.Printf("Janitor: cannot list partial uploads: %v", err)
+		return
+	}
+	remaining := len(sessions)
+	// read-only mode keeps the upload directory unchanged, abandoned sessions
+	// are removed once it ends
+	readOnly := s.readOnly != nil && s.readOnly()
+	if readOnly {
+		sessions = nil
+	}
+	for _, sess := range sessions {
+		if s.partialMaxAge > 0 && time.Since(sess.modTime) > s.partialMaxAge && s.removePartial(sess) {
+			remaining--
+		}
+	}
+	// a key outlives its upload by as long as an idle partial upload is kept
+	if s.partialMaxAge > 0 && !readOnly {
+		s.keys.expire(s.partialMaxAge)
+	}
+	incompleteSessions.Set(int64(remaining))
+}
+
+// removePartial deletes an abandoned session unless it was written to since
+// it was listed
+func (s *Server) removePartial(sess partialSession) bool {
+	var paths []string
+	switch {
+	case sess.tusID != "":
+		unlock := s.tus.lock(sess.tusID)
+		defer unlock()
+		infoPath, dataPath := s.tusPaths(sess.tusID)
+		paths = []string{dataPath, infoPath}
+	case sess.archiveID != "":
+		unlock := s.archives.lock(sess.archiveID)
+		defer unlock()
+		infoPath, dir := s.archivePaths(sess.archiveID)
+		paths = []string{infoPath, dir}
+	default:
+		s.ranged.mu.Lock()
+		defer s.ranged.mu.Unlock()
+		paths = []string{filepath.Join(s.dir, partialDir, sess.name)}
+	}
+	if info, err := os.Stat(paths[0]); err == nil && time.Since(info.ModTime()) <= s.partialMaxAge {
+		return false
+	}
+	switch {
+	case sess.tusID != "":
+		s.tus.forget(sess.tusID)
+	case sess.archiveID != "":
+		s.archives.forget(sess.archiveID)
+	default:
+		s.ranged.forget(sess.name)
+	}
+	for _, p := range paths {
+		if err := os.RemoveAll(p); err != nil {
+			log.Printf("Janitor: cannot remove %s: %v", s.redact.name(filepath.Base(p)), s.redact.err(err))
+			return false
+		}
+	}
+	log.Printf("Janitor: removed abandoned upload %s%s%s (idle since %s)", s.redact.name(sess.name), sess.tusID, sess.archiveID, sess.modTime.Format(time.RFC3339))
+	return true
+}
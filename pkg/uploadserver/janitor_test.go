@@ -0,0 +1,73 @@
+package uploadserver
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestSweepPartials(t *testing.T) {
+	ts := newTestServer(t, &Server{partialMaxAge: time.Hour})
+	old := time.Now().Add(-2 * time.Hour)
+
+	ts.putRange(t, "stale.bin", "bytes 0-3/8", "data")
+	ts.putRange(t, "fresh.bin", "bytes 0-3/8", "data")
+	staleTus := path.Base(ts.tusCreate(t, "stale-tus.bin", 8))
+	freshTus := path.Base(ts.tusCreate(t, "fresh-tus.bin", 8))
+
+	staleInfo, staleData := ts.srv.tusPaths(staleTus)
+	for _, p := range []string{ts.partialPath("stale.bin"), staleInfo, staleData} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts.srv.sweepPartials()
+	if got := incompleteSessions.Value(); got != 2 {
+		t.Errorf("incomplete sessions after the sweep = %d, want 2", got)
+	}
+	for _, p := range []string{ts.partialPath("stale.bin"), staleInfo, staleData} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("abandoned %s was kept: %v", p, err)
+		}
+	}
+	freshInfo, freshData := ts.srv.tusPaths(freshTus)
+	for _, p := range []string{ts.partialPath("fresh.bin"), freshInfo, freshData} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("active upload %s was removed: %v", p, err)
+		}
+	}
+
+	// the ranged upload starts over instead of completing on stale state
+	if resp, body := ts.putRange(t, "stale.bin", "bytes 4-7/8", "more"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("PUT after the partial was swept: status %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestMaxIncompleteSessions(t *testing.T) {
+	ts := newTestServer(t, &Server{maxIncompleteSessions: 1})
+
+	if resp, body := ts.putRange(t, "a.bin", "bytes 0-3/8", "data"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("first ranged upload: status %d: %s", resp.StatusCode, body)
+	}
+	if resp, _ := ts.putRange(t, "b.bin", "bytes 0-3/8", "data"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("ranged upload over the cap: status %d, want 429", resp.StatusCode)
+	}
+	req := ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", "8")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("c.bin")))
+	if resp, _ := ts.do(t, req); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("tus upload over the cap: status %d, want 429", resp.StatusCode)
+	}
+
+	// the upload in progress may still finish, which frees its slot
+	if resp, body := ts.putRange(t, "a.bin", "bytes 4-7/8", "more"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("finishing the upload in progress: status %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := ts.putRange(t, "b.bin", "bytes 0-3/8", "data"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("ranged upload after a slot freed: status %d: %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,171 @@
+package uploadserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// journalMinCompact is the number of logged changes below which a journal is
+// never compacted, however small its map
+const journalMinCompact = 1024
+
+// journalFile is the on-disk snapshot of a journaled map
+type journalFile[V any] struct {
+	Files map[string]V `json:"files"`
+}
+
+// journalEntry is one logged change: Name set to Value, or removed without one
+type journalEntry[V any] struct {
+	Name  string `json:"name"`
+	Value *V     `json:"value,omitempty"`
+}
+
+// journal persists a map of stored files without rewriting all of it on every
+// change: a change is appended as one JSON line to path+".log" and replayed
+// over the snapshot at path when the map is loaded. Once the log holds more
+// lines than the map has entries, the snapshot is rewritten atomically and
+// the log emptied. Callers serialize the calls.
+type journal[V any] struct {
+	path  string
+	log   *os.File
+	lines int
+}
+
+// openJournal loads the map saved at path, replaying its log, and opens the
+// log for appending. A line torn by a crash is dropped when it is the last
+// one; anything else unreadable is an error.
+func openJournal[V any](path string) (*journal[V], map[string]V, error) {
+	files := make(map[string]V)
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, nil, err
+	default:
+		var f journalFile[V]
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, nil, fmt.Errorf("%s is corrupt: %w", path, err)
+		}
+		for name, v := range f.Files {
+			files[name] = v
+		}
+	}
+
+	lf, err := os.OpenFile(path+".log", os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	j := &journal[V]{path: path, log: lf}
+	if err := j.replay(files); err != nil {
+		lf.Close()
+		return nil, nil, err
+	}
+	return j, files, nil
+}
+
+// replay applies the logged changes to files, and cuts a last line torn by
+// a crash off the log so the next change starts a line of its own
+func (j *journal[V]) replay(files map[string]V) error {
+	r := bufio.NewReader(j.log)
+	var read int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return j.log.Truncate(read)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var e journalEntry[V]
+		if err := json.Unmarshal(line, &e); err != nil || e.Name == "" {
+			return fmt.Errorf("%s.log is corrupt at line %d", j.path, j.lines+1)
+		}
+		if e.Value != nil {
+			files[e.Name] = *e.Value
+		} else {
+			delete(files, e.Name)
+		}
+		read += int64(len(line))
+		j.lines++
+	}
+}
+
+// set logs name being set to v
+func (j *journal[V]) set(name string, v V, files map[string]V) error {
+	return j.append(journalEntry[V]{Name: name, Value: &v}, files)
+}
+
+// remove logs the removal of name
+func (j *journal[V]) remove(name string, files map[string]V) error {
+	return j.append(journalEntry[V]{Name: name}, files)
+}
+
+// append logs e, then compacts the journal to files, the map with e applied,
+// once the log has grown past it
+func (j *journal[V]) append(e journalEntry[V], files map[string]V) error {
+	if j == nil {
+		return nil
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.log.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if j.lines++; j.lines > max(journalMinCompact, len(files)) {
+		return j.compact(files)
+	}
+	return nil
+}
+
+// compact saves files as the snapshot and empties the log. The snapshot is
+// written to a temporary file renamed over path, so a crash never leaves a
+// truncated one behind; a crash before the log is emptied replays it over a
+// snapshot already holding its changes, which yields the same map.
+func (j *journal[V]) compact(files map[string]V) error {
+	if j == nil {
+		return nil
+	}
+	b, err := json.Marshal(journalFile[V]{Files: files})
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	if err := j.log.Truncate(0); err != nil {
+		return err
+	}
+	j.lines = 0
+	return nil
+}
+
+// removeJournal deletes the snapshot at path and its log
+func removeJournal(path string) error {
+	for _, p := range []string{path, path + ".log"} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// close closes the log; the journal must not be used afterwards
+func (j *journal[V]) close() error {
+	if j == nil {
+		return nil
+	}
+	return j.log.Close()
+}
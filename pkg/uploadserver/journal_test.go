@@ -0,0 +1,102 @@
+package uploadserver
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func openTestJournal(t *testing.T, path string) (*journal[string], map[string]string) {
+	t.Helper()
+	j, files, err := openJournal[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { j.close() })
+	return j, files
+}
+
+func TestJournalReplaysLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.json")
+	j, files := openTestJournal(t, path)
+	for _, name := range []string{"a", "b"} {
+		files[name] = "hash of " + name
+		if err := j.set(name, files[name], files); err != nil {
+			t.Fatal(err)
+		}
+	}
+	delete(files, "a")
+	if err := j.remove("a", files); err != nil {
+		t.Fatal(err)
+	}
+	// changes are appended, the snapshot is only written on compaction
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("a change rewrote the snapshot: %v", err)
+	}
+
+	_, reopened := openTestJournal(t, path)
+	if len(reopened) != 1 || reopened["b"] != "hash of b" {
+		t.Fatalf("reopened %v, want only b", reopened)
+	}
+}
+
+func TestJournalDropsTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.json")
+	j, files := openTestJournal(t, path)
+	files["a"] = "1"
+	if err := j.set("a", "1", files); err != nil {
+		t.Fatal(err)
+	}
+	// a crash in the middle of appending the next change
+	f, err := os.OpenFile(path+".log", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"name":"b","val`)
+	f.Close()
+
+	j, files = openTestJournal(t, path)
+	if len(files) != 1 || files["a"] != "1" {
+		t.Fatalf("reopened %v, want only a", files)
+	}
+	files["c"] = "3"
+	if err := j.set("c", "3", files); err != nil {
+		t.Fatal(err)
+	}
+	if _, files = openTestJournal(t, path); len(files) != 2 || files["c"] != "3" {
+		t.Fatalf("a change after the torn line was lost: %v", files)
+	}
+}
+
+func TestJournalRefusesCorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.json")
+	if err := os.WriteFile(path+".log", []byte("{\"name\":\"a\",\"value\":\"1\"}\nnot json\n{\"name\":\"b\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openJournal[string](path); err == nil {
+		t.Fatal("a corrupt line inside the log was accepted")
+	}
+}
+
+func TestJournalCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.json")
+	j, files := openTestJournal(t, path)
+	for i := range journalMinCompact + 1 {
+		name := strconv.Itoa(i % 10)
+		files[name] = strconv.Itoa(i)
+		if err := j.set(name, files[name], files); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := os.Stat(path + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("log of %d bytes after compaction, want it emptied", info.Size())
+	}
+	if _, reopened := openTestJournal(t, path); len(reopened) != 10 || reopened["0"] != strconv.Itoa(journalMinCompact-4) {
+		t.Fatalf("reopened %v after compaction", reopened)
+	}
+}
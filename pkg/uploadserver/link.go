@@ -0,0 +1,76 @@
+package uploadserver
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// linkFile makes dst a hard link to src and reports whether it did, failing
+// with fs.ErrExist when dst exists unless overwrite. Without hard links
+// between them, such as across filesystems, it links nothing and the caller
+// copies instead.
+func linkFile(src, dst string, overwrite bool) (bool, error) {
+	if !overwrite {
+		err := os.Link(src, dst)
+		if err == nil || errors.Is(err, fs.ErrExist) {
+			return err == nil, err
+		}
+		return false, nil
+	}
+	// linked beside dst, then renamed over it so dst is replaced at once
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".link")
+	os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		return false, nil
+	}
+	err := os.Rename(tmp, dst)
+	// renaming a link over another link to the same file leaves both
+	os.Remove(tmp)
+	return err == nil, err
+}
+
+// createStored creates the file at path for new content. Whatever is stored
+// there is unlinked first rather than truncated: it may be a link sharing its
+// content with another name, which writing in place would change too.
+func createStored(path string) (*os.File, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// writeStored is os.WriteFile creating the file with createStored and
+// flushing it as the sync policy asks; placeStored flushes its directory
+func (s *Server) writeStored(path string, data []byte) error {
+	f, err := createStored(path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = s.syncCommit(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyFrom stores the content of in at dst, replacing what is stored there
+func copyFrom(in *os.File, dst string) error {
+	out, err := createStored(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
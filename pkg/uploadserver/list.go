@@ -0,0 +1,90 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// listBatch is how many directory entries ListFilesStream reads at a time
+const listBatch = 256
+
+// ListFilesStream sends a FileInfo for every stored file, reading each
+// upload directory listBatch entries at a time so neither the server nor the
+// client holds the whole listing. Hidden files and files outside the
+// directory they route to are left out, as in the hash index, and so are
+// the files another namespace stored under a storage key.
+func (s *Server) ListFilesStream(
+	ctx context.Context, req *fileuploadv1.ListFilesRequest, stream *connect.ServerStream[fileuploadv1.FileInfo]) error {
+
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	visible := func(name string) bool { return s.lookupName(ctx, baseName(name)) == name }
+	for _, sub := range s.files.dirs() {
+		if err := s.listDir(ctx, sub, req.Prefix, visible, stream.Send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listDir sends the stored files of the subdirectory sub whose name starts
+// with prefix that visible reports true for
+func (s *Server) listDir(ctx context.Context, sub, prefix string, visible func(name string) bool, send func(*fileuploadv1.FileInfo) error) error {
+	dir, err := os.Open(filepath.Join(s.files.dir, sub))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	defer dir.Close()
+	for {
+		if ctx.Err() != nil {
+			return contextError(ctx)
+		}
+		entries, readErr := dir.ReadDir(listBatch)
+		for _, e := range entries {
+			name := s.files.nameAt(sub, e.Name())
+			if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") || !strings.HasPrefix(baseName(name), prefix) ||
+				s.files.subdir(name) != sub || !visible(name) {
+				continue
+			}
+			info, err := e.Info()
+			if errors.Is(err, os.ErrNotExist) {
+				// removed since the directory was read
+				continue
+			}
+			if err != nil {
+				return connect.NewError(connect.CodeInternal, err)
+			}
+			hash, _ := s.index.hashOf(name)
+			if err := send(&fileuploadv1.FileInfo{
+				Filename:     baseName(name),
+				Size:         info.Size(),
+				Sha256:       hash,
+				ModifiedUnix: info.ModTime().Unix(),
+				StoragePath:  s.files.relPath(name),
+				ExpiresUnix:  unixOrZero(s.expiries.get(name)),
+			}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			return connect.NewError(connect.CodeInternal, readErr)
+		}
+	}
+}
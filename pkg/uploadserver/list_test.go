@@ -0,0 +1,82 @@
+package uploadserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// listFiles returns the FileInfo messages of ListFilesStream by filename
+func (ts *testServer) listFiles(t *testing.T, prefix string) map[string]*fileuploadv1.FileInfo {
+	t.Helper()
+	stream, err := ts.client.ListFilesStream(t.Context(), &fileuploadv1.ListFilesRequest{Prefix: prefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	files := map[string]*fileuploadv1.FileInfo{}
+	for stream.Receive() {
+		files[stream.Msg().Filename] = stream.Msg()
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestListFilesStream(t *testing.T) {
+	ts := newRoutedServer(t, Route{Match: ".pdf", Dir: "docs"})
+	ts.srv.index = newHashIndex()
+	ts.uploadFile(t, "report-1.pdf", "first")
+	ts.uploadFile(t, "report-2.txt", "second")
+	ts.uploadFile(t, "notes.txt", "notes")
+	if _, err := ts.uploadExpiring(t, "report-3.txt", "third", 60, 0); err != nil {
+		t.Fatal(err)
+	}
+	// neither hidden files nor files outside the directory their name routes to
+	for _, path := range []string{".hidden", filepath.Join("docs", "report-4.txt")} {
+		if err := os.WriteFile(filepath.Join(ts.dir, path), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a file stored behind the server's back is listed without a hash
+	if err := os.WriteFile(filepath.Join(ts.dir, "report-5.txt"), []byte("unindexed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := ts.listFiles(t, "report-")
+	if len(files) != 4 {
+		t.Fatalf("listed %d files: %v", len(files), files)
+	}
+	if f := files["report-1.pdf"]; f.Size != 5 || f.Sha256 != sha256Hex("first") || f.StoragePath != "docs/report-1.pdf" || f.ModifiedUnix == 0 {
+		t.Errorf("routed file listed as %v", f)
+	}
+	if f := files["report-2.txt"]; f.StoragePath != "report-2.txt" || f.Sha256 != sha256Hex("second") || f.ExpiresUnix != 0 {
+		t.Errorf("plain file listed as %v", f)
+	}
+	if f := files["report-3.txt"]; f.ExpiresUnix == 0 {
+		t.Errorf("expiring file listed without its expiry: %v", f)
+	}
+	if f := files["report-5.txt"]; f.Size != 9 || f.Sha256 != "" {
+		t.Errorf("unindexed file listed as %v", f)
+	}
+
+	if all := ts.listFiles(t, ""); len(all) != 5 || all["notes.txt"] == nil {
+		t.Fatalf("listed %d files without a prefix: %v", len(all), all)
+	}
+}
+
+func TestListFilesStreamBatches(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	for i := range listBatch + 10 {
+		if err := os.WriteFile(filepath.Join(ts.dir, fmt.Sprintf("f%03d", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if files := ts.listFiles(t, ""); len(files) != listBatch+10 {
+		t.Fatalf("listed %d files, want %d", len(files), listBatch+10)
+	}
+}
@@ -0,0 +1,116 @@
+package uploadserver
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// receiveCounter counts the messages the streaming handlers receive
+type receiveCounter struct {
+	n atomic.Int64
+}
+
+func (c *receiveCounter) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc { return next }
+
+func (c *receiveCounter) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (c *receiveCounter) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		return next(ctx, countingConn{conn, c})
+	}
+}
+
+type countingConn struct {
+	connect.StreamingHandlerConn
+	counter *receiveCounter
+}
+
+func (c countingConn) Receive(msg any) error {
+	err := c.StreamingHandlerConn.Receive(msg)
+	if err == nil {
+		c.counter.n.Add(1)
+	}
+	return err
+}
+
+func TestOversizedDeclaredUploadReadsNoChunk(t *testing.T) {
+	var received receiveCounter
+	ts := newTestServer(t, &Server{maxFileSize: 10}, connect.WithInterceptors(&received))
+
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: "big.bin", DeclaredSize: proto.Int64(11)},
+	}})
+	for range 10 {
+		if err := stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("x")}}); err != nil {
+			break
+		}
+	}
+	_, err = stream.CloseAndReceive()
+	if connect.CodeOf(err) != connect.CodeResourceExhausted || !strings.Contains(err.Error(), "10 bytes") {
+		t.Fatalf("got %v, want resource_exhausted with the limit", err)
+	}
+	if n := received.n.Load(); n != 1 {
+		t.Fatalf("the server read %d messages, want only the metadata", n)
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	ts := newTestServer(t, &Server{maxFileSize: 10})
+	const big = "0123456789A"
+
+	if _, err := ts.streamUpload(t.Context(), "stream.bin", []byte(big), 4); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Errorf("streamed upload past the limit: %v", err)
+	}
+	if _, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "unary.bin", Data: []byte(big)}); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Errorf("unary upload past the limit: %v", err)
+	}
+	if resp, _ := ts.putRange(t, "put.bin", "", big); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("PUT past the limit: %d", resp.StatusCode)
+	}
+	if resp, _ := ts.putRange(t, "range.bin", "bytes 0-3/11", "0123"); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("ranged PUT declaring a total past the limit: %d", resp.StatusCode)
+	}
+	body, contentType := multipartBody(t, "form.bin", big, nil)
+	if rec := postMultipart(ts.srv, body, contentType); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("multipart upload past the limit: %d", rec.Code)
+	}
+	req := ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", "11")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("tus.bin")))
+	if resp, _ := ts.do(t, req); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("tus upload past the limit: %d", resp.StatusCode)
+	}
+	resp, _ := ts.do(t, ts.tusRequest(t, http.MethodOptions, tusBasePath, ""))
+	if got := resp.Header.Get("Tus-Max-Size"); got != "10" {
+		t.Errorf("Tus-Max-Size %q", got)
+	}
+
+	entries, _ := os.ReadDir(ts.dir)
+	for _, e := range entries {
+		if !e.IsDir() {
+			t.Errorf("%s stored past the limit", e.Name())
+		}
+	}
+
+	// exactly at the limit is fine
+	ts.uploadFile(t, "exact.bin", big[:10])
+	if resp, body := ts.putRange(t, "exact-put.bin", "", big[:10]); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT at the limit: %d %s", resp.StatusCode, body)
+	}
+}
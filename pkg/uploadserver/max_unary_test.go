@@ -0,0 +1,40 @@
+package uploadserver
+
+import (
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestMaxUnaryUploadBytes(t *testing.T) {
+	ts := newTestServer(t, &Server{maxUnaryUploadBytes: 8})
+
+	ts.uploadFile(t, "at-limit.txt", "12345678")
+
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename: "over.txt",
+		Data:     []byte("123456789"),
+		Sha256:   sha256Hex("123456789"),
+	})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument || !strings.Contains(err.Error(), "streaming Upload RPC") {
+		t.Fatalf("UploadFile one byte over the limit: %v, want invalid argument pointing to streaming", err)
+	}
+	ts.assertNotStored(t, "over.txt")
+
+	// the limit applies to each call, a large file still arrives in chunks
+	if _, err := ts.streamUpload(t.Context(), "streamed.txt", []byte("123456789"), 4); err != nil {
+		t.Fatalf("streaming upload over the unary limit: %v", err)
+	}
+	if _, err := ts.uploadChunk(t.Context(), "chunked.txt", 0, "12345678", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.uploadChunk(t.Context(), "chunked.txt", 8, "9", true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.stored(t, "chunked.txt"); got != "123456789" {
+		t.Fatalf("chunked upload stored %q", got)
+	}
+}
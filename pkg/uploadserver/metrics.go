@@ -0,0 +1,33 @@
+package uploadserver
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// hiddenVars are the expvar variables MetricsHandler leaves out: the command
+// line may hold credentials passed as flags
+var hiddenVars = map[string]bool{"cmdline": true}
+
+// MetricsHandler serves the expvar metrics as JSON like expvar.Handler, the
+// server's uploadserver_* counters among them, without the command line. New
+// does not serve it: mount it on a listener only operators reach.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\n")
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if hiddenVars[kv.Key] {
+				return
+			}
+			if !first {
+				fmt.Fprintf(w, ",\n")
+			}
+			first = false
+			fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+		})
+		fmt.Fprintf(w, "\n}\n")
+	})
+}
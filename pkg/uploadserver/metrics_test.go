@@ -0,0 +1,37 @@
+package uploadserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsNotServedByNew(t *testing.T) {
+	hs, _ := serveConfig(t, Config{Dir: t.TempDir()})
+	resp, err := hs.Client().Get(hs.URL + "/debug/vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET /debug/vars on the upload handler: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestMetricsHandlerHidesCommandLine(t *testing.T) {
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("metrics are not JSON: %v\n%s", err, rec.Body)
+	}
+	if _, ok := vars["cmdline"]; ok {
+		t.Error("metrics expose the command line")
+	}
+	for _, name := range []string{"memstats", "uploadserver_incomplete_sessions"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("metrics lack %s", name)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package uploadserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+// testCA is a self-signed certificate authority issuing test certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	file string // the CA certificate in PEM
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &testCA{cert: cert, key: key, pool: x509.NewCertPool(), file: filepath.Join(t.TempDir(), "ca.pem")}
+	ca.pool.AddCert(cert)
+	if err := os.WriteFile(ca.file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+// issue returns a certificate of name signed by the CA for usage, valid for 127.0.0.1
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serveMutualTLS serves the handler of ts again over TLS, requiring client
+// certificates signed by ca
+func serveMutualTLS(t *testing.T, ts *testServer, ca *testCA) *httptest.Server {
+	t.Helper()
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{ca.issue(t, "server", x509.ExtKeyUsageServerAuth)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool,
+	}
+	hs := httptest.NewUnstartedServer(ts.Config.Handler)
+	hs.EnableHTTP2 = true
+	hs.TLS = config
+	hs.StartTLS()
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+// mutualTLSClient returns an HTTP client trusting ca that presents certs
+func mutualTLSClient(t *testing.T, ca *testCA, certs ...tls.Certificate) *http.Client {
+	transport := &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: ca.pool, Certificates: certs},
+		ForceAttemptHTTP2: true,
+	}
+	t.Cleanup(transport.CloseIdleConnections)
+	return &http.Client{Transport: transport}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	ts := newTestServer(t, &Server{})
+	hs := serveMutualTLS(t, ts, ca)
+
+	upload := func(certs ...tls.Certificate) error {
+		client := fileuploadv1connect.NewFileUploadServiceClient(mutualTLSClient(t, ca, certs...), hs.URL)
+		_, err := client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+			Filename: "mtls.txt",
+			Data:     []byte("data"),
+			Sha256:   sha256Hex("data"),
+		})
+		return err
+	}
+	if err := upload(); err == nil {
+		t.Fatal("upload without a client certificate accepted")
+	}
+	if err := upload(newTestCA(t).issue(t, "client", x509.ExtKeyUsageClientAuth)); err == nil {
+		t.Fatal("upload with a certificate of another CA accepted")
+	}
+	if err := upload(ca.issue(t, "client", x509.ExtKeyUsageClientAuth)); err != nil {
+		t.Fatalf("upload with a client certificate of the CA: %v", err)
+	}
+	if got := ts.stored(t, "mtls.txt"); got != "data" {
+		t.Fatalf("stored %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package uploadserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Slugify lowercases name and turns every run of characters other than
+// letters and digits into a single '-', keeping the extension:
+// "My Report (Final).PDF" becomes "my-report-final.pdf"
+func Slugify(name string) string {
+	ext := filepath.Ext(name)
+	stem := slug(strings.TrimSuffix(name, ext))
+	if stem == "" {
+		stem = "file"
+	}
+	if ext = slug(ext); ext != "" {
+		return stem + "." + ext
+	}
+	return stem
+}
+
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// TimestampPrefix prefixes name with the current UTC time, so uploads under
+// the same name are kept side by side in the order they arrived:
+// "report.pdf" becomes "20261014T153000Z-report.pdf"
+func TimestampPrefix(name string) string {
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + name
+}
+
+// ParseNameTransformer parses a comma-separated list of the built-in
+// transformers slugify and timestamp, applied in order; "" or none is nil
+func ParseNameTransformer(s string) (func(string) string, error) {
+	if s == "" || s == "none" {
+		return nil, nil
+	}
+	var steps []func(string) string
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "slugify":
+			steps = append(steps, Slugify)
+		case "timestamp":
+			steps = append(steps, TimestampPrefix)
+		default:
+			return nil, fmt.Errorf("unknown name transformer %q, want slugify or timestamp", name)
+		}
+	}
+	return func(name string) string {
+		for _, step := range steps {
+			name = step(name)
+		}
+		return name
+	}, nil
+}
@@ -0,0 +1,87 @@
+package uploadserver
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"My Report (Final).PDF", "my-report-final.pdf"},
+		{"already-a-slug.txt", "already-a-slug.txt"},
+		{"__leading and trailing__.md", "leading-and-trailing.md"},
+		{"Ünïcode Name.txt", "ünïcode-name.txt"},
+		{"no extension", "no-extension"},
+		{"(((.txt", "file.txt"},
+		{"archive.tar.gz", "archive-tar.gz"},
+	} {
+		if got := Slugify(tc.in); got != tc.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+// timestamped matches the names TimestampPrefix makes of report.pdf
+var timestamped = regexp.MustCompile(`^\d{8}T\d{6}Z-report\.pdf$`)
+
+func TestTimestampPrefix(t *testing.T) {
+	if got := TimestampPrefix("report.pdf"); !timestamped.MatchString(got) {
+		t.Fatalf("TimestampPrefix = %q", got)
+	}
+}
+
+func TestParseNameTransformer(t *testing.T) {
+	for _, s := range []string{"", "none"} {
+		if transform, err := ParseNameTransformer(s); err != nil || transform != nil {
+			t.Errorf("ParseNameTransformer(%q) = %v, want none", s, err)
+		}
+	}
+	transform, err := ParseNameTransformer("slugify, timestamp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := transform("Report.PDF")
+	if !timestamped.MatchString(got) {
+		t.Fatalf("slugify then timestamp made %q", got)
+	}
+	if _, err := ParseNameTransformer("slugify,uppercase"); err == nil {
+		t.Fatal("an unknown transformer was accepted")
+	}
+}
+
+func TestNameTransformer(t *testing.T) {
+	ts := newTestServer(t, &Server{nameTransformer: Slugify})
+
+	if got := ts.uploadFile(t, "My Report.PDF", "unary").StoredFilename; got != "my-report.pdf" {
+		t.Errorf("UploadFile stored %q", got)
+	}
+	streamed, err := ts.streamUpload(t.Context(), "Holiday Photo.JPG", []byte("streamed"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed.StoredFilename != "holiday-photo.jpg" {
+		t.Errorf("Upload stored %q", streamed.StoredFilename)
+	}
+	resp, body := ts.putRange(t, "Some%20Notes.TXT", "bytes 0-2/3", "put")
+	if resp.StatusCode != http.StatusCreated || !strings.Contains(string(body), "some-notes.txt") {
+		t.Errorf("ranged PUT: status %d: %s", resp.StatusCode, body)
+	}
+	for name, want := range map[string]string{"my-report.pdf": "unary", "holiday-photo.jpg": "streamed", "some-notes.txt": "put"} {
+		if got := ts.stored(t, name); got != want {
+			t.Errorf("%s holds %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNameTransformerResanitized(t *testing.T) {
+	// a transformer cannot reach out of the upload directory
+	ts := newTestServer(t, &Server{nameTransformer: func(name string) string { return "../../" + name }})
+	if got := ts.uploadFile(t, "a.txt", "data").StoredFilename; got != "a.txt" {
+		t.Fatalf("stored %q", got)
+	}
+	if got := ts.stored(t, "a.txt"); got != "data" {
+		t.Fatalf("stored %q", got)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build nats
+
+package uploadserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsPublisher publishes upload events on a NATS subject with the official
+// client, which reconnects on its own. Each publish is flushed, so it only
+// succeeds once the server has received the event.
+type natsPublisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATSPublisher returns a Publisher sending each event as JSON to subject
+// on the NATS servers of url, a comma-separated list of nats:// or tls://
+// URLs with optional credentials. A server that is down at startup is
+// retried in the background, its events failing until it is reached.
+func NewNATSPublisher(url, subject string) (Publisher, error) {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return nil, fmt.Errorf("invalid NATS subject %q", subject)
+	}
+	conn, err := nats.Connect(url,
+		nats.Name("uploadserver"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connect to NATS: %w", err)
+	}
+	return &natsPublisher{conn: conn, subject: subject}, nil
+}
+
+// Publish sends event and waits until the server has it
+func (p *natsPublisher) Publish(ctx context.Context, event UploadCompleted) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := p.conn.Publish(p.subject, payload); err != nil {
+		return err
+	}
+	return p.conn.FlushWithContext(ctx)
+}
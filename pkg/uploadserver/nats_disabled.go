@@ -0,0 +1,11 @@
+//go:build !nats
+
+package uploadserver
+
+import "errors"
+
+// NewNATSPublisher is only available in builds with the nats tag, which
+// include the NATS client: go build -tags nats ./cmd/server
+func NewNATSPublisher(url, subject string) (Publisher, error) {
+	return nil, errors.New("NATS support is not built in, rebuild with -tags nats")
+}
@@ -0,0 +1,11 @@
+//go:build !nats
+
+package uploadserver
+
+import "testing"
+
+func TestNATSPublisherNotBuiltIn(t *testing.T) {
+	if _, err := NewNATSPublisher("nats://127.0.0.1:4222", "uploads.completed"); err == nil {
+		t.Fatal("NewNATSPublisher succeeded in a build without the nats tag")
+	}
+}
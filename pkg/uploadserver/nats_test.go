@@ -0,0 +1,33 @@
+//go:build nats
+
+package uploadserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNATSPublisher(t *testing.T) {
+	if _, err := NewNATSPublisher("nats://127.0.0.1:4222", "bad subject"); err == nil {
+		t.Fatal("accepted a subject with a space")
+	}
+
+	// nothing listens here: the publisher is created, its events fail
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	p, err := NewNATSPublisher("nats://"+addr, "uploads.completed")
+	if err != nil {
+		t.Fatalf("publisher of an unreachable server: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+	if err := p.Publish(ctx, UploadCompleted{Filename: "a.txt"}); err == nil {
+		t.Fatal("publish to an unreachable server succeeded")
+	}
+}
@@ -0,0 +1,55 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// defaultOpenFilesWait is how long an upload or download waits for a file
+// slot when Config.OpenFilesWait is 0
+const defaultOpenFilesWait = 5 * time.Second
+
+// errTooManyOpenFiles is the cause of the ResourceExhausted error refusing an
+// upload or download while every Config.MaxOpenFiles slot is in use
+var errTooManyOpenFiles = errors.New("too many open files")
+
+// fileBudget bounds the storage files held open at once by uploads and
+// downloads, so a low descriptor limit is met with a clear error up front
+// instead of failing opens mid-stream. A nil fileBudget never blocks.
+type fileBudget struct {
+	slots *byteBudget // one unit per open file
+	max   int
+	wait  time.Duration
+}
+
+func newFileBudget(max int, wait time.Duration) *fileBudget {
+	if max <= 0 {
+		return nil
+	}
+	if wait <= 0 {
+		wait = defaultOpenFilesWait
+	}
+	return &fileBudget{slots: newByteBudget(int64(max)), max: max, wait: wait}
+}
+
+// acquire waits up to the budget's wait for a free slot and returns the
+// function releasing it, or CodeResourceExhausted when none freed up
+func (b *fileBudget) acquire(ctx context.Context) (func(), error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, b.wait)
+	defer cancel()
+	if _, err := b.slots.acquire(waitCtx, 1); err != nil {
+		if ctx.Err() != nil {
+			return nil, contextError(ctx)
+		}
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("%w: all %d file slots stayed busy for %s, retry later", errTooManyOpenFiles, b.max, b.wait))
+	}
+	return func() { b.slots.release(1) }, nil
+}
@@ -0,0 +1,87 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestFileBudget(t *testing.T) {
+	if newFileBudget(0, time.Second) != nil {
+		t.Fatal("a budget of 0 files limits opens")
+	}
+	var unlimited *fileBudget
+	if release, err := unlimited.acquire(t.Context()); err != nil {
+		t.Fatal(err)
+	} else {
+		release()
+	}
+
+	b := newFileBudget(1, 20*time.Millisecond)
+	release, err := b.acquire(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.acquire(t.Context()); connect.CodeOf(err) != connect.CodeResourceExhausted || !errors.Is(err, errTooManyOpenFiles) {
+		t.Fatalf("acquire with every slot busy: %v, want resource exhausted", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := b.acquire(ctx); connect.CodeOf(err) != connect.CodeCanceled {
+		t.Fatalf("acquire of a canceled request: %v, want canceled", err)
+	}
+
+	// a slot freed while waiting is taken
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		release()
+	}()
+	b.wait = time.Second
+	if release, err = b.acquire(t.Context()); err != nil {
+		t.Fatalf("acquire while a slot is freed: %v", err)
+	}
+	release()
+}
+
+func TestMaxOpenFiles(t *testing.T) {
+	ts := newTestServer(t, &Server{openFiles: newFileBudget(1, 20*time.Millisecond)})
+	ts.uploadFile(t, "a.txt", "stored")
+
+	// the only slot is held by another request
+	release, err := ts.srv.openFiles.acquire(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "b.txt", Data: []byte("refused")})
+	if connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("UploadFile without a file slot: %v, want resource exhausted", err)
+	}
+	if _, err := ts.streamUpload(t.Context(), "b.txt", []byte("refused"), 4); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("Upload without a file slot: %v, want resource exhausted", err)
+	}
+	if _, err := ts.uploadChunk(t.Context(), "b.txt", 0, "ref", false, ""); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("chunked UploadFile without a file slot: %v, want resource exhausted", err)
+	}
+	if _, err := ts.download(t.Context(), "a.txt"); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("Download without a file slot: %v, want resource exhausted", err)
+	}
+	if resp, body := ts.putRange(t, "b.txt", "", "refused"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("PUT without a file slot: status %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := ts.do(t, ts.newRequest(t, http.MethodGet, "/files/a.txt", nil)); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("GET without a file slot: status %d: %s", resp.StatusCode, body)
+	}
+	ts.assertNotStored(t, "b.txt")
+
+	release()
+	ts.uploadFile(t, "b.txt", "stored later")
+	if got, err := ts.download(t.Context(), "a.txt"); err != nil || string(got) != "stored" {
+		t.Fatalf("Download once a slot is free: %q, %v", got, err)
+	}
+}
@@ -0,0 +1,54 @@
+package uploadserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// decodeProblem checks resp is a problem+json response of status and returns its body
+func decodeProblem(t *testing.T, resp *http.Response, body []byte, status int) problem {
+	t.Helper()
+	if resp.StatusCode != status {
+		t.Fatalf("status %d, want %d: %s", resp.StatusCode, status, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type %q, want application/problem+json", ct)
+	}
+	var p problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("body %q: %v", body, err)
+	}
+	if p.Type != "about:blank" || p.Status != status || p.Title != http.StatusText(status) {
+		t.Fatalf("problem %+v does not match status %d", p, status)
+	}
+	return p
+}
+
+func TestProblemResponses(t *testing.T) {
+	ts := newTestServer(t, &Server{maxFileSize: 4})
+
+	resp, body := ts.do(t, ts.newRequest(t, http.MethodGet, "/files/missing.txt", nil))
+	if p := decodeProblem(t, resp, body, http.StatusNotFound); p.Code != "not_found" || p.Detail == "" {
+		t.Errorf("GET of a missing file: %+v", p)
+	}
+
+	resp, body = ts.putRange(t, "big.bin", "", "too large")
+	if p := decodeProblem(t, resp, body, http.StatusRequestEntityTooLarge); p.Code != "resource_exhausted" || !strings.Contains(p.Detail, "4") {
+		t.Errorf("PUT over the size limit: %+v", p)
+	}
+
+	req := ts.newRequest(t, http.MethodPost, "/upload", strings.NewReader("not multipart"))
+	req.Header.Set("Content-Type", "text/plain")
+	resp, body = ts.do(t, req)
+	if p := decodeProblem(t, resp, body, http.StatusBadRequest); p.Code != "invalid_argument" {
+		t.Errorf("multipart upload without a form: %+v", p)
+	}
+
+	// protocol errors of tus have no matching connect code
+	resp, body = ts.do(t, ts.tusRequest(t, http.MethodPost, tusBasePath, ""))
+	if p := decodeProblem(t, resp, body, http.StatusBadRequest); p.Code != "" || !strings.Contains(p.Detail, "Upload-Length") {
+		t.Errorf("tus create without Upload-Length: %+v", p)
+	}
+}
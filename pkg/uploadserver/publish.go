@@ -0,0 +1,76 @@
+package uploadserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// publishTimeout bounds one Publisher.Publish call
+const publishTimeout = 10 * time.Second
+
+// UploadCompleted is the event published for every stored upload
+type UploadCompleted struct {
+	Time time.Time `json:"time"`
+	// RPC is the endpoint that stored the file: Upload, UploadBidi, UploadFile, multipart,
+	// PutFile, tus, CopyFile or CloseArchive
+	RPC string `json:"rpc"`
+	// Filename as requested by the client, after sanitization
+	Filename string `json:"filename"`
+	// StoredFilename is the name to fetch the file with
+	StoredFilename string `json:"stored_filename"`
+	// StoragePath is where it landed, relative to the upload directory
+	StoragePath string `json:"storage_path"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	Peer        string `json:"peer"`
+	Identity    string `json:"identity,omitempty"`
+}
+
+// Publisher sends upload events to a message broker. Publish is called
+// concurrently, after the file is stored and before the client is answered.
+type Publisher interface {
+	Publish(ctx context.Context, event UploadCompleted) error
+}
+
+// uploaded runs the post-upload work for a file just stored under stored:
+// its thumbnail, then its upload event
+func (s *Server) uploaded(ctx context.Context, rpc, filename, stored string, size int64, hash string) error {
+	s.makeThumbnail(stored)
+	return s.publishUpload(ctx, rpc, filename, stored, size, hash)
+}
+
+// publishUpload reports a stored upload to the publisher, a no-op when none
+// is configured. A failure is only logged unless Config.PublishRequired makes
+// it fail the upload, which leaves the stored file in place.
+func (s *Server) publishUpload(ctx context.Context, rpc, filename, stored string, size int64, hash string) error {
+	if s.publisher == nil {
+		return nil
+	}
+	c := callerFrom(ctx)
+	// the upload is stored, publish even when the client has gone away
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+	err := s.publisher.Publish(ctx, UploadCompleted{
+		Time:           time.Now().UTC(),
+		RPC:            rpc,
+		Filename:       filename,
+		StoredFilename: baseName(stored),
+		StoragePath:    s.files.relPath(stored),
+		Size:           size,
+		SHA256:         hash,
+		Peer:           c.addr,
+		Identity:       c.identity,
+	})
+	if err == nil {
+		return nil
+	}
+	log.Printf("Publishing upload of %s failed: %v", s.redact.name(stored), err)
+	if !s.publishRequired {
+		return nil
+	}
+	return connect.NewError(connect.CodeUnavailable, fmt.Errorf("file stored but its upload event could not be published: %w", err))
+}
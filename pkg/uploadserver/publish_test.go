@@ -0,0 +1,115 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+)
+
+// memoryPublisher keeps the published events in memory, failing with err when set
+type memoryPublisher struct {
+	mu     sync.Mutex
+	events []UploadCompleted
+	err    error
+}
+
+func (p *memoryPublisher) Publish(ctx context.Context, event UploadCompleted) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+// published returns the events received so far
+func (p *memoryPublisher) published() []UploadCompleted {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]UploadCompleted(nil), p.events...)
+}
+
+func TestPublishUploadCompleted(t *testing.T) {
+	pub := &memoryPublisher{}
+	ts := newTestServer(t, &Server{publisher: pub})
+
+	ts.uploadFile(t, "unary.txt", "unary")
+	if _, err := ts.streamUpload(t.Context(), "streamed.txt", []byte("streamed"), 3); err != nil {
+		t.Fatal(err)
+	}
+	body, ct := multipartBody(t, "form.txt", "form", nil)
+	req := ts.newRequest(t, http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", ct)
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusOK {
+		t.Fatalf("multipart: status %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := ts.putRange(t, "put.txt", "", "put"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+	location := ts.tusCreate(t, "tus.txt", 3)
+	if resp := ts.tusPatch(t, location, 0, "tus"); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("tus PATCH: status %d", resp.StatusCode)
+	}
+	// an incomplete ranged upload stores nothing and publishes nothing
+	ts.putRange(t, "partial.txt", "bytes 0-1/4", "pa")
+
+	events := pub.published()
+	want := []struct{ rpc, name, data string }{
+		{"UploadFile", "unary.txt", "unary"},
+		{"Upload", "streamed.txt", "streamed"},
+		{"multipart", "form.txt", "form"},
+		{"PutFile", "put.txt", "put"},
+		{"tus", "tus.txt", "tus"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("%d events published, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.RPC != w.rpc || e.Filename != w.name || e.StoredFilename != w.name ||
+			e.Size != int64(len(w.data)) || e.SHA256 != sha256Hex(w.data) || e.Peer == "" || e.Time.IsZero() {
+			t.Errorf("event %d = %+v, want %s of %s", i, e, w.rpc, w.name)
+		}
+	}
+}
+
+func TestPublishFailure(t *testing.T) {
+	pub := &memoryPublisher{err: errors.New("broker down")}
+	ts := newTestServer(t, &Server{publisher: pub})
+	logged := captureLog(t)
+
+	// by default the failure is only logged
+	ts.uploadFile(t, "logged.txt", "kept")
+	if !strings.Contains(logged.String(), "broker down") {
+		t.Fatalf("publish failure not logged: %s", logged)
+	}
+
+	// with publishRequired the upload fails but the file stays stored
+	ts.srv.publishRequired = true
+	if _, err := ts.streamUpload(t.Context(), "required.txt", []byte("stored anyway"), 4); connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Fatalf("upload with an unpublished event: %v, want unavailable", err)
+	}
+	if got := ts.stored(t, "required.txt"); got != "stored anyway" {
+		t.Fatalf("stored %q after the publish failed", got)
+	}
+	if resp, body := ts.putRange(t, "put.txt", "", "put"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("PUT with an unpublished event: status %d: %s", resp.StatusCode, body)
+	}
+
+	// the retry overwrites the file and publishes
+	pub.mu.Lock()
+	pub.err = nil
+	pub.mu.Unlock()
+	if _, err := ts.streamUpload(t.Context(), "required.txt", []byte("stored anyway"), 4); err != nil {
+		t.Fatalf("retried upload: %v", err)
+	}
+	if events := pub.published(); len(events) != 1 || events[0].Filename != "required.txt" {
+		t.Fatalf("events after the retry: %+v", events)
+	}
+}
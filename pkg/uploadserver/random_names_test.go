@@ -0,0 +1,77 @@
+package uploadserver
+
+import (
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// uuidName matches a random version 4 UUID followed by an extension
+var uuidName = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}(\.[a-z]+)?$`)
+
+func TestRandomNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, err := openEventLog(path, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	ts := newTestServer(t, &Server{randomNames: true, events: l})
+
+	first := ts.uploadFile(t, "report.pdf", "first").StoredFilename
+	second := ts.uploadFile(t, "report.pdf", "second").StoredFilename
+	streamed, err := ts.streamUpload(t.Context(), "photo.jpg", []byte("streamed"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := ts.putRange(t, "notes.txt", "bytes 0-2/3", "put")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("ranged PUT: status %d: %s", resp.StatusCode, body)
+	}
+
+	names := map[string]string{
+		first:                   "first",
+		second:                  "second",
+		streamed.StoredFilename: "streamed",
+	}
+	if len(names) != 3 {
+		t.Fatalf("stored names are not unique: %q, %q, %q", first, second, streamed.StoredFilename)
+	}
+	for name, want := range names {
+		if !uuidName.MatchString(name) {
+			t.Errorf("stored name %q is not a UUID", name)
+		}
+		if got := ts.stored(t, name); got != want {
+			t.Errorf("%s holds %q, want %q", name, got, want)
+		}
+	}
+	if filepath.Ext(first) != ".pdf" || filepath.Ext(streamed.StoredFilename) != ".jpg" {
+		t.Errorf("extensions not kept: %q, %q", first, streamed.StoredFilename)
+	}
+	ts.assertNotStored(t, "report.pdf")
+	ts.assertNotStored(t, "photo.jpg")
+	ts.assertNotStored(t, "notes.txt")
+
+	// the events log keeps the requested name next to the stored one
+	events := readEvents(t, path)
+	if len(events) != 4 {
+		t.Fatalf("%d events logged, want 4: %+v", len(events), events)
+	}
+	if e := events[0]; e.Filename != "report.pdf" || e.StoredFilename != first {
+		t.Errorf("event of the first upload: %+v", e)
+	}
+	if e := events[2]; e.Filename != "photo.jpg" || e.StoredFilename != streamed.StoredFilename {
+		t.Errorf("event of the streamed upload: %+v", e)
+	}
+	if e := events[3]; e.Filename != "notes.txt" || !uuidName.MatchString(e.StoredFilename) || filepath.Ext(e.StoredFilename) != ".txt" {
+		t.Errorf("event of the ranged upload: %+v", e)
+	}
+}
+
+func TestStoredFilenameWithoutRandomNames(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if got := ts.uploadFile(t, "dir/a.txt", "kept").StoredFilename; got != "a.txt" {
+		t.Fatalf("stored_filename = %q, want the sanitized client name", got)
+	}
+}
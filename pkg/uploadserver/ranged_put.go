@@ -0,0 +1,482 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// partialDir holds incomplete resumable uploads inside the upload directory:
+// ranged partials at their scopedName, and the server's own state in
+// subdirectories no sanitized name can name
+const partialDir = ".partial"
+
+// defaultScope is the scope of the partial uploads of callers without a
+// client certificate
+const defaultScope = "default"
+
+// callerScope is the sanitized identity of the client certificate of the
+// caller of ctx, or defaultScope without one
+func callerScope(ctx context.Context) string {
+	if id := callerFrom(ctx).identity; id != "" {
+		return sanitizeFilename(id)
+	}
+	return defaultScope
+}
+
+// scopedName is the name the partial upload of filename by the caller of ctx
+// is kept under: its callerScope, "/" and filename. Two clients sending the
+// same filename in pieces never write into each other's partial file, and
+// neither can see or complete the other's upload.
+func scopedName(ctx context.Context, filename string) string {
+	return callerScope(ctx) + "/" + filename
+}
+
+// contentHashHeader carries the hex-encoded SHA-256 a PUT client declares for
+// the whole file, as a header or, hashing while it sends, as a trailer
+const contentHashHeader = "X-Content-Sha256"
+
+// byteRange is an inclusive range of bytes, as in Content-Range
+type byteRange struct{ start, end int64 }
+
+// rangedUpload tracks which byte ranges of a PUT upload have been received
+type rangedUpload struct {
+	total  int64       // declared total size, -1 while unknown
+	ranges []byteRange // sorted and merged
+}
+
+// add merges r into the received ranges, coalescing overlaps and neighbours
+func (u *rangedUpload) add(r byteRange) {
+	ranges := append(u.ranges, r)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	merged := ranges[:1]
+	for _, next := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if next.start <= last.end+1 {
+			last.end = max(last.end, next.end)
+			continue
+		}
+		merged = append(merged, next)
+	}
+	u.ranges = merged
+}
+
+// contiguous returns how many bytes have been received from offset 0 without a gap
+func (u *rangedUpload) contiguous() int64 {
+	if len(u.ranges) == 0 || u.ranges[0].start != 0 {
+		return 0
+	}
+	return u.ranges[0].end + 1
+}
+
+func (u *rangedUpload) complete() bool {
+	return u.total >= 0 && u.contiguous() == u.total
+}
+
+// check refuses r when it does not fit the declared size of the upload:
+// total, declared along with r or -1, or the one declared before. A total
+// declared for the first time must also hold every range already received.
+func (u *rangedUpload) check(total int64, r byteRange) error {
+	if total >= 0 && u.total >= 0 && u.total != total {
+		return fmt.Errorf("Content-Range total changed from %d to %d", u.total, total)
+	}
+	if total < 0 {
+		total = u.total
+	}
+	if total < 0 {
+		return nil
+	}
+	if r.end >= total {
+		return fmt.Errorf("bytes %d-%d are past the declared total of %d", r.start, r.end, total)
+	}
+	if n := len(u.ranges); n > 0 && u.ranges[n-1].end >= total {
+		return fmt.Errorf("bytes up to %d were already received, past the declared total of %d", u.ranges[n-1].end, total)
+	}
+	return nil
+}
+
+// rangedUploads holds the in-progress ranged uploads keyed by scopedName,
+// saved in dir so they survive restarts
+type rangedUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*rangedUpload
+	dir     string // rangesDir, nothing is saved when empty
+	sync    bool   // flush every saved state
+}
+
+// add records a received range and reports the resulting state; a completed
+// upload is forgotten so the next PUT to the same name starts afresh. Failing
+// to save the state is a connect error, other errors are not.
+func (t *rangedUploads) add(name string, total int64, r byteRange) (rangedUpload, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := t.get(name)
+	if u == nil {
+		if t.uploads == nil {
+			t.uploads = make(map[string]*rangedUpload)
+		}
+		u = &rangedUpload{total: -1}
+		t.uploads[name] = u
+	}
+	if err := u.check(total, r); err != nil {
+		return *u, err
+	}
+	if total >= 0 {
+		u.total = total
+	}
+	u.add(r)
+	if u.complete() {
+		t.forget(name)
+		return *u, nil
+	}
+	if err := t.save(name, u); err != nil {
+		return *u, connect.NewError(connect.CodeInternal, fmt.Errorf("save received ranges: %w", err))
+	}
+	return *u, nil
+}
+
+// check refuses r, declared with total or -1, when it does not fit the upload
+// of name, before it is written
+func (t *rangedUploads) check(name string, total int64, r byteRange) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if u := t.get(name); u != nil {
+		return u.check(total, r)
+	}
+	return nil
+}
+
+// resume replaces the state of name, for an upload whose partial file was
+// put in place by other means
+func (t *rangedUploads) resume(name string, u rangedUpload) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.uploads == nil {
+		t.uploads = make(map[string]*rangedUpload)
+	}
+	t.uploads[name] = &u
+	return t.save(name, &u)
+}
+
+// status returns the state of the in-progress upload of name, empty when there is none
+func (t *rangedUploads) status(name string) rangedUpload {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if u := t.get(name); u != nil {
+		return *u
+	}
+	return rangedUpload{total: -1}
+}
+
+// parseContentRange parses "bytes start-end/total" where total may be "*"
+func parseContentRange(h string) (byteRange, int64, error) {
+	spec, ok := strings.CutPrefix(h, "bytes ")
+	if !ok {
+		return byteRange{}, 0, errors.New("Content-Range must use the bytes unit")
+	}
+	rng, totalStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return byteRange{}, 0, errors.New("Content-Range is missing the total size")
+	}
+	startStr, endStr, ok := strings.Cut(rng, "-")
+	if !ok {
+		return byteRange{}, 0, errors.New("Content-Range is missing the byte range")
+	}
+	start, err1 := strconv.ParseInt(startStr, 10, 64)
+	end, err2 := strconv.ParseInt(endStr, 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return byteRange{}, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	total := int64(-1)
+	if totalStr != "*" {
+		t, err := strconv.ParseInt(totalStr, 10, 64)
+		if err != nil || end >= t {
+			return byteRange{}, 0, fmt.Errorf("invalid Content-Range %q", h)
+		}
+		total = t
+	}
+	return byteRange{start, end}, total, nil
+}
+
+// handlePutFile stores the request body as /files/{name}. Without a
+// Content-Range header the body is the whole file; with one, the bytes are
+// written at their offset into a partial file (in any order, overlaps allowed)
+// and the file is moved into place once every byte up to the declared total
+// has been received. Incomplete uploads answer 202 with a Range header giving
+// the contiguous prefix received so far, which "Content-Range: bytes */*"
+// with no body asks for without writing. Uploads sending an Idempotency-Key
+// are only stored with the X-Content-Sha256 they declare, and repeating the
+// key of a stored one answers its result again.
+func (s *Server) handlePutFile(w http.ResponseWriter, r *http.Request) {
+	var (
+		filename = sanitizeFilename(r.PathValue("name"))
+		resp     *fileuploadv1.UploadResponse
+		done     bool
+		err      error
+	)
+	defer func() {
+		if done || err != nil {
+			s.recordUpload(r.Context(), "PutFile", filename, resp.GetStoredFilename(), resp.GetSize(), resp.GetHashOk(), err)
+		}
+	}()
+
+	if err = s.checkUploadable(); err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if err = s.checkExtension(r.Context(), filename); err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	cr := r.Header.Get("Content-Range")
+	if strings.HasPrefix(cr, "bytes */") {
+		// a status query writes nothing, it answers like an incomplete upload
+		s.writeRangeStatus(w, s.ranged.status(scopedName(r.Context(), filename)))
+		return
+	}
+	keyed, err := s.beginKeyed(r.Context(), r.Header, filename, r.Header.Get(contentHashHeader))
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if keyed.Result != nil {
+		// answered before, the upload is not recorded again
+		result, err := keyedResult(keyed)
+		if err == nil {
+			err = writePutResult(w, result)
+		}
+		if err != nil {
+			writeHTTPError(w, err)
+		}
+		return
+	}
+
+	var state rangedUpload
+	if cr == "" {
+		resp, err = s.putWholeFile(filename, keyed.SHA256, r)
+		done = true
+	} else {
+		state, err = s.putRange(filename, cr, r)
+		done = state.complete()
+		if err == nil && done {
+			resp, err = s.finishRangedUpload(filename, state.total, keyed.SHA256, r)
+		}
+	}
+	if err == nil && done {
+		err = s.uploaded(r.Context(), "PutFile", filename, resp.StoredFilename, resp.Size, resp.Sha256)
+	}
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	if !done {
+		s.writeRangeStatus(w, state)
+		return
+	}
+
+	s.completeKeyed(r.Context(), keyed, resp)
+	if err := writePutResult(w, resp); err != nil {
+		writeHTTPError(w, err)
+	}
+}
+
+// writeRangeStatus answers an incomplete ranged upload with 202 and the
+// contiguous prefix received so far in the Range header
+func (s *Server) writeRangeStatus(w http.ResponseWriter, state rangedUpload) {
+	if n := state.contiguous(); n > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// writePutResult answers a stored PUT upload with 201 and resp as JSON
+func writePutResult(w http.ResponseWriter, resp *fileuploadv1.UploadResponse) error {
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+	return nil
+}
+
+// putWholeFile stores the body of r as filename. Content without the hash
+// want, when set, is discarded with DataLoss.
+func (s *Server) putWholeFile(filename, want string, r *http.Request) (*fileuploadv1.UploadResponse, error) {
+	if err := s.checkFileSize(r.ContentLength); err != nil {
+		return nil, err
+	}
+	stored := s.storedName(r.Context(), filename)
+	if err := s.checkRetention(stored); err != nil {
+		return nil, err
+	}
+	staged, err := s.stagedPath(stored)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	size, serverHash, err := s.writeFile(r.Context(), staged, r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if want != "" && want != serverHash {
+		log.Printf("HASH MISMATCH! Discarding keyed upload of %s", s.redact.name(filename))
+		os.Remove(staged)
+		return nil, connect.NewError(connect.CodeDataLoss, errKeyedHashMismatch)
+	}
+	// a hash in the trailer of a chunked body commits the content like the
+	// finish_commit of an Upload stream; one in the header is only reported
+	if h := r.Trailer.Get(contentHashHeader); h != "" && r.Header.Get(contentHashHeader) == "" && h != serverHash {
+		log.Printf("HASH MISMATCH! Discarding upload of %s", s.redact.name(filename))
+		os.Remove(staged)
+		return nil, connect.NewError(connect.CodeDataLoss, errors.New("checksum mismatch"))
+	}
+	if err := s.placeStored(staged, stored, serverHash); err != nil {
+		return nil, err
+	}
+	return s.putResponse(stored, size, serverHash, r), nil
+}
+
+func (s *Server) putRange(filename, contentRange string, r *http.Request) (rangedUpload, error) {
+	rng, total, err := parseContentRange(contentRange)
+	if err != nil {
+		return rangedUpload{}, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return s.writeRange(r.Context(), filename, total, rng, r.Body)
+}
+
+// writeRange writes body at rng into the partial file the caller of ctx keeps
+// for filename and records it; total is the declared size of the whole file,
+// -1 while unknown
+func (s *Server) writeRange(ctx context.Context, filename string, total int64, rng byteRange, body io.Reader) (rangedUpload, error) {
+	if err := s.checkFileSize(max(total, rng.end+1)); err != nil {
+		return rangedUpload{}, err
+	}
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return rangedUpload{}, err
+	}
+	defer release()
+
+	// stray bytes past the total would end up in the stored file
+	key := scopedName(ctx, filename)
+	if err := s.ranged.check(key, total, rng); err != nil {
+		return rangedUpload{}, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	partPath := filepath.Join(s.dir, partialDir, key)
+	if err := os.MkdirAll(filepath.Dir(partPath), 0755); err != nil {
+		return rangedUpload{}, connect.NewError(connect.CodeInternal, err)
+	}
+	if _, err := os.Stat(partPath); errors.Is(err, os.ErrNotExist) {
+		// the first range starts a new incomplete upload
+		if err := s.checkSessionCapacity(); err != nil {
+			return rangedUpload{}, err
+		}
+	}
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return rangedUpload{}, connect.NewError(connect.CodeInternal, err)
+	}
+	want := rng.end - rng.start + 1
+	n, err := io.Copy(io.NewOffsetWriter(f, rng.start), io.LimitReader(body, want))
+	if err == nil {
+		err = s.syncChunk(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return rangedUpload{}, writeError(err)
+	}
+	if n != want {
+		return rangedUpload{}, connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("body has %d bytes but Content-Range declares %d", n, want))
+	}
+
+	state, err := s.ranged.add(key, total, rng)
+	if err != nil {
+		if connect.CodeOf(err) == connect.CodeUnknown {
+			err = connect.NewError(connect.CodeInvalidArgument, err)
+		}
+		return state, err
+	}
+	log.Printf("PutFile: %s received bytes %d-%d (%d/%d contiguous)", s.redact.name(key), rng.start, rng.end, state.contiguous(), state.total)
+	return state, nil
+}
+
+// finishRangedUpload moves a completed partial file of total bytes into
+// place, as long as it has the hash want when set
+func (s *Server) finishRangedUpload(filename string, total int64, want string, r *http.Request) (*fileuploadv1.UploadResponse, error) {
+	stored, serverHash, err := s.finishPartial(r.Context(), filename, total, want)
+	if err != nil {
+		return nil, err
+	}
+	return s.putResponse(stored, total, serverHash, r), nil
+}
+
+// finishPartial moves the completed partial file the caller of ctx kept for
+// filename into place, cut to total bytes, and returns the name it is stored
+// under and its hex-encoded SHA-256. With want set, a partial file with other
+// content is discarded instead and DataLoss returned.
+func (s *Server) finishPartial(ctx context.Context, filename string, total int64, want string) (string, string, error) {
+	partPath := filepath.Join(s.dir, partialDir, scopedName(ctx, filename))
+	// bytes past the total may linger from before it was declared
+	if err := os.Truncate(partPath, total); err != nil {
+		return "", "", connect.NewError(connect.CodeInternal, err)
+	}
+	if err := s.syncPath(partPath); err != nil {
+		return "", "", writeError(err)
+	}
+	serverHash, err := hashStored(ctx, partPath)
+	if err != nil {
+		return "", "", connect.NewError(connect.CodeInternal, err)
+	}
+	if want != "" && want != serverHash {
+		log.Printf("HASH MISMATCH! Discarding keyed upload of %s", s.redact.name(filename))
+		os.Remove(partPath)
+		return "", "", connect.NewError(connect.CodeDataLoss, errKeyedHashMismatch)
+	}
+	stored := s.storedName(ctx, filename)
+	if err := s.placeStored(partPath, stored, serverHash); err != nil {
+		return "", "", err
+	}
+	return stored, serverHash, nil
+}
+
+// putResponse compares the stored hash with the optional X-Content-Sha256
+// request header or trailer, read once the body is
+func (s *Server) putResponse(filename string, size int64, serverHash string, r *http.Request) *fileuploadv1.UploadResponse {
+	clientHash := r.Header.Get(contentHashHeader)
+	if clientHash == "" {
+		clientHash = r.Trailer.Get(contentHashHeader)
+	}
+	hashOk := serverHash == clientHash
+	log.Printf("PutFile complete: %s (%d bytes)", s.redact.name(filename), size)
+	log.Printf("Hash verification - Server: %s, Client: %s, OK: %v", s.redact.hash(serverHash), s.redact.hash(clientHash), hashOk)
+	return &fileuploadv1.UploadResponse{
+		Message:        "ok",
+		Size:           size,
+		HashOk:         hashOk,
+		HashStatus:     hashStatus(serverHash, clientHash),
+		Sha256:         serverHash,
+		StoredFilename: baseName(filename),
+		StoragePath:    s.files.relPath(filename),
+	}
+}
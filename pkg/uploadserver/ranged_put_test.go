@@ -0,0 +1,353 @@
+package uploadserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// putRange PUTs body to /files/{name} with the Content-Range header cr, empty for none
+func (ts *testServer) putRange(t *testing.T, name, cr, body string) (*http.Response, []byte) {
+	t.Helper()
+	req := ts.newRequest(t, http.MethodPut, "/files/"+name, strings.NewReader(body))
+	if cr != "" {
+		req.Header.Set("Content-Range", cr)
+	}
+	return ts.do(t, req)
+}
+
+// partialPath is where a ranged upload of name by a caller without a client
+// certificate is kept
+func (ts *testServer) partialPath(name string) string {
+	return filepath.Join(ts.dir, partialDir, scopedName(context.Background(), name))
+}
+
+// uploadChunk sends data at offset of name with UploadFile as the caller of ctx
+func (ts *testServer) uploadChunk(ctx context.Context, name string, offset int64, data string, last bool, hash string) (*fileuploadv1.UploadResponse, error) {
+	return ts.srv.UploadFile(ctx, &fileuploadv1.UploadFileRequest{
+		Filename: name,
+		Data:     []byte(data),
+		Sha256:   hash,
+		Offset:   &offset,
+		IsLast:   last,
+	})
+}
+
+// asCaller is ctx as seen by a request from a client certificate of identity
+func asCaller(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller{identity: identity, names: []string{identity}})
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header string
+		rng    byteRange
+		total  int64
+		ok     bool
+	}{
+		{"bytes 0-9/10", byteRange{0, 9}, 10, true},
+		{"bytes 5-9/*", byteRange{5, 9}, -1, true},
+		{"bytes 0-10/10", byteRange{}, 0, false},
+		{"bytes 9-5/10", byteRange{}, 0, false},
+		{"bytes -1-5/10", byteRange{}, 0, false},
+		{"bytes 0-9", byteRange{}, 0, false},
+		{"items 0-9/10", byteRange{}, 0, false},
+		{"bytes 0/10", byteRange{}, 0, false},
+	}
+	for _, tt := range tests {
+		rng, total, err := parseContentRange(tt.header)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseContentRange(%q) error %v, want ok %v", tt.header, err, tt.ok)
+			continue
+		}
+		if tt.ok && (rng != tt.rng || total != tt.total) {
+			t.Errorf("parseContentRange(%q) = %v, %d, want %v, %d", tt.header, rng, total, tt.rng, tt.total)
+		}
+	}
+}
+
+func TestRangedUploadMergesRanges(t *testing.T) {
+	u := rangedUpload{total: -1}
+	for _, r := range []byteRange{{10, 19}, {30, 39}, {0, 9}, {15, 32}} {
+		u.add(r)
+	}
+	if len(u.ranges) != 1 || u.ranges[0] != (byteRange{0, 39}) {
+		t.Fatalf("ranges %v, want one range 0-39", u.ranges)
+	}
+	if u.contiguous() != 40 || u.complete() {
+		t.Fatalf("contiguous %d, complete %v with an unknown total", u.contiguous(), u.complete())
+	}
+	u.total = 40
+	if !u.complete() {
+		t.Fatal("all 40 bytes received but not complete")
+	}
+}
+
+func TestRangedUploadCheck(t *testing.T) {
+	received := rangedUpload{total: -1, ranges: []byteRange{{50, 59}}}
+	declared := rangedUpload{total: 10, ranges: []byteRange{{0, 4}}}
+	tests := []struct {
+		name  string
+		u     rangedUpload
+		total int64
+		r     byteRange
+		ok    bool
+	}{
+		{"unknown total", received, -1, byteRange{100, 200}, true},
+		{"total below a received range", received, 10, byteRange{0, 9}, false},
+		{"total holding every range", received, 60, byteRange{0, 9}, true},
+		{"range past the declared total", declared, -1, byteRange{10, 19}, false},
+		{"range within the declared total", declared, -1, byteRange{5, 9}, true},
+		{"total changed", declared, 20, byteRange{5, 9}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.u.check(tt.total, tt.r); (err == nil) != tt.ok {
+			t.Errorf("%s: check error %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestRangedPut(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	// out of order and overlapping, the total declared on any of them
+	resp, _ := ts.putRange(t, "data.bin", "bytes 6-10/*", "world")
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Range") != "" {
+		t.Fatalf("first range: status %d, Range %q", resp.StatusCode, resp.Header.Get("Range"))
+	}
+	resp, _ = ts.putRange(t, "data.bin", "bytes 0-3/11", "hell")
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Range") != "bytes=0-3" {
+		t.Fatalf("second range: status %d, Range %q", resp.StatusCode, resp.Header.Get("Range"))
+	}
+	req := ts.newRequest(t, http.MethodPut, "/files/data.bin", strings.NewReader("llo w"))
+	req.Header.Set("Content-Range", "bytes 2-6/11")
+	req.Header.Set("X-Content-Sha256", sha256Hex("hello world"))
+	resp, body := ts.do(t, req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("last range: status %d, body %s", resp.StatusCode, body)
+	}
+	var result struct {
+		Size   string `json:"size"`
+		HashOk bool   `json:"hashOk"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Size != "11" || !result.HashOk {
+		t.Fatalf("response %s", body)
+	}
+	if got := ts.stored(t, "data.bin"); got != "hello world" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestRangedPutWholeFile(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	resp, body := ts.putRange(t, "whole.txt", "", "content")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status %d, body %s", resp.StatusCode, body)
+	}
+	if got := ts.stored(t, "whole.txt"); got != "content" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+// putTrailer sends body as a chunked PUT of name with hash in the
+// X-Content-Sha256 trailer
+func (ts *testServer) putTrailer(t *testing.T, name, body, hash string) (*http.Response, []byte) {
+	t.Helper()
+	// a reader of unknown length makes the body chunked
+	req := ts.newRequest(t, http.MethodPut, "/files/"+name, io.MultiReader(strings.NewReader(body)))
+	req.Trailer = http.Header{contentHashHeader: {hash}}
+	return ts.do(t, req)
+}
+
+func TestRangedPutTrailerHash(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	resp, body := ts.putTrailer(t, "a.txt", "trailed", sha256Hex("trailed"))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status %d, body %s", resp.StatusCode, body)
+	}
+	var stored fileuploadv1.UploadResponse
+	if err := protojson.Unmarshal(body, &stored); err != nil || !stored.HashOk {
+		t.Fatalf("response %s (%v), want hash_ok from the trailer", body, err)
+	}
+
+	// a trailer hash that does not match discards the upload
+	resp, body = ts.putTrailer(t, "a.txt", "corrupted", sha256Hex("trailed"))
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(string(body), "checksum mismatch") {
+		t.Fatalf("mismatching trailer: status %d, body %s", resp.StatusCode, body)
+	}
+	// and like a stream failing its commit, leaves no corrupt file behind
+	if _, err := os.Stat(filepath.Join(ts.dir, "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("corrupt upload kept: %v", err)
+	}
+
+	// a header hash is only reported, as before
+	req := ts.newRequest(t, http.MethodPut, "/files/b.txt", strings.NewReader("headed"))
+	req.Header.Set(contentHashHeader, sha256Hex("other"))
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("mismatching header: status %d, body %s", resp.StatusCode, body)
+	}
+}
+
+func TestRangedPutRejectsBytesPastTotal(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if resp, _ := ts.putRange(t, "a.bin", "bytes 50-59/*", "0123456789"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("stray range: status %d", resp.StatusCode)
+	}
+	// the total now declared does not hold the bytes already received
+	if resp, _ := ts.putRange(t, "a.bin", "bytes 0-9/10", "0123456789"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("total below a received range: status %d, want 400", resp.StatusCode)
+	}
+
+	if resp, _ := ts.putRange(t, "b.bin", "bytes 0-4/10", "01234"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("first range: status %d", resp.StatusCode)
+	}
+	if resp, _ := ts.putRange(t, "b.bin", "bytes 10-19/*", "0123456789"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("range past the declared total: status %d, want 400", resp.StatusCode)
+	}
+	if resp, _ := ts.putRange(t, "b.bin", "bytes 5-9/*", "56789"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("last range: status %d", resp.StatusCode)
+	}
+	if got := ts.stored(t, "b.bin"); got != "0123456789" {
+		t.Fatalf("stored %q, want exactly the 10 declared bytes", got)
+	}
+}
+
+func TestUploadFileOffsetChunks(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	ctx := t.Context()
+	resp, err := ts.uploadChunk(ctx, "chunks.bin", 4, "4567", false, "")
+	if err != nil || resp.Message != "chunk received" || resp.Size != 0 {
+		t.Fatalf("chunk past a gap = %v, %v", resp, err)
+	}
+	// the last chunk may arrive before the ones it follows
+	if resp, err = ts.uploadChunk(ctx, "chunks.bin", 8, "89", true, ""); err != nil || resp.Size != 0 {
+		t.Fatalf("last chunk = %v, %v", resp, err)
+	}
+	ts.assertNotStored(t, "chunks.bin")
+	// resending a chunk, as a client retrying would, is harmless
+	if _, err = ts.uploadChunk(ctx, "chunks.bin", 4, "4567", false, ""); err != nil {
+		t.Fatalf("resent chunk: %v", err)
+	}
+	resp, err = ts.uploadChunk(ctx, "chunks.bin", 0, "0123", false, sha256Hex("0123456789"))
+	if err != nil {
+		t.Fatalf("completing chunk: %v", err)
+	}
+	if resp.Message != "ok" || resp.Size != 10 || !resp.HashOk || resp.Sha256 != sha256Hex("0123456789") {
+		t.Fatalf("completed upload = %v", resp)
+	}
+	if got := ts.stored(t, "chunks.bin"); got != "0123456789" {
+		t.Fatalf("stored %q", got)
+	}
+	if _, err := os.Stat(ts.partialPath("chunks.bin")); !os.IsNotExist(err) {
+		t.Fatalf("partial file kept after completion: %v", err)
+	}
+}
+
+func TestUploadFileOffsetChunksHashMismatch(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	resp, err := ts.uploadChunk(t.Context(), "bad.bin", 0, "data", true, sha256Hex("other"))
+	if err != nil || resp.HashOk {
+		t.Fatalf("chunk completing with a wrong hash = %v, %v", resp, err)
+	}
+}
+
+func TestUploadFileOffsetChunksRejected(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	offset, negative := int64(0), int64(-1)
+	for name, req := range map[string]*fileuploadv1.UploadFileRequest{
+		"negative offset": {Filename: "a.bin", Data: []byte("data"), Offset: &negative},
+		"dry run":         {Filename: "a.bin", Data: []byte("data"), Offset: &offset, DryRun: true},
+		"no data":         {Filename: "a.bin", Offset: &offset},
+	} {
+		if _, err := ts.client.UploadFile(t.Context(), req); connect.CodeOf(err) != connect.CodeInvalidArgument {
+			t.Errorf("%s: %v, want invalid argument", name, err)
+		}
+	}
+	if _, err := ts.uploadChunk(t.Context(), "d.bin", 4, "4567", true, ""); err != nil {
+		t.Fatal(err)
+	}
+	// a chunk past the end the last chunk gave
+	if _, err := ts.uploadChunk(t.Context(), "d.bin", 8, "89", false, ""); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("chunk past the last one: %v, want invalid argument", err)
+	}
+}
+
+func TestUploadFileOffsetChunksScopedByCaller(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	alice := asCaller(t.Context(), "alice")
+	bob := asCaller(t.Context(), "bob")
+
+	if _, err := ts.uploadChunk(alice, "report.bin", 0, "AAAA", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.uploadChunk(bob, "report.bin", 0, "BBBBBBBB", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	// the end alice declares does not refuse bob's bytes past it
+	if _, err := ts.uploadChunk(alice, "report.bin", 4, "aa", true, sha256Hex("AAAAaa")); err != nil {
+		t.Fatalf("alice's last chunk: %v", err)
+	}
+	if got := ts.stored(t, "report.bin"); got != "AAAAaa" {
+		t.Fatalf("alice's upload stored %q", got)
+	}
+	// nor does a caller without a certificate complete bob's upload
+	if resp, body := ts.putRange(t, "report.bin", "bytes 8-9/10", "xx"); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("anonymous range: status %d: %s", resp.StatusCode, body)
+	}
+	resp, err := ts.uploadChunk(bob, "report.bin", 8, "bb", true, sha256Hex("BBBBBBBBbb"))
+	if err != nil || !resp.HashOk {
+		t.Fatalf("bob's last chunk = %v, %v", resp, err)
+	}
+	if got := ts.stored(t, "report.bin"); got != "BBBBBBBBbb" {
+		t.Fatalf("bob's upload stored %q", got)
+	}
+	if b, err := os.ReadFile(ts.partialPath("report.bin")); err != nil || len(b) != 10 || string(b[8:]) != "xx" {
+		t.Fatalf("anonymous partial holds %q, %v", b, err)
+	}
+}
+
+func TestGetUploadStatus(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	alice := asCaller(t.Context(), "alice")
+	status := func(ctx context.Context) *fileuploadv1.GetUploadStatusResponse {
+		t.Helper()
+		resp, err := ts.srv.GetUploadStatus(ctx, &fileuploadv1.GetUploadStatusRequest{Filename: "../status.bin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if got := status(alice); got.Filename != "status.bin" || got.Offset != 0 || got.TotalSize != nil {
+		t.Fatalf("status before any chunk = %v", got)
+	}
+	ts.uploadChunk(alice, "status.bin", 0, "0123", false, "")
+	ts.uploadChunk(alice, "status.bin", 8, "89", true, "")
+	if got := status(alice); got.Offset != 4 || got.GetTotalSize() != 10 {
+		t.Fatalf("status with a gap = %v, want offset 4 of 10", got)
+	}
+	// other callers see only their own uploads of the name
+	if got := status(asCaller(t.Context(), "bob")); got.Offset != 0 || got.TotalSize != nil {
+		t.Fatalf("bob sees alice's upload: %v", got)
+	}
+	if got := status(t.Context()); got.Offset != 0 {
+		t.Fatalf("a caller without a certificate sees alice's upload: %v", got)
+	}
+
+	ts.uploadChunk(alice, "status.bin", 4, "4567", false, "")
+	if got := status(alice); got.Offset != 0 || got.TotalSize != nil {
+		t.Fatalf("status once complete = %v, want no upload in progress", got)
+	}
+}
@@ -0,0 +1,89 @@
+package uploadserver
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// rangesDir holds, inside partialDir, the received ranges of every ranged PUT
+// and chunked UploadFile upload at its scopedName, so they resume where they
+// stopped after a server restart instead of from 0
+const rangesDir = ".ranges"
+
+// savedRanges is the persisted form of a rangedUpload
+type savedRanges struct {
+	Total  int64      `json:"total"`
+	Ranges [][2]int64 `json:"ranges"`
+}
+
+func (t *rangedUploads) statePath(name string) string {
+	return filepath.Join(t.dir, name+".json")
+}
+
+// get returns the in-progress upload of name, loading its saved ranges the
+// first time after a restart, or nil when there is none. Callers hold t.mu.
+func (t *rangedUploads) get(name string) *rangedUpload {
+	if u, ok := t.uploads[name]; ok {
+		return u
+	}
+	if t.dir == "" {
+		return nil
+	}
+	b, err := os.ReadFile(t.statePath(name))
+	if err != nil {
+		return nil
+	}
+	var saved savedRanges
+	if err := json.Unmarshal(b, &saved); err != nil {
+		os.Remove(t.statePath(name))
+		return nil
+	}
+	// ranges outlive their partial file when it was removed or completed
+	// while the server was down
+	if _, err := os.Stat(filepath.Join(filepath.Dir(t.dir), name)); err != nil {
+		os.Remove(t.statePath(name))
+		return nil
+	}
+	u := &rangedUpload{total: saved.Total}
+	for _, r := range saved.Ranges {
+		if r[0] >= 0 && r[0] <= r[1] && (saved.Total < 0 || r[1] < saved.Total) {
+			u.add(byteRange{r[0], r[1]})
+		}
+	}
+	if t.uploads == nil {
+		t.uploads = make(map[string]*rangedUpload)
+	}
+	t.uploads[name] = u
+	return u
+}
+
+// save persists the ranges of name, replacing the previous state
+// atomically. Callers hold t.mu.
+func (t *rangedUploads) save(name string, u *rangedUpload) error {
+	if t.dir == "" {
+		return nil
+	}
+	saved := savedRanges{Total: u.total, Ranges: make([][2]int64, len(u.ranges))}
+	for i, r := range u.ranges {
+		saved.Ranges[i] = [2]int64{r.start, r.end}
+	}
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	path := t.statePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b, t.sync)
+}
+
+// forget drops the state of name from memory and disk. Callers hold t.mu.
+func (t *rangedUploads) forget(name string) {
+	delete(t.uploads, name)
+	if t.dir != "" {
+		// a state left behind is dropped by get once its partial file is gone
+		os.Remove(t.statePath(name))
+	}
+}
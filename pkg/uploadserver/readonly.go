@@ -0,0 +1,19 @@
+package uploadserver
+
+import (
+	"errors"
+
+	"connectrpc.com/connect"
+)
+
+var errReadOnly = errors.New("read-only mode")
+
+// checkWritesAllowed refuses uploads and other changes to stored files while the
+// server is in read-only mode or storage is unhealthy. Downloads and metadata
+// are not affected.
+func (s *Server) checkWritesAllowed() error {
+	if s.readOnly != nil && s.readOnly() {
+		return connect.NewError(connect.CodeUnavailable, errReadOnly)
+	}
+	return s.storage.checkAvailable()
+}
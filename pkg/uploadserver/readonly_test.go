@@ -0,0 +1,90 @@
+package uploadserver
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	var readOnly atomic.Bool
+	ts := newTestServer(t, &Server{readOnly: readOnly.Load})
+	ts.uploadFile(t, "a.txt", "stored before")
+	readOnly.Store(true)
+
+	unavailable := func(what string, err error) {
+		t.Helper()
+		if connect.CodeOf(err) != connect.CodeUnavailable || !strings.Contains(err.Error(), "read-only mode") {
+			t.Errorf("%s in read-only mode: %v, want unavailable: read-only mode", what, err)
+		}
+	}
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "b.txt", Data: []byte("refused")})
+	unavailable("UploadFile", err)
+	_, err = ts.streamUpload(t.Context(), "b.txt", []byte("refused"), 4)
+	unavailable("Upload", err)
+	_, err = ts.uploadChunk(t.Context(), "b.txt", 0, "ref", false, "")
+	unavailable("chunked UploadFile", err)
+	_, err = ts.client.RenameFile(t.Context(), &fileuploadv1.RenameFileRequest{From: "a.txt", To: "b.txt"})
+	unavailable("RenameFile", err)
+	_, err = ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "a.txt", To: "b.txt"})
+	unavailable("CopyFile", err)
+
+	if resp, body := ts.putRange(t, "b.txt", "", "refused"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("PUT in read-only mode: status %d: %s", resp.StatusCode, body)
+	}
+	body, ct := multipartBody(t, "b.txt", "refused", nil)
+	req := ts.newRequest(t, http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", ct)
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("multipart upload in read-only mode: status %d: %s", resp.StatusCode, body)
+	}
+	req = ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", "2")
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("tus creation in read-only mode: status %d: %s", resp.StatusCode, body)
+	}
+	ts.assertNotStored(t, "b.txt")
+
+	// reads keep working
+	if got, err := ts.download(t.Context(), "a.txt"); err != nil || string(got) != "stored before" {
+		t.Errorf("Download in read-only mode: %q, %v", got, err)
+	}
+	if _, err := ts.client.GetFileMetadata(t.Context(), &fileuploadv1.GetFileMetadataRequest{Filename: "a.txt"}); err != nil {
+		t.Errorf("GetFileMetadata in read-only mode: %v", err)
+	}
+	if resp, body := ts.do(t, ts.newRequest(t, http.MethodGet, "/files/a.txt", nil)); resp.StatusCode != http.StatusOK || string(body) != "stored before" {
+		t.Errorf("GET in read-only mode: status %d: %s", resp.StatusCode, body)
+	}
+
+	// leaving the mode takes effect at once
+	readOnly.Store(false)
+	ts.uploadFile(t, "b.txt", "stored after")
+}
+
+func TestReadOnlyModeKeepsPartials(t *testing.T) {
+	var readOnly atomic.Bool
+	ts := newTestServer(t, &Server{readOnly: readOnly.Load, partialMaxAge: time.Hour})
+	ts.putRange(t, "stale.bin", "bytes 0-3/8", "data")
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(ts.partialPath("stale.bin"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly.Store(true)
+	ts.srv.sweepPartials()
+	if _, err := os.Stat(ts.partialPath("stale.bin")); err != nil {
+		t.Fatalf("abandoned partial removed in read-only mode: %v", err)
+	}
+	readOnly.Store(false)
+	ts.srv.sweepPartials()
+	if _, err := os.Stat(ts.partialPath("stale.bin")); !os.IsNotExist(err) {
+		t.Fatalf("abandoned partial kept once read-only mode ended: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package uploadserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// redactor rewrites filenames, titles and content hashes for the server log.
+// The zero value logs them unchanged.
+type redactor struct {
+	names  bool
+	hashes bool
+}
+
+// name replaces a filename or title with a short digest of it, so log lines
+// about the same file can still be correlated without revealing it
+func (r redactor) name(s string) string {
+	if !r.names || s == "" {
+		return s
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "redacted-" + hex.EncodeToString(sum[:4])
+}
+
+// title strips control characters from a title, so a newline cannot forge
+// a log line, then redacts it like a filename
+func (r redactor) title(s string) string {
+	return r.name(strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) {
+			return -1
+		}
+		return c
+	}, s))
+}
+
+// hash truncates a hex content hash to its first 8 characters
+func (r redactor) hash(h string) string {
+	if !r.hashes || len(h) <= 8 {
+		return h
+	}
+	return h[:8] + "..."
+}
+
+// err redacts the filename in the path of a filesystem error
+func (r redactor) err(err error) error {
+	if !r.names {
+		return err
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: r.name(filepath.Base(pathErr.Path)), Err: pathErr.Err}
+	}
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return &os.LinkError{Op: linkErr.Op, Old: r.name(filepath.Base(linkErr.Old)), New: r.name(filepath.Base(linkErr.New)), Err: linkErr.Err}
+	}
+	return err
+}
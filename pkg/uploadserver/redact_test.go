@@ -0,0 +1,77 @@
+package uploadserver
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	hash := sha256Hex("content")
+	var plain redactor
+	if plain.name("secret.pdf") != "secret.pdf" || plain.hash(hash) != hash {
+		t.Fatal("the zero redactor changed what it logs")
+	}
+
+	r := redactor{names: true, hashes: true}
+	name := r.name("secret.pdf")
+	if strings.Contains(name, "secret") || !strings.HasPrefix(name, "redacted-") || len(name) != len("redacted-")+8 {
+		t.Fatalf("name(secret.pdf) = %q", name)
+	}
+	if r.name("secret.pdf") != name || r.name("other.pdf") == name {
+		t.Fatal("redacted names do not correlate the lines about one file")
+	}
+	if r.name("") != "" {
+		t.Fatal("redacted an empty title")
+	}
+	if got := r.hash(hash); got != hash[:8]+"..." {
+		t.Fatalf("hash = %q", got)
+	}
+
+	err := r.err(&fs.PathError{Op: "open", Path: "/srv/uploads/secret.pdf", Err: fs.ErrNotExist})
+	if strings.Contains(err.Error(), "secret") || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("err = %v", err)
+	}
+	err = r.err(&os.LinkError{Op: "rename", Old: "/a/secret.pdf", New: "/b/secret.pdf", Err: fs.ErrExist})
+	if strings.Contains(err.Error(), "secret") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRedactedLogs(t *testing.T) {
+	logged := captureLog(t)
+	ts := newTestServer(t, &Server{redact: redactor{names: true, hashes: true}})
+	hash := sha256Hex("private content")
+
+	ts.uploadFile(t, "patient-record.pdf", "private content")
+	if _, err := ts.streamUpload(t.Context(), "patient-scan.png", []byte("private content"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := ts.putRange(t, "patient-notes.txt", "bytes 0-3/4", "note"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+	out := logged.String()
+	if out == "" {
+		t.Fatal("nothing logged")
+	}
+	if strings.Contains(out, "patient") || strings.Contains(out, hash) {
+		t.Fatalf("log reveals filenames or hashes:\n%s", out)
+	}
+	if !strings.Contains(out, redactor{names: true}.name("patient-record.pdf")) || !strings.Contains(out, hash[:8]+"...") {
+		t.Fatalf("log lacks the redacted forms:\n%s", out)
+	}
+}
+
+func TestRedactorTitle(t *testing.T) {
+	var plain redactor
+	if got := plain.title("line one\nline two\ttabbed\x00"); got != "line oneline twotabbed" {
+		t.Fatalf("title = %q", got)
+	}
+	r := redactor{names: true}
+	if got, want := r.title("secret\n"), r.name("secret"); got != want {
+		t.Fatalf("redacted title %q, want %q as for the stripped title", got, want)
+	}
+}
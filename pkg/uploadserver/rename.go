@@ -0,0 +1,142 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// RenameFile gives a stored file a new name, which may route it to another
+// subdirectory, or under Config.StorageKey to another key. Its hash index
+// entry and thumbnail follow it.
+func (s *Server) RenameFile(
+	ctx context.Context, req *fileuploadv1.RenameFileRequest) (*fileuploadv1.RenameFileResponse, error) {
+
+	from, to := sanitizeFilename(req.From), sanitizeFilename(req.To)
+	if err := s.checkWritesAllowed(); err != nil {
+		return nil, err
+	}
+	if err := s.checkExtension(ctx, to); err != nil {
+		return nil, err
+	}
+	from, to = s.lookupName(ctx, from), s.uploadName(ctx, to)
+	src := s.files.path(from)
+	info, err := os.Stat(src)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("file not found: %s", baseName(from)))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	resp := &fileuploadv1.RenameFileResponse{Filename: baseName(to)}
+	if from == to {
+		return resp, nil
+	}
+	for _, name := range []string{from, to} {
+		if err := s.checkRetention(name); err != nil {
+			return nil, err
+		}
+	}
+	hash, ok := s.index.hashOf(from)
+	if !ok {
+		if hash, err = hashFile(src); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	}
+	dst, key, err := s.writePath(to, hash, req.Overwrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return nil, writeError(err)
+	}
+	if err := s.moveFile(src, dst, req.Overwrite); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("%s already exists", baseName(to)))
+		}
+		return nil, writeError(err)
+	}
+	if err := errors.Join(s.syncDir(src), s.syncDir(dst)); err != nil {
+		return nil, writeError(err)
+	}
+	s.files.keys.set(from, "")
+	if key != "" {
+		s.keyed(to, key)
+	}
+	s.index.remove(from)
+	s.index.set(to, hash)
+	s.expiries.rename(from, to)
+	s.retention.rename(from, to)
+	os.Remove(s.thumbnailPath(to))
+	os.Rename(s.thumbnailPath(from), s.thumbnailPath(to))
+
+	log.Printf("Renamed: %s to %s", s.redact.name(from), s.redact.name(to))
+	return resp, nil
+}
+
+// moveFile renames src to dst, failing with fs.ErrExist when dst exists
+// unless overwrite. Across filesystems, such as a route subdirectory on
+// another mount, it copies then deletes src.
+func (s *Server) moveFile(src, dst string, overwrite bool) error {
+	if overwrite {
+		if err := os.Rename(src, dst); !errors.Is(err, syscall.EXDEV) {
+			return err
+		}
+	} else {
+		// a hard link refuses an existing dst atomically
+		err := os.Link(src, dst)
+		if err == nil {
+			return os.Remove(src)
+		}
+		if errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	// another filesystem, or one without hard links
+	if err := s.copyFile(src, dst, overwrite); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to dst, failing with fs.ErrExist when dst exists
+// unless overwrite. On Linux io.Copy uses copy_file_range, which filesystems
+// such as Btrfs and XFS serve by sharing extents instead of copying bytes.
+func (s *Server) copyFile(src, dst string, overwrite bool) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var out *os.File
+	if overwrite {
+		out, err = createStored(dst)
+	} else {
+		out, err = os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = s.syncCommit(out)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package uploadserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func (ts *testServer) rename(t *testing.T, from, to string, overwrite bool) (*fileuploadv1.RenameFileResponse, error) {
+	t.Helper()
+	return ts.client.RenameFile(t.Context(), &fileuploadv1.RenameFileRequest{From: from, To: to, Overwrite: overwrite})
+}
+
+func TestRenameFile(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.uploadFile(t, "draft.txt", "first draft")
+
+	resp, err := ts.rename(t, "draft.txt", "../final.txt", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Filename != "final.txt" {
+		t.Fatalf("renamed to %q, want the sanitized final.txt", resp.Filename)
+	}
+	if got := ts.stored(t, "final.txt"); got != "first draft" {
+		t.Fatalf("final.txt holds %q", got)
+	}
+	ts.assertNotStored(t, "draft.txt")
+	if hash, ok := ts.srv.index.hashOf("final.txt"); !ok || hash != sha256Hex("first draft") {
+		t.Fatalf("index entry of the new name: %q, %v", hash, ok)
+	}
+	if _, ok := ts.srv.index.hashOf("draft.txt"); ok {
+		t.Fatal("the index still holds the old name")
+	}
+
+	if _, err := ts.rename(t, "draft.txt", "other.txt", false); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("renaming a missing file: %v, want not found", err)
+	}
+	// renaming a file to its own name changes nothing
+	if _, err := ts.rename(t, "final.txt", "final.txt", false); err != nil || ts.stored(t, "final.txt") != "first draft" {
+		t.Fatalf("renaming to the same name: %v", err)
+	}
+}
+
+func TestRenameFileCollision(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.uploadFile(t, "a.txt", "from a")
+	ts.uploadFile(t, "b.txt", "from b")
+
+	if _, err := ts.rename(t, "a.txt", "b.txt", false); connect.CodeOf(err) != connect.CodeAlreadyExists {
+		t.Fatalf("renaming onto an existing file: %v, want already exists", err)
+	}
+	if ts.stored(t, "a.txt") != "from a" || ts.stored(t, "b.txt") != "from b" {
+		t.Fatal("a refused rename changed the files")
+	}
+
+	if _, err := ts.rename(t, "a.txt", "b.txt", true); err != nil {
+		t.Fatalf("renaming with overwrite: %v", err)
+	}
+	if got := ts.stored(t, "b.txt"); got != "from a" {
+		t.Fatalf("b.txt holds %q after the overwrite", got)
+	}
+	ts.assertNotStored(t, "a.txt")
+	if hash, _ := ts.srv.index.hashOf("b.txt"); hash != sha256Hex("from a") {
+		t.Fatalf("index holds %q for the overwritten file", hash)
+	}
+}
+
+func TestRenameFileAcrossRoutes(t *testing.T) {
+	ts := newRoutedServer(t, Route{Match: ".pdf", Dir: "docs"})
+	ts.uploadFile(t, "report.txt", "%PDF-1.7")
+
+	if _, err := ts.rename(t, "report.txt", "report.pdf", false); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(ts.dir, "docs", "report.pdf")); err != nil || string(b) != "%PDF-1.7" {
+		t.Fatalf("docs/report.pdf: %q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "report.txt")); !os.IsNotExist(err) {
+		t.Fatalf("report.txt left behind: %v", err)
+	}
+
+	// and back out of the subdirectory
+	if _, err := ts.rename(t, "report.pdf", "report.bin", false); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(ts.dir, "report.bin")); err != nil || string(b) != "%PDF-1.7" {
+		t.Fatalf("report.bin: %q, %v", b, err)
+	}
+}
+
+func TestMoveFileCopiesAcrossFilesystems(t *testing.T) {
+	s := &Server{}
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	os.WriteFile(src, []byte("copied"), 0644)
+	os.WriteFile(dst, []byte("kept"), 0644)
+
+	// the fallback used when rename and link cross a mount
+	if err := s.copyFile(src, dst, false); !os.IsExist(err) {
+		t.Fatalf("copy onto an existing file: %v, want it refused", err)
+	}
+	if err := s.copyFile(src, dst, true); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "copied" {
+		t.Fatalf("dst holds %q", b)
+	}
+}
+
+func TestRenameFileExtensionPolicy(t *testing.T) {
+	ts := newTestServer(t, &Server{extensions: map[string]ExtensionPolicy{"*": {Deny: []string{".exe"}}}})
+	ts.uploadFile(t, "setup.txt", "MZ")
+	if _, err := ts.rename(t, "setup.txt", "setup.exe", false); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("renaming to a denied extension: %v, want invalid argument", err)
+	}
+	ts.assertNotStored(t, "setup.exe")
+}
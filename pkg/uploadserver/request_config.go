@@ -0,0 +1,47 @@
+package uploadserver
+
+import (
+	"context"
+	"net/http"
+)
+
+// requestConfig is the configuration applying to the caller of one request,
+// resolved from its identity once by withRequestConfig so that the handlers
+// apply the caller's rules without looking them up again
+type requestConfig struct {
+	namespace  string
+	extensions *ExtensionPolicy // nil accepts every file
+}
+
+type requestConfigKey struct{}
+
+// withRequestConfig stores the configuration of the caller, set in the
+// context by withCaller, in the context of every request
+func (s *Server) withRequestConfig(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.resolveRequestConfig(callerFrom(r.Context()))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestConfigKey{}, cfg)))
+	})
+}
+
+// requestConfig returns the configuration stored in ctx by
+// withRequestConfig, resolving it from the caller in ctx when the request
+// did not pass through it
+func (s *Server) requestConfig(ctx context.Context) requestConfig {
+	if cfg, ok := ctx.Value(requestConfigKey{}).(requestConfig); ok {
+		return cfg
+	}
+	return s.resolveRequestConfig(callerFrom(ctx))
+}
+
+func (s *Server) resolveRequestConfig(c caller) requestConfig {
+	cfg := requestConfig{namespace: c.namespace}
+	if len(s.extensions) > 0 {
+		policy, ok := s.extensions[c.namespace]
+		if !ok {
+			policy = s.extensions[defaultPolicyNamespace]
+		}
+		cfg.extensions = &policy
+	}
+	return cfg
+}
@@ -0,0 +1,82 @@
+package uploadserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+)
+
+// certRequest is a request from a client certificate of namespace, or
+// without one when namespace is empty
+func certRequest(namespace string) *http.Request {
+	req := httptest.NewRequest(http.MethodPut, "/files/a.txt", nil)
+	if namespace != "" {
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+			Subject: pkix.Name{CommonName: namespace + "-client", Organization: []string{namespace}},
+		}}}
+	}
+	return req
+}
+
+func TestWithRequestConfig(t *testing.T) {
+	policies, err := newExtensionPolicies(map[string]ExtensionPolicy{
+		"acme": {Allow: []string{".pdf"}},
+		"*":    {Deny: []string{".exe"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{extensions: policies}
+
+	var stored bool
+	var got requestConfig
+	var checkErr error
+	handler := withCaller(s.withRequestConfig(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, stored = r.Context().Value(requestConfigKey{}).(requestConfig)
+		got = s.requestConfig(r.Context())
+		checkErr = s.checkExtension(r.Context(), "a.txt")
+	})))
+
+	for _, tc := range []struct {
+		namespace string
+		allow     []string
+		deny      []string
+		refused   bool
+	}{
+		{"acme", []string{".pdf"}, nil, true},
+		{"globex", nil, []string{".exe"}, false},
+		{"", nil, []string{".exe"}, false},
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), certRequest(tc.namespace))
+		if !stored {
+			t.Fatalf("namespace %q: no configuration in the request context", tc.namespace)
+		}
+		if got.namespace != tc.namespace || got.extensions == nil ||
+			len(got.extensions.Allow) != len(tc.allow) || len(got.extensions.Deny) != len(tc.deny) {
+			t.Errorf("namespace %q: configuration %+v", tc.namespace, got)
+		}
+		if refused := connect.CodeOf(checkErr) == connect.CodeInvalidArgument; refused != tc.refused {
+			t.Errorf("namespace %q: a.txt checked with %v", tc.namespace, checkErr)
+		}
+	}
+
+	// the handlers use the configuration resolved with the request
+	ctx := t.Context()
+	handler = withCaller(s.withRequestConfig(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})))
+	handler.ServeHTTP(httptest.NewRecorder(), certRequest("acme"))
+	s.extensions = nil
+	if err := s.checkExtension(ctx, "a.txt"); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("a.txt checked with %v, want the policy resolved with the request", err)
+	}
+	// a context without one resolves it from the caller
+	if cfg := s.requestConfig(inNamespace(t.Context(), "acme")); cfg.namespace != "acme" || cfg.extensions != nil {
+		t.Fatalf("configuration resolved without policies %+v", cfg)
+	}
+}
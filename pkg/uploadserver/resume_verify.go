@@ -0,0 +1,59 @@
+package uploadserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// errPartialCorrupt is the cause of the DataLoss error refusing to resume an
+// upload whose partial file does not match the client's prefix_sha256
+var errPartialCorrupt = errors.New("partial upload does not match prefix_sha256, it was discarded: start over from offset 0")
+
+// verifyPrefix re-hashes the first offset bytes of the partial file the
+// caller of ctx keeps for filename before a resumed upload continues. On a
+// mismatch the partial upload is discarded, so a corrupt partial file never
+// ends up in the completed one.
+func (s *Server) verifyPrefix(ctx context.Context, filename string, offset int64, prefixHash string) error {
+	key := scopedName(ctx, filename)
+	state := s.ranged.status(key)
+	if received := state.contiguous(); received < offset {
+		return connect.NewError(connect.CodeFailedPrecondition,
+			fmt.Errorf("only %d leading bytes of %s were received, cannot verify %d", received, filename, offset))
+	}
+	partPath := filepath.Join(s.dir, partialDir, key)
+	f, err := os.Open(partPath)
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	hasher := sha256.New()
+	// a partial file truncated behind the server's back hashes short and fails
+	_, err = io.Copy(hasher, io.NewSectionReader(f, 0, offset))
+	f.Close()
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) == strings.ToLower(prefixHash) {
+		log.Printf("UploadFile: %s verified up to byte %d", s.redact.name(filename), offset)
+		return nil
+	}
+
+	log.Printf("UploadFile: partial %s does not match the client's first %d bytes, discarding it", s.redact.name(filename), offset)
+	s.ranged.mu.Lock()
+	s.ranged.forget(key)
+	err = os.Remove(partPath)
+	s.ranged.mu.Unlock()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove corrupt partial %s: %v", s.redact.name(filename), s.redact.err(err))
+	}
+	return connect.NewError(connect.CodeDataLoss, errPartialCorrupt)
+}
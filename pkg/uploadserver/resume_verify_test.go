@@ -0,0 +1,85 @@
+package uploadserver
+
+import (
+	"os"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// resumeChunk sends data at offset of name with the hash of the bytes before it
+func (ts *testServer) resumeChunk(t *testing.T, name string, offset int64, data, prefix string, last bool, hash string) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	return ts.srv.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename:     name,
+		Data:         []byte(data),
+		Sha256:       hash,
+		Offset:       &offset,
+		IsLast:       last,
+		PrefixSha256: sha256Hex(prefix),
+	})
+}
+
+func TestResumeVerifiesPrefix(t *testing.T) {
+	const content = "0123456789abcdef"
+	ts := newTestServer(t, &Server{})
+	if _, err := ts.uploadChunk(t.Context(), "good.txt", 0, content[:8], false, ""); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ts.resumeChunk(t, "good.txt", 8, content[8:], content[:8], true, sha256Hex(content))
+	if err != nil {
+		t.Fatalf("resume after a good partial: %v", err)
+	}
+	if !resp.HashOk || ts.stored(t, "good.txt") != content {
+		t.Fatalf("stored %q, %v", ts.stored(t, "good.txt"), resp)
+	}
+}
+
+func TestResumeDiscardsTamperedPartial(t *testing.T) {
+	const content = "0123456789abcdef"
+	ts := newTestServer(t, &Server{})
+	if _, err := ts.uploadChunk(t.Context(), "bad.txt", 0, content[:8], false, ""); err != nil {
+		t.Fatal(err)
+	}
+	// the partial file is damaged behind the server's back
+	if err := os.WriteFile(ts.partialPath("bad.txt"), []byte("0123X567"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ts.resumeChunk(t, "bad.txt", 8, content[8:], content[:8], true, sha256Hex(content))
+	if connect.CodeOf(err) != connect.CodeDataLoss {
+		t.Fatalf("resume after a tampered partial: %v, want data loss", err)
+	}
+	if _, err := os.Stat(ts.partialPath("bad.txt")); !os.IsNotExist(err) {
+		t.Fatalf("tampered partial kept: %v", err)
+	}
+	ts.assertNotStored(t, "bad.txt")
+	status, err := ts.client.GetUploadStatus(t.Context(), &fileuploadv1.GetUploadStatusRequest{Filename: "bad.txt"})
+	if err != nil || status.Offset != 0 {
+		t.Fatalf("status after the discard: %v, %v, want offset 0", status, err)
+	}
+
+	// starting over from the first byte stores the right content
+	if _, err := ts.uploadChunk(t.Context(), "bad.txt", 0, content[:8], false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.resumeChunk(t, "bad.txt", 8, content[8:], content[:8], true, sha256Hex(content)); err != nil {
+		t.Fatalf("resume after starting over: %v", err)
+	}
+	if got := ts.stored(t, "bad.txt"); got != content {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestResumeVerifyPastReceived(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if _, err := ts.uploadChunk(t.Context(), "a.txt", 0, "0123", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	// the hash covers bytes the server never received
+	_, err := ts.resumeChunk(t, "a.txt", 8, "89", "01234567", false, "")
+	if connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Fatalf("verify past the received bytes: %v, want failed precondition", err)
+	}
+}
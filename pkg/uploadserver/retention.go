@@ -0,0 +1,43 @@
+package uploadserver
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// retentionFile holds, in the upload directory, when the retention of each
+// file stored under Config.Retention ends
+const retentionFile = ".retention.json"
+
+// errRetained is the cause of the PermissionDenied error refusing to replace,
+// rename or delete a file under retention
+var errRetained = errors.New("file is under retention")
+
+// checkRetention refuses changes to filename until its retention ends. It is
+// enforced even once Config.Retention is turned off, for files stored before.
+func (s *Server) checkRetention(filename string) error {
+	until := s.retention.get(filename)
+	if until.After(time.Now()) {
+		return connect.NewError(connect.CodePermissionDenied,
+			fmt.Errorf("%w: %s cannot be changed until %s", errRetained, baseName(filename), until.UTC().Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// retain starts the retention of a file that was just stored
+func (s *Server) retain(filename string) {
+	if s.retentionPeriod > 0 {
+		s.retention.set(filename, time.Now().Add(s.retentionPeriod))
+	}
+}
+
+// sweepRetention forgets the retention of files for which it has ended, so
+// sweepExpired can delete them
+func (s *Server) sweepRetention() {
+	for _, name := range s.retention.due(time.Now()) {
+		s.retention.set(name, time.Time{})
+	}
+}
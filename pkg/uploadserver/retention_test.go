@@ -0,0 +1,100 @@
+package uploadserver
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// endRetention moves the end of the retention of name into the past
+func (ts *testServer) endRetention(name string) {
+	ts.srv.retention.set(name, time.Now().Add(-time.Second))
+}
+
+func TestRetentionBlocksChanges(t *testing.T) {
+	ts := newTestServer(t, &Server{retentionPeriod: time.Hour})
+	ts.uploadFile(t, "record.txt", "original")
+	ts.uploadFile(t, "other.txt", "other")
+
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "record.txt", Data: []byte("changed"), Sha256: sha256Hex("changed")})
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("UploadFile over a retained file: %v, want permission denied", err)
+	}
+	if _, err := ts.streamUpload(t.Context(), "record.txt", []byte("changed"), 2); connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("Upload over a retained file: %v, want permission denied", err)
+	}
+	if resp, body := ts.putRange(t, "record.txt", "bytes 0-6/7", "changed"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("ranged PUT over a retained file: status %d: %s", resp.StatusCode, body)
+	}
+	if _, err := ts.rename(t, "record.txt", "moved.txt", false); connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("rename of a retained file: %v, want permission denied", err)
+	}
+	if _, err := ts.rename(t, "other.txt", "record.txt", true); connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("rename onto a retained file: %v, want permission denied", err)
+	}
+	_, err = ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "other.txt", To: "record.txt", Overwrite: true})
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("copy onto a retained file: %v, want permission denied", err)
+	}
+	if got := ts.stored(t, "record.txt"); got != "original" {
+		t.Fatalf("retained file holds %q", got)
+	}
+
+	// once the retention ends, the file can change again
+	ts.endRetention("record.txt")
+	if resp := ts.uploadFile(t, "record.txt", "changed"); resp.StoredFilename != "record.txt" {
+		t.Fatalf("stored %q", resp.StoredFilename)
+	}
+	if got := ts.stored(t, "record.txt"); got != "changed" {
+		t.Fatalf("file holds %q after its retention", got)
+	}
+	// the new content starts a retention of its own
+	if until := ts.srv.retention.get("record.txt"); until.Before(time.Now().Add(50 * time.Minute)) {
+		t.Fatalf("retention of the new content ends %v", until)
+	}
+}
+
+func TestRetentionBlocksExpiry(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex(), retentionPeriod: time.Hour})
+	if _, err := ts.uploadExpiring(t, "temp.txt", "temporary", 60, 0); err != nil {
+		t.Fatal(err)
+	}
+	ts.expire("temp.txt")
+
+	// the janitor leaves the expired file alone within its retention
+	ts.srv.sweepExpired()
+	if _, err := os.Stat(filepath.Join(ts.dir, "temp.txt")); err != nil {
+		t.Fatalf("deleted within its retention: %v", err)
+	}
+
+	// and deletes it after
+	ts.endRetention("temp.txt")
+	ts.srv.sweepExpired()
+	if _, err := os.Stat(filepath.Join(ts.dir, "temp.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expired file kept after its retention: %v", err)
+	}
+}
+
+func TestRetentionSurvivesRestart(t *testing.T) {
+	ts := newTestServer(t, &Server{retentionPeriod: time.Hour})
+	ts.uploadFile(t, "record.txt", "original")
+	loaded, err := openExpiries(filepath.Join(ts.dir, retentionFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// enforced for files stored before, even with the mode turned off
+	ts.srv.retention, ts.srv.retentionPeriod = loaded, 0
+	_, err = ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "record.txt", Data: []byte("changed"), Sha256: sha256Hex("changed")})
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Fatalf("overwrite after a restart: %v, want permission denied", err)
+	}
+	// while new files are not retained
+	ts.uploadFile(t, "new.txt", "new")
+	ts.uploadFile(t, "new.txt", "replaced")
+}
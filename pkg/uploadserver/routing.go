@@ -0,0 +1,143 @@
+package uploadserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Route stores the files matching Match in a subdirectory of Config.Dir
+type Route struct {
+	// Match is an extension such as ".pdf", or a content type such as
+	// "image/png" or "image/*" looked up from the extension
+	Match string
+	// Dir is the subdirectory, relative to Config.Dir
+	Dir string
+}
+
+// router maps the name of a stored file to where it is stored: its storage
+// key, the first matching route's subdirectory, or the storage directory
+// itself. A keyed name, see keyedName, without a key yet is pending, and so
+// is a plain name routed to the key of a keyed file.
+type router struct {
+	dir    string
+	routes []Route
+	keys   *storageKeys
+}
+
+// newRouter validates routes and creates their subdirectories
+func newRouter(dir string, routes []Route) (*router, error) {
+	r := &router{dir: dir}
+	for _, route := range routes {
+		match := strings.ToLower(strings.TrimSpace(route.Match))
+		sub := filepath.Clean(route.Dir)
+		if match == "" {
+			return nil, fmt.Errorf("route to %q has no extension or content type", route.Dir)
+		}
+		// hidden directories hold probes and partial uploads
+		if route.Dir == "" || !filepath.IsLocal(sub) || strings.HasPrefix(sub, ".") {
+			return nil, fmt.Errorf("route %q: directory %q must be a relative path inside the storage directory", match, route.Dir)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("create route directory: %w", err)
+		}
+		r.routes = append(r.routes, Route{Match: match, Dir: sub})
+	}
+	return r, nil
+}
+
+// locate returns the subdirectory and file name name is stored under
+func (r *router) locate(name string) (sub, file string) {
+	if key := r.keys.get(name); key != "" {
+		sub, file = filepath.Split(filepath.FromSlash(key))
+		return filepath.Clean(sub), file
+	}
+	sub = r.route(name)
+	// the key of another file is out of reach by a plain name
+	if isKeyed(name) || r.keys.nameOf(filepath.ToSlash(filepath.Join(sub, name))) != "" {
+		// named after a digest, so any name fits
+		sum := sha256.Sum256([]byte(name))
+		return filepath.Join(partialDir, pendingDir), hex.EncodeToString(sum[:])
+	}
+	return sub, name
+}
+
+// subdir returns the subdirectory name is stored in, "" for the default
+func (r *router) subdir(name string) string {
+	if sub, _ := r.locate(name); sub != "." {
+		return sub
+	}
+	return ""
+}
+
+// route returns the subdirectory the routes send filename to, "" for the default
+func (r *router) route(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return ""
+	}
+	contentType, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+	for _, route := range r.routes {
+		switch {
+		case strings.HasPrefix(route.Match, "."):
+			if route.Match == ext {
+				return route.Dir
+			}
+		case strings.HasSuffix(route.Match, "/*"):
+			if contentType != "" && strings.HasPrefix(contentType, strings.TrimSuffix(route.Match, "*")) {
+				return route.Dir
+			}
+		case route.Match == contentType:
+			return route.Dir
+		}
+	}
+	return ""
+}
+
+// path returns where name is stored
+func (r *router) path(name string) string {
+	sub, file := r.locate(name)
+	return filepath.Join(r.dir, sub, file)
+}
+
+// relPath returns where name is stored relative to the storage directory,
+// with "/" separators
+func (r *router) relPath(name string) string {
+	sub, file := r.locate(name)
+	return filepath.ToSlash(filepath.Join(sub, file))
+}
+
+// nameAt returns the name of the file found as file in the subdirectory
+// sub: the keyed name of the file stored at that key, or file itself
+func (r *router) nameAt(sub, file string) string {
+	if name := r.keys.nameOf(filepath.ToSlash(filepath.Join(sub, file))); name != "" {
+		return name
+	}
+	return file
+}
+
+// dirs lists the directories holding stored files, the default one first
+func (r *router) dirs() []string {
+	dirs := []string{""}
+	seen := map[string]bool{"": true}
+	for _, route := range r.routes {
+		if !seen[route.Dir] {
+			seen[route.Dir] = true
+			dirs = append(dirs, route.Dir)
+		}
+	}
+	for _, dir := range r.keys.dirs() {
+		if dir == "." {
+			dir = ""
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
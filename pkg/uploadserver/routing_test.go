@@ -0,0 +1,222 @@
+package uploadserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestRouterSubdir(t *testing.T) {
+	r, err := newRouter(t.TempDir(), []Route{
+		{Match: ".PDF", Dir: "docs"},
+		{Match: "image/png", Dir: "png"},
+		{Match: "image/*", Dir: "images"},
+		{Match: ".txt", Dir: "notes/text"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"report.pdf":  "docs",
+		"REPORT.Pdf":  "docs",
+		"logo.png":    "png",
+		"photo.jpg":   "images",
+		"anim.gif":    "images",
+		"readme.txt":  filepath.Join("notes", "text"),
+		"archive.zip": "",
+		"Makefile":    "",
+	} {
+		if got := r.subdir(name); got != want {
+			t.Errorf("subdir(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got, want := r.dirs(), []string{"", "docs", "png", "images", filepath.Join("notes", "text")}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("dirs() = %q, want %q", got, want)
+	}
+	for _, sub := range r.dirs()[1:] {
+		if fi, err := os.Stat(filepath.Join(r.dir, sub)); err != nil || !fi.IsDir() {
+			t.Errorf("route directory %s was not created: %v", sub, err)
+		}
+	}
+}
+
+func TestNewRouterRejectsBadRoutes(t *testing.T) {
+	for _, route := range []Route{
+		{Match: "", Dir: "docs"},
+		{Match: ".pdf", Dir: ""},
+		{Match: ".pdf", Dir: "../outside"},
+		{Match: ".pdf", Dir: "/abs"},
+		{Match: ".pdf", Dir: ".hidden"},
+	} {
+		if _, err := newRouter(t.TempDir(), []Route{route}); err == nil {
+			t.Errorf("newRouter accepted %+v", route)
+		}
+	}
+}
+
+// newRoutedServer is a test server storing files according to routes
+func newRoutedServer(t *testing.T, routes ...Route) *testServer {
+	t.Helper()
+	ts := newTestServer(t, &Server{})
+	files, err := newRouter(ts.dir, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.srv.files = files
+	return ts
+}
+
+func TestRoutedUploads(t *testing.T) {
+	ts := newRoutedServer(t, Route{Match: ".pdf", Dir: "docs"}, Route{Match: "image/*", Dir: "images"})
+
+	ts.uploadFile(t, "unary.pdf", "unary")
+	if _, err := ts.streamUpload(t.Context(), "streamed.png", []byte("streamed"), 3); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := ts.do(t, ts.newRequest(t, http.MethodPut, "/files/put.jpg", strings.NewReader("put"))); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+	ts.uploadFile(t, "plain.txt", "plain")
+
+	for path, want := range map[string]string{
+		filepath.Join("docs", "unary.pdf"):      "unary",
+		filepath.Join("images", "streamed.png"): "streamed",
+		filepath.Join("images", "put.jpg"):      "put",
+		"plain.txt":                             "plain",
+	} {
+		if b, err := os.ReadFile(filepath.Join(ts.dir, path)); err != nil || string(b) != want {
+			t.Errorf("%s holds %q, %v; want %q", path, b, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "unary.pdf")); !os.IsNotExist(err) {
+		t.Errorf("routed file also stored in the default directory: %v", err)
+	}
+
+	// downloads and metadata find the routed files by name alone
+	resp, body := ts.do(t, ts.newRequest(t, http.MethodGet, "/files/streamed.png", nil))
+	if resp.StatusCode != http.StatusOK || string(body) != "streamed" {
+		t.Fatalf("GET routed file: status %d, %q", resp.StatusCode, body)
+	}
+	meta, err := ts.client.GetFileMetadata(t.Context(), &fileuploadv1.GetFileMetadataRequest{Filename: "unary.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Size != int64(len("unary")) || meta.Sha256 != sha256Hex("unary") {
+		t.Fatalf("metadata of a routed file: %+v", meta)
+	}
+}
+
+func TestStoragePath(t *testing.T) {
+	pub := &memoryPublisher{}
+	ts := newRoutedServer(t, Route{Match: ".pdf", Dir: "docs"}, Route{Match: "image/*", Dir: "images"})
+	ts.srv.publisher = pub
+
+	paths := map[string]string{}
+	paths["unary"] = ts.uploadFile(t, "unary.pdf", "unary").StoragePath
+	streamed, err := ts.streamUpload(t.Context(), "streamed.png", []byte("streamed"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths["streamed"] = streamed.StoragePath
+	if _, err := ts.uploadChunk(t.Context(), "chunked.pdf", 0, "chun", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	chunked, err := ts.uploadChunk(t.Context(), "chunked.pdf", 4, "ked", true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths["chunked"] = chunked.StoragePath
+	resp, body := ts.putRange(t, "put.jpg", "", "put")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+	var put struct {
+		StoragePath string `json:"storagePath"`
+	}
+	if err := json.Unmarshal(body, &put); err != nil {
+		t.Fatal(err)
+	}
+	paths["put"] = put.StoragePath
+	copied, err := ts.client.CopyFile(t.Context(), &fileuploadv1.CopyFileRequest{From: "unary.pdf", To: "copy.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths["copy"] = copied.StoragePath
+	archive, err := ts.buildArchive(t, "bundle.tar", "a.txt", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths["archive"] = archive.StoragePath
+
+	want := map[string]string{
+		"unary":    "docs/unary.pdf",
+		"streamed": "images/streamed.png",
+		"chunked":  "docs/chunked.pdf",
+		"put":      "images/put.jpg",
+		"copy":     "docs/copy.pdf",
+		"archive":  "bundle.tar",
+	}
+	for how, path := range want {
+		if paths[how] != path {
+			t.Errorf("%s: storage path %q, want %q", how, paths[how], path)
+			continue
+		}
+		// the path leads to the file
+		if _, err := os.Stat(filepath.Join(ts.dir, filepath.FromSlash(path))); err != nil {
+			t.Errorf("%s: nothing at the storage path: %v", how, err)
+		}
+	}
+	for _, e := range pub.published() {
+		if e.StoragePath != ts.srv.files.relPath(e.StoredFilename) || e.StoragePath == "" {
+			t.Errorf("event of %s with storage path %q", e.StoredFilename, e.StoragePath)
+		}
+	}
+}
+
+func TestStoragePathOfRandomNames(t *testing.T) {
+	ts := newRoutedServer(t, Route{Match: ".pdf", Dir: "docs"})
+	ts.srv.randomNames = true
+	resp := ts.uploadFile(t, "report.pdf", "report")
+	if resp.StoragePath != "docs/"+resp.StoredFilename {
+		t.Fatalf("storage path %q of %q, want it in docs/", resp.StoragePath, resp.StoredFilename)
+	}
+	if b, err := os.ReadFile(filepath.Join(ts.dir, filepath.FromSlash(resp.StoragePath))); err != nil || string(b) != "report" {
+		t.Fatalf("storage path holds %q, %v", b, err)
+	}
+	// nothing stored, no path
+	dry, err := ts.dryRunUpload(t, "dry.pdf", "dry", sha256Hex("dry"))
+	if err != nil || dry.StoragePath != "" {
+		t.Fatalf("dry run reported storage path %q, %v", dry.GetStoragePath(), err)
+	}
+}
+
+func TestHashIndexScanRoutes(t *testing.T) {
+	dir := t.TempDir()
+	files, err := newRouter(dir, []Route{{Match: ".pdf", Dir: "docs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "docs", "a.pdf"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+	// stored before the route existed, unreachable by its name
+	os.WriteFile(filepath.Join(dir, "old.pdf"), []byte("old"), 0644)
+
+	x := newHashIndex()
+	if err := x.scan(files, nil, redactor{}); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := x.lookup(sha256Hex("a"), ""); !ok || name != "a.pdf" {
+		t.Fatalf("routed file not indexed: %q, %v", name, ok)
+	}
+	if name, ok := x.lookup(sha256Hex("b"), ""); !ok || name != "b.txt" {
+		t.Fatalf("default file not indexed: %q, %v", name, ok)
+	}
+	if _, ok := x.lookup(sha256Hex("old"), ""); ok {
+		t.Fatal("indexed a file outside the directory its name routes to")
+	}
+}
@@ -0,0 +1,152 @@
+package uploadserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+var errScrubRunning = errors.New("a scrub is already running")
+
+// snapshot returns a copy of the indexed hashes by filename
+func (x *hashIndex) snapshot() map[string]string {
+	if x == nil {
+		return nil
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	files := make(map[string]string, len(x.byName))
+	for name, hash := range x.byName {
+		files[name] = hash
+	}
+	return files
+}
+
+// Scrub re-reads every file in the hash index and compares its SHA-256 with
+// the indexed one, so bit rot or changes made behind the server's back are
+// found before the file is needed. Files replaced or removed through the
+// server while the scrub runs are skipped. The index is left unchanged, and
+// only one scrub runs at a time since it reads the whole store.
+func (s *Server) Scrub(
+	ctx context.Context, req *fileuploadv1.ScrubRequest, stream *connect.ServerStream[fileuploadv1.ScrubResponse]) error {
+
+	if !s.scrubbing.CompareAndSwap(false, true) {
+		return connect.NewError(connect.CodeUnavailable, errScrubRunning)
+	}
+	defer s.scrubbing.Store(false)
+
+	files := s.index.snapshot()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	log.Printf("Scrub started: %d files", len(names))
+
+	summary := &fileuploadv1.ScrubSummary{}
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return contextError(ctx)
+		}
+		file, size, err := s.scrubFile(ctx, name, files[name])
+		if err != nil {
+			return err
+		}
+		if file == nil {
+			summary.FilesSkipped++
+			continue
+		}
+		summary.FilesChecked++
+		summary.BytesChecked += size
+		if file.Status != fileuploadv1.ScrubStatus_SCRUB_STATUS_OK {
+			summary.Damaged = append(summary.Damaged, file)
+			found := s.redact.hash(file.ActualSha256)
+			switch {
+			case file.Error != "":
+				found = file.Error
+			case found == "":
+				found = "no file"
+			}
+			log.Printf("Scrub: %s is %s (indexed hash %s, found %s)", s.redact.name(name), file.Status, s.redact.hash(file.ExpectedSha256), found)
+		}
+		if err := stream.Send(&fileuploadv1.ScrubResponse{
+			Payload: &fileuploadv1.ScrubResponse_Progress{Progress: &fileuploadv1.ScrubProgress{
+				File:         file,
+				FilesChecked: summary.FilesChecked,
+				FilesTotal:   int64(len(names)),
+				BytesChecked: summary.BytesChecked,
+			}},
+		}); err != nil {
+			return err
+		}
+	}
+	log.Printf("Scrub complete: %d files (%d bytes) checked, %d damaged, %d skipped",
+		summary.FilesChecked, summary.BytesChecked, len(summary.Damaged), summary.FilesSkipped)
+	return stream.Send(&fileuploadv1.ScrubResponse{
+		Payload: &fileuploadv1.ScrubResponse_Summary{Summary: summary},
+	})
+}
+
+// scrubFile checks the stored file name against its indexed hash and returns
+// the result with the bytes read, or nil when the file changed through the
+// server in the meantime
+func (s *Server) scrubFile(ctx context.Context, name, want string) (*fileuploadv1.ScrubFile, int64, error) {
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	file := &fileuploadv1.ScrubFile{Filename: baseName(name), ExpectedSha256: want}
+	size, got, err := hashSized(s.files.path(name))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		file.Status = fileuploadv1.ScrubStatus_SCRUB_STATUS_MISSING
+	case err != nil:
+		file.Status = fileuploadv1.ScrubStatus_SCRUB_STATUS_UNREADABLE
+		// the path of the file on the server is not part of the answer
+		file.Error = err.Error()
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			file.Error = pathErr.Err.Error()
+		}
+	case got != want:
+		file.Status = fileuploadv1.ScrubStatus_SCRUB_STATUS_CORRUPT
+		file.ActualSha256 = got
+	default:
+		file.Status = fileuploadv1.ScrubStatus_SCRUB_STATUS_OK
+		file.ActualSha256 = got
+	}
+	if file.Status != fileuploadv1.ScrubStatus_SCRUB_STATUS_OK {
+		// an upload, rename or expiry removes or replaces the index entry
+		if current, ok := s.index.hashOf(name); !ok || current != want {
+			return nil, 0, nil
+		}
+	}
+	return file, size, nil
+}
+
+// hashSized returns the size and hex-encoded SHA-256 of the file at path
+func hashSized(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, f)
+	if err != nil {
+		return n, "", err
+	}
+	return n, hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,103 @@
+package uploadserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// scrub runs Scrub and returns the progress messages with the summary
+func (ts *testServer) scrub(t *testing.T) ([]*fileuploadv1.ScrubProgress, *fileuploadv1.ScrubSummary, error) {
+	t.Helper()
+	stream, err := ts.client.Scrub(t.Context(), &fileuploadv1.ScrubRequest{})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stream.Close()
+	var progress []*fileuploadv1.ScrubProgress
+	var summary *fileuploadv1.ScrubSummary
+	for stream.Receive() {
+		if p := stream.Msg().GetProgress(); p != nil {
+			progress = append(progress, p)
+		}
+		if s := stream.Msg().GetSummary(); s != nil {
+			summary = s
+		}
+	}
+	return progress, summary, stream.Err()
+}
+
+func TestScrub(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.uploadFile(t, "a.txt", "intact")
+	ts.uploadFile(t, "b.txt", "will rot")
+	ts.uploadFile(t, "c.txt", "will vanish")
+	// bit rot and a file removed behind the server's back
+	if err := os.WriteFile(filepath.Join(ts.dir, "b.txt"), []byte("will rut"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(ts.dir, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	progress, summary, err := ts.scrub(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]fileuploadv1.ScrubStatus{
+		"a.txt": fileuploadv1.ScrubStatus_SCRUB_STATUS_OK,
+		"b.txt": fileuploadv1.ScrubStatus_SCRUB_STATUS_CORRUPT,
+		"c.txt": fileuploadv1.ScrubStatus_SCRUB_STATUS_MISSING,
+	}
+	if len(progress) != len(want) {
+		t.Fatalf("%d progress messages, want one per file", len(progress))
+	}
+	for i, p := range progress {
+		if p.File.Status != want[p.File.Filename] {
+			t.Errorf("%s: status %v, want %v", p.File.Filename, p.File.Status, want[p.File.Filename])
+		}
+		if p.FilesChecked != int64(i+1) || p.FilesTotal != 3 {
+			t.Errorf("progress %d of %d after %d files", p.FilesChecked, p.FilesTotal, i+1)
+		}
+	}
+	if summary == nil || summary.FilesChecked != 3 || summary.BytesChecked != int64(len("intact")+len("will rut")) {
+		t.Fatalf("summary %v", summary)
+	}
+	if len(summary.Damaged) != 2 {
+		t.Fatalf("damaged %v, want b.txt and c.txt", summary.Damaged)
+	}
+	corrupt := summary.Damaged[0]
+	if corrupt.Filename != "b.txt" || corrupt.ExpectedSha256 != sha256Hex("will rot") || corrupt.ActualSha256 != sha256Hex("will rut") {
+		t.Errorf("corrupt file %v", corrupt)
+	}
+	// the scrub reports, it does not repair the index
+	if hash, _ := ts.srv.index.hashOf("b.txt"); hash != sha256Hex("will rot") {
+		t.Errorf("index of b.txt changed to %s", hash)
+	}
+}
+
+func TestScrubSkipsFilesChangedMeanwhile(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.uploadFile(t, "a.txt", "old")
+	if err := os.WriteFile(filepath.Join(ts.dir, "a.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// replaced through the server after the scrub listed it
+	ts.srv.index.set("a.txt", sha256Hex("new"))
+	file, _, err := ts.srv.scrubFile(t.Context(), "a.txt", sha256Hex("old"))
+	if err != nil || file != nil {
+		t.Fatalf("scrub of a replaced file %v, %v, want it skipped", file, err)
+	}
+}
+
+func TestScrubOneAtATime(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex()})
+	ts.srv.scrubbing.Store(true)
+	if _, _, err := ts.scrub(t); connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Fatalf("second scrub: %v, want unavailable", err)
+	}
+}
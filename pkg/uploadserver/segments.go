@@ -0,0 +1,137 @@
+package uploadserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// segmentHasher hashes a stream in fixed-size segments for a segmented commit.
+// Each segment gets its own hash from newHash, so with hash workers several
+// segments are hashed at once; the sums are taken in segment order. A nil
+// segmentHasher ignores writes.
+type segmentHasher struct {
+	size    int64
+	newHash func() chunkHash
+	cur     chunkHash   // nil until the segment's first byte
+	n       int64       // bytes hashed into cur
+	done    []chunkHash // the complete segments
+}
+
+func newSegmentHasher(size int64, newHash func() chunkHash) *segmentHasher {
+	if size <= 0 {
+		return nil
+	}
+	return &segmentHasher{size: size, newHash: newHash}
+}
+
+func (h *segmentHasher) Write(p []byte) {
+	if h == nil {
+		return
+	}
+	for len(p) > 0 {
+		n := min(int64(len(p)), h.size-h.n)
+		if h.cur == nil {
+			h.cur = h.newHash()
+		}
+		h.cur.Write(p[:n])
+		h.n += n
+		p = p[n:]
+		if h.n == h.size {
+			h.next()
+		}
+	}
+}
+
+func (h *segmentHasher) next() {
+	h.cur.end()
+	h.done = append(h.done, h.cur)
+	h.cur = nil
+	h.n = 0
+}
+
+// end stops the hashing of an abandoned upload
+func (h *segmentHasher) end() {
+	if h == nil {
+		return
+	}
+	if h.cur != nil {
+		h.cur.end()
+	}
+	for _, d := range h.done {
+		d.end()
+	}
+}
+
+// corrupt compares the segment hashes sent by the client with the received
+// content and returns the indexes that differ
+func (h *segmentHasher) corrupt(client []string) ([]int64, error) {
+	if h.n > 0 {
+		h.next()
+	}
+	if len(client) != len(h.done) {
+		return nil, fmt.Errorf("commit has %d segment hashes, content has %d segments", len(client), len(h.done))
+	}
+	var bad []int64
+	for i, d := range h.done {
+		if client[i] != d.sum() {
+			bad = append(bad, int64(i))
+		}
+	}
+	return bad, nil
+}
+
+// keepForRepair turns a staged upload with corrupt segments into a partial
+// ranged upload missing exactly those segments, so the client can PUT them
+// again with Content-Range instead of re-sending everything. It returns the
+// data_loss error describing them.
+func (s *Server) keepForRepair(ctx context.Context, file *os.File, staged, filename string, total, segmentSize int64, bad []int64) error {
+	mismatch := connect.NewError(connect.CodeDataLoss, fmt.Errorf("checksum mismatch in %d of %d segments", len(bad), (total+segmentSize-1)/segmentSize))
+	if err := file.Close(); err != nil {
+		return mismatch
+	}
+	key := scopedName(ctx, filename)
+	partPath := filepath.Join(s.dir, partialDir, key)
+	if err := os.MkdirAll(filepath.Dir(partPath), 0755); err != nil {
+		log.Printf("Upload: cannot keep %s for repair: %v", s.redact.name(filename), s.redact.err(err))
+		return mismatch
+	}
+	if err := os.Rename(staged, partPath); err != nil {
+		log.Printf("Upload: cannot keep %s for repair: %v", s.redact.name(filename), s.redact.err(err))
+		return mismatch
+	}
+
+	state := rangedUpload{total: total}
+	var next int64
+	for _, i := range bad {
+		if start := i * segmentSize; start > next {
+			state.ranges = append(state.ranges, byteRange{next, start - 1})
+		}
+		next = min((i+1)*segmentSize, total)
+	}
+	if next < total {
+		state.ranges = append(state.ranges, byteRange{next, total - 1})
+	}
+	if err := s.ranged.resume(key, state); err != nil {
+		// the repair still works until the next restart
+		log.Printf("Upload: cannot save the state of %s: %v", s.redact.name(filename), s.redact.err(err))
+	}
+	log.Printf("Upload: kept %s for repair, corrupt segments %v", s.redact.name(filename), bad)
+
+	detail, err := connect.NewErrorDetail(&fileuploadv1.CorruptSegments{
+		Filename:    filename,
+		SegmentSize: segmentSize,
+		TotalSize:   total,
+		Indexes:     bad,
+	})
+	if err == nil {
+		mismatch.AddDetail(detail)
+	}
+	return mismatch
+}
@@ -0,0 +1,117 @@
+package uploadserver
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// segmentSums hashes data in segments of size bytes, as a client would
+func segmentSums(data string, size int) []string {
+	var sums []string
+	for off := 0; off < len(data); off += size {
+		sums = append(sums, sha256Hex(data[off:min(off+size, len(data))]))
+	}
+	return sums
+}
+
+func TestSegmentHasher(t *testing.T) {
+	var none *segmentHasher
+	none.Write([]byte("ignored"))
+	if newSegmentHasher(0, nil) != nil {
+		t.Fatal("a segment size of 0 hashes segments")
+	}
+
+	h := newSegmentHasher(4, (&Server{}).newChunkHash)
+	h.Write([]byte("012"))
+	h.Write([]byte("3XXXX8"))
+	h.Write([]byte("9"))
+	bad, err := h.corrupt(segmentSums("0123456789", 4))
+	if err != nil || !slices.Equal(bad, []int64{1}) {
+		t.Fatalf("corrupt = %v, %v; want segment 1", bad, err)
+	}
+
+	h = newSegmentHasher(4, (&Server{}).newChunkHash)
+	h.Write([]byte("0123456789"))
+	if _, err := h.corrupt(segmentSums("01234567", 4)); err == nil {
+		t.Fatal("corrupt accepted a commit with too few segment hashes")
+	}
+}
+
+// segmentedUpload streams data with segment_size set to size, then commits the
+// hashes of want, the content the client meant to send, in 4-byte segments
+func (ts *testServer) segmentedUpload(t *testing.T, name, data, want string, size int) error {
+	t.Helper()
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		return err
+	}
+	reqs := []*fileuploadv1.UploadRequest{
+		{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: name, SegmentSize: int64(size)}}},
+		{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte(data)}},
+		{Payload: &fileuploadv1.UploadRequest_SegmentedCommit{SegmentedCommit: &fileuploadv1.SegmentedCommit{
+			Sha256:        sha256Hex(want),
+			SegmentSha256: segmentSums(want, 4),
+		}}},
+	}
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			break
+		}
+	}
+	_, err = stream.CloseAndReceive()
+	return err
+}
+
+func TestSegmentedUpload(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	if err := ts.segmentedUpload(t, "intact.bin", "0123456789", "0123456789", 4); err != nil {
+		t.Fatalf("segmented upload of intact content: %v", err)
+	}
+	if got := ts.stored(t, "intact.bin"); got != "0123456789" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestSegmentedUploadRepair(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	err := ts.segmentedUpload(t, "huge.bin", "0123XXXX89", "0123456789", 4)
+	if connect.CodeOf(err) != connect.CodeDataLoss {
+		t.Fatalf("upload with a corrupt segment: %v, want data loss", err)
+	}
+	var corrupt *fileuploadv1.CorruptSegments
+	for _, detail := range err.(*connect.Error).Details() {
+		if msg, err := detail.Value(); err == nil {
+			corrupt, _ = msg.(*fileuploadv1.CorruptSegments)
+		}
+	}
+	if corrupt == nil || corrupt.Filename != "huge.bin" || corrupt.SegmentSize != 4 || corrupt.TotalSize != 10 || !slices.Equal(corrupt.Indexes, []int64{1}) {
+		t.Fatalf("CorruptSegments detail = %+v", corrupt)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "huge.bin")); !os.IsNotExist(err) {
+		t.Fatalf("content with a corrupt segment was stored: %v", err)
+	}
+
+	// re-sending the corrupt segment completes the kept upload
+	resp, body := ts.putRange(t, "huge.bin", "bytes 4-7/10", "4567")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("re-sending the segment: status %d: %s", resp.StatusCode, body)
+	}
+	if got := ts.stored(t, "huge.bin"); got != "0123456789" {
+		t.Fatalf("repaired file holds %q", got)
+	}
+}
+
+func TestSegmentedCommitRequiresSegmentSize(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	err := ts.segmentedUpload(t, "a.bin", "0123", "0123", 0)
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("segmented commit without segment_size: %v, want invalid argument", err)
+	}
+}
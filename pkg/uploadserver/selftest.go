@@ -0,0 +1,63 @@
+package uploadserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// selfTestSize is the size of the file written by the startup self-test
+const selfTestSize = 4096
+
+// selfTest stores a random file in every storage directory with the same code
+// path as uploads, reads it back, checks its hash and deletes it again
+func (s *Server) selfTest(ctx context.Context) error {
+	size := int64(selfTestSize)
+	if s.maxFileSize > 0 {
+		size = min(size, s.maxFileSize)
+	}
+	data := make([]byte, size)
+	rand.Read(data)
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+
+	for _, sub := range s.files.dirs() {
+		// hidden, so the hash index never picks it up
+		path := filepath.Join(s.dir, sub, ".self-test-"+want[:16])
+		if err := s.selfTestFile(ctx, path, data, want); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Dir(path), err)
+		}
+	}
+	log.Printf("Storage self-test passed: %d directories", len(s.files.dirs()))
+	return nil
+}
+
+func (s *Server) selfTestFile(ctx context.Context, path string, data []byte, want string) (err error) {
+	defer func() {
+		if removeErr := os.Remove(path); err == nil && removeErr != nil {
+			err = fmt.Errorf("delete: %w", removeErr)
+		}
+	}()
+	n, written, err := s.writeFile(ctx, path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if n != int64(len(data)) || written != want {
+		return fmt.Errorf("write: stored %d bytes with hash %s, want %d bytes with hash %s", n, written, len(data), want)
+	}
+	read, err := hashFile(path)
+	if err != nil {
+		return fmt.Errorf("read back: %w", err)
+	}
+	if read != want {
+		return errors.New("read back: content differs from what was written")
+	}
+	return nil
+}
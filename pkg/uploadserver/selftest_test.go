@@ -0,0 +1,41 @@
+package uploadserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewSelfTest(t *testing.T) {
+	dir := t.TempDir()
+	serveConfig(t, Config{Dir: dir, SelfTest: true, MaxFileSize: 100, Routes: []Route{{Match: ".pdf", Dir: "docs"}}})
+
+	// the test files are gone from every directory
+	for _, sub := range []string{"", "docs"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".self-test-") {
+				t.Errorf("self-test left %s behind", filepath.Join(sub, e.Name()))
+			}
+		}
+	}
+}
+
+func TestSelfTestFails(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "gone")
+	s := &Server{dir: missing, files: &router{dir: missing}}
+	if err := s.selfTest(t.Context()); err == nil || !strings.Contains(err.Error(), "write") {
+		t.Fatalf("self-test of a missing directory: %v, want a write error", err)
+	}
+
+	dir := t.TempDir()
+	s = &Server{dir: dir, files: &router{dir: dir}}
+	fillDisk(t, filepath.Join(dir, ".full"))
+	if err := s.selfTestFile(t.Context(), filepath.Join(dir, ".full"), []byte("data"), sha256Hex("data")); err == nil {
+		t.Fatal("self-test passed on a full disk")
+	}
+}
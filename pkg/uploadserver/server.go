@@ -0,0 +1,1223 @@
+package uploadserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+const (
+	version           = "0.1.0"
+	downloadChunkSize = 32 * 1024 // 32KB chunks
+
+	// max-age advertised on cacheable responses of side-effect-free RPCs
+	serverInfoMaxAge   = 5 * time.Minute
+	fileMetadataMaxAge = 10 * time.Second
+)
+
+// maxFilenameBytes is the longest name most filesystems accept
+const maxFilenameBytes = 255
+
+// sanitizeFilename prevents path traversal attacks. Whatever the client sends,
+// the result is a single non-empty path element that:
+//   - contains no '/' or '\' (only the last element of a Unix or Windows path,
+//     drive letters included, is kept)
+//   - does not start with '.', so it can neither be "." or ".." nor hide as a
+//     dotfile next to the server's own (.partial, write probes)
+//   - is valid UTF-8 without control characters or ':' (NTFS streams)
+//   - has no leading or trailing spaces and no trailing dots
+//   - is at most maxFilenameBytes long, keeping the extension when truncated
+func sanitizeFilename(filename string) string {
+	name := strings.ToValidUTF8(filename, "_")
+	name = strings.ReplaceAll(name, "\\", "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	// a bare drive-relative name such as C:x.txt
+	if len(name) >= 2 && name[1] == ':' && isASCIILetter(name[0]) {
+		name = name[2:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == ':' {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(name, ". ")
+	name = strings.TrimRight(truncateFilename(name, maxFilenameBytes), ". ")
+	if name == "" {
+		name = "unnamed_file"
+	}
+	return name
+}
+
+func isASCIILetter(b byte) bool {
+	return ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// truncateFilename shortens name to at most max bytes on a rune boundary,
+// preserving a short extension
+func truncateFilename(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > max/4 {
+		ext = ""
+	}
+	stem := name[:len(name)-len(ext)]
+	limit := max - len(ext)
+	for limit > 0 && !utf8.RuneStart(stem[limit]) {
+		limit--
+	}
+	return stem[:limit] + ext
+}
+
+// storedName returns the name a completed upload of filename is stored under:
+// filename itself or as rewritten by Config.NameTransformer, or with
+// Config.RandomNames a random UUID plus its extension. Under
+// Config.StorageKey that is the keyedName in the namespace of the caller of ctx.
+func (s *Server) storedName(ctx context.Context, filename string) string {
+	name := filename
+	switch {
+	case s.randomNames:
+		ext := filepath.Ext(filename)
+		if len(ext) > maxFilenameBytes/4 {
+			ext = ""
+		}
+		name = newUUID() + ext
+		log.Printf("Storing %s as %s", s.redact.name(filename), name)
+	case s.nameTransformer != nil:
+		name = sanitizeFilename(s.nameTransformer(filename))
+		if name != filename {
+			log.Printf("Storing %s as %s", s.redact.name(filename), s.redact.name(name))
+		}
+	}
+	if s.storageKey != nil {
+		return keyedName(callerFrom(ctx).namespace, name)
+	}
+	return name
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// isSHA256 reports whether s is a hex-encoded SHA-256 as uploads carry it
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// writeFull writes all of p, turning a short write without an error into
+// io.ErrShortWrite so data is never silently dropped by a custom writer
+func writeFull(w io.Writer, p []byte) error {
+	n, err := w.Write(p)
+	if err == nil && n != len(p) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// errDiskFull is the cause of the ResourceExhausted error returned by writeError
+var errDiskFull = errors.New("disk full")
+
+// writeError maps a failed write of stored data to a connect error. A full
+// disk is ResourceExhausted, which clients may retry once space is freed, and
+// a corrupt gzip request body InvalidArgument.
+func writeError(err error) error {
+	if errors.Is(err, syscall.ENOSPC) {
+		return connect.NewError(connect.CodeResourceExhausted, errDiskFull)
+	}
+	// the failing side of the copy was a corrupt gzip request body
+	var decodeErr bodyDecodeError
+	if errors.As(err, &decodeErr) {
+		return connect.NewError(connect.CodeInvalidArgument, decodeErr)
+	}
+	return connect.NewError(connect.CodeInternal, err)
+}
+
+// uploadState is the position of an Upload stream in its message sequence
+type uploadState int
+
+const (
+	expectingMetadata uploadState = iota
+	receivingChunks
+	// the commit was received, only the end of the stream may follow
+	committed
+)
+
+// Server implements the upload RPCs and HTTP endpoints over one storage directory
+type Server struct {
+	fileuploadv1connect.UnimplementedFileUploadServiceHandler
+
+	// dir is the storage directory
+	dir string
+	// files resolves where a sanitized filename is stored inside dir
+	files *router
+	// writes coalesces concurrent uploads of identical content
+	writes inflight
+	// events records the outcome of every upload, nil when disabled
+	events *eventLog
+	// watchers are the WatchEvents subscribers
+	watchers eventBus
+	// stopping is closed once Config.Context is done
+	stopping <-chan struct{}
+	// storage reports whether the upload directory currently accepts writes
+	storage *storageHealth
+	// disk refuses uploads while the disk is nearly full, nil when disabled
+	disk *diskWatermark
+	// ranged tracks the byte ranges received by in-progress PUT /files uploads
+	ranged rangedUploads
+	// keys maps idempotency keys to their uploads
+	keys idempotencyKeys
+	// tus serializes writes to each tus upload session
+	tus tusStore
+	// archives serializes changes to each BeginArchive session
+	archives tusStore
+	// index maps content hashes to stored files
+	index *hashIndex
+	// expiries holds when files uploaded with a TTL or expiry time are deleted
+	expiries *expiries
+	// retention holds until when files stored in immutable mode cannot be
+	// changed, written for retentionPeriod after they are stored
+	retention       *expiries
+	retentionPeriod time.Duration
+	// rejectDuplicates refuses content already stored under another name
+	rejectDuplicates bool
+	// dedupCopies makes CopyFile link copies instead of copying their bytes
+	dedupCopies bool
+	// slowChunkThreshold is the gap between stream messages that logs a warning
+	slowChunkThreshold time.Duration
+	// maxFileSize bounds the size of one stored file, 0 means unlimited
+	maxFileSize int64
+	// maxUnaryUploadBytes bounds the data of one UploadFile call, 0 means unlimited
+	maxUnaryUploadBytes int64
+	// extensions are the extension policies by namespace, nil accepts every file
+	extensions map[string]ExtensionPolicy
+	// maxMessageBytes bounds one RPC message, 0 means unlimited
+	maxMessageBytes int
+	// rules check upload metadata, including Config.MaxTitleLength
+	rules metadataRules
+	// partialMaxAge is how long an idle partial upload is kept, 0 forever
+	partialMaxAge time.Duration
+	// maxIncompleteSessions caps the resumable uploads in progress, 0 is unlimited
+	maxIncompleteSessions int
+	// externalURL locates stored files on external storage, nil serves them directly
+	externalURL func(name string) (string, error)
+	// buffers bounds the memory of chunks being written across uploads, nil when unlimited
+	buffers *byteBudget
+	// extract unpacks uploaded archives on request, nil when disabled
+	extract *extractor
+	// readOnly reports whether writes are refused, nil when never
+	readOnly func() bool
+	// openFiles bounds the storage files held open by uploads and downloads, nil when unlimited
+	openFiles *fileBudget
+	// sync is when written data is flushed to stable storage
+	sync SyncPolicy
+	// hashWorkers holds a token per running hash worker, up to Config.HashWorkers
+	hashWorkers chan struct{}
+	// skipHash is Config.SkipHashVerification
+	skipHash bool
+	// redact hides filenames and hashes in log lines
+	redact redactor
+	// randomNames stores uploads under random names instead of the client's
+	randomNames bool
+	// nameTransformer rewrites stored names unless randomNames is set, nil keeps them
+	nameTransformer func(string) string
+	// storageKey places stored files at a key instead of their route, nil when disabled
+	storageKey *keyTemplate
+	// publisher receives an event for every stored upload, nil when disabled
+	publisher Publisher
+	// publishRequired fails uploads whose event could not be published
+	publishRequired bool
+	// thumbs makes thumbnails of stored images, nil when disabled
+	thumbs *thumbnailer
+	// compressDownloads gzips compressible GET /files responses of at least
+	// compressMinBytes
+	compressDownloads bool
+	compressMinBytes  int64
+	// scrubbing is set while a Scrub runs
+	scrubbing atomic.Bool
+	// stats holds the latest GetStats answers
+	stats statsCache
+}
+
+// uploadWriteBuffer is the size of the buffer gathering the small chunks of
+// an Upload stream into fewer writes; larger chunks go straight to the file
+const uploadWriteBuffer = 64 << 10
+
+// writeBuffers recycles the write buffers of finished uploads
+var writeBuffers = sync.Pool{New: func() any { return bufio.NewWriterSize(nil, uploadWriteBuffer) }}
+
+// uploadStream is the request side of Upload and UploadBidi
+type uploadStream interface {
+	Receive() bool
+	Msg() *fileuploadv1.UploadRequest
+	Err() error
+	Peer() connect.Peer
+}
+
+// Upload handles streaming uploads with the Commit message pattern:
+// 1. metadata -> 2. chunks... -> 3. finish_commit (hash verification).
+// The file is only kept once the stream ends right after the commit, any
+// message out of this order fails the upload with CodeInvalidArgument.
+func (s *Server) Upload(
+	ctx context.Context, stream *connect.ClientStream[fileuploadv1.UploadRequest]) (*fileuploadv1.UploadResponse, error) {
+	return s.receiveUpload(ctx, "Upload", stream, nil)
+}
+
+// maxChunkNacks is how many times in a row a chunk may fail its CRC before
+// the upload fails, so a link corrupting everything does not loop forever
+const maxChunkNacks = 3
+
+// crc32c is the table of the chunk_crc32c checksums
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// receiveUpload runs the Upload protocol over stream for rpc. ack, when not
+// nil, is called with the bytes written so far after every
+// UploadMetadata.ack_every chunks, once the write buffer holds none of them,
+// and after the commit if indexed chunks were left unacknowledged. It is also called
+// to NACK a chunk failing its CRC: chunks and commits are then dropped until
+// that chunk is sent again. Without ack such a chunk fails the upload.
+func (s *Server) receiveUpload(ctx context.Context, rpc string, stream uploadStream, ack func(*fileuploadv1.UploadAck) error) (resp *fileuploadv1.UploadResponse, err error) {
+	var (
+		file      *os.File
+		out       io.Writer          // where chunks go once the metadata is received
+		buffered  *bufio.Writer      // out when it buffers file, nil under SyncPerChunk
+		dryRun    bool               // check and hash the content, storing nothing
+		hashOnly  bool               // only hash the content, skipping the checks
+		extract   bool               // unpack the stored archive into the extraction directory
+		ttl       int64              // ttl_seconds of the metadata
+		expiresAt int64              // expires_unix of the metadata
+		declared  int64         = -1 // declared total size, -1 when unknown
+		requested string             // sanitized filename sent by the client
+		filename  string             // name the file is stored under
+		staged    string             // where the content is written until it is placed
+		totalSize int64
+		hasher    = s.newChunkHash()
+		sha       string // hash declared by the metadata, empty when none
+		shared    bool   // filename links to the file of a concurrent upload of sha
+		finish    func(name string, err error)
+		writing   trace.Span     // the write phase, from the metadata to the commit
+		segments  *segmentHasher // nil unless metadata sets segment_size
+		timer     = newChunkTimer(s.slowChunkThreshold)
+		chunks    int64      // chunks received, counted for acks and expected
+		ackEvery  int64  = 1 // chunks per ack
+		expected  uint64     // chunks the metadata declares, 0 when unknown
+		nacks     int        // times the next chunk failed its CRC
+		resending bool       // a chunk was NACKed and has not arrived again
+		indexed   bool       // chunks carry chunk_index, so may be resent after the commit
+
+		state  uploadState
+		commit *fileuploadv1.UploadRequest // the finish_commit or segmented_commit message
+	)
+	// hand the outcome to the uploads waiting to share the content
+	defer func() {
+		if finish != nil {
+			finish(filename, err)
+		}
+	}()
+	defer func() {
+		s.recordUpload(ctx, rpc, requested, resp.GetStoredFilename(), totalSize, resp.GetHashOk(), err)
+	}()
+	// a failed or abandoned upload (cancelled, past its deadline, stream
+	// error) must not leave a partial file behind
+	defer func() {
+		if err != nil && (file != nil || shared) {
+			if file != nil {
+				file.Close()
+			}
+			os.Remove(staged)
+		}
+	}()
+
+	// stop the hash workers of an abandoned upload
+	defer func() {
+		hasher.end()
+		segments.end()
+	}()
+	// an ack vouches for bytes in the file, not in the write buffer
+	sendAck := func(msg *fileuploadv1.UploadAck) error {
+		if buffered != nil {
+			if err := buffered.Flush(); err != nil {
+				return writeError(err)
+			}
+		}
+		return ack(msg)
+	}
+
+	if err := s.checkUploadable(); err != nil {
+		return nil, err
+	}
+
+	// memory of the chunk being written, accounted against the global budget
+	var held int64
+	defer func() { s.buffers.release(held) }()
+
+	for stream.Receive() {
+		s.buffers.release(held)
+		held = 0
+
+		// Check context for cancellation or an expired client deadline
+		if ctx.Err() != nil {
+			return nil, contextError(ctx)
+		}
+
+		timer.received(s.redact.name(filename), stream.Peer().Addr, totalSize)
+		req := stream.Msg()
+
+		switch payload := req.Payload.(type) {
+
+		case *fileuploadv1.UploadRequest_Metadata:
+			if state != expectingMetadata {
+				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("metadata already received"))
+			}
+			state = receivingChunks
+
+			requested = sanitizeFilename(payload.Metadata.Filename)
+			filename = requested
+			dryRun = payload.Metadata.DryRun
+			hashOnly = payload.Metadata.HashOnly
+			// hash only uploads store nothing
+			if !hashOnly {
+				if err := s.checkExtension(ctx, requested); err != nil {
+					return nil, err
+				}
+			}
+			extract = payload.Metadata.Extract && !dryRun && !hashOnly
+			if err := s.checkExtract(requested, payload.Metadata.Extract); err != nil {
+				return nil, err
+			}
+			if s.skipHash && (hashOnly || payload.Metadata.SegmentSize > 0 || payload.Metadata.IfMatchSha256 != "") {
+				return nil, connect.NewError(connect.CodeFailedPrecondition, errHashingDisabled)
+			}
+			// the metadata fields were checked by validationInterceptor
+			if payload.Metadata.DeclaredSize != nil {
+				declared = payload.Metadata.GetDeclaredSize()
+				// refuse before a single chunk is read rather than mid-stream
+				if err := s.checkFileSize(declared); err != nil {
+					return nil, err
+				}
+			}
+			ttl, expiresAt = payload.Metadata.TtlSeconds, payload.Metadata.ExpiresUnix
+			segments = newSegmentHasher(payload.Metadata.SegmentSize, s.newChunkHash)
+			ackEvery = max(1, int64(payload.Metadata.AckEvery))
+			expected = payload.Metadata.ExpectedChunks
+			log.Printf("Upload started: %s (title: %s, dry run: %v, hash only: %v)", s.redact.name(requested), s.redact.title(payload.Metadata.Title), dryRun, hashOnly)
+
+			sha = payload.Metadata.Sha256
+			if sha != "" && !isSHA256(sha) {
+				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("sha256 must be 64 lowercase hex characters"))
+			}
+			if hashOnly {
+				out = io.Discard
+				continue
+			}
+			// checked before the existing file is truncated
+			if err := s.checkIfMatch(s.lookupName(ctx, requested), payload.Metadata.IfMatchSha256, !dryRun); err != nil {
+				return nil, err
+			}
+			if dryRun {
+				out = io.Discard
+				continue
+			}
+			release, err := s.openFiles.acquire(ctx)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+			filename = s.storedName(ctx, requested)
+			if err := s.checkRetention(filename); err != nil {
+				return nil, err
+			}
+			if staged, err = s.stagedPath(filename); err != nil {
+				return nil, connect.NewError(connect.CodeInternal, err)
+			}
+			if sha != "" {
+				if shared, finish, err = s.shareContent(ctx, sha, staged); err != nil {
+					return nil, err
+				}
+			}
+			if shared {
+				// the chunks are still hashed, to check them against sha
+				log.Printf("Upload of %s shares the file of a concurrent upload of identical content", s.redact.name(filename))
+				out = io.Discard
+				continue
+			}
+
+			file, err = createStored(staged)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInternal, err)
+			}
+			defer file.Close()
+			_, writing = startPhase(ctx, "write")
+			defer func() { endPhase(writing, err) }()
+			out = file
+			// every chunk is flushed on its own under SyncPerChunk
+			if s.sync != SyncPerChunk {
+				buffered = writeBuffers.Get().(*bufio.Writer)
+				buffered.Reset(file)
+				defer func() {
+					buffered.Reset(nil)
+					writeBuffers.Put(buffered)
+				}()
+				out = buffered
+			}
+
+		case *fileuploadv1.UploadRequest_Chunk:
+			switch state {
+			case expectingMetadata:
+				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("metadata must be sent first"))
+			case committed:
+				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("chunk received after finish_commit"))
+			}
+
+			if req.ChunkIndex != nil && req.GetChunkIndex() != uint64(chunks) {
+				// sent before the client learnt of the NACK
+				if resending {
+					continue
+				}
+				return nil, connect.NewError(connect.CodeInvalidArgument,
+					fmt.Errorf("chunk_index %d received, expected %d", req.GetChunkIndex(), chunks))
+			}
+			if resending && req.ChunkIndex == nil {
+				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("chunk_index is required to resend a NACKed chunk"))
+			}
+			resending, indexed = false, req.ChunkIndex != nil
+			if req.ChunkCrc32C != nil && crc32.Checksum(payload.Chunk, crc32c) != req.GetChunkCrc32C() {
+				if ack == nil {
+					return nil, connect.NewError(connect.CodeDataLoss, fmt.Errorf("chunk %d failed its CRC32C check", chunks))
+				}
+				if nacks++; nacks > maxChunkNacks {
+					return nil, connect.NewError(connect.CodeDataLoss,
+						fmt.Errorf("chunk %d failed its CRC32C check %d times", chunks, nacks))
+				}
+				log.Printf("Chunk %d of %s failed its CRC32C check, asking for it again", chunks, s.redact.name(filename))
+				resending = true
+				if err := sendAck(&fileuploadv1.UploadAck{
+					Offset:     totalSize,
+					ChunkIndex: uint64(chunks),
+					Status:     fileuploadv1.ChunkStatus_CHUNK_STATUS_CORRUPT,
+				}); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			nacks = 0
+
+			if expected > 0 && uint64(chunks) >= expected {
+				return nil, connect.NewError(connect.CodeInvalidArgument,
+					fmt.Errorf("received more than declared: %d chunks declared", expected))
+			}
+			if declared >= 0 && totalSize+int64(len(payload.Chunk)) > declared {
+				return nil, connect.NewError(connect.CodeInvalidArgument,
+					fmt.Errorf("received more than declared: %d bytes declared", declared))
+			}
+			if err := s.checkFileSize(totalSize + int64(len(payload.Chunk))); err != nil {
+				return nil, err
+			}
+			// over budget, this stream stops reading until other chunks are written
+			if held, err = s.buffers.acquire(ctx, int64(len(payload.Chunk))); err != nil {
+				return nil, contextError(ctx)
+			}
+
+			// Write to file AND update hash
+			if err := writeFull(out, payload.Chunk); err != nil {
+				// the deferred cleanup removes the partial file
+				return nil, writeError(err)
+			}
+			if file != nil {
+				if err := s.syncChunk(file); err != nil {
+					return nil, writeError(err)
+				}
+			}
+			hasher.Write(payload.Chunk)
+			segments.Write(payload.Chunk)
+			totalSize += int64(len(payload.Chunk))
+			chunks++
+			if ack != nil && chunks%ackEvery == 0 {
+				if err := sendAck(writtenAck(totalSize, chunks)); err != nil {
+					return nil, err
+				}
+			}
+
+		case *fileuploadv1.UploadRequest_FinishCommit, *fileuploadv1.UploadRequest_SegmentedCommit:
+			// the client sends the commit again after the NACKed chunk
+			if resending {
+				continue
+			}
+			switch state {
+			case expectingMetadata:
+				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("no file data received"))
+			case committed:
+				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("duplicate finish_commit"))
+			}
+			if declared >= 0 && totalSize != declared {
+				return nil, connect.NewError(connect.CodeInvalidArgument,
+					fmt.Errorf("received less than declared: %d of %d bytes", totalSize, declared))
+			}
+			if expected > 0 && uint64(chunks) != expected {
+				return nil, connect.NewError(connect.CodeInvalidArgument,
+					fmt.Errorf("received less than declared: %d of %d chunks", chunks, expected))
+			}
+			// chunks lost on the way leave a hash over what arrived, only the count tells
+			if req.CommitSize != nil && req.GetCommitSize() != totalSize {
+				return nil, connect.NewError(connect.CodeInvalidArgument,
+					fmt.Errorf("client sent %d bytes but the server received %d", req.GetCommitSize(), totalSize))
+			}
+
+			state = committed
+			commit = req
+			// a client sending indexed chunks waits for the last ones to be
+			// acknowledged, to resend any NACKed
+			if ack != nil && indexed && chunks%ackEvery != 0 {
+				if err := sendAck(writtenAck(totalSize, chunks)); err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("unknown message type"))
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		// the stream breaks when the deadline passes, report the deadline
+		if ctx.Err() != nil {
+			return nil, contextError(ctx)
+		}
+		return nil, err
+	}
+
+	if state != committed {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("stream closed without commit"))
+	}
+	if buffered != nil {
+		if err := buffered.Flush(); err != nil {
+			return nil, writeError(err)
+		}
+	}
+
+	// Final hash verification
+	_, hashing := startPhase(ctx, "hash")
+	serverHash := hasher.sum()
+	hashing.End()
+	clientHash := commit.GetFinishCommit()
+	var bad []int64
+	if segmented := commit.GetSegmentedCommit(); segmented != nil {
+		if segments == nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("segmented commit requires segment_size in metadata"))
+		}
+		clientHash = segmented.Sha256
+		if bad, err = segments.corrupt(segmented.SegmentSha256); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+	}
+
+	log.Printf("Upload complete: %s (%d bytes)", s.redact.name(filename), totalSize)
+	log.Printf("Hash verification - Server: %s, Client: %s", s.redact.hash(serverHash), s.redact.hash(clientHash))
+
+	if hashOnly {
+		return &fileuploadv1.UploadResponse{
+			Message:    "Hash computed, nothing stored",
+			Size:       totalSize,
+			HashOk:     serverHash == clientHash,
+			HashStatus: hashStatus(serverHash, clientHash),
+			Sha256:     serverHash,
+		}, nil
+	}
+	if sha != "" && clientHash != sha {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("the declared sha256 differs from the hash of the commit"))
+	}
+	// a shared upload has no file of its own to repair
+	if len(bad) > 0 && file != nil {
+		return nil, s.keepForRepair(ctx, file, staged, requested, totalSize, segments.size, bad)
+	}
+	if serverHash != clientHash && !s.skipHash {
+		log.Printf("HASH MISMATCH! Deleting corrupted file")
+		return nil, connect.NewError(connect.CodeDataLoss, errors.New("checksum mismatch"))
+	}
+	message, status := "Upload successful and verified", fileuploadv1.HashStatus_HASH_STATUS_VERIFIED
+	if s.skipHash {
+		// the content was never hashed, its hash is neither checked nor known
+		message, status = "Upload successful, hash not verified", fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED
+	}
+
+	// a TTL counts from the time the file is stored
+	expires, err := expiryTime(ttl, expiresAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		if err := s.checkDuplicate(s.lookupName(ctx, filename), serverHash); err != nil {
+			return nil, err
+		}
+		return &fileuploadv1.UploadResponse{
+			Message:     "Dry run: upload would be accepted",
+			Size:        totalSize,
+			HashOk:      !s.skipHash,
+			HashStatus:  status,
+			Sha256:      serverHash,
+			ExpiresUnix: unixOrZero(expires),
+		}, nil
+	}
+
+	if file != nil {
+		if err := errors.Join(s.syncCommit(file), file.Close()); err != nil {
+			return nil, writeError(err)
+		}
+	}
+	if err := s.placeStored(staged, filename, serverHash); err != nil {
+		return nil, err
+	}
+	s.expiries.set(filename, expires)
+
+	if shared {
+		message += ", content shared with a concurrent upload"
+	}
+	// placed: a failure to extract or publish leaves the stored file alone
+	file, shared = nil, false
+	var extracted string
+	if extract {
+		if extracted, err = s.extractStored(ctx, requested, filename); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.uploaded(ctx, rpc, requested, filename, totalSize, serverHash); err != nil {
+		return nil, err
+	}
+	return &fileuploadv1.UploadResponse{
+		Message:        message,
+		Size:           totalSize,
+		HashOk:         !s.skipHash,
+		HashStatus:     status,
+		Sha256:         serverHash,
+		StoredFilename: baseName(filename),
+		StoragePath:    s.files.relPath(filename),
+		ExtractedDir:   extracted,
+		ExpiresUnix:    unixOrZero(expires),
+	}, nil
+}
+
+// UploadFile handles unary uploads from browser clients
+func (s *Server) UploadFile(
+	ctx context.Context, req *fileuploadv1.UploadFileRequest) (resp *fileuploadv1.UploadResponse, err error) {
+
+	filename := sanitizeFilename(req.Filename)
+
+	defer func() {
+		s.recordUpload(ctx, "UploadFile", filename, resp.GetStoredFilename(), int64(len(req.Data)), resp.GetHashOk(), err)
+	}()
+
+	if err := s.checkUploadable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkExtension(ctx, filename); err != nil {
+		return nil, err
+	}
+	// the whole request is in memory, steer large files to the streaming RPC
+	if s.maxUnaryUploadBytes > 0 && int64(len(req.Data)) > s.maxUnaryUploadBytes {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf(
+			"UploadFile data of %d bytes is over the %d byte limit: use the streaming Upload RPC, or UploadFile with offset in smaller chunks",
+			len(req.Data), s.maxUnaryUploadBytes))
+	}
+
+	// the other fields were checked by validationInterceptor
+	expires, err := expiryTime(req.TtlSeconds, req.ExpiresUnix, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkExtract(filename, req.Extract); err != nil {
+		return nil, err
+	}
+	if req.Offset != nil {
+		return s.uploadFileChunk(ctx, filename, req, expires)
+	}
+
+	log.Printf("UploadFile: %s (title: %s, dry run: %v)", s.redact.name(filename), s.redact.title(req.Title), req.DryRun)
+
+	if err := s.checkFileSize(int64(len(req.Data))); err != nil {
+		return nil, err
+	}
+
+	// Calculate and verify hash
+	_, hashing := startPhase(ctx, "hash")
+	hasher := sha256.New()
+	hasher.Write(req.Data)
+	serverHash := hex.EncodeToString(hasher.Sum(nil))
+	hashOk := (serverHash == req.Sha256)
+	hashing.End()
+
+	log.Printf("Hash verification - Server: %s, Client: %s, OK: %v", s.redact.hash(serverHash), s.redact.hash(req.Sha256), hashOk)
+
+	if err := s.checkIfMatch(s.lookupName(ctx, filename), req.IfMatchSha256, !req.DryRun); err != nil {
+		return nil, err
+	}
+	if req.DryRun {
+		if err := s.checkDuplicate(s.lookupName(ctx, filename), serverHash); err != nil {
+			return nil, err
+		}
+		return &fileuploadv1.UploadResponse{
+			Message:     "dry run",
+			Size:        int64(len(req.Data)),
+			HashOk:      hashOk,
+			HashStatus:  hashStatus(serverHash, req.Sha256),
+			Sha256:      serverHash,
+			ExpiresUnix: unixOrZero(expires),
+		}, nil
+	}
+
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	stored := s.storedName(ctx, filename)
+	if err := s.checkRetention(stored); err != nil {
+		return nil, err
+	}
+	staged, err := s.stagedPath(stored)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	// Write file, unless a concurrent upload of the same content stores it first
+	shared, finish, err := s.shareContent(ctx, serverHash, staged)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { finish(stored, err) }()
+	if shared {
+		log.Printf("UploadFile: %s shares the file of a concurrent upload of identical content", s.redact.name(filename))
+	} else {
+		_, writing := startPhase(ctx, "write")
+		err := s.writeStored(staged, req.Data)
+		endPhase(writing, err)
+		if err != nil {
+			// do not leave a partial file taking up space
+			os.Remove(staged)
+			return nil, writeError(err)
+		}
+	}
+	if err := s.placeStored(staged, stored, serverHash); err != nil {
+		return nil, err
+	}
+	s.expiries.set(stored, expires)
+	var extracted string
+	if req.Extract {
+		if extracted, err = s.extractStored(ctx, filename, stored); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.uploaded(ctx, "UploadFile", filename, stored, int64(len(req.Data)), serverHash); err != nil {
+		return nil, err
+	}
+
+	return &fileuploadv1.UploadResponse{
+		Message:        "ok",
+		Size:           int64(len(req.Data)),
+		HashOk:         hashOk,
+		HashStatus:     hashStatus(serverHash, req.Sha256),
+		Sha256:         serverHash,
+		StoredFilename: baseName(stored),
+		StoragePath:    s.files.relPath(stored),
+		ExtractedDir:   extracted,
+		ExpiresUnix:    unixOrZero(expires),
+	}, nil
+}
+
+// hashStatus tells whether clientHash, empty when the client sent none,
+// confirms serverHash
+func hashStatus(serverHash, clientHash string) fileuploadv1.HashStatus {
+	switch clientHash {
+	case "":
+		return fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED
+	case serverHash:
+		return fileuploadv1.HashStatus_HASH_STATUS_VERIFIED
+	}
+	return fileuploadv1.HashStatus_HASH_STATUS_MISMATCH
+}
+
+// uploadFileChunk stores one chunk of an UploadFile split over several calls
+// with offset and is_last, completing the file once every byte is in. Calls
+// sending an Idempotency-Key only store content with their sha256, and
+// repeating the key of a completed upload returns its result again. The file
+// expires at expires, taken from the completing call.
+func (s *Server) uploadFileChunk(ctx context.Context, filename string, req *fileuploadv1.UploadFileRequest, expires time.Time) (*fileuploadv1.UploadResponse, error) {
+	offset := req.GetOffset()
+	switch {
+	case req.DryRun:
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("dry_run cannot be combined with offset"))
+	case len(req.Data) == 0:
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("chunk has no data"))
+	}
+	keyed, err := s.beginKeyed(ctx, rpcHeader(ctx), filename, req.Sha256)
+	if err != nil {
+		return nil, err
+	}
+	if keyed.Result != nil {
+		log.Printf("UploadFile: %s already stored under its idempotency key", s.redact.name(filename))
+		return keyedResult(keyed)
+	}
+	if req.PrefixSha256 != "" && offset > 0 {
+		if err := s.verifyPrefix(ctx, filename, offset, req.PrefixSha256); err != nil {
+			return nil, err
+		}
+	}
+	total := int64(-1)
+	if req.IsLast {
+		total = offset + int64(len(req.Data))
+	}
+	rng := byteRange{offset, offset + int64(len(req.Data)) - 1}
+	state, err := s.writeRange(ctx, filename, total, rng, bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, err
+	}
+	if !state.complete() {
+		return &fileuploadv1.UploadResponse{
+			Message: "chunk received",
+			Size:    state.contiguous(),
+		}, nil
+	}
+
+	if err := s.checkIfMatch(s.lookupName(ctx, filename), req.IfMatchSha256, true); err != nil {
+		// keep the complete partial file, the call can be retried
+		if err := s.ranged.resume(scopedName(ctx, filename), state); err != nil {
+			log.Printf("UploadFile: cannot save the state of %s: %v", s.redact.name(filename), s.redact.err(err))
+		}
+		return nil, err
+	}
+	size := state.total
+	stored, serverHash, err := s.finishPartial(ctx, filename, size, keyed.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	s.expiries.set(stored, expires)
+	var extracted string
+	if req.Extract {
+		if extracted, err = s.extractStored(ctx, filename, stored); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.uploaded(ctx, "UploadFile", filename, stored, size, serverHash); err != nil {
+		return nil, err
+	}
+	hashOk := serverHash == req.Sha256
+	log.Printf("UploadFile complete: %s (%d bytes)", s.redact.name(stored), size)
+	log.Printf("Hash verification - Server: %s, Client: %s, OK: %v", s.redact.hash(serverHash), s.redact.hash(req.Sha256), hashOk)
+	resp := &fileuploadv1.UploadResponse{
+		Message:        "ok",
+		Size:           size,
+		HashOk:         hashOk,
+		HashStatus:     hashStatus(serverHash, req.Sha256),
+		Sha256:         serverHash,
+		StoredFilename: baseName(stored),
+		StoragePath:    s.files.relPath(stored),
+		ExtractedDir:   extracted,
+		ExpiresUnix:    unixOrZero(expires),
+	}
+	s.completeKeyed(ctx, keyed, resp)
+	return resp, nil
+}
+
+// GetServerInfo reports the server version and capabilities, with the
+// limits a client needs to choose between UploadFile and Upload
+func (s *Server) GetServerInfo(
+	ctx context.Context, req *fileuploadv1.GetServerInfoRequest) (*fileuploadv1.GetServerInfoResponse, error) {
+
+	setCacheable(ctx, serverInfoMaxAge)
+	return &fileuploadv1.GetServerInfoResponse{
+		Version:             version,
+		MaxUnaryUploadBytes: s.maxUnaryUploadBytes,
+		MaxMessageBytes:     int64(s.maxMessageBytes),
+	}, nil
+}
+
+// GetFileMetadata returns the size, SHA-256 and modification time of a stored file
+func (s *Server) GetFileMetadata(
+	ctx context.Context, req *fileuploadv1.GetFileMetadataRequest) (*fileuploadv1.GetFileMetadataResponse, error) {
+
+	filename := s.lookupName(ctx, sanitizeFilename(req.Filename))
+	safePath := s.files.path(filename)
+
+	info, err := os.Stat(safePath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("file not found: %s", baseName(filename)))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	hash, err := hashFile(safePath)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	location, err := s.externalLocation(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	setCacheable(ctx, fileMetadataMaxAge)
+	return &fileuploadv1.GetFileMetadataResponse{
+		Filename:     baseName(filename),
+		Size:         info.Size(),
+		Sha256:       hash,
+		ModifiedUnix: info.ModTime().Unix(),
+		DownloadUrl:  location,
+		HasThumbnail: s.hasThumbnail(filename, info),
+		ExpiresUnix:  unixOrZero(s.expiries.get(filename)),
+	}, nil
+}
+
+// StatFile reports whether filename is stored, with its size, indexed hash
+// and modification time, without reading the file
+func (s *Server) StatFile(
+	ctx context.Context, req *fileuploadv1.StatFileRequest) (*fileuploadv1.StatFileResponse, error) {
+
+	filename := s.lookupName(ctx, sanitizeFilename(req.Filename))
+	resp := &fileuploadv1.StatFileResponse{Filename: baseName(filename)}
+	safePath := s.files.path(filename)
+	info, err := os.Stat(safePath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return resp, nil
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	hash, ok := s.index.hashOf(filename)
+	if !ok {
+		// stored behind the server's back, hashing is the only way
+		if hash, err = hashFile(safePath); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	}
+	resp.Exists = true
+	resp.Size = info.Size()
+	resp.Sha256 = hash
+	resp.ModifiedUnix = info.ModTime().Unix()
+	return resp, nil
+}
+
+// GetUploadStatus reports how many leading bytes of a chunked UploadFile or
+// ranged PUT upload of the caller have arrived. The state is saved next to
+// the partial file, so uploads resume from the same offset after a server
+// restart. Asked with the Idempotency-Key of a completed upload, it reports
+// every byte in.
+func (s *Server) GetUploadStatus(
+	ctx context.Context, req *fileuploadv1.GetUploadStatusRequest) (*fileuploadv1.GetUploadStatusResponse, error) {
+
+	filename := sanitizeFilename(req.Filename)
+	key, err := idempotencyKey(ctx, rpcHeader(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if u, ok := s.keys.lookup(key); ok && u.Filename == filename && u.Result != nil {
+		result, err := keyedResult(u)
+		if err != nil {
+			return nil, err
+		}
+		return &fileuploadv1.GetUploadStatusResponse{
+			Filename:  filename,
+			Offset:    result.Size,
+			TotalSize: proto.Int64(result.Size),
+		}, nil
+	}
+	state := s.ranged.status(scopedName(ctx, filename))
+	resp := &fileuploadv1.GetUploadStatusResponse{
+		Filename: filename,
+		Offset:   state.contiguous(),
+	}
+	if state.total >= 0 {
+		resp.TotalSize = proto.Int64(state.total)
+	}
+	return resp, nil
+}
+
+// Download streams a stored file back to the client in chunks
+func (s *Server) Download(
+	ctx context.Context, req *fileuploadv1.DownloadRequest, stream *connect.ServerStream[fileuploadv1.DownloadResponse]) error {
+
+	filename := s.lookupName(ctx, sanitizeFilename(req.Filename))
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	file, err := s.openStoredFile(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	location, err := s.externalLocation(filename)
+	if err != nil {
+		return err
+	}
+	if location != "" {
+		log.Printf("Download of %s handed off to external storage", s.redact.name(filename))
+		return stream.Send(&fileuploadv1.DownloadResponse{Location: location})
+	}
+
+	if req.Offset != 0 {
+		info, err := file.Stat()
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		if req.Offset < 0 || req.Offset > info.Size() {
+			return connect.NewError(connect.CodeOutOfRange,
+				fmt.Errorf("offset %d is outside the %d bytes of %s", req.Offset, info.Size(), baseName(filename)))
+		}
+		if _, err := file.Seek(req.Offset, io.SeekStart); err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+	}
+
+	log.Printf("Download started: %s (offset %d)", s.redact.name(filename), req.Offset)
+	buf := make([]byte, downloadChunkSize)
+	for {
+		if ctx.Err() != nil {
+			return contextError(ctx)
+		}
+		n, err := file.Read(buf)
+		if n > 0 {
+			if sendErr := stream.Send(&fileuploadv1.DownloadResponse{Chunk: buf[:n]}); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+	}
+}
+
+// externalLocation returns the Config.ExternalURL of a stored file, "" when
+// files are served directly
+func (s *Server) externalLocation(filename string) (string, error) {
+	if s.externalURL == nil {
+		return "", nil
+	}
+	location, err := s.externalURL(s.files.relPath(filename))
+	if err != nil {
+		return "", connect.NewError(connect.CodeUnavailable, fmt.Errorf("locate %s on external storage: %w", baseName(filename), err))
+	}
+	return location, nil
+}
+
+// openStoredFile opens a regular file of the upload directory, reporting anything else as CodeNotFound
+func (s *Server) openStoredFile(filename string) (*os.File, error) {
+	file, err := os.Open(s.files.path(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("file not found: %s", baseName(filename)))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("file not found: %s", baseName(filename)))
+	}
+	return file, nil
+}
+
+// checkFileSize returns CodeResourceExhausted when size exceeds the maximum file size
+func (s *Server) checkFileSize(size int64) error {
+	if s.maxFileSize > 0 && size > s.maxFileSize {
+		return connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("file exceeds the maximum size of %d bytes", s.maxFileSize))
+	}
+	return nil
+}
+
+// checkTitle applies the title rules of validationInterceptor to the uploads
+// it does not see, over plain HTTP and tus
+func (s *Server) checkTitle(title string) error {
+	return s.rules.title(title)
+}
+
+// contextError maps a done context to CodeDeadlineExceeded or CodeCanceled
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return connect.NewError(connect.CodeDeadlineExceeded, ctx.Err())
+	}
+	return connect.NewError(connect.CodeCanceled, ctx.Err())
+}
+
+// setCacheable lets browsers and HTTP intermediaries cache the response when
+// a side-effect-free RPC was called with HTTP GET
+func setCacheable(ctx context.Context, maxAge time.Duration) {
+	info, ok := connect.CallInfoForHandlerContext(ctx)
+	if !ok || info.HTTPMethod() != http.MethodGet {
+		return
+	}
+	info.ResponseHeader().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file at path
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// checkWritable writes and deletes a probe file to verify that uploads can be stored in dir
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-probe-*")
+	if err != nil {
+		return err
+	}
+	_, writeErr := f.Write([]byte("probe"))
+	closeErr := f.Close()
+	removeErr := os.Remove(f.Name())
+	return errors.Join(writeErr, closeErr, removeErr)
+}
@@ -0,0 +1,449 @@
+package uploadserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+func TestMain(m *testing.M) {
+	// every upload logs a few lines, keep the test output readable
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testServer serves a Server over HTTP/2 with TLS, with a client for it
+type testServer struct {
+	*httptest.Server
+	srv    *Server
+	dir    string
+	client fileuploadv1connect.FileUploadServiceClient
+}
+
+// newTestServer serves srv, with the handler options opts, storing uploads
+// in a temporary directory, stopped with the test or benchmark
+func newTestServer(t testing.TB, srv *Server, opts ...connect.HandlerOption) *testServer {
+	t.Helper()
+	srv.dir = t.TempDir()
+	srv.files = &router{dir: srv.dir}
+	srv.ranged.dir = filepath.Join(srv.dir, partialDir, rangesDir)
+	srv.keys.dir = filepath.Join(srv.dir, partialDir, keysDir)
+	srv.expiries = &expiries{path: filepath.Join(srv.dir, expiryFile), at: make(map[string]time.Time)}
+	srv.retention = &expiries{path: filepath.Join(srv.dir, retentionFile), at: make(map[string]time.Time)}
+	// innermost, as New installs it
+	opts = append(opts, connect.WithInterceptors(validationInterceptor{srv.rules}))
+	mux := http.NewServeMux()
+	mux.Handle(fileuploadv1connect.NewFileUploadServiceHandler(srv, opts...))
+	mux.HandleFunc("POST /upload", srv.handleMultipartUpload)
+	mux.HandleFunc("PUT /files/{name}", srv.handlePutFile)
+	mux.HandleFunc("GET /files/{name}", srv.handleGetFile)
+	mux.HandleFunc(tusBasePath, srv.handleTus)
+	hs := httptest.NewUnstartedServer(withCaller(srv.withRequestConfig(checkHeaders(mux))))
+	hs.EnableHTTP2 = true
+	hs.TLS = &tls.Config{}
+	hs.StartTLS()
+	t.Cleanup(hs.Close)
+	return &testServer{
+		Server: hs,
+		srv:    srv,
+		dir:    srv.dir,
+		client: fileuploadv1connect.NewFileUploadServiceClient(hs.Client(), hs.URL),
+	}
+}
+
+// do sends req to the test server and returns the response with its body read
+func (ts *testServer) do(t *testing.T, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: read body: %v", req.Method, req.URL.Path, err)
+	}
+	return resp, body
+}
+
+// newRequest is http.NewRequest against the test server, failing the test on error
+func (ts *testServer) newRequest(t *testing.T, method, path string, body io.Reader) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// stored returns the content of a file of the upload directory
+func (ts *testServer) stored(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(ts.dir, name))
+	if err != nil {
+		t.Fatalf("read stored %s: %v", name, err)
+	}
+	return string(b)
+}
+
+// uploadFile stores data as name with UploadFile, failing the test on error
+func (ts *testServer) uploadFile(t *testing.T, name, data string) *fileuploadv1.UploadResponse {
+	t.Helper()
+	resp, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{
+		Filename: name,
+		Data:     []byte(data),
+		Sha256:   sha256Hex(data),
+	})
+	if err != nil {
+		t.Fatalf("UploadFile %s: %v", name, err)
+	}
+	return resp
+}
+
+// streamUpload streams data as name in chunks of chunk bytes and commits its hash
+func (ts *testServer) streamUpload(ctx context.Context, name string, data []byte, chunk int) (*fileuploadv1.UploadResponse, error) {
+	stream, err := ts.client.Upload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reqs := []*fileuploadv1.UploadRequest{{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: name},
+	}}}
+	for off := 0; off < len(data); off += chunk {
+		reqs = append(reqs, &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{
+			Chunk: data[off:min(off+chunk, len(data))],
+		}})
+	}
+	sum := sha256.Sum256(data)
+	reqs = append(reqs, &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{
+		FinishCommit: hex.EncodeToString(sum[:]),
+	}})
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			break // the server's error comes with the response
+		}
+	}
+	return stream.CloseAndReceive()
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// getRPC calls procedure of the upload service with HTTP GET, its JSON
+// message in the query string, as Connect does for NO_SIDE_EFFECTS methods
+func (ts *testServer) getRPC(t *testing.T, procedure, message string) (*http.Response, []byte) {
+	t.Helper()
+	query := url.Values{"connect": {"v1"}, "encoding": {"json"}, "message": {message}}
+	return ts.do(t, ts.newRequest(t, http.MethodGet, "/fileupload.v1.FileUploadService/"+procedure+"?"+query.Encode(), nil))
+}
+
+func TestGetServerInfoLimits(t *testing.T) {
+	handler, err := New(Config{Dir: t.TempDir(), Context: t.Context(), MaxUnaryUploadBytes: 1 << 20, MaxMessageBytes: 4 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := httptest.NewServer(handler)
+	t.Cleanup(hs.Close)
+	info, err := fileuploadv1connect.NewFileUploadServiceClient(hs.Client(), hs.URL).GetServerInfo(t.Context(), &fileuploadv1.GetServerInfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MaxUnaryUploadBytes != 1<<20 || info.MaxMessageBytes != 4<<20 {
+		t.Fatalf("server info %v, want the configured limits", info)
+	}
+}
+
+func TestReadRPCsAreCacheableOverGET(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	ts.uploadFile(t, "a.txt", "cached")
+
+	for _, tc := range []struct {
+		procedure, message, cacheControl, body string
+	}{
+		{"GetServerInfo", "{}", "public, max-age=300", `"version"`},
+		{"GetFileMetadata", `{"filename":"a.txt"}`, "public, max-age=10", sha256Hex("cached")},
+	} {
+		resp, body := ts.getRPC(t, tc.procedure, tc.message)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", tc.procedure, resp.StatusCode, body)
+		}
+		if got := resp.Header.Get("Cache-Control"); got != tc.cacheControl {
+			t.Errorf("GET %s: Cache-Control %q, want %q", tc.procedure, got, tc.cacheControl)
+		}
+		if !strings.Contains(string(body), tc.body) {
+			t.Errorf("GET %s: body %s lacks %s", tc.procedure, body, tc.body)
+		}
+	}
+
+	// a POST is not cached by intermediaries anyway, it gets no header
+	req := ts.newRequest(t, http.MethodPost, "/fileupload.v1.FileUploadService/GetServerInfo", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	if resp, body := ts.do(t, req); resp.StatusCode != http.StatusOK || resp.Header.Get("Cache-Control") != "" {
+		t.Fatalf("POST GetServerInfo: status %d, Cache-Control %q: %s", resp.StatusCode, resp.Header.Get("Cache-Control"), body)
+	}
+
+	// a file missing is not cached
+	if resp, _ := ts.getRPC(t, "GetFileMetadata", `{"filename":"missing.txt"}`); resp.StatusCode != http.StatusNotFound || resp.Header.Get("Cache-Control") != "" {
+		t.Fatalf("GET of a missing file: status %d, Cache-Control %q", resp.StatusCode, resp.Header.Get("Cache-Control"))
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkWritable(dir); err != nil {
+		t.Fatalf("writable directory: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("the probe file was left behind: %v", entries)
+	}
+
+	notDir := filepath.Join(dir, "file")
+	if err := os.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWritable(notDir); err == nil {
+		t.Fatal("a regular file was taken for a writable directory")
+	}
+
+	t.Run("read-only", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root writes to read-only directories")
+		}
+		readOnly := t.TempDir()
+		if err := os.Chmod(readOnly, 0555); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chmod(readOnly, 0755) })
+		if err := checkWritable(readOnly); err == nil {
+			t.Fatal("a read-only directory was taken for a writable one")
+		}
+	})
+}
+
+func TestMaxMessageBytes(t *testing.T) {
+	ts := newTestServer(t, &Server{}, connect.WithReadMaxBytes(1024))
+	ts.uploadFile(t, "small.txt", "fits")
+
+	big := strings.Repeat("x", 2048)
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "big.txt", Data: []byte(big), Sha256: sha256Hex(big)})
+	if connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("UploadFile over the message limit: %v, want resource exhausted", err)
+	}
+	// a stream sends the same content in chunks under the limit
+	if _, err := ts.streamUpload(t.Context(), "big.txt", []byte(big), 512); err != nil {
+		t.Fatalf("stream in small chunks: %v", err)
+	}
+	if _, err := ts.streamUpload(t.Context(), "big2.txt", []byte(big), 2048); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("chunk over the message limit: %v, want resource exhausted", err)
+	}
+}
+
+// shortWriter accepts at most n bytes per Write without reporting an error
+type shortWriter struct{ n int }
+
+func (w shortWriter) Write(p []byte) (int, error) {
+	return min(len(p), w.n), nil
+}
+
+func TestWriteFull(t *testing.T) {
+	if err := writeFull(shortWriter{n: 8}, []byte("fits")); err != nil {
+		t.Fatalf("complete write: %v", err)
+	}
+	if err := writeFull(shortWriter{n: 2}, []byte("too long")); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("short write: %v, want io.ErrShortWrite", err)
+	}
+	failing := errors.New("disk on fire")
+	if err := writeFull(errWriter{failing}, []byte("x")); !errors.Is(err, failing) {
+		t.Fatalf("failed write: %v, want the writer's error", err)
+	}
+}
+
+type errWriter struct{ err error }
+
+func (w errWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestSanitizeFilename(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "report.pdf", "report.pdf"},
+		{"traversal", "../../x", "x"},
+		{"nested traversal", "a/../../etc/passwd", "passwd"},
+		{"absolute path", "/etc/passwd", "passwd"},
+		{"backslashes", `..\..\windows\win.ini`, "win.ini"},
+		{"mixed separators", `a/b\c.txt`, "c.txt"},
+		{"drive letter path", `C:\Users\x.txt`, "x.txt"},
+		{"drive relative", "C:x.txt", "x.txt"},
+		{"drive root", `C:\`, "unnamed_file"},
+		{"empty", "", "unnamed_file"},
+		{"dot", ".", "unnamed_file"},
+		{"dot dot", "..", "unnamed_file"},
+		{"trailing separator", "dir/", "unnamed_file"},
+		{"dotfile", ".hidden", "hidden"},
+		{"partial dir", ".partial", "partial"},
+		{"trailing dots and spaces", " name. . ", "name"},
+		{"NUL", "a\x00b.txt", "a_b.txt"},
+		{"control characters", "a\nb\tc\x7f.txt", "a_b_c_.txt"},
+		{"NTFS stream", "file.txt:stream", "file.txt_stream"},
+		{"unicode", "résumé 日本.pdf", "résumé 日本.pdf"},
+		{"invalid UTF-8", "a\xffb.txt", "a_b.txt"},
+		{"overlong", long + ".pdf", strings.Repeat("a", 251) + ".pdf"},
+		{"overlong extension", "a." + long, ("a." + long)[:255]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitizeFilename(tc.in); got != tc.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameInvariants(t *testing.T) {
+	for _, in := range []string{
+		"../../x", `C:\x`, ".", "..", "...", "\x00", " . ", "a/", strings.Repeat("日本", 200), strings.Repeat("é", 200) + ".txt",
+	} {
+		got := sanitizeFilename(in)
+		switch {
+		case got == "" || strings.ContainsAny(got, `/\:`):
+			t.Errorf("sanitizeFilename(%q) = %q is not a single path element", in, got)
+		case strings.HasPrefix(got, ".") || strings.HasSuffix(got, ".") || strings.TrimSpace(got) != got:
+			t.Errorf("sanitizeFilename(%q) = %q has leading or trailing dots or spaces", in, got)
+		case len(got) > maxFilenameBytes || !utf8.ValidString(got):
+			t.Errorf("sanitizeFilename(%q) = %q is too long or not UTF-8", in, got)
+		case sanitizeFilename(got) != got:
+			t.Errorf("sanitizeFilename is not idempotent on %q", got)
+		}
+	}
+}
+
+// sendUpload sends reqs on an Upload stream and closes it
+func (ts *testServer) sendUpload(t *testing.T, reqs ...*fileuploadv1.UploadRequest) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			break // the server's error comes with the response
+		}
+	}
+	return stream.CloseAndReceive()
+}
+
+func TestUploadRejectsMessagesOutOfOrder(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	metadata := &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: "order.txt"},
+	}}
+	chunk := &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("data")}}
+	commit := &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("data")}}
+	segmented := &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_SegmentedCommit{
+		SegmentedCommit: &fileuploadv1.SegmentedCommit{Sha256: sha256Hex("data")},
+	}}
+
+	for name, reqs := range map[string][]*fileuploadv1.UploadRequest{
+		"chunk before the metadata":     {chunk, metadata, commit},
+		"commit before the metadata":    {commit},
+		"second metadata":               {metadata, chunk, metadata, commit},
+		"chunk after the commit":        {metadata, chunk, commit, chunk},
+		"second commit":                 {metadata, chunk, commit, commit},
+		"segmented commit after commit": {metadata, chunk, commit, segmented},
+		"metadata after the commit":     {metadata, chunk, commit, metadata},
+		"no commit":                     {metadata, chunk},
+	} {
+		if _, err := ts.sendUpload(t, reqs...); connect.CodeOf(err) != connect.CodeInvalidArgument {
+			t.Errorf("%s: %v, want invalid argument", name, err)
+		}
+		ts.assertNotStored(t, "order.txt")
+	}
+
+	if _, err := ts.sendUpload(t, metadata, chunk, commit); err != nil {
+		t.Fatalf("upload in order: %v", err)
+	}
+	if got := ts.stored(t, "order.txt"); got != "data" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestUploadMixedChunkSizes(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncOnCommit, SyncPerChunk} {
+		ts := newTestServer(t, &Server{sync: policy})
+		data := make([]byte, 300<<10)
+		rand.Read(data)
+		reqs := []*fileuploadv1.UploadRequest{{Payload: &fileuploadv1.UploadRequest_Metadata{
+			Metadata: &fileuploadv1.UploadMetadata{Filename: "mixed.bin"},
+		}}}
+		// small chunks are buffered, large ones bypass the buffer, in any order
+		for off, i := 0, 0; off < len(data); i++ {
+			n := min([]int{7, 100 << 10, 1000, uploadWriteBuffer, 1}[i%5], len(data)-off)
+			reqs = append(reqs, &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: data[off : off+n]}})
+			off += n
+		}
+		reqs = append(reqs, &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex(string(data))}})
+		if _, err := ts.sendUpload(t, reqs...); err != nil {
+			t.Fatalf("sync %v: %v", policy, err)
+		}
+		if got := ts.stored(t, "mixed.bin"); got != string(data) {
+			t.Fatalf("sync %v: stored content differs", policy)
+		}
+		// a recycled buffer carries nothing over
+		if _, err := ts.streamUpload(t.Context(), "small.txt", []byte("small"), 2); err != nil {
+			t.Fatal(err)
+		}
+		if got := ts.stored(t, "small.txt"); got != "small" {
+			t.Fatalf("sync %v: stored %q", policy, got)
+		}
+	}
+}
+
+func TestUploadExpectedChunks(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	chunk := &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("ab")}}
+	commit := &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("abab")}}
+	expecting := func(n uint64) *fileuploadv1.UploadRequest {
+		return &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+			Metadata: &fileuploadv1.UploadMetadata{Filename: "counted.txt", ExpectedChunks: n},
+		}}
+	}
+
+	for _, n := range []uint64{1, 3} {
+		_, err := ts.sendUpload(t, expecting(n), chunk, chunk, commit)
+		if connect.CodeOf(err) != connect.CodeInvalidArgument {
+			t.Errorf("%d chunks declared, 2 sent: %v, want invalid argument", n, err)
+		}
+		ts.assertNotStored(t, "counted.txt")
+	}
+	for _, n := range []uint64{0, 2} {
+		if _, err := ts.sendUpload(t, expecting(n), chunk, chunk, commit); err != nil {
+			t.Fatalf("%d chunks declared, 2 sent: %v", n, err)
+		}
+	}
+	if got := ts.stored(t, "counted.txt"); got != "abab" {
+		t.Fatalf("stored %q", got)
+	}
+}
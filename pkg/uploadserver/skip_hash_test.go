@@ -0,0 +1,84 @@
+package uploadserver
+
+import (
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// commitUpload streams data in one chunk with metadata and commits hash
+func (ts *testServer) commitUpload(t *testing.T, metadata *fileuploadv1.UploadMetadata, data, hash string) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*fileuploadv1.UploadRequest{
+		{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: metadata}},
+		{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte(data)}},
+		{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: hash}},
+	} {
+		if err := stream.Send(req); err != nil {
+			break
+		}
+	}
+	return stream.CloseAndReceive()
+}
+
+func TestSkipHashVerification(t *testing.T) {
+	ts := newTestServer(t, &Server{index: newHashIndex(), skipHash: true})
+	ts.srv.index.set("a.txt", sha256Hex("before"))
+
+	// the commit is not checked against the content
+	resp, err := ts.commitUpload(t, &fileuploadv1.UploadMetadata{Filename: "a.txt"}, "unhashed", sha256Hex("something else"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.HashOk || resp.HashStatus != fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED || resp.Sha256 != "" {
+		t.Fatalf("response %+v, want no hash and not_provided", resp)
+	}
+	if got := ts.stored(t, "a.txt"); got != "unhashed" {
+		t.Fatalf("stored %q", got)
+	}
+	// the hash of the replaced content is gone from the index
+	if hash, ok := ts.srv.index.hashOf("a.txt"); ok {
+		t.Fatalf("index keeps hash %s for the unhashed file", hash)
+	}
+
+	// unary uploads are still verified
+	if resp := ts.uploadFile(t, "b.txt", "checked"); !resp.HashOk || resp.Sha256 != sha256Hex("checked") {
+		t.Fatalf("UploadFile response %+v, want verified", resp)
+	}
+}
+
+func TestSkipHashVerificationRefusesHashedUploads(t *testing.T) {
+	ts := newTestServer(t, &Server{skipHash: true})
+	for name, metadata := range map[string]*fileuploadv1.UploadMetadata{
+		"hash only": {Filename: "a.txt", HashOnly: true},
+		"segmented": {Filename: "a.txt", SegmentSize: 1 << 20},
+		"if match":  {Filename: "a.txt", IfMatchSha256: sha256Hex("old")},
+	} {
+		if _, err := ts.commitUpload(t, metadata, "data", sha256Hex("data")); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+			t.Errorf("%s upload: %v, want failed precondition", name, err)
+		}
+	}
+}
+
+func TestSkipHashVerificationConflicts(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"duplicates":  {RejectDuplicateContent: true},
+		"hashed keys": {StorageKey: "{hash[:2]}/{filename}"},
+	} {
+		cfg.Dir, cfg.SkipHashVerification = t.TempDir(), true
+		if _, err := New(cfg); err == nil || !strings.Contains(err.Error(), "hash") {
+			t.Errorf("%s: New returned %v, want refused", name, err)
+		}
+	}
+	// a template without {hash} is fine
+	if _, err := New(Config{Dir: t.TempDir(), SkipHashVerification: true, StorageKey: "{namespace}/{filename}"}); err != nil {
+		t.Fatal(err)
+	}
+}
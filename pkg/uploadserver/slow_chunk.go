@@ -0,0 +1,34 @@
+package uploadserver
+
+import (
+	"log"
+	"time"
+)
+
+// slowChunkWarnInterval rate-limits slow-chunk warnings within one upload
+const slowChunkWarnInterval = 30 * time.Second
+
+// chunkTimer warns when a client takes longer than threshold between two
+// messages of an upload stream, without failing the upload
+type chunkTimer struct {
+	threshold time.Duration // 0 disables the warning
+	last      time.Time
+	lastWarn  time.Time
+}
+
+func newChunkTimer(threshold time.Duration) *chunkTimer {
+	return &chunkTimer{threshold: threshold, last: time.Now()}
+}
+
+// received is called for every message; received is the byte count so far
+func (t *chunkTimer) received(filename, peer string, received int64) {
+	now := time.Now()
+	gap := now.Sub(t.last)
+	t.last = now
+	if t.threshold <= 0 || gap < t.threshold || now.Sub(t.lastWarn) < slowChunkWarnInterval {
+		return
+	}
+	t.lastWarn = now
+	log.Printf("Slow upload: %s from %s waited %v for the next message (%d bytes received so far)",
+		filename, peer, gap.Round(time.Millisecond), received)
+}
@@ -0,0 +1,99 @@
+package uploadserver
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// syncBuffer is a bytes.Buffer safe for the log writes of the server goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLog returns what the standard logger prints until the test ends
+func captureLog(t *testing.T) *syncBuffer {
+	t.Helper()
+	var buf syncBuffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func TestChunkTimer(t *testing.T) {
+	logged := captureLog(t)
+	timer := newChunkTimer(time.Second)
+	timer.received("a.bin", "peer", 10)
+	if logged.String() != "" {
+		t.Fatalf("warned about a fast chunk: %s", logged)
+	}
+
+	timer.last = time.Now().Add(-2 * time.Second)
+	timer.received("a.bin", "peer", 20)
+	if !strings.Contains(logged.String(), "Slow upload: a.bin from peer") || !strings.Contains(logged.String(), "20 bytes received") {
+		t.Fatalf("no warning about a slow chunk: %q", logged)
+	}
+
+	// rate-limited within one upload
+	timer.last = time.Now().Add(-2 * time.Second)
+	timer.received("a.bin", "peer", 30)
+	if n := strings.Count(logged.String(), "Slow upload"); n != 1 {
+		t.Fatalf("%d warnings within %v", n, slowChunkWarnInterval)
+	}
+
+	disabled := newChunkTimer(0)
+	disabled.last = time.Now().Add(-time.Hour)
+	disabled.received("b.bin", "peer", 1)
+	if strings.Contains(logged.String(), "b.bin") {
+		t.Fatal("a zero threshold warned")
+	}
+}
+
+func TestSlowChunkWarning(t *testing.T) {
+	logged := captureLog(t)
+	ts := newTestServer(t, &Server{slowChunkThreshold: 50 * time.Millisecond})
+
+	stream, err := ts.client.Upload(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: "slow.bin"},
+	}})
+	// two stalls in a row, the second is not logged again
+	for range 2 {
+		time.Sleep(100 * time.Millisecond)
+		stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("data")}})
+	}
+	stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{
+		FinishCommit: sha256Hex("datadata"),
+	}})
+	if _, err := stream.CloseAndReceive(); err != nil {
+		t.Fatalf("a slow upload failed: %v", err)
+	}
+	if n := strings.Count(logged.String(), "Slow upload: slow.bin"); n != 1 {
+		t.Fatalf("%d slow upload warnings, want 1:\n%s", n, logged)
+	}
+	if got := ts.stored(t, "slow.bin"); got != "datadata" {
+		t.Fatalf("stored %q", got)
+	}
+}
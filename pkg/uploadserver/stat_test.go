@@ -0,0 +1,54 @@
+package uploadserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func (ts *testServer) stat(t *testing.T, name string) *fileuploadv1.StatFileResponse {
+	t.Helper()
+	resp, err := ts.client.StatFile(t.Context(), &fileuploadv1.StatFileRequest{Filename: name})
+	if err != nil {
+		t.Fatalf("StatFile %s: %v", name, err)
+	}
+	return resp
+}
+
+func TestStatFile(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	ts.uploadFile(t, "a.txt", "hello")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filepath.Join(ts.dir, "a.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	got := ts.stat(t, "../a.txt")
+	if !got.Exists || got.Filename != "a.txt" || got.Size != 5 || got.Sha256 != sha256Hex("hello") || got.ModifiedUnix != mtime.Unix() {
+		t.Fatalf("stat of a stored file %v", got)
+	}
+
+	// a missing name is not an error, it is what the client asks about
+	if err := os.Mkdir(filepath.Join(ts.dir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"missing.txt", "subdir"} {
+		if got := ts.stat(t, name); got.Exists || got.Size != 0 || got.Sha256 != "" {
+			t.Errorf("stat of %s %v, want it missing", name, got)
+		}
+	}
+}
+
+func TestStatFileNotIndexed(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	// copied into the directory behind the server's back
+	if err := os.WriteFile(filepath.Join(ts.dir, "b.txt"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.stat(t, "b.txt"); !got.Exists || got.Sha256 != sha256Hex("outside") {
+		t.Fatalf("stat of an unindexed file %v", got)
+	}
+}
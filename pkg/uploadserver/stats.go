@@ -0,0 +1,109 @@
+package uploadserver
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// statsMaxAge is how long GetStats answers with the totals it computed last
+// instead of reading the upload directories again
+const statsMaxAge = 10 * time.Second
+
+// statsLargest is how many of the largest files GetStats reports
+const statsLargest = 10
+
+// statsCache holds the latest GetStats answer of each namespace; mu also
+// keeps concurrent calls from reading the directories at the same time
+type statsCache struct {
+	mu     sync.Mutex
+	latest map[string]cachedStats
+}
+
+type cachedStats struct {
+	resp *fileuploadv1.GetStatsResponse
+	at   time.Time
+}
+
+// GetStats totals the stored files overall and per first directory of their
+// storage path, from the same listing as ListFilesStream, and reports the
+// largest ones. Like the listing it only covers the files the caller can
+// reach, so the answer is reused for statsMaxAge per namespace.
+func (s *Server) GetStats(
+	ctx context.Context, req *fileuploadv1.GetStatsRequest) (*fileuploadv1.GetStatsResponse, error) {
+
+	namespace := callerFrom(ctx).namespace
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	if c, ok := s.stats.latest[namespace]; ok && time.Since(c.at) < statsMaxAge {
+		return c.resp, nil
+	}
+
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	now := time.Now()
+	resp := &fileuploadv1.GetStatsResponse{ComputedUnix: now.Unix()}
+	dirs := make(map[string]*fileuploadv1.DirectoryStats)
+	add := func(f *fileuploadv1.FileInfo) error {
+		resp.TotalFiles++
+		resp.TotalBytes += f.Size
+		dir, _, ok := strings.Cut(f.StoragePath, "/")
+		if !ok {
+			dir = ""
+		}
+		d := dirs[dir]
+		if d == nil {
+			d = &fileuploadv1.DirectoryStats{Directory: dir}
+			dirs[dir] = d
+		}
+		d.Files++
+		d.Bytes += f.Size
+		// trimmed now and then rather than kept sorted on every file
+		resp.LargestFiles = append(resp.LargestFiles, f)
+		if len(resp.LargestFiles) >= 2*statsLargest {
+			resp.LargestFiles = largestFiles(resp.LargestFiles)
+		}
+		return nil
+	}
+	visible := func(name string) bool { return s.lookupName(ctx, baseName(name)) == name }
+	for _, sub := range s.files.dirs() {
+		if err := s.listDir(ctx, sub, "", visible, add); err != nil {
+			return nil, err
+		}
+	}
+
+	resp.LargestFiles = largestFiles(resp.LargestFiles)
+	if resp.TotalFiles > 0 {
+		resp.AverageSize = resp.TotalBytes / resp.TotalFiles
+	}
+	for _, d := range dirs {
+		resp.Directories = append(resp.Directories, d)
+	}
+	slices.SortFunc(resp.Directories, func(a, b *fileuploadv1.DirectoryStats) int {
+		return strings.Compare(a.Directory, b.Directory)
+	})
+	if s.stats.latest == nil {
+		s.stats.latest = make(map[string]cachedStats)
+	}
+	s.stats.latest[namespace] = cachedStats{resp: resp, at: now}
+	return resp, nil
+}
+
+// largestFiles returns the statsLargest largest of files, largest first
+func largestFiles(files []*fileuploadv1.FileInfo) []*fileuploadv1.FileInfo {
+	slices.SortFunc(files, func(a, b *fileuploadv1.FileInfo) int {
+		if c := cmp.Compare(b.Size, a.Size); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Filename, b.Filename)
+	})
+	return files[:min(len(files), statsLargest)]
+}
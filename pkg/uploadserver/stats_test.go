@@ -0,0 +1,91 @@
+package uploadserver
+
+import (
+	"strings"
+	"testing"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestGetStats(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	sizes := map[string]int{"a.txt": 10, "b.txt": 40, "c.txt": 1}
+	for name, size := range sizes {
+		ts.uploadFile(t, name, strings.Repeat("x", size))
+	}
+	for i := range 12 {
+		ts.uploadFile(t, string(rune('k'+i))+".bin", strings.Repeat("y", 2))
+	}
+
+	stats, err := ts.client.GetStats(t.Context(), &fileuploadv1.GetStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalFiles != 15 || stats.TotalBytes != 75 || stats.AverageSize != 5 {
+		t.Fatalf("totals %d files, %d bytes, average %d; want 15, 75, 5", stats.TotalFiles, stats.TotalBytes, stats.AverageSize)
+	}
+	if len(stats.Directories) != 1 || stats.Directories[0].Directory != "" || stats.Directories[0].Files != 15 || stats.Directories[0].Bytes != 75 {
+		t.Fatalf("directories %v, want every file in the upload directory", stats.Directories)
+	}
+	if len(stats.LargestFiles) != statsLargest {
+		t.Fatalf("%d largest files, want %d", len(stats.LargestFiles), statsLargest)
+	}
+	for i, want := range []string{"b.txt", "a.txt", "k.bin"} {
+		if got := stats.LargestFiles[i]; got.Filename != want {
+			t.Fatalf("largest[%d] = %v, want %s", i, got, want)
+		}
+	}
+
+	// uploads within statsMaxAge are answered from the cached totals
+	ts.uploadFile(t, "late.txt", "late")
+	again, err := ts.client.GetStats(t.Context(), &fileuploadv1.GetStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.TotalFiles != 15 || again.ComputedUnix != stats.ComputedUnix {
+		t.Fatalf("second call counted %d files, want the cached 15", again.TotalFiles)
+	}
+}
+
+func TestGetStatsEmpty(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	stats, err := ts.client.GetStats(t.Context(), &fileuploadv1.GetStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalFiles != 0 || stats.AverageSize != 0 || len(stats.Directories) != 0 || len(stats.LargestFiles) != 0 {
+		t.Fatalf("stats of an empty server %v", stats)
+	}
+}
+
+func TestGetStatsKeyed(t *testing.T) {
+	ts := newKeyedServer(t, "{namespace}/{filename}")
+	for _, u := range []struct{ namespace, name, data string }{
+		{"acme", "a.txt", "hello"},
+		{"acme", "b.txt", "hi"},
+		{"globex", "secret.txt", "not for acme"},
+	} {
+		if _, err := ts.uploadIn(t, u.namespace, u.name, u.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each namespace totals only the files it can list
+	for namespace, want := range map[string]struct{ files, bytes int64 }{"acme": {2, 7}, "globex": {1, 12}} {
+		stats, err := ts.srv.GetStats(inNamespace(t.Context(), namespace), &fileuploadv1.GetStatsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.TotalFiles != want.files || stats.TotalBytes != want.bytes {
+			t.Errorf("%s: %d files, %d bytes; want %d, %d", namespace, stats.TotalFiles, stats.TotalBytes, want.files, want.bytes)
+		}
+		if len(stats.Directories) != 1 || stats.Directories[0].Directory != namespace {
+			t.Errorf("%s: directories %v, want only %s", namespace, stats.Directories, namespace)
+		}
+		for _, f := range stats.LargestFiles {
+			if !strings.HasPrefix(f.StoragePath, namespace+"/") {
+				t.Errorf("%s: largest files include %s", namespace, f.StoragePath)
+			}
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package uploadserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// storageKeyFile holds, in the upload directory, where each file placed by
+// Config.StorageKey is stored
+const storageKeyFile = ".keys.json"
+
+// keyTemplate derives where an upload is stored from Config.StorageKey, such
+// as "{namespace}/{yyyy}/{mm}/{hash[:2]}/{filename}"
+type keyTemplate struct {
+	parts []keyPart
+}
+
+// keyPart is a literal, or a variable with length the digits of {hash[:n]}
+type keyPart struct {
+	literal  string
+	variable string
+	length   int
+}
+
+// keyVariables are the variables a storage key template may use
+var keyVariables = map[string]bool{
+	"namespace": true, "yyyy": true, "mm": true, "dd": true,
+	"hash": true, "filename": true, "uuid": true,
+}
+
+// parseKeyTemplate validates a storage key template, nil for "". Its last
+// path element must be {filename}, so the stored files keep their names on
+// disk, and no element may be empty, "..", or hidden.
+func parseKeyTemplate(template string) (*keyTemplate, error) {
+	if template == "" {
+		return nil, nil
+	}
+	elements := strings.Split(template, "/")
+	if elements[len(elements)-1] != "{filename}" {
+		return nil, fmt.Errorf("storage key %q must end with /{filename}", template)
+	}
+	for _, e := range elements {
+		if e == "" || strings.HasPrefix(e, ".") || strings.Contains(e, `\`) {
+			return nil, fmt.Errorf("storage key %q has an empty, hidden or invalid path element %q", template, e)
+		}
+	}
+	t := &keyTemplate{}
+	for rest := template; rest != ""; {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.parts = append(t.parts, keyPart{literal: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("storage key %q has an unmatched }", template)
+		}
+		if open > 0 {
+			t.parts = append(t.parts, keyPart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("storage key %q has an unmatched {", template)
+		}
+		part, err := parseKeyVariable(rest[open+1 : open+end])
+		if err != nil {
+			return nil, fmt.Errorf("storage key %q: %w", template, err)
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[open+end+1:]
+	}
+	return t, nil
+}
+
+// parseKeyVariable parses the inside of {...}: a variable name, or
+// hash[:n] for the first n hex digits of the SHA-256
+func parseKeyVariable(v string) (keyPart, error) {
+	if digits, ok := strings.CutPrefix(v, "hash[:"); ok {
+		n, err := strconv.Atoi(strings.TrimSuffix(digits, "]"))
+		if !strings.HasSuffix(digits, "]") || err != nil || n < 1 || n > 64 {
+			return keyPart{}, fmt.Errorf("{%s} is not {hash[:n]} with n from 1 to 64", v)
+		}
+		return keyPart{variable: "hash", length: n}, nil
+	}
+	if !keyVariables[v] {
+		return keyPart{}, fmt.Errorf("unknown variable {%s}, want namespace, yyyy, mm, dd, hash, hash[:n], filename or uuid", v)
+	}
+	return keyPart{variable: v}, nil
+}
+
+// uses tells whether the template has the variable v
+func (t *keyTemplate) uses(v string) bool {
+	return t != nil && slices.ContainsFunc(t.parts, func(p keyPart) bool { return p.variable == v })
+}
+
+// resolve returns the key, relative to the storage directory with "/"
+// separators, of filename stored at now with hash by a caller of namespace
+func (t *keyTemplate) resolve(namespace, hash, filename string, now time.Time) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		switch p.variable {
+		case "":
+			b.WriteString(p.literal)
+		case "namespace":
+			b.WriteString(keyNamespace(namespace))
+		case "yyyy":
+			b.WriteString(now.UTC().Format("2006"))
+		case "mm":
+			b.WriteString(now.UTC().Format("01"))
+		case "dd":
+			b.WriteString(now.UTC().Format("02"))
+		case "hash":
+			if p.length > 0 && p.length < len(hash) {
+				b.WriteString(hash[:p.length])
+			} else {
+				b.WriteString(hash)
+			}
+		case "filename":
+			b.WriteString(filename)
+		case "uuid":
+			b.WriteString(newUUID())
+		}
+	}
+	return b.String()
+}
+
+// keyNamespace is the namespace a caller's files are keyed under: its
+// sanitized namespace, "default" for callers without one
+func keyNamespace(namespace string) string {
+	if namespace == "" {
+		return "default"
+	}
+	return sanitizeFilename(namespace)
+}
+
+// keyedName is the name a file stored by a caller of namespace is known by
+// once a storage key template places it: its keyNamespace, "/" and filename.
+// A sanitized filename never holds a "/", so the name cannot be mistaken for
+// a plain one, and two namespaces storing the same filename never share it.
+func keyedName(namespace, filename string) string {
+	return keyNamespace(namespace) + "/" + filename
+}
+
+// baseName returns the filename of name, keyed or not, as clients know it
+func baseName(name string) string {
+	return name[strings.LastIndexByte(name, '/')+1:]
+}
+
+// storageKeys maps the keyed names of the files placed by a storage key
+// template to their key. It is saved to path after each change, and kept
+// even once Config.StorageKey is turned off so the files stored before are
+// still found.
+type storageKeys struct {
+	mu    sync.Mutex
+	path  string
+	keys  map[string]string // keyed name -> key
+	names map[string]string // key -> keyed name
+}
+
+// storageKeysFile is the on-disk format of storageKeys
+type storageKeysFile struct {
+	Files map[string]string `json:"files"`
+}
+
+// openStorageKeys loads the keys saved at path. Like the expiries they
+// cannot be rebuilt from the stored files, so a corrupt file is an error.
+func openStorageKeys(path string) (*storageKeys, error) {
+	k := &storageKeys{path: path, keys: make(map[string]string), names: make(map[string]string)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f storageKeysFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", path, err)
+	}
+	for name, key := range f.Files {
+		namespace, filename, ok := strings.Cut(name, "/")
+		// a hand-edited file must not point outside the storage directory
+		if !ok || keyNamespace(namespace) != namespace || sanitizeFilename(filename) != filename ||
+			!filepath.IsLocal(filepath.FromSlash(key)) || filepath.Base(filepath.FromSlash(key)) != filename {
+			return nil, fmt.Errorf("%s: invalid key %q for %s", path, key, name)
+		}
+		if other, taken := k.names[key]; taken {
+			return nil, fmt.Errorf("%s: key %q is used by both %s and %s", path, key, other, name)
+		}
+		k.keys[name] = key
+		k.names[key] = name
+	}
+	return k, nil
+}
+
+// get returns the key of the keyed name, "" when it has none
+func (k *storageKeys) get(name string) string {
+	if k == nil {
+		return ""
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.keys[name]
+}
+
+// nameOf returns the keyed name of the file stored at key, "" for none
+func (k *storageKeys) nameOf(key string) string {
+	if k == nil {
+		return ""
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.names[key]
+}
+
+// set records the key of the keyed name, or forgets it when key is ""
+func (k *storageKeys) set(name, key string) {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	old := k.keys[name]
+	if old == key {
+		return
+	}
+	if old != "" {
+		delete(k.names, old)
+	}
+	if key == "" {
+		delete(k.keys, name)
+	} else {
+		k.keys[name] = key
+		k.names[key] = name
+	}
+	if k.path == "" {
+		return
+	}
+	b, err := json.Marshal(storageKeysFile{Files: k.keys})
+	if err == nil {
+		tmp := k.path + ".tmp"
+		if err = os.WriteFile(tmp, b, 0644); err == nil {
+			err = os.Rename(tmp, k.path)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save %s: %v", filepath.Base(k.path), err)
+	}
+}
+
+// dirs returns the distinct directories of the keys, sorted
+func (k *storageKeys) dirs() []string {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, key := range k.keys {
+		dir := filepath.Dir(filepath.FromSlash(key))
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// lookupName returns the name filename is known by to the caller of ctx:
+// its keyed name when a storage key placed it for the caller's namespace,
+// filename itself otherwise. Files another namespace stored under a key are
+// out of reach.
+func (s *Server) lookupName(ctx context.Context, filename string) string {
+	if name := keyedName(callerFrom(ctx).namespace, filename); s.files.keys.get(name) != "" {
+		return name
+	}
+	return filename
+}
+
+// uploadName returns the name a file the caller of ctx writes as filename,
+// other than by an upload, is stored under: its keyedName under
+// Config.StorageKey, filename itself otherwise
+func (s *Server) uploadName(ctx context.Context, filename string) string {
+	if s.storageKey == nil {
+		return filename
+	}
+	return keyedName(callerFrom(ctx).namespace, filename)
+}
+
+// resolveKey returns the key Config.StorageKey places the keyed name with
+// content hash at. A key holding the file of another name is refused with
+// CodeAlreadyExists: an upload never writes over another namespace's file.
+func (s *Server) resolveKey(name, hash string) (string, error) {
+	namespace, filename, _ := strings.Cut(name, "/")
+	key := s.storageKey.resolve(namespace, hash, filename, time.Now())
+	if key == s.files.keys.get(name) {
+		return key, nil
+	}
+	// nor over a file stored before the template, or behind the server's back
+	_, err := os.Lstat(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if s.files.keys.nameOf(key) != "" || !errors.Is(err, os.ErrNotExist) {
+		return "", connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("storage key %s is taken", key))
+	}
+	return key, nil
+}
+
+// writePath returns where a copy or rename writes name with content hash,
+// and the key it goes to when name is keyed. A keyed name already stored is
+// refused with CodeAlreadyExists unless overwrite, like a plain file.
+func (s *Server) writePath(name, hash string, overwrite bool) (path, key string, err error) {
+	if !isKeyed(name) {
+		return s.files.path(name), "", nil
+	}
+	if !overwrite && s.files.keys.get(name) != "" {
+		return "", "", connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("%s already exists", baseName(name)))
+	}
+	if key, err = s.resolveKey(name, hash); err != nil {
+		return "", "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), key, nil
+}
+
+// keyed records that the keyed name is now stored at key. The file it was
+// stored at before, under a key that resolved differently, is deleted: the
+// new content replaced it.
+func (s *Server) keyed(name, key string) {
+	if old := s.files.keys.get(name); old != "" && old != key {
+		os.Remove(filepath.Join(s.dir, filepath.FromSlash(old)))
+	}
+	s.files.keys.set(name, key)
+}
+
+// isKeyed reports whether name is a keyedName, placed by a storage key
+func isKeyed(name string) bool {
+	return strings.Contains(name, "/")
+}
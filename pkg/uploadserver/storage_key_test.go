@@ -0,0 +1,344 @@
+package uploadserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// newKeyedServer starts a test server placing uploads at template
+func newKeyedServer(t *testing.T, template string) *testServer {
+	t.Helper()
+	key, err := parseKeyTemplate(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, &Server{index: newHashIndex(), storageKey: key})
+	if ts.srv.files.keys, err = openStorageKeys(filepath.Join(ts.dir, storageKeyFile)); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+// uploadIn stores name with data as a caller of namespace
+func (ts *testServer) uploadIn(t *testing.T, namespace, name, data string) (*fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	return ts.srv.UploadFile(inNamespace(t.Context(), namespace), &fileuploadv1.UploadFileRequest{
+		Filename: name, Data: []byte(data), Sha256: sha256Hex(data),
+	})
+}
+
+func TestParseKeyTemplate(t *testing.T) {
+	for _, template := range []string{
+		"{filename}",
+		"{namespace}/{filename}",
+		"{namespace}/{yyyy}/{mm}/{hash[:2]}/{filename}",
+		"archive-{yyyy}{mm}{dd}/{uuid}/{filename}",
+	} {
+		if _, err := parseKeyTemplate(template); err != nil {
+			t.Errorf("parseKeyTemplate(%q): %v", template, err)
+		}
+	}
+	for _, template := range []string{
+		"{namespace}",
+		"{filename}/{namespace}",
+		"/{filename}",
+		"a//{filename}",
+		"../{filename}",
+		".hidden/{filename}",
+		"{user}/{filename}",
+		"{hash[:0]}/{filename}",
+		"{hash[:65]}/{filename}",
+		"{hash[2]}/{filename}",
+		"{yyyy/{filename}",
+		"yyyy}/{filename}",
+	} {
+		if _, err := parseKeyTemplate(template); err == nil {
+			t.Errorf("parseKeyTemplate(%q) accepted", template)
+		}
+	}
+	if key, err := parseKeyTemplate(""); key != nil || err != nil {
+		t.Errorf("empty template: %v, %v", key, err)
+	}
+}
+
+func TestKeyTemplateResolve(t *testing.T) {
+	now := time.Date(2026, 3, 7, 23, 30, 0, 0, time.FixedZone("", -2*60*60))
+	hash := sha256Hex("content")
+	for _, tc := range []struct{ template, namespace, want string }{
+		{"{filename}", "acme", "report.pdf"},
+		{"{namespace}/{filename}", "acme", "acme/report.pdf"},
+		{"{namespace}/{filename}", "", "default/report.pdf"},
+		{"{namespace}/{yyyy}/{mm}/{dd}/{filename}", "acme", "acme/2026/03/08/report.pdf"},
+		{"{hash[:2]}/{hash[:4]}/{filename}", "acme", hash[:2] + "/" + hash[:4] + "/report.pdf"},
+		{"by-hash/{hash}/{filename}", "acme", "by-hash/" + hash + "/report.pdf"},
+	} {
+		key, err := parseKeyTemplate(tc.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := key.resolve(tc.namespace, hash, "report.pdf", now); got != tc.want {
+			t.Errorf("%s in %q = %q, want %q", tc.template, tc.namespace, got, tc.want)
+		}
+	}
+	key, _ := parseKeyTemplate("{uuid}/{filename}")
+	if a, b := key.resolve("", hash, "a.txt", now), key.resolve("", hash, "a.txt", now); a == b {
+		t.Errorf("{uuid} resolved to %s twice", a)
+	}
+}
+
+func TestStorageKeyPlacesUploads(t *testing.T) {
+	for _, template := range []string{
+		"{filename}",
+		"{namespace}/{filename}",
+		"{namespace}/{yyyy}/{mm}/{hash[:2]}/{filename}",
+		"files/{uuid}/{filename}",
+	} {
+		ts := newKeyedServer(t, template)
+		resp, err := ts.uploadIn(t, "acme", "report.pdf", "content")
+		if err != nil {
+			t.Fatalf("%s: %v", template, err)
+		}
+		if resp.StoredFilename != "report.pdf" {
+			t.Errorf("%s: stored as %q", template, resp.StoredFilename)
+		}
+		if got := ts.stored(t, resp.StoragePath); got != "content" {
+			t.Errorf("%s: %s holds %q", template, resp.StoragePath, got)
+		}
+		if template == "{namespace}/{yyyy}/{mm}/{hash[:2]}/{filename}" {
+			want := "acme/" + time.Now().UTC().Format("2006/01") + "/" + sha256Hex("content")[:2] + "/report.pdf"
+			if resp.StoragePath != want {
+				t.Errorf("%s: stored at %s, want %s", template, resp.StoragePath, want)
+			}
+		}
+		// the other upload paths are placed the same way
+		streamed, err := ts.streamUpload(t.Context(), "streamed.txt", []byte("streamed"), 3)
+		if err != nil {
+			t.Fatalf("%s: streamed: %v", template, err)
+		}
+		if got := ts.stored(t, streamed.StoragePath); got != "streamed" {
+			t.Errorf("%s: %s holds %q", template, streamed.StoragePath, got)
+		}
+		// found by its owner only
+		stat, err := ts.srv.StatFile(inNamespace(t.Context(), "acme"), &fileuploadv1.StatFileRequest{Filename: "report.pdf"})
+		if err != nil || !stat.Exists || stat.Sha256 != sha256Hex("content") {
+			t.Errorf("%s: acme stats %v, %v", template, stat, err)
+		}
+		stat, err = ts.srv.StatFile(inNamespace(t.Context(), "globex"), &fileuploadv1.StatFileRequest{Filename: "report.pdf"})
+		if err != nil || stat.Exists {
+			t.Errorf("%s: globex stats %v, %v", template, stat, err)
+		}
+	}
+}
+
+func TestStorageKeyPerNamespace(t *testing.T) {
+	ts := newKeyedServer(t, "{namespace}/{filename}")
+	for namespace, data := range map[string]string{"acme": "from acme", "globex": "from globex"} {
+		resp, err := ts.uploadIn(t, namespace, "a.txt", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := namespace + "/a.txt"; resp.StoragePath != want {
+			t.Fatalf("stored at %s, want %s", resp.StoragePath, want)
+		}
+	}
+	// neither upload overwrote nor moved the other namespace's file
+	if got := ts.stored(t, "acme/a.txt"); got != "from acme" {
+		t.Fatalf("acme/a.txt holds %q", got)
+	}
+	if got := ts.stored(t, "globex/a.txt"); got != "from globex" {
+		t.Fatalf("globex/a.txt holds %q", got)
+	}
+
+	check := func(srv *Server) {
+		t.Helper()
+		for namespace, data := range map[string]string{"acme": "from acme", "globex": "from globex"} {
+			meta, err := srv.GetFileMetadata(inNamespace(t.Context(), namespace), &fileuploadv1.GetFileMetadataRequest{Filename: "a.txt"})
+			if err != nil {
+				t.Fatalf("%s metadata: %v", namespace, err)
+			}
+			if meta.Filename != "a.txt" || meta.Sha256 != sha256Hex(data) {
+				t.Fatalf("%s sees %s with hash %s, want the hash of %q", namespace, meta.Filename, meta.Sha256, data)
+			}
+		}
+		// a namespace without a file of that name finds none
+		_, err := srv.GetFileMetadata(inNamespace(t.Context(), "initech"), &fileuploadv1.GetFileMetadataRequest{Filename: "a.txt"})
+		if connect.CodeOf(err) != connect.CodeNotFound {
+			t.Fatalf("initech metadata: %v, want not found", err)
+		}
+	}
+	check(ts.srv)
+
+	// the keys survive a restart, and the hash index finds the files by them
+	keys, err := openStorageKeys(filepath.Join(ts.dir, storageKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := &router{dir: ts.dir, keys: keys}
+	index, err := openHashIndex("", files, false, redactor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash, _ := index.hashOf("globex/a.txt"); hash != sha256Hex("from globex") {
+		t.Fatalf("rescanned hash of globex/a.txt is %q", hash)
+	}
+	ts.srv.files, ts.srv.index = files, index
+	check(ts.srv)
+}
+
+func TestStorageKeyNeverOverwritesAnotherKey(t *testing.T) {
+	// without {namespace} every namespace resolves the same key
+	ts := newKeyedServer(t, "shared/{filename}")
+	if _, err := ts.uploadIn(t, "acme", "a.txt", "from acme"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.uploadIn(t, "globex", "a.txt", "from globex"); connect.CodeOf(err) != connect.CodeAlreadyExists {
+		t.Fatalf("upload to a key holding another namespace's file: %v, want already exists", err)
+	}
+	if err := os.WriteFile(filepath.Join(ts.dir, "shared", "b.txt"), []byte("stored by hand"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.uploadIn(t, "acme", "b.txt", "upload"); connect.CodeOf(err) != connect.CodeAlreadyExists {
+		t.Fatalf("upload to a key holding an unkeyed file: %v, want already exists", err)
+	}
+	if got := ts.stored(t, "shared/a.txt"); got != "from acme" {
+		t.Fatalf("shared/a.txt holds %q", got)
+	}
+	if got := ts.stored(t, "shared/b.txt"); got != "stored by hand" {
+		t.Fatalf("shared/b.txt holds %q", got)
+	}
+	entries, _ := os.ReadDir(filepath.Join(ts.dir, partialDir, pendingDir))
+	if len(entries) != 0 {
+		t.Fatalf("%d refused uploads left pending", len(entries))
+	}
+
+	// the same namespace replaces its own file
+	if _, err := ts.uploadIn(t, "acme", "a.txt", "replaced"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.stored(t, "shared/a.txt"); got != "replaced" {
+		t.Fatalf("shared/a.txt holds %q", got)
+	}
+}
+
+func TestStorageKeyReuploadMoves(t *testing.T) {
+	ts := newKeyedServer(t, "{hash[:8]}/{filename}")
+	first, err := ts.uploadIn(t, "acme", "a.txt", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ts.uploadIn(t, "acme", "a.txt", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.StoragePath == second.StoragePath {
+		t.Fatalf("both stored at %s", first.StoragePath)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, filepath.FromSlash(first.StoragePath))); !os.IsNotExist(err) {
+		t.Fatalf("replaced content left at %s: %v", first.StoragePath, err)
+	}
+	if got := ts.stored(t, second.StoragePath); got != "second" {
+		t.Fatalf("%s holds %q", second.StoragePath, got)
+	}
+}
+
+func TestStorageKeyCopyAndRename(t *testing.T) {
+	ts := newKeyedServer(t, "{namespace}/{filename}")
+	acme := inNamespace(t.Context(), "acme")
+	if _, err := ts.uploadIn(t, "acme", "a.txt", "content"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.uploadIn(t, "globex", "b.txt", "globex's"); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := ts.srv.CopyFile(acme, &fileuploadv1.CopyFileRequest{From: "a.txt", To: "b.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.StoredFilename != "b.txt" || copied.StoragePath != "acme/b.txt" {
+		t.Fatalf("copied to %s at %s", copied.StoredFilename, copied.StoragePath)
+	}
+	renamed, err := ts.srv.RenameFile(acme, &fileuploadv1.RenameFileRequest{From: "a.txt", To: "c.txt"})
+	if err != nil || renamed.Filename != "c.txt" {
+		t.Fatalf("rename: %v, %v", renamed, err)
+	}
+	for path, want := range map[string]string{"acme/b.txt": "content", "acme/c.txt": "content", "globex/b.txt": "globex's"} {
+		if got := ts.stored(t, path); got != want {
+			t.Errorf("%s holds %q, want %q", path, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "acme", "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("renamed file left behind: %v", err)
+	}
+	// another namespace cannot reach acme's files
+	_, err = ts.srv.RenameFile(inNamespace(t.Context(), "globex"), &fileuploadv1.RenameFileRequest{From: "c.txt", To: "d.txt"})
+	if connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("globex renames acme's file: %v, want not found", err)
+	}
+}
+
+func TestOpenStorageKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), storageKeyFile)
+	k, err := openStorageKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.set("acme/a.txt", "acme/2026/a.txt")
+	k.set("default/b.txt", "b.txt")
+	k.set("default/b.txt", "")
+	if k, err = openStorageKeys(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := k.get("acme/a.txt"); got != "acme/2026/a.txt" {
+		t.Fatalf("saved key %q", got)
+	}
+	if got := k.nameOf("b.txt"); got != "" {
+		t.Fatalf("forgotten key still names %q", got)
+	}
+
+	for _, bad := range []string{
+		`not json`,
+		`{"files":{"acme/a.txt":"../a.txt"}}`,
+		`{"files":{"acme/a.txt":"x/b.txt"}}`,
+		`{"files":{"a.txt":"a.txt"}}`,
+		`{"files":{"../a.txt":"a.txt"}}`,
+		`{"files":{"acme/a.txt":"a.txt","globex/a.txt":"a.txt"}}`,
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := openStorageKeys(path); err == nil {
+			t.Errorf("openStorageKeys accepted %s", bad)
+		}
+	}
+}
+
+func TestRouterPendingKeyedNames(t *testing.T) {
+	r := &router{dir: "/srv", keys: &storageKeys{keys: map[string]string{}, names: map[string]string{}}}
+	r.keys.set("acme/a.txt", "acme/a.txt")
+	r.keys.set("acme/b.txt", "b.txt")
+
+	if got := r.path("acme/a.txt"); got != filepath.Join("/srv", "acme", "a.txt") {
+		t.Errorf("keyed name at %s", got)
+	}
+	if got := r.subdir("globex/a.txt"); got != filepath.Join(partialDir, pendingDir) {
+		t.Errorf("keyed name without a key in %q, want the pending directory", got)
+	}
+	if r.path("globex/a.txt") == r.path("acme/a.txt") || r.path("globex/a.txt") == r.path("a.txt") {
+		t.Error("a keyed name without a key shares a path with another file")
+	}
+	if r.path("b.txt") == r.path("acme/b.txt") {
+		t.Error("a plain name reaches the file at its key")
+	}
+	if got := r.nameAt("acme", "a.txt"); got != "acme/a.txt" {
+		t.Errorf("nameAt(acme, a.txt) = %q", got)
+	}
+	if got := r.nameAt("", "a.txt"); got != "a.txt" {
+		t.Errorf("nameAt(, a.txt) = %q", got)
+	}
+}
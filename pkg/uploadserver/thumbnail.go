@@ -0,0 +1,165 @@
+package uploadserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"connectrpc.com/connect"
+	"golang.org/x/image/draw"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// thumbnailDir holds the thumbnails of stored images inside the upload directory
+const thumbnailDir = ".thumbnails"
+
+const thumbnailQuality = 80
+
+// thumbnailer generates a JPEG thumbnail of every stored PNG, JPEG or GIF
+// image; a nil thumbnailer generates none
+type thumbnailer struct {
+	size      int // longest side of a thumbnail
+	maxSource int // longest side of an image worth decoding, 0 is unlimited
+}
+
+func newThumbnailer(size, maxSource int) *thumbnailer {
+	if size <= 0 {
+		return nil
+	}
+	return &thumbnailer{size: size, maxSource: maxSource}
+}
+
+// thumbnailPath is where the thumbnail of a stored file is kept, named after
+// a digest so any stored name fits
+func (s *Server) thumbnailPath(stored string) string {
+	sum := sha256.Sum256([]byte(stored))
+	return filepath.Join(s.dir, thumbnailDir, hex.EncodeToString(sum[:])+".jpg")
+}
+
+// makeThumbnail replaces the thumbnail of a file that was just stored. Files
+// that are not images, or larger than the source limit, get none. Failures
+// are logged: the upload itself succeeded.
+func (s *Server) makeThumbnail(stored string) {
+	if s.thumbs == nil {
+		return
+	}
+	path := s.thumbnailPath(stored)
+	os.Remove(path)
+	err := s.thumbs.write(s.files.path(stored), path)
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		// not an image
+	case err != nil:
+		log.Printf("Thumbnail of %s skipped: %v", s.redact.name(stored), s.redact.err(err))
+	default:
+		log.Printf("Thumbnail created: %s", s.redact.name(stored))
+	}
+}
+
+func (t *thumbnailer) write(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// check the dimensions before decoding so a huge image is never held in memory
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return err
+	}
+	if t.maxSource > 0 && max(cfg.Width, cfg.Height) > t.maxSource {
+		return fmt.Errorf("%dx%d image is over the %d pixel limit", cfg.Width, cfg.Height, t.maxSource)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(img, t.size), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// scaleDown shrinks img so its longest side is at most size with a
+// Catmull-Rom filter, on a white background since JPEG has no transparency
+func scaleDown(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w > size || h > size {
+		if w >= h {
+			dw, dh = size, max(1, h*size/w)
+		} else {
+			dw, dh = max(1, w*size/h), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+// hasThumbnail reports whether stored has a thumbnail of its current
+// content: one older than the file belongs to content since replaced
+func (s *Server) hasThumbnail(stored string, file os.FileInfo) bool {
+	if s.thumbs == nil {
+		return false
+	}
+	info, err := os.Stat(s.thumbnailPath(stored))
+	return err == nil && !info.ModTime().Before(file.ModTime())
+}
+
+// GetThumbnail returns the JPEG thumbnail of a stored image
+func (s *Server) GetThumbnail(
+	ctx context.Context, req *fileuploadv1.GetThumbnailRequest) (*fileuploadv1.GetThumbnailResponse, error) {
+
+	if s.thumbs == nil {
+		return nil, connect.NewError(connect.CodeUnimplemented, errors.New("thumbnails are not enabled on this server"))
+	}
+	filename := s.lookupName(ctx, sanitizeFilename(req.Filename))
+	info, err := os.Stat(s.files.path(filename))
+	if err != nil || !info.Mode().IsRegular() || !s.hasThumbnail(filename, info) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("no thumbnail for %s", baseName(filename)))
+	}
+	data, err := os.ReadFile(s.thumbnailPath(filename))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	setCacheable(ctx, fileMetadataMaxAge)
+	return &fileuploadv1.GetThumbnailResponse{
+		Data:        data,
+		ContentType: "image/jpeg",
+		Width:       int32(cfg.Width),
+		Height:      int32(cfg.Height),
+	}, nil
+}
@@ -0,0 +1,113 @@
+package uploadserver
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// testImage encodes a w x h image, red on the left half and blue on the right
+func testImage(t *testing.T, w, h int, encode func(*bytes.Buffer, image.Image) error) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			c := color.RGBA{R: 0xff, A: 0xff}
+			if x >= w/2 {
+				c = color.RGBA{B: 0xff, A: 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) }
+
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) }
+
+func (ts *testServer) thumbnail(t *testing.T, name string) (*fileuploadv1.GetThumbnailResponse, error) {
+	t.Helper()
+	return ts.client.GetThumbnail(t.Context(), &fileuploadv1.GetThumbnailRequest{Filename: name})
+}
+
+func TestThumbnails(t *testing.T) {
+	ts := newTestServer(t, &Server{thumbs: newThumbnailer(64, 1000)})
+
+	for _, tc := range []struct {
+		name         string
+		data         string
+		wantW, wantH int
+	}{
+		{"wide.png", testImage(t, 400, 100, encodePNG), 64, 16},
+		{"tall.jpg", testImage(t, 150, 300, encodeJPEG), 32, 64},
+		{"small.png", testImage(t, 20, 10, encodePNG), 20, 10},
+	} {
+		ts.uploadFile(t, tc.name, tc.data)
+		thumb, err := ts.thumbnail(t, tc.name)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(thumb.Data))
+		if err != nil {
+			t.Fatalf("%s: thumbnail is not a JPEG: %v", tc.name, err)
+		}
+		b := img.Bounds()
+		if b.Dx() != tc.wantW || b.Dy() != tc.wantH || thumb.Width != int32(tc.wantW) || thumb.Height != int32(tc.wantH) {
+			t.Errorf("%s: thumbnail %dx%d (reported %dx%d), want %dx%d",
+				tc.name, b.Dx(), b.Dy(), thumb.Width, thumb.Height, tc.wantW, tc.wantH)
+		}
+		if thumb.ContentType != "image/jpeg" {
+			t.Errorf("%s: content type %q", tc.name, thumb.ContentType)
+		}
+		// the filter keeps the colors of each half
+		if r, _, bl, _ := img.At(b.Dx()/8, b.Dy()/2).RGBA(); r < 0xc000 || bl > 0x4000 {
+			t.Errorf("%s: left of the thumbnail is not red", tc.name)
+		}
+		if r, _, bl, _ := img.At(b.Dx()*7/8, b.Dy()/2).RGBA(); bl < 0xc000 || r > 0x4000 {
+			t.Errorf("%s: right of the thumbnail is not blue", tc.name)
+		}
+		meta, err := ts.client.GetFileMetadata(t.Context(), &fileuploadv1.GetFileMetadataRequest{Filename: tc.name})
+		if err != nil || !meta.HasThumbnail {
+			t.Errorf("%s: metadata without a thumbnail: %v", tc.name, err)
+		}
+	}
+
+	// files that are not images, or over the source limit, get none
+	ts.uploadFile(t, "notes.txt", "not an image")
+	ts.uploadFile(t, "huge.png", testImage(t, 1200, 10, encodePNG))
+	for _, name := range []string{"notes.txt", "huge.png", "missing.png"} {
+		if _, err := ts.thumbnail(t, name); connect.CodeOf(err) != connect.CodeNotFound {
+			t.Errorf("thumbnail of %s: %v, want not found", name, err)
+		}
+	}
+
+	// replacing an image with other content drops its thumbnail
+	ts.uploadFile(t, "wide.png", "now text")
+	if _, err := ts.thumbnail(t, "wide.png"); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Errorf("thumbnail of a replaced image: %v, want not found", err)
+	}
+}
+
+func TestThumbnailsDisabled(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	ts.uploadFile(t, "a.png", testImage(t, 10, 10, encodePNG))
+	if _, err := ts.thumbnail(t, "a.png"); connect.CodeOf(err) != connect.CodeUnimplemented {
+		t.Fatalf("thumbnail without -thumbnails: %v, want unimplemented", err)
+	}
+	meta, err := ts.client.GetFileMetadata(t.Context(), &fileuploadv1.GetFileMetadataRequest{Filename: "a.png"})
+	if err != nil || meta.HasThumbnail {
+		t.Fatalf("metadata reports a thumbnail when disabled: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package uploadserver
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+func TestMaxTitleLength(t *testing.T) {
+	ts := newTestServer(t, &Server{rules: metadataRules{maxTitleLength: 16}})
+	long := strings.Repeat("t", 17)
+
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "unary.txt", Title: long, Data: []byte("data")})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Errorf("UploadFile with a long title: %v, want invalid_argument", err)
+	}
+	_, err = ts.sendUpload(t,
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: "streamed.txt", Title: long}}},
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("data")}},
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("data")}},
+	)
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Errorf("Upload with a long title: %v, want invalid_argument", err)
+	}
+	_, err = ts.client.BeginArchive(t.Context(), &fileuploadv1.BeginArchiveRequest{Filename: "a.tar", Title: long})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Errorf("BeginArchive with a long title: %v, want invalid_argument", err)
+	}
+
+	// the title field may follow the file, which is then removed again
+	body, ct := multipartBody(t, "form.txt", "data", map[string]string{"title": long})
+	req := ts.newRequest(t, http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", ct)
+	if resp, _ := ts.do(t, req); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("multipart with a long title: status %d, want 400", resp.StatusCode)
+	}
+
+	req = ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", "4")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("tus.txt"))+
+		",title "+base64.StdEncoding.EncodeToString([]byte(long)))
+	if resp, _ := ts.do(t, req); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("tus with a long title: status %d, want 400", resp.StatusCode)
+	}
+
+	for _, name := range []string{"unary.txt", "streamed.txt", "form.txt"} {
+		if _, err := os.Stat(filepath.Join(ts.dir, name)); !os.IsNotExist(err) {
+			t.Errorf("upload with a long title stored %s: %v", name, err)
+		}
+	}
+	if sessions, _ := ts.srv.partialSessions(); len(sessions) != 0 {
+		t.Errorf("sessions with a long title were started: %+v", sessions)
+	}
+
+	// a title at the limit is accepted
+	resp, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "ok.txt", Title: long[:16], Data: []byte("data")})
+	if err != nil || resp.StoredFilename != "ok.txt" {
+		t.Fatalf("UploadFile with a title at the limit: %v", err)
+	}
+}
+
+func TestTitleControlCharactersNotLogged(t *testing.T) {
+	logged := captureLog(t)
+	ts := newTestServer(t, &Server{})
+	forged := "report\n2026/01/01 00:00:00 Upload started: forged.txt\r\x1b[2J"
+	_, err := ts.client.UploadFile(t.Context(), &fileuploadv1.UploadFileRequest{Filename: "a.txt", Title: forged, Data: []byte("data")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := logged.String()
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "2026/01/01") {
+			t.Fatalf("a title forged a log line: %q", out)
+		}
+	}
+	if !strings.Contains(out, "title: report2026/01/01 00:00:00 Upload started: forged.txt[2J") {
+		t.Fatalf("title not logged without its control characters: %q", out)
+	}
+}
@@ -0,0 +1,97 @@
+package uploadserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/otelconnect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans started by the upload handlers
+const tracerName = "github.com/lao-tseu-is-alive/go-grpc-file-upload/pkg/uploadserver"
+
+// traceContext reads the W3C traceparent header, so the spans of a request
+// join the trace of the client that sent it
+var traceContext = propagation.TraceContext{}
+
+// newTracingInterceptor returns the otelconnect interceptor starting a server
+// span for every RPC, named after its procedure
+func newTracingInterceptor(provider trace.TracerProvider) (connect.Interceptor, error) {
+	return otelconnect.NewInterceptor(
+		otelconnect.WithTracerProvider(provider),
+		otelconnect.WithPropagator(traceContext),
+		otelconnect.WithTrustRemote(),
+		otelconnect.WithoutMetrics(),
+	)
+}
+
+// traced starts a server span named after the method and route around the
+// plain-HTTP upload handler next; a nil provider leaves next untraced
+func traced(provider trace.TracerProvider, next http.HandlerFunc) http.HandlerFunc {
+	if provider == nil {
+		return next
+	}
+	tracer := provider.Tracer(tracerName)
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.Pattern
+		if !strings.Contains(name, " ") {
+			name = r.Method + " " + name
+		}
+		ctx := traceContext.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("network.peer.address", r.RemoteAddr),
+			))
+		defer span.End()
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// startPhase starts the child span of one phase of an upload, such as writing
+// its content or checking its hash. It records nothing outside a traced request.
+func startPhase(ctx context.Context, phase string) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, "upload."+phase)
+}
+
+// endPhase ends the span of a phase, marking it failed with err
+func endPhase(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// hashStored hashes the file at path within a hash phase span
+func hashStored(ctx context.Context, path string) (hash string, err error) {
+	_, span := startPhase(ctx, "hash")
+	defer func() { endPhase(span, err) }()
+	return hashFile(path)
+}
+
+// traceUpload adds the outcome of an upload to the span of its request
+func traceUpload(ctx context.Context, filename string, size int64, hashOk bool, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = connect.CodeOf(err).String()
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(
+		attribute.String("upload.filename", filename),
+		attribute.Int64("upload.size", size),
+		attribute.Bool("upload.hash_ok", hashOk),
+		attribute.String("upload.result", result),
+	)
+}
@@ -0,0 +1,135 @@
+package uploadserver
+
+import (
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracedServer returns a test server exporting the spans of its RPCs and
+// plain-HTTP uploads to the returned in-memory exporter as soon as they end
+func newTracedServer(t *testing.T, srv *Server) (*testServer, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(t.Context()) })
+	interceptor, err := newTracingInterceptor(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, srv, connect.WithInterceptors(interceptor))
+	mux := http.NewServeMux()
+	mux.Handle("/", ts.Config.Handler)
+	mux.HandleFunc("PUT /files/{name}", traced(provider, srv.handlePutFile))
+	ts.Config.Handler = withCaller(mux)
+	return ts, exporter
+}
+
+// spanNamed returns the exported span called name
+func spanNamed(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span %s among %d exported", name, len(exporter.GetSpans()))
+	return tracetest.SpanStub{}
+}
+
+// attr returns the value of the attribute key of span
+func attr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// childrenOf returns the names of the spans whose parent is span
+func childrenOf(exporter *tracetest.InMemoryExporter, span tracetest.SpanStub) map[string]bool {
+	names := make(map[string]bool)
+	for _, s := range exporter.GetSpans() {
+		if s.Parent.SpanID() == span.SpanContext.SpanID() {
+			names[s.Name] = true
+		}
+	}
+	return names
+}
+
+func TestTracingUploadSpans(t *testing.T) {
+	ts, exporter := newTracedServer(t, &Server{})
+	ts.uploadFile(t, "traced.txt", "hello world")
+
+	rpc := spanNamed(t, exporter, "fileupload.v1.FileUploadService/UploadFile")
+	if rpc.SpanKind != trace.SpanKindServer {
+		t.Errorf("span kind %v, want server", rpc.SpanKind)
+	}
+	for key, want := range map[attribute.Key]attribute.Value{
+		"rpc.system":      attribute.StringValue("connect_rpc"),
+		"rpc.method":      attribute.StringValue("UploadFile"),
+		"upload.filename": attribute.StringValue("traced.txt"),
+		"upload.size":     attribute.Int64Value(11),
+		"upload.hash_ok":  attribute.BoolValue(true),
+		"upload.result":   attribute.StringValue("ok"),
+	} {
+		if got := attr(rpc, key); got != want {
+			t.Errorf("attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+	children := childrenOf(exporter, rpc)
+	if !children["upload.hash"] || !children["upload.write"] {
+		t.Fatalf("child spans %v, want upload.hash and upload.write", children)
+	}
+}
+
+func TestTracingStreamAndFailedUpload(t *testing.T) {
+	ts, exporter := newTracedServer(t, &Server{})
+	if _, err := ts.streamUpload(t.Context(), "stream.bin", []byte("streamed content"), 4); err != nil {
+		t.Fatal(err)
+	}
+	rpc := spanNamed(t, exporter, "fileupload.v1.FileUploadService/Upload")
+	if got := attr(rpc, "upload.size"); got != attribute.Int64Value(16) {
+		t.Errorf("upload.size = %v, want 16", got.Emit())
+	}
+	if children := childrenOf(exporter, rpc); !children["upload.hash"] || !children["upload.write"] {
+		t.Fatalf("child spans %v, want upload.hash and upload.write", children)
+	}
+
+	// a corrupt upload fails its span
+	exporter.Reset()
+	if _, err := declaredUpload(t.Context(), ts.client, "bad.bin", sha256Hex("other"), []byte("corrupt")); err == nil {
+		t.Fatal("an upload with the wrong hash succeeded")
+	}
+	rpc = spanNamed(t, exporter, "fileupload.v1.FileUploadService/Upload")
+	if rpc.Status.Code != codes.Error || attr(rpc, "upload.result") != attribute.StringValue("data_loss") {
+		t.Fatalf("span status %v, result %v", rpc.Status, attr(rpc, "upload.result").Emit())
+	}
+}
+
+func TestTracingHTTPUploadJoinsClientTrace(t *testing.T) {
+	ts, exporter := newTracedServer(t, &Server{})
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := ts.newRequest(t, http.MethodPut, "/files/joined.txt", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	if resp, _ := ts.do(t, req); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	put := spanNamed(t, exporter, "PUT /files/{name}")
+	if got := put.SpanContext.TraceID().String(); got != traceID {
+		t.Fatalf("trace %s, want the client's %s", got, traceID)
+	}
+	if got := attr(put, "upload.filename"); got != attribute.StringValue("joined.txt") {
+		t.Errorf("upload.filename = %v", got.Emit())
+	}
+	if children := childrenOf(exporter, put); !children["upload.write"] {
+		t.Fatalf("child spans %v, want upload.write", children)
+	}
+}
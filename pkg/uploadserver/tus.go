@@ -0,0 +1,396 @@
+package uploadserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// tus.io protocol support (core protocol plus the creation extension)
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation"
+	tusBasePath   = "/tus/"
+)
+
+// tusDir holds, inside partialDir, every tus session as {id}.json with the
+// session and {id}.bin with the bytes received so far. A sanitized filename
+// never contains a slash, so a ranged PUT partial can never land inside it.
+const tusDir = ".tus"
+
+// errSessionFilename rejects a session file whose filename would not survive
+// sanitizeFilename: the server did not write it, and it must not decide
+// where a file is stored
+var errSessionFilename = errors.New("session file holds an unsafe filename")
+
+// checkSessionFilename sanitizes again a filename loaded from a session file,
+// refusing the session when that changes it
+func checkSessionFilename(name string) error {
+	if name == "" || sanitizeFilename(name) != name {
+		return errSessionFilename
+	}
+	return nil
+}
+
+// tusSession is persisted next to its partial file so uploads survive restarts
+type tusSession struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Title    string `json:"title"`
+	Length   int64  `json:"length"`
+}
+
+// tusStore serializes PATCH requests per session
+type tusStore struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (t *tusStore) lock(id string) func() {
+	t.mu.Lock()
+	if t.locks == nil {
+		t.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := t.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		t.locks[id] = l
+	}
+	t.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+func (t *tusStore) forget(id string) {
+	t.mu.Lock()
+	delete(t.locks, id)
+	t.mu.Unlock()
+}
+
+func (s *Server) tusPaths(id string) (info, data string) {
+	base := filepath.Join(s.dir, partialDir, tusDir, id)
+	return base + ".json", base + ".bin"
+}
+
+func (s *Server) loadTusSession(id string) (*tusSession, int64, error) {
+	// ids are generated as hex, anything else cannot name a session
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return nil, 0, os.ErrNotExist
+	}
+	infoPath, dataPath := s.tusPaths(id)
+	b, err := os.ReadFile(infoPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	var sess tusSession
+	if err := json.Unmarshal(b, &sess); err != nil {
+		return nil, 0, err
+	}
+	if err := checkSessionFilename(sess.Filename); err != nil || sess.ID != id {
+		log.Printf("tus upload %s: refusing a session file not written by the server", id)
+		return nil, 0, errSessionFilename
+	}
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &sess, info.Size(), nil
+}
+
+// parseTusMetadata decodes the Upload-Metadata header: comma separated
+// "key base64value" pairs
+func parseTusMetadata(h string) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(h, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %q", key)
+		}
+		meta[key] = string(value)
+	}
+	return meta, nil
+}
+
+// handleTus dispatches the tus protocol requests on /tus/ and /tus/{id}
+func (s *Server) handleTus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	id := strings.TrimPrefix(r.URL.Path, tusBasePath)
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		if s.maxFileSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(s.maxFileSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		writeProblem(w, http.StatusPreconditionFailed, "", "unsupported Tus-Resumable version")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		s.tusCreate(w, r)
+	case r.Method == http.MethodHead && id != "":
+		s.tusHead(w, id)
+	case r.Method == http.MethodPatch && id != "":
+		s.tusPatch(w, r, id)
+	default:
+		writeProblem(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+}
+
+// tusCreate starts a new upload of Upload-Length bytes and returns its Location
+func (s *Server) tusCreate(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		writeProblem(w, http.StatusBadRequest, "", "missing or invalid Upload-Length")
+		return
+	}
+	meta, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	sess, err := s.createTusSession(r.Context(), meta["filename"], meta["title"], length)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	// an empty upload is complete as soon as it is created
+	if length == 0 {
+		resp, err := s.tusFinish(r.Context(), "tus", sess)
+		if err != nil {
+			s.dropTusSession(sess.ID)
+			writeHTTPError(w, err)
+			return
+		}
+		w.Header().Set("Upload-Stored-Filename", resp.StoredFilename)
+	}
+	w.Header().Set("Location", tusBasePath+sess.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// createTusSession starts an upload of length bytes, written by tus PATCH
+// requests or UploadChunk
+func (s *Server) createTusSession(ctx context.Context, filename, title string, length int64) (*tusSession, error) {
+	if err := s.checkUploadable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkFileSize(length); err != nil {
+		return nil, err
+	}
+	filename = sanitizeFilename(filename)
+	if err := s.checkExtension(ctx, filename); err != nil {
+		return nil, err
+	}
+	if err := s.checkTitle(title); err != nil {
+		return nil, err
+	}
+	if err := s.checkSessionCapacity(); err != nil {
+		return nil, err
+	}
+
+	var raw [16]byte
+	rand.Read(raw[:])
+	sess := &tusSession{
+		ID:       hex.EncodeToString(raw[:]),
+		Filename: filename,
+		Title:    title,
+		Length:   length,
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.dir, partialDir, tusDir), 0755); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	infoPath, dataPath := s.tusPaths(sess.ID)
+	b, _ := json.Marshal(sess)
+	if err := os.WriteFile(infoPath, b, 0644); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if err := os.WriteFile(dataPath, nil, 0644); err != nil {
+		os.Remove(infoPath)
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	log.Printf("tus upload created: %s for %s (%d bytes, title: %s)", sess.ID, s.redact.name(sess.Filename), length, s.redact.title(sess.Title))
+	return sess, nil
+}
+
+// dropTusSession deletes the files of the session id
+func (s *Server) dropTusSession(id string) {
+	infoPath, dataPath := s.tusPaths(id)
+	os.Remove(infoPath)
+	os.Remove(dataPath)
+	s.tus.forget(id)
+}
+
+// tusHead reports how many bytes of the upload the server holds
+func (s *Server) tusHead(w http.ResponseWriter, id string) {
+	sess, offset, err := s.loadTusSession(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(sess.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// tusPatch appends the body at Upload-Offset, which must equal the current offset
+func (s *Server) tusPatch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		writeProblem(w, http.StatusUnsupportedMediaType, "", "Content-Type must be application/offset+octet-stream")
+		return
+	}
+	reqOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "", "missing or invalid Upload-Offset")
+		return
+	}
+	offset, resp, err := s.appendTusSession(r.Context(), "tus", id, reqOffset, r.Body)
+	switch {
+	case errors.Is(err, errTusNotFound):
+		writeProblem(w, http.StatusNotFound, "", "upload not found")
+		return
+	case errors.Is(err, errTusOffset):
+		writeProblem(w, http.StatusConflict, "", fmt.Sprintf("Upload-Offset %d does not match current offset %d", reqOffset, offset))
+		return
+	case err != nil:
+		writeHTTPError(w, err)
+		return
+	}
+	if resp != nil {
+		w.Header().Set("Upload-Stored-Filename", resp.StoredFilename)
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var (
+	errTusNotFound = errors.New("upload not found")
+	errTusOffset   = errors.New("offset does not match the current offset")
+)
+
+// appendTusSession writes body to the session id at offset, which must be its
+// current offset, and stores the file once it is complete, reporting it for
+// rpc. It returns the new offset, or the current one with errTusOffset, and
+// the stored file once complete.
+func (s *Server) appendTusSession(ctx context.Context, rpc, id string, offset int64, body io.Reader) (int64, *fileuploadv1.UploadResponse, error) {
+	if err := s.checkUploadable(); err != nil {
+		return 0, nil, err
+	}
+
+	unlock := s.tus.lock(id)
+	defer unlock()
+
+	sess, current, err := s.loadTusSession(id)
+	if err != nil {
+		return 0, nil, connect.NewError(connect.CodeNotFound, errTusNotFound)
+	}
+	if offset != current {
+		return current, nil, connect.NewError(connect.CodeFailedPrecondition,
+			fmt.Errorf("%w: %d, the server holds %d bytes", errTusOffset, offset, current))
+	}
+
+	release, err := s.openFiles.acquire(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer release()
+	_, dataPath := s.tusPaths(id)
+	f, err := os.OpenFile(dataPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, nil, connect.NewError(connect.CodeInternal, err)
+	}
+	remaining := sess.Length - offset
+	n, copyErr := io.Copy(f, io.LimitReader(body, remaining))
+	syncErr := s.syncChunk(f)
+	closeErr := f.Close()
+	offset += n
+	// keep what was written even on error, the client resumes from the offset
+	if err := errors.Join(copyErr, closeErr); err != nil {
+		log.Printf("tus upload %s interrupted at offset %d: %v", id, offset, s.redact.err(err))
+		if errors.Is(err, syscall.ENOSPC) {
+			// the client resumes from the session's offset once space is freed
+			return 0, nil, writeError(err)
+		}
+	}
+	if syncErr != nil {
+		// the reported offset must not include data that may not be on disk
+		return 0, nil, writeError(syncErr)
+	}
+
+	if offset < sess.Length {
+		return offset, nil, nil
+	}
+	resp, err := s.tusFinish(ctx, rpc, sess)
+	if err != nil {
+		return 0, nil, err
+	}
+	return offset, resp, nil
+}
+
+// tusFinish moves a completed upload into place, removes its session and
+// returns where it is stored, reporting the upload for rpc. A rejected
+// duplicate ends the session too, its content is already deleted.
+func (s *Server) tusFinish(ctx context.Context, rpc string, sess *tusSession) (*fileuploadv1.UploadResponse, error) {
+	infoPath, dataPath := s.tusPaths(sess.ID)
+	stored := s.storedName(ctx, sess.Filename)
+	err := s.syncPath(dataPath)
+	var hash string
+	if err == nil {
+		hash, err = hashStored(ctx, dataPath)
+	}
+	if err != nil {
+		err = writeError(err)
+	} else {
+		err = s.placeStored(dataPath, stored, hash)
+	}
+	if err == nil || connect.CodeOf(err) == connect.CodeAlreadyExists {
+		os.Remove(infoPath)
+		s.tus.forget(sess.ID)
+	}
+	if err == nil {
+		log.Printf("tus upload complete: %s (%d bytes)", s.redact.name(stored), sess.Length)
+		err = s.uploaded(ctx, rpc, sess.Filename, stored, sess.Length, hash)
+	}
+	s.recordUpload(ctx, rpc, sess.Filename, baseName(stored), sess.Length, false, err)
+	if err != nil {
+		return nil, err
+	}
+	return &fileuploadv1.UploadResponse{
+		Message:        "ok",
+		Size:           sess.Length,
+		HashStatus:     fileuploadv1.HashStatus_HASH_STATUS_NOT_PROVIDED,
+		Sha256:         hash,
+		StoredFilename: baseName(stored),
+		StoragePath:    s.files.relPath(stored),
+	}, nil
+}
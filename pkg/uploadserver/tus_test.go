@@ -0,0 +1,183 @@
+package uploadserver
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tusRequest is a tus protocol request with the Tus-Resumable header
+func (ts *testServer) tusRequest(t *testing.T, method, path, body string) *http.Request {
+	t.Helper()
+	req := ts.newRequest(t, method, path, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", tusVersion)
+	return req
+}
+
+// tusCreate starts a tus upload of length bytes for filename and returns its URL path
+func (ts *testServer) tusCreate(t *testing.T, filename string, length int) string {
+	t.Helper()
+	req := ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", strconv.Itoa(length))
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(filename)))
+	resp, _ := ts.do(t, req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d, want 201", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	if !strings.HasPrefix(location, tusBasePath) {
+		t.Fatalf("create: Location %q", location)
+	}
+	return location
+}
+
+// tusPatch sends data at offset and returns the response
+func (ts *testServer) tusPatch(t *testing.T, location string, offset int, data string) *http.Response {
+	t.Helper()
+	req := ts.tusRequest(t, http.MethodPatch, location, data)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	resp, _ := ts.do(t, req)
+	return resp
+}
+
+func TestTusUpload(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+
+	resp, _ := ts.do(t, ts.newRequest(t, http.MethodOptions, tusBasePath, nil))
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Tus-Version") != tusVersion ||
+		resp.Header.Get("Tus-Extension") != tusExtensions {
+		t.Fatalf("OPTIONS: status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+
+	// every other request must declare the protocol version
+	resp, _ = ts.do(t, ts.newRequest(t, http.MethodPost, tusBasePath, nil))
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("POST without Tus-Resumable: status %d, want 412", resp.StatusCode)
+	}
+
+	location := ts.tusCreate(t, "notes.txt", 11)
+	head := func() *http.Response {
+		resp, _ := ts.do(t, ts.tusRequest(t, http.MethodHead, location, ""))
+		return resp
+	}
+	if resp := head(); resp.StatusCode != http.StatusOK || resp.Header.Get("Upload-Offset") != "0" ||
+		resp.Header.Get("Upload-Length") != "11" {
+		t.Fatalf("HEAD after create: status %d, offset %q, length %q", resp.StatusCode,
+			resp.Header.Get("Upload-Offset"), resp.Header.Get("Upload-Length"))
+	}
+
+	if resp := ts.tusPatch(t, location, 0, "hello "); resp.StatusCode != http.StatusNoContent || resp.Header.Get("Upload-Offset") != "6" {
+		t.Fatalf("first PATCH: status %d, offset %q", resp.StatusCode, resp.Header.Get("Upload-Offset"))
+	}
+	if resp := ts.tusPatch(t, location, 0, "hello "); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("PATCH at a stale offset: status %d, want 409", resp.StatusCode)
+	}
+	req := ts.tusRequest(t, http.MethodPatch, location, "world")
+	req.Header.Set("Upload-Offset", "6")
+	if resp, _ := ts.do(t, req); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("PATCH without the offset content type: status %d, want 415", resp.StatusCode)
+	}
+	if resp := head(); resp.Header.Get("Upload-Offset") != "6" {
+		t.Fatalf("HEAD after first PATCH: offset %q, want 6", resp.Header.Get("Upload-Offset"))
+	}
+
+	if resp := ts.tusPatch(t, location, 6, "world"); resp.StatusCode != http.StatusNoContent || resp.Header.Get("Upload-Offset") != "11" {
+		t.Fatalf("last PATCH: status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+	if got := ts.stored(t, "notes.txt"); got != "hello world" {
+		t.Fatalf("stored %q, want %q", got, "hello world")
+	}
+	// a completed session is gone
+	if resp := head(); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("HEAD after completion: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestTusEmptyUploadCompletesOnCreate(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	ts.tusCreate(t, "empty.txt", 0)
+	if got := ts.stored(t, "empty.txt"); got != "" {
+		t.Fatalf("stored %q, want an empty file", got)
+	}
+}
+
+func TestTusEmptyUploadReportsFailedFinish(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	// a directory in the way makes moving the empty file into place fail
+	if err := os.Mkdir(filepath.Join(ts.dir, "taken"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ts.dir, "taken", "f"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	req := ts.tusRequest(t, http.MethodPost, tusBasePath, "")
+	req.Header.Set("Upload-Length", "0")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("taken")))
+	resp, _ := ts.do(t, req)
+	if resp.StatusCode != http.StatusInternalServerError || resp.Header.Get("Location") != "" {
+		t.Fatalf("create: status %d, Location %q, want 500 without a session", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if left, _ := os.ReadDir(filepath.Join(ts.dir, partialDir, tusDir)); len(left) != 0 {
+		t.Fatalf("the failed session was left behind: %v", left)
+	}
+}
+
+func TestTusSessionOutOfReachOfRangedPut(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	location := ts.tusCreate(t, "report.txt", 4)
+	id := strings.TrimPrefix(location, tusBasePath)
+
+	// a ranged PUT writes partial files named by the client next to where
+	// sessions are kept
+	evil := `{"id":"` + id + `","filename":"../../escaped.txt","length":4}`
+	for _, name := range []string{"tus-" + id + ".json", id + ".json", ".tus", "..%2F.tus%2F" + id + ".json"} {
+		req := ts.newRequest(t, http.MethodPut, "/files/"+name, strings.NewReader(evil))
+		req.Header.Set("Content-Range", "bytes 0-"+strconv.Itoa(len(evil)-1)+"/*")
+		ts.do(t, req)
+	}
+
+	if resp := ts.tusPatch(t, location, 0, "data"); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PATCH: status %d, want 204", resp.StatusCode)
+	}
+	if got := ts.stored(t, "report.txt"); got != "data" {
+		t.Fatalf("stored %q, want %q", got, "data")
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "..", "..", "escaped.txt")); !os.IsNotExist(err) {
+		t.Fatalf("a file was written outside the upload directory: %v", err)
+	}
+}
+
+func TestTusRefusesUnsafeSessionFilename(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	location := ts.tusCreate(t, "report.txt", 4)
+	id := strings.TrimPrefix(location, tusBasePath)
+
+	// however it got there, a session naming a path must not be stored
+	infoPath := filepath.Join(ts.dir, partialDir, tusDir, id+".json")
+	evil := `{"id":"` + id + `","filename":"../escaped.txt","length":4}`
+	if err := os.WriteFile(infoPath, []byte(evil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if resp := ts.tusPatch(t, location, 0, "data"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("PATCH of a tampered session: status %d, want 404", resp.StatusCode)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "..", "escaped.txt")); !os.IsNotExist(err) {
+		t.Fatalf("a file was written outside the upload directory: %v", err)
+	}
+}
+
+func TestCheckSessionFilename(t *testing.T) {
+	for _, name := range []string{"", "../x", "a/b", `a\b`, "..", ".hidden", "C:x", "a\x00b", strings.Repeat("a", 300)} {
+		if checkSessionFilename(name) == nil {
+			t.Errorf("checkSessionFilename(%q) accepted an unsafe name", name)
+		}
+	}
+	if err := checkSessionFilename("report 2024.pdf"); err != nil {
+		t.Errorf("checkSessionFilename refused a sanitized name: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// UploadBidi runs the Upload protocol and acknowledges written chunks with
+// the offset received so far, then sends the UploadResponse as the last
+// message. An ack means the chunk went through the same write (and per-chunk
+// sync) as with Upload, so a client can bound the bytes it has in flight.
+// A chunk failing its CRC is NACKed instead of failing the upload, see
+// receiveUpload.
+func (s *Server) UploadBidi(
+	ctx context.Context, stream *connect.BidiStream[fileuploadv1.UploadRequest, fileuploadv1.UploadBidiResponse]) error {
+
+	ack := func(msg *fileuploadv1.UploadAck) error {
+		return stream.Send(&fileuploadv1.UploadBidiResponse{
+			Payload: &fileuploadv1.UploadBidiResponse_Ack{Ack: msg},
+		})
+	}
+	resp, err := s.receiveUpload(ctx, "UploadBidi", &bidiUploadStream{stream: stream}, ack)
+	if err != nil {
+		return err
+	}
+	return stream.Send(&fileuploadv1.UploadBidiResponse{
+		Payload: &fileuploadv1.UploadBidiResponse_Result{Result: resp},
+	})
+}
+
+// writtenAck acknowledges the first chunks, offset bytes in all
+func writtenAck(offset, chunks int64) *fileuploadv1.UploadAck {
+	return &fileuploadv1.UploadAck{
+		Offset:     offset,
+		ChunkIndex: uint64(chunks - 1),
+		Status:     fileuploadv1.ChunkStatus_CHUNK_STATUS_WRITTEN,
+	}
+}
+
+// bidiUploadStream gives the request side of a bidi stream the shape of a
+// client stream
+type bidiUploadStream struct {
+	stream *connect.BidiStream[fileuploadv1.UploadRequest, fileuploadv1.UploadBidiResponse]
+	msg    *fileuploadv1.UploadRequest
+	err    error
+}
+
+func (b *bidiUploadStream) Receive() bool {
+	msg, err := b.stream.Receive()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			b.err = err
+		}
+		return false
+	}
+	b.msg = msg
+	return true
+}
+
+func (b *bidiUploadStream) Msg() *fileuploadv1.UploadRequest { return b.msg }
+
+func (b *bidiUploadStream) Err() error { return b.err }
+
+func (b *bidiUploadStream) Peer() connect.Peer { return b.stream.Peer() }
@@ -0,0 +1,263 @@
+package uploadserver
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// bidiUpload sends name in chunks of chunkSize over UploadBidi, asking for an
+// ack every ackEvery chunks, and returns the acked offsets with the result.
+// Before each chunk past an ack it calls onAck with that offset.
+func (ts *testServer) bidiUpload(t *testing.T, name, data, hash string, chunkSize int, ackEvery uint32, onAck func(offset int64)) ([]int64, *fileuploadv1.UploadResponse, error) {
+	t.Helper()
+	stream, err := ts.client.UploadBidi(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.CloseResponse()
+	send := func(req *fileuploadv1.UploadRequest) error {
+		if err := stream.Send(req); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	}
+	var acks []int64
+	// receive reads one message, an ack or the result
+	receive := func() (*fileuploadv1.UploadResponse, error) {
+		msg, err := stream.Receive()
+		if err != nil {
+			return nil, err
+		}
+		if ack := msg.GetAck(); ack != nil {
+			acks = append(acks, ack.Offset)
+			if onAck != nil {
+				onAck(ack.Offset)
+			}
+			return nil, nil
+		}
+		return msg.GetResult(), nil
+	}
+
+	if err := send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: name, AckEvery: ackEvery},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	every := max(1, int(ackEvery))
+	for i, n := 0, 0; i < len(data); i += chunkSize {
+		if err := send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{
+			Chunk: []byte(data[i:min(i+chunkSize, len(data))]),
+		}}); err != nil {
+			t.Fatal(err)
+		}
+		// wait for the ack each ackEvery chunks, so the server holds no more
+		if n++; n%every == 0 {
+			if _, err := receive(); err != nil {
+				return acks, nil, err
+			}
+		}
+	}
+	if err := send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: hash}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseRequest(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		resp, err := receive()
+		if err != nil || resp != nil {
+			return acks, resp, err
+		}
+	}
+}
+
+func TestUploadBidiAcks(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	const data = "0123456789abcdefghij"
+	for _, tc := range []struct {
+		every uint32
+		want  []int64
+	}{
+		{0, []int64{4, 8, 12, 16, 20}},
+		{2, []int64{8, 16}},
+		{5, []int64{20}},
+	} {
+		acks, resp, err := ts.bidiUpload(t, "bidi.txt", data, sha256Hex(data), 4, tc.every, nil)
+		if err != nil {
+			t.Fatalf("ack every %d: %v", tc.every, err)
+		}
+		if !slices.Equal(acks, tc.want) {
+			t.Errorf("ack every %d: acks %v, want %v", tc.every, acks, tc.want)
+		}
+		if !resp.HashOk || resp.Size != int64(len(data)) || resp.StoredFilename != "bidi.txt" {
+			t.Errorf("ack every %d: result %v", tc.every, resp)
+		}
+		if got := ts.stored(t, "bidi.txt"); got != data {
+			t.Fatalf("stored %q", got)
+		}
+	}
+}
+
+func TestUploadBidiAcksWrittenBytes(t *testing.T) {
+	// chunks far smaller than the write buffer, which an ack must not hide
+	ts := newTestServer(t, &Server{})
+	const data = "0123456789abcdefghij"
+	onAck := func(offset int64) {
+		b, err := os.ReadFile(filepath.Join(ts.dir, "bidi.txt"))
+		if err != nil || string(b) != data[:offset] {
+			t.Errorf("file holds %q, %v when %d bytes are acked", b, err, offset)
+		}
+	}
+	if _, _, err := ts.bidiUpload(t, "bidi.txt", data, sha256Hex(data), 4, 1, onAck); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadBidiFailure(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	acks, _, err := ts.bidiUpload(t, "bad.txt", "0123456789", sha256Hex("something else"), 4, 1, nil)
+	if connect.CodeOf(err) != connect.CodeDataLoss {
+		t.Fatalf("wrong hash: %v, want data loss", err)
+	}
+	if !slices.Equal(acks, []int64{4, 8, 10}) {
+		t.Errorf("acks %v before the failure", acks)
+	}
+	if _, err := os.Stat(filepath.Join(ts.dir, "bad.txt")); !os.IsNotExist(err) {
+		t.Fatalf("a failed upload left its file: %v", err)
+	}
+}
+
+// crcChunk is a chunk request at index with the CRC32C of crcOf
+func crcChunk(data, crcOf string, index uint64) *fileuploadv1.UploadRequest {
+	return &fileuploadv1.UploadRequest{
+		Payload:     &fileuploadv1.UploadRequest_Chunk{Chunk: []byte(data)},
+		ChunkCrc32C: proto.Uint32(crc32.Checksum([]byte(crcOf), crc32c)),
+		ChunkIndex:  proto.Uint64(index),
+	}
+}
+
+func TestUploadBidiNacksCorruptChunk(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	stream, err := ts.client.UploadBidi(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.CloseResponse()
+	send := func(req *fileuploadv1.UploadRequest) {
+		t.Helper()
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receiveAck := func() *fileuploadv1.UploadAck {
+		t.Helper()
+		msg, err := stream.Receive()
+		if err != nil || msg.GetAck() == nil {
+			t.Fatalf("received %v, %v; want an ack", msg, err)
+		}
+		return msg.GetAck()
+	}
+	commit := &fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("abcdefghijkl")}}
+
+	send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: "crc.txt", AckEvery: 1},
+	}})
+	send(crcChunk("abcd", "abcd", 0))
+	if ack := receiveAck(); ack.Status != fileuploadv1.ChunkStatus_CHUNK_STATUS_WRITTEN || ack.Offset != 4 || ack.ChunkIndex != 0 {
+		t.Fatalf("ack of chunk 0: %v", ack)
+	}
+	send(crcChunk("efgX", "efgh", 1))
+	if ack := receiveAck(); ack.Status != fileuploadv1.ChunkStatus_CHUNK_STATUS_CORRUPT || ack.Offset != 4 || ack.ChunkIndex != 1 {
+		t.Fatalf("NACK of chunk 1: %v", ack)
+	}
+	// sent before the client learnt of the NACK: dropped
+	send(crcChunk("ijkl", "ijkl", 2))
+	send(commit)
+	// and sent again
+	send(crcChunk("efgh", "efgh", 1))
+	send(crcChunk("ijkl", "ijkl", 2))
+	send(commit)
+	if err := stream.CloseRequest(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []int64{8, 12} {
+		if ack := receiveAck(); ack.Offset != want {
+			t.Fatalf("ack %v, want offset %d", ack, want)
+		}
+	}
+	msg, err := stream.Receive()
+	if err != nil || !msg.GetResult().GetHashOk() {
+		t.Fatalf("result %v, %v", msg, err)
+	}
+	if got := ts.stored(t, "crc.txt"); got != "abcdefghijkl" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestUploadBidiNackLimit(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	stream, err := ts.client.UploadBidi(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.CloseResponse()
+	stream.Send(&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{
+		Metadata: &fileuploadv1.UploadMetadata{Filename: "crc.txt"},
+	}})
+	for range maxChunkNacks + 1 {
+		if err := stream.Send(crcChunk("bad", "good", 0)); err != nil {
+			break
+		}
+	}
+	stream.CloseRequest()
+	for {
+		msg, err := stream.Receive()
+		if err != nil {
+			if connect.CodeOf(err) != connect.CodeDataLoss {
+				t.Fatalf("chunk corrupt every time: %v, want data loss", err)
+			}
+			break
+		}
+		if msg.GetAck().GetStatus() != fileuploadv1.ChunkStatus_CHUNK_STATUS_CORRUPT {
+			t.Fatalf("received %v, want NACKs", msg)
+		}
+	}
+}
+
+func TestUploadChunkCRC(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	upload := func(chunk *fileuploadv1.UploadRequest) error {
+		stream, err := ts.client.Upload(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range []*fileuploadv1.UploadRequest{
+			{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: "crc.txt"}}},
+			chunk,
+			{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("data")}},
+		} {
+			if err := stream.Send(req); err != nil {
+				break
+			}
+		}
+		_, err = stream.CloseAndReceive()
+		return err
+	}
+	if err := upload(crcChunk("data", "data", 0)); err != nil {
+		t.Fatal(err)
+	}
+	// without acks there is no NACK, a corrupt chunk fails the upload
+	if err := upload(crcChunk("data", "dXta", 0)); connect.CodeOf(err) != connect.CodeDataLoss {
+		t.Fatalf("corrupt chunk on Upload: %v, want data loss", err)
+	}
+}
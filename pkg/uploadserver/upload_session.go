@@ -0,0 +1,94 @@
+package uploadserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// uploadSessionHeader carries the upload ID of a session on every call of
+// it, for load balancers to route the calls of one session to the server
+// holding it
+const uploadSessionHeader = "Upload-Session"
+
+// CreateUpload starts an upload session of req.Size bytes. Sessions are tus
+// uploads: the chunks may be sent with UploadChunk or to upload_url.
+func (s *Server) CreateUpload(
+	ctx context.Context, req *fileuploadv1.CreateUploadRequest) (*fileuploadv1.UploadSession, error) {
+
+	if req.Size < 0 {
+		return nil, fieldViolation("size", "must not be negative")
+	}
+	sess, err := s.createTusSession(ctx, req.Filename, req.Title, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	if info, ok := connect.CallInfoForHandlerContext(ctx); ok {
+		info.ResponseHeader().Set(uploadSessionHeader, sess.ID)
+	}
+	return uploadSession(sess, 0), nil
+}
+
+// UploadChunk appends req.Data to a session at req.Offset and stores the
+// file once it has all its bytes
+func (s *Server) UploadChunk(
+	ctx context.Context, req *fileuploadv1.UploadChunkRequest) (*fileuploadv1.UploadChunkResponse, error) {
+
+	if err := checkSessionHeader(ctx, req.UploadId); err != nil {
+		return nil, err
+	}
+	sess, offset, err := s.loadTusSession(req.UploadId)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeNotFound, errTusNotFound)
+	}
+	if req.Offset == offset && int64(len(req.Data)) > sess.Length-offset {
+		return nil, fieldViolation("data", "has %d bytes, the session only has %d left", len(req.Data), sess.Length-offset)
+	}
+	offset, stored, err := s.appendTusSession(ctx, "UploadChunk", req.UploadId, req.Offset, bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, err
+	}
+	return &fileuploadv1.UploadChunkResponse{Offset: offset, Stored: stored}, nil
+}
+
+// GetUploadSession reports the offset of a session, to resume it
+func (s *Server) GetUploadSession(
+	ctx context.Context, req *fileuploadv1.GetUploadSessionRequest) (*fileuploadv1.UploadSession, error) {
+
+	if err := checkSessionHeader(ctx, req.UploadId); err != nil {
+		return nil, err
+	}
+	sess, offset, err := s.loadTusSession(req.UploadId)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeNotFound, errTusNotFound)
+	}
+	return uploadSession(sess, offset), nil
+}
+
+func uploadSession(sess *tusSession, offset int64) *fileuploadv1.UploadSession {
+	return &fileuploadv1.UploadSession{
+		UploadId:  sess.ID,
+		UploadUrl: tusBasePath + sess.ID,
+		Filename:  sess.Filename,
+		Size:      sess.Length,
+		Offset:    offset,
+	}
+}
+
+// checkSessionHeader refuses a call whose Upload-Session header names another
+// session than id: it was routed for that session, maybe to the wrong server.
+// The header is optional, a server that holds every session needs none.
+func checkSessionHeader(ctx context.Context, id string) error {
+	info, ok := connect.CallInfoForHandlerContext(ctx)
+	if !ok {
+		return nil
+	}
+	if h := info.RequestHeader().Get(uploadSessionHeader); h != "" && h != id {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("upload-session header does not match upload_id"))
+	}
+	return nil
+}
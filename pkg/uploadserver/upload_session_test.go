@@ -0,0 +1,154 @@
+package uploadserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// inSession returns ctx for calls carrying the Upload-Session header of id
+func inSession(ctx context.Context, id string) context.Context {
+	ctx, info := connect.NewClientContext(ctx)
+	info.RequestHeader().Set(uploadSessionHeader, id)
+	return ctx
+}
+
+// createUpload starts a session of size bytes for filename
+func (ts *testServer) createUpload(t *testing.T, filename string, size int64) *fileuploadv1.UploadSession {
+	t.Helper()
+	ctx, info := connect.NewClientContext(t.Context())
+	sess, err := ts.client.CreateUpload(ctx, &fileuploadv1.CreateUploadRequest{Filename: filename, Size: size})
+	if err != nil {
+		t.Fatalf("create upload: %v", err)
+	}
+	if got := info.ResponseHeader().Get(uploadSessionHeader); got != sess.UploadId {
+		t.Fatalf("Upload-Session header %q, want the upload ID %q", got, sess.UploadId)
+	}
+	return sess
+}
+
+// sendChunk sends data at offset to the session id
+func (ts *testServer) sendChunk(t *testing.T, id string, offset int64, data string) (*fileuploadv1.UploadChunkResponse, error) {
+	t.Helper()
+	return ts.client.UploadChunk(inSession(t.Context(), id), &fileuploadv1.UploadChunkRequest{
+		UploadId: id, Offset: offset, Data: []byte(data),
+	})
+}
+
+func TestUploadSession(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	sess := ts.createUpload(t, "notes.txt", 11)
+	if sess.Offset != 0 || sess.Size != 11 || sess.Filename != "notes.txt" || sess.UploadUrl != tusBasePath+sess.UploadId {
+		t.Fatalf("session %v", sess)
+	}
+
+	resp, err := ts.sendChunk(t, sess.UploadId, 0, "hello ")
+	if err != nil || resp.Offset != 6 || resp.Stored != nil {
+		t.Fatalf("first chunk: %v, %v; want offset 6 and nothing stored", resp, err)
+	}
+	if _, err := ts.sendChunk(t, sess.UploadId, 0, "hello "); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+		t.Fatalf("chunk at a stale offset: %v, want failed precondition", err)
+	}
+	if _, err := ts.sendChunk(t, sess.UploadId, 6, "world and more"); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("chunk past the size: %v, want invalid argument", err)
+	}
+	status, err := ts.client.GetUploadSession(inSession(t.Context(), sess.UploadId), &fileuploadv1.GetUploadSessionRequest{UploadId: sess.UploadId})
+	if err != nil || status.Offset != 6 {
+		t.Fatalf("session %v, %v; want offset 6", status, err)
+	}
+
+	resp, err = ts.sendChunk(t, sess.UploadId, 6, "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Offset != 11 || resp.Stored.GetStoredFilename() != "notes.txt" || resp.Stored.GetSha256() != sha256Hex("hello world") {
+		t.Fatalf("last chunk: %v", resp)
+	}
+	if got := ts.stored(t, "notes.txt"); got != "hello world" {
+		t.Fatalf("stored %q", got)
+	}
+	// a completed session is gone
+	_, err = ts.client.GetUploadSession(t.Context(), &fileuploadv1.GetUploadSessionRequest{UploadId: sess.UploadId})
+	if connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("session after completion: %v, want not found", err)
+	}
+}
+
+func TestUploadSessionHeaderMismatch(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	sess := ts.createUpload(t, "a.txt", 3)
+	_, err := ts.client.UploadChunk(inSession(t.Context(), "another"), &fileuploadv1.UploadChunkRequest{UploadId: sess.UploadId, Data: []byte("abc")})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("chunk routed for another session: %v, want invalid argument", err)
+	}
+	// the header is optional
+	if resp, err := ts.client.UploadChunk(t.Context(), &fileuploadv1.UploadChunkRequest{UploadId: sess.UploadId, Data: []byte("abc")}); err != nil || resp.Stored == nil {
+		t.Fatalf("chunk without the header: %v, %v", resp, err)
+	}
+}
+
+func TestUploadSessionOverTus(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	sess := ts.createUpload(t, "mixed.txt", 11)
+	if resp := ts.tusPatch(t, sess.UploadUrl, 0, "hello "); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PATCH to upload_url: status %d", resp.StatusCode)
+	}
+	resp, err := ts.sendChunk(t, sess.UploadId, 6, "world")
+	if err != nil || resp.Stored == nil {
+		t.Fatalf("last chunk: %v, %v", resp, err)
+	}
+	if got := ts.stored(t, "mixed.txt"); got != "hello world" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestUploadSessionEmpty(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	sess := ts.createUpload(t, "empty.txt", 0)
+	resp, err := ts.sendChunk(t, sess.UploadId, 0, "")
+	if err != nil || resp.Stored == nil {
+		t.Fatalf("empty chunk: %v, %v; want the file stored", resp, err)
+	}
+	if got := ts.stored(t, "empty.txt"); got != "" {
+		t.Fatalf("stored %q", got)
+	}
+}
+
+func TestUploadSessionRefused(t *testing.T) {
+	ts := newTestServer(t, &Server{extensions: map[string]ExtensionPolicy{"*": {Deny: []string{".exe"}}}})
+	_, err := ts.client.CreateUpload(t.Context(), &fileuploadv1.CreateUploadRequest{Filename: "setup.exe", Size: 3})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("session of a denied file type: %v, want invalid argument", err)
+	}
+	_, err = ts.client.CreateUpload(t.Context(), &fileuploadv1.CreateUploadRequest{Filename: "a.txt", Size: -1})
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Fatalf("session of a negative size: %v, want invalid argument", err)
+	}
+	_, err = ts.client.UploadChunk(t.Context(), &fileuploadv1.UploadChunkRequest{UploadId: "0123456789abcdef0123456789abcdef", Data: []byte("abc")})
+	if connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("chunk of an unknown session: %v, want not found", err)
+	}
+}
+
+func TestUploadSessionKeyed(t *testing.T) {
+	ts := newKeyedServer(t, "{namespace}/{filename}")
+	ctx := inNamespace(t.Context(), "acme")
+	sess, err := ts.srv.CreateUpload(ctx, &fileuploadv1.CreateUploadRequest{Filename: "a.txt", Size: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ts.srv.UploadChunk(ctx, &fileuploadv1.UploadChunkRequest{UploadId: sess.UploadId, Data: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Stored.StoredFilename != "a.txt" || resp.Stored.StoragePath != "acme/a.txt" {
+		t.Fatalf("stored %v, want a.txt at acme/a.txt", resp.Stored)
+	}
+	if got := ts.stored(t, "acme/a.txt"); got != "hello" {
+		t.Fatalf("stored %q", got)
+	}
+}
@@ -0,0 +1,139 @@
+package uploadserver
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+	"github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1/fileuploadv1connect"
+)
+
+// fieldViolation returns the CodeInvalidArgument error refusing field, with
+// a FieldViolation detail naming it for clients
+func fieldViolation(field, format string, args ...any) error {
+	description := fmt.Sprintf(format, args...)
+	err := connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("%s %s", field, description))
+	if detail, detailErr := connect.NewErrorDetail(&fileuploadv1.FieldViolation{Field: field, Description: description}); detailErr == nil {
+		err.AddDetail(detail)
+	}
+	return err
+}
+
+// metadataRules are the checks on the metadata of an upload that need
+// nothing but the request: validationInterceptor applies them before the
+// handler runs, the plain-HTTP and tus handlers call them directly
+type metadataRules struct {
+	maxTitleLength int // in bytes, 0 is unlimited
+}
+
+func (m metadataRules) title(title string) error {
+	if m.maxTitleLength > 0 && len(title) > m.maxTitleLength {
+		return fieldViolation("title", "is %d bytes, over the limit of %d", len(title), m.maxTitleLength)
+	}
+	return nil
+}
+
+// sha256Field checks the optional hex-encoded SHA-256 in field
+func sha256Field(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if b, err := hex.DecodeString(value); err != nil || len(b) != 32 {
+		return fieldViolation(field, "is not a hex-encoded SHA-256")
+	}
+	return nil
+}
+
+func (m metadataRules) uploadMetadata(md *fileuploadv1.UploadMetadata) error {
+	if err := m.title(md.Title); err != nil {
+		return err
+	}
+	if md.DeclaredSize != nil && md.GetDeclaredSize() < 0 {
+		return fieldViolation("declared_size", "must not be negative")
+	}
+	if md.SegmentSize < 0 {
+		return fieldViolation("segment_size", "must not be negative")
+	}
+	if err := sha256Field("if_match_sha256", md.IfMatchSha256); err != nil {
+		return err
+	}
+	_, err := expiryTime(md.TtlSeconds, md.ExpiresUnix, time.Now())
+	return err
+}
+
+func (m metadataRules) uploadFileRequest(req *fileuploadv1.UploadFileRequest) error {
+	if err := m.title(req.Title); err != nil {
+		return err
+	}
+	if req.Offset != nil && req.GetOffset() < 0 {
+		return fieldViolation("offset", "must not be negative")
+	}
+	if err := sha256Field("if_match_sha256", req.IfMatchSha256); err != nil {
+		return err
+	}
+	if err := sha256Field("prefix_sha256", req.PrefixSha256); err != nil {
+		return err
+	}
+	_, err := expiryTime(req.TtlSeconds, req.ExpiresUnix, time.Now())
+	return err
+}
+
+// validationInterceptor refuses malformed upload metadata with
+// CodeInvalidArgument before the handler runs: unary requests as a whole, and
+// the metadata message of upload streams as it is received
+type validationInterceptor struct {
+	rules metadataRules
+}
+
+func (v validationInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		var err error
+		switch msg := req.Any().(type) {
+		case *fileuploadv1.UploadFileRequest:
+			err = v.rules.uploadFileRequest(msg)
+		case *fileuploadv1.BeginArchiveRequest:
+			err = v.rules.title(msg.Title)
+		case *fileuploadv1.CreateUploadRequest:
+			err = v.rules.title(msg.Title)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (v validationInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (v validationInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		switch conn.Spec().Procedure {
+		case fileuploadv1connect.FileUploadServiceUploadProcedure, fileuploadv1connect.FileUploadServiceUploadBidiProcedure:
+			conn = validatingConn{conn, v.rules}
+		}
+		return next(ctx, conn)
+	}
+}
+
+// validatingConn checks every UploadMetadata message received on an upload
+// stream, failing the receive of a malformed one
+type validatingConn struct {
+	connect.StreamingHandlerConn
+	rules metadataRules
+}
+
+func (c validatingConn) Receive(msg any) error {
+	if err := c.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	if req, ok := msg.(*fileuploadv1.UploadRequest); ok && req.GetMetadata() != nil {
+		return c.rules.uploadMetadata(req.GetMetadata())
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package uploadserver
+
+import (
+	"errors"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// violatedField returns the field named by the FieldViolation detail of err,
+// "" when it has none
+func violatedField(t *testing.T, err error) string {
+	t.Helper()
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeInvalidArgument {
+		t.Fatalf("%v, want invalid argument", err)
+	}
+	for _, detail := range connectErr.Details() {
+		msg, err := detail.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := msg.(*fileuploadv1.FieldViolation); ok {
+			return v.Field
+		}
+	}
+	return ""
+}
+
+func TestValidateUploadFileRequest(t *testing.T) {
+	ts := newTestServer(t, &Server{rules: metadataRules{maxTitleLength: 8}})
+	for want, req := range map[string]*fileuploadv1.UploadFileRequest{
+		"title":           {Title: "far too long"},
+		"offset":          {Offset: proto.Int64(-1)},
+		"if_match_sha256": {IfMatchSha256: "not hex"},
+		"prefix_sha256":   {PrefixSha256: sha256Hex("a")[:10]},
+		"ttl_seconds":     {TtlSeconds: 60, ExpiresUnix: 1},
+		"expires_unix":    {ExpiresUnix: 1},
+	} {
+		req.Filename, req.Data = "a.txt", []byte("data")
+		_, err := ts.client.UploadFile(t.Context(), req)
+		if got := violatedField(t, err); got != want {
+			t.Errorf("violation of %q reported for %q", got, want)
+		}
+	}
+	ts.assertNotStored(t, "a.txt")
+}
+
+func TestValidateUploadMetadata(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	for want, md := range map[string]*fileuploadv1.UploadMetadata{
+		"declared_size":   {DeclaredSize: proto.Int64(-1)},
+		"segment_size":    {SegmentSize: -4},
+		"if_match_sha256": {IfMatchSha256: "xyz"},
+		"ttl_seconds":     {TtlSeconds: -1},
+	} {
+		md.Filename = "a.txt"
+		_, err := ts.sendUpload(t,
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: md}},
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("data")}},
+			&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("data")}},
+		)
+		if got := violatedField(t, err); got != want {
+			t.Errorf("violation of %q reported for %q", got, want)
+		}
+	}
+	ts.assertNotStored(t, "a.txt")
+
+	// well-formed metadata goes through
+	if _, err := ts.streamUpload(t.Context(), "a.txt", []byte("data"), 2); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,147 @@
+package uploadserver
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// watchBuffer is how many events wait for a WatchEvents subscriber before
+// newer ones are dropped for it
+const watchBuffer = 256
+
+var errServerStopping = errors.New("server is stopping")
+
+// eventBus fans server events out to the WatchEvents subscribers. Publishing
+// never blocks: a subscriber whose buffer is full misses the event, and the
+// next one it receives tells how many it missed.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*watcher]struct{}
+}
+
+type watcher struct {
+	types     []fileuploadv1.EventType // every type when empty
+	namespace string                   // every namespace when empty
+	events    chan *fileuploadv1.ServerEvent
+	dropped   atomic.Uint64
+}
+
+func (w *watcher) wants(event *fileuploadv1.ServerEvent) bool {
+	if len(w.types) > 0 && !slices.Contains(w.types, event.Type) {
+		return false
+	}
+	return w.namespace == "" || w.namespace == event.Namespace
+}
+
+func (b *eventBus) subscribe(types []fileuploadv1.EventType, namespace string) *watcher {
+	w := &watcher{types: types, namespace: namespace, events: make(chan *fileuploadv1.ServerEvent, watchBuffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[*watcher]struct{})
+	}
+	b.subs[w] = struct{}{}
+	return w
+}
+
+func (b *eventBus) unsubscribe(w *watcher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, w)
+}
+
+// publish hands event to every interested subscriber; they share it, so it
+// must not be changed afterwards
+func (b *eventBus) publish(event *fileuploadv1.ServerEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for w := range b.subs {
+		if !w.wants(event) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			w.dropped.Add(1)
+		}
+	}
+}
+
+// broadcastUpload reports the outcome of an upload of filename, stored under
+// stored, to the WatchEvents subscribers
+func (s *Server) broadcastUpload(ctx context.Context, rpc, filename, stored string, size int64, err error) {
+	c := callerFrom(ctx)
+	event := &fileuploadv1.ServerEvent{
+		Type:      fileuploadv1.EventType_EVENT_TYPE_UPLOAD,
+		TimeUnix:  time.Now().Unix(),
+		Rpc:       rpc,
+		Filename:  filename,
+		Size:      size,
+		Code:      "ok",
+		Identity:  c.identity,
+		Namespace: c.namespace,
+	}
+	if stored != filename {
+		event.StoredFilename = stored
+	}
+	if err != nil {
+		event.Type = fileuploadv1.EventType_EVENT_TYPE_ERROR
+		event.Code = connect.CodeOf(err).String()
+	}
+	s.watchers.publish(event)
+}
+
+// recordDelete reports the deletion of the expired file filename to the
+// WatchEvents subscribers
+func (s *Server) recordDelete(filename string) {
+	s.watchers.publish(&fileuploadv1.ServerEvent{
+		Type:     fileuploadv1.EventType_EVENT_TYPE_DELETE,
+		TimeUnix: time.Now().Unix(),
+		Filename: filename,
+		Code:     "ok",
+	})
+}
+
+// WatchEvents streams the server events matching the request until the
+// client goes away or Config.Context is done
+func (s *Server) WatchEvents(
+	ctx context.Context, req *fileuploadv1.WatchEventsRequest, stream *connect.ServerStream[fileuploadv1.ServerEvent]) error {
+
+	for _, t := range req.Types {
+		if _, ok := fileuploadv1.EventType_name[int32(t)]; !ok || t == fileuploadv1.EventType_EVENT_TYPE_UNSPECIFIED {
+			return fieldViolation("types", "has the unknown event type %d", t)
+		}
+	}
+	w := s.watchers.subscribe(req.Types, req.Namespace)
+	defer s.watchers.unsubscribe(w)
+	// the response headers tell the client it is subscribed
+	if err := stream.Send(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return contextError(ctx)
+		case <-s.stopping:
+			return connect.NewError(connect.CodeUnavailable, errServerStopping)
+		case event := <-w.events:
+			if dropped := w.dropped.Swap(0); dropped > 0 {
+				// the event is shared with the other subscribers
+				event = proto.Clone(event).(*fileuploadv1.ServerEvent)
+				event.Dropped = dropped
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package uploadserver
+
+import (
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	fileuploadv1 "github.com/lao-tseu-is-alive/go-grpc-file-upload/gen/fileupload/v1"
+)
+
+// watch subscribes to WatchEvents with req and returns the received events
+// on a channel
+func (ts *testServer) watch(t *testing.T, req *fileuploadv1.WatchEventsRequest) <-chan *fileuploadv1.ServerEvent {
+	t.Helper()
+	// the call returns once the server has subscribed
+	stream, err := ts.client.WatchEvents(t.Context(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *fileuploadv1.ServerEvent, watchBuffer)
+	go func() {
+		defer close(events)
+		defer stream.Close()
+		for stream.Receive() {
+			events <- stream.Msg()
+		}
+	}()
+	return events
+}
+
+// nextEvent returns the next event from events, failing after a while
+func nextEvent(t *testing.T, events <-chan *fileuploadv1.ServerEvent) *fileuploadv1.ServerEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	all := ts.watch(t, &fileuploadv1.WatchEventsRequest{})
+	errs := ts.watch(t, &fileuploadv1.WatchEventsRequest{Types: []fileuploadv1.EventType{fileuploadv1.EventType_EVENT_TYPE_ERROR}})
+
+	ts.uploadFile(t, "a.txt", "data")
+	e := nextEvent(t, all)
+	if e.Type != fileuploadv1.EventType_EVENT_TYPE_UPLOAD || e.Rpc != "UploadFile" || e.Filename != "a.txt" || e.Size != 4 || e.Code != "ok" || e.TimeUnix == 0 {
+		t.Fatalf("upload event %v", e)
+	}
+
+	_, err := ts.sendUpload(t,
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Metadata{Metadata: &fileuploadv1.UploadMetadata{Filename: "bad.txt"}}},
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_Chunk{Chunk: []byte("data")}},
+		&fileuploadv1.UploadRequest{Payload: &fileuploadv1.UploadRequest_FinishCommit{FinishCommit: sha256Hex("other")}},
+	)
+	if err == nil {
+		t.Fatal("upload with a wrong hash succeeded")
+	}
+	for _, events := range []<-chan *fileuploadv1.ServerEvent{all, errs} {
+		if e := nextEvent(t, events); e.Type != fileuploadv1.EventType_EVENT_TYPE_ERROR || e.Filename != "bad.txt" || e.Code != connect.CodeOf(err).String() {
+			t.Fatalf("error event %v for %v", e, err)
+		}
+	}
+
+	if _, err := ts.uploadExpiring(t, "temp.txt", "temporary", 60, 0); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, all)
+	ts.expire("temp.txt")
+	ts.srv.sweepExpired()
+	if e := nextEvent(t, all); e.Type != fileuploadv1.EventType_EVENT_TYPE_DELETE || e.Filename != "temp.txt" {
+		t.Fatalf("delete event %v", e)
+	}
+
+	// the error watcher saw nothing else
+	select {
+	case e := <-errs:
+		t.Fatalf("error watcher received %v", e)
+	default:
+	}
+}
+
+func TestWatchEventsNamespace(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	events := ts.watch(t, &fileuploadv1.WatchEventsRequest{Namespace: "acme"})
+	for _, namespace := range []string{"other", "acme"} {
+		_, err := ts.srv.UploadFile(inNamespace(t.Context(), namespace), &fileuploadv1.UploadFileRequest{
+			Filename: namespace + ".txt", Data: []byte("data"), Sha256: sha256Hex("data"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e := nextEvent(t, events); e.Filename != "acme.txt" || e.Namespace != "acme" || e.Identity != "acme-client" {
+		t.Fatalf("event %v, want the upload of namespace acme only", e)
+	}
+}
+
+func TestWatchEventsUnknownType(t *testing.T) {
+	ts := newTestServer(t, &Server{})
+	stream, err := ts.client.WatchEvents(t.Context(), &fileuploadv1.WatchEventsRequest{Types: []fileuploadv1.EventType{42}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	for stream.Receive() {
+	}
+	if connect.CodeOf(stream.Err()) != connect.CodeInvalidArgument {
+		t.Fatalf("unknown event type: %v, want invalid argument", stream.Err())
+	}
+}
+
+func TestEventBusDropsForSlowSubscribers(t *testing.T) {
+	var bus eventBus
+	w := bus.subscribe(nil, "")
+	for range watchBuffer + 3 {
+		bus.publish(&fileuploadv1.ServerEvent{Type: fileuploadv1.EventType_EVENT_TYPE_UPLOAD})
+	}
+	if len(w.events) != watchBuffer || w.dropped.Load() != 3 {
+		t.Fatalf("%d events buffered and %d dropped, want %d and 3", len(w.events), w.dropped.Load(), watchBuffer)
+	}
+	bus.unsubscribe(w)
+	bus.publish(&fileuploadv1.ServerEvent{})
+	if w.dropped.Load() != 3 {
+		t.Fatal("an unsubscribed watcher still receives events")
+	}
+}